@@ -0,0 +1,194 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/chzyer/readline"
+	"github.com/gorilla/websocket"
+
+	"github.com/sipeed/picoclaw/pkg/channels"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// localChatHandler serves the local channel's WebSocket endpoint. The
+// channel is looked up per request so it keeps working across config
+// reloads that restart it.
+func localChatHandler(manager *channels.Manager) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ch, ok := manager.GetChannel("local")
+		handler, isHandler := ch.(http.Handler)
+		if !ok || !isHandler {
+			http.Error(w, "local channel is not enabled", http.StatusNotFound)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+func chatCmd() {
+	message := ""
+	session := "default"
+	token := ""
+
+	args := os.Args[2:]
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "-m", "--message":
+			if i+1 < len(args) {
+				message = args[i+1]
+				i++
+			}
+		case "-s", "--session":
+			if i+1 < len(args) {
+				session = args[i+1]
+				i++
+			}
+		case "--token":
+			if i+1 < len(args) {
+				token = args[i+1]
+				i++
+			}
+		case "-h", "--help":
+			chatHelp()
+			return
+		}
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+	if token == "" {
+		token = cfg.Channels.Local.Token
+	}
+
+	conn, err := dialLocalChat(cfg, session, token)
+	if err != nil {
+		fmt.Printf("Error connecting to gateway: %v\n", err)
+		os.Exit(1)
+	}
+	defer conn.Close()
+
+	if message != "" {
+		if err := conn.WriteJSON(channels.LocalFrame{Type: "message", Content: message}); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		for {
+			var frame channels.LocalFrame
+			if err := conn.ReadJSON(&frame); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			if frame.Type == "message" {
+				fmt.Printf("\n%s %s\n", logo, frame.Content)
+				return
+			}
+		}
+	}
+
+	fmt.Printf("%s Connected to gateway session %q (Ctrl+C to exit)\n\n", logo, session)
+	chatInteractive(conn)
+}
+
+func chatHelp() {
+	fmt.Println("\nUsage: picoclaw chat [options]")
+	fmt.Println()
+	fmt.Println("Chat with the running gateway's agent through the local channel.")
+	fmt.Println()
+	fmt.Println("Options:")
+	fmt.Println("  -m, --message <text>   Send one message, print the reply and exit")
+	fmt.Println("  -s, --session <name>   Session to join (default: default)")
+	fmt.Println("  --token <token>        Token, if channels.local.token is set on the gateway")
+}
+
+// dialLocalChat opens the WebSocket to the gateway's local channel.
+func dialLocalChat(cfg *config.Config, session, token string) (*websocket.Conn, error) {
+	u, err := url.Parse(gatewayURL(cfg, channels.LocalChatPath))
+	if err != nil {
+		return nil, err
+	}
+	u.Scheme = "ws"
+	query := url.Values{"session": {session}}
+	if user := os.Getenv("USER"); user != "" {
+		query.Set("user", user)
+	}
+	u.RawQuery = query.Encode()
+
+	header := http.Header{}
+	if token != "" {
+		header.Set("Authorization", "Bearer "+token)
+	}
+	conn, resp, err := websocket.DefaultDialer.Dial(u.String(), header)
+	if err != nil && resp != nil {
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return conn, err
+}
+
+// chatInteractive reads lines from the terminal and prints replies as they
+// arrive, including ones the agent sends on its own (cron, subagents).
+func chatInteractive(conn *websocket.Conn) {
+	rl, err := readline.NewEx(&readline.Config{
+		Prompt:          fmt.Sprintf("%s You: ", logo),
+		HistoryFile:     filepath.Join(os.TempDir(), ".picoclaw_history"),
+		HistoryLimit:    100,
+		InterruptPrompt: "^C",
+		EOFPrompt:       "exit",
+	})
+	if err != nil {
+		fmt.Printf("Error initializing readline: %v\n", err)
+		return
+	}
+	defer rl.Close()
+
+	go func() {
+		for {
+			var frame channels.LocalFrame
+			if err := conn.ReadJSON(&frame); err != nil {
+				fmt.Fprintf(rl.Stderr(), "\nDisconnected from gateway: %v\n", err)
+				rl.Close()
+				return
+			}
+			if frame.Type == "message" {
+				fmt.Fprintf(rl.Stdout(), "\n%s %s\n\n", logo, frame.Content)
+			}
+		}
+	}()
+
+	for {
+		line, err := rl.Readline()
+		if err != nil {
+			if err == readline.ErrInterrupt || err == io.EOF {
+				fmt.Println("\nGoodbye!")
+			}
+			return
+		}
+
+		input := strings.TrimSpace(line)
+		if input == "" {
+			continue
+		}
+		if input == "exit" || input == "quit" {
+			fmt.Println("Goodbye!")
+			return
+		}
+
+		if err := conn.WriteJSON(channels.LocalFrame{Type: "message", Content: input}); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+	}
+}
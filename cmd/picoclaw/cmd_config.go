@@ -0,0 +1,145 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func configCmd() {
+	if len(os.Args) < 3 {
+		configHelp()
+		return
+	}
+
+	subcommand := os.Args[2]
+
+	switch subcommand {
+	case "validate":
+		configValidateCmd()
+	case "schema":
+		configSchemaCmd()
+	case "get":
+		if len(os.Args) < 4 {
+			fmt.Println("Usage: picoclaw config get <path>")
+			os.Exit(1)
+		}
+		configGetCmd(os.Args[3])
+	case "set":
+		if len(os.Args) < 5 {
+			fmt.Println("Usage: picoclaw config set <path> <value>")
+			os.Exit(1)
+		}
+		configSetCmd(os.Args[3], os.Args[4])
+	case "--help", "-h", "help":
+		configHelp()
+	default:
+		fmt.Printf("Unknown config command: %s\n", subcommand)
+		configHelp()
+		os.Exit(1)
+	}
+}
+
+func configHelp() {
+	fmt.Println("\nConfig commands:")
+	fmt.Println("  validate             Check config.json for unknown keys, type errors and broken references")
+	fmt.Println("  schema               Print a JSON Schema for config.json")
+	fmt.Println("  get <path>           Print the effective value at a dotted path")
+	fmt.Println("  set <path> <value>   Set a value in config.json")
+	fmt.Println()
+	fmt.Println("Paths use dotted keys and list indices, e.g. channels.telegram.token or model_list.0.api_key.")
+	fmt.Println("String values are taken verbatim; other values are parsed as JSON.")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  picoclaw config validate")
+	fmt.Println("  picoclaw config schema > ~/.picoclaw/config.schema.json")
+	fmt.Println("  picoclaw config get agents.defaults.model")
+	fmt.Println("  picoclaw config set channels.telegram.enabled true")
+	fmt.Println("  picoclaw config set channels.telegram.allow_from '[\"123456\"]'")
+}
+
+func configValidateCmd() {
+	path := getConfigPath()
+	issues, err := config.ValidateFile(path)
+	if err != nil {
+		fmt.Printf("Error reading %s: %v\n", path, err)
+		os.Exit(1)
+	}
+
+	errors := 0
+	for _, issue := range issues {
+		fmt.Println(issue)
+		if issue.Severity == config.SeverityError {
+			errors++
+		}
+	}
+
+	if errors > 0 {
+		fmt.Printf("\n%s is invalid: %d error(s), %d warning(s)\n", path, errors, len(issues)-errors)
+		os.Exit(1)
+	}
+	if len(issues) > 0 {
+		fmt.Printf("\n%s is valid with %d warning(s)\n", path, len(issues))
+		return
+	}
+	fmt.Printf("✓ %s is valid\n", path)
+}
+
+func configSchemaCmd() {
+	data, err := json.MarshalIndent(config.Schema(), "", "  ")
+	if err != nil {
+		fmt.Printf("Error generating schema: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println(string(data))
+}
+
+func configGetCmd(path string) {
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	value, err := cfg.GetPath(path)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Scalars print bare so the output can be used directly in scripts.
+	switch v := value.(type) {
+	case string:
+		fmt.Println(v)
+	case json.Number:
+		fmt.Println(v.String())
+	case bool:
+		fmt.Println(v)
+	case nil:
+		fmt.Println("null")
+	default:
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(string(data))
+	}
+}
+
+func configSetCmd(path, value string) {
+	issues, err := config.SetPath(getConfigPath(), path, value)
+	for _, issue := range issues {
+		fmt.Println(issue)
+	}
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("✓ Set %s\n", path)
+}
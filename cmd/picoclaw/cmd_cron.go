@@ -66,6 +66,7 @@ func cronHelp() {
 	fmt.Println("  -d, --deliver     Deliver response to channel")
 	fmt.Println("  --to             Recipient for delivery")
 	fmt.Println("  --channel        Channel for delivery")
+	fmt.Println("  --no-cache       Always ask the model, never answer from the response cache")
 }
 
 func cronListCmd(storePath string) {
@@ -115,6 +116,7 @@ func cronAddCmd(storePath string) {
 	deliver := false
 	channel := ""
 	to := ""
+	noCache := false
 
 	args := os.Args[3:]
 	for i := 0; i < len(args); i++ {
@@ -153,6 +155,8 @@ func cronAddCmd(storePath string) {
 				channel = args[i+1]
 				i++
 			}
+		case "--no-cache":
+			noCache = true
 		}
 	}
 
@@ -191,6 +195,13 @@ func cronAddCmd(storePath string) {
 		fmt.Printf("Error adding job: %v\n", err)
 		return
 	}
+	if noCache {
+		job.Payload.NoCache = true
+		if err := cs.UpdateJob(job); err != nil {
+			fmt.Printf("Error adding job: %v\n", err)
+			return
+		}
+	}
 
 	fmt.Printf("✓ Added job '%s' (%s)\n", job.Name, job.ID)
 }
@@ -5,11 +5,15 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/sipeed/picoclaw/pkg/agent"
@@ -17,6 +21,7 @@ import (
 	"github.com/sipeed/picoclaw/pkg/channels"
 	"github.com/sipeed/picoclaw/pkg/config"
 	"github.com/sipeed/picoclaw/pkg/cron"
+	"github.com/sipeed/picoclaw/pkg/dashboard"
 	"github.com/sipeed/picoclaw/pkg/devices"
 	"github.com/sipeed/picoclaw/pkg/health"
 	"github.com/sipeed/picoclaw/pkg/heartbeat"
@@ -43,6 +48,10 @@ func gatewayCmd() {
 		fmt.Printf("Error loading config: %v\n", err)
 		os.Exit(1)
 	}
+	if err := checkConfig(cfg); err != nil {
+		fmt.Printf("Error: %v\nRun 'picoclaw config validate' for details.\n", err)
+		os.Exit(1)
+	}
 
 	provider, modelID, err := providers.CreateProvider(cfg)
 	if err != nil {
@@ -56,6 +65,9 @@ func gatewayCmd() {
 
 	msgBus := bus.NewMessageBus()
 	agentLoop := agent.NewAgentLoop(cfg, msgBus, provider)
+	if err := agentLoop.PersistSubagentTasks(); err != nil {
+		logger.WarnCF("gateway", "Failed to load subagent task registry", map[string]any{"error": err.Error()})
+	}
 
 	// Print agent startup info
 	fmt.Println("\n📦 Agent Status:")
@@ -92,14 +104,17 @@ func gatewayCmd() {
 		cfg.Heartbeat.Enabled,
 	)
 	heartbeatService.SetBus(msgBus)
-	heartbeatService.SetHandler(func(prompt, channel, chatID string) *tools.ToolResult {
+	heartbeatService.SetExecGuard(
+		tools.NewExecToolWithConfig(cfg.WorkspacePath(), cfg.Agents.Defaults.RestrictToWorkspace, cfg),
+	)
+	heartbeatService.SetHandler(func(ctx context.Context, prompt, channel, chatID string) *tools.ToolResult {
 		// Use cli:direct as fallback if no valid channel
 		if channel == "" || chatID == "" {
 			channel, chatID = "cli", "direct"
 		}
 		// Use ProcessHeartbeat - no session history, each heartbeat is independent
 		var response string
-		response, err = agentLoop.ProcessHeartbeat(context.Background(), prompt, channel, chatID)
+		response, err = agentLoop.ProcessHeartbeat(ctx, prompt, channel, chatID)
 		if err != nil {
 			return tools.ErrorResult(fmt.Sprintf("Heartbeat error: %v", err))
 		}
@@ -127,24 +142,7 @@ func gatewayCmd() {
 	}
 
 	if transcriber != nil {
-		if telegramChannel, ok := channelManager.GetChannel("telegram"); ok {
-			if tc, ok := telegramChannel.(*channels.TelegramChannel); ok {
-				tc.SetTranscriber(transcriber)
-				logger.InfoC("voice", "Groq transcription attached to Telegram channel")
-			}
-		}
-		if discordChannel, ok := channelManager.GetChannel("discord"); ok {
-			if dc, ok := discordChannel.(*channels.DiscordChannel); ok {
-				dc.SetTranscriber(transcriber)
-				logger.InfoC("voice", "Groq transcription attached to Discord channel")
-			}
-		}
-		if slackChannel, ok := channelManager.GetChannel("slack"); ok {
-			if sc, ok := slackChannel.(*channels.SlackChannel); ok {
-				sc.SetTranscriber(transcriber)
-				logger.InfoC("voice", "Groq transcription attached to Slack channel")
-			}
-		}
+		attachTranscriber(channelManager, transcriber)
 	}
 
 	enabledChannels := channelManager.GetEnabledChannels()
@@ -187,6 +185,29 @@ func gatewayCmd() {
 	}
 
 	healthServer := health.NewServer(cfg.Gateway.Host, cfg.Gateway.Port)
+	healthServer.Handle("/ratelimits", loopbackOnly(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(providers.RateLimitStatuses())
+	})))
+	outbox := outboxHandler(channelManager.Outbox())
+	healthServer.Handle("/outbox", outbox)
+	healthServer.Handle("/outbox/", outbox)
+	healthServer.Handle(channels.LocalChatPath, localChatHandler(channelManager))
+	if cfg.Gateway.Dashboard.Enabled {
+		dashboardServer, err := dashboard.NewServer(dashboard.Options{
+			Token:    cfg.Gateway.Dashboard.Token,
+			Channels: channelManager,
+			Agents:   agentLoop,
+			Cron:     cronService,
+		})
+		if err != nil {
+			fmt.Printf("⚠ Dashboard disabled: %v\n", err)
+		} else {
+			healthServer.Handle(dashboard.BasePath, dashboardServer)
+			fmt.Printf("✓ Dashboard available at http://%s:%d%s\n",
+				cfg.Gateway.Host, cfg.Gateway.Port, dashboard.BasePath)
+		}
+	}
 	go func() {
 		if err := healthServer.Start(); err != nil && err != http.ErrServerClosed {
 			logger.ErrorCF("health", "Health server error", map[string]any{"error": err.Error()})
@@ -196,11 +217,29 @@ func gatewayCmd() {
 
 	go agentLoop.Run(ctx)
 
+	reloader := &gatewayReloader{
+		ctx:         ctx,
+		cfg:         cfg,
+		agentLoop:   agentLoop,
+		channels:    channelManager,
+		transcriber: transcriber,
+	}
+	configWatcher := config.NewWatcher(getConfigPath(), config.DefaultWatchInterval, reloader.Reload)
+	configWatcher.Start()
+
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, os.Interrupt)
-	<-sigChan
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGHUP)
+	for sig := range sigChan {
+		if sig == syscall.SIGHUP {
+			logger.InfoC("gateway", "SIGHUP received, reloading config")
+			reloader.Reload()
+			continue
+		}
+		break
+	}
 
 	fmt.Println("\nShutting down...")
+	configWatcher.Stop()
 	cancel()
 	healthServer.Stop(context.Background())
 	deviceService.Stop()
@@ -211,6 +250,111 @@ func gatewayCmd() {
 	fmt.Println("✓ Gateway stopped")
 }
 
+// attachTranscriber wires voice transcription into channels that support it.
+func attachTranscriber(channelManager *channels.Manager, transcriber *voice.GroqTranscriber) {
+	if telegramChannel, ok := channelManager.GetChannel("telegram"); ok {
+		if tc, ok := telegramChannel.(*channels.TelegramChannel); ok {
+			tc.SetTranscriber(transcriber)
+			logger.InfoC("voice", "Groq transcription attached to Telegram channel")
+		}
+	}
+	if discordChannel, ok := channelManager.GetChannel("discord"); ok {
+		if dc, ok := discordChannel.(*channels.DiscordChannel); ok {
+			dc.SetTranscriber(transcriber)
+			logger.InfoC("voice", "Groq transcription attached to Discord channel")
+		}
+	}
+	if slackChannel, ok := channelManager.GetChannel("slack"); ok {
+		if sc, ok := slackChannel.(*channels.SlackChannel); ok {
+			sc.SetTranscriber(transcriber)
+			logger.InfoC("voice", "Groq transcription attached to Slack channel")
+		}
+	}
+	if whatsAppChannel, ok := channelManager.GetChannel("whatsapp_cloud"); ok {
+		if wc, ok := whatsAppChannel.(*channels.WhatsAppCloudChannel); ok {
+			wc.SetTranscriber(transcriber)
+			logger.InfoC("voice", "Groq transcription attached to WhatsApp Cloud channel")
+		}
+	}
+}
+
+// gatewayReloader applies config.json changes to a running gateway.
+type gatewayReloader struct {
+	mu          sync.Mutex
+	ctx         context.Context
+	cfg         *config.Config
+	agentLoop   *agent.AgentLoop
+	channels    *channels.Manager
+	transcriber *voice.GroqTranscriber
+}
+
+// checkConfig runs the full config validation and returns its errors, if
+// any, as one error. Warnings are ignored.
+func checkConfig(cfg *config.Config) error {
+	var errs []string
+	for _, issue := range cfg.Validate() {
+		if issue.Severity == config.SeverityError {
+			errs = append(errs, issue.Path+": "+issue.Message)
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("invalid config: %s", strings.Join(errs, "; "))
+}
+
+// Reload loads and validates the config file, then rebuilds providers and
+// agents and restarts only the channels whose config changed. An invalid
+// config leaves the running gateway untouched.
+func (r *gatewayReloader) Reload() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cfg, err := loadConfig()
+	if err == nil {
+		err = checkConfig(cfg)
+	}
+	if err != nil {
+		logger.ErrorCF("gateway", "Config reload rejected, keeping current config",
+			map[string]any{"error": err.Error()})
+		return
+	}
+
+	provider, modelID, err := providers.CreateProvider(cfg)
+	if err != nil {
+		logger.ErrorCF("gateway", "Config reload rejected: cannot create provider, keeping current config",
+			map[string]any{"error": err.Error()})
+		return
+	}
+	if modelID != "" {
+		cfg.Agents.Defaults.Model = modelID
+	}
+
+	if cfg.Gateway.Host != r.cfg.Gateway.Host || cfg.Gateway.Port != r.cfg.Gateway.Port ||
+		cfg.Gateway.Dashboard != r.cfg.Gateway.Dashboard {
+		logger.WarnC("gateway", "Gateway host/port/dashboard changes require a restart")
+	}
+	if cfg.Heartbeat != r.cfg.Heartbeat || cfg.Devices != r.cfg.Devices {
+		logger.WarnC("gateway", "Heartbeat and device changes require a restart")
+	}
+
+	r.agentLoop.ReloadConfig(cfg, provider)
+
+	changed, err := r.channels.ApplyConfig(r.ctx, cfg)
+	if err != nil {
+		logger.ErrorCF("gateway", "Some channels failed to apply new config",
+			map[string]any{"error": err.Error()})
+	}
+	if len(changed) > 0 && r.transcriber != nil {
+		attachTranscriber(r.channels, r.transcriber)
+	}
+
+	r.cfg = cfg
+	logger.InfoCF("gateway", "Config reloaded", map[string]any{
+		"channels_changed": changed,
+	})
+}
+
 func setupCronTool(
 	agentLoop *agent.AgentLoop,
 	msgBus *bus.MessageBus,
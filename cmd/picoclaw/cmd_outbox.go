@@ -0,0 +1,243 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/channels"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+// outboxState is what GET /outbox returns.
+type outboxState struct {
+	Pending     []channels.OutboxEntry `json:"pending"`
+	DeadLetters []channels.OutboxEntry `json:"dead_letters"`
+}
+
+// outboxHandler serves the outbox state at /outbox and requeues dead
+// letters on POST /outbox/resend with a JSON body {"ids": [...]} (no IDs
+// resends all). The messages can hold anything the agent said, so the
+// endpoints only answer requests from this machine.
+func outboxHandler(outbox *channels.Outbox) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /outbox", func(w http.ResponseWriter, r *http.Request) {
+		dead, err := outbox.DeadLetters()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(outboxState{Pending: outbox.Pending(), DeadLetters: dead})
+	})
+	mux.HandleFunc("POST /outbox/resend", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			IDs []string `json:"ids"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+		n, err := outbox.Resend(req.IDs)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]int{"resent": n})
+	})
+	return loopbackOnly(mux)
+}
+
+// loopbackOnly rejects requests that do not come from this machine: the
+// client must use a loopback address, or the address the gateway listens on
+// when it is bound to a specific interface.
+func loopbackOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		ip := net.ParseIP(host)
+		if err != nil || ip == nil || !(ip.IsLoopback() || isLocalAddr(r, ip)) {
+			http.Error(w, "only available from localhost", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isLocalAddr reports whether ip is the local address of r's connection.
+func isLocalAddr(r *http.Request, ip net.IP) bool {
+	local, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr)
+	if !ok {
+		return false
+	}
+	host, _, err := net.SplitHostPort(local.String())
+	return err == nil && ip.Equal(net.ParseIP(host))
+}
+
+func outboxCmd() {
+	subcommand := "list"
+	if len(os.Args) >= 3 {
+		subcommand = os.Args[2]
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		return
+	}
+
+	switch subcommand {
+	case "list":
+		outboxListCmd(cfg)
+	case "show":
+		if len(os.Args) < 4 {
+			fmt.Println("Usage: picoclaw outbox show <id>")
+			return
+		}
+		outboxShowCmd(cfg, os.Args[3])
+	case "resend":
+		if len(os.Args) < 4 {
+			fmt.Println("Usage: picoclaw outbox resend <id>... | --all")
+			return
+		}
+		ids := os.Args[3:]
+		if ids[0] == "--all" {
+			ids = nil
+		}
+		outboxResendCmd(cfg, ids)
+	default:
+		fmt.Printf("Unknown outbox command: %s\n", subcommand)
+		outboxHelp()
+	}
+}
+
+func outboxHelp() {
+	fmt.Println("\nOutbox commands:")
+	fmt.Println("  list                 List pending and permanently failed messages")
+	fmt.Println("  show <id>            Show a message in full")
+	fmt.Println("  resend <id>... | --all")
+	fmt.Println("                       Queue failed messages for delivery again")
+}
+
+// loadOutboxState asks the running gateway for the outbox state, or reads
+// the outbox files if the gateway is not running.
+func loadOutboxState(cfg *config.Config) (outboxState, error) {
+	client := &http.Client{Timeout: 2 * time.Second}
+	resp, err := client.Get(gatewayURL(cfg, "/outbox"))
+	if err == nil {
+		defer resp.Body.Close()
+		var state outboxState
+		if resp.StatusCode != http.StatusOK {
+			return state, fmt.Errorf("gateway returned %s", resp.Status)
+		}
+		err := json.NewDecoder(resp.Body).Decode(&state)
+		return state, err
+	}
+
+	outbox := localOutbox(cfg)
+	dead, err := outbox.DeadLetters()
+	return outboxState{Pending: outbox.Pending(), DeadLetters: dead}, err
+}
+
+func localOutbox(cfg *config.Config) *channels.Outbox {
+	return channels.NewOutbox(filepath.Join(cfg.WorkspacePath(), "outbox"), channels.OutboxOptions{}, nil)
+}
+
+func outboxListCmd(cfg *config.Config) {
+	state, err := loadOutboxState(cfg)
+	if err != nil {
+		fmt.Printf("Error reading outbox: %v\n", err)
+		return
+	}
+
+	fmt.Printf("\nPending (%d):\n", len(state.Pending))
+	for _, e := range state.Pending {
+		line := fmt.Sprintf("  %s  %s:%s  %s", e.ID, e.Message.Channel, e.Message.ChatID, outboxPreview(e))
+		if e.Attempts > 0 {
+			line += fmt.Sprintf("  [%d attempts, next in %s: %s]", e.Attempts,
+				time.Until(e.NextAttempt).Round(time.Second), utils.Truncate(e.LastError, 60))
+		}
+		fmt.Println(line)
+	}
+
+	fmt.Printf("\nFailed (%d):\n", len(state.DeadLetters))
+	for _, e := range state.DeadLetters {
+		fmt.Printf("  %s  %s:%s  %s  [%s, %d attempts: %s]\n", e.ID, e.Message.Channel, e.Message.ChatID,
+			outboxPreview(e), e.FailedAt.Format("2006-01-02 15:04"), e.Attempts, utils.Truncate(e.LastError, 60))
+	}
+	if len(state.DeadLetters) > 0 {
+		fmt.Println("\nResend with: picoclaw outbox resend <id>... | --all")
+	}
+}
+
+func outboxPreview(e channels.OutboxEntry) string {
+	preview := fmt.Sprintf("%q", utils.Truncate(e.Message.Content, 40))
+	if n := len(e.Message.Media); n > 0 {
+		preview += fmt.Sprintf(" +%d file(s)", n)
+	}
+	return preview
+}
+
+func outboxShowCmd(cfg *config.Config, id string) {
+	state, err := loadOutboxState(cfg)
+	if err != nil {
+		fmt.Printf("Error reading outbox: %v\n", err)
+		return
+	}
+	for _, e := range append(state.Pending, state.DeadLetters...) {
+		if e.ID == id {
+			data, _ := json.MarshalIndent(e, "", "  ")
+			fmt.Println(string(data))
+			return
+		}
+	}
+	fmt.Printf("No message with ID %s\n", id)
+}
+
+func outboxResendCmd(cfg *config.Config, ids []string) {
+	body, _ := json.Marshal(map[string][]string{"ids": ids})
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Post(gatewayURL(cfg, "/outbox/resend"), "application/json", bytes.NewReader(body))
+
+	var n int
+	if err == nil {
+		defer resp.Body.Close()
+		var result struct {
+			Resent int `json:"resent"`
+		}
+		if resp.StatusCode != http.StatusOK {
+			fmt.Printf("Error: gateway returned %s\n", resp.Status)
+			return
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		n = result.Resent
+	} else {
+		// Gateway not running: requeue on disk for its next start.
+		n, err = localOutbox(cfg).Resend(ids)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		if n > 0 {
+			fmt.Println("Gateway not running; messages will be sent when it starts.")
+		}
+	}
+
+	if n == 0 {
+		fmt.Println("No matching failed messages.")
+		return
+	}
+	fmt.Printf("✓ Requeued %d message(s)\n", n)
+}
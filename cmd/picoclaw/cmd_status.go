@@ -4,10 +4,21 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"net"
+	"net/http"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/sipeed/picoclaw/pkg/auth"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	ollamaprovider "github.com/sipeed/picoclaw/pkg/providers/ollama"
 )
 
 func statusCmd() {
@@ -55,7 +66,6 @@ func statusCmd() {
 		hasDeepSeek := cfg.Providers.DeepSeek.APIKey != ""
 		hasVolcEngine := cfg.Providers.VolcEngine.APIKey != ""
 		hasNvidia := cfg.Providers.Nvidia.APIKey != ""
-		hasOllama := cfg.Providers.Ollama.APIBase != ""
 
 		status := func(enabled bool) string {
 			if enabled {
@@ -79,11 +89,8 @@ func statusCmd() {
 		} else {
 			fmt.Println("vLLM/Local: not set")
 		}
-		if hasOllama {
-			fmt.Printf("Ollama: ✓ %s\n", cfg.Providers.Ollama.APIBase)
-		} else {
-			fmt.Println("Ollama: not set")
-		}
+		printOllamaStatus(cfg)
+		printRateLimitStatus(cfg)
 
 		store, _ := auth.LoadStore()
 		if store != nil && len(store.Credentials) > 0 {
@@ -100,3 +107,141 @@ func statusCmd() {
 		}
 	}
 }
+
+// printOllamaStatus probes every Ollama server referenced by model_list (or
+// the default local one) and lists the installed models, flagging configured
+// models that are not pulled yet.
+func printOllamaStatus(cfg *config.Config) {
+	configured := make(map[string][]string) // server -> configured model IDs
+	var servers []string
+	addServer := func(apiBase string) string {
+		base := ollamaprovider.NormalizeBaseURL(apiBase)
+		if _, ok := configured[base]; !ok {
+			configured[base] = nil
+			servers = append(servers, base)
+		}
+		return base
+	}
+	for _, m := range cfg.ModelList {
+		if protocol, modelID := providers.ExtractProtocol(m.Model); protocol == "ollama" {
+			base := addServer(m.APIBase)
+			configured[base] = append(configured[base], modelID)
+		}
+	}
+	if cfg.Providers.Ollama.APIBase != "" {
+		addServer(cfg.Providers.Ollama.APIBase)
+	}
+	if len(servers) == 0 {
+		addServer("")
+	}
+
+	for _, base := range servers {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		installed, err := providers.ListOllamaModels(ctx, base)
+		cancel()
+		if err != nil {
+			fmt.Printf("Ollama: not reachable at %s\n", base)
+			continue
+		}
+
+		fmt.Printf("Ollama: ✓ %s (%d models)\n", base, len(installed))
+		for _, name := range installed {
+			fmt.Printf("  %s\n", name)
+		}
+		for _, modelID := range configured[base] {
+			if !ollamaModelInstalled(installed, modelID) {
+				fmt.Printf("  %s (configured, not pulled)\n", modelID)
+			}
+		}
+	}
+}
+
+// printRateLimitStatus lists the rpm/tpm limits of model_list and, if the
+// gateway is running, the live state of each limiter's queue.
+func printRateLimitStatus(cfg *config.Config) {
+	var limited []config.ModelConfig
+	for _, m := range cfg.ModelList {
+		if m.RPM > 0 || m.TPM > 0 {
+			limited = append(limited, m)
+		}
+	}
+	if len(limited) == 0 {
+		return
+	}
+
+	fmt.Println("\nRate limits:")
+	live, err := fetchRateLimits(cfg)
+	if err != nil {
+		for _, m := range limited {
+			fmt.Printf("  %s: %s\n", m.Model, formatLimits(m.RPM, m.TPM))
+		}
+		fmt.Println("  (gateway not running, queue state unavailable)")
+		return
+	}
+	if len(live) == 0 {
+		fmt.Println("  no requests yet")
+	}
+	for _, st := range live {
+		name := st.Model
+		if st.KeyID != "" {
+			name += " [key " + st.KeyID + "]"
+		}
+		line := fmt.Sprintf("  %s: %s, %d queued, %d requests",
+			name, formatLimits(st.RPM, st.TPM), st.Waiting, st.Requests)
+		if st.TPM > 0 {
+			line += fmt.Sprintf(" / %d tokens", st.Tokens)
+		}
+		line += " available"
+		if !st.BlockedUntil.IsZero() {
+			line += fmt.Sprintf(", retry after %s", time.Until(st.BlockedUntil).Round(time.Second))
+		}
+		fmt.Println(line)
+	}
+}
+
+// gatewayURL returns the URL of path on the local gateway's HTTP server.
+func gatewayURL(cfg *config.Config, path string) string {
+	host := cfg.Gateway.Host
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "127.0.0.1"
+	}
+	return "http://" + net.JoinHostPort(host, strconv.Itoa(cfg.Gateway.Port)) + path
+}
+
+// fetchRateLimits asks a running gateway for its rate limiter state.
+func fetchRateLimits(cfg *config.Config) ([]providers.RateLimitStatus, error) {
+	client := &http.Client{Timeout: 2 * time.Second}
+	resp, err := client.Get(gatewayURL(cfg, "/ratelimits"))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("gateway returned %s", resp.Status)
+	}
+	var statuses []providers.RateLimitStatus
+	if err := json.NewDecoder(resp.Body).Decode(&statuses); err != nil {
+		return nil, err
+	}
+	return statuses, nil
+}
+
+func formatLimits(rpm, tpm int) string {
+	var parts []string
+	if rpm > 0 {
+		parts = append(parts, fmt.Sprintf("%d rpm", rpm))
+	}
+	if tpm > 0 {
+		parts = append(parts, fmt.Sprintf("%d tpm", tpm))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// ollamaModelInstalled matches modelID against installed names, treating a
+// missing tag as ":latest" the way Ollama does.
+func ollamaModelInstalled(installed []string, modelID string) bool {
+	if !strings.Contains(modelID, ":") {
+		modelID += ":latest"
+	}
+	return slices.Contains(installed, modelID)
+}
@@ -105,6 +105,8 @@ func main() {
 		onboard()
 	case "agent":
 		agentCmd()
+	case "chat":
+		chatCmd()
 	case "gateway":
 		gatewayCmd()
 	case "status":
@@ -115,6 +117,10 @@ func main() {
 		authCmd()
 	case "cron":
 		cronCmd()
+	case "config":
+		configCmd()
+	case "outbox":
+		outboxCmd()
 	case "skills":
 		if len(os.Args) < 3 {
 			skillsHelp()
@@ -180,10 +186,13 @@ func printHelp() {
 	fmt.Println("Commands:")
 	fmt.Println("  onboard     Initialize picoclaw configuration and workspace")
 	fmt.Println("  agent       Interact with the agent directly")
+	fmt.Println("  chat        Chat with the running gateway's agent")
 	fmt.Println("  auth        Manage authentication (login, logout, status)")
 	fmt.Println("  gateway     Start picoclaw gateway")
 	fmt.Println("  status      Show picoclaw status")
 	fmt.Println("  cron        Manage scheduled tasks")
+	fmt.Println("  config      Validate, inspect and edit config.json")
+	fmt.Println("  outbox      Inspect and resend undelivered messages")
 	fmt.Println("  migrate     Migrate from OpenClaw to PicoClaw")
 	fmt.Println("  skills      Manage skills (install, list, remove)")
 	fmt.Println("  version     Show version information")
@@ -19,6 +19,7 @@ type ContextBuilder struct {
 	skillsLoader *skills.SkillsLoader
 	memory       *MemoryStore
 	tools        *tools.ToolRegistry // Direct reference to tool registry
+	nowFunc      func() time.Time    // for testing
 }
 
 func getGlobalConfigDir() string {
@@ -40,6 +41,7 @@ func NewContextBuilder(workspace string) *ContextBuilder {
 		workspace:    workspace,
 		skillsLoader: skills.NewSkillsLoader(workspace, globalSkillsDir, builtinSkillsDir),
 		memory:       NewMemoryStore(workspace),
+		nowFunc:      time.Now,
 	}
 }
 
@@ -48,8 +50,14 @@ func (cb *ContextBuilder) SetToolsRegistry(registry *tools.ToolRegistry) {
 	cb.tools = registry
 }
 
+// withTools returns a copy of the builder that describes registry instead.
+func (cb *ContextBuilder) withTools(registry *tools.ToolRegistry) *ContextBuilder {
+	clone := *cb
+	clone.tools = registry
+	return &clone
+}
+
 func (cb *ContextBuilder) getIdentity() string {
-	now := time.Now().Format("2006-01-02 15:04 (Monday)")
 	workspacePath, _ := filepath.Abs(filepath.Join(cb.workspace))
 	runtime := fmt.Sprintf("%s %s, Go %s", runtime.GOOS, runtime.GOARCH, runtime.Version())
 
@@ -60,9 +68,6 @@ func (cb *ContextBuilder) getIdentity() string {
 
 You are picoclaw, a helpful AI assistant.
 
-## Current Time
-%s
-
 ## Runtime
 %s
 
@@ -81,7 +86,7 @@ Your workspace is at: %s
 2. **Be helpful and accurate** - When using tools, briefly explain what you're doing.
 
 3. **Memory** - When interacting with me if something seems memorable, update %s/memory/MEMORY.md`,
-		now, runtime, workspacePath, workspacePath, workspacePath, workspacePath, toolsSection, workspacePath)
+		runtime, workspacePath, workspacePath, workspacePath, workspacePath, toolsSection, workspacePath)
 }
 
 func (cb *ContextBuilder) buildToolsSection() string {
@@ -170,11 +175,6 @@ func (cb *ContextBuilder) BuildMessages(
 
 	systemPrompt := cb.BuildSystemPrompt()
 
-	// Add Current Session info if provided
-	if channel != "" && chatID != "" {
-		systemPrompt += fmt.Sprintf("\n\n## Current Session\nChannel: %s\nChat ID: %s", channel, chatID)
-	}
-
 	// Log system prompt summary for debugging (debug mode only)
 	logger.DebugCF("agent", "System prompt built",
 		map[string]any{
@@ -197,12 +197,25 @@ func (cb *ContextBuilder) BuildMessages(
 		systemPrompt += "\n\n## Summary of Previous Conversation\n\n" + summary
 	}
 
+	// The time and session go in later system messages, so the first one
+	// stays the same from turn to turn and providers can cache it. The time
+	// is volatile: the response cache leaves it out of its key.
 	history = sanitizeHistoryForProvider(history)
 
-	messages = append(messages, providers.Message{
-		Role:    "system",
-		Content: systemPrompt,
-	})
+	messages = append(messages,
+		providers.Message{Role: "system", Content: systemPrompt},
+		providers.Message{
+			Role:     "system",
+			Content:  "## Current Time\n" + cb.nowFunc().Format("2006-01-02 15:04 (Monday)"),
+			Volatile: true,
+		},
+	)
+	if channel != "" && chatID != "" {
+		messages = append(messages, providers.Message{
+			Role:    "system",
+			Content: fmt.Sprintf("## Current Session\nChannel: %s\nChat ID: %s", channel, chatID),
+		})
+	}
 
 	messages = append(messages, history...)
 
@@ -210,6 +223,7 @@ func (cb *ContextBuilder) BuildMessages(
 		messages = append(messages, providers.Message{
 			Role:    "user",
 			Content: currentMessage,
+			Media:   media,
 		})
 	}
 
@@ -307,6 +321,11 @@ func (cb *ContextBuilder) loadSkills() string {
 	return "# Skill Definitions\n\n" + content
 }
 
+// ListSkills returns all skills visible to this context builder.
+func (cb *ContextBuilder) ListSkills() []skills.SkillInfo {
+	return cb.skillsLoader.ListSkills()
+}
+
 // GetSkillsInfo returns information about loaded skills.
 func (cb *ContextBuilder) GetSkillsInfo() map[string]any {
 	allSkills := cb.skillsLoader.ListSkills()
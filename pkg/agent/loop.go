@@ -8,8 +8,11 @@ package agent
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"path/filepath"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -31,36 +34,52 @@ import (
 
 type AgentLoop struct {
 	bus            *bus.MessageBus
-	cfg            *config.Config
+	cfg            atomic.Pointer[config.Config] // swapped by ReloadConfig while turns run
 	registry       *AgentRegistry
 	state          *state.Manager
 	running        atomic.Bool
 	summarizing    sync.Map
 	fallback       *providers.FallbackChain
 	channelManager *channels.Manager
+	usage          *UsageTracker
+	subagents      *tools.SubagentManager
+	extraTools     []tools.Tool // tools added via RegisterTool, kept across reloads
+	reloadMu       sync.Mutex
 }
 
 // processOptions configures how a message is processed
 type processOptions struct {
-	SessionKey      string // Session identifier for history/context
-	Channel         string // Target channel for tool execution
-	ChatID          string // Target chat ID for tool execution
-	UserMessage     string // User message content (may include prefix)
-	DefaultResponse string // Response when LLM returns empty
-	EnableSummary   bool   // Whether to trigger summarization
-	SendResponse    bool   // Whether to send response via bus
-	NoHistory       bool   // If true, don't load session history (for heartbeat)
+	SessionKey      string   // Session identifier for history/context
+	Channel         string   // Target channel for tool execution
+	ChatID          string   // Target chat ID for tool execution
+	UserMessage     string   // User message content (may include prefix)
+	Media           []string // Attachment paths sent with the user message
+	DefaultResponse string   // Response when LLM returns empty
+	EnableSummary   bool     // Whether to trigger summarization
+	SendResponse    bool     // Whether to send response via bus
+	NoHistory       bool     // If true, don't load session history (for heartbeat)
+	ShowReasoning   bool     // Whether this turn is user-facing, so show_reasoning applies
+	// ResponseFormat, if set, requires the final answer to be JSON matching
+	// its schema.
+	ResponseFormat *providers.ResponseFormat
 }
 
 func NewAgentLoop(cfg *config.Config, msgBus *bus.MessageBus, provider providers.LLMProvider) *AgentLoop {
 	registry := NewAgentRegistry(cfg, provider)
 
+	// One subagent manager for all agents, so spawned tasks can be listed and
+	// cancelled from anywhere and survive config reloads.
+	subagents := tools.NewSubagentManager(provider, cfg.Agents.Defaults.Model, cfg.WorkspacePath(), msgBus)
+	subagents.SetSessionKeyFunc(routing.BuildSubagentSessionKey)
+
 	// Register shared tools to all agents
-	registerSharedTools(cfg, msgBus, registry, provider)
+	registerSharedTools(cfg, msgBus, registry, subagents)
 
 	// Set up shared fallback chain
 	cooldown := providers.NewCooldownTracker()
 	fallbackChain := providers.NewFallbackChain(cooldown)
+	fallbackChain.SetHealthTracker(providers.NewHealthTracker(0))
+	fallbackChain.SetStrategy(fallbackStrategy(cfg))
 
 	// Create state manager using default agent's workspace for channel recording
 	defaultAgent := registry.GetDefaultAgent()
@@ -69,14 +88,26 @@ func NewAgentLoop(cfg *config.Config, msgBus *bus.MessageBus, provider providers
 		stateManager = state.NewManager(defaultAgent.Workspace)
 	}
 
-	return &AgentLoop{
+	al := &AgentLoop{
 		bus:         msgBus,
-		cfg:         cfg,
 		registry:    registry,
 		state:       stateManager,
 		summarizing: sync.Map{},
 		fallback:    fallbackChain,
+		usage:       NewUsageTracker(),
+		subagents:   subagents,
 	}
+	al.cfg.Store(cfg)
+	subagents.SetRunner(al.runSubagent)
+	return al
+}
+
+// PersistSubagentTasks saves the subagent task registry in the workspace
+// and loads the tasks left by the previous run, so they outlive a restart.
+// Only the long-running gateway should call it: the registry belongs to
+// one process at a time.
+func (al *AgentLoop) PersistSubagentTasks() error {
+	return al.subagents.SetStorePath(filepath.Join(al.cfg.Load().WorkspacePath(), "subagents", "tasks.json"))
 }
 
 // registerSharedTools registers tools that are shared across all agents (web, message, spawn).
@@ -84,8 +115,10 @@ func registerSharedTools(
 	cfg *config.Config,
 	msgBus *bus.MessageBus,
 	registry *AgentRegistry,
-	provider providers.LLMProvider,
+	subagents *tools.SubagentManager,
 ) {
+	subagents.SetMaxDepth(cfg.Agents.Defaults.MaxSubagentDepth)
+
 	for _, agentID := range registry.ListAgentIDs() {
 		agent, ok := registry.GetAgent(agentID)
 		if !ok {
@@ -121,6 +154,11 @@ func registerSharedTools(
 			})
 			return nil
 		})
+		messageTool.SetRichSendCallback(func(msg bus.OutboundMessage) error {
+			msgBus.PublishOutbound(msg)
+			return nil
+		})
+		messageTool.SetWorkspace(agent.Workspace, cfg.Agents.Defaults.RestrictToWorkspace)
 		agent.Tools.Register(messageTool)
 
 		// Skill discovery and installation tools
@@ -136,23 +174,61 @@ func registerSharedTools(
 		agent.Tools.Register(tools.NewInstallSkillTool(registryMgr, agent.Workspace))
 
 		// Spawn tool with allowlist checker
-		subagentManager := tools.NewSubagentManager(provider, agent.Model, agent.Workspace, msgBus)
-		subagentManager.SetLLMOptions(agent.MaxTokens, agent.Temperature)
-		spawnTool := tools.NewSpawnTool(subagentManager)
+		spawnTool := tools.NewSpawnTool(subagents)
 		currentAgentID := agentID
+		spawnTool.SetAgentID(currentAgentID)
 		spawnTool.SetAllowlistChecker(func(targetAgentID string) bool {
 			return registry.CanSpawnSubagent(currentAgentID, targetAgentID)
 		})
 		agent.Tools.Register(spawnTool)
+		agent.Tools.Register(tools.NewSubagentListTool(subagents, currentAgentID))
+		agent.Tools.Register(tools.NewSubagentStatusTool(subagents, currentAgentID))
+		agent.Tools.Register(tools.NewSubagentCancelTool(subagents, currentAgentID))
 
 		// Update context builder with the complete tools registry
 		agent.ContextBuilder.SetToolsRegistry(agent.Tools)
 	}
 }
 
+// handleInterruptedSubagents deals with background tasks cut off by the
+// last shutdown. With resume_subagents they are started again from the
+// beginning; otherwise they are marked failed and reported to the chats
+// that spawned them, as rerunning could repeat their side effects.
+func (al *AgentLoop) handleInterruptedSubagents() {
+	if al.cfg.Load().Agents.Defaults.ResumeSubagents {
+		if n := al.subagents.ResumeInterrupted(nil); n > 0 {
+			logger.InfoCF("agent", "Resumed interrupted subagent tasks", map[string]any{"count": n})
+		}
+		return
+	}
+
+	for _, task := range al.subagents.AbandonInterrupted() {
+		logger.WarnCF("agent", "Subagent task interrupted by restart, not resumed",
+			map[string]any{"task_id": task.ID, "label": task.Label})
+		if task.OriginChannel == "" || task.OriginChatID == "" {
+			continue
+		}
+		name := task.ID
+		if task.Label != "" {
+			name = fmt.Sprintf("%s (%s)", task.ID, task.Label)
+		}
+		al.bus.PublishOutbound(bus.OutboundMessage{
+			Channel: task.OriginChannel,
+			ChatID:  task.OriginChatID,
+			Content: fmt.Sprintf("Background task %s was interrupted by a restart and was not resumed.", name),
+		})
+	}
+}
+
 func (al *AgentLoop) Run(ctx context.Context) error {
 	al.running.Store(true)
 
+	al.handleInterruptedSubagents()
+
+	if secs := al.cfg.Load().Agents.Defaults.HealthProbeInterval; secs > 0 {
+		al.fallback.StartProbes(ctx, time.Duration(secs)*time.Second, al.probeTargets)
+	}
+
 	for al.running.Load() {
 		select {
 		case <-ctx.Done():
@@ -201,6 +277,10 @@ func (al *AgentLoop) Stop() {
 }
 
 func (al *AgentLoop) RegisterTool(tool tools.Tool) {
+	al.reloadMu.Lock()
+	al.extraTools = append(al.extraTools, tool)
+	al.reloadMu.Unlock()
+
 	for _, agentID := range al.registry.ListAgentIDs() {
 		if agent, ok := al.registry.GetAgent(agentID); ok {
 			agent.Tools.Register(tool)
@@ -212,6 +292,89 @@ func (al *AgentLoop) SetChannelManager(cm *channels.Manager) {
 	al.channelManager = cm
 }
 
+// ReloadConfig rebuilds all agents, their tools and routing bindings from cfg
+// using provider, then swaps them in. In-flight messages finish on the agents
+// they started with; agents whose workspace is unchanged keep their sessions.
+func (al *AgentLoop) ReloadConfig(cfg *config.Config, provider providers.LLMProvider) {
+	al.reloadMu.Lock()
+	defer al.reloadMu.Unlock()
+
+	next := NewAgentRegistry(cfg, provider)
+	registerSharedTools(cfg, al.bus, next, al.subagents)
+	for _, agentID := range next.ListAgentIDs() {
+		agent, ok := next.GetAgent(agentID)
+		if !ok {
+			continue
+		}
+		for _, tool := range al.extraTools {
+			agent.Tools.Register(tool)
+		}
+		agent.ContextBuilder.SetToolsRegistry(agent.Tools)
+
+		// Share the live session manager so turns still in flight on the old
+		// instance aren't lost when the new one saves.
+		if current, ok := al.registry.GetAgent(agentID); ok && current.Workspace == agent.Workspace {
+			agent.Sessions = current.Sessions
+		}
+	}
+
+	al.registry.replaceWith(next)
+	al.cfg.Store(cfg)
+	al.fallback.SetStrategy(fallbackStrategy(cfg))
+
+	logger.InfoCF("agent", "Agents reloaded from config", map[string]any{
+		"agents": al.registry.ListAgentIDs(),
+	})
+}
+
+// GetRegistry returns the agent registry.
+func (al *AgentLoop) GetRegistry() *AgentRegistry {
+	return al.registry
+}
+
+// GetUsage returns token usage recorded since the loop was created.
+func (al *AgentLoop) GetUsage() []UsageStats {
+	return al.usage.Snapshot()
+}
+
+// GetProviderHealth returns recent latency and error statistics of the
+// fallback candidates.
+func (al *AgentLoop) GetProviderHealth() []providers.ProviderHealth {
+	return al.fallback.HealthTracker().Snapshot()
+}
+
+// probeTargets lists the fallback candidates of all agents, once each, with
+// the provider that serves them. Agents without fallbacks are not probed.
+func (al *AgentLoop) probeTargets() []providers.ProbeTarget {
+	seen := make(map[string]bool)
+	var targets []providers.ProbeTarget
+	for _, agentID := range al.registry.ListAgentIDs() {
+		agent, ok := al.registry.GetAgent(agentID)
+		if !ok || len(agent.Candidates) < 2 {
+			continue
+		}
+		for _, c := range agent.Candidates {
+			key := providers.ModelKey(c.Provider, c.Model)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			targets = append(targets, providers.ProbeTarget{Candidate: c, Provider: agent.Provider})
+		}
+	}
+	return targets
+}
+
+// fallbackStrategy reads the candidate selection strategy from cfg, falling
+// back to configured order if it is unknown.
+func fallbackStrategy(cfg *config.Config) providers.SelectionStrategy {
+	strategy, err := providers.ParseSelectionStrategy(cfg.Agents.Defaults.FallbackStrategy)
+	if err != nil {
+		logger.WarnCF("agent", "Using ordered fallback", map[string]any{"error": err.Error()})
+	}
+	return strategy
+}
+
 // RecordLastChannel records the last active channel for this workspace.
 // This uses the atomic state save mechanism to prevent data loss on crash.
 func (al *AgentLoop) RecordLastChannel(channel string) error {
@@ -231,16 +394,19 @@ func (al *AgentLoop) RecordLastChatID(chatID string) error {
 }
 
 func (al *AgentLoop) ProcessDirect(ctx context.Context, content, sessionKey string) (string, error) {
-	return al.ProcessDirectWithChannel(ctx, content, sessionKey, "cli", "direct")
+	return al.ProcessDirectWithChannel(ctx, content, sessionKey, "cli", "direct", "cli")
 }
 
+// ProcessDirectWithChannel processes content as a message from senderID on
+// channel and chatID, such as a cron job ("cron") or a dashboard request,
+// and returns the agent's answer.
 func (al *AgentLoop) ProcessDirectWithChannel(
 	ctx context.Context,
-	content, sessionKey, channel, chatID string,
+	content, sessionKey, channel, chatID, senderID string,
 ) (string, error) {
 	msg := bus.InboundMessage{
 		Channel:    channel,
-		SenderID:   "cron",
+		SenderID:   senderID,
 		ChatID:     chatID,
 		Content:    content,
 		SessionKey: sessionKey,
@@ -249,6 +415,25 @@ func (al *AgentLoop) ProcessDirectWithChannel(
 	return al.processMessage(ctx, msg)
 }
 
+// ProcessDirectStructured is ProcessDirectWithChannel with the final answer
+// constrained to JSON matching format. The returned string is that JSON; an
+// error is returned if the model fails to produce it after repair attempts.
+func (al *AgentLoop) ProcessDirectStructured(
+	ctx context.Context,
+	content, sessionKey, channel, chatID, senderID string,
+	format *providers.ResponseFormat,
+) (string, error) {
+	msg := bus.InboundMessage{
+		Channel:    channel,
+		SenderID:   senderID,
+		ChatID:     chatID,
+		Content:    content,
+		SessionKey: sessionKey,
+	}
+
+	return al.processMessageWithFormat(ctx, msg, format)
+}
+
 // ProcessHeartbeat processes a heartbeat request without session history.
 // Each heartbeat is independent and doesn't accumulate context.
 func (al *AgentLoop) ProcessHeartbeat(ctx context.Context, content, channel, chatID string) (string, error) {
@@ -266,6 +451,14 @@ func (al *AgentLoop) ProcessHeartbeat(ctx context.Context, content, channel, cha
 }
 
 func (al *AgentLoop) processMessage(ctx context.Context, msg bus.InboundMessage) (string, error) {
+	return al.processMessageWithFormat(ctx, msg, nil)
+}
+
+func (al *AgentLoop) processMessageWithFormat(
+	ctx context.Context,
+	msg bus.InboundMessage,
+	format *providers.ResponseFormat,
+) (string, error) {
 	// Add message preview to log (show full content for error messages)
 	var logContent string
 	if strings.Contains(msg.Content, "Error:") || strings.Contains(msg.Content, "error") {
@@ -286,12 +479,42 @@ func (al *AgentLoop) processMessage(ctx context.Context, msg bus.InboundMessage)
 		return al.processSystemMessage(ctx, msg)
 	}
 
+	// Passive group messages are context for later replies, not requests
+	if msg.Metadata["passive"] == "true" {
+		al.recordPassiveMessage(msg)
+		return "", nil
+	}
+
 	// Check for commands
 	if response, handled := al.handleCommand(ctx, msg); handled {
 		return response, nil
 	}
 
-	// Route to determine agent and session key
+	agent, sessionKey, route := al.routeMessage(msg)
+
+	logger.InfoCF("agent", "Routed message",
+		map[string]any{
+			"agent_id":    agent.ID,
+			"session_key": sessionKey,
+			"matched_by":  route.MatchedBy,
+		})
+
+	return al.runAgentLoop(ctx, agent, processOptions{
+		SessionKey:      sessionKey,
+		Channel:         msg.Channel,
+		ChatID:          msg.ChatID,
+		UserMessage:     msg.Content,
+		Media:           msg.Media,
+		DefaultResponse: "I've completed processing but have no response to give.",
+		EnableSummary:   true,
+		SendResponse:    false,
+		ShowReasoning:   format == nil, // structured answers are read by programs
+		ResponseFormat:  format,
+	})
+}
+
+// routeMessage picks the agent and session key for an inbound message.
+func (al *AgentLoop) routeMessage(msg bus.InboundMessage) (*AgentInstance, string, routing.ResolvedRoute) {
 	route := al.registry.ResolveRoute(routing.RouteInput{
 		Channel:    msg.Channel,
 		AccountID:  msg.Metadata["account_id"],
@@ -310,23 +533,38 @@ func (al *AgentLoop) processMessage(ctx context.Context, msg bus.InboundMessage)
 	sessionKey := route.SessionKey
 	if msg.SessionKey != "" && strings.HasPrefix(msg.SessionKey, "agent:") {
 		sessionKey = msg.SessionKey
+		// An agent-scoped key also selects its agent.
+		if parsed := routing.ParseAgentSessionKey(sessionKey); parsed != nil {
+			if scoped, ok := al.registry.GetAgent(parsed.AgentID); ok {
+				agent = scoped
+			}
+		}
 	}
+	return agent, sessionKey, route
+}
 
-	logger.InfoCF("agent", "Routed message",
-		map[string]any{
-			"agent_id":    agent.ID,
-			"session_key": sessionKey,
-			"matched_by":  route.MatchedBy,
-		})
+// recordPassiveMessage adds a group message the channel did not answer to
+// the session history, labelled with its sender, so later replies in the
+// group can refer to it.
+func (al *AgentLoop) recordPassiveMessage(msg bus.InboundMessage) {
+	if strings.TrimSpace(msg.Content) == "" {
+		return
+	}
+	agent, sessionKey, _ := al.routeMessage(msg)
 
-	return al.runAgentLoop(ctx, agent, processOptions{
-		SessionKey:      sessionKey,
-		Channel:         msg.Channel,
-		ChatID:          msg.ChatID,
-		UserMessage:     msg.Content,
-		DefaultResponse: "I've completed processing but have no response to give.",
-		EnableSummary:   true,
-		SendResponse:    false,
+	sender := msg.SenderID
+	for _, key := range []string{"user_name", "sender_name", "username"} {
+		if name := msg.Metadata[key]; name != "" {
+			sender = name
+			break
+		}
+	}
+	agent.Sessions.AddMessage(sessionKey, "user", fmt.Sprintf("[%s]: %s", sender, msg.Content))
+	agent.Sessions.Save(sessionKey)
+
+	logger.DebugCF("agent", "Recorded passive group message", map[string]any{
+		"agent_id":    agent.ID,
+		"session_key": sessionKey,
 	})
 }
 
@@ -352,7 +590,7 @@ func (al *AgentLoop) processSystemMessage(ctx context.Context, msg bus.InboundMe
 	}
 
 	// Extract subagent result from message content
-	// Format: "Task 'label' completed.\n\nResult:\n<actual content>"
+	// Format: "Task 'label' <status>.\n\nResult:\n<actual content>"
 	content := msg.Content
 	if idx := strings.Index(content, "Result:\n"); idx >= 0 {
 		content = content[idx+8:] // Extract just the result part
@@ -369,8 +607,16 @@ func (al *AgentLoop) processSystemMessage(ctx context.Context, msg bus.InboundMe
 		return "", nil
 	}
 
-	// Use default agent for system messages
+	// Subagent results go back to the agent that spawned the task; other
+	// system messages use the default agent.
 	agent := al.registry.GetDefaultAgent()
+	if taskID, ok := strings.CutPrefix(msg.SenderID, "subagent:"); ok {
+		if task, ok := al.subagents.GetTask(taskID); ok && task.ParentAgentID != "" {
+			if parent, ok := al.registry.GetAgent(task.ParentAgentID); ok {
+				agent = parent
+			}
+		}
+	}
 
 	// Use the origin session for context
 	sessionKey := routing.BuildAgentMainSessionKey(agent.ID)
@@ -383,6 +629,7 @@ func (al *AgentLoop) processSystemMessage(ctx context.Context, msg bus.InboundMe
 		DefaultResponse: "Background task completed.",
 		EnableSummary:   false,
 		SendResponse:    true,
+		ShowReasoning:   true,
 	})
 }
 
@@ -413,11 +660,16 @@ func (al *AgentLoop) runAgentLoop(ctx context.Context, agent *AgentInstance, opt
 		history,
 		summary,
 		opts.UserMessage,
-		nil,
+		opts.Media,
 		opts.Channel,
 		opts.ChatID,
 	)
 
+	// The format instruction goes to the model but not into the session.
+	if opts.ResponseFormat != nil && len(messages) > 0 && messages[len(messages)-1].Role == "user" {
+		messages[len(messages)-1].Content += "\n\n" + providers.StructuredOutputInstruction(opts.ResponseFormat)
+	}
+
 	// 3. Save user message to session
 	agent.Sessions.AddMessage(opts.SessionKey, "user", opts.UserMessage)
 
@@ -431,6 +683,9 @@ func (al *AgentLoop) runAgentLoop(ctx context.Context, agent *AgentInstance, opt
 	// This is controlled by the tool's Silent flag and ForUser content
 
 	// 5. Handle empty response
+	if finalContent == "" && opts.ResponseFormat != nil {
+		return "", fmt.Errorf("no structured answer after %d iterations", iteration)
+	}
 	if finalContent == "" {
 		finalContent = opts.DefaultResponse
 	}
@@ -475,6 +730,15 @@ func (al *AgentLoop) runLLMIteration(
 ) (string, int, error) {
 	iteration := 0
 	var finalContent string
+	repairs := 0
+	chatOptions := map[string]any{
+		"max_tokens":       agent.MaxTokens,
+		"temperature":      agent.Temperature,
+		"prompt_cache_key": promptCacheKey(agent.ID, opts.SessionKey),
+	}
+	if opts.ResponseFormat != nil {
+		chatOptions["response_format"] = opts.ResponseFormat
+	}
 
 	for iteration < agent.MaxIterations {
 		iteration++
@@ -518,10 +782,7 @@ func (al *AgentLoop) runLLMIteration(
 			if len(agent.Candidates) > 1 && al.fallback != nil {
 				fbResult, fbErr := al.fallback.Execute(ctx, agent.Candidates,
 					func(ctx context.Context, provider, model string) (*providers.LLMResponse, error) {
-						return agent.Provider.Chat(ctx, messages, providerToolDefs, model, map[string]any{
-							"max_tokens":  agent.MaxTokens,
-							"temperature": agent.Temperature,
-						})
+						return agent.Provider.Chat(ctx, messages, providerToolDefs, model, chatOptions)
 					},
 				)
 				if fbErr != nil {
@@ -534,10 +795,7 @@ func (al *AgentLoop) runLLMIteration(
 				}
 				return fbResult.Response, nil
 			}
-			return agent.Provider.Chat(ctx, messages, providerToolDefs, agent.Model, map[string]any{
-				"max_tokens":  agent.MaxTokens,
-				"temperature": agent.Temperature,
-			})
+			return agent.Provider.Chat(ctx, messages, providerToolDefs, agent.Model, chatOptions)
 		}
 
 		// Retry loop for context/token errors
@@ -590,9 +848,51 @@ func (al *AgentLoop) runLLMIteration(
 			return "", iteration, fmt.Errorf("LLM call failed after retries: %w", err)
 		}
 
+		al.usage.Record(agent.ID, agent.Model, response.Usage)
+
+		if response.Reasoning != "" {
+			logger.DebugCF("agent", "LLM reasoning",
+				map[string]any{
+					"agent_id":        agent.ID,
+					"iteration":       iteration,
+					"reasoning_chars": len(response.Reasoning),
+				})
+			if al.cfg.Load().Agents.Defaults.ShowReasoning && opts.ShowReasoning &&
+				!constants.IsInternalChannel(opts.Channel) {
+				al.bus.PublishOutbound(bus.OutboundMessage{
+					Channel: opts.Channel,
+					ChatID:  opts.ChatID,
+					Content: "💭 " + utils.Truncate(response.Reasoning, maxReasoningPreview),
+				})
+			}
+		}
+
 		// Check if no tool calls - we're done
 		if len(response.ToolCalls) == 0 {
 			finalContent = response.Content
+			if opts.ResponseFormat != nil {
+				structured, parseErr := providers.ParseStructuredOutput(response.Content, opts.ResponseFormat)
+				if parseErr != nil {
+					if repairs >= maxStructuredRepairs || iteration >= agent.MaxIterations {
+						return "", iteration, fmt.Errorf("structured output invalid: %w", parseErr)
+					}
+					repairs++
+					logger.WarnCF("agent", "Structured output invalid, asking for a repair",
+						map[string]any{
+							"agent_id": agent.ID,
+							"repair":   repairs,
+							"error":    parseErr.Error(),
+						})
+					messages = append(messages,
+						providers.Message{Role: "assistant", Content: response.Content},
+						providers.Message{Role: "user", Content: fmt.Sprintf(
+							"Your answer is not valid: %v. %s", parseErr,
+							providers.StructuredOutputInstruction(opts.ResponseFormat))},
+					)
+					continue
+				}
+				finalContent = structured
+			}
 			logger.InfoCF("agent", "LLM response without tool calls (direct answer)",
 				map[string]any{
 					"agent_id":      agent.ID,
@@ -621,9 +921,13 @@ func (al *AgentLoop) runLLMIteration(
 			})
 
 		// Build assistant message with tool calls
+		// Reasoning goes back with the tool calls: thinking models need it,
+		// and signed thinking blocks must be returned unchanged.
 		assistantMsg := providers.Message{
-			Role:    "assistant",
-			Content: response.Content,
+			Role:             "assistant",
+			Content:          response.Content,
+			ReasoningContent: response.Reasoning,
+			ThinkingBlocks:   response.ThinkingBlocks,
 		}
 		for _, tc := range normalizedToolCalls {
 			argumentsJSON, _ := json.Marshal(tc.Arguments)
@@ -723,6 +1027,21 @@ func (al *AgentLoop) runLLMIteration(
 	return finalContent, iteration, nil
 }
 
+// maxStructuredRepairs is how many times an answer that does not match the
+// requested response format is sent back to the model for correction.
+const maxStructuredRepairs = 2
+
+// maxReasoningPreview caps reasoning shown to users with show_reasoning.
+const maxReasoningPreview = 3000
+
+// promptCacheKey derives a stable, opaque prompt cache key for a session so
+// providers can route its requests to the same cache without seeing the
+// session key itself.
+func promptCacheKey(agentID, sessionKey string) string {
+	sum := sha256.Sum256([]byte(agentID + "\x00" + sessionKey))
+	return "picoclaw-" + hex.EncodeToString(sum[:12])
+}
+
 // updateToolContexts updates the context for tools that need channel/chatID info.
 func (al *AgentLoop) updateToolContexts(agent *AgentInstance, channel, chatID string) {
 	// Use ContextualTool interface instead of type assertions
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -606,6 +607,7 @@ func TestAgentLoop_ContextExhaustionRetry(t *testing.T) {
 		sessionKey,
 		"test",
 		"test-chat",
+		"cron",
 	)
 	if err != nil {
 		t.Fatalf("Expected success after retry, got error: %v", err)
@@ -631,3 +633,365 @@ func TestAgentLoop_ContextExhaustionRetry(t *testing.T) {
 		t.Errorf("Expected history to be compressed (len < 8), got %d", len(finalHistory))
 	}
 }
+
+type scriptedProvider struct {
+	replies  []string
+	calls    int
+	options  []map[string]any
+	messages [][]providers.Message
+}
+
+func (m *scriptedProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	reply := m.replies[min(m.calls, len(m.replies)-1)]
+	m.calls++
+	m.options = append(m.options, opts)
+	m.messages = append(m.messages, append([]providers.Message(nil), messages...))
+	return &providers.LLMResponse{Content: reply}, nil
+}
+
+func (m *scriptedProvider) GetDefaultModel() string {
+	return "mock-model"
+}
+
+func TestAgentLoop_ProcessDirectStructuredRepairs(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+	}
+	provider := &scriptedProvider{replies: []string{"The disk is fine.", "```json\n{\"ok\": true}\n```"}}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+
+	format := &providers.ResponseFormat{Schema: map[string]any{
+		"type":       "object",
+		"properties": map[string]any{"ok": map[string]any{"type": "boolean"}},
+		"required":   []any{"ok"},
+	}}
+	got, err := al.ProcessDirectStructured(context.Background(), "Check the disk", "agent:main:cron-1", "cli", "direct", "cron",
+		format)
+	if err != nil {
+		t.Fatalf("ProcessDirectStructured() error = %v", err)
+	}
+	if got != `{"ok": true}` {
+		t.Errorf("got %q, want the JSON without fences", got)
+	}
+	if provider.calls != 2 {
+		t.Fatalf("calls = %d, want 2 (answer + repair)", provider.calls)
+	}
+	if provider.options[0]["response_format"] != format {
+		t.Error("response_format not passed to the provider")
+	}
+	first := provider.messages[0]
+	if !strings.Contains(first[len(first)-1].Content, "JSON Schema") {
+		t.Error("format instruction missing from the user message")
+	}
+	repair := provider.messages[1]
+	if !strings.Contains(repair[len(repair)-1].Content, "not valid JSON") {
+		t.Errorf("repair prompt = %q", repair[len(repair)-1].Content)
+	}
+
+	// The instruction is not kept in the session.
+	history := al.registry.GetDefaultAgent().Sessions.GetHistory("agent:main:cron-1")
+	if len(history) == 0 || history[0].Content != "Check the disk" {
+		t.Errorf("history = %+v", history)
+	}
+}
+
+func TestAgentLoop_ProcessDirectStructuredGivesUp(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+	}
+	provider := &scriptedProvider{replies: []string{"no json here"}}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+
+	_, err := al.ProcessDirectStructured(context.Background(), "Check", "cron-2", "cli", "direct", "cron",
+		&providers.ResponseFormat{})
+	if err == nil || !strings.Contains(err.Error(), "structured output invalid") {
+		t.Fatalf("err = %v, want structured output error", err)
+	}
+	if provider.calls != 1+maxStructuredRepairs {
+		t.Errorf("calls = %d, want %d", provider.calls, 1+maxStructuredRepairs)
+	}
+}
+
+// TestAgentLoop_PassiveGroupMessage checks that passive messages only add
+// context to the group's session.
+func TestAgentLoop_PassiveGroupMessage(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+	}
+	provider := &scriptedProvider{replies: []string{"Friday"}}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+	helper := testHelper{al: al}
+	ctx := context.Background()
+
+	metadata := map[string]string{"peer_kind": "group", "peer_id": "g1", "user_name": "alice", "passive": "true"}
+	passive := bus.InboundMessage{
+		Channel:  "test",
+		SenderID: "u1",
+		ChatID:   "g1",
+		Content:  "the release is on Friday",
+		Metadata: metadata,
+	}
+	if got := helper.executeAndGetResponse(t, ctx, passive); got != "" {
+		t.Errorf("passive response = %q, want none", got)
+	}
+	if provider.calls != 0 {
+		t.Fatalf("provider called %d times for a passive message", provider.calls)
+	}
+
+	question := passive
+	question.Content = "when is the release?"
+	question.Metadata = map[string]string{"peer_kind": "group", "peer_id": "g1", "user_name": "bob"}
+	if got := helper.executeAndGetResponse(t, ctx, question); got != "Friday" {
+		t.Errorf("response = %q", got)
+	}
+	found := false
+	for _, m := range provider.messages[0] {
+		found = found || m.Content == "[alice]: the release is on Friday"
+	}
+	if !found {
+		t.Error("passive message missing from the prompt")
+	}
+}
+
+// TestAgentLoop_InterruptedSubagentsReported checks that tasks cut off by a
+// restart are reported rather than run again, unless resuming is enabled.
+func TestAgentLoop_InterruptedSubagentsReported(t *testing.T) {
+	workspace := t.TempDir()
+	storePath := filepath.Join(workspace, "subagents", "tasks.json")
+	if err := os.MkdirAll(filepath.Dir(storePath), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	store := `{"version":1,"tasks":[{"id":"subagent-1","task":"send the report","status":"running",` +
+		`"origin_channel":"telegram","origin_chat_id":"42","created":1}]}`
+	if err := os.WriteFile(storePath, []byte(store), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         workspace,
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+	}
+	msgBus := bus.NewMessageBus()
+	provider := &scriptedProvider{replies: []string{"done"}}
+
+	// A loop that does not persist tasks, like the CLI's, leaves the store alone.
+	NewAgentLoop(cfg, msgBus, provider)
+	if data, _ := os.ReadFile(storePath); string(data) != store {
+		t.Fatalf("store rewritten by a non-persisting loop: %s", data)
+	}
+
+	al := NewAgentLoop(cfg, msgBus, provider)
+	if err := al.PersistSubagentTasks(); err != nil {
+		t.Fatalf("PersistSubagentTasks: %v", err)
+	}
+	al.handleInterruptedSubagents()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	out, ok := msgBus.SubscribeOutbound(ctx)
+	if !ok || out.Channel != "telegram" || out.ChatID != "42" || !strings.Contains(out.Content, "subagent-1") {
+		t.Fatalf("report = %+v, %v", out, ok)
+	}
+	if task, _ := al.subagents.GetTask("subagent-1"); task.Status != tools.SubagentFailed {
+		t.Errorf("Status = %s, want failed", task.Status)
+	}
+	if provider.calls != 0 {
+		t.Errorf("interrupted task was run again")
+	}
+}
+
+type reasoningProvider struct{}
+
+func (m *reasoningProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	return &providers.LLMResponse{Content: "42", Reasoning: "six times seven"}, nil
+}
+
+func (m *reasoningProvider) GetDefaultModel() string {
+	return "mock-model"
+}
+
+// TestAgentLoop_ShowReasoningForChannelMessage checks that show_reasoning
+// sends the reasoning to the chat of an inbound channel message.
+func TestAgentLoop_ShowReasoningForChannelMessage(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+				ShowReasoning:     true,
+			},
+		},
+	}
+	msgBus := bus.NewMessageBus()
+	al := NewAgentLoop(cfg, msgBus, &reasoningProvider{})
+	helper := testHelper{al: al}
+
+	msg := bus.InboundMessage{Channel: "telegram", SenderID: "u1", ChatID: "42", Content: "what is 6*7?"}
+	if got := helper.executeAndGetResponse(t, context.Background(), msg); got != "42" {
+		t.Errorf("response = %q", got)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	out, ok := msgBus.SubscribeOutbound(ctx)
+	if !ok || out.Channel != "telegram" || out.ChatID != "42" || out.Content != "💭 six times seven" {
+		t.Fatalf("reasoning message = %+v, %v", out, ok)
+	}
+}
+
+type noteTool struct {
+	notes []string
+}
+
+func (t *noteTool) Name() string        { return "note" }
+func (t *noteTool) Description() string { return "Save a note" }
+func (t *noteTool) Parameters() map[string]any {
+	return map[string]any{
+		"type":       "object",
+		"properties": map[string]any{"text": map[string]any{"type": "string"}},
+	}
+}
+
+func (t *noteTool) Execute(ctx context.Context, args map[string]any) *tools.ToolResult {
+	text, _ := args["text"].(string)
+	t.notes = append(t.notes, text)
+	return tools.SilentResult("saved")
+}
+
+// TestAgentLoop_HeartbeatHitsResponseCache runs the same heartbeat twice, at
+// different times, through a cached provider.
+func TestAgentLoop_HeartbeatHitsResponseCache(t *testing.T) {
+	temperature := 0.0
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+				Temperature:       &temperature,
+			},
+		},
+	}
+	inner := &scriptedProvider{replies: []string{"HEARTBEAT_OK"}}
+	cache := providers.NewResponseCache(t.TempDir(), time.Hour, 100)
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), providers.NewCachingProvider(inner, cache, false))
+
+	agent := al.GetRegistry().GetDefaultAgent()
+	now := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	for range 2 {
+		at := now
+		agent.ContextBuilder.nowFunc = func() time.Time { return at }
+		now = now.Add(30 * time.Minute)
+		got, err := al.ProcessHeartbeat(context.Background(), "# Heartbeat Check\n\nCheck the disk.", "cli", "direct")
+		if err != nil {
+			t.Fatalf("ProcessHeartbeat() error = %v", err)
+		}
+		if got != "HEARTBEAT_OK" {
+			t.Errorf("response = %q", got)
+		}
+	}
+	if inner.calls != 1 {
+		t.Errorf("provider calls = %d, want the second heartbeat served from the cache", inner.calls)
+	}
+}
+
+// TestAgentLoop_ReplayToolLoop records a tool loop and replays it offline.
+// The recording is served by the hand-written testdata cassette; the replay
+// is strict, so it fails if the loop sends anything it did not record.
+func TestAgentLoop_ReplayToolLoop(t *testing.T) {
+	workspace := filepath.Join(t.TempDir(), "workspace")
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         workspace,
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+	}
+	live, err := providers.NewReplayProvider(filepath.Join("testdata", "tool_loop.json"))
+	if err != nil {
+		t.Fatalf("NewReplayProvider() error = %v", err)
+	}
+	cassette := filepath.Join(t.TempDir(), "tool_loop.json")
+
+	run := func(name string, provider providers.LLMProvider) {
+		// Both runs start from an empty workspace at the same path, so they
+		// send the same requests.
+		if err := os.RemoveAll(workspace); err != nil {
+			t.Fatal(err)
+		}
+		al := NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+		note := &noteTool{}
+		al.RegisterTool(note)
+
+		got, err := al.ProcessDirect(context.Background(), "Remember to buy milk", "test-session")
+		if err != nil {
+			t.Fatalf("%s: ProcessDirect() error = %v", name, err)
+		}
+		if got != "Noted: buy milk." {
+			t.Errorf("%s: response = %q, want the recorded answer", name, got)
+		}
+		if len(note.notes) != 1 || note.notes[0] != "buy milk" {
+			t.Errorf("%s: notes = %v, want the recorded tool call executed", name, note.notes)
+		}
+	}
+
+	run("record", providers.NewRecordingProvider(live, cassette))
+	replay, err := providers.NewReplayProvider(cassette)
+	if err != nil {
+		t.Fatalf("NewReplayProvider() error = %v", err)
+	}
+	replay.SetStrict(true)
+	run("replay", replay)
+
+	if n := replay.Remaining(); n != 0 {
+		t.Errorf("%d recorded interactions not used", n)
+	}
+	if n := live.Remaining(); n != 0 {
+		t.Errorf("%d hand-written interactions not used", n)
+	}
+}
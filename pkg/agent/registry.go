@@ -65,7 +65,23 @@ func (r *AgentRegistry) GetAgent(agentID string) (*AgentInstance, bool) {
 
 // ResolveRoute determines which agent handles the message.
 func (r *AgentRegistry) ResolveRoute(input routing.RouteInput) routing.ResolvedRoute {
-	return r.resolver.ResolveRoute(input)
+	r.mu.RLock()
+	resolver := r.resolver
+	r.mu.RUnlock()
+	return resolver.ResolveRoute(input)
+}
+
+// replaceWith swaps in the agents and routing bindings of other.
+// Messages already being processed keep their old AgentInstance.
+func (r *AgentRegistry) replaceWith(other *AgentRegistry) {
+	other.mu.RLock()
+	agents, resolver := other.agents, other.resolver
+	other.mu.RUnlock()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.agents = agents
+	r.resolver = resolver
 }
 
 // ListAgentIDs returns all registered agent IDs.
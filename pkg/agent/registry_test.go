@@ -2,10 +2,14 @@ package agent
 
 import (
 	"context"
+	"path/filepath"
 	"testing"
 
+	"github.com/sipeed/picoclaw/pkg/bus"
 	"github.com/sipeed/picoclaw/pkg/config"
 	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/routing"
+	"github.com/sipeed/picoclaw/pkg/tools"
 )
 
 type mockRegistryProvider struct{}
@@ -203,3 +207,84 @@ func TestAgentInstance_FallbackExplicitEmpty(t *testing.T) {
 		t.Errorf("expected 0 fallbacks (explicit empty), got %d: %v", len(agent.Fallbacks), agent.Fallbacks)
 	}
 }
+
+func TestAgentLoop_ReloadConfig(t *testing.T) {
+	workspace := t.TempDir()
+	cfg := testCfg(nil)
+	cfg.Agents.Defaults.Workspace = workspace
+
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), &mockProvider{})
+	al.RegisterTool(&mockCustomTool{})
+
+	main := al.GetRegistry().GetDefaultAgent()
+	main.Sessions.AddMessage("s1", "user", "hello")
+
+	next := testCfg([]config.AgentConfig{
+		{ID: "main", Default: true, Workspace: workspace},
+		{ID: "helper", Workspace: filepath.Join(workspace, "helper")},
+	})
+	next.Agents.Defaults.Workspace = workspace
+	next.Bindings = []config.AgentBinding{
+		{AgentID: "helper", Match: config.BindingMatch{Channel: "slack"}},
+	}
+	al.ReloadConfig(next, &mockProvider{})
+
+	registry := al.GetRegistry()
+	helper, ok := registry.GetAgent("helper")
+	if !ok {
+		t.Fatal("expected helper agent after reload")
+	}
+	if _, ok := helper.Tools.Get((&mockCustomTool{}).Name()); !ok {
+		t.Error("expected tools registered via RegisterTool to survive reload")
+	}
+
+	reloadedMain, _ := registry.GetAgent("main")
+	if reloadedMain == main {
+		t.Error("expected main agent to be rebuilt")
+	}
+	if len(reloadedMain.Sessions.GetHistory("s1")) != 1 {
+		t.Error("expected sessions to carry over when workspace is unchanged")
+	}
+
+	route := registry.ResolveRoute(routing.RouteInput{Channel: "slack"})
+	if route.AgentID != "helper" {
+		t.Errorf("expected binding to route slack to helper, got %s", route.AgentID)
+	}
+}
+
+// TestAgentLoop_ReloadDuringTurns reloads the config while turns and a
+// subagent run read it; run with -race.
+func TestAgentLoop_ReloadDuringTurns(t *testing.T) {
+	workspace := t.TempDir()
+	agents := []config.AgentConfig{{
+		ID:        "main",
+		Default:   true,
+		Subagents: &config.SubagentsConfig{Model: &config.AgentModelConfig{Primary: "cheap-model"}},
+	}}
+	cfg := testCfg(agents)
+	cfg.Agents.Defaults.Workspace = workspace
+	cfg.Agents.Defaults.ShowReasoning = true
+
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), &reasoningProvider{})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 10; i++ {
+			al.ProcessDirectWithChannel(context.Background(), "what is 6*7?", "s1", "telegram", "42", "u1")
+			al.runSubagent(context.Background(), tools.SubagentRequest{TaskID: "t1", Task: "add numbers"})
+		}
+	}()
+
+	for i := 0; ; i++ {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		next := testCfg(agents)
+		next.Agents.Defaults.Workspace = workspace
+		next.Agents.Defaults.ShowReasoning = i%2 == 0
+		al.ReloadConfig(next, &reasoningProvider{})
+	}
+}
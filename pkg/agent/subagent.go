@@ -0,0 +1,135 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/routing"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+const subagentPromptNote = `## Subagent
+
+You are running as a subagent on a task delegated by another agent. Complete it independently using your tools; nobody will answer follow-up questions.
+Finish with a clear, concise summary of what was done and the result. That summary is reported back to the agent that spawned you.`
+
+// runSubagent executes a spawned task as a registered agent: the target
+// agent's workspace, tools, skills and limits, with the parent's
+// subagents.model override if one is configured. The transcript is kept in
+// its own session so it never mixes with the agent's conversations.
+func (al *AgentLoop) runSubagent(ctx context.Context, req tools.SubagentRequest) (*tools.ToolLoopResult, error) {
+	agent, err := al.subagentInstance(req)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionKey := req.SessionKey
+	if sessionKey == "" {
+		sessionKey = routing.BuildSubagentSessionKey(agent.ID, req.TaskID)
+	}
+
+	logger.InfoCF("agent", "Running subagent",
+		map[string]any{
+			"task_id":     req.TaskID,
+			"agent_id":    agent.ID,
+			"parent":      req.ParentAgentID,
+			"model":       agent.Model,
+			"session_key": sessionKey,
+		})
+
+	messages := agent.ContextBuilder.BuildMessages(nil, "", req.Task, nil, req.OriginChannel, req.OriginChatID)
+	if len(messages) > 0 && messages[0].Role == "system" {
+		messages[0].Content += "\n\n---\n\n" + subagentPromptNote
+	}
+
+	agent.Sessions.AddMessage(sessionKey, "user", req.Task)
+
+	content, iterations, err := al.runLLMIteration(ctx, agent, messages, processOptions{
+		SessionKey:   sessionKey,
+		Channel:      req.OriginChannel,
+		ChatID:       req.OriginChatID,
+		UserMessage:  req.Task,
+		SendResponse: false,
+	})
+	if err != nil {
+		agent.Sessions.Save(sessionKey)
+		return nil, err
+	}
+	if ctx.Err() != nil {
+		agent.Sessions.Save(sessionKey)
+		return nil, ctx.Err()
+	}
+
+	agent.Sessions.AddMessage(sessionKey, "assistant", content)
+	agent.Sessions.Save(sessionKey)
+
+	return &tools.ToolLoopResult{Content: content, Iterations: iterations}, nil
+}
+
+// subagentInstance resolves the agent a request runs as. The result is a
+// copy, so model overrides never leak into the registered agent, and it
+// gets its own tool registry: the message, spawn and cron tools track the
+// chat they serve and what they sent, and sharing them would let the run
+// redirect or suppress the replies of the agent's own turns.
+func (al *AgentLoop) subagentInstance(req tools.SubagentRequest) (*AgentInstance, error) {
+	targetID := req.AgentID
+	if strings.TrimSpace(targetID) == "" {
+		targetID = req.ParentAgentID
+	}
+
+	var target *AgentInstance
+	if strings.TrimSpace(targetID) == "" {
+		target = al.registry.GetDefaultAgent()
+	} else {
+		target, _ = al.registry.GetAgent(routing.NormalizeAgentID(targetID))
+	}
+	if target == nil {
+		return nil, fmt.Errorf("agent %q not found", targetID)
+	}
+
+	for _, name := range req.Tools {
+		if _, ok := target.Tools.Get(name); !ok {
+			return nil, fmt.Errorf("agent %q has no tool %q", target.ID, name)
+		}
+	}
+
+	instance := *target
+	instance.Tools = target.Tools.Fork(req.Tools)
+	instance.ContextBuilder = target.ContextBuilder.withTools(instance.Tools)
+
+	parent := target
+	if req.ParentAgentID != "" {
+		if p, ok := al.registry.GetAgent(routing.NormalizeAgentID(req.ParentAgentID)); ok {
+			parent = p
+		}
+	}
+	if parent.Subagents != nil && parent.Subagents.Model != nil &&
+		strings.TrimSpace(parent.Subagents.Model.Primary) != "" {
+		instance.Model = strings.TrimSpace(parent.Subagents.Model.Primary)
+		instance.Fallbacks = parent.Subagents.Model.Fallbacks
+		instance.Candidates = providers.ResolveCandidates(providers.ModelConfig{
+			Primary:   instance.Model,
+			Fallbacks: instance.Fallbacks,
+		}, al.cfg.Load().Agents.Defaults.Provider)
+	}
+
+	return &instance, nil
+}
+
+// ListSubagentTasks returns all subagent tasks spawned by any agent.
+func (al *AgentLoop) ListSubagentTasks() []*tools.SubagentTask {
+	return al.subagents.ListTasks()
+}
+
+// GetSubagentTask returns a snapshot of one subagent task.
+func (al *AgentLoop) GetSubagentTask(taskID string) (*tools.SubagentTask, bool) {
+	return al.subagents.GetTask(taskID)
+}
+
+// CancelSubagentTask stops a running subagent task.
+func (al *AgentLoop) CancelSubagentTask(taskID string) error {
+	return al.subagents.Cancel(taskID)
+}
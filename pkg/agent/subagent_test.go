@@ -0,0 +1,223 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/routing"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+type recordingProvider struct {
+	mu     sync.Mutex
+	models []string
+	system []string
+}
+
+func (p *recordingProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.models = append(p.models, model)
+	p.system = append(p.system, messages[0].Content)
+	return &providers.LLMResponse{Content: "research done"}, nil
+}
+
+func (p *recordingProvider) GetDefaultModel() string {
+	return "mock-model"
+}
+
+func TestSubagent_RunsAsTargetAgent(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "main-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 5,
+			},
+			List: []config.AgentConfig{
+				{
+					ID:      "main",
+					Default: true,
+					Subagents: &config.SubagentsConfig{
+						AllowAgents: []string{"researcher"},
+						Model:       &config.AgentModelConfig{Primary: "cheap-model"},
+					},
+				},
+				{ID: "researcher", Workspace: t.TempDir(), Model: &config.AgentModelConfig{Primary: "research-model"}},
+			},
+		},
+	}
+
+	provider := &recordingProvider{}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+
+	mainAgent := al.registry.GetDefaultAgent()
+	spawn, ok := mainAgent.Tools.Get("spawn")
+	if !ok {
+		t.Fatal("spawn tool not registered")
+	}
+	result := spawn.Execute(context.Background(), map[string]any{
+		"task":     "find papers",
+		"agent_id": "researcher",
+	})
+	if result.IsError {
+		t.Fatalf("spawn failed: %s", result.ForLLM)
+	}
+
+	var task *tools.SubagentTask
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if task, _ = al.GetSubagentTask("subagent-1"); task != nil && task.Status != tools.SubagentRunning {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if task == nil || task.Status != tools.SubagentCompleted {
+		t.Fatalf("task did not complete: %+v", task)
+	}
+	if task.ParentAgentID != "main" || task.Result != "research done" {
+		t.Errorf("unexpected task: %+v", task)
+	}
+
+	provider.mu.Lock()
+	if len(provider.models) != 1 || provider.models[0] != "cheap-model" {
+		t.Errorf("expected parent's subagents.model, got %v", provider.models)
+	}
+	if !strings.Contains(provider.system[0], "running as a subagent") {
+		t.Error("expected subagent note in system prompt")
+	}
+	provider.mu.Unlock()
+
+	researcher, _ := al.registry.GetAgent("researcher")
+	if researcher.Model != "research-model" {
+		t.Errorf("model override leaked into registered agent: %s", researcher.Model)
+	}
+	history := researcher.Sessions.GetHistory(routing.BuildSubagentSessionKey("researcher", "subagent-1"))
+	if len(history) != 2 || history[1].Content != "research done" {
+		t.Errorf("expected transcript in subagent session, got %+v", history)
+	}
+	if len(researcher.Sessions.GetHistory(routing.BuildAgentMainSessionKey("researcher"))) != 0 {
+		t.Error("subagent run should not touch the agent's main session")
+	}
+}
+
+// overlapProvider interleaves a subagent run with a main-loop turn: the
+// subagent sends a message while the main turn is waiting for its reply.
+type overlapProvider struct {
+	mu           sync.Mutex
+	mainOnce     sync.Once
+	mainStarted  chan struct{}
+	subagentSent chan struct{}
+	subCalls     int
+	subTools     []string
+}
+
+func (p *overlapProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	if !strings.Contains(messages[0].Content, "running as a subagent") {
+		p.mainOnce.Do(func() { close(p.mainStarted) })
+		<-p.subagentSent
+		return &providers.LLMResponse{Content: "main reply"}, nil
+	}
+
+	p.mu.Lock()
+	p.subCalls++
+	call := p.subCalls
+	for _, tool := range tools {
+		p.subTools = append(p.subTools, tool.Function.Name)
+	}
+	p.mu.Unlock()
+
+	if call == 1 {
+		<-p.mainStarted
+		return &providers.LLMResponse{ToolCalls: []providers.ToolCall{{
+			ID:        "call-1",
+			Name:      "message",
+			Arguments: map[string]any{"content": "progress"},
+		}}}, nil
+	}
+	close(p.subagentSent)
+	return &providers.LLMResponse{Content: "subagent done"}, nil
+}
+
+func (p *overlapProvider) GetDefaultModel() string {
+	return "mock-model"
+}
+
+func TestSubagent_KeepsOwnToolState(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 5,
+			},
+		},
+	}
+
+	msgBus := bus.NewMessageBus()
+	provider := &overlapProvider{mainStarted: make(chan struct{}), subagentSent: make(chan struct{})}
+	al := NewAgentLoop(cfg, msgBus, provider)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go al.Run(ctx)
+
+	spawn, _ := al.registry.GetDefaultAgent().Tools.Get("spawn")
+	spawn.(tools.ContextualTool).SetContext("telegram", "sub-chat")
+	if result := spawn.Execute(ctx, map[string]any{
+		"task":  "report progress",
+		"tools": []any{"message"},
+	}); result.IsError {
+		t.Fatalf("spawn failed: %s", result.ForLLM)
+	}
+	msgBus.PublishInbound(bus.InboundMessage{
+		Channel:    "telegram",
+		SenderID:   "user",
+		ChatID:     "main-chat",
+		Content:    "hello",
+		SessionKey: "main-session",
+	})
+
+	got := map[string]string{}
+	for len(got) < 2 {
+		msg, ok := msgBus.SubscribeOutbound(ctx)
+		if !ok {
+			t.Fatalf("timed out, got %v", got)
+		}
+		got[msg.ChatID] = msg.Content
+	}
+	if got["main-chat"] != "main reply" {
+		t.Errorf("main turn reply = %q, want it delivered despite the subagent's message", got["main-chat"])
+	}
+	if got["sub-chat"] != "progress" {
+		t.Errorf("subagent message went to the wrong chat: %v", got)
+	}
+
+	provider.mu.Lock()
+	defer provider.mu.Unlock()
+	for _, name := range provider.subTools {
+		if name != "message" {
+			t.Errorf("subagent was offered tool %q outside its allow list", name)
+		}
+	}
+}
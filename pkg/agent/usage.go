@@ -0,0 +1,71 @@
+package agent
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// UsageStats aggregates token usage for one agent/model pair.
+type UsageStats struct {
+	AgentID          string    `json:"agent_id"`
+	Model            string    `json:"model"`
+	Requests         int       `json:"requests"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	TotalTokens      int       `json:"total_tokens"`
+	CachedTokens     int       `json:"cached_tokens"`
+	LastUsed         time.Time `json:"last_used"`
+}
+
+// UsageTracker records LLM token usage in memory since gateway start.
+type UsageTracker struct {
+	mu    sync.Mutex
+	stats map[string]*UsageStats
+}
+
+// NewUsageTracker creates an empty usage tracker.
+func NewUsageTracker() *UsageTracker {
+	return &UsageTracker{stats: make(map[string]*UsageStats)}
+}
+
+// Record adds one LLM call to the stats. A nil usage still counts the request.
+func (t *UsageTracker) Record(agentID, model string, usage *providers.UsageInfo) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := agentID + "/" + model
+	s, ok := t.stats[key]
+	if !ok {
+		s = &UsageStats{AgentID: agentID, Model: model}
+		t.stats[key] = s
+	}
+	s.Requests++
+	s.LastUsed = time.Now()
+	if usage != nil {
+		s.PromptTokens += usage.PromptTokens
+		s.CompletionTokens += usage.CompletionTokens
+		s.TotalTokens += usage.TotalTokens
+		s.CachedTokens += usage.CachedTokens
+	}
+}
+
+// Snapshot returns a copy of all stats sorted by agent and model.
+func (t *UsageTracker) Snapshot() []UsageStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	out := make([]UsageStats, 0, len(t.stats))
+	for _, s := range t.stats {
+		out = append(out, *s)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].AgentID != out[j].AgentID {
+			return out[i].AgentID < out[j].AgentID
+		}
+		return out[i].Model < out[j].Model
+	})
+	return out
+}
@@ -0,0 +1,106 @@
+package bus
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// MediaKind is the broad type of an attachment, which decides how channels
+// present it (photo, voice/audio message, video or plain file).
+type MediaKind string
+
+const (
+	MediaImage MediaKind = "image"
+	MediaAudio MediaKind = "audio"
+	MediaVideo MediaKind = "video"
+	MediaFile  MediaKind = "file"
+)
+
+// Name returns the file name shown to the recipient.
+func (m OutboundMedia) Name() string {
+	if m.Filename != "" {
+		return m.Filename
+	}
+	if m.Path != "" {
+		return filepath.Base(m.Path)
+	}
+	if exts, _ := mime.ExtensionsByType(m.MIMEType); len(exts) > 0 {
+		return "file" + exts[0]
+	}
+	return "file"
+}
+
+// ContentType returns MIMEType if set, otherwise the type guessed from the
+// file extension or, failing that, from the first bytes of the content.
+func (m OutboundMedia) ContentType() string {
+	if m.MIMEType != "" {
+		return m.MIMEType
+	}
+	if t := mime.TypeByExtension(strings.ToLower(filepath.Ext(m.Name()))); t != "" {
+		return t
+	}
+	head := m.Data
+	if head == nil && m.Path != "" {
+		f, err := os.Open(m.Path)
+		if err != nil {
+			return "application/octet-stream"
+		}
+		defer f.Close()
+		buf := make([]byte, 512)
+		n, _ := io.ReadFull(f, buf)
+		head = buf[:n]
+	}
+	return http.DetectContentType(head)
+}
+
+// Kind classifies the media by its content type.
+func (m OutboundMedia) Kind() MediaKind {
+	t := m.ContentType()
+	switch {
+	case strings.HasPrefix(t, "image/"):
+		return MediaImage
+	case strings.HasPrefix(t, "audio/"):
+		return MediaAudio
+	case strings.HasPrefix(t, "video/"):
+		return MediaVideo
+	default:
+		return MediaFile
+	}
+}
+
+// Size returns the size of the content in bytes.
+func (m OutboundMedia) Size() (int64, error) {
+	if m.Data != nil || m.Path == "" {
+		return int64(len(m.Data)), nil
+	}
+	info, err := os.Stat(m.Path)
+	if err != nil {
+		return 0, err
+	}
+	if info.IsDir() {
+		return 0, fmt.Errorf("%s is a directory", m.Path)
+	}
+	return info.Size(), nil
+}
+
+// Open returns a reader for the content. The caller closes it.
+func (m OutboundMedia) Open() (io.ReadCloser, error) {
+	if m.Data != nil || m.Path == "" {
+		return io.NopCloser(bytes.NewReader(m.Data)), nil
+	}
+	return os.Open(m.Path)
+}
+
+// ReadAll returns the whole content.
+func (m OutboundMedia) ReadAll() ([]byte, error) {
+	if m.Data != nil || m.Path == "" {
+		return m.Data, nil
+	}
+	return os.ReadFile(m.Path)
+}
@@ -11,9 +11,62 @@ type InboundMessage struct {
 }
 
 type OutboundMessage struct {
-	Channel string `json:"channel"`
-	ChatID  string `json:"chat_id"`
-	Content string `json:"content"`
+	Channel     string               `json:"channel"`
+	ChatID      string               `json:"chat_id"`
+	Content     string               `json:"content"`
+	Media       []OutboundMedia      `json:"media,omitempty"`
+	Interactive []InteractiveElement `json:"interactive,omitempty"`
+}
+
+// InteractiveKind is the kind of an interactive element.
+type InteractiveKind string
+
+const (
+	// InteractiveButtons is a set of buttons shown with the message.
+	InteractiveButtons InteractiveKind = "buttons"
+	// InteractiveSelect is a drop-down menu of options.
+	InteractiveSelect InteractiveKind = "select"
+	// InteractiveQuickReplies are suggested replies shown above the input
+	// field, which disappear once one is used.
+	InteractiveQuickReplies InteractiveKind = "quick_replies"
+)
+
+// InteractiveElement is a set of options the user can pick from. When the
+// user picks one, the channel publishes an InboundMessage whose content is
+// the option's value and whose metadata holds "interaction" (the kind),
+// "action_id" (the element ID), "action_value" and "action_label".
+type InteractiveElement struct {
+	Kind        InteractiveKind     `json:"kind"`
+	ID          string              `json:"id,omitempty"`
+	Placeholder string              `json:"placeholder,omitempty"` // select menus
+	Options     []InteractiveOption `json:"options"`
+}
+
+// InteractiveOption is one button or menu entry. An option with a URL
+// opens the link instead of replying.
+type InteractiveOption struct {
+	Label string `json:"label"`
+	Value string `json:"value,omitempty"` // defaults to Label
+	URL   string `json:"url,omitempty"`
+	Style string `json:"style,omitempty"` // "primary" or "danger"; buttons only
+}
+
+// OptionValue returns the value replied when o is picked.
+func (o InteractiveOption) OptionValue() string {
+	if o.Value != "" {
+		return o.Value
+	}
+	return o.Label
+}
+
+// OutboundMedia is a file sent along with an outbound message, either read
+// from Path or given inline as Data.
+type OutboundMedia struct {
+	Path     string `json:"path,omitempty"`
+	Data     []byte `json:"data,omitempty"`
+	Filename string `json:"filename,omitempty"`
+	MIMEType string `json:"mime_type,omitempty"`
+	Caption  string `json:"caption,omitempty"`
 }
 
 type MessageHandler func(InboundMessage) error
@@ -17,20 +17,23 @@ type Channel interface {
 }
 
 type BaseChannel struct {
-	config    any
-	bus       *bus.MessageBus
-	running   bool
-	name      string
-	allowList []string
+	config       any
+	bus          *bus.MessageBus
+	running      bool
+	name         string
+	allowList    []string
+	interactions *interactions
+	groups       *groupGate
 }
 
 func NewBaseChannel(name string, config any, bus *bus.MessageBus, allowList []string) *BaseChannel {
 	return &BaseChannel{
-		config:    config,
-		bus:       bus,
-		name:      name,
-		allowList: allowList,
-		running:   false,
+		config:       config,
+		bus:          bus,
+		name:         name,
+		allowList:    allowList,
+		running:      false,
+		interactions: newInteractions(),
 	}
 }
 
@@ -98,6 +101,36 @@ func (c *BaseChannel) HandleMessage(senderID, chatID, content string, media []st
 	c.bus.PublishInbound(msg)
 }
 
+// HandleInteraction publishes the pick of the option registered under
+// token as an inbound message to the chat the option was sent to. It
+// returns false if the token is unknown or expired.
+func (c *BaseChannel) HandleInteraction(senderID, token string, metadata map[string]string) bool {
+	it, ok := c.interactions.lookup(token)
+	if !ok {
+		return false
+	}
+	c.HandleMessage(senderID, it.chatID, it.value, nil, interactionMetadata(it, metadata))
+	return true
+}
+
+// persistInteractions keeps the channel's interactive options in the log
+// file at path, so they can still be picked after a reload or restart.
+func (c *BaseChannel) persistInteractions(path string) error {
+	return c.interactions.persist(path)
+}
+
+// resolveQuickReply returns the value of the quick reply labelled text
+// that was sent to chatID, adding the interaction to metadata, or text
+// itself if it is not one.
+func (c *BaseChannel) resolveQuickReply(chatID, text string, metadata map[string]string) string {
+	it, ok := c.interactions.matchQuickReply(chatID, text)
+	if !ok {
+		return text
+	}
+	interactionMetadata(it, metadata)
+	return it.value
+}
+
 func (c *BaseChannel) setRunning(running bool) {
 	c.running = running
 }
@@ -38,6 +38,7 @@ func NewDingTalkChannel(cfg config.DingTalkConfig, messageBus *bus.MessageBus) (
 	}
 
 	base := NewBaseChannel("dingtalk", cfg, messageBus, cfg.AllowFrom)
+	base.setGroupPolicy(cfg.Groups, config.GroupPolicy{})
 
 	return &DingTalkChannel{
 		BaseChannel:  base,
@@ -115,9 +116,18 @@ func (c *DingTalkChannel) Send(ctx context.Context, msg bus.OutboundMessage) err
 	})
 
 	// Use the session webhook to send the reply
-	return c.SendDirectReply(ctx, sessionWebhook, msg.Content)
+	for _, chunk := range dingTalkFormat.Render(msg.Content) {
+		if err := c.SendDirectReply(ctx, sessionWebhook, chunk); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
+// dingTalkFormat is the Markdown of DingTalk markdown messages, which are
+// limited to 5000 characters.
+var dingTalkFormat = MessageFormat{Dialect: DialectMarkdown, MaxLen: 5000}
+
 // onChatBotMessageReceived implements the IChatBotMessageHandler function signature
 // This is called by the Stream SDK when a new message arrives
 // IChatBotMessageHandler is: func(c context.Context, data *chatbot.BotCallbackDataModel) ([]byte, error)
@@ -165,6 +175,11 @@ func (c *DingTalkChannel) onChatBotMessageReceived(
 	} else {
 		metadata["peer_kind"] = "group"
 		metadata["peer_id"] = data.ConversationId
+
+		var ok bool
+		if content, _, ok = c.admitGroupMessage(senderID, chatID, content, data.IsInAtList, metadata); !ok {
+			return nil, nil
+		}
 	}
 
 	logger.DebugCF("dingtalk", "Received message", map[string]any{
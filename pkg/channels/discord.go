@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -20,6 +21,7 @@ import (
 const (
 	transcriptionTimeout = 30 * time.Second
 	sendTimeout          = 10 * time.Second
+	uploadTimeout        = 60 * time.Second
 )
 
 type DiscordChannel struct {
@@ -40,6 +42,7 @@ func NewDiscordChannel(cfg config.DiscordConfig, bus *bus.MessageBus) (*DiscordC
 	}
 
 	base := NewBaseChannel("discord", cfg, bus, cfg.AllowFrom)
+	base.setGroupPolicy(cfg.Groups, mentionOnlyDefaults(cfg.MentionOnly))
 
 	return &DiscordChannel{
 		BaseChannel: base,
@@ -75,6 +78,7 @@ func (c *DiscordChannel) Start(ctx context.Context) error {
 	c.botUserID = botUser.ID
 
 	c.session.AddHandler(c.handleMessage)
+	c.session.AddHandler(c.handleInteraction)
 
 	if err := c.session.Open(); err != nil {
 		return fmt.Errorf("failed to open discord session: %w", err)
@@ -121,15 +125,25 @@ func (c *DiscordChannel) Send(ctx context.Context, msg bus.OutboundMessage) erro
 		return fmt.Errorf("channel ID is empty")
 	}
 
-	runes := []rune(msg.Content)
-	if len(runes) == 0 {
-		return nil
-	}
+	files, notices := discordMediaLimits.prepare(msg.Media)
+	content := appendNotices(msg.Content, notices)
+	content = interactiveContent(content, msg.Interactive)
 
-	chunks := utils.SplitMessage(msg.Content, 2000) // Split messages into chunks, Discord length limit: 2000 chars
+	if len([]rune(content)) > 0 {
+		chunks := discordFormat.Render(content)
+		for i, chunk := range chunks {
+			var components []discordgo.MessageComponent
+			if i == len(chunks)-1 {
+				components = c.components(msg.ChatID, msg.Interactive)
+			}
+			if err := c.sendChunk(ctx, channelID, chunk, components); err != nil {
+				return err
+			}
+		}
+	}
 
-	for _, chunk := range chunks {
-		if err := c.sendChunk(ctx, channelID, chunk); err != nil {
+	for _, f := range files {
+		if err := c.sendFile(ctx, channelID, f); err != nil {
 			return err
 		}
 	}
@@ -137,14 +151,211 @@ func (c *DiscordChannel) Send(ctx context.Context, msg bus.OutboundMessage) erro
 	return nil
 }
 
-func (c *DiscordChannel) sendChunk(ctx context.Context, channelID, content string) error {
+// discordFormat is Discord's Markdown with its 2000 character limit.
+var discordFormat = MessageFormat{Dialect: DialectMarkdown, MaxLen: 2000}
+
+func (c *DiscordChannel) SupportsMedia() bool {
+	return true
+}
+
+func (c *DiscordChannel) SupportsInteractive() bool {
+	return true
+}
+
+// Message component limits.
+const (
+	discordMaxRows        = 5
+	discordButtonsPerRow  = 5
+	discordSelectLimit    = 25
+	discordComponentLabel = 80
+)
+
+// components lays out the interactive elements as message components:
+// rows of up to five buttons, and a row for each menu. Quick replies are
+// grey buttons, and menu options that open links become link buttons, as
+// Discord menus cannot. Components beyond Discord's five rows are dropped.
+func (c *DiscordChannel) components(chatID string, elements []bus.InteractiveElement) []discordgo.MessageComponent {
+	links, elements := linkOptions(elements, bus.InteractiveSelect)
+
+	var rows []discordgo.MessageComponent
+	var buttons []discordgo.MessageComponent
+	flush := func() {
+		for len(buttons) > 0 {
+			n := min(len(buttons), discordButtonsPerRow)
+			rows = append(rows, discordgo.ActionsRow{Components: buttons[:n]})
+			buttons = buttons[n:]
+		}
+	}
+
+	for _, el := range elements {
+		if el.Kind != bus.InteractiveSelect {
+			for _, opt := range el.Options {
+				buttons = append(buttons, c.button(chatID, el, opt))
+			}
+			continue
+		}
+		flush()
+		placeholder := el.Placeholder
+		if placeholder == "" {
+			placeholder = "Choose an option"
+		}
+		menu := discordgo.SelectMenu{
+			MenuType:    discordgo.StringSelectMenu,
+			CustomID:    newInteractionToken(),
+			Placeholder: utils.Truncate(placeholder, 150),
+		}
+		for _, opt := range el.Options[:min(len(el.Options), discordSelectLimit)] {
+			menu.Options = append(menu.Options, discordgo.SelectMenuOption{
+				Label: utils.Truncate(opt.Label, discordComponentLabel),
+				Value: c.interactions.register(chatID, el, opt),
+			})
+		}
+		rows = append(rows, discordgo.ActionsRow{Components: []discordgo.MessageComponent{menu}})
+	}
+	for _, opt := range links {
+		buttons = append(buttons, c.button(chatID, bus.InteractiveElement{}, opt))
+	}
+	flush()
+
+	if len(rows) > discordMaxRows {
+		logger.WarnCF("discord", "Dropping message components over the row limit", map[string]any{
+			"rows": len(rows),
+		})
+		rows = rows[:discordMaxRows]
+	}
+	return rows
+}
+
+func (c *DiscordChannel) button(chatID string, el bus.InteractiveElement, opt bus.InteractiveOption) discordgo.Button {
+	button := discordgo.Button{Label: utils.Truncate(opt.Label, discordComponentLabel)}
+	if opt.URL != "" {
+		button.Style = discordgo.LinkButton
+		button.URL = opt.URL
+		return button
+	}
+
+	button.CustomID = c.interactions.register(chatID, el, opt)
+	switch {
+	case opt.Style == "danger":
+		button.Style = discordgo.DangerButton
+	case opt.Style == "primary":
+		button.Style = discordgo.PrimaryButton
+	default:
+		button.Style = discordgo.SecondaryButton
+	}
+	return button
+}
+
+// handleInteraction handles clicks on buttons and picks from menus sent by
+// Send.
+func (c *DiscordChannel) handleInteraction(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if i.Type != discordgo.InteractionMessageComponent {
+		return
+	}
+	user := i.User
+	if i.Member != nil {
+		user = i.Member.User
+	}
+	if user == nil {
+		return
+	}
+
+	// Acknowledge the click; the reply comes as a new message.
+	if err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredMessageUpdate,
+	}); err != nil {
+		logger.DebugCF("discord", "Failed to acknowledge interaction", map[string]any{"error": err.Error()})
+	}
+
+	if !c.IsAllowed(user.ID) {
+		logger.DebugCF("discord", "Interaction rejected by allowlist", map[string]any{
+			"user_id": user.ID,
+		})
+		return
+	}
+
+	data := i.MessageComponentData()
+	token := data.CustomID
+	if len(data.Values) > 0 {
+		token = data.Values[0]
+	}
+
+	peerKind := "channel"
+	peerID := i.ChannelID
+	if i.GuildID == "" {
+		peerKind = "direct"
+		peerID = user.ID
+	}
+	metadata := map[string]string{
+		"user_id":    user.ID,
+		"username":   user.Username,
+		"guild_id":   i.GuildID,
+		"channel_id": i.ChannelID,
+		"is_dm":      fmt.Sprintf("%t", i.GuildID == ""),
+		"peer_kind":  peerKind,
+		"peer_id":    peerID,
+	}
+
+	if c.HandleInteraction(user.ID, token, metadata) {
+		c.startTyping(i.ChannelID)
+	}
+}
+
+// discordMediaLimits is the attachment limit for bots in servers without
+// boosts.
+var discordMediaLimits = mediaLimits{bus.MediaFile: 10 << 20}
+
+// sendFile uploads f as a message attachment, with its caption as the
+// message text.
+func (c *DiscordChannel) sendFile(ctx context.Context, channelID string, f outboundFile) error {
+	r, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", f.Name(), err)
+	}
+	defer r.Close()
+
+	sendCtx, cancel := context.WithTimeout(ctx, uploadTimeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.session.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+			Content: utils.Truncate(f.Caption, 2000),
+			Files: []*discordgo.File{{
+				Name:        f.Name(),
+				ContentType: f.ContentType(),
+				Reader:      r,
+			}},
+		}, discordgo.WithContext(sendCtx))
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("failed to upload %s to discord: %w", f.Name(), err)
+		}
+		return nil
+	case <-sendCtx.Done():
+		return fmt.Errorf("upload %s timeout: %w", f.Name(), sendCtx.Err())
+	}
+}
+
+func (c *DiscordChannel) sendChunk(
+	ctx context.Context,
+	channelID, content string,
+	components []discordgo.MessageComponent,
+) error {
 	// Use the passed ctx for timeout control
 	sendCtx, cancel := context.WithTimeout(ctx, sendTimeout)
 	defer cancel()
 
 	done := make(chan error, 1)
 	go func() {
-		_, err := c.session.ChannelMessageSend(channelID, content)
+		_, err := c.session.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+			Content:    content,
+			Components: components,
+		})
 		done <- err
 	}()
 
@@ -184,32 +395,41 @@ func (c *DiscordChannel) handleMessage(s *discordgo.Session, m *discordgo.Messag
 		return
 	}
 
-	// If configured to only respond to mentions, check if bot is mentioned
-	// Skip this check for DMs (GuildID is empty) - DMs should always be responded to
-	if c.config.MentionOnly && m.GuildID != "" {
-		isMentioned := false
-		for _, mention := range m.Mentions {
-			if mention.ID == c.botUserID {
-				isMentioned = true
-				break
-			}
-		}
-		if !isMentioned {
-			logger.DebugCF("discord", "Message ignored - bot not mentioned", map[string]any{
-				"user_id": m.Author.ID,
-			})
-			return
-		}
-	}
-
 	senderID := m.Author.ID
 	senderName := m.Author.Username
 	if m.Author.Discriminator != "" && m.Author.Discriminator != "0" {
 		senderName += "#" + m.Author.Discriminator
 	}
 
-	content := m.Content
-	content = c.stripBotMention(content)
+	peerKind := "channel"
+	peerID := m.ChannelID
+	if m.GuildID == "" {
+		peerKind = "direct"
+		peerID = senderID
+	}
+
+	metadata := map[string]string{
+		"message_id":   m.ID,
+		"user_id":      senderID,
+		"username":     m.Author.Username,
+		"display_name": senderName,
+		"guild_id":     m.GuildID,
+		"channel_id":   m.ChannelID,
+		"is_dm":        fmt.Sprintf("%t", m.GuildID == ""),
+		"peer_kind":    peerKind,
+		"peer_id":      peerID,
+	}
+
+	content := c.stripBotMention(m.Content)
+	// DMs (GuildID is empty) are always answered.
+	if m.GuildID != "" {
+		mentioned := slices.ContainsFunc(m.Mentions, func(u *discordgo.User) bool { return u.ID == c.botUserID })
+		var ok bool
+		if content, _, ok = c.admitGroupMessage(senderID, m.ChannelID, content, mentioned, metadata); !ok {
+			return
+		}
+	}
+
 	mediaPaths := make([]string, 0, len(m.Attachments))
 	localFiles := make([]string, 0, len(m.Attachments))
 
@@ -286,25 +506,6 @@ func (c *DiscordChannel) handleMessage(s *discordgo.Session, m *discordgo.Messag
 		"preview":     utils.Truncate(content, 50),
 	})
 
-	peerKind := "channel"
-	peerID := m.ChannelID
-	if m.GuildID == "" {
-		peerKind = "direct"
-		peerID = senderID
-	}
-
-	metadata := map[string]string{
-		"message_id":   m.ID,
-		"user_id":      senderID,
-		"username":     m.Author.Username,
-		"display_name": senderName,
-		"guild_id":     m.GuildID,
-		"channel_id":   m.ChannelID,
-		"is_dm":        fmt.Sprintf("%t", m.GuildID == ""),
-		"peer_kind":    peerKind,
-		"peer_id":      peerID,
-	}
-
 	c.HandleMessage(senderID, m.ChannelID, content, mediaPaths, metadata)
 }
 
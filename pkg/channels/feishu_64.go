@@ -6,11 +6,14 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
 	lark "github.com/larksuite/oapi-sdk-go/v3"
 	larkdispatcher "github.com/larksuite/oapi-sdk-go/v3/event/dispatcher"
+	"github.com/larksuite/oapi-sdk-go/v3/event/dispatcher/callback"
 	larkim "github.com/larksuite/oapi-sdk-go/v3/service/im/v1"
 	larkws "github.com/larksuite/oapi-sdk-go/v3/ws"
 
@@ -26,12 +29,14 @@ type FeishuChannel struct {
 	client   *lark.Client
 	wsClient *larkws.Client
 
-	mu     sync.Mutex
-	cancel context.CancelFunc
+	mu        sync.Mutex
+	cancel    context.CancelFunc
+	chatTypes sync.Map // chat ID -> chat type of its last message, for card actions
 }
 
 func NewFeishuChannel(cfg config.FeishuConfig, bus *bus.MessageBus) (*FeishuChannel, error) {
 	base := NewBaseChannel("feishu", cfg, bus, cfg.AllowFrom)
+	base.setGroupPolicy(cfg.Groups, config.GroupPolicy{})
 
 	return &FeishuChannel{
 		BaseChannel: base,
@@ -46,7 +51,8 @@ func (c *FeishuChannel) Start(ctx context.Context) error {
 	}
 
 	dispatcher := larkdispatcher.NewEventDispatcher(c.config.VerificationToken, c.config.EncryptKey).
-		OnP2MessageReceiveV1(c.handleMessageReceive)
+		OnP2MessageReceiveV1(c.handleMessageReceive).
+		OnP2CardActionTrigger(c.handleCardAction)
 
 	runCtx, cancel := context.WithCancel(ctx)
 
@@ -97,7 +103,179 @@ func (c *FeishuChannel) Send(ctx context.Context, msg bus.OutboundMessage) error
 		return fmt.Errorf("chat ID is empty")
 	}
 
-	payload, err := json.Marshal(map[string]string{"text": msg.Content})
+	files, notices := feishuMediaLimits.prepare(msg.Media)
+	content := appendNotices(msg.Content, notices)
+
+	content = interactiveContent(content, msg.Interactive)
+
+	if content != "" || len(files) == 0 {
+		chunks := feishuFormat.Render(content)
+		for i, chunk := range chunks {
+			card := feishuMarkdownCard(chunk)
+			if i == len(chunks)-1 && len(msg.Interactive) > 0 {
+				card["elements"] = append(card["elements"].([]map[string]any), c.cardActions(msg.ChatID, msg.Interactive))
+			}
+			if err := c.createMessage(ctx, msg.ChatID, larkim.MsgTypeInteractive, card); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, f := range files {
+		if err := c.sendFile(ctx, msg.ChatID, f); err != nil {
+			return err
+		}
+	}
+
+	logger.DebugCF("feishu", "Feishu message sent", map[string]any{
+		"chat_id": msg.ChatID,
+		"files":   len(files),
+	})
+
+	return nil
+}
+
+func (c *FeishuChannel) SupportsMedia() bool {
+	return true
+}
+
+// feishuMediaLimits are the upload limits of the image and file APIs.
+var feishuMediaLimits = mediaLimits{
+	bus.MediaImage: 10 << 20,
+	bus.MediaFile:  30 << 20,
+}
+
+// feishuFormat is the Markdown of card markdown elements. Cards are
+// limited to 30 KB of JSON, which leaves room for escaping.
+var feishuFormat = MessageFormat{Dialect: DialectFeishu, MaxLen: 16000}
+
+// feishuMarkdownCard wraps content in a message card with a single
+// markdown element, the only Feishu message type that renders Markdown.
+func feishuMarkdownCard(content string) map[string]any {
+	return map[string]any{
+		"config": map[string]any{"wide_screen_mode": true},
+		"elements": []map[string]any{
+			{"tag": "markdown", "content": content},
+		},
+	}
+}
+
+func (c *FeishuChannel) SupportsInteractive() bool {
+	return true
+}
+
+// cardActions returns a card action module with the interactive elements:
+// buttons for buttons and quick replies, and a static select for each menu.
+// Menu options that open links become link buttons, as menus cannot.
+func (c *FeishuChannel) cardActions(chatID string, elements []bus.InteractiveElement) map[string]any {
+	links, elements := linkOptions(elements, bus.InteractiveSelect)
+
+	var actions []map[string]any
+	for _, el := range elements {
+		if el.Kind != bus.InteractiveSelect {
+			for _, opt := range el.Options {
+				actions = append(actions, c.cardButton(chatID, el, opt))
+			}
+			continue
+		}
+		var options []map[string]any
+		for _, opt := range el.Options {
+			options = append(options, map[string]any{
+				"text":  feishuPlainText(opt.Label),
+				"value": c.interactions.register(chatID, el, opt),
+			})
+		}
+		placeholder := el.Placeholder
+		if placeholder == "" {
+			placeholder = "Choose an option"
+		}
+		actions = append(actions, map[string]any{
+			"tag":         "select_static",
+			"placeholder": feishuPlainText(placeholder),
+			"options":     options,
+		})
+	}
+	for _, opt := range links {
+		actions = append(actions, c.cardButton(chatID, bus.InteractiveElement{}, opt))
+	}
+
+	return map[string]any{"tag": "action", "actions": actions}
+}
+
+func (c *FeishuChannel) cardButton(chatID string, el bus.InteractiveElement, opt bus.InteractiveOption) map[string]any {
+	button := map[string]any{
+		"tag":  "button",
+		"text": feishuPlainText(opt.Label),
+		"type": "default",
+	}
+	if opt.Style == "primary" || opt.Style == "danger" {
+		button["type"] = opt.Style
+	}
+	if opt.URL != "" {
+		button["url"] = opt.URL
+	} else {
+		button["value"] = map[string]string{"token": c.interactions.register(chatID, el, opt)}
+	}
+	return button
+}
+
+func feishuPlainText(text string) map[string]string {
+	return map[string]string{"tag": "plain_text", "content": text}
+}
+
+// handleCardAction handles clicks on card buttons and picks from card
+// menus sent by Send.
+func (c *FeishuChannel) handleCardAction(
+	_ context.Context,
+	event *callback.CardActionTriggerEvent,
+) (*callback.CardActionTriggerResponse, error) {
+	if event == nil || event.Event == nil || event.Event.Action == nil || event.Event.Operator == nil {
+		return nil, nil
+	}
+	action := event.Event.Action
+	operator := event.Event.Operator
+
+	senderID := operator.OpenID
+	if operator.UserID != nil && *operator.UserID != "" {
+		senderID = *operator.UserID
+	}
+	if !c.IsAllowed(senderID) {
+		logger.DebugCF("feishu", "Card action rejected by allowlist", map[string]any{
+			"sender_id": senderID,
+		})
+		return nil, nil
+	}
+
+	token := action.Option
+	if t, ok := action.Value["token"].(string); ok {
+		token = t
+	}
+
+	metadata := map[string]string{
+		"peer_kind": "direct",
+		"peer_id":   senderID,
+	}
+	if ctx := event.Event.Context; ctx != nil {
+		metadata["message_id"] = ctx.OpenMessageID
+		if chatType, ok := c.chatTypes.Load(ctx.OpenChatID); ok && chatType != "p2p" {
+			metadata["peer_kind"] = "group"
+			metadata["peer_id"] = ctx.OpenChatID
+		}
+	}
+	if operator.TenantKey != nil {
+		metadata["tenant_key"] = *operator.TenantKey
+	}
+
+	if !c.HandleInteraction(senderID, token, metadata) {
+		return &callback.CardActionTriggerResponse{
+			Toast: &callback.Toast{Type: "info", Content: "This option has expired."},
+		}, nil
+	}
+	return &callback.CardActionTriggerResponse{}, nil
+}
+
+func (c *FeishuChannel) createMessage(ctx context.Context, chatID, msgType string, content any) error {
+	payload, err := json.Marshal(content)
 	if err != nil {
 		return fmt.Errorf("failed to marshal feishu content: %w", err)
 	}
@@ -105,8 +283,8 @@ func (c *FeishuChannel) Send(ctx context.Context, msg bus.OutboundMessage) error
 	req := larkim.NewCreateMessageReqBuilder().
 		ReceiveIdType(larkim.ReceiveIdTypeChatId).
 		Body(larkim.NewCreateMessageReqBodyBuilder().
-			ReceiveId(msg.ChatID).
-			MsgType(larkim.MsgTypeText).
+			ReceiveId(chatID).
+			MsgType(msgType).
 			Content(string(payload)).
 			Uuid(fmt.Sprintf("picoclaw-%d", time.Now().UnixNano())).
 			Build()).
@@ -121,13 +299,74 @@ func (c *FeishuChannel) Send(ctx context.Context, msg bus.OutboundMessage) error
 		return fmt.Errorf("feishu api error: code=%d msg=%s", resp.Code, resp.Msg)
 	}
 
-	logger.DebugCF("feishu", "Feishu message sent", map[string]any{
-		"chat_id": msg.ChatID,
-	})
-
 	return nil
 }
 
+// sendFile uploads f and sends it as an image or file message. Feishu
+// media messages have no caption, so the caption goes out as text first.
+func (c *FeishuChannel) sendFile(ctx context.Context, chatID string, f outboundFile) error {
+	if f.Caption != "" {
+		if err := c.createMessage(ctx, chatID, larkim.MsgTypeText, map[string]string{"text": f.Caption}); err != nil {
+			return err
+		}
+	}
+
+	r, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", f.Name(), err)
+	}
+	defer r.Close()
+
+	if f.kind == bus.MediaImage {
+		resp, err := c.client.Im.V1.Image.Create(ctx, larkim.NewCreateImageReqBuilder().
+			Body(larkim.NewCreateImageReqBodyBuilder().
+				ImageType("message").
+				Image(r).
+				Build()).
+			Build())
+		if err != nil {
+			return fmt.Errorf("failed to upload feishu image: %w", err)
+		}
+		if !resp.Success() {
+			return fmt.Errorf("feishu image upload error: code=%d msg=%s", resp.Code, resp.Msg)
+		}
+		return c.createMessage(ctx, chatID, larkim.MsgTypeImage,
+			map[string]string{"image_key": *resp.Data.ImageKey})
+	}
+
+	resp, err := c.client.Im.V1.File.Create(ctx, larkim.NewCreateFileReqBuilder().
+		Body(larkim.NewCreateFileReqBodyBuilder().
+			FileType(feishuFileType(f.Name())).
+			FileName(f.Name()).
+			File(r).
+			Build()).
+		Build())
+	if err != nil {
+		return fmt.Errorf("failed to upload feishu file: %w", err)
+	}
+	if !resp.Success() {
+		return fmt.Errorf("feishu file upload error: code=%d msg=%s", resp.Code, resp.Msg)
+	}
+	return c.createMessage(ctx, chatID, larkim.MsgTypeFile,
+		map[string]string{"file_key": *resp.Data.FileKey})
+}
+
+// feishuFileType maps a file name to the file_type of the upload API.
+func feishuFileType(name string) string {
+	switch ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(name), ".")); ext {
+	case "opus", "mp4", "pdf", "doc", "xls", "ppt":
+		return ext
+	case "docx":
+		return "doc"
+	case "xlsx":
+		return "xls"
+	case "pptx":
+		return "ppt"
+	default:
+		return "stream"
+	}
+}
+
 func (c *FeishuChannel) handleMessageReceive(_ context.Context, event *larkim.P2MessageReceiveV1) error {
 	if event == nil || event.Event == nil || event.Event.Message == nil {
 		return nil
@@ -166,12 +405,21 @@ func (c *FeishuChannel) handleMessageReceive(_ context.Context, event *larkim.P2
 	}
 
 	chatType := stringValue(message.ChatType)
+	c.chatTypes.Store(chatID, chatType)
 	if chatType == "p2p" {
 		metadata["peer_kind"] = "direct"
 		metadata["peer_id"] = senderID
 	} else {
 		metadata["peer_kind"] = "group"
 		metadata["peer_id"] = chatID
+
+		// Without the permission to read all group messages, Feishu only
+		// delivers messages that @ the bot, so any mention is taken as one.
+		var ok bool
+		content, _, ok = c.admitGroupMessage(senderID, chatID, content, len(message.Mentions) > 0, metadata)
+		if !ok {
+			return nil
+		}
 	}
 
 	logger.InfoCF("feishu", "Feishu message received", map[string]any{
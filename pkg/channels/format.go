@@ -0,0 +1,317 @@
+package channels
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+// Dialect is the markup a platform renders messages in. The agent writes
+// Markdown; RenderMarkdown converts it to the dialect of each channel.
+type Dialect string
+
+const (
+	DialectPlain              Dialect = "plain"
+	DialectMarkdown           Dialect = "markdown"
+	DialectTelegramHTML       Dialect = "telegram_html"
+	DialectTelegramMarkdownV2 Dialect = "telegram_markdownv2"
+	DialectSlack              Dialect = "slack"
+	DialectFeishu             Dialect = "feishu"
+	DialectWhatsApp           Dialect = "whatsapp"
+)
+
+// MessageFormat is how a platform displays messages: the dialect and the
+// longest message it accepts, in bytes. Counting bytes is conservative for
+// platforms that count characters. A MaxLen of 0 means no limit.
+type MessageFormat struct {
+	Dialect Dialect
+	MaxLen  int
+}
+
+// Split splits markdown into chunks that each fit in one message once
+// rendered. Chunks end at line breaks where possible, and a code block
+// split across chunks is closed and reopened so each chunk renders on its
+// own.
+func (f MessageFormat) Split(markdown string) []string {
+	if f.MaxLen <= 0 || len(markdown) <= f.MaxLen && len(RenderMarkdown(markdown, f.Dialect)) <= f.MaxLen {
+		return []string{markdown}
+	}
+	return f.split(markdown, f.MaxLen)
+}
+
+// minSplitLen stops splitting markup that grows too much when rendered to
+// ever fit, such as a line of nothing but characters that need escaping.
+const minSplitLen = 64
+
+func (f MessageFormat) split(markdown string, budget int) []string {
+	var chunks []string
+	for _, chunk := range utils.SplitMessage(markdown, budget) {
+		rendered := len(RenderMarkdown(chunk, f.Dialect))
+		if rendered <= f.MaxLen || len(chunk) <= minSplitLen {
+			chunks = append(chunks, chunk)
+			continue
+		}
+		// Rendering added escapes or tags; split this chunk again, smaller
+		// by the same proportion.
+		smaller := max(len(chunk)*f.MaxLen/rendered*9/10, minSplitLen)
+		chunks = append(chunks, f.split(chunk, smaller)...)
+	}
+	return chunks
+}
+
+// Render splits markdown like Split and renders each chunk.
+func (f MessageFormat) Render(markdown string) []string {
+	chunks := f.Split(markdown)
+	for i, chunk := range chunks {
+		chunks[i] = RenderMarkdown(chunk, f.Dialect)
+	}
+	return chunks
+}
+
+// dialectSyntax is how a dialect writes each Markdown construct. Text
+// outside code has already been escaped when these are applied, except
+// for code and link targets, which the functions escape themselves.
+type dialectSyntax struct {
+	escape func(text string) string
+	bold   [2]string
+	italic [2]string
+	strike [2]string
+	quote  string
+	link   func(text, url string) string
+	code   func(code string) string
+	block  func(lang, code string) string
+}
+
+var dialects = map[Dialect]dialectSyntax{
+	DialectPlain: {
+		escape: noEscape,
+		quote:  "> ",
+		link:   plainLink,
+		code:   noEscape,
+		block:  func(lang, code string) string { return strings.TrimRight(code, "\n") },
+	},
+	DialectMarkdown: {
+		escape: noEscape,
+		bold:   [2]string{"**", "**"},
+		italic: [2]string{"*", "*"},
+		strike: [2]string{"~~", "~~"},
+		quote:  "> ",
+		link:   func(text, url string) string { return "[" + text + "](" + url + ")" },
+		code:   func(code string) string { return "`" + code + "`" },
+		block:  fencedBlock(noEscape, true),
+	},
+	DialectTelegramHTML: {
+		escape: escapeHTML,
+		bold:   [2]string{"<b>", "</b>"},
+		italic: [2]string{"<i>", "</i>"},
+		strike: [2]string{"<s>", "</s>"},
+		link: func(text, url string) string {
+			return `<a href="` + strings.ReplaceAll(escapeHTML(url), `"`, "&quot;") + `">` + text + "</a>"
+		},
+		code: func(code string) string { return "<code>" + escapeHTML(code) + "</code>" },
+		block: func(lang, code string) string {
+			if lang != "" {
+				return `<pre><code class="language-` + lang + `">` + escapeHTML(code) + "</code></pre>"
+			}
+			return "<pre><code>" + escapeHTML(code) + "</code></pre>"
+		},
+	},
+	DialectTelegramMarkdownV2: {
+		escape: escapeTelegramMarkdownV2,
+		bold:   [2]string{"*", "*"},
+		italic: [2]string{"_", "_"},
+		strike: [2]string{"~", "~"},
+		quote:  ">",
+		link: func(text, url string) string {
+			return "[" + text + "](" + telegramURLEscaper.Replace(url) + ")"
+		},
+		code:  func(code string) string { return "`" + telegramCodeEscaper.Replace(code) + "`" },
+		block: fencedBlock(telegramCodeEscaper.Replace, true),
+	},
+	DialectSlack: {
+		escape: escapeSlack,
+		bold:   [2]string{"*", "*"},
+		italic: [2]string{"_", "_"},
+		strike: [2]string{"~", "~"},
+		quote:  "> ",
+		link: func(text, url string) string {
+			if text == escapeSlack(url) {
+				return "<" + url + ">"
+			}
+			return "<" + url + "|" + text + ">"
+		},
+		code:  func(code string) string { return "`" + escapeSlack(code) + "`" },
+		block: fencedBlock(escapeSlack, false),
+	},
+	DialectFeishu: {
+		escape: escapeFeishu,
+		bold:   [2]string{"**", "**"},
+		italic: [2]string{"*", "*"},
+		strike: [2]string{"~~", "~~"},
+		link:   func(text, url string) string { return "[" + text + "](" + url + ")" },
+		code:   func(code string) string { return "`" + code + "`" },
+		block:  fencedBlock(noEscape, true),
+	},
+	DialectWhatsApp: {
+		escape: noEscape,
+		bold:   [2]string{"*", "*"},
+		italic: [2]string{"_", "_"},
+		strike: [2]string{"~", "~"},
+		quote:  "> ",
+		link:   plainLink,
+		code:   func(code string) string { return "`" + code + "`" },
+		block:  fencedBlock(noEscape, false),
+	},
+}
+
+// Markers standing in for Markdown syntax while the text is escaped. Code
+// and links are replaced by numbered placeholders between \x00 bytes.
+const (
+	markBoldOpen    = "\x01"
+	markBoldClose   = "\x02"
+	markItalicOpen  = "\x03"
+	markItalicClose = "\x04"
+	markStrikeOpen  = "\x05"
+	markStrikeClose = "\x06"
+	markQuote       = "\x07"
+)
+
+var (
+	reCodeBlock   = regexp.MustCompile("```(?:([\\w+#-]*)[ \\t]*\\n)?([\\s\\S]*?)```")
+	reInlineCode  = regexp.MustCompile("`([^`\\n]+)`")
+	reLink        = regexp.MustCompile(`\[([^\]\n]+)\]\(([^)\s]+)\)`)
+	reHeading     = regexp.MustCompile(`(?m)^#{1,6}[ \t]+(.+?)[ \t#]*$`)
+	reQuote       = regexp.MustCompile(`(?m)^>[ \t]?`)
+	reBullet      = regexp.MustCompile(`(?m)^([ \t]*)[-*+][ \t]+`)
+	reBoldStars   = regexp.MustCompile(`\*\*(.+?)\*\*`)
+	reBoldUnders  = regexp.MustCompile(`__(.+?)__`)
+	reItalicStar  = regexp.MustCompile(`\*([^*\s](?:[^*\n]*[^*\s])?)\*`)
+	reItalicUnder = regexp.MustCompile(`(^|[^\p{L}\p{N}_])_([^_\s](?:[^_\n]*[^_\s])?)_([^\p{L}\p{N}_]|$)`)
+	reStrike      = regexp.MustCompile(`~~(.+?)~~`)
+	rePlaceholder = regexp.MustCompile("\x00(CB|IC|L)(\\d+)\x00")
+)
+
+// RenderMarkdown converts the Markdown the agent writes to d. Headings
+// become bold lines and bullets become "•"; constructs a dialect has no
+// syntax for are reduced to their text.
+func RenderMarkdown(markdown string, d Dialect) string {
+	if markdown == "" {
+		return ""
+	}
+	syntax, ok := dialects[d]
+	if !ok {
+		syntax = dialects[DialectPlain]
+	}
+
+	type codeBlock struct{ lang, code string }
+	type link struct{ text, url string }
+	var blocks []codeBlock
+	var codes []string
+	var links []link
+
+	text := reCodeBlock.ReplaceAllStringFunc(markdown, func(m string) string {
+		match := reCodeBlock.FindStringSubmatch(m)
+		blocks = append(blocks, codeBlock{lang: match[1], code: match[2]})
+		return fmt.Sprintf("\x00CB%d\x00", len(blocks)-1)
+	})
+	text = reInlineCode.ReplaceAllStringFunc(text, func(m string) string {
+		codes = append(codes, m[1:len(m)-1])
+		return fmt.Sprintf("\x00IC%d\x00", len(codes)-1)
+	})
+	text = reLink.ReplaceAllStringFunc(text, func(m string) string {
+		match := reLink.FindStringSubmatch(m)
+		links = append(links, link{text: match[1], url: match[2]})
+		return fmt.Sprintf("\x00L%d\x00", len(links)-1)
+	})
+
+	text = reHeading.ReplaceAllString(text, markBoldOpen+"$1"+markBoldClose)
+	text = reQuote.ReplaceAllString(text, markQuote)
+	text = reBullet.ReplaceAllString(text, "$1• ")
+	text = reBoldStars.ReplaceAllString(text, markBoldOpen+"$1"+markBoldClose)
+	text = reBoldUnders.ReplaceAllString(text, markBoldOpen+"$1"+markBoldClose)
+	text = reItalicStar.ReplaceAllString(text, markItalicOpen+"$1"+markItalicClose)
+	text = reItalicUnder.ReplaceAllString(text, "$1"+markItalicOpen+"$2"+markItalicClose+"$3")
+	text = reStrike.ReplaceAllString(text, markStrikeOpen+"$1"+markStrikeClose)
+
+	text = syntax.escape(text)
+	text = strings.NewReplacer(
+		markBoldOpen, syntax.bold[0], markBoldClose, syntax.bold[1],
+		markItalicOpen, syntax.italic[0], markItalicClose, syntax.italic[1],
+		markStrikeOpen, syntax.strike[0], markStrikeClose, syntax.strike[1],
+		markQuote, syntax.quote,
+	).Replace(text)
+
+	return rePlaceholder.ReplaceAllStringFunc(text, func(m string) string {
+		match := rePlaceholder.FindStringSubmatch(m)
+		i, _ := strconv.Atoi(match[2])
+		switch match[1] {
+		case "CB":
+			return syntax.block(blocks[i].lang, blocks[i].code)
+		case "IC":
+			return syntax.code(codes[i])
+		default:
+			return syntax.link(syntax.escape(links[i].text), links[i].url)
+		}
+	})
+}
+
+func noEscape(text string) string {
+	return text
+}
+
+func plainLink(text, url string) string {
+	if text == url {
+		return url
+	}
+	return text + " (" + url + ")"
+}
+
+// fencedBlock returns a block renderer writing ``` fences, with the
+// language after the opening fence if the dialect supports it.
+func fencedBlock(escape func(string) string, withLang bool) func(lang, code string) string {
+	return func(lang, code string) string {
+		if !withLang {
+			lang = ""
+		}
+		if !strings.HasSuffix(code, "\n") {
+			code += "\n"
+		}
+		return "```" + lang + "\n" + escape(code) + "```"
+	}
+}
+
+func escapeHTML(text string) string {
+	text = strings.ReplaceAll(text, "&", "&amp;")
+	text = strings.ReplaceAll(text, "<", "&lt;")
+	text = strings.ReplaceAll(text, ">", "&gt;")
+	return text
+}
+
+// escapeSlack escapes the characters Slack reserves for links and
+// mentions.
+func escapeSlack(text string) string {
+	return escapeHTML(text)
+}
+
+// escapeFeishu escapes angle brackets, which Feishu card Markdown reads as
+// tags such as <at> and <font>.
+func escapeFeishu(text string) string {
+	return strings.NewReplacer("<", "&#60;", ">", "&#62;").Replace(text)
+}
+
+var (
+	telegramEscaper = strings.NewReplacer(
+		`\`, `\\`, "_", `\_`, "*", `\*`, "[", `\[`, "]", `\]`, "(", `\(`, ")", `\)`, "~", `\~`, "`", "\\`",
+		">", `\>`, "#", `\#`, "+", `\+`, "-", `\-`, "=", `\=`, "|", `\|`, "{", `\{`, "}", `\}`, ".", `\.`, "!", `\!`,
+	)
+	telegramCodeEscaper = strings.NewReplacer(`\`, `\\`, "`", "\\`")
+	telegramURLEscaper  = strings.NewReplacer(`\`, `\\`, ")", `\)`)
+)
+
+// escapeTelegramMarkdownV2 escapes every character MarkdownV2 reserves.
+func escapeTelegramMarkdownV2(text string) string {
+	return telegramEscaper.Replace(text)
+}
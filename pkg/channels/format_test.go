@@ -0,0 +1,71 @@
+package channels
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderMarkdown(t *testing.T) {
+	input := "## Result\n**done** in *2s*, see [docs](https://x.io/a_b) or `run(x)`.\n- one_two\n> note\n~~old~~ <tag> & 1.5!\n```go\nif a < b {}\n```"
+
+	tests := []struct {
+		dialect Dialect
+		want    string
+	}{
+		{DialectPlain,
+			"Result\ndone in 2s, see docs (https://x.io/a_b) or run(x).\n• one_two\n> note\nold <tag> & 1.5!\nif a < b {}"},
+		{DialectMarkdown,
+			"**Result**\n**done** in *2s*, see [docs](https://x.io/a_b) or `run(x)`.\n• one_two\n> note\n~~old~~ <tag> & 1.5!\n```go\nif a < b {}\n```"},
+		{DialectTelegramHTML,
+			"<b>Result</b>\n<b>done</b> in <i>2s</i>, see <a href=\"https://x.io/a_b\">docs</a> or <code>run(x)</code>.\n• one_two\nnote\n<s>old</s> &lt;tag&gt; &amp; 1.5!\n<pre><code class=\"language-go\">if a &lt; b {}\n</code></pre>"},
+		{DialectTelegramMarkdownV2,
+			"*Result*\n*done* in _2s_, see [docs](https://x.io/a_b) or `run(x)`\\.\n• one\\_two\n>note\n~old~ <tag\\> & 1\\.5\\!\n```go\nif a < b {}\n```"},
+		{DialectSlack,
+			"*Result*\n*done* in _2s_, see <https://x.io/a_b|docs> or `run(x)`.\n• one_two\n> note\n~old~ &lt;tag&gt; &amp; 1.5!\n```\nif a &lt; b {}\n```"},
+		{DialectFeishu,
+			"**Result**\n**done** in *2s*, see [docs](https://x.io/a_b) or `run(x)`.\n• one_two\nnote\n~~old~~ &#60;tag&#62; & 1.5!\n```go\nif a < b {}\n```"},
+		{DialectWhatsApp,
+			"*Result*\n*done* in _2s_, see docs (https://x.io/a_b) or `run(x)`.\n• one_two\n> note\n~old~ <tag> & 1.5!\n```\nif a < b {}\n```"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.dialect), func(t *testing.T) {
+			if got := RenderMarkdown(input, tt.dialect); got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderMarkdown_Identifiers(t *testing.T) {
+	// Underscores and asterisks inside words and expressions are not
+	// emphasis.
+	got := RenderMarkdown("set max_retry_count to 2 * 3 * 4", DialectTelegramHTML)
+	if got != "set max_retry_count to 2 * 3 * 4" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestMessageFormat_Split(t *testing.T) {
+	code := "```go\n" + strings.Repeat("x := a < b && c > d\n", 60) + "```"
+	f := MessageFormat{Dialect: DialectTelegramHTML, MaxLen: 500}
+
+	chunks := f.Render("Intro\n" + code)
+	if len(chunks) < 3 {
+		t.Fatalf("got %d chunks, want the code block split", len(chunks))
+	}
+	for i, chunk := range chunks {
+		if len(chunk) > f.MaxLen {
+			t.Errorf("chunk %d is %d bytes, over the limit", i, len(chunk))
+		}
+		if strings.Count(chunk, "<pre>") != strings.Count(chunk, "</pre>") {
+			t.Errorf("chunk %d has an unclosed code block:\n%s", i, chunk)
+		}
+	}
+
+	if got := (MessageFormat{Dialect: DialectPlain, MaxLen: 100}).Split("short"); len(got) != 1 || got[0] != "short" {
+		t.Errorf("Split(short) = %q", got)
+	}
+	if got := (MessageFormat{Dialect: DialectPlain}).Split(code); len(got) != 1 {
+		t.Errorf("no limit: got %d chunks", len(got))
+	}
+}
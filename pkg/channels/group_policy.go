@@ -0,0 +1,165 @@
+package channels
+
+import (
+	"maps"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// groupGate applies a channel's group policy and tracks replies per group
+// for cooldowns and rate limits.
+type groupGate struct {
+	policy   config.GroupPolicyConfig
+	defaults config.GroupPolicy // used where the policy leaves Trigger or Prefixes unset
+	mu       sync.Mutex
+	last     map[string]time.Time
+	recent   map[string][]time.Time // admitted within the last minute
+}
+
+// setGroupPolicy sets the group policy, with defaults that keep a
+// channel's older options (mention_only, trigger prefixes) working.
+func (c *BaseChannel) setGroupPolicy(policy config.GroupPolicyConfig, defaults config.GroupPolicy) {
+	c.groups = &groupGate{
+		policy:   policy,
+		defaults: defaults,
+		last:     make(map[string]time.Time),
+		recent:   make(map[string][]time.Time),
+	}
+}
+
+// admitGroupMessage applies the group policy to a message in a group chat.
+// Channels call it before acknowledging or processing the message, with
+// mentioned reporting whether it addresses the bot. It returns the content
+// with any trigger prefix removed, whether the reply belongs in a thread,
+// and whether to pass the message on. Messages not passed on are published
+// as passive context when the policy asks for it.
+func (c *BaseChannel) admitGroupMessage(
+	senderID, chatID, content string,
+	mentioned bool,
+	metadata map[string]string,
+) (string, bool, bool) {
+	g := c.groups
+	if g == nil {
+		return content, false, true
+	}
+
+	group := metadata["parent_peer_id"]
+	if group == "" {
+		group = metadata["peer_id"]
+	}
+	if group == "" {
+		group = chatID
+	}
+	policy := g.policy.ForChat(chatID, group)
+	if policy.Trigger == "" {
+		policy.Trigger = g.defaults.Trigger
+	}
+	if policy.Prefixes == nil {
+		policy.Prefixes = g.defaults.Prefixes
+	}
+
+	stripped, triggered := groupTriggered(policy, content, mentioned)
+	reason := "not triggered"
+	if triggered {
+		if g.allowReply(group, policy, time.Now()) {
+			return stripped, policy.ReplyInThread != nil && *policy.ReplyInThread, true
+		}
+		reason = "rate limited"
+	}
+
+	logger.DebugCF(c.name, "Group message not answered", map[string]any{
+		"chat_id": chatID,
+		"sender":  senderID,
+		"reason":  reason,
+	})
+	if policy.Passive != nil && *policy.Passive && c.IsAllowed(senderID) {
+		passive := maps.Clone(metadata)
+		passive["passive"] = "true"
+		c.HandleMessage(senderID, chatID, content, nil, passive)
+	}
+	return content, false, false
+}
+
+// groupTriggered reports whether policy's trigger matches content, and
+// returns content without a matched prefix.
+func groupTriggered(policy config.GroupPolicy, content string, mentioned bool) (string, bool) {
+	switch policy.Trigger {
+	case "", config.GroupTriggerAlways:
+		return content, true
+	case config.GroupTriggerPrefix:
+		return cutGroupPrefix(content, policy.Prefixes)
+	case config.GroupTriggerKeyword:
+		return content, containsKeyword(content, policy.Keywords)
+	}
+
+	// Mention: also accept the prefixes and keywords, if any.
+	if mentioned {
+		return content, true
+	}
+	if rest, ok := cutGroupPrefix(content, policy.Prefixes); ok {
+		return rest, true
+	}
+	return content, containsKeyword(content, policy.Keywords)
+}
+
+func cutGroupPrefix(content string, prefixes []string) (string, bool) {
+	for _, prefix := range prefixes {
+		if prefix == "" {
+			continue
+		}
+		if rest, ok := strings.CutPrefix(content, prefix); ok {
+			return strings.TrimSpace(rest), true
+		}
+	}
+	return content, false
+}
+
+func containsKeyword(content string, keywords []string) bool {
+	lower := strings.ToLower(content)
+	for _, keyword := range keywords {
+		if keyword != "" && strings.Contains(lower, strings.ToLower(keyword)) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowReply records a reply in group unless the policy's cooldown or
+// per-minute limit forbids it.
+func (g *groupGate) allowReply(group string, policy config.GroupPolicy, now time.Time) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	cooldown := time.Duration(policy.CooldownSeconds) * time.Second
+	if last, ok := g.last[group]; ok && cooldown > 0 && now.Sub(last) < cooldown {
+		return false
+	}
+
+	recent := g.recent[group][:0]
+	for _, t := range g.recent[group] {
+		if now.Sub(t) < time.Minute {
+			recent = append(recent, t)
+		}
+	}
+	if policy.MaxPerMinute > 0 && len(recent) >= policy.MaxPerMinute {
+		g.recent[group] = recent
+		return false
+	}
+
+	g.last[group] = now
+	g.recent[group] = append(recent, now)
+	return true
+}
+
+// mentionOnlyDefaults returns the group policy defaults matching a
+// channel's mention_only option.
+func mentionOnlyDefaults(mentionOnly bool) config.GroupPolicy {
+	if mentionOnly {
+		return config.GroupPolicy{Trigger: config.GroupTriggerMention}
+	}
+	return config.GroupPolicy{}
+}
@@ -0,0 +1,120 @@
+package channels
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestGroupTriggered(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    config.GroupPolicy
+		content   string
+		mentioned bool
+		want      bool
+		wantText  string
+	}{
+		{"always", config.GroupPolicy{}, "hello", false, true, "hello"},
+		{"mention", config.GroupPolicy{Trigger: "mention"}, "hello", true, true, "hello"},
+		{"mention missing", config.GroupPolicy{Trigger: "mention"}, "hello", false, false, "hello"},
+		{
+			"mention accepts prefix",
+			config.GroupPolicy{Trigger: "mention", Prefixes: []string{"/ask"}},
+			"/ask what time is it", false, true, "what time is it",
+		},
+		{"prefix", config.GroupPolicy{Trigger: "prefix", Prefixes: []string{"!bot"}}, "!bot hi", false, true, "hi"},
+		{
+			"prefix ignores mention",
+			config.GroupPolicy{Trigger: "prefix", Prefixes: []string{"!bot"}},
+			"hi", true, false, "hi",
+		},
+		{
+			"keyword",
+			config.GroupPolicy{Trigger: "keyword", Keywords: []string{"deploy"}},
+			"Who can DEPLOY today?", false, true, "Who can DEPLOY today?",
+		},
+		{
+			"keyword missing",
+			config.GroupPolicy{Trigger: "keyword", Keywords: []string{"deploy"}},
+			"lunch?", false, false, "lunch?",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, ok := groupTriggered(tt.policy, tt.content, tt.mentioned)
+			if ok != tt.want || text != tt.wantText {
+				t.Errorf("groupTriggered() = %q, %v, want %q, %v", text, ok, tt.wantText, tt.want)
+			}
+		})
+	}
+}
+
+func TestGroupGateAllowReply(t *testing.T) {
+	c := NewBaseChannel("test", nil, nil, nil)
+	c.setGroupPolicy(config.GroupPolicyConfig{}, config.GroupPolicy{})
+	now := time.Now()
+
+	cooldown := config.GroupPolicy{CooldownSeconds: 30}
+	if !c.groups.allowReply("g1", cooldown, now) {
+		t.Fatal("first reply refused")
+	}
+	if c.groups.allowReply("g1", cooldown, now.Add(10*time.Second)) {
+		t.Error("reply allowed within the cooldown")
+	}
+	if !c.groups.allowReply("g2", cooldown, now.Add(10*time.Second)) {
+		t.Error("cooldown applied to another group")
+	}
+	if !c.groups.allowReply("g1", cooldown, now.Add(31*time.Second)) {
+		t.Error("reply refused after the cooldown")
+	}
+
+	limit := config.GroupPolicy{MaxPerMinute: 2}
+	for i, want := range []bool{true, true, false} {
+		if got := c.groups.allowReply("g3", limit, now.Add(time.Duration(i)*time.Second)); got != want {
+			t.Errorf("reply %d allowed = %v, want %v", i+1, got, want)
+		}
+	}
+	if !c.groups.allowReply("g3", limit, now.Add(61*time.Second)) {
+		t.Error("reply refused after a minute")
+	}
+}
+
+func TestAdmitGroupMessage(t *testing.T) {
+	mb := bus.NewMessageBus()
+	c := NewBaseChannel("test", nil, mb, nil)
+	passive := true
+	thread := true
+	c.setGroupPolicy(config.GroupPolicyConfig{
+		GroupPolicy: config.GroupPolicy{Passive: &passive},
+		Chats: map[string]config.GroupPolicy{
+			"g2": {Trigger: config.GroupTriggerAlways, ReplyInThread: &thread},
+		},
+	}, mentionOnlyDefaults(true))
+	metadata := map[string]string{"peer_kind": "group", "peer_id": "g1"}
+
+	if _, _, ok := c.admitGroupMessage("alice", "g1", "hello", false, metadata); ok {
+		t.Fatal("message without mention admitted")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	msg, ok := mb.ConsumeInbound(ctx)
+	if !ok {
+		t.Fatal("no passive message published")
+	}
+	if msg.Content != "hello" || msg.Metadata["passive"] != "true" || metadata["passive"] != "" {
+		t.Errorf("passive message = %+v", msg)
+	}
+
+	if _, inThread, ok := c.admitGroupMessage("alice", "g1", "hello", true, metadata); !ok || inThread {
+		t.Errorf("mention: ok = %v, thread = %v", ok, inThread)
+	}
+	// The override for g2 answers everything, in threads.
+	g2 := map[string]string{"peer_kind": "group", "peer_id": "g2"}
+	if _, inThread, ok := c.admitGroupMessage("alice", "g2", "hello", false, g2); !ok || !inThread {
+		t.Errorf("override: ok = %v, thread = %v", ok, inThread)
+	}
+}
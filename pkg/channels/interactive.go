@@ -0,0 +1,344 @@
+package channels
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// InteractiveChannel is implemented by channels that render interactive
+// elements with their platform's buttons and menus. For other channels the
+// manager lists the options in the message text.
+type InteractiveChannel interface {
+	SupportsInteractive() bool
+}
+
+const (
+	// interactionTTL is how long a sent option can still be picked.
+	interactionTTL = 7 * 24 * time.Hour
+	// maxInteractions bounds the registry; the oldest entries are dropped
+	// beyond it.
+	maxInteractions = 10000
+)
+
+// interaction is an option of a sent interactive element.
+type interaction struct {
+	token     string
+	chatID    string
+	kind      bus.InteractiveKind
+	elementID string
+	label     string
+	value     string
+	expires   time.Time
+}
+
+func (it *interaction) record() interactionRecord {
+	return interactionRecord{
+		Token:     it.token,
+		ChatID:    it.chatID,
+		Kind:      it.kind,
+		ElementID: it.elementID,
+		Label:     it.label,
+		Value:     it.value,
+		Expires:   it.expires,
+	}
+}
+
+// interactionRecord is a line of the interaction log: an option that was
+// sent or, with Dropped set, the removal of the option with that token.
+type interactionRecord struct {
+	Token     string              `json:"token"`
+	ChatID    string              `json:"chat_id,omitempty"`
+	Kind      bus.InteractiveKind `json:"kind,omitempty"`
+	ElementID string              `json:"element_id,omitempty"`
+	Label     string              `json:"label,omitempty"`
+	Value     string              `json:"value,omitempty"`
+	Expires   time.Time           `json:"expires,omitempty"`
+	Dropped   bool                `json:"dropped,omitempty"`
+}
+
+// interactions remembers the options of sent interactive elements by a
+// short random token. Channels put the token in the platform's callback
+// data, which is often too small for the option's value, and look the
+// option up again when it is picked. With a log file (see persist) the
+// options survive config reloads and restarts.
+type interactions struct {
+	mu      sync.Mutex
+	byToken map[string]*interaction
+	order   []string // tokens, oldest first
+	path    string   // log file, empty to keep the options in memory only
+	nowFunc func() time.Time
+}
+
+func newInteractions() *interactions {
+	return &interactions{byToken: make(map[string]*interaction), nowFunc: time.Now}
+}
+
+// register remembers opt of el, sent to chatID, and returns its token.
+func (r *interactions) register(chatID string, el bus.InteractiveElement, opt bus.InteractiveOption) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.nowFunc()
+	for len(r.order) > 0 {
+		oldest, ok := r.byToken[r.order[0]]
+		if ok && len(r.order) < maxInteractions && now.Before(oldest.expires) {
+			break
+		}
+		delete(r.byToken, r.order[0])
+		r.order = r.order[1:]
+	}
+
+	token := newInteractionToken()
+	it := &interaction{
+		token:     token,
+		chatID:    chatID,
+		kind:      el.Kind,
+		elementID: el.ID,
+		label:     opt.Label,
+		value:     opt.OptionValue(),
+		expires:   now.Add(interactionTTL),
+	}
+	r.byToken[token] = it
+	r.order = append(r.order, token)
+	r.log(it.record())
+	return token
+}
+
+// lookup returns the option registered under token.
+func (r *interactions) lookup(token string) (interaction, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	it, ok := r.byToken[token]
+	if !ok || !r.nowFunc().Before(it.expires) {
+		return interaction{}, false
+	}
+	return *it, true
+}
+
+// matchQuickReply returns the quick reply sent to chatID that is labelled
+// text, for platforms where picking a quick reply sends its label as an
+// ordinary message. A match uses up the chat's quick replies.
+func (r *interactions) matchQuickReply(chatID, text string) (interaction, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var match *interaction
+	for _, it := range r.byToken {
+		if it.kind == bus.InteractiveQuickReplies && it.chatID == chatID && it.label == text {
+			match = it
+			break
+		}
+	}
+	if match == nil || !r.nowFunc().Before(match.expires) {
+		return interaction{}, false
+	}
+	var dropped []interactionRecord
+	for token, it := range r.byToken {
+		if it.kind == bus.InteractiveQuickReplies && it.chatID == chatID {
+			delete(r.byToken, token)
+			dropped = append(dropped, interactionRecord{Token: token, Dropped: true})
+		}
+	}
+	r.log(dropped...)
+	return *match, true
+}
+
+// persist loads the options logged at path by earlier instances of the
+// channel, and logs the options sent from now on there. The log is rewritten
+// without expired and dropped options first.
+func (r *interactions) persist(path string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	now := r.nowFunc()
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(data)+1)
+	for scanner.Scan() {
+		var rec interactionRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil || rec.Token == "" {
+			continue
+		}
+		if rec.Dropped || !now.Before(rec.Expires) {
+			delete(r.byToken, rec.Token)
+			continue
+		}
+		if _, ok := r.byToken[rec.Token]; !ok {
+			r.order = append(r.order, rec.Token)
+		}
+		r.byToken[rec.Token] = &interaction{
+			token:     rec.Token,
+			chatID:    rec.ChatID,
+			kind:      rec.Kind,
+			elementID: rec.ElementID,
+			label:     rec.Label,
+			value:     rec.Value,
+			expires:   rec.Expires,
+		}
+	}
+
+	var kept []string
+	for _, token := range r.order {
+		if _, ok := r.byToken[token]; ok {
+			kept = append(kept, token)
+		}
+	}
+	if len(kept) > maxInteractions {
+		for _, token := range kept[:len(kept)-maxInteractions] {
+			delete(r.byToken, token)
+		}
+		kept = kept[len(kept)-maxInteractions:]
+	}
+	r.order = kept
+
+	var buf bytes.Buffer
+	for _, token := range kept {
+		line, _ := json.Marshal(r.byToken[token].record())
+		buf.Write(append(line, '\n'))
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	r.path = path
+	return nil
+}
+
+// log appends recs to the log file, if there is one. The caller must hold
+// r.mu.
+func (r *interactions) log(recs ...interactionRecord) {
+	if r.path == "" || len(recs) == 0 {
+		return
+	}
+	var buf bytes.Buffer
+	for _, rec := range recs {
+		line, _ := json.Marshal(rec)
+		buf.Write(append(line, '\n'))
+	}
+	f, err := os.OpenFile(r.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err == nil {
+		_, err = f.Write(buf.Bytes())
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		logger.WarnCF("channels", "Failed to log interactive options", map[string]any{
+			"path":  r.path,
+			"error": err.Error(),
+		})
+	}
+}
+
+func newInteractionToken() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// interactionMetadata adds the metadata describing a picked option.
+func interactionMetadata(it interaction, metadata map[string]string) map[string]string {
+	if metadata == nil {
+		metadata = make(map[string]string)
+	}
+	metadata["interaction"] = string(it.kind)
+	metadata["action_id"] = it.elementID
+	metadata["action_value"] = it.value
+	metadata["action_label"] = it.label
+	return metadata
+}
+
+// interactiveAsText replaces the interactive elements of msg with a
+// numbered list of their options, for channels without buttons.
+func interactiveAsText(msg bus.OutboundMessage) bus.OutboundMessage {
+	for _, el := range msg.Interactive {
+		var lines []string
+		if el.Placeholder != "" {
+			lines = append(lines, el.Placeholder)
+		}
+		for i, opt := range el.Options {
+			line := fmt.Sprintf("%d. %s", i+1, opt.Label)
+			if opt.URL != "" {
+				line += ": " + opt.URL
+			}
+			lines = append(lines, line)
+		}
+		msg.Content = appendContent(msg.Content, strings.Join(lines, "\n"))
+	}
+	msg.Interactive = nil
+	return msg
+}
+
+// interactiveContent returns the text to send with interactive elements,
+// for platforms that need a message to attach them to.
+func interactiveContent(content string, elements []bus.InteractiveElement) string {
+	if content != "" || len(elements) == 0 {
+		return content
+	}
+	if p := elements[0].Placeholder; p != "" {
+		return p
+	}
+	return "Choose an option:"
+}
+
+// linkOptions returns the options of the elements that open a URL, and the
+// elements with those options removed, for platforms that cannot put links
+// in a given kind of element.
+func linkOptions(
+	elements []bus.InteractiveElement,
+	kinds ...bus.InteractiveKind,
+) ([]bus.InteractiveOption, []bus.InteractiveElement) {
+	var links []bus.InteractiveOption
+	var rest []bus.InteractiveElement
+	for _, el := range elements {
+		if !containsKind(kinds, el.Kind) {
+			rest = append(rest, el)
+			continue
+		}
+		kept := el
+		kept.Options = nil
+		for _, opt := range el.Options {
+			if opt.URL != "" {
+				links = append(links, opt)
+			} else {
+				kept.Options = append(kept.Options, opt)
+			}
+		}
+		if len(kept.Options) > 0 {
+			rest = append(rest, kept)
+		}
+	}
+	return links, rest
+}
+
+func containsKind(kinds []bus.InteractiveKind, kind bus.InteractiveKind) bool {
+	for _, k := range kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
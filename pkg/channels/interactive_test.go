@@ -0,0 +1,173 @@
+package channels
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+)
+
+func TestInteractions(t *testing.T) {
+	r := newInteractions()
+	now := time.Now()
+	r.nowFunc = func() time.Time { return now }
+
+	el := bus.InteractiveElement{Kind: bus.InteractiveButtons, ID: "deploy"}
+	token := r.register("chat1", el, bus.InteractiveOption{Label: "Yes", Value: "deploy it"})
+
+	it, ok := r.lookup(token)
+	if !ok || it.chatID != "chat1" || it.value != "deploy it" || it.elementID != "deploy" {
+		t.Fatalf("lookup = %+v, %v", it, ok)
+	}
+	if _, ok := r.lookup("unknown"); ok {
+		t.Error("unknown token found")
+	}
+
+	now = now.Add(interactionTTL)
+	if _, ok := r.lookup(token); ok {
+		t.Error("expired token found")
+	}
+	r.register("chat1", el, bus.InteractiveOption{Label: "No"})
+	if len(r.byToken) != 1 {
+		t.Errorf("registry has %d entries, want the expired one pruned", len(r.byToken))
+	}
+}
+
+func TestInteractionsPersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "interactions", "telegram.jsonl")
+	r := newInteractions()
+	if err := r.persist(path); err != nil {
+		t.Fatalf("persist: %v", err)
+	}
+	token := r.register("chat1", bus.InteractiveElement{Kind: bus.InteractiveButtons, ID: "deploy"},
+		bus.InteractiveOption{Label: "Yes", Value: "deploy it"})
+	quick := bus.InteractiveElement{Kind: bus.InteractiveQuickReplies}
+	r.register("chat1", quick, bus.InteractiveOption{Label: "Later"})
+	r.register("chat1", quick, bus.InteractiveOption{Label: "Now"})
+	r.matchQuickReply("chat1", "Now")
+
+	// A new instance, as after a reload or restart, knows the button but
+	// not the quick replies that were used up.
+	reloaded := newInteractions()
+	if err := reloaded.persist(path); err != nil {
+		t.Fatalf("persist: %v", err)
+	}
+	if it, ok := reloaded.lookup(token); !ok || it.value != "deploy it" || it.elementID != "deploy" {
+		t.Errorf("lookup after reload = %+v, %v", it, ok)
+	}
+	if _, ok := reloaded.matchQuickReply("chat1", "Later"); ok {
+		t.Error("used-up quick reply matched after reload")
+	}
+
+	expired := newInteractions()
+	expired.nowFunc = func() time.Time { return time.Now().Add(interactionTTL) }
+	if err := expired.persist(path); err != nil {
+		t.Fatalf("persist: %v", err)
+	}
+	if data, _ := os.ReadFile(path); len(data) != 0 || len(expired.byToken) != 0 {
+		t.Errorf("expired options kept: %s", data)
+	}
+}
+
+func TestInteractionsMatchQuickReply(t *testing.T) {
+	r := newInteractions()
+	el := bus.InteractiveElement{Kind: bus.InteractiveQuickReplies}
+	r.register("chat1", el, bus.InteractiveOption{Label: "Later", Value: "remind me tomorrow"})
+	r.register("chat1", el, bus.InteractiveOption{Label: "Now"})
+	r.register("chat1", bus.InteractiveElement{Kind: bus.InteractiveButtons}, bus.InteractiveOption{Label: "Skip"})
+
+	if _, ok := r.matchQuickReply("chat2", "Later"); ok {
+		t.Error("matched a quick reply sent to another chat")
+	}
+	if _, ok := r.matchQuickReply("chat1", "Skip"); ok {
+		t.Error("matched a button as a quick reply")
+	}
+	it, ok := r.matchQuickReply("chat1", "Later")
+	if !ok || it.value != "remind me tomorrow" {
+		t.Fatalf("match = %+v, %v", it, ok)
+	}
+	if _, ok := r.matchQuickReply("chat1", "Now"); ok {
+		t.Error("quick replies still match after one was picked")
+	}
+}
+
+func TestBaseChannelHandleInteraction(t *testing.T) {
+	mb := bus.NewMessageBus()
+	c := NewBaseChannel("test", nil, mb, nil)
+	token := c.interactions.register("chat1",
+		bus.InteractiveElement{Kind: bus.InteractiveSelect, ID: "env"},
+		bus.InteractiveOption{Label: "Production", Value: "prod"})
+
+	if c.HandleInteraction("user1", "unknown", nil) {
+		t.Error("handled an unknown token")
+	}
+	if !c.HandleInteraction("user1", token, map[string]string{"peer_kind": "direct"}) {
+		t.Fatal("token not handled")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	msg, ok := mb.ConsumeInbound(ctx)
+	if !ok {
+		t.Fatal("no inbound message")
+	}
+	if msg.ChatID != "chat1" || msg.Content != "prod" {
+		t.Errorf("inbound = %+v", msg)
+	}
+	md := msg.Metadata
+	if md["interaction"] != "select" || md["action_id"] != "env" || md["action_label"] != "Production" ||
+		md["peer_kind"] != "direct" {
+		t.Errorf("metadata = %v", md)
+	}
+}
+
+func TestInteractiveAsText(t *testing.T) {
+	msg := interactiveAsText(bus.OutboundMessage{
+		Content: "Deploy now?",
+		Interactive: []bus.InteractiveElement{{
+			Kind:    bus.InteractiveButtons,
+			Options: []bus.InteractiveOption{{Label: "Yes"}, {Label: "Docs", URL: "https://example.com"}},
+		}},
+	})
+	if msg.Interactive != nil {
+		t.Error("expected interactive elements to be dropped")
+	}
+	if want := "Deploy now?\n1. Yes\n2. Docs: https://example.com"; msg.Content != want {
+		t.Errorf("content = %q, want %q", msg.Content, want)
+	}
+}
+
+func TestDiscordComponents(t *testing.T) {
+	c := &DiscordChannel{BaseChannel: NewBaseChannel("discord", nil, bus.NewMessageBus(), nil)}
+	rows := c.components("chat1", []bus.InteractiveElement{
+		{Kind: bus.InteractiveButtons, Options: []bus.InteractiveOption{
+			{Label: "A", Style: "primary"}, {Label: "B"}, {Label: "C"}, {Label: "D"}, {Label: "E"},
+			{Label: "Docs", URL: "https://example.com"},
+		}},
+		{Kind: bus.InteractiveSelect, Options: []bus.InteractiveOption{{Label: "dev"}, {Label: "prod"}}},
+	})
+
+	if len(rows) != 3 {
+		t.Fatalf("got %d rows, want 2 button rows and a select row", len(rows))
+	}
+	first := rows[0].(discordgo.ActionsRow).Components
+	if len(first) != 5 || first[0].(discordgo.Button).Style != discordgo.PrimaryButton {
+		t.Errorf("first row = %+v", first)
+	}
+	link := rows[1].(discordgo.ActionsRow).Components[0].(discordgo.Button)
+	if link.Style != discordgo.LinkButton || link.URL != "https://example.com" || link.CustomID != "" {
+		t.Errorf("link button = %+v", link)
+	}
+	menu := rows[2].(discordgo.ActionsRow).Components[0].(discordgo.SelectMenu)
+	if len(menu.Options) != 2 {
+		t.Errorf("select = %+v", menu)
+	}
+	if _, ok := c.interactions.lookup(first[1].(discordgo.Button).CustomID); !ok {
+		t.Error("button token not registered")
+	}
+}
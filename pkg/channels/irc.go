@@ -0,0 +1,489 @@
+package channels
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+const (
+	ircReconnectDelay = 10 * time.Second
+	// ircMaxText is the longest message text sent in one line, leaving
+	// room within IRC's 512-byte line for the command, target and the
+	// prefix the server adds when relaying it.
+	ircMaxText = 400
+	// Flood control: each line costs ircFloodPenalty, and lines wait once
+	// the cost runs more than ircFloodBurst ahead of the clock. This is the
+	// scheme most servers' flood protection expects.
+	ircFloodPenalty = 2 * time.Second
+	ircFloodBurst   = 8 * time.Second
+)
+
+// ircFormat is plain text; IRC lines are split further by ircSplitText.
+var ircFormat = MessageFormat{Dialect: DialectPlain}
+
+// IRCChannel connects to an IRC server as a client. Private messages use
+// the sender's nick as chat ID, and channel messages the channel name.
+type IRCChannel struct {
+	*BaseChannel
+	config    config.IRCConfig
+	ctx       context.Context
+	cancel    context.CancelFunc
+	mu        sync.Mutex // guards conn, nick and writes
+	conn      net.Conn
+	nick      string
+	floodMu   sync.Mutex
+	floodTime time.Time
+}
+
+// ircMessage is a parsed IRC line.
+type ircMessage struct {
+	source  string // the prefix, nick!user@host, if any
+	nick    string // from the prefix, if any
+	account string // the sender's services account, from the account tag
+	command string
+	params  []string
+}
+
+func parseIRCLine(line string) ircMessage {
+	var msg ircMessage
+	if strings.HasPrefix(line, "@") { // message tags
+		var tags string
+		tags, line, _ = strings.Cut(line[1:], " ")
+		for _, tag := range strings.Split(tags, ";") {
+			if account, ok := strings.CutPrefix(tag, "account="); ok {
+				msg.account = account
+			}
+		}
+	}
+	if strings.HasPrefix(line, ":") {
+		line = line[1:]
+		msg.source, line, _ = strings.Cut(line, " ")
+		msg.nick, _, _ = strings.Cut(msg.source, "!")
+	}
+	for line != "" {
+		if strings.HasPrefix(line, ":") {
+			msg.params = append(msg.params, line[1:])
+			break
+		}
+		var param string
+		param, line, _ = strings.Cut(line, " ")
+		if param == "" {
+			continue
+		}
+		if msg.command == "" {
+			msg.command = strings.ToUpper(param)
+		} else {
+			msg.params = append(msg.params, param)
+		}
+	}
+	return msg
+}
+
+func NewIRCChannel(cfg config.IRCConfig, messageBus *bus.MessageBus) (*IRCChannel, error) {
+	if cfg.Server == "" || cfg.Nick == "" {
+		return nil, fmt.Errorf("irc server and nick are required")
+	}
+
+	// Nicks can be taken by anyone, so the channel checks allow_from itself
+	// against accounts and hostmasks (see ircAllowed).
+	base := NewBaseChannel("irc", cfg, messageBus, nil)
+	base.setGroupPolicy(cfg.Groups, mentionOnlyDefaults(cfg.MentionOnly))
+
+	return &IRCChannel{
+		BaseChannel: base,
+		config:      cfg,
+		nick:        cfg.Nick,
+	}, nil
+}
+
+func (c *IRCChannel) Start(ctx context.Context) error {
+	logger.InfoCF("irc", "Starting IRC channel", map[string]any{
+		"server": c.config.Server,
+		"nick":   c.config.Nick,
+	})
+
+	c.ctx, c.cancel = context.WithCancel(ctx)
+
+	conn, err := c.dial()
+	if err != nil {
+		c.cancel()
+		return fmt.Errorf("failed to connect to IRC server: %w", err)
+	}
+	go c.run(conn)
+
+	c.setRunning(true)
+	logger.InfoC("irc", "IRC channel started")
+	return nil
+}
+
+func (c *IRCChannel) Stop(ctx context.Context) error {
+	logger.InfoC("irc", "Stopping IRC channel")
+
+	if c.cancel != nil {
+		c.cancel()
+	}
+
+	c.mu.Lock()
+	if c.conn != nil {
+		fmt.Fprintf(c.conn, "QUIT :bye\r\n")
+		c.conn.Close()
+		c.conn = nil
+	}
+	c.mu.Unlock()
+
+	c.setRunning(false)
+	logger.InfoC("irc", "IRC channel stopped")
+	return nil
+}
+
+func (c *IRCChannel) dial() (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: 15 * time.Second}
+	if !c.config.TLS {
+		return dialer.DialContext(c.ctx, "tcp", c.config.Server)
+	}
+	host, _, _ := net.SplitHostPort(c.config.Server)
+	tlsDialer := &tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: host}}
+	return tlsDialer.DialContext(c.ctx, "tcp", c.config.Server)
+}
+
+// run serves conn and then keeps reconnecting until the channel stops.
+func (c *IRCChannel) run(conn net.Conn) {
+	for {
+		err := c.serve(conn)
+		if c.ctx.Err() != nil {
+			return
+		}
+		logger.WarnCF("irc", "Disconnected, reconnecting", map[string]any{"error": fmt.Sprint(err)})
+
+		for {
+			select {
+			case <-c.ctx.Done():
+				return
+			case <-time.After(ircReconnectDelay):
+			}
+			if conn, err = c.dial(); err == nil {
+				break
+			}
+			logger.ErrorCF("irc", "Reconnect failed", map[string]any{"error": err.Error()})
+		}
+	}
+}
+
+// serve registers on conn and handles its lines until it closes.
+func (c *IRCChannel) serve(conn net.Conn) error {
+	c.mu.Lock()
+	c.conn = conn
+	c.nick = c.config.Nick
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		if c.conn == conn {
+			c.conn = nil
+		}
+		c.mu.Unlock()
+		conn.Close()
+	}()
+
+	// account-tag labels messages with the sender's services account, which
+	// allow_from can match. SASL is requested on its own, so that a server
+	// without account-tag still logs the bot in.
+	c.writeLine("CAP REQ :account-tag")
+	if c.config.SASLUser != "" {
+		c.writeLine("CAP REQ :sasl")
+	}
+	if c.config.Password != "" {
+		c.writeLine("PASS " + c.config.Password)
+	}
+	c.writeLine("NICK " + c.config.Nick)
+	username := c.config.Username
+	if username == "" {
+		username = c.config.Nick
+	}
+	realName := c.config.RealName
+	if realName == "" {
+		realName = "picoclaw"
+	}
+	c.writeLine(fmt.Sprintf("USER %s 0 * :%s", username, realName))
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		msg := parseIRCLine(strings.TrimRight(scanner.Text(), "\r"))
+		if err := c.handleLine(msg); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return fmt.Errorf("connection closed")
+}
+
+func (c *IRCChannel) handleLine(msg ircMessage) error {
+	switch msg.command {
+	case "PING":
+		c.writeLine("PONG :" + strings.Join(msg.params, " "))
+	case "CAP":
+		if len(msg.params) < 3 {
+			break
+		}
+		sasl := slices.Contains(strings.Fields(msg.params[2]), "sasl")
+		switch {
+		case sasl && msg.params[1] == "ACK":
+			c.writeLine("AUTHENTICATE PLAIN")
+		case sasl && msg.params[1] == "NAK":
+			logger.WarnC("irc", "Server does not support SASL")
+			c.writeLine("CAP END")
+		case c.config.SASLUser == "" && (msg.params[1] == "ACK" || msg.params[1] == "NAK"):
+			// The account-tag answer; without SASL, nothing else is pending.
+			c.writeLine("CAP END")
+		}
+	case "AUTHENTICATE":
+		if len(msg.params) > 0 && msg.params[0] == "+" {
+			creds := c.config.SASLUser + "\x00" + c.config.SASLUser + "\x00" + c.config.SASLPassword
+			c.writeLine("AUTHENTICATE " + base64.StdEncoding.EncodeToString([]byte(creds)))
+		}
+	case "903": // SASL success
+		c.writeLine("CAP END")
+	case "904", "905", "906": // SASL failure
+		c.writeLine("CAP END")
+		return fmt.Errorf("SASL authentication failed")
+	case "433": // nick in use
+		c.mu.Lock()
+		c.nick += "_"
+		nick := c.nick
+		c.mu.Unlock()
+		c.writeLine("NICK " + nick)
+	case "001": // welcome
+		if len(msg.params) > 0 {
+			c.mu.Lock()
+			c.nick = msg.params[0]
+			c.mu.Unlock()
+		}
+		if c.config.NickServPassword != "" {
+			c.writeLine("PRIVMSG NickServ :IDENTIFY " + c.config.NickServPassword)
+		}
+		for _, channel := range c.config.Channels {
+			c.writeLine("JOIN " + channel)
+		}
+		logger.InfoCF("irc", "Registered", map[string]any{"nick": c.currentNick()})
+	case "PRIVMSG":
+		if len(msg.params) == 2 {
+			c.handlePrivmsg(msg, msg.params[0], msg.params[1])
+		}
+	case "ERROR":
+		return fmt.Errorf("server error: %s", strings.Join(msg.params, " "))
+	}
+	return nil
+}
+
+func (c *IRCChannel) handlePrivmsg(msg ircMessage, target, text string) {
+	sender := msg.nick
+	// CTCP: keep actions as text, ignore the rest (VERSION, PING, ...).
+	if strings.HasPrefix(text, "\x01") {
+		action, ok := strings.CutPrefix(strings.Trim(text, "\x01"), "ACTION ")
+		if !ok {
+			return
+		}
+		text = "* " + sender + " " + action
+	}
+
+	nick := c.currentNick()
+	if strings.EqualFold(sender, nick) {
+		return
+	}
+	if !ircAllowed(c.config.AllowFrom, msg.source, msg.account) {
+		logger.DebugCF("irc", "Message rejected by allowlist", map[string]any{
+			"sender":  msg.source,
+			"account": msg.account,
+		})
+		return
+	}
+
+	isPM := strings.EqualFold(target, nick)
+	metadata := map[string]string{
+		"user_name": sender,
+		"platform":  "irc",
+		"peer_kind": "direct",
+		"peer_id":   sender,
+	}
+	chatID := sender
+	if !isPM {
+		chatID = target
+		metadata["peer_kind"] = "group"
+		metadata["peer_id"] = target
+		content, mentioned := stripNickMention(text, nick)
+		var ok bool
+		if text, _, ok = c.admitGroupMessage(sender, chatID, content, mentioned, metadata); !ok {
+			return
+		}
+	}
+
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+
+	logger.DebugCF("irc", "Received message", map[string]any{
+		"sender":  sender,
+		"chat_id": chatID,
+		"preview": utils.Truncate(text, 50),
+	})
+
+	c.HandleMessage(sender, chatID, text, nil, metadata)
+}
+
+// ircAllowed reports whether a sender may talk to the bot. Entries with "!"
+// or "@" are hostmasks such as "*!*@user/alice", matched against the
+// sender's nick!user@host with * and ? wildcards. Other entries are services
+// accounts, matched against the account tag, which the server only sets for
+// logged-in users. A bare nick is never trusted, since anyone can take it.
+func ircAllowed(allowFrom []string, source, account string) bool {
+	if len(allowFrom) == 0 {
+		return true
+	}
+	for _, entry := range allowFrom {
+		if strings.ContainsAny(entry, "!@") {
+			if ircMaskMatch(entry, source) {
+				return true
+			}
+		} else if account != "" && account != "*" && strings.EqualFold(entry, account) {
+			return true
+		}
+	}
+	return false
+}
+
+// ircMaskMatch reports whether s matches the hostmask mask, ignoring case.
+func ircMaskMatch(mask, s string) bool {
+	pattern := regexp.QuoteMeta(mask)
+	pattern = strings.NewReplacer(`\*`, ".*", `\?`, ".").Replace(pattern)
+	ok, _ := regexp.MatchString("(?i)^"+pattern+"$", s)
+	return ok
+}
+
+// stripNickMention reports whether text addresses nick, either as a
+// leading "nick:" / "nick," or as a standalone word, and returns text with
+// the leading form removed.
+func stripNickMention(text, nick string) (string, bool) {
+	if nick == "" {
+		return text, false
+	}
+	if len(text) > len(nick) && strings.EqualFold(text[:len(nick)], nick) &&
+		(text[len(nick)] == ':' || text[len(nick)] == ',') {
+		return strings.TrimSpace(text[len(nick)+1:]), true
+	}
+	lower, lowerNick := strings.ToLower(text), strings.ToLower(nick)
+	isWord := func(b byte) bool {
+		return b == '_' || b == '-' || b >= '0' && b <= '9' || b >= 'a' && b <= 'z' || b >= 0x80
+	}
+	for offset := 0; ; {
+		i := strings.Index(lower[offset:], lowerNick)
+		if i < 0 {
+			return text, false
+		}
+		start, end := offset+i, offset+i+len(lowerNick)
+		if (start == 0 || !isWord(lower[start-1])) && (end == len(lower) || !isWord(lower[end])) {
+			return text, true
+		}
+		offset = start + 1
+	}
+}
+
+func (c *IRCChannel) currentNick() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.nick
+}
+
+func (c *IRCChannel) Send(ctx context.Context, msg bus.OutboundMessage) error {
+	if !c.IsRunning() {
+		return fmt.Errorf("irc channel not running")
+	}
+
+	for _, chunk := range ircFormat.Render(msg.Content) {
+		for _, line := range ircSplitText(chunk, ircMaxText) {
+			if err := c.throttle(ctx); err != nil {
+				return err
+			}
+			if err := c.writeLine(fmt.Sprintf("PRIVMSG %s :%s", msg.ChatID, line)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ircSplitText splits text into non-empty lines of at most maxBytes,
+// breaking long lines at spaces where possible and never inside a UTF-8
+// character.
+func ircSplitText(text string, maxBytes int) []string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(line, " \r\t")
+		for len(line) > maxBytes {
+			cut := maxBytes
+			for cut > 0 && !utf8.RuneStart(line[cut]) {
+				cut--
+			}
+			if i := strings.LastIndexByte(line[:cut], ' '); i > maxBytes/2 {
+				cut = i
+			}
+			lines = append(lines, line[:cut])
+			line = strings.TrimLeft(line[cut:], " ")
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// throttle waits until another line may be sent without tripping the
+// server's flood protection.
+func (c *IRCChannel) throttle(ctx context.Context) error {
+	c.floodMu.Lock()
+	now := time.Now()
+	if c.floodTime.Before(now) {
+		c.floodTime = now
+	}
+	wait := c.floodTime.Sub(now) - ircFloodBurst
+	c.floodTime = c.floodTime.Add(ircFloodPenalty)
+	c.floodMu.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(wait):
+		return nil
+	}
+}
+
+func (c *IRCChannel) writeLine(line string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return fmt.Errorf("irc not connected")
+	}
+	// A line break in a parameter would start a new command.
+	line = strings.NewReplacer("\r", " ", "\n", " ").Replace(line)
+	c.conn.SetWriteDeadline(time.Now().Add(30 * time.Second))
+	_, err := fmt.Fprintf(c.conn, "%s\r\n", line)
+	return err
+}
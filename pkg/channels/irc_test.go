@@ -0,0 +1,175 @@
+package channels
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestParseIRCLine(t *testing.T) {
+	msg := parseIRCLine("@time=x;account=alice :alice!a@host PRIVMSG #dev :hello there")
+	if msg.nick != "alice" || msg.source != "alice!a@host" || msg.account != "alice" ||
+		msg.command != "PRIVMSG" || len(msg.params) != 2 || msg.params[0] != "#dev" || msg.params[1] != "hello there" {
+		t.Errorf("parsed = %+v", msg)
+	}
+	if msg := parseIRCLine("PING :abc"); msg.command != "PING" || msg.params[0] != "abc" {
+		t.Errorf("parsed = %+v", msg)
+	}
+}
+
+func TestIRCAllowed(t *testing.T) {
+	allow := []string{"alice", "*!*@user/bob"}
+	tests := []struct {
+		source, account string
+		want            bool
+	}{
+		{"alice!a@host", "alice", true},
+		{"someone!a@host", "Alice", true},
+		{"alice!a@host", "", false}, // the nick alone proves nothing
+		{"alice!a@host", "*", false},
+		{"bob!b@user/bob", "", true},
+		{"bob!b@evil.example", "", false},
+	}
+	for _, tt := range tests {
+		if got := ircAllowed(allow, tt.source, tt.account); got != tt.want {
+			t.Errorf("ircAllowed(%q, %q) = %v, want %v", tt.source, tt.account, got, tt.want)
+		}
+	}
+	if !ircAllowed(nil, "anyone!a@host", "") {
+		t.Error("empty allow_from should allow everyone")
+	}
+}
+
+func TestStripNickMention(t *testing.T) {
+	tests := []struct {
+		text      string
+		want      string
+		mentioned bool
+	}{
+		{"picobot: status?", "status?", true},
+		{"PicoBot, status?", "status?", true},
+		{"ask picobot later", "ask picobot later", true},
+		{"picobots are great", "picobots are great", false},
+		{"nothing here", "nothing here", false},
+	}
+	for _, tt := range tests {
+		got, mentioned := stripNickMention(tt.text, "picobot")
+		if got != tt.want || mentioned != tt.mentioned {
+			t.Errorf("stripNickMention(%q) = %q, %v", tt.text, got, mentioned)
+		}
+	}
+}
+
+func TestIRCSplitText(t *testing.T) {
+	long := strings.Repeat("word ", 30) + strings.Repeat("é", 40)
+	lines := ircSplitText("first\n\n"+long, 50)
+	if lines[0] != "first" {
+		t.Errorf("lines[0] = %q", lines[0])
+	}
+	for _, line := range lines {
+		if line == "" || len(line) > 50 || !utf8.ValidString(line) {
+			t.Errorf("bad line %q", line)
+		}
+	}
+	unspaced := func(s string) string { return strings.ReplaceAll(s, " ", "") }
+	if got := unspaced(strings.Join(lines[1:], "")); got != unspaced(long) {
+		t.Errorf("rejoined = %q", got)
+	}
+}
+
+func TestIRCChannel(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	mb := bus.NewMessageBus()
+	c, err := NewIRCChannel(config.IRCConfig{
+		Server: ln.Addr().String(), Nick: "picobot", SASLUser: "bot", SASLPassword: "pw",
+		Channels: []string{"#dev"}, MentionOnly: true, AllowFrom: []string{"alice"},
+	}, mb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop(context.Background())
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	r := bufio.NewReader(conn)
+	expect := func(want string) {
+		t.Helper()
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if line = strings.TrimRight(line, "\r\n"); line != want {
+			t.Fatalf("got %q, want %q", line, want)
+		}
+	}
+	send := func(line string) { fmt.Fprintf(conn, "%s\r\n", line) }
+
+	expect("CAP REQ :account-tag")
+	expect("CAP REQ :sasl")
+	expect("NICK picobot")
+	expect("USER picobot 0 * :picoclaw")
+	send(":srv CAP * ACK :account-tag")
+	send(":srv CAP * ACK :sasl")
+	expect("AUTHENTICATE PLAIN")
+	send("AUTHENTICATE +")
+	expect("AUTHENTICATE " + base64.StdEncoding.EncodeToString([]byte("bot\x00bot\x00pw")))
+	send(":srv 903 picobot :SASL authentication successful")
+	expect("CAP END")
+	send(":srv 001 picobot :Welcome")
+	expect("JOIN #dev")
+	send("PING :srv")
+	expect("PONG :srv")
+
+	send("@account=alice :alice!a@host PRIVMSG #dev :just chatting")
+	send(":alice!x@elsewhere PRIVMSG #dev :picobot: not logged in, not alice")
+	send("@account=alice :alice!a@host PRIVMSG #dev :picobot: status?")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	msg, ok := mb.ConsumeInbound(ctx)
+	if !ok {
+		t.Fatal("no inbound message")
+	}
+	if msg.SenderID != "alice" || msg.ChatID != "#dev" || msg.Content != "status?" ||
+		msg.Metadata["peer_kind"] != "group" {
+		t.Errorf("inbound = %+v", msg)
+	}
+
+	send("@account=alice :alice!a@host PRIVMSG picobot :in private")
+	msg, ok = mb.ConsumeInbound(ctx)
+	if !ok {
+		t.Fatal("no inbound private message")
+	}
+	if msg.ChatID != "alice" || msg.Metadata["peer_kind"] != "direct" {
+		t.Errorf("inbound = %+v", msg)
+	}
+
+	content := "All good.\n" + strings.Repeat("x", 450)
+	if err := c.Send(context.Background(), bus.OutboundMessage{ChatID: "#dev", Content: content}); err != nil {
+		t.Fatal(err)
+	}
+	expect("PRIVMSG #dev :All good.")
+	expect("PRIVMSG #dev :" + strings.Repeat("x", ircMaxText))
+	expect("PRIVMSG #dev :" + strings.Repeat("x", 50))
+}
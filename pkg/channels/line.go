@@ -4,12 +4,15 @@ import (
 	"bytes"
 	"context"
 	"crypto/hmac"
+	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"strings"
 	"sync"
@@ -30,6 +33,9 @@ const (
 	lineBotInfoEndpoint  = lineAPIBase + "/info"
 	lineLoadingEndpoint  = lineAPIBase + "/chat/loading/start"
 	lineReplyTokenMaxAge = 25 * time.Second
+	lineMediaPath        = "/line/media/"
+	lineMediaTTL         = time.Hour
+	lineMaxMessages      = 5 // per reply or push request
 )
 
 type replyTokenEntry struct {
@@ -37,6 +43,11 @@ type replyTokenEntry struct {
 	timestamp time.Time
 }
 
+type lineMediaEntry struct {
+	media   bus.OutboundMedia
+	expires time.Time
+}
+
 // LINEChannel implements the Channel interface for LINE Official Account
 // using the LINE Messaging API with HTTP webhook for receiving messages
 // and REST API for sending messages.
@@ -49,6 +60,7 @@ type LINEChannel struct {
 	botDisplayName string   // Bot's display name for text-based mention detection
 	replyTokens    sync.Map // chatID -> replyTokenEntry
 	quoteTokens    sync.Map // chatID -> quoteToken (string)
+	media          sync.Map // token -> lineMediaEntry, served at lineMediaPath
 	ctx            context.Context
 	cancel         context.CancelFunc
 }
@@ -60,6 +72,7 @@ func NewLINEChannel(cfg config.LINEConfig, messageBus *bus.MessageBus) (*LINECha
 	}
 
 	base := NewBaseChannel("line", cfg, messageBus, cfg.AllowFrom)
+	base.setGroupPolicy(cfg.Groups, config.GroupPolicy{Trigger: config.GroupTriggerMention})
 
 	return &LINEChannel{
 		BaseChannel: base,
@@ -92,6 +105,7 @@ func (c *LINEChannel) Start(ctx context.Context) error {
 		path = "/webhook/line"
 	}
 	mux.HandleFunc(path, c.webhookHandler)
+	mux.HandleFunc(lineMediaPath, c.mediaHandler)
 
 	addr := fmt.Sprintf("%s:%d", c.config.WebhookHost, c.config.WebhookPort)
 	c.httpServer = &http.Server{
@@ -234,9 +248,14 @@ type lineEvent struct {
 	ReplyToken string          `json:"replyToken"`
 	Source     lineSource      `json:"source"`
 	Message    json.RawMessage `json:"message"`
+	Postback   *linePostback   `json:"postback"`
 	Timestamp  int64           `json:"timestamp"`
 }
 
+type linePostback struct {
+	Data string `json:"data"`
+}
+
 type lineSource struct {
 	Type    string `json:"type"` // "user", "group", "room"
 	UserID  string `json:"userId"`
@@ -265,6 +284,10 @@ type lineMentionee struct {
 }
 
 func (c *LINEChannel) processEvent(event lineEvent) {
+	if event.Type == "postback" {
+		c.processPostback(event)
+		return
+	}
 	if event.Type != "message" {
 		logger.DebugCF("line", "Ignoring non-message event", map[string]any{
 			"type": event.Type,
@@ -284,12 +307,32 @@ func (c *LINEChannel) processEvent(event lineEvent) {
 		return
 	}
 
-	// In group chats, only respond when the bot is mentioned
-	if isGroup && !c.isBotMentioned(msg) {
-		logger.DebugCF("line", "Ignoring group message without mention", map[string]any{
-			"chat_id": chatID,
-		})
-		return
+	metadata := map[string]string{
+		"platform":    "line",
+		"source_type": event.Source.Type,
+		"message_id":  msg.ID,
+	}
+
+	if isGroup {
+		metadata["peer_kind"] = "group"
+		metadata["peer_id"] = chatID
+	} else {
+		metadata["peer_kind"] = "direct"
+		metadata["peer_id"] = senderID
+	}
+
+	// In group chats, the group policy decides whether to respond; by
+	// default only when the bot is mentioned. Media is judged by its label.
+	var text string
+	if isGroup {
+		text = fmt.Sprintf("[%s]", msg.Type)
+		if msg.Type == "text" {
+			text = c.stripBotMention(msg.Text, msg)
+		}
+		var ok bool
+		if text, _, ok = c.admitGroupMessage(senderID, chatID, text, c.isBotMentioned(msg), metadata); !ok {
+			return
+		}
 	}
 
 	// Store reply token for later use
@@ -323,9 +366,8 @@ func (c *LINEChannel) processEvent(event lineEvent) {
 	switch msg.Type {
 	case "text":
 		content = msg.Text
-		// Strip bot mention from text in group chats
 		if isGroup {
-			content = c.stripBotMention(content, msg)
+			content = text
 		}
 	case "image":
 		localPath := c.downloadContent(msg.ID, "image.jpg")
@@ -360,20 +402,6 @@ func (c *LINEChannel) processEvent(event lineEvent) {
 		return
 	}
 
-	metadata := map[string]string{
-		"platform":    "line",
-		"source_type": event.Source.Type,
-		"message_id":  msg.ID,
-	}
-
-	if isGroup {
-		metadata["peer_kind"] = "group"
-		metadata["peer_id"] = chatID
-	} else {
-		metadata["peer_kind"] = "direct"
-		metadata["peer_id"] = senderID
-	}
-
 	logger.DebugCF("line", "Received message", map[string]any{
 		"sender_id":    senderID,
 		"chat_id":      chatID,
@@ -388,6 +416,41 @@ func (c *LINEChannel) processEvent(event lineEvent) {
 	c.HandleMessage(senderID, chatID, content, mediaPaths, metadata)
 }
 
+// processPostback handles a tap on a quick reply sent by Send.
+func (c *LINEChannel) processPostback(event lineEvent) {
+	if event.Postback == nil {
+		return
+	}
+	senderID := event.Source.UserID
+	chatID := c.resolveChatID(event.Source)
+
+	if event.ReplyToken != "" {
+		c.replyTokens.Store(chatID, replyTokenEntry{
+			token:     event.ReplyToken,
+			timestamp: time.Now(),
+		})
+	}
+
+	metadata := map[string]string{
+		"platform":    "line",
+		"source_type": event.Source.Type,
+		"peer_kind":   "direct",
+		"peer_id":     senderID,
+	}
+	if event.Source.Type == "group" || event.Source.Type == "room" {
+		metadata["peer_kind"] = "group"
+		metadata["peer_id"] = chatID
+	}
+
+	if !c.HandleInteraction(senderID, event.Postback.Data, metadata) {
+		logger.DebugCF("line", "Ignoring unknown or expired postback", map[string]any{
+			"chat_id": chatID,
+		})
+		return
+	}
+	c.sendLoading(senderID)
+}
+
 // isBotMentioned checks if the bot is mentioned in the message.
 // It first checks the mention metadata (userId match), then falls back
 // to text-based detection using the bot's display name, since LINE may
@@ -500,28 +563,187 @@ func (c *LINEChannel) Send(ctx context.Context, msg bus.OutboundMessage) error {
 		quoteToken = qt.(string)
 	}
 
+	files, notices := lineMediaLimits.prepare(msg.Media)
+	content := appendNotices(msg.Content, notices)
+
+	var messages []map[string]any
+	var links []string
+	for _, f := range files {
+		if c.config.PublicURL == "" {
+			content = appendContent(content, fmt.Sprintf("(%s was not sent: channels.line.public_url is not set)", f.Name()))
+			continue
+		}
+		fileURL := c.serveMedia(f.OutboundMedia)
+		if f.kind == bus.MediaImage && f.size <= lineImagePreviewLimit {
+			if f.Caption != "" {
+				links = append(links, f.Caption)
+			}
+			messages = append(messages, map[string]any{
+				"type":               "image",
+				"originalContentUrl": fileURL,
+				"previewImageUrl":    fileURL,
+			})
+			continue
+		}
+		// Other files have no LINE message type that takes them as-is,
+		// so they are sent as download links.
+		links = append(links, appendContent(f.Caption, f.Name()+": "+fileURL))
+	}
+	for _, link := range links {
+		content = appendContent(content, link)
+	}
+	content = interactiveContent(content, msg.Interactive)
+	if content != "" || len(messages) == 0 {
+		var texts []map[string]any
+		for i, chunk := range lineFormat.Render(content) {
+			if i > 0 {
+				quoteToken = ""
+			}
+			texts = append(texts, buildTextMessage(chunk, quoteToken))
+		}
+		messages = append(texts, messages...)
+	}
+	if len(msg.Interactive) > 0 {
+		// Quick replies only show when attached to the last message.
+		messages[len(messages)-1]["quickReply"] = map[string]any{
+			"items": c.quickReplyItems(msg.ChatID, msg.Interactive),
+		}
+	}
+
+	batch := messages[:min(len(messages), lineMaxMessages)]
+	rest := messages[len(batch):]
+
 	// Try reply token first (free, valid for ~25 seconds)
+	sent := false
 	if entry, ok := c.replyTokens.LoadAndDelete(msg.ChatID); ok {
 		tokenEntry := entry.(replyTokenEntry)
 		if time.Since(tokenEntry.timestamp) < lineReplyTokenMaxAge {
-			if err := c.sendReply(ctx, tokenEntry.token, msg.Content, quoteToken); err == nil {
+			if err := c.sendReply(ctx, tokenEntry.token, batch); err == nil {
 				logger.DebugCF("line", "Message sent via Reply API", map[string]any{
 					"chat_id": msg.ChatID,
 					"quoted":  quoteToken != "",
 				})
-				return nil
+				sent = true
+			} else {
+				logger.DebugC("line", "Reply API failed, falling back to Push API")
 			}
-			logger.DebugC("line", "Reply API failed, falling back to Push API")
 		}
 	}
+	if !sent {
+		rest = messages
+	}
 
 	// Fall back to Push API
-	return c.sendPush(ctx, msg.ChatID, msg.Content, quoteToken)
+	for len(rest) > 0 {
+		batch = rest[:min(len(rest), lineMaxMessages)]
+		rest = rest[len(batch):]
+		if err := c.sendPush(ctx, msg.ChatID, batch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lineFormat is plain text: LINE does not render Markdown. Text messages
+// take up to 5000 characters.
+var lineFormat = MessageFormat{Dialect: DialectPlain, MaxLen: 5000}
+
+func (c *LINEChannel) SupportsMedia() bool {
+	return true
+}
+
+func (c *LINEChannel) SupportsInteractive() bool {
+	return true
+}
+
+// Quick reply limits.
+const (
+	lineQuickReplyLimit = 13
+	lineQuickReplyLabel = 20
+)
+
+// quickReplyItems turns the options of all interactive elements into
+// quick reply buttons, LINE's only interactive element for text messages.
+// Options that open links become URI actions.
+func (c *LINEChannel) quickReplyItems(chatID string, elements []bus.InteractiveElement) []map[string]any {
+	var items []map[string]any
+	for _, el := range elements {
+		for _, opt := range el.Options {
+			label := utils.Truncate(opt.Label, lineQuickReplyLabel)
+			action := map[string]string{"type": "uri", "label": label, "uri": opt.URL}
+			if opt.URL == "" {
+				action = map[string]string{
+					"type":        "postback",
+					"label":       label,
+					"data":        c.interactions.register(chatID, el, opt),
+					"displayText": opt.Label,
+				}
+			}
+			items = append(items, map[string]any{"type": "action", "action": action})
+		}
+	}
+	if len(items) > lineQuickReplyLimit {
+		logger.WarnCF("line", "Dropping quick replies over the limit", map[string]any{"count": len(items)})
+		items = items[:lineQuickReplyLimit]
+	}
+	return items
+}
+
+// lineMediaLimits is the image message limit; other files are sent as
+// links and only bounded by what the webhook server is willing to serve.
+var lineMediaLimits = mediaLimits{
+	bus.MediaImage: 10 << 20,
+	bus.MediaFile:  200 << 20,
+}
+
+// lineImagePreviewLimit is the preview image limit. The image itself is
+// used as its preview, so larger images are sent as links.
+const lineImagePreviewLimit = 1 << 20
+
+// serveMedia makes m downloadable from the webhook server for lineMediaTTL
+// and returns its public URL. LINE fetches message media from a URL
+// rather than accepting uploads.
+func (c *LINEChannel) serveMedia(m bus.OutboundMedia) string {
+	now := time.Now()
+	c.media.Range(func(key, value any) bool {
+		if now.After(value.(lineMediaEntry).expires) {
+			c.media.Delete(key)
+		}
+		return true
+	})
+
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	token := hex.EncodeToString(buf)
+	c.media.Store(token, lineMediaEntry{media: m, expires: now.Add(lineMediaTTL)})
+
+	return strings.TrimSuffix(c.config.PublicURL, "/") + lineMediaPath + token + "/" + url.PathEscape(m.Name())
+}
+
+// mediaHandler serves files registered with serveMedia.
+func (c *LINEChannel) mediaHandler(w http.ResponseWriter, r *http.Request) {
+	token, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, lineMediaPath), "/")
+	value, ok := c.media.Load(token)
+	if !ok || time.Now().After(value.(lineMediaEntry).expires) {
+		http.NotFound(w, r)
+		return
+	}
+	m := value.(lineMediaEntry).media
+
+	rc, err := m.Open()
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer rc.Close()
+
+	w.Header().Set("Content-Type", m.ContentType())
+	io.Copy(w, rc)
 }
 
 // buildTextMessage creates a text message object, optionally with quoteToken.
-func buildTextMessage(content, quoteToken string) map[string]string {
-	msg := map[string]string{
+func buildTextMessage(content, quoteToken string) map[string]any {
+	msg := map[string]any{
 		"type": "text",
 		"text": content,
 	}
@@ -531,21 +753,21 @@ func buildTextMessage(content, quoteToken string) map[string]string {
 	return msg
 }
 
-// sendReply sends a message using the LINE Reply API.
-func (c *LINEChannel) sendReply(ctx context.Context, replyToken, content, quoteToken string) error {
+// sendReply sends messages using the LINE Reply API.
+func (c *LINEChannel) sendReply(ctx context.Context, replyToken string, messages []map[string]any) error {
 	payload := map[string]any{
 		"replyToken": replyToken,
-		"messages":   []map[string]string{buildTextMessage(content, quoteToken)},
+		"messages":   messages,
 	}
 
 	return c.callAPI(ctx, lineReplyEndpoint, payload)
 }
 
-// sendPush sends a message using the LINE Push API.
-func (c *LINEChannel) sendPush(ctx context.Context, to, content, quoteToken string) error {
+// sendPush sends messages using the LINE Push API.
+func (c *LINEChannel) sendPush(ctx context.Context, to string, messages []map[string]any) error {
 	payload := map[string]any{
 		"to":       to,
-		"messages": []map[string]string{buildTextMessage(content, quoteToken)},
+		"messages": messages,
 	}
 
 	return c.callAPI(ctx, linePushEndpoint, payload)
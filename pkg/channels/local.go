@@ -0,0 +1,215 @@
+package channels
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+// LocalChatPath is where the gateway serves the local channel.
+const LocalChatPath = "/chat"
+
+// LocalFrame is a JSON message on the local channel's WebSocket, in
+// either direction. Type is "message" for chat text.
+type LocalFrame struct {
+	Type    string `json:"type"`
+	Content string `json:"content,omitempty"`
+}
+
+// LocalChannel lets terminal clients such as `picoclaw chat` talk to the
+// gateway's agent over a WebSocket. Clients pick a session with the
+// "session" query parameter, which becomes the chat ID; every client on a
+// session receives its replies.
+type LocalChannel struct {
+	*BaseChannel
+	config   config.LocalConfig
+	upgrader websocket.Upgrader
+	mu       sync.Mutex
+	clients  map[string]map[*localClient]struct{} // by chat ID
+}
+
+type localClient struct {
+	conn    *websocket.Conn
+	writeMu sync.Mutex
+}
+
+func (lc *localClient) write(frame LocalFrame) error {
+	lc.writeMu.Lock()
+	defer lc.writeMu.Unlock()
+	lc.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	return lc.conn.WriteJSON(frame)
+}
+
+func NewLocalChannel(cfg config.LocalConfig, messageBus *bus.MessageBus) (*LocalChannel, error) {
+	base := NewBaseChannel("local", cfg, messageBus, cfg.AllowFrom)
+
+	return &LocalChannel{
+		BaseChannel: base,
+		config:      cfg,
+		clients:     make(map[string]map[*localClient]struct{}),
+	}, nil
+}
+
+func (c *LocalChannel) Start(ctx context.Context) error {
+	c.setRunning(true)
+	logger.InfoC("local", "Local channel started")
+	return nil
+}
+
+func (c *LocalChannel) Stop(ctx context.Context) error {
+	c.setRunning(false)
+
+	c.mu.Lock()
+	for _, clients := range c.clients {
+		for client := range clients {
+			client.conn.Close()
+		}
+	}
+	c.clients = make(map[string]map[*localClient]struct{})
+	c.mu.Unlock()
+
+	logger.InfoC("local", "Local channel stopped")
+	return nil
+}
+
+// ServeHTTP upgrades a client connection and relays its messages until it
+// disconnects.
+func (c *LocalChannel) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !c.IsRunning() {
+		http.Error(w, "local channel not running", http.StatusServiceUnavailable)
+		return
+	}
+	if !c.authorized(r) {
+		if c.config.Token == "" {
+			http.Error(w, "set channels.local.token to connect from other hosts", http.StatusForbidden)
+		} else {
+			http.Error(w, "invalid or missing token", http.StatusUnauthorized)
+		}
+		return
+	}
+
+	query := r.URL.Query()
+	chatID := query.Get("session")
+	if chatID == "" {
+		chatID = "default"
+	}
+	user := query.Get("user")
+	if user == "" {
+		user = "local"
+	}
+
+	conn, err := c.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	// The gateway server's timeouts leave deadlines on hijacked connections.
+	conn.SetReadDeadline(time.Time{})
+	client := &localClient{conn: conn}
+	c.addClient(chatID, client)
+	defer func() {
+		c.removeClient(chatID, client)
+		conn.Close()
+	}()
+
+	logger.InfoCF("local", "Client connected", map[string]any{"session": chatID, "user": user})
+	for {
+		var frame LocalFrame
+		if err := conn.ReadJSON(&frame); err != nil {
+			logger.DebugCF("local", "Client disconnected", map[string]any{"session": chatID, "error": err.Error()})
+			return
+		}
+		content := strings.TrimSpace(frame.Content)
+		if frame.Type != "message" || content == "" {
+			continue
+		}
+
+		logger.DebugCF("local", "Received message", map[string]any{
+			"session": chatID,
+			"preview": utils.Truncate(content, 50),
+		})
+
+		c.HandleMessage(user, chatID, content, nil, map[string]string{
+			"user_name": user,
+			"platform":  "local",
+			"peer_kind": "direct",
+			"peer_id":   chatID,
+		})
+	}
+}
+
+// authorized checks the bearer token (header or "token" query parameter),
+// or without a configured token, that the client is on this machine.
+func (c *LocalChannel) authorized(r *http.Request) bool {
+	if c.config.Token == "" {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		ip := net.ParseIP(host)
+		return err == nil && ip != nil && ip.IsLoopback()
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		token = r.URL.Query().Get("token")
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(c.config.Token)) == 1
+}
+
+func (c *LocalChannel) addClient(chatID string, client *localClient) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.clients[chatID] == nil {
+		c.clients[chatID] = make(map[*localClient]struct{})
+	}
+	c.clients[chatID][client] = struct{}{}
+}
+
+func (c *LocalChannel) removeClient(chatID string, client *localClient) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.clients[chatID], client)
+	if len(c.clients[chatID]) == 0 {
+		delete(c.clients, chatID)
+	}
+}
+
+func (c *LocalChannel) Send(ctx context.Context, msg bus.OutboundMessage) error {
+	if !c.IsRunning() {
+		return fmt.Errorf("local channel not running")
+	}
+
+	c.mu.Lock()
+	clients := make([]*localClient, 0, len(c.clients[msg.ChatID]))
+	for client := range c.clients[msg.ChatID] {
+		clients = append(clients, client)
+	}
+	c.mu.Unlock()
+
+	if len(clients) == 0 {
+		// Nobody is there to read it; a client that connects later starts
+		// a new conversation rather than waiting for old replies.
+		return fmt.Errorf("no local client connected to session %s: %w", msg.ChatID, ErrPermanent)
+	}
+
+	var delivered bool
+	for _, client := range clients {
+		if err := client.write(LocalFrame{Type: "message", Content: msg.Content}); err != nil {
+			logger.WarnCF("local", "Failed to write to client", map[string]any{"error": err.Error()})
+			continue
+		}
+		delivered = true
+	}
+	if !delivered {
+		return fmt.Errorf("failed to deliver to session %s", msg.ChatID)
+	}
+	return nil
+}
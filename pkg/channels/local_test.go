@@ -0,0 +1,88 @@
+package channels
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestLocalChannel(t *testing.T) {
+	mb := bus.NewMessageBus()
+	c, err := NewLocalChannel(config.LocalConfig{}, mb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Start(context.Background())
+	defer c.Stop(context.Background())
+
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+	wsURL := "ws" + strings.TrimPrefix(srv.URL, "http") + LocalChatPath + "?session=work&user=alice"
+
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if err := conn.WriteJSON(LocalFrame{Type: "message", Content: "hello"}); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	msg, ok := mb.ConsumeInbound(ctx)
+	if !ok {
+		t.Fatal("no inbound message")
+	}
+	if msg.Channel != "local" || msg.SenderID != "alice" || msg.ChatID != "work" || msg.Content != "hello" ||
+		msg.Metadata["peer_kind"] != "direct" {
+		t.Errorf("inbound = %+v", msg)
+	}
+
+	if err := c.Send(context.Background(), bus.OutboundMessage{ChatID: "work", Content: "hi there"}); err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var frame LocalFrame
+	if err := conn.ReadJSON(&frame); err != nil {
+		t.Fatal(err)
+	}
+	if frame.Type != "message" || frame.Content != "hi there" {
+		t.Errorf("frame = %+v", frame)
+	}
+
+	if err := c.Send(context.Background(), bus.OutboundMessage{ChatID: "other", Content: "x"}); !isPermanent(err) {
+		t.Errorf("err = %v, want a permanent error for a session without clients", err)
+	}
+}
+
+func TestLocalChannelToken(t *testing.T) {
+	c, err := NewLocalChannel(config.LocalConfig{Token: "secret"}, bus.NewMessageBus())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Start(context.Background())
+	defer c.Stop(context.Background())
+
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+	wsURL := "ws" + strings.TrimPrefix(srv.URL, "http") + LocalChatPath
+
+	if _, resp, err := websocket.DefaultDialer.Dial(wsURL, nil); err == nil ||
+		resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("dial without token: err = %v", err)
+	}
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, http.Header{"Authorization": {"Bearer secret"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+}
@@ -8,8 +8,12 @@ package channels
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"path/filepath"
+	"reflect"
 	"sync"
+	"time"
 
 	"github.com/sipeed/picoclaw/pkg/bus"
 	"github.com/sipeed/picoclaw/pkg/config"
@@ -19,9 +23,11 @@ import (
 
 type Manager struct {
 	channels     map[string]Channel
+	applied      map[string]*config.Config // config each channel was built from
 	bus          *bus.MessageBus
 	config       *config.Config
 	dispatchTask *asyncTask
+	outbox       *Outbox
 	mu           sync.RWMutex
 }
 
@@ -32,9 +38,11 @@ type asyncTask struct {
 func NewManager(cfg *config.Config, messageBus *bus.MessageBus) (*Manager, error) {
 	m := &Manager{
 		channels: make(map[string]Channel),
+		applied:  make(map[string]*config.Config),
 		bus:      messageBus,
 		config:   cfg,
 	}
+	m.outbox = NewOutbox(filepath.Join(cfg.WorkspacePath(), "outbox"), outboxOptions(cfg), m.deliver)
 
 	if err := m.initChannels(); err != nil {
 		return nil, err
@@ -43,170 +51,235 @@ func NewManager(cfg *config.Config, messageBus *bus.MessageBus) (*Manager, error
 	return m, nil
 }
 
-func (m *Manager) initChannels() error {
-	logger.InfoC("channels", "Initializing channel manager")
-
-	if m.config.Channels.Telegram.Enabled && m.config.Channels.Telegram.Token != "" {
-		logger.DebugC("channels", "Attempting to initialize Telegram channel")
-		telegram, err := NewTelegramChannel(m.config, m.bus)
-		if err != nil {
-			logger.ErrorCF("channels", "Failed to initialize Telegram channel", map[string]any{
-				"error": err.Error(),
-			})
-		} else {
-			m.channels["telegram"] = telegram
-			logger.InfoC("channels", "Telegram channel enabled successfully")
-		}
-	}
-
-	if m.config.Channels.WhatsApp.Enabled && m.config.Channels.WhatsApp.BridgeURL != "" {
-		logger.DebugC("channels", "Attempting to initialize WhatsApp channel")
-		whatsapp, err := NewWhatsAppChannel(m.config.Channels.WhatsApp, m.bus)
-		if err != nil {
-			logger.ErrorCF("channels", "Failed to initialize WhatsApp channel", map[string]any{
-				"error": err.Error(),
-			})
-		} else {
-			m.channels["whatsapp"] = whatsapp
-			logger.InfoC("channels", "WhatsApp channel enabled successfully")
-		}
-	}
-
-	if m.config.Channels.Feishu.Enabled {
-		logger.DebugC("channels", "Attempting to initialize Feishu channel")
-		feishu, err := NewFeishuChannel(m.config.Channels.Feishu, m.bus)
-		if err != nil {
-			logger.ErrorCF("channels", "Failed to initialize Feishu channel", map[string]any{
-				"error": err.Error(),
-			})
-		} else {
-			m.channels["feishu"] = feishu
-			logger.InfoC("channels", "Feishu channel enabled successfully")
-		}
-	}
+// channelFactory describes how to build one channel from config.
+// section returns the channel's config block so reloads can detect changes.
+type channelFactory struct {
+	name        string
+	displayName string
+	enabled     func(c *config.ChannelsConfig) bool
+	section     func(c *config.ChannelsConfig) any
+	create      func(cfg *config.Config, messageBus *bus.MessageBus) (Channel, error)
+}
 
-	if m.config.Channels.Discord.Enabled && m.config.Channels.Discord.Token != "" {
-		logger.DebugC("channels", "Attempting to initialize Discord channel")
-		discord, err := NewDiscordChannel(m.config.Channels.Discord, m.bus)
-		if err != nil {
-			logger.ErrorCF("channels", "Failed to initialize Discord channel", map[string]any{
-				"error": err.Error(),
-			})
-		} else {
-			m.channels["discord"] = discord
-			logger.InfoC("channels", "Discord channel enabled successfully")
-		}
-	}
+var channelFactories = []channelFactory{
+	{
+		name:        "telegram",
+		displayName: "Telegram",
+		enabled:     func(c *config.ChannelsConfig) bool { return c.Telegram.Enabled && c.Telegram.Token != "" },
+		section:     func(c *config.ChannelsConfig) any { return c.Telegram },
+		create: func(cfg *config.Config, b *bus.MessageBus) (Channel, error) {
+			return NewTelegramChannel(cfg, b)
+		},
+	},
+	{
+		name:        "whatsapp",
+		displayName: "WhatsApp",
+		enabled:     func(c *config.ChannelsConfig) bool { return c.WhatsApp.Enabled && c.WhatsApp.BridgeURL != "" },
+		section:     func(c *config.ChannelsConfig) any { return c.WhatsApp },
+		create: func(cfg *config.Config, b *bus.MessageBus) (Channel, error) {
+			return NewWhatsAppChannel(cfg.Channels.WhatsApp, b)
+		},
+	},
+	{
+		name:        "whatsapp_cloud",
+		displayName: "WhatsApp Cloud",
+		enabled: func(c *config.ChannelsConfig) bool {
+			return c.WhatsAppCloud.Enabled && c.WhatsAppCloud.PhoneNumberID != ""
+		},
+		section: func(c *config.ChannelsConfig) any { return c.WhatsAppCloud },
+		create: func(cfg *config.Config, b *bus.MessageBus) (Channel, error) {
+			return NewWhatsAppCloudChannel(cfg.Channels.WhatsAppCloud, b)
+		},
+	},
+	{
+		name:        "feishu",
+		displayName: "Feishu",
+		enabled:     func(c *config.ChannelsConfig) bool { return c.Feishu.Enabled },
+		section:     func(c *config.ChannelsConfig) any { return c.Feishu },
+		create: func(cfg *config.Config, b *bus.MessageBus) (Channel, error) {
+			return NewFeishuChannel(cfg.Channels.Feishu, b)
+		},
+	},
+	{
+		name:        "discord",
+		displayName: "Discord",
+		enabled:     func(c *config.ChannelsConfig) bool { return c.Discord.Enabled && c.Discord.Token != "" },
+		section:     func(c *config.ChannelsConfig) any { return c.Discord },
+		create: func(cfg *config.Config, b *bus.MessageBus) (Channel, error) {
+			return NewDiscordChannel(cfg.Channels.Discord, b)
+		},
+	},
+	{
+		name:        "maixcam",
+		displayName: "MaixCam",
+		enabled:     func(c *config.ChannelsConfig) bool { return c.MaixCam.Enabled },
+		section:     func(c *config.ChannelsConfig) any { return c.MaixCam },
+		create: func(cfg *config.Config, b *bus.MessageBus) (Channel, error) {
+			return NewMaixCamChannel(cfg.Channels.MaixCam, b)
+		},
+	},
+	{
+		name:        "qq",
+		displayName: "QQ",
+		enabled:     func(c *config.ChannelsConfig) bool { return c.QQ.Enabled },
+		section:     func(c *config.ChannelsConfig) any { return c.QQ },
+		create: func(cfg *config.Config, b *bus.MessageBus) (Channel, error) {
+			return NewQQChannel(cfg.Channels.QQ, b)
+		},
+	},
+	{
+		name:        "dingtalk",
+		displayName: "DingTalk",
+		enabled:     func(c *config.ChannelsConfig) bool { return c.DingTalk.Enabled && c.DingTalk.ClientID != "" },
+		section:     func(c *config.ChannelsConfig) any { return c.DingTalk },
+		create: func(cfg *config.Config, b *bus.MessageBus) (Channel, error) {
+			return NewDingTalkChannel(cfg.Channels.DingTalk, b)
+		},
+	},
+	{
+		name:        "slack",
+		displayName: "Slack",
+		enabled:     func(c *config.ChannelsConfig) bool { return c.Slack.Enabled && c.Slack.BotToken != "" },
+		section:     func(c *config.ChannelsConfig) any { return c.Slack },
+		create: func(cfg *config.Config, b *bus.MessageBus) (Channel, error) {
+			return NewSlackChannel(cfg.Channels.Slack, b)
+		},
+	},
+	{
+		name:        "line",
+		displayName: "LINE",
+		enabled:     func(c *config.ChannelsConfig) bool { return c.LINE.Enabled && c.LINE.ChannelAccessToken != "" },
+		section:     func(c *config.ChannelsConfig) any { return c.LINE },
+		create: func(cfg *config.Config, b *bus.MessageBus) (Channel, error) {
+			return NewLINEChannel(cfg.Channels.LINE, b)
+		},
+	},
+	{
+		name:        "onebot",
+		displayName: "OneBot",
+		enabled:     func(c *config.ChannelsConfig) bool { return c.OneBot.Enabled && c.OneBot.WSUrl != "" },
+		section:     func(c *config.ChannelsConfig) any { return c.OneBot },
+		create: func(cfg *config.Config, b *bus.MessageBus) (Channel, error) {
+			return NewOneBotChannel(cfg.Channels.OneBot, b)
+		},
+	},
+	{
+		name:        "wecom",
+		displayName: "WeCom",
+		enabled:     func(c *config.ChannelsConfig) bool { return c.WeCom.Enabled && c.WeCom.Token != "" },
+		section:     func(c *config.ChannelsConfig) any { return c.WeCom },
+		create: func(cfg *config.Config, b *bus.MessageBus) (Channel, error) {
+			return NewWeComBotChannel(cfg.Channels.WeCom, b)
+		},
+	},
+	{
+		name:        "wecom_app",
+		displayName: "WeCom App",
+		enabled:     func(c *config.ChannelsConfig) bool { return c.WeComApp.Enabled && c.WeComApp.CorpID != "" },
+		section:     func(c *config.ChannelsConfig) any { return c.WeComApp },
+		create: func(cfg *config.Config, b *bus.MessageBus) (Channel, error) {
+			return NewWeComAppChannel(cfg.Channels.WeComApp, b)
+		},
+	},
+	{
+		name:        "signal",
+		displayName: "Signal",
+		enabled:     func(c *config.ChannelsConfig) bool { return c.Signal.Enabled && c.Signal.Account != "" },
+		section:     func(c *config.ChannelsConfig) any { return c.Signal },
+		create: func(cfg *config.Config, b *bus.MessageBus) (Channel, error) {
+			return NewSignalChannel(cfg.Channels.Signal, b)
+		},
+	},
+	{
+		name:        "mattermost",
+		displayName: "Mattermost",
+		enabled:     func(c *config.ChannelsConfig) bool { return c.Mattermost.Enabled && c.Mattermost.Token != "" },
+		section:     func(c *config.ChannelsConfig) any { return c.Mattermost },
+		create: func(cfg *config.Config, b *bus.MessageBus) (Channel, error) {
+			return NewMattermostChannel(cfg.Channels.Mattermost, b)
+		},
+	},
+	{
+		name:        "rocketchat",
+		displayName: "Rocket.Chat",
+		enabled:     func(c *config.ChannelsConfig) bool { return c.RocketChat.Enabled && c.RocketChat.Token != "" },
+		section:     func(c *config.ChannelsConfig) any { return c.RocketChat },
+		create: func(cfg *config.Config, b *bus.MessageBus) (Channel, error) {
+			return NewRocketChatChannel(cfg.Channels.RocketChat, b)
+		},
+	},
+	{
+		name:        "irc",
+		displayName: "IRC",
+		enabled:     func(c *config.ChannelsConfig) bool { return c.IRC.Enabled && c.IRC.Nick != "" },
+		section:     func(c *config.ChannelsConfig) any { return c.IRC },
+		create: func(cfg *config.Config, b *bus.MessageBus) (Channel, error) {
+			return NewIRCChannel(cfg.Channels.IRC, b)
+		},
+	},
+	{
+		name:        "xmpp",
+		displayName: "XMPP",
+		enabled:     func(c *config.ChannelsConfig) bool { return c.XMPP.Enabled && c.XMPP.JID != "" },
+		section:     func(c *config.ChannelsConfig) any { return c.XMPP },
+		create: func(cfg *config.Config, b *bus.MessageBus) (Channel, error) {
+			return NewXMPPChannel(cfg.Channels.XMPP, b)
+		},
+	},
+	{
+		name:        "local",
+		displayName: "Local",
+		enabled:     func(c *config.ChannelsConfig) bool { return c.Local.Enabled },
+		section:     func(c *config.ChannelsConfig) any { return c.Local },
+		create: func(cfg *config.Config, b *bus.MessageBus) (Channel, error) {
+			return NewLocalChannel(cfg.Channels.Local, b)
+		},
+	},
+}
 
-	if m.config.Channels.MaixCam.Enabled {
-		logger.DebugC("channels", "Attempting to initialize MaixCam channel")
-		maixcam, err := NewMaixCamChannel(m.config.Channels.MaixCam, m.bus)
-		if err != nil {
-			logger.ErrorCF("channels", "Failed to initialize MaixCam channel", map[string]any{
-				"error": err.Error(),
-			})
-		} else {
-			m.channels["maixcam"] = maixcam
-			logger.InfoC("channels", "MaixCam channel enabled successfully")
-		}
-	}
+func (m *Manager) initChannels() error {
+	logger.InfoC("channels", "Initializing channel manager")
 
-	if m.config.Channels.QQ.Enabled {
-		logger.DebugC("channels", "Attempting to initialize QQ channel")
-		qq, err := NewQQChannel(m.config.Channels.QQ, m.bus)
-		if err != nil {
-			logger.ErrorCF("channels", "Failed to initialize QQ channel", map[string]any{
-				"error": err.Error(),
-			})
-		} else {
-			m.channels["qq"] = qq
-			logger.InfoC("channels", "QQ channel enabled successfully")
+	for _, f := range channelFactories {
+		if !f.enabled(&m.config.Channels) {
+			continue
 		}
-	}
-
-	if m.config.Channels.DingTalk.Enabled && m.config.Channels.DingTalk.ClientID != "" {
-		logger.DebugC("channels", "Attempting to initialize DingTalk channel")
-		dingtalk, err := NewDingTalkChannel(m.config.Channels.DingTalk, m.bus)
-		if err != nil {
-			logger.ErrorCF("channels", "Failed to initialize DingTalk channel", map[string]any{
-				"error": err.Error(),
-			})
-		} else {
-			m.channels["dingtalk"] = dingtalk
-			logger.InfoC("channels", "DingTalk channel enabled successfully")
+		if channel, err := m.createChannel(f, m.config); err == nil {
+			m.channels[f.name] = channel
+			m.applied[f.name] = m.config
 		}
 	}
 
-	if m.config.Channels.Slack.Enabled && m.config.Channels.Slack.BotToken != "" {
-		logger.DebugC("channels", "Attempting to initialize Slack channel")
-		slackCh, err := NewSlackChannel(m.config.Channels.Slack, m.bus)
-		if err != nil {
-			logger.ErrorCF("channels", "Failed to initialize Slack channel", map[string]any{
-				"error": err.Error(),
-			})
-		} else {
-			m.channels["slack"] = slackCh
-			logger.InfoC("channels", "Slack channel enabled successfully")
-		}
-	}
-
-	if m.config.Channels.LINE.Enabled && m.config.Channels.LINE.ChannelAccessToken != "" {
-		logger.DebugC("channels", "Attempting to initialize LINE channel")
-		line, err := NewLINEChannel(m.config.Channels.LINE, m.bus)
-		if err != nil {
-			logger.ErrorCF("channels", "Failed to initialize LINE channel", map[string]any{
-				"error": err.Error(),
-			})
-		} else {
-			m.channels["line"] = line
-			logger.InfoC("channels", "LINE channel enabled successfully")
-		}
-	}
+	logger.InfoCF("channels", "Channel initialization completed", map[string]any{
+		"enabled_channels": len(m.channels),
+	})
 
-	if m.config.Channels.OneBot.Enabled && m.config.Channels.OneBot.WSUrl != "" {
-		logger.DebugC("channels", "Attempting to initialize OneBot channel")
-		onebot, err := NewOneBotChannel(m.config.Channels.OneBot, m.bus)
-		if err != nil {
-			logger.ErrorCF("channels", "Failed to initialize OneBot channel", map[string]any{
-				"error": err.Error(),
-			})
-		} else {
-			m.channels["onebot"] = onebot
-			logger.InfoC("channels", "OneBot channel enabled successfully")
-		}
-	}
+	return nil
+}
 
-	if m.config.Channels.WeCom.Enabled && m.config.Channels.WeCom.Token != "" {
-		logger.DebugC("channels", "Attempting to initialize WeCom channel")
-		wecom, err := NewWeComBotChannel(m.config.Channels.WeCom, m.bus)
-		if err != nil {
-			logger.ErrorCF("channels", "Failed to initialize WeCom channel", map[string]any{
-				"error": err.Error(),
-			})
-		} else {
-			m.channels["wecom"] = wecom
-			logger.InfoC("channels", "WeCom channel enabled successfully")
-		}
+// createChannel builds a channel from cfg, logging failures.
+func (m *Manager) createChannel(f channelFactory, cfg *config.Config) (Channel, error) {
+	logger.DebugC("channels", fmt.Sprintf("Attempting to initialize %s channel", f.displayName))
+	channel, err := f.create(cfg, m.bus)
+	if err != nil {
+		logger.ErrorCF("channels", fmt.Sprintf("Failed to initialize %s channel", f.displayName), map[string]any{
+			"error": err.Error(),
+		})
+		return nil, err
 	}
-
-	if m.config.Channels.WeComApp.Enabled && m.config.Channels.WeComApp.CorpID != "" {
-		logger.DebugC("channels", "Attempting to initialize WeCom App channel")
-		wecomApp, err := NewWeComAppChannel(m.config.Channels.WeComApp, m.bus)
-		if err != nil {
-			logger.ErrorCF("channels", "Failed to initialize WeCom App channel", map[string]any{
-				"error": err.Error(),
+	// Interactive options outlive the channel instance, so buttons sent
+	// before a reload or restart keep working.
+	if p, ok := channel.(interface{ persistInteractions(path string) error }); ok {
+		path := filepath.Join(cfg.WorkspacePath(), "interactions", f.name+".jsonl")
+		if err := p.persistInteractions(path); err != nil {
+			logger.WarnCF("channels", "Failed to load interactive options", map[string]any{
+				"channel": f.name,
+				"error":   err.Error(),
 			})
-		} else {
-			m.channels["wecom_app"] = wecomApp
-			logger.InfoC("channels", "WeCom App channel enabled successfully")
 		}
 	}
-
-	logger.InfoCF("channels", "Channel initialization completed", map[string]any{
-		"enabled_channels": len(m.channels),
-	})
-
-	return nil
+	logger.InfoC("channels", fmt.Sprintf("%s channel enabled successfully", f.displayName))
+	return channel, nil
 }
 
 func (m *Manager) StartAll(ctx context.Context) error {
@@ -268,8 +341,99 @@ func (m *Manager) StopAll(ctx context.Context) error {
 	return nil
 }
 
+// ApplyConfig reconciles the running channels with cfg. Channels whose
+// config section is unchanged keep running; changed channels are restarted,
+// disabled ones stopped and newly enabled ones started. If a changed channel
+// fails to start, the previous instance is rebuilt from its old config.
+// It returns the names of channels that were touched.
+func (m *Manager) ApplyConfig(ctx context.Context, cfg *config.Config) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var changed []string
+	var errs []error
+
+	for _, f := range channelFactories {
+		old, running := m.channels[f.name]
+		previous := m.applied[f.name]
+		enabled := f.enabled(&cfg.Channels)
+
+		if running == enabled && (!enabled ||
+			(previous != nil && reflect.DeepEqual(f.section(&previous.Channels), f.section(&cfg.Channels)))) {
+			continue
+		}
+		changed = append(changed, f.name)
+
+		if running {
+			logger.InfoCF("channels", "Stopping channel for reload", map[string]any{"channel": f.name})
+			if err := old.Stop(ctx); err != nil {
+				logger.ErrorCF("channels", "Error stopping channel", map[string]any{
+					"channel": f.name,
+					"error":   err.Error(),
+				})
+			}
+			delete(m.channels, f.name)
+		}
+
+		delete(m.applied, f.name)
+		if !enabled {
+			continue
+		}
+
+		next, err := m.startChannel(ctx, f, cfg)
+		if err == nil {
+			m.channels[f.name] = next
+			m.applied[f.name] = cfg
+			continue
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", f.name, err))
+
+		if previous != nil {
+			// Roll back to the config the channel was running with.
+			if restored, rerr := m.startChannel(ctx, f, previous); rerr == nil {
+				m.channels[f.name] = restored
+				m.applied[f.name] = previous
+				logger.WarnCF("channels", "Channel rolled back to previous config", map[string]any{"channel": f.name})
+			}
+		}
+	}
+
+	m.config = cfg
+	m.outbox.SetOptions(outboxOptions(cfg))
+
+	if len(m.channels) > 0 && m.dispatchTask == nil {
+		dispatchCtx, cancel := context.WithCancel(ctx)
+		m.dispatchTask = &asyncTask{cancel: cancel}
+		go m.dispatchOutbound(dispatchCtx)
+	}
+
+	return changed, errors.Join(errs...)
+}
+
+// startChannel creates and starts a channel from cfg.
+func (m *Manager) startChannel(ctx context.Context, f channelFactory, cfg *config.Config) (Channel, error) {
+	channel, err := m.createChannel(f, cfg)
+	if err != nil {
+		return nil, err
+	}
+	logger.InfoCF("channels", "Starting channel", map[string]any{
+		"channel": f.name,
+	})
+	if err := channel.Start(ctx); err != nil {
+		logger.ErrorCF("channels", "Failed to start channel", map[string]any{
+			"channel": f.name,
+			"error":   err.Error(),
+		})
+		return nil, err
+	}
+	return channel, nil
+}
+
+// dispatchOutbound moves outbound messages from the bus into the outbox,
+// which delivers them with retries.
 func (m *Manager) dispatchOutbound(ctx context.Context) {
 	logger.InfoC("channels", "Outbound dispatcher started")
+	go m.outbox.Run(ctx)
 
 	for {
 		select {
@@ -287,19 +451,8 @@ func (m *Manager) dispatchOutbound(ctx context.Context) {
 				continue
 			}
 
-			m.mu.RLock()
-			channel, exists := m.channels[msg.Channel]
-			m.mu.RUnlock()
-
-			if !exists {
-				logger.WarnCF("channels", "Unknown channel for outbound message", map[string]any{
-					"channel": msg.Channel,
-				})
-				continue
-			}
-
-			if err := channel.Send(ctx, msg); err != nil {
-				logger.ErrorCF("channels", "Error sending message to channel", map[string]any{
+			if err := m.outbox.Enqueue(msg); err != nil {
+				logger.ErrorCF("channels", "Failed to persist outbound message", map[string]any{
 					"channel": msg.Channel,
 					"error":   err.Error(),
 				})
@@ -308,6 +461,38 @@ func (m *Manager) dispatchOutbound(ctx context.Context) {
 	}
 }
 
+// deliver sends one outbound message to its channel.
+func (m *Manager) deliver(ctx context.Context, msg bus.OutboundMessage) error {
+	m.mu.RLock()
+	channel, exists := m.channels[msg.Channel]
+	m.mu.RUnlock()
+
+	if !exists {
+		return fmt.Errorf("channel %s not found", msg.Channel)
+	}
+
+	if mc, ok := channel.(MediaChannel); !ok || !mc.SupportsMedia() {
+		msg = mediaAsText(msg)
+	}
+	if ic, ok := channel.(InteractiveChannel); !ok || !ic.SupportsInteractive() {
+		msg = interactiveAsText(msg)
+	}
+
+	return channel.Send(ctx, msg)
+}
+
+// Outbox returns the queue of outbound messages.
+func (m *Manager) Outbox() *Outbox {
+	return m.outbox
+}
+
+func outboxOptions(cfg *config.Config) OutboxOptions {
+	return OutboxOptions{
+		MaxAttempts: cfg.Gateway.Outbox.MaxAttempts,
+		MaxBackoff:  time.Duration(cfg.Gateway.Outbox.MaxBackoff) * time.Second,
+	}
+}
+
 func (m *Manager) GetChannel(name string) (Channel, bool) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
@@ -350,6 +535,7 @@ func (m *Manager) UnregisterChannel(name string) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	delete(m.channels, name)
+	delete(m.applied, name)
 }
 
 func (m *Manager) SendToChannel(ctx context.Context, channelName, chatID, content string) error {
@@ -0,0 +1,74 @@
+package channels
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func maixcamConfig(enabled bool, port int) *config.Config {
+	cfg := config.DefaultConfig()
+	cfg.Channels.MaixCam.Enabled = enabled
+	cfg.Channels.MaixCam.Host = "127.0.0.1"
+	cfg.Channels.MaixCam.Port = port
+	return cfg
+}
+
+func TestManagerApplyConfig(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	m, err := NewManager(config.DefaultConfig(), bus.NewMessageBus())
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	defer m.StopAll(ctx)
+
+	// Enabling a channel starts it.
+	changed, err := m.ApplyConfig(ctx, maixcamConfig(true, 0))
+	if err != nil {
+		t.Fatalf("ApplyConfig failed: %v", err)
+	}
+	if len(changed) != 1 || changed[0] != "maixcam" {
+		t.Fatalf("expected maixcam to change, got %v", changed)
+	}
+	first, ok := m.GetChannel("maixcam")
+	if !ok || !first.IsRunning() {
+		t.Fatal("expected maixcam to be running")
+	}
+
+	// Identical config leaves the running instance alone.
+	changed, err = m.ApplyConfig(ctx, maixcamConfig(true, 0))
+	if err != nil || len(changed) != 0 {
+		t.Fatalf("expected no changes, got %v (err=%v)", changed, err)
+	}
+	if same, _ := m.GetChannel("maixcam"); same != first {
+		t.Error("expected unchanged channel to keep its instance")
+	}
+
+	// A config that fails to start rolls back to the previous one.
+	changed, err = m.ApplyConfig(ctx, maixcamConfig(true, 70000))
+	if err == nil {
+		t.Fatal("expected error for unstartable channel")
+	}
+	if len(changed) != 1 {
+		t.Errorf("expected maixcam to be reported as changed, got %v", changed)
+	}
+	restored, ok := m.GetChannel("maixcam")
+	if !ok || !restored.IsRunning() || restored == first {
+		t.Fatal("expected maixcam to be restored with a fresh instance")
+	}
+
+	// Disabling stops and removes the channel.
+	if _, err = m.ApplyConfig(ctx, maixcamConfig(false, 0)); err != nil {
+		t.Fatalf("ApplyConfig failed: %v", err)
+	}
+	if _, ok := m.GetChannel("maixcam"); ok {
+		t.Error("expected maixcam to be removed")
+	}
+	if restored.IsRunning() {
+		t.Error("expected removed channel to be stopped")
+	}
+}
@@ -0,0 +1,479 @@
+package channels
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+const (
+	mattermostReconnectDelay = 5 * time.Second
+	mattermostFilesPerPost   = 5
+)
+
+// mattermostFormat is Mattermost's Markdown, in posts of at most 16383
+// characters.
+var mattermostFormat = MessageFormat{Dialect: DialectMarkdown, MaxLen: 16000}
+
+// mattermostMediaLimits is Mattermost's default maximum file size.
+var mattermostMediaLimits = mediaLimits{bus.MediaFile: 100 << 20}
+
+// MattermostChannel receives posts from the Mattermost WebSocket events
+// API and replies through the REST API, as a bot account.
+//
+// Chat IDs are channel IDs, with "/" and the root post ID appended for
+// threads, so each thread has its own session.
+type MattermostChannel struct {
+	*BaseChannel
+	config      config.MattermostConfig
+	baseURL     string
+	client      *http.Client
+	botUserID   string
+	botUsername string
+	ctx         context.Context
+	cancel      context.CancelFunc
+	mu          sync.Mutex
+	conn        *websocket.Conn
+}
+
+type mattermostEvent struct {
+	Event string `json:"event"`
+	Data  struct {
+		ChannelType string `json:"channel_type"`
+		TeamID      string `json:"team_id"`
+		SenderName  string `json:"sender_name"`
+		Post        string `json:"post"`     // JSON-encoded mattermostPost
+		Mentions    string `json:"mentions"` // JSON-encoded user IDs
+	} `json:"data"`
+}
+
+type mattermostPost struct {
+	ID        string   `json:"id"`
+	UserID    string   `json:"user_id"`
+	ChannelID string   `json:"channel_id"`
+	RootID    string   `json:"root_id"`
+	Message   string   `json:"message"`
+	Type      string   `json:"type"`
+	FileIDs   []string `json:"file_ids"`
+	Metadata  struct {
+		Files []mattermostFileInfo `json:"files"`
+	} `json:"metadata"`
+}
+
+type mattermostFileInfo struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	MimeType string `json:"mime_type"`
+}
+
+func NewMattermostChannel(cfg config.MattermostConfig, messageBus *bus.MessageBus) (*MattermostChannel, error) {
+	if cfg.URL == "" || cfg.Token == "" {
+		return nil, fmt.Errorf("mattermost url and token are required")
+	}
+
+	base := NewBaseChannel("mattermost", cfg, messageBus, cfg.AllowFrom)
+	base.setGroupPolicy(cfg.Groups, mentionOnlyDefaults(cfg.MentionOnly))
+
+	return &MattermostChannel{
+		BaseChannel: base,
+		config:      cfg,
+		baseURL:     strings.TrimRight(cfg.URL, "/"),
+		client:      &http.Client{Timeout: 60 * time.Second},
+	}, nil
+}
+
+func (c *MattermostChannel) Start(ctx context.Context) error {
+	logger.InfoC("mattermost", "Starting Mattermost channel")
+
+	c.ctx, c.cancel = context.WithCancel(ctx)
+
+	var me struct {
+		ID       string `json:"id"`
+		Username string `json:"username"`
+	}
+	if err := c.api(c.ctx, http.MethodGet, "/users/me", nil, &me); err != nil {
+		c.cancel()
+		return fmt.Errorf("mattermost authentication failed: %w", err)
+	}
+	c.botUserID = me.ID
+	c.botUsername = me.Username
+
+	logger.InfoCF("mattermost", "Mattermost bot connected", map[string]any{
+		"bot_user_id": c.botUserID,
+		"username":    c.botUsername,
+	})
+
+	go c.listen()
+
+	c.setRunning(true)
+	logger.InfoC("mattermost", "Mattermost channel started")
+	return nil
+}
+
+func (c *MattermostChannel) Stop(ctx context.Context) error {
+	logger.InfoC("mattermost", "Stopping Mattermost channel")
+
+	if c.cancel != nil {
+		c.cancel()
+	}
+
+	c.mu.Lock()
+	if c.conn != nil {
+		c.conn.Close()
+		c.conn = nil
+	}
+	c.mu.Unlock()
+
+	c.setRunning(false)
+	logger.InfoC("mattermost", "Mattermost channel stopped")
+	return nil
+}
+
+func (c *MattermostChannel) SupportsMedia() bool {
+	return true
+}
+
+// listen reads the event stream, reconnecting until the channel stops.
+func (c *MattermostChannel) listen() {
+	for {
+		err := c.readEvents()
+		if c.ctx.Err() != nil {
+			return
+		}
+		logger.WarnCF("mattermost", "WebSocket closed, reconnecting", map[string]any{
+			"error": fmt.Sprint(err),
+		})
+
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-time.After(mattermostReconnectDelay):
+		}
+	}
+}
+
+func (c *MattermostChannel) readEvents() error {
+	wsURL := "ws" + strings.TrimPrefix(c.baseURL, "http") + "/api/v4/websocket"
+	dialer := websocket.Dialer{HandshakeTimeout: 10 * time.Second}
+	header := http.Header{"Authorization": []string{"Bearer " + c.config.Token}}
+
+	conn, _, err := dialer.DialContext(c.ctx, wsURL, header)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.conn = conn
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		if c.conn == conn {
+			c.conn = nil
+		}
+		c.mu.Unlock()
+		conn.Close()
+	}()
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		var ev mattermostEvent
+		if err := json.Unmarshal(data, &ev); err != nil || ev.Event != "posted" {
+			continue
+		}
+		c.handlePosted(ev)
+	}
+}
+
+func (c *MattermostChannel) handlePosted(ev mattermostEvent) {
+	var post mattermostPost
+	if err := json.Unmarshal([]byte(ev.Data.Post), &post); err != nil {
+		logger.WarnCF("mattermost", "Failed to parse post", map[string]any{"error": err.Error()})
+		return
+	}
+	// System posts (joins, header changes, ...) have a type.
+	if post.UserID == c.botUserID || post.Type != "" {
+		return
+	}
+
+	username := strings.TrimPrefix(ev.Data.SenderName, "@")
+	senderID := post.UserID
+	if username != "" {
+		senderID += "|" + username
+	}
+	if !c.IsAllowed(senderID) {
+		logger.DebugCF("mattermost", "Message rejected by allowlist", map[string]any{"sender_id": senderID})
+		return
+	}
+
+	isDM := ev.Data.ChannelType == "D"
+	chatID := post.ChannelID
+	peerKind := "channel"
+	peerID := post.ChannelID
+	switch ev.Data.ChannelType {
+	case "D":
+		peerKind = "direct"
+		peerID = post.UserID
+	case "G":
+		peerKind = "group"
+	}
+
+	metadata := map[string]string{
+		"message_id": post.ID,
+		"channel_id": post.ChannelID,
+		"root_id":    post.RootID,
+		"user_name":  username,
+		"platform":   "mattermost",
+		"peer_kind":  peerKind,
+		"peer_id":    peerID,
+		"team_id":    ev.Data.TeamID,
+	}
+	rootID := post.RootID
+	if rootID != "" {
+		// Each thread is its own session; bindings on the channel still
+		// apply through the parent peer.
+		chatID = post.ChannelID + "/" + rootID
+		if !isDM {
+			metadata["peer_id"] = chatID
+			metadata["parent_peer_kind"] = peerKind
+			metadata["parent_peer_id"] = post.ChannelID
+		}
+	}
+
+	content := c.stripBotMention(post.Message)
+	if !isDM {
+		var thread, ok bool
+		content, thread, ok = c.admitGroupMessage(senderID, chatID, content, c.isMentioned(ev, post), metadata)
+		if !ok {
+			return
+		}
+		if thread && rootID == "" {
+			// Start a thread on the message, as its own session.
+			rootID = post.ID
+			chatID = post.ChannelID + "/" + rootID
+			metadata["root_id"] = rootID
+			metadata["peer_id"] = chatID
+			metadata["parent_peer_kind"] = peerKind
+			metadata["parent_peer_id"] = post.ChannelID
+		}
+	}
+
+	var mediaPaths []string
+	for _, file := range post.Metadata.Files {
+		path := utils.DownloadFile(c.baseURL+"/api/v4/files/"+file.ID, file.Name, utils.DownloadOptions{
+			ExtraHeaders: map[string]string{"Authorization": "Bearer " + c.config.Token},
+			LoggerPrefix: "mattermost",
+		})
+		if path == "" {
+			continue
+		}
+		mediaPaths = append(mediaPaths, path)
+		content = appendContent(content, fmt.Sprintf("[file: %s]", file.Name))
+	}
+
+	if strings.TrimSpace(content) == "" {
+		return
+	}
+
+	go c.sendTyping(post.ChannelID, rootID)
+
+	logger.DebugCF("mattermost", "Received message", map[string]any{
+		"sender_id": senderID,
+		"chat_id":   chatID,
+		"preview":   utils.Truncate(content, 50),
+	})
+
+	c.HandleMessage(senderID, chatID, content, mediaPaths, metadata)
+}
+
+func (c *MattermostChannel) isMentioned(ev mattermostEvent, post mattermostPost) bool {
+	var mentions []string
+	if ev.Data.Mentions != "" {
+		json.Unmarshal([]byte(ev.Data.Mentions), &mentions)
+	}
+	return slices.Contains(mentions, c.botUserID) ||
+		(c.botUsername != "" && strings.Contains(post.Message, "@"+c.botUsername))
+}
+
+func (c *MattermostChannel) stripBotMention(text string) string {
+	if c.botUsername == "" {
+		return strings.TrimSpace(text)
+	}
+	return strings.TrimSpace(strings.ReplaceAll(text, "@"+c.botUsername, ""))
+}
+
+func (c *MattermostChannel) sendTyping(channelID, parentID string) {
+	ctx, cancel := context.WithTimeout(c.ctx, 10*time.Second)
+	defer cancel()
+	body := map[string]string{"channel_id": channelID, "parent_id": parentID}
+	if err := c.api(ctx, http.MethodPost, "/users/"+c.botUserID+"/typing", body, nil); err != nil {
+		logger.DebugCF("mattermost", "Failed to send typing indicator", map[string]any{"error": err.Error()})
+	}
+}
+
+func (c *MattermostChannel) Send(ctx context.Context, msg bus.OutboundMessage) error {
+	if !c.IsRunning() {
+		return fmt.Errorf("mattermost channel not running")
+	}
+
+	channelID, rootID, _ := strings.Cut(msg.ChatID, "/")
+	if channelID == "" {
+		return fmt.Errorf("invalid mattermost chat ID: %s", msg.ChatID)
+	}
+
+	files, notices := mattermostMediaLimits.prepare(msg.Media)
+	content := appendNotices(msg.Content, notices)
+
+	var fileIDs []string
+	for _, f := range files {
+		id, err := c.uploadFile(ctx, channelID, f)
+		if err != nil {
+			return err
+		}
+		fileIDs = append(fileIDs, id)
+	}
+
+	// Files go with the last text post, a few per post.
+	chunks := []string{""}
+	if content != "" {
+		chunks = mattermostFormat.Render(content)
+	}
+	for i, chunk := range chunks {
+		post := map[string]any{"channel_id": channelID, "root_id": rootID, "message": chunk}
+		if i == len(chunks)-1 && len(fileIDs) > 0 {
+			n := min(len(fileIDs), mattermostFilesPerPost)
+			post["file_ids"] = fileIDs[:n]
+			fileIDs = fileIDs[n:]
+		}
+		if err := c.api(ctx, http.MethodPost, "/posts", post, nil); err != nil {
+			return err
+		}
+	}
+	for len(fileIDs) > 0 {
+		n := min(len(fileIDs), mattermostFilesPerPost)
+		post := map[string]any{"channel_id": channelID, "root_id": rootID, "file_ids": fileIDs[:n]}
+		if err := c.api(ctx, http.MethodPost, "/posts", post, nil); err != nil {
+			return err
+		}
+		fileIDs = fileIDs[n:]
+	}
+
+	logger.DebugCF("mattermost", "Message sent", map[string]any{
+		"channel_id": channelID,
+		"root_id":    rootID,
+	})
+	return nil
+}
+
+// uploadFile uploads f to channelID and returns its file ID.
+func (c *MattermostChannel) uploadFile(ctx context.Context, channelID string, f outboundFile) (string, error) {
+	r, err := f.Open()
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %w", f.Name(), err)
+	}
+	defer r.Close()
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("channel_id", channelID)
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="files"; filename=%q`, f.Name()))
+	header.Set("Content-Type", f.ContentType())
+	part, err := mw.CreatePart(header)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(part, r); err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", f.Name(), err)
+	}
+	if err := mw.Close(); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/api/v4/files", &body)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+
+	var result struct {
+		FileInfos []mattermostFileInfo `json:"file_infos"`
+	}
+	if err := c.do(req, &result); err != nil {
+		return "", fmt.Errorf("failed to upload %s: %w", f.Name(), err)
+	}
+	if len(result.FileInfos) == 0 {
+		return "", fmt.Errorf("failed to upload %s: no file info returned", f.Name())
+	}
+	return result.FileInfos[0].ID, nil
+}
+
+// api calls a REST API v4 endpoint with a JSON body, decoding the response
+// into result if not nil.
+func (c *MattermostChannel) api(ctx context.Context, method, path string, payload, result any) error {
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("failed to marshal payload: %w", err)
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+"/api/v4"+path, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return c.do(req, result)
+}
+
+func (c *MattermostChannel) do(req *http.Request, result any) error {
+	req.Header.Set("Authorization", "Bearer "+c.config.Token)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("API request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(respBody, &apiErr) == nil && apiErr.Message != "" {
+			return fmt.Errorf("mattermost API error (status %d): %s", resp.StatusCode, apiErr.Message)
+		}
+		return fmt.Errorf("mattermost API error (status %d): %s", resp.StatusCode, string(respBody))
+	}
+
+	if result != nil {
+		if err := json.Unmarshal(respBody, result); err != nil {
+			return fmt.Errorf("failed to parse response: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package channels
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// fakeChatServer serves a chat platform's REST API from routes and pushes
+// events to WebSocket clients, recording JSON request bodies.
+type fakeChatServer struct {
+	*httptest.Server
+	events   chan any
+	mu       sync.Mutex
+	requests map[string][]map[string]any
+}
+
+func newFakeChatServer(
+	t *testing.T,
+	wsPath string,
+	routes map[string]string,
+	onConnect func(*websocket.Conn),
+) *fakeChatServer {
+	f := &fakeChatServer{events: make(chan any, 10), requests: make(map[string][]map[string]any)}
+	upgrader := websocket.Upgrader{}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == wsPath {
+			conn, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			if onConnect != nil {
+				onConnect(conn)
+			}
+			go func() {
+				for {
+					if _, _, err := conn.ReadMessage(); err != nil {
+						return
+					}
+				}
+			}()
+			for ev := range f.events {
+				if conn.WriteJSON(ev) != nil {
+					return
+				}
+			}
+			return
+		}
+
+		var body map[string]any
+		data, _ := io.ReadAll(r.Body)
+		json.Unmarshal(data, &body)
+		f.mu.Lock()
+		f.requests[r.URL.Path] = append(f.requests[r.URL.Path], body)
+		f.mu.Unlock()
+		if resp, ok := routes[r.URL.Path]; ok {
+			io.WriteString(w, resp)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	t.Cleanup(func() {
+		close(f.events)
+		f.Close()
+	})
+	return f
+}
+
+func (f *fakeChatServer) requestsTo(path string) []map[string]any {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requests[path]
+}
+
+func TestMattermostChannel(t *testing.T) {
+	f := newFakeChatServer(t, "/api/v4/websocket", map[string]string{
+		"/api/v4/users/me":          `{"id":"bot1","username":"picoclaw"}`,
+		"/api/v4/posts":             `{"id":"p9"}`,
+		"/api/v4/users/bot1/typing": `{}`,
+	}, nil)
+
+	mb := bus.NewMessageBus()
+	c, err := NewMattermostChannel(config.MattermostConfig{URL: f.URL, Token: "t", MentionOnly: true}, mb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop(context.Background())
+
+	posted := func(channelType, message, rootID, mentions string) map[string]any {
+		post, _ := json.Marshal(map[string]any{
+			"id": "p1", "user_id": "u1", "channel_id": "c1", "root_id": rootID, "message": message,
+		})
+		return map[string]any{"event": "posted", "data": map[string]any{
+			"channel_type": channelType, "team_id": "team1", "sender_name": "@alice",
+			"post": string(post), "mentions": mentions,
+		}}
+	}
+	f.events <- posted("O", "not for the bot", "", "")
+	f.events <- posted("O", "@picoclaw summarize this", "root1", `["bot1"]`)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	msg, ok := mb.ConsumeInbound(ctx)
+	if !ok {
+		t.Fatal("no inbound message")
+	}
+	if msg.SenderID != "u1|alice" || msg.ChatID != "c1/root1" || msg.Content != "summarize this" {
+		t.Errorf("inbound = %+v", msg)
+	}
+	md := msg.Metadata
+	if md["team_id"] != "team1" || md["peer_kind"] != "channel" || md["peer_id"] != "c1/root1" ||
+		md["parent_peer_id"] != "c1" {
+		t.Errorf("metadata = %v", md)
+	}
+
+	if err := c.Send(context.Background(), bus.OutboundMessage{ChatID: "c1/root1", Content: "**Done**"}); err != nil {
+		t.Fatal(err)
+	}
+	posts := f.requestsTo("/api/v4/posts")
+	if len(posts) != 1 || posts[0]["channel_id"] != "c1" || posts[0]["root_id"] != "root1" ||
+		posts[0]["message"] != "**Done**" {
+		t.Errorf("posts = %v", posts)
+	}
+}
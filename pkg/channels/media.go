@@ -0,0 +1,96 @@
+package channels
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+)
+
+// MediaChannel is implemented by channels that upload outbound attachments
+// through their platform's API. For other channels the manager replaces the
+// attachments with a note listing their names.
+type MediaChannel interface {
+	SupportsMedia() bool
+}
+
+// mediaLimits maps a media kind to the largest upload a platform accepts
+// for it, in bytes. Kinds without an entry use the bus.MediaFile limit.
+type mediaLimits map[bus.MediaKind]int64
+
+func (l mediaLimits) limit(kind bus.MediaKind) int64 {
+	if n, ok := l[kind]; ok {
+		return n
+	}
+	return l[bus.MediaFile]
+}
+
+// outboundFile is an attachment ready for upload, with the kind it is sent
+// as. An image over the image limit but within the file limit is sent as a
+// plain file, for example.
+type outboundFile struct {
+	bus.OutboundMedia
+	kind bus.MediaKind
+	size int64
+}
+
+// prepare checks the attachments against the limits. It returns the ones to
+// upload and one notice for each that cannot be sent, to be delivered as
+// text in their place.
+func (l mediaLimits) prepare(media []bus.OutboundMedia) ([]outboundFile, []string) {
+	var files []outboundFile
+	var notices []string
+	for _, m := range media {
+		size, err := m.Size()
+		if err != nil {
+			notices = append(notices, fmt.Sprintf("(%s was not sent: %v)", m.Name(), err))
+			continue
+		}
+		kind := m.Kind()
+		if size > l.limit(kind) && kind != bus.MediaFile && size <= l.limit(bus.MediaFile) {
+			kind = bus.MediaFile
+		}
+		if limit := l.limit(kind); size > limit {
+			notices = append(notices, fmt.Sprintf("(%s was not sent: %s is over the %s limit)",
+				m.Name(), formatSize(size), formatSize(limit)))
+			continue
+		}
+		files = append(files, outboundFile{OutboundMedia: m, kind: kind, size: size})
+	}
+	return files, notices
+}
+
+// appendNotices adds the notices to content, each on its own line.
+func appendNotices(content string, notices []string) string {
+	for _, n := range notices {
+		content = appendContent(content, n)
+	}
+	return content
+}
+
+// mediaAsText replaces the attachments of msg with a note naming them, for
+// channels that cannot upload files.
+func mediaAsText(msg bus.OutboundMessage) bus.OutboundMessage {
+	if len(msg.Media) == 0 {
+		return msg
+	}
+	names := make([]string, len(msg.Media))
+	for i, m := range msg.Media {
+		names[i] = m.Name()
+	}
+	msg.Content = appendContent(msg.Content,
+		fmt.Sprintf("(attachments not supported on this channel: %s)", strings.Join(names, ", ")))
+	msg.Media = nil
+	return msg
+}
+
+func formatSize(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
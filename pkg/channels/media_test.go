@@ -0,0 +1,169 @@
+package channels
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestMediaLimitsPrepare(t *testing.T) {
+	dir := t.TempDir()
+	bigPNG := filepath.Join(dir, "big.png")
+	if err := os.WriteFile(bigPNG, make([]byte, 3000), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	limits := mediaLimits{bus.MediaImage: 1000, bus.MediaFile: 5000}
+	files, notices := limits.prepare([]bus.OutboundMedia{
+		{Data: []byte("hello"), Filename: "note.txt"},
+		{Path: bigPNG},
+		{Data: make([]byte, 6000), Filename: "huge.zip"},
+		{Path: filepath.Join(dir, "missing.pdf")},
+	})
+
+	if len(files) != 2 {
+		t.Fatalf("files = %+v, want 2", files)
+	}
+	if files[0].kind != bus.MediaFile || files[0].size != 5 {
+		t.Errorf("note.txt prepared as %s/%d", files[0].kind, files[0].size)
+	}
+	if files[1].kind != bus.MediaFile {
+		t.Errorf("oversized image kind = %s, want it sent as a file", files[1].kind)
+	}
+	if len(notices) != 2 ||
+		!strings.Contains(notices[0], "huge.zip was not sent: 5.9 KB is over the 4.9 KB limit") ||
+		!strings.Contains(notices[1], "missing.pdf was not sent") {
+		t.Errorf("notices = %q", notices)
+	}
+}
+
+func TestMediaAsText(t *testing.T) {
+	msg := mediaAsText(bus.OutboundMessage{
+		Content: "Here is the report",
+		Media:   []bus.OutboundMedia{{Path: "/tmp/report.pdf"}, {Data: []byte("x"), MIMEType: "image/png"}},
+	})
+	if msg.Media != nil {
+		t.Error("expected media to be dropped")
+	}
+	want := "Here is the report\n(attachments not supported on this channel: report.pdf, file.png)"
+	if msg.Content != want {
+		t.Errorf("content = %q, want %q", msg.Content, want)
+	}
+}
+
+func TestWeComWebhookUploadURL(t *testing.T) {
+	got, err := wecomWebhookUploadURL("https://qyapi.weixin.qq.com/cgi-bin/webhook/send?key=abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "https://qyapi.weixin.qq.com/cgi-bin/webhook/upload_media?key=abc&type=file" {
+		t.Errorf("upload URL = %s", got)
+	}
+	if _, err := wecomWebhookUploadURL("https://qyapi.weixin.qq.com/cgi-bin/webhook/send"); err == nil {
+		t.Error("expected an error for a URL without key")
+	}
+}
+
+func TestOneBotBuildSendRequest_Media(t *testing.T) {
+	c, _ := NewOneBotChannel(config.OneBotConfig{}, bus.NewMessageBus())
+	files, _ := oneBotMediaLimits.prepare([]bus.OutboundMedia{
+		{Data: []byte("img"), Filename: "a.png", Caption: "chart"},
+		{Data: []byte("ogg"), Filename: "b.ogg"},
+	})
+
+	action, params, err := c.buildSendRequest(bus.OutboundMessage{ChatID: "group:42"}, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if action != "send_group_msg" {
+		t.Errorf("action = %s", action)
+	}
+	segments := params.(map[string]any)["message"].([]oneBotMessageSegment)
+	var types []string
+	for _, s := range segments {
+		types = append(types, s.Type)
+	}
+	if got := strings.Join(types, ","); got != "text,image,record" {
+		t.Errorf("segments = %s, want text,image,record", got)
+	}
+	if file := segments[1].Data["file"]; file != "base64://aW1n" {
+		t.Errorf("image file = %v", file)
+	}
+}
+
+func TestLINEServeMedia(t *testing.T) {
+	c, _ := NewLINEChannel(config.LINEConfig{
+		ChannelSecret:      "secret",
+		ChannelAccessToken: "token",
+		PublicURL:          "https://bot.example.com/",
+	}, bus.NewMessageBus())
+
+	fileURL := c.serveMedia(bus.OutboundMedia{Data: []byte("report"), Filename: "q3 report.txt"})
+	path, ok := strings.CutPrefix(fileURL, "https://bot.example.com"+lineMediaPath)
+	if !ok || !strings.HasSuffix(path, "/q3%20report.txt") {
+		t.Fatalf("URL = %s", fileURL)
+	}
+
+	rec := httptest.NewRecorder()
+	c.mediaHandler(rec, httptest.NewRequest("GET", lineMediaPath+path, nil))
+	body, _ := io.ReadAll(rec.Result().Body)
+	if rec.Code != 200 || string(body) != "report" {
+		t.Errorf("served %d %q", rec.Code, body)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("Content-Type = %s", ct)
+	}
+
+	rec = httptest.NewRecorder()
+	c.mediaHandler(rec, httptest.NewRequest("GET", lineMediaPath+"unknown/x", nil))
+	if rec.Code != 404 {
+		t.Errorf("unknown token status = %d, want 404", rec.Code)
+	}
+}
+
+func TestManagerSendsMediaAsTextToPlainChannels(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cfg := config.DefaultConfig()
+	cfg.Agents.Defaults.Workspace = t.TempDir()
+	msgBus := bus.NewMessageBus()
+	m, err := NewManager(cfg, msgBus)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain := &recordingChannel{sent: make(chan bus.OutboundMessage, 1)}
+	m.RegisterChannel("plain", plain)
+	go m.dispatchOutbound(ctx)
+
+	msgBus.PublishOutbound(bus.OutboundMessage{
+		Channel: "plain",
+		ChatID:  "1",
+		Media:   []bus.OutboundMedia{{Path: "/tmp/a.pdf"}},
+	})
+	got := <-plain.sent
+	if got.Media != nil || got.Content != "(attachments not supported on this channel: a.pdf)" {
+		t.Errorf("sent = %+v", got)
+	}
+}
+
+type recordingChannel struct {
+	sent chan bus.OutboundMessage
+}
+
+func (c *recordingChannel) Name() string                    { return "plain" }
+func (c *recordingChannel) Start(ctx context.Context) error { return nil }
+func (c *recordingChannel) Stop(ctx context.Context) error  { return nil }
+func (c *recordingChannel) IsRunning() bool                 { return true }
+func (c *recordingChannel) IsAllowed(senderID string) bool  { return true }
+func (c *recordingChannel) Send(ctx context.Context, msg bus.OutboundMessage) error {
+	c.sent <- msg
+	return nil
+}
@@ -2,6 +2,7 @@ package channels
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -99,6 +100,10 @@ type oneBotMessageSegment struct {
 
 func NewOneBotChannel(cfg config.OneBotConfig, messageBus *bus.MessageBus) (*OneBotChannel, error) {
 	base := NewBaseChannel("onebot", cfg, messageBus, cfg.AllowFrom)
+	base.setGroupPolicy(cfg.Groups, config.GroupPolicy{
+		Trigger:  config.GroupTriggerMention,
+		Prefixes: cfg.GroupTriggerPrefix,
+	})
 
 	const dedupSize = 1024
 	return &OneBotChannel{
@@ -382,11 +387,57 @@ func (c *OneBotChannel) Send(ctx context.Context, msg bus.OutboundMessage) error
 		return fmt.Errorf("OneBot WebSocket not connected")
 	}
 
-	action, params, err := c.buildSendRequest(msg)
+	files, notices := oneBotMediaLimits.prepare(msg.Media)
+	msg.Content = appendNotices(msg.Content, notices)
+
+	// Images, voice and video go inline in the message; other files are
+	// uploaded to the chat's file area afterwards.
+	var inline, uploads []outboundFile
+	for _, f := range files {
+		if f.kind == bus.MediaFile {
+			uploads = append(uploads, f)
+		} else {
+			inline = append(inline, f)
+		}
+	}
+
+	if msg.Content == "" && len(inline) == 0 && len(uploads) > 0 {
+		return c.uploadFiles(msg.ChatID, uploads)
+	}
+
+	// Long text goes out in several messages, the inline media with the
+	// last one.
+	chunks := oneBotFormat.Render(msg.Content)
+	for _, chunk := range chunks[:len(chunks)-1] {
+		action, params, err := c.buildSendRequest(bus.OutboundMessage{ChatID: msg.ChatID, Content: chunk}, nil)
+		if err != nil {
+			return err
+		}
+		if err := c.writeRequest(conn, action, params); err != nil {
+			return err
+		}
+	}
+	msg.Content = chunks[len(chunks)-1]
+
+	action, params, err := c.buildSendRequest(msg, inline)
 	if err != nil {
 		return err
 	}
+	if err := c.writeRequest(conn, action, params); err != nil {
+		return err
+	}
+
+	if msgID, ok := c.pendingEmojiMsg.LoadAndDelete(msg.ChatID); ok {
+		if mid, ok := msgID.(string); ok && mid != "" {
+			c.setMsgEmojiLike(mid, 289, false)
+		}
+	}
+
+	return c.uploadFiles(msg.ChatID, uploads)
+}
 
+// writeRequest sends an API request without waiting for its response.
+func (c *OneBotChannel) writeRequest(conn *websocket.Conn, action string, params any) error {
 	echo := fmt.Sprintf("send_%d", atomic.AddInt64(&c.echoCounter, 1))
 
 	req := oneBotAPIRequest{
@@ -410,13 +461,58 @@ func (c *OneBotChannel) Send(ctx context.Context, msg bus.OutboundMessage) error
 		})
 		return err
 	}
+	return nil
+}
 
-	if msgID, ok := c.pendingEmojiMsg.LoadAndDelete(msg.ChatID); ok {
-		if mid, ok := msgID.(string); ok && mid != "" {
-			c.setMsgEmojiLike(mid, 289, false)
-		}
+func (c *OneBotChannel) SupportsMedia() bool {
+	return true
+}
+
+// oneBotFormat is plain text, as QQ does not render Markdown, in messages
+// short enough for QQ to show without folding them.
+var oneBotFormat = MessageFormat{Dialect: DialectPlain, MaxLen: 4000}
+
+// oneBotMediaLimits keep base64 payloads on the WebSocket reasonable; QQ
+// itself accepts images up to 30 MB.
+var oneBotMediaLimits = mediaLimits{
+	bus.MediaImage: 30 << 20,
+	bus.MediaFile:  50 << 20,
+}
+
+// oneBotFileSegment returns the base64:// file reference OneBot accepts in
+// media segments and file uploads.
+func oneBotFileSegment(f outboundFile) (string, error) {
+	data, err := f.ReadAll()
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", f.Name(), err)
+	}
+	return "base64://" + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// uploadFiles uploads files with the upload_group_file or
+// upload_private_file actions.
+func (c *OneBotChannel) uploadFiles(chatID string, files []outboundFile) error {
+	if len(files) == 0 {
+		return nil
+	}
+	isGroup, id, err := parseOneBotChatID(chatID)
+	if err != nil {
+		return err
 	}
 
+	for _, f := range files {
+		file, err := oneBotFileSegment(f)
+		if err != nil {
+			return err
+		}
+		action, params := "upload_private_file", map[string]any{"user_id": id, "file": file, "name": f.Name()}
+		if isGroup {
+			action, params = "upload_group_file", map[string]any{"group_id": id, "file": file, "name": f.Name()}
+		}
+		if _, err := c.sendAPIRequest(action, params, 2*time.Minute); err != nil {
+			return fmt.Errorf("failed to upload %s: %w", f.Name(), err)
+		}
+	}
 	return nil
 }
 
@@ -432,33 +528,79 @@ func (c *OneBotChannel) buildMessageSegments(chatID, content string) []oneBotMes
 		}
 	}
 
-	segments = append(segments, oneBotMessageSegment{
-		Type: "text",
-		Data: map[string]any{"text": content},
-	})
+	if content != "" {
+		segments = append(segments, oneBotMessageSegment{
+			Type: "text",
+			Data: map[string]any{"text": content},
+		})
+	}
 
 	return segments
 }
 
-func (c *OneBotChannel) buildSendRequest(msg bus.OutboundMessage) (string, any, error) {
-	chatID := msg.ChatID
-	segments := c.buildMessageSegments(chatID, msg.Content)
+// buildMediaSegments returns image, record (voice) and video segments for
+// files, each preceded by its caption.
+func buildMediaSegments(files []outboundFile) ([]oneBotMessageSegment, error) {
+	var segments []oneBotMessageSegment
+	for _, f := range files {
+		file, err := oneBotFileSegment(f)
+		if err != nil {
+			return nil, err
+		}
+		if f.Caption != "" {
+			segments = append(segments, oneBotMessageSegment{
+				Type: "text",
+				Data: map[string]any{"text": "\n" + f.Caption + "\n"},
+			})
+		}
+		segType := "image"
+		switch f.kind {
+		case bus.MediaAudio:
+			segType = "record"
+		case bus.MediaVideo:
+			segType = "video"
+		}
+		segments = append(segments, oneBotMessageSegment{
+			Type: segType,
+			Data: map[string]any{"file": file},
+		})
+	}
+	return segments, nil
+}
 
-	var action, idKey string
-	var rawID string
+func parseOneBotChatID(chatID string) (isGroup bool, id int64, err error) {
+	idKey, rawID := "user_id", chatID
 	if rest, ok := strings.CutPrefix(chatID, "group:"); ok {
-		action, idKey, rawID = "send_group_msg", "group_id", rest
+		isGroup, idKey, rawID = true, "group_id", rest
 	} else if rest, ok := strings.CutPrefix(chatID, "private:"); ok {
-		action, idKey, rawID = "send_private_msg", "user_id", rest
-	} else {
-		action, idKey, rawID = "send_private_msg", "user_id", chatID
+		rawID = rest
 	}
 
-	id, err := strconv.ParseInt(rawID, 10, 64)
+	id, err = strconv.ParseInt(rawID, 10, 64)
 	if err != nil {
-		return "", nil, fmt.Errorf("invalid %s in chatID: %s", idKey, chatID)
+		return false, 0, fmt.Errorf("invalid %s in chatID: %s", idKey, chatID)
 	}
-	return action, map[string]any{idKey: id, "message": segments}, nil
+	return isGroup, id, nil
+}
+
+func (c *OneBotChannel) buildSendRequest(msg bus.OutboundMessage, media []outboundFile) (string, any, error) {
+	chatID := msg.ChatID
+	segments := c.buildMessageSegments(chatID, msg.Content)
+
+	mediaSegments, err := buildMediaSegments(media)
+	if err != nil {
+		return "", nil, err
+	}
+	segments = append(segments, mediaSegments...)
+
+	isGroup, id, err := parseOneBotChatID(chatID)
+	if err != nil {
+		return "", nil, err
+	}
+	if isGroup {
+		return "send_group_msg", map[string]any{"group_id": id, "message": segments}, nil
+	}
+	return "send_private_msg", map[string]any{"user_id": id, "message": segments}, nil
 }
 
 func (c *OneBotChannel) listen() {
@@ -887,17 +1029,11 @@ func (c *OneBotChannel) handleMessage(raw *oneBotRawEvent) {
 			metadata["sender_name"] = sender.Nickname
 		}
 
-		triggered, strippedContent := c.checkGroupTrigger(content, isBotMentioned)
-		if !triggered {
-			logger.DebugCF("onebot", "Group message ignored (no trigger)", map[string]any{
-				"sender":       senderID,
-				"group":        groupIDStr,
-				"is_mentioned": isBotMentioned,
-				"content":      truncate(content, 100),
-			})
+		var ok bool
+		if content, _, ok = c.admitGroupMessage(senderID, chatID, content, isBotMentioned, metadata); !ok {
 			return
 		}
-		content = strippedContent
+		content = strings.TrimSpace(content)
 
 	default:
 		logger.WarnCF("onebot", "Unknown message type, cannot route", map[string]any{
@@ -960,23 +1096,3 @@ func truncate(s string, n int) string {
 	}
 	return string(runes[:n]) + "..."
 }
-
-func (c *OneBotChannel) checkGroupTrigger(
-	content string,
-	isBotMentioned bool,
-) (triggered bool, strippedContent string) {
-	if isBotMentioned {
-		return true, strings.TrimSpace(content)
-	}
-
-	for _, prefix := range c.config.GroupTriggerPrefix {
-		if prefix == "" {
-			continue
-		}
-		if strings.HasPrefix(content, prefix) {
-			return true, strings.TrimSpace(strings.TrimPrefix(content, prefix))
-		}
-	}
-
-	return false, content
-}
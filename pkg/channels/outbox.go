@@ -0,0 +1,435 @@
+package channels
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/mymmrac/telego/telegoapi"
+	"github.com/slack-go/slack"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+const (
+	outboxPendingFile    = "pending.json"
+	outboxDeadLetterFile = "dead_letter.jsonl"
+
+	defaultOutboxMaxAttempts    = 12
+	defaultOutboxInitialBackoff = 2 * time.Second
+	defaultOutboxMaxBackoff     = 5 * time.Minute
+)
+
+// ErrPermanent marks a send error that retrying cannot fix, such as a
+// session that no longer exists. Channels wrap it with %w; the outbox then
+// gives up on the message at once instead of retrying it.
+var ErrPermanent = errors.New("permanent delivery failure")
+
+// OutboxEntry is an outbound message waiting for delivery, or one that
+// failed permanently and sits in the dead-letter log.
+type OutboxEntry struct {
+	ID          string              `json:"id"`
+	Message     bus.OutboundMessage `json:"message"`
+	CreatedAt   time.Time           `json:"created_at"`
+	Attempts    int                 `json:"attempts"`
+	NextAttempt time.Time           `json:"next_attempt,omitempty"`
+	LastError   string              `json:"last_error,omitempty"`
+	FailedAt    time.Time           `json:"failed_at,omitempty"`
+}
+
+// OutboxOptions control retries. Zero values use the defaults: 12
+// attempts, backing off from 2 seconds up to 5 minutes.
+type OutboxOptions struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+func (o OutboxOptions) withDefaults() OutboxOptions {
+	if o.MaxAttempts <= 0 {
+		o.MaxAttempts = defaultOutboxMaxAttempts
+	}
+	if o.InitialBackoff <= 0 {
+		o.InitialBackoff = defaultOutboxInitialBackoff
+	}
+	if o.MaxBackoff <= 0 {
+		o.MaxBackoff = defaultOutboxMaxBackoff
+	}
+	return o
+}
+
+// Outbox delivers outbound messages reliably. Pending messages are kept on
+// disk so they survive restarts. Each chat's messages go out in order: a
+// message is only sent once the one before it was delivered or given up on.
+// A failed send backs off only its chat, so a chat the bot cannot reach
+// does not hold up the others. Messages still failing after MaxAttempts,
+// or failing with an error that retrying cannot fix (see isPermanent), are
+// appended to the dead-letter log, from which they can be resent.
+type Outbox struct {
+	dir  string
+	send func(ctx context.Context, msg bus.OutboundMessage) error
+
+	mu       sync.Mutex
+	opts     OutboxOptions
+	queues   map[string][]*OutboxEntry // chat key -> pending entries, oldest first
+	order    []string                  // chat keys in first-enqueued order, for a stable pending.json
+	inflight map[string]bool           // chat keys with a send in progress
+	wake     chan struct{}
+	nowFunc  func() time.Time // for testing
+}
+
+// NewOutbox creates an outbox storing its files in dir and delivering with
+// send, and loads the messages left pending by a previous run.
+func NewOutbox(dir string, opts OutboxOptions, send func(ctx context.Context, msg bus.OutboundMessage) error) *Outbox {
+	o := &Outbox{
+		dir:      dir,
+		send:     send,
+		opts:     opts.withDefaults(),
+		queues:   make(map[string][]*OutboxEntry),
+		inflight: make(map[string]bool),
+		wake:     make(chan struct{}, 1),
+		nowFunc:  time.Now,
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, outboxPendingFile))
+	if err != nil {
+		return o
+	}
+	var entries []*OutboxEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		logger.WarnCF("channels", "Ignoring unreadable outbox", map[string]any{"error": err.Error()})
+		return o
+	}
+	for _, e := range entries {
+		o.push(e)
+	}
+	if len(entries) > 0 {
+		logger.InfoCF("channels", "Resuming outbox delivery", map[string]any{"pending": len(entries)})
+	}
+	return o
+}
+
+// SetOptions changes the retry options for subsequent failures.
+func (o *Outbox) SetOptions(opts OutboxOptions) {
+	o.mu.Lock()
+	o.opts = opts.withDefaults()
+	o.mu.Unlock()
+}
+
+// Enqueue queues msg for delivery.
+func (o *Outbox) Enqueue(msg bus.OutboundMessage) error {
+	o.mu.Lock()
+	o.push(&OutboxEntry{ID: newOutboxID(), Message: msg, CreatedAt: o.nowFunc()})
+	err := o.save()
+	o.mu.Unlock()
+
+	o.notify()
+	return err
+}
+
+// Run delivers queued messages until ctx is cancelled.
+func (o *Outbox) Run(ctx context.Context) {
+	for {
+		wait := o.dispatch(ctx)
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-o.wake:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// Pending returns the messages waiting for delivery.
+func (o *Outbox) Pending() []OutboxEntry {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var entries []OutboxEntry
+	for _, key := range o.order {
+		for _, e := range o.queues[key] {
+			entries = append(entries, *e)
+		}
+	}
+	return entries
+}
+
+// DeadLetters returns the messages that failed permanently, oldest first.
+func (o *Outbox) DeadLetters() ([]OutboxEntry, error) {
+	return readDeadLetters(filepath.Join(o.dir, outboxDeadLetterFile))
+}
+
+// Resend moves dead letters back into the queue with their attempts reset:
+// those with the given IDs, or all of them if ids is empty. It returns how
+// many were requeued.
+func (o *Outbox) Resend(ids []string) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	path := filepath.Join(o.dir, outboxDeadLetterFile)
+	dead, err := readDeadLetters(path)
+	if err != nil {
+		return 0, err
+	}
+
+	wanted := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+	var keep []OutboxEntry
+	n := 0
+	for _, e := range dead {
+		if len(ids) > 0 && !wanted[e.ID] {
+			keep = append(keep, e)
+			continue
+		}
+		e.Attempts, e.NextAttempt, e.FailedAt = 0, time.Time{}, time.Time{}
+		o.push(&e)
+		n++
+	}
+	if n == 0 {
+		return 0, nil
+	}
+
+	if err := o.save(); err != nil {
+		return 0, err
+	}
+	if err := writeDeadLetters(path, keep); err != nil {
+		return n, err
+	}
+	o.notify()
+	return n, nil
+}
+
+// dispatch starts a send for every chat whose next message is due and
+// returns how long to wait until the next one is.
+func (o *Outbox) dispatch(ctx context.Context) time.Duration {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	now := o.nowFunc()
+	wait := time.Hour
+	for _, key := range o.order {
+		queue := o.queues[key]
+		if len(queue) == 0 || o.inflight[key] {
+			continue
+		}
+		head := queue[0]
+		if d := head.NextAttempt.Sub(now); d > 0 {
+			wait = min(wait, d)
+			continue
+		}
+		o.inflight[key] = true
+		go o.deliver(ctx, key, head)
+	}
+	return wait
+}
+
+func (o *Outbox) deliver(ctx context.Context, key string, e *OutboxEntry) {
+	err := o.send(ctx, e.Message)
+	if err != nil && ctx.Err() != nil {
+		// Shutting down; the message stays pending for the next run.
+		o.mu.Lock()
+		delete(o.inflight, key)
+		o.mu.Unlock()
+		return
+	}
+
+	o.mu.Lock()
+	delete(o.inflight, key)
+	now := o.nowFunc()
+	if err == nil {
+		o.pop(key)
+	} else {
+		e.Attempts++
+		e.LastError = err.Error()
+		if e.Attempts >= o.opts.MaxAttempts || isPermanent(err) {
+			e.FailedAt = now
+			o.pop(key)
+			if derr := o.appendDeadLetter(e); derr != nil {
+				logger.ErrorCF("channels", "Failed to write dead letter", map[string]any{"error": derr.Error()})
+			}
+			logger.ErrorCF("channels", "Giving up on outbound message", map[string]any{
+				"id":       e.ID,
+				"channel":  e.Message.Channel,
+				"chat_id":  e.Message.ChatID,
+				"attempts": e.Attempts,
+				"error":    e.LastError,
+			})
+		} else {
+			delay := o.opts.InitialBackoff << (e.Attempts - 1)
+			if delay <= 0 || delay > o.opts.MaxBackoff {
+				delay = o.opts.MaxBackoff
+			}
+			e.NextAttempt = now.Add(delay)
+			logger.WarnCF("channels", "Outbound message failed, will retry", map[string]any{
+				"id":       e.ID,
+				"channel":  e.Message.Channel,
+				"attempts": e.Attempts,
+				"retry_in": delay.String(),
+				"error":    e.LastError,
+			})
+		}
+	}
+	if serr := o.save(); serr != nil {
+		logger.ErrorCF("channels", "Failed to save outbox", map[string]any{"error": serr.Error()})
+	}
+	o.mu.Unlock()
+
+	o.notify()
+}
+
+// isPermanent reports whether err means the message can never be delivered
+// as it is: errors wrapping ErrPermanent, and platform API errors for bad
+// requests, blocked bots and chats that do not exist.
+func isPermanent(err error) bool {
+	if errors.Is(err, ErrPermanent) {
+		return true
+	}
+	var tgErr *telegoapi.Error
+	if errors.As(err, &tgErr) {
+		return tgErr.ErrorCode == 400 || tgErr.ErrorCode == 403
+	}
+	var dcErr *discordgo.RESTError
+	if errors.As(err, &dcErr) && dcErr.Response != nil {
+		code := dcErr.Response.StatusCode
+		return code == 400 || code == 403 || code == 404
+	}
+	var slackErr slack.SlackErrorResponse
+	if errors.As(err, &slackErr) {
+		switch slackErr.Err {
+		case "channel_not_found", "not_in_channel", "is_archived", "user_not_found", "cannot_dm_bot":
+			return true
+		}
+	}
+	return false
+}
+
+func (o *Outbox) notify() {
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (o *Outbox) push(e *OutboxEntry) {
+	key := e.Message.Channel + "\x00" + e.Message.ChatID
+	if _, ok := o.queues[key]; !ok {
+		o.order = append(o.order, key)
+	}
+	o.queues[key] = append(o.queues[key], e)
+}
+
+func (o *Outbox) pop(key string) {
+	o.queues[key] = o.queues[key][1:]
+	if len(o.queues[key]) > 0 {
+		return
+	}
+	delete(o.queues, key)
+	for i, k := range o.order {
+		if k == key {
+			o.order = append(o.order[:i], o.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// save writes the pending entries to disk, replacing the file atomically.
+func (o *Outbox) save() error {
+	entries := make([]*OutboxEntry, 0)
+	for _, key := range o.order {
+		entries = append(entries, o.queues[key]...)
+	}
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(o.dir, 0o755); err != nil {
+		return err
+	}
+	path := filepath.Join(o.dir, outboxPendingFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (o *Outbox) appendDeadLetter(e *OutboxEntry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(o.dir, 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(o.dir, outboxDeadLetterFile), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+func readDeadLetters(path string) ([]OutboxEntry, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []OutboxEntry
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(data)+1)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var e OutboxEntry
+		if err := json.Unmarshal(line, &e); err != nil {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+func writeDeadLetters(path string, entries []OutboxEntry) error {
+	var buf bytes.Buffer
+	for _, e := range entries {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func newOutboxID() string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
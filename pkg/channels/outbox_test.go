@@ -0,0 +1,220 @@
+package channels
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/mymmrac/telego/telegoapi"
+	"github.com/slack-go/slack"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+)
+
+// flakySender fails the first failures sends to each channel and records
+// the content of delivered messages.
+type flakySender struct {
+	mu        sync.Mutex
+	failures  map[string]int
+	delivered []string
+	done      chan struct{}
+	want      int
+}
+
+func (s *flakySender) send(ctx context.Context, msg bus.OutboundMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failures[msg.Channel] > 0 {
+		s.failures[msg.Channel]--
+		return errors.New("503 service unavailable")
+	}
+	s.delivered = append(s.delivered, msg.Content)
+	if len(s.delivered) == s.want {
+		close(s.done)
+	}
+	return nil
+}
+
+func waitDelivered(t *testing.T, s *flakySender) {
+	t.Helper()
+	select {
+	case <-s.done:
+	case <-time.After(5 * time.Second):
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		t.Fatalf("timed out, delivered %v", s.delivered)
+	}
+}
+
+func TestOutbox_RetriesInOrder(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sender := &flakySender{failures: map[string]int{"telegram": 2}, done: make(chan struct{}), want: 3}
+	o := NewOutbox(t.TempDir(), OutboxOptions{InitialBackoff: 10 * time.Millisecond}, sender.send)
+	for _, content := range []string{"first", "second", "third"} {
+		if err := o.Enqueue(bus.OutboundMessage{Channel: "telegram", ChatID: "1", Content: content}); err != nil {
+			t.Fatalf("Enqueue: %v", err)
+		}
+	}
+	go o.Run(ctx)
+
+	waitDelivered(t, sender)
+	if got := sender.delivered; got[0] != "first" || got[1] != "second" || got[2] != "third" {
+		t.Errorf("delivered %v, want first, second, third", got)
+	}
+	if pending := o.Pending(); len(pending) != 0 {
+		t.Errorf("pending = %+v, want empty", pending)
+	}
+}
+
+func TestOutbox_DeadLetterAndResend(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dir := t.TempDir()
+	sender := &flakySender{failures: map[string]int{"slack": 2}, done: make(chan struct{}), want: 2}
+	o := NewOutbox(dir, OutboxOptions{MaxAttempts: 2, InitialBackoff: time.Millisecond}, sender.send)
+	o.Enqueue(bus.OutboundMessage{Channel: "slack", ChatID: "C1", Content: "lost"})
+	o.Enqueue(bus.OutboundMessage{Channel: "slack", ChatID: "C1", Content: "next"})
+	go o.Run(ctx)
+
+	// "lost" uses up both attempts; "next" goes out after it.
+	deadline := time.Now().Add(5 * time.Second)
+	var dead []OutboxEntry
+	for time.Now().Before(deadline) {
+		dead, _ = o.DeadLetters()
+		if len(dead) == 1 && len(o.Pending()) == 0 {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if len(dead) != 1 || dead[0].Message.Content != "lost" || dead[0].Attempts != 2 || dead[0].LastError == "" {
+		t.Fatalf("dead letters = %+v", dead)
+	}
+
+	if n, err := o.Resend([]string{"unknown"}); err != nil || n != 0 {
+		t.Errorf("Resend(unknown) = %d, %v", n, err)
+	}
+	if n, err := o.Resend([]string{dead[0].ID}); err != nil || n != 1 {
+		t.Fatalf("Resend = %d, %v", n, err)
+	}
+	waitDelivered(t, sender)
+	if dead, _ := o.DeadLetters(); len(dead) != 0 {
+		t.Errorf("dead letters after resend = %+v", dead)
+	}
+}
+
+func TestOutbox_BacksOffPerChat(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	delivered := make(chan string, 1)
+	send := func(ctx context.Context, msg bus.OutboundMessage) error {
+		if msg.ChatID == "blocked" {
+			return errors.New("503 service unavailable")
+		}
+		delivered <- msg.Content
+		return nil
+	}
+	o := NewOutbox(t.TempDir(), OutboxOptions{InitialBackoff: time.Hour}, send)
+	o.Enqueue(bus.OutboundMessage{Channel: "telegram", ChatID: "blocked", Content: "stuck"})
+	go o.Run(ctx)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for pending := o.Pending(); len(pending) != 1 || pending[0].Attempts == 0; pending = o.Pending() {
+		if time.Now().After(deadline) {
+			t.Fatalf("first send never failed, pending = %+v", pending)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// The failing chat is backing off for an hour; another chat on the same
+	// channel is not.
+	o.Enqueue(bus.OutboundMessage{Channel: "telegram", ChatID: "ok", Content: "hello"})
+	select {
+	case got := <-delivered:
+		if got != "hello" {
+			t.Errorf("delivered %q, want hello", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("message to the other chat was held up by the failing one")
+	}
+}
+
+func TestOutbox_DeadLettersPermanentErrors(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	send := func(ctx context.Context, msg bus.OutboundMessage) error {
+		return fmt.Errorf("no local client connected to session %s: %w", msg.ChatID, ErrPermanent)
+	}
+	o := NewOutbox(t.TempDir(), OutboxOptions{InitialBackoff: time.Hour}, send)
+	o.Enqueue(bus.OutboundMessage{Channel: "local", ChatID: "s1", Content: "hi"})
+	go o.Run(ctx)
+
+	deadline := time.Now().Add(5 * time.Second)
+	var dead []OutboxEntry
+	for len(dead) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+		dead, _ = o.DeadLetters()
+	}
+	if len(dead) != 1 || dead[0].Attempts != 1 || len(o.Pending()) != 0 {
+		t.Errorf("dead letters = %+v, pending = %+v; want the message given up after one attempt", dead, o.Pending())
+	}
+}
+
+func TestIsPermanent(t *testing.T) {
+	discordErr := func(status int) error {
+		return &discordgo.RESTError{Response: &http.Response{StatusCode: status}}
+	}
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"marked", fmt.Errorf("session gone: %w", ErrPermanent), true},
+		{"telegram blocked", fmt.Errorf("send: %w", &telegoapi.Error{ErrorCode: 403}), true},
+		{"telegram bad request", &telegoapi.Error{ErrorCode: 400, Description: "Bad Request: chat not found"}, true},
+		{"telegram rate limit", &telegoapi.Error{ErrorCode: 429}, false},
+		{"discord unknown channel", discordErr(404), true},
+		{"discord server error", discordErr(502), false},
+		{"slack channel not found", fmt.Errorf("send: %w", slack.SlackErrorResponse{Err: "channel_not_found"}), true},
+		{"slack rate limit", slack.SlackErrorResponse{Err: "ratelimited"}, false},
+		{"network", errors.New("connection reset by peer"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isPermanent(tt.err); got != tt.want {
+				t.Errorf("isPermanent(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOutbox_PersistsPending(t *testing.T) {
+	dir := t.TempDir()
+	o := NewOutbox(dir, OutboxOptions{}, nil)
+	o.Enqueue(bus.OutboundMessage{Channel: "discord", ChatID: "1", Content: "a"})
+	o.Enqueue(bus.OutboundMessage{Channel: "line", ChatID: "2", Content: "b"})
+	o.Enqueue(bus.OutboundMessage{Channel: "discord", ChatID: "1", Content: "c",
+		Media: []bus.OutboundMedia{{Data: []byte("x"), Filename: "x.txt"}}})
+
+	reopened := NewOutbox(dir, OutboxOptions{}, nil)
+	pending := reopened.Pending()
+	if len(pending) != 3 {
+		t.Fatalf("pending = %+v, want 3 entries", pending)
+	}
+	if pending[0].Message.Content != "a" || pending[1].Message.Content != "c" || pending[2].Message.Content != "b" {
+		t.Errorf("pending order = %s, %s, %s", pending[0].Message.Content, pending[1].Message.Content,
+			pending[2].Message.Content)
+	}
+	if string(pending[1].Message.Media[0].Data) != "x" {
+		t.Errorf("media not persisted: %+v", pending[1].Message.Media)
+	}
+}
@@ -32,6 +32,7 @@ type QQChannel struct {
 
 func NewQQChannel(cfg config.QQConfig, messageBus *bus.MessageBus) (*QQChannel, error) {
 	base := NewBaseChannel("qq", cfg, messageBus, cfg.AllowFrom)
+	base.setGroupPolicy(cfg.Groups, config.GroupPolicy{})
 
 	return &QQChannel{
 		BaseChannel:  base,
@@ -116,23 +117,28 @@ func (c *QQChannel) Send(ctx context.Context, msg bus.OutboundMessage) error {
 		return fmt.Errorf("QQ bot not running")
 	}
 
-	// 构造消息
-	msgToCreate := &dto.MessageToCreate{
-		Content: msg.Content,
-	}
+	for _, chunk := range qqFormat.Render(msg.Content) {
+		// 构造消息
+		msgToCreate := &dto.MessageToCreate{
+			Content: chunk,
+		}
 
-	// C2C 消息发送
-	_, err := c.api.PostC2CMessage(ctx, msg.ChatID, msgToCreate)
-	if err != nil {
-		logger.ErrorCF("qq", "Failed to send C2C message", map[string]any{
-			"error": err.Error(),
-		})
-		return err
+		// C2C 消息发送
+		_, err := c.api.PostC2CMessage(ctx, msg.ChatID, msgToCreate)
+		if err != nil {
+			logger.ErrorCF("qq", "Failed to send C2C message", map[string]any{
+				"error": err.Error(),
+			})
+			return err
+		}
 	}
 
 	return nil
 }
 
+// qqFormat 纯文本，QQ 机器人消息不渲染 Markdown
+var qqFormat = MessageFormat{Dialect: DialectPlain, MaxLen: 2000}
+
 // handleC2CMessage 处理 QQ 私聊消息
 func (c *QQChannel) handleC2CMessage() event.C2CMessageEventHandler {
 	return func(event *dto.WSPayload, data *dto.WSC2CMessageData) error {
@@ -213,6 +219,12 @@ func (c *QQChannel) handleGroupATMessage() event.GroupATMessageEventHandler {
 			"peer_id":    data.GroupID,
 		}
 
+		// Group AT events only arrive when the bot is mentioned.
+		content, _, ok := c.admitGroupMessage(senderID, data.GroupID, content, true, metadata)
+		if !ok {
+			return nil
+		}
+
 		c.HandleMessage(senderID, data.GroupID, content, []string{}, metadata)
 
 		return nil
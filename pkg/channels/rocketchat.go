@@ -0,0 +1,507 @@
+package channels
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+const rocketChatReconnectDelay = 5 * time.Second
+
+// rocketChatFormat is Rocket.Chat's Markdown, within the default maximum
+// message size of 5000 characters.
+var rocketChatFormat = MessageFormat{Dialect: DialectMarkdown, MaxLen: 5000}
+
+// rocketChatMediaLimits is Rocket.Chat's default maximum upload size.
+var rocketChatMediaLimits = mediaLimits{bus.MediaFile: 100 << 20}
+
+// RocketChatChannel receives messages over the Rocket.Chat realtime API
+// (DDP over WebSocket) and replies through the REST API, as a user with a
+// personal access token.
+//
+// Chat IDs are room IDs, with "/" and the thread's message ID appended for
+// threads, like the Mattermost channel.
+type RocketChatChannel struct {
+	*BaseChannel
+	config      config.RocketChatConfig
+	baseURL     string
+	client      *http.Client
+	botUsername string
+	ctx         context.Context
+	cancel      context.CancelFunc
+	mu          sync.Mutex
+	conn        *websocket.Conn
+	roomTeams   sync.Map // room ID -> team ID ("" if none)
+}
+
+// rocketChatDDP is a DDP frame; only the fields we use are decoded.
+type rocketChatDDP struct {
+	Msg        string `json:"msg"`
+	ID         string `json:"id,omitempty"`
+	Collection string `json:"collection,omitempty"`
+	Error      *struct {
+		Reason string `json:"reason"`
+	} `json:"error,omitempty"`
+	Fields struct {
+		EventName string            `json:"eventName"`
+		Args      []json.RawMessage `json:"args"`
+	} `json:"fields"`
+}
+
+type rocketChatMessage struct {
+	ID          string           `json:"_id"`
+	RoomID      string           `json:"rid"`
+	Text        string           `json:"msg"`
+	ThreadID    string           `json:"tmid"`
+	Type        string           `json:"t"`
+	EditedAt    any              `json:"editedAt"`
+	User        rocketChatUser   `json:"u"`
+	Mentions    []rocketChatUser `json:"mentions"`
+	Attachments []struct {
+		Title     string `json:"title"`
+		TitleLink string `json:"title_link"`
+	} `json:"attachments"`
+}
+
+type rocketChatUser struct {
+	ID       string `json:"_id"`
+	Username string `json:"username"`
+}
+
+type rocketChatRoomInfo struct {
+	RoomType string `json:"roomType"`
+}
+
+func NewRocketChatChannel(cfg config.RocketChatConfig, messageBus *bus.MessageBus) (*RocketChatChannel, error) {
+	if cfg.URL == "" || cfg.UserID == "" || cfg.Token == "" {
+		return nil, fmt.Errorf("rocketchat url, user_id and token are required")
+	}
+
+	base := NewBaseChannel("rocketchat", cfg, messageBus, cfg.AllowFrom)
+	base.setGroupPolicy(cfg.Groups, mentionOnlyDefaults(cfg.MentionOnly))
+
+	return &RocketChatChannel{
+		BaseChannel: base,
+		config:      cfg,
+		baseURL:     strings.TrimRight(cfg.URL, "/"),
+		client:      &http.Client{Timeout: 60 * time.Second},
+	}, nil
+}
+
+func (c *RocketChatChannel) Start(ctx context.Context) error {
+	logger.InfoC("rocketchat", "Starting Rocket.Chat channel")
+
+	c.ctx, c.cancel = context.WithCancel(ctx)
+
+	var me struct {
+		Username string `json:"username"`
+	}
+	if err := c.api(c.ctx, http.MethodGet, "/me", nil, &me); err != nil {
+		c.cancel()
+		return fmt.Errorf("rocketchat authentication failed: %w", err)
+	}
+	c.botUsername = me.Username
+
+	logger.InfoCF("rocketchat", "Rocket.Chat bot connected", map[string]any{
+		"user_id":  c.config.UserID,
+		"username": c.botUsername,
+	})
+
+	go c.listen()
+
+	c.setRunning(true)
+	logger.InfoC("rocketchat", "Rocket.Chat channel started")
+	return nil
+}
+
+func (c *RocketChatChannel) Stop(ctx context.Context) error {
+	logger.InfoC("rocketchat", "Stopping Rocket.Chat channel")
+
+	if c.cancel != nil {
+		c.cancel()
+	}
+
+	c.mu.Lock()
+	if c.conn != nil {
+		c.conn.Close()
+		c.conn = nil
+	}
+	c.mu.Unlock()
+
+	c.setRunning(false)
+	logger.InfoC("rocketchat", "Rocket.Chat channel stopped")
+	return nil
+}
+
+func (c *RocketChatChannel) SupportsMedia() bool {
+	return true
+}
+
+// listen follows the realtime API, reconnecting until the channel stops.
+func (c *RocketChatChannel) listen() {
+	for {
+		err := c.readEvents()
+		if c.ctx.Err() != nil {
+			return
+		}
+		logger.WarnCF("rocketchat", "WebSocket closed, reconnecting", map[string]any{
+			"error": fmt.Sprint(err),
+		})
+
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-time.After(rocketChatReconnectDelay):
+		}
+	}
+}
+
+func (c *RocketChatChannel) readEvents() error {
+	wsURL := "ws" + strings.TrimPrefix(c.baseURL, "http") + "/websocket"
+	dialer := websocket.Dialer{HandshakeTimeout: 10 * time.Second}
+
+	conn, _, err := dialer.DialContext(c.ctx, wsURL, nil)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.conn = conn
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		if c.conn == conn {
+			c.conn = nil
+		}
+		c.mu.Unlock()
+		conn.Close()
+	}()
+
+	// Connect, log in with the access token and subscribe to messages in
+	// every room the user is in.
+	login := map[string]string{"resume": c.config.Token}
+	handshake := []map[string]any{
+		{"msg": "connect", "version": "1", "support": []string{"1"}},
+		{"msg": "method", "method": "login", "id": "login", "params": []any{login}},
+		{"msg": "sub", "id": "messages", "name": "stream-room-messages", "params": []any{"__my_messages__", false}},
+	}
+	for _, frame := range handshake {
+		if err := conn.WriteJSON(frame); err != nil {
+			return err
+		}
+	}
+
+	for {
+		var frame rocketChatDDP
+		if err := conn.ReadJSON(&frame); err != nil {
+			return err
+		}
+		switch frame.Msg {
+		case "ping":
+			if err := conn.WriteJSON(map[string]string{"msg": "pong"}); err != nil {
+				return err
+			}
+		case "result", "nosub":
+			if frame.Error != nil {
+				return fmt.Errorf("%s failed: %s", frame.ID, frame.Error.Reason)
+			}
+		case "changed":
+			if frame.Collection == "stream-room-messages" && len(frame.Fields.Args) > 0 {
+				c.handleFrame(frame.Fields.Args)
+			}
+		}
+	}
+}
+
+func (c *RocketChatChannel) handleFrame(args []json.RawMessage) {
+	var msg rocketChatMessage
+	if err := json.Unmarshal(args[0], &msg); err != nil {
+		logger.WarnCF("rocketchat", "Failed to parse message", map[string]any{"error": err.Error()})
+		return
+	}
+	var room rocketChatRoomInfo
+	if len(args) > 1 {
+		json.Unmarshal(args[1], &room)
+	}
+	c.handleMessage(msg, room)
+}
+
+func (c *RocketChatChannel) handleMessage(msg rocketChatMessage, room rocketChatRoomInfo) {
+	// System messages have a type; edits are delivered again with editedAt.
+	if msg.User.ID == c.config.UserID || msg.Type != "" || msg.EditedAt != nil {
+		return
+	}
+
+	senderID := msg.User.ID + "|" + msg.User.Username
+	if !c.IsAllowed(senderID) {
+		logger.DebugCF("rocketchat", "Message rejected by allowlist", map[string]any{"sender_id": senderID})
+		return
+	}
+
+	isDM := room.RoomType == "d"
+	chatID := msg.RoomID
+	peerKind := "channel"
+	peerID := msg.RoomID
+	if isDM {
+		peerKind = "direct"
+		peerID = msg.User.ID
+	}
+
+	metadata := map[string]string{
+		"message_id": msg.ID,
+		"room_id":    msg.RoomID,
+		"thread_id":  msg.ThreadID,
+		"user_name":  msg.User.Username,
+		"platform":   "rocketchat",
+		"peer_kind":  peerKind,
+		"peer_id":    peerID,
+	}
+	if !isDM {
+		metadata["team_id"] = c.roomTeam(msg.RoomID)
+	}
+	if msg.ThreadID != "" {
+		chatID = msg.RoomID + "/" + msg.ThreadID
+		if !isDM {
+			metadata["peer_id"] = chatID
+			metadata["parent_peer_kind"] = peerKind
+			metadata["parent_peer_id"] = msg.RoomID
+		}
+	}
+
+	content := msg.Text
+	if c.botUsername != "" {
+		content = strings.ReplaceAll(content, "@"+c.botUsername, "")
+	}
+	content = strings.TrimSpace(content)
+
+	if !isDM {
+		var thread, ok bool
+		content, thread, ok = c.admitGroupMessage(senderID, chatID, content, c.isMentioned(msg), metadata)
+		if !ok {
+			return
+		}
+		if thread && msg.ThreadID == "" {
+			// Start a thread on the message, as its own session.
+			chatID = msg.RoomID + "/" + msg.ID
+			metadata["thread_id"] = msg.ID
+			metadata["peer_id"] = chatID
+			metadata["parent_peer_kind"] = peerKind
+			metadata["parent_peer_id"] = msg.RoomID
+		}
+	}
+
+	var mediaPaths []string
+	for _, att := range msg.Attachments {
+		if att.TitleLink == "" {
+			continue
+		}
+		path := utils.DownloadFile(c.baseURL+att.TitleLink, att.Title, utils.DownloadOptions{
+			ExtraHeaders: c.authHeaders(),
+			LoggerPrefix: "rocketchat",
+		})
+		if path == "" {
+			continue
+		}
+		mediaPaths = append(mediaPaths, path)
+		content = appendContent(content, fmt.Sprintf("[file: %s]", att.Title))
+	}
+
+	if strings.TrimSpace(content) == "" {
+		return
+	}
+
+	logger.DebugCF("rocketchat", "Received message", map[string]any{
+		"sender_id": senderID,
+		"chat_id":   chatID,
+		"preview":   utils.Truncate(content, 50),
+	})
+
+	c.HandleMessage(senderID, chatID, content, mediaPaths, metadata)
+}
+
+func (c *RocketChatChannel) isMentioned(msg rocketChatMessage) bool {
+	mentioned := slices.ContainsFunc(msg.Mentions, func(u rocketChatUser) bool { return u.ID == c.config.UserID })
+	return mentioned || (c.botUsername != "" && strings.Contains(msg.Text, "@"+c.botUsername))
+}
+
+// roomTeam returns the ID of the team a room belongs to, or "", looking it
+// up once per room.
+func (c *RocketChatChannel) roomTeam(roomID string) string {
+	if teamID, ok := c.roomTeams.Load(roomID); ok {
+		return teamID.(string)
+	}
+
+	var info struct {
+		Room struct {
+			TeamID string `json:"teamId"`
+		} `json:"room"`
+	}
+	ctx, cancel := context.WithTimeout(c.ctx, 10*time.Second)
+	defer cancel()
+	if err := c.api(ctx, http.MethodGet, "/rooms.info?roomId="+url.QueryEscape(roomID), nil, &info); err != nil {
+		logger.DebugCF("rocketchat", "Failed to look up room", map[string]any{"room_id": roomID, "error": err.Error()})
+		return ""
+	}
+	c.roomTeams.Store(roomID, info.Room.TeamID)
+	return info.Room.TeamID
+}
+
+func (c *RocketChatChannel) Send(ctx context.Context, msg bus.OutboundMessage) error {
+	if !c.IsRunning() {
+		return fmt.Errorf("rocketchat channel not running")
+	}
+
+	roomID, threadID, _ := strings.Cut(msg.ChatID, "/")
+	if roomID == "" {
+		return fmt.Errorf("invalid rocketchat chat ID: %s", msg.ChatID)
+	}
+
+	files, notices := rocketChatMediaLimits.prepare(msg.Media)
+	content := appendNotices(msg.Content, notices)
+
+	if content != "" {
+		for _, chunk := range rocketChatFormat.Render(content) {
+			message := map[string]string{"rid": roomID, "msg": chunk}
+			if threadID != "" {
+				message["tmid"] = threadID
+			}
+			err := c.api(ctx, http.MethodPost, "/chat.sendMessage", map[string]any{"message": message}, nil)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, f := range files {
+		if err := c.uploadFile(ctx, roomID, threadID, f); err != nil {
+			return err
+		}
+	}
+
+	logger.DebugCF("rocketchat", "Message sent", map[string]any{
+		"room_id":   roomID,
+		"thread_id": threadID,
+	})
+	return nil
+}
+
+// uploadFile posts f to the room as a message of its own.
+func (c *RocketChatChannel) uploadFile(ctx context.Context, roomID, threadID string, f outboundFile) error {
+	r, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", f.Name(), err)
+	}
+	defer r.Close()
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	if threadID != "" {
+		mw.WriteField("tmid", threadID)
+	}
+	if f.Caption != "" {
+		mw.WriteField("description", f.Caption)
+	}
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename=%q`, f.Name()))
+	header.Set("Content-Type", f.ContentType())
+	part, err := mw.CreatePart(header)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(part, r); err != nil {
+		return fmt.Errorf("failed to read %s: %w", f.Name(), err)
+	}
+	if err := mw.Close(); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		c.baseURL+"/api/v1/rooms.upload/"+url.PathEscape(roomID), &body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+
+	if err := c.do(req, nil); err != nil {
+		return fmt.Errorf("failed to upload %s: %w", f.Name(), err)
+	}
+	return nil
+}
+
+func (c *RocketChatChannel) authHeaders() map[string]string {
+	return map[string]string{"X-User-Id": c.config.UserID, "X-Auth-Token": c.config.Token}
+}
+
+// api calls a REST API v1 endpoint with a JSON body, decoding the response
+// into result if not nil.
+func (c *RocketChatChannel) api(ctx context.Context, method, path string, payload, result any) error {
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("failed to marshal payload: %w", err)
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+"/api/v1"+path, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return c.do(req, result)
+}
+
+func (c *RocketChatChannel) do(req *http.Request, result any) error {
+	for k, v := range c.authHeaders() {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("API request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var status struct {
+		Success *bool  `json:"success"`
+		Error   string `json:"error"`
+	}
+	json.Unmarshal(respBody, &status)
+	if resp.StatusCode != http.StatusOK || (status.Success != nil && !*status.Success) {
+		if status.Error != "" {
+			return fmt.Errorf("rocketchat API error (status %d): %s", resp.StatusCode, status.Error)
+		}
+		return fmt.Errorf("rocketchat API error (status %d): %s", resp.StatusCode, string(respBody))
+	}
+
+	if result != nil {
+		if err := json.Unmarshal(respBody, result); err != nil {
+			return fmt.Errorf("failed to parse response: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package channels
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestRocketChatChannel(t *testing.T) {
+	logins := make(chan map[string]any, 1)
+	f := newFakeChatServer(t, "/websocket", map[string]string{
+		"/api/v1/me":               `{"success":true,"_id":"bot1","username":"picoclaw"}`,
+		"/api/v1/rooms.info":       `{"success":true,"room":{"teamId":"team1"}}`,
+		"/api/v1/chat.sendMessage": `{"success":true}`,
+	}, func(conn *websocket.Conn) {
+		var connect, login map[string]any
+		conn.ReadJSON(&connect)
+		conn.ReadJSON(&login)
+		logins <- login
+	})
+
+	mb := bus.NewMessageBus()
+	c, err := NewRocketChatChannel(config.RocketChatConfig{
+		URL: f.URL, UserID: "bot1", Token: "t", MentionOnly: true,
+	}, mb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop(context.Background())
+
+	changed := func(text string, mentions []map[string]string, roomType string) map[string]any {
+		return map[string]any{"msg": "changed", "collection": "stream-room-messages", "fields": map[string]any{
+			"eventName": "__my_messages__",
+			"args": []any{
+				map[string]any{
+					"_id": "m1", "rid": "r1", "msg": text, "tmid": "t1",
+					"u": map[string]string{"_id": "u1", "username": "alice"}, "mentions": mentions,
+				},
+				map[string]any{"roomType": roomType},
+			},
+		}}
+	}
+	f.events <- map[string]string{"msg": "ping"}
+	f.events <- changed("chatter", nil, "c")
+	f.events <- changed("@picoclaw what changed?", []map[string]string{{"_id": "bot1", "username": "picoclaw"}}, "c")
+
+	select {
+	case login := <-logins:
+		if login["method"] != "login" {
+			t.Errorf("login frame = %v", login)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no login")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	msg, ok := mb.ConsumeInbound(ctx)
+	if !ok {
+		t.Fatal("no inbound message")
+	}
+	if msg.SenderID != "u1|alice" || msg.ChatID != "r1/t1" || msg.Content != "what changed?" {
+		t.Errorf("inbound = %+v", msg)
+	}
+	if msg.Metadata["team_id"] != "team1" || msg.Metadata["parent_peer_id"] != "r1" {
+		t.Errorf("metadata = %v", msg.Metadata)
+	}
+
+	if err := c.Send(context.Background(), bus.OutboundMessage{ChatID: "r1/t1", Content: "Done"}); err != nil {
+		t.Fatal(err)
+	}
+	sent := f.requestsTo("/api/v1/chat.sendMessage")
+	if len(sent) != 1 {
+		t.Fatalf("sent = %v", sent)
+	}
+	if m := sent[0]["message"].(map[string]any); m["rid"] != "r1" || m["tmid"] != "t1" || m["msg"] != "Done" {
+		t.Errorf("message = %v", m)
+	}
+}
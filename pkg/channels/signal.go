@@ -0,0 +1,511 @@
+package channels
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+const (
+	signalRPCPath        = "/api/v1/rpc"
+	signalEventsPath     = "/api/v1/events"
+	signalCheckPath      = "/api/v1/check"
+	signalGroupPrefix    = "group:"
+	signalReconnectDelay = 5 * time.Second
+	signalReceivedEmoji  = "👀"
+	signalRepliedEmoji   = "✅"
+)
+
+// signalMediaLimits is the attachment size Signal accepts.
+var signalMediaLimits = mediaLimits{bus.MediaFile: 100 << 20}
+
+// signalFormat is plain text: Signal styles text with ranges rather than
+// markup. Longer messages are sent by signal-cli as a text attachment.
+var signalFormat = MessageFormat{Dialect: DialectPlain, MaxLen: 4000}
+
+// SignalChannel talks to a signal-cli daemon started with --http. It
+// receives messages from the daemon's server-sent event stream and sends
+// through its JSON-RPC endpoint.
+//
+// Direct chats use the sender's phone number as chat ID, and group chats
+// "group:" followed by the base64 group ID.
+type SignalChannel struct {
+	*BaseChannel
+	config      config.SignalConfig
+	baseURL     string
+	rpcClient   *http.Client
+	eventClient *http.Client
+	rpcID       atomic.Int64
+	ctx         context.Context
+	cancel      context.CancelFunc
+	pendingAcks sync.Map // chatID -> signalMessageRef
+}
+
+// signalMessageRef identifies a received message for reactions.
+type signalMessageRef struct {
+	author    string
+	timestamp int64
+}
+
+type signalReceiveEvent struct {
+	Envelope signalEnvelope `json:"envelope"`
+	Account  string         `json:"account"`
+}
+
+type signalEnvelope struct {
+	Source       string             `json:"source"`
+	SourceNumber string             `json:"sourceNumber"`
+	SourceUUID   string             `json:"sourceUuid"`
+	SourceName   string             `json:"sourceName"`
+	Timestamp    int64              `json:"timestamp"`
+	DataMessage  *signalDataMessage `json:"dataMessage"`
+}
+
+type signalDataMessage struct {
+	Timestamp   int64              `json:"timestamp"`
+	Message     string             `json:"message"`
+	GroupInfo   *signalGroupInfo   `json:"groupInfo"`
+	Attachments []signalAttachment `json:"attachments"`
+	Mentions    []signalMention    `json:"mentions"`
+}
+
+// signalMention is a mention in a data message. Its place in the text is
+// held by an object replacement character.
+type signalMention struct {
+	Number string `json:"number"`
+}
+
+type signalGroupInfo struct {
+	GroupID string `json:"groupId"`
+}
+
+type signalAttachment struct {
+	ID          string `json:"id"`
+	ContentType string `json:"contentType"`
+	Filename    string `json:"filename"`
+	Size        int64  `json:"size"`
+}
+
+type signalRPCResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func NewSignalChannel(cfg config.SignalConfig, messageBus *bus.MessageBus) (*SignalChannel, error) {
+	if cfg.Account == "" {
+		return nil, fmt.Errorf("signal account is required")
+	}
+
+	baseURL := strings.TrimRight(cfg.URL, "/")
+	if baseURL == "" {
+		baseURL = "http://127.0.0.1:8080"
+	}
+
+	base := NewBaseChannel("signal", cfg, messageBus, cfg.AllowFrom)
+	base.setGroupPolicy(cfg.Groups, config.GroupPolicy{})
+
+	return &SignalChannel{
+		BaseChannel: base,
+		config:      cfg,
+		baseURL:     baseURL,
+		rpcClient:   &http.Client{Timeout: 2 * time.Minute},
+		eventClient: &http.Client{},
+	}, nil
+}
+
+func (c *SignalChannel) Start(ctx context.Context) error {
+	logger.InfoCF("signal", "Starting Signal channel", map[string]any{
+		"url":     c.baseURL,
+		"account": c.config.Account,
+	})
+
+	checkCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(checkCtx, http.MethodGet, c.baseURL+signalCheckPath, nil)
+	if err != nil {
+		return fmt.Errorf("invalid signal-cli url: %w", err)
+	}
+	resp, err := c.rpcClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach signal-cli daemon: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("signal-cli daemon check failed: %s", resp.Status)
+	}
+
+	c.ctx, c.cancel = context.WithCancel(ctx)
+	go c.listen()
+
+	c.setRunning(true)
+	logger.InfoC("signal", "Signal channel started")
+	return nil
+}
+
+func (c *SignalChannel) Stop(ctx context.Context) error {
+	logger.InfoC("signal", "Stopping Signal channel")
+
+	if c.cancel != nil {
+		c.cancel()
+	}
+
+	c.setRunning(false)
+	logger.InfoC("signal", "Signal channel stopped")
+	return nil
+}
+
+func (c *SignalChannel) SupportsMedia() bool {
+	return true
+}
+
+func (c *SignalChannel) Send(ctx context.Context, msg bus.OutboundMessage) error {
+	if !c.IsRunning() {
+		return fmt.Errorf("signal channel not running")
+	}
+
+	files, notices := signalMediaLimits.prepare(msg.Media)
+	content := appendNotices(msg.Content, notices)
+	for _, f := range files {
+		if f.Caption != "" {
+			content = appendContent(content, f.Caption)
+		}
+	}
+
+	attachments := make([]string, 0, len(files))
+	for _, f := range files {
+		uri, err := signalDataURI(f.OutboundMedia)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", f.Name(), err)
+		}
+		attachments = append(attachments, uri)
+	}
+
+	// Attachments go with the last message.
+	chunks := []string{""}
+	if content != "" {
+		chunks = signalFormat.Render(content)
+	}
+	for i, chunk := range chunks {
+		params := signalTarget(msg.ChatID)
+		params["message"] = chunk
+		if i == len(chunks)-1 && len(attachments) > 0 {
+			params["attachments"] = attachments
+		}
+		if _, err := c.rpc(ctx, "send", params); err != nil {
+			return err
+		}
+	}
+
+	if ref, ok := c.pendingAcks.LoadAndDelete(msg.ChatID); ok {
+		c.react(msg.ChatID, ref.(signalMessageRef), signalRepliedEmoji)
+	}
+
+	logger.DebugCF("signal", "Message sent", map[string]any{
+		"chat_id":     msg.ChatID,
+		"attachments": len(attachments),
+	})
+	return nil
+}
+
+// signalTarget returns the send parameters addressing chatID.
+func signalTarget(chatID string) map[string]any {
+	if groupID, ok := strings.CutPrefix(chatID, signalGroupPrefix); ok {
+		return map[string]any{"groupId": groupID}
+	}
+	return map[string]any{"recipient": []string{chatID}}
+}
+
+// signalDataURI encodes m the way signal-cli accepts attachment contents,
+// so the daemon does not need access to our files.
+func signalDataURI(m bus.OutboundMedia) (string, error) {
+	data, err := m.ReadAll()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("data:%s;filename=%s;base64,%s",
+		m.ContentType(), m.Name(), base64.StdEncoding.EncodeToString(data)), nil
+}
+
+// rpc calls a signal-cli JSON-RPC method for the configured account.
+func (c *SignalChannel) rpc(ctx context.Context, method string, params map[string]any) (json.RawMessage, error) {
+	params["account"] = c.config.Account
+	body, err := json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"method":  method,
+		"params":  params,
+		"id":      c.rpcID.Add(1),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s request: %w", method, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+signalRPCPath, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.rpcClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("signal-cli %s request failed: %w", method, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("signal-cli %s failed: %s: %s", method, resp.Status, strings.TrimSpace(string(data)))
+	}
+
+	var rpcResp signalRPCResponse
+	if err := json.NewDecoder(resp.Body).Decode(&rpcResp); err != nil {
+		return nil, fmt.Errorf("failed to decode signal-cli %s response: %w", method, err)
+	}
+	if rpcResp.Error != nil {
+		return nil, fmt.Errorf("signal-cli %s failed: %s (code %d)", method, rpcResp.Error.Message, rpcResp.Error.Code)
+	}
+	return rpcResp.Result, nil
+}
+
+// react sets our reaction on a received message. A later reaction
+// replaces an earlier one, so the received mark turns into the replied one.
+func (c *SignalChannel) react(chatID string, ref signalMessageRef, emoji string) {
+	params := signalTarget(chatID)
+	params["emoji"] = emoji
+	params["targetAuthor"] = ref.author
+	params["targetTimestamp"] = ref.timestamp
+
+	ctx, cancel := context.WithTimeout(c.ctx, 10*time.Second)
+	defer cancel()
+	if _, err := c.rpc(ctx, "sendReaction", params); err != nil {
+		logger.DebugCF("signal", "Failed to send reaction", map[string]any{
+			"chat_id": chatID,
+			"error":   err.Error(),
+		})
+	}
+}
+
+// listen reads the daemon's event stream, reconnecting until the channel
+// stops.
+func (c *SignalChannel) listen() {
+	for {
+		err := c.readEvents()
+		if c.ctx.Err() != nil {
+			return
+		}
+		logger.WarnCF("signal", "Event stream closed, reconnecting", map[string]any{
+			"error": fmt.Sprint(err),
+		})
+
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-time.After(signalReconnectDelay):
+		}
+	}
+}
+
+func (c *SignalChannel) readEvents() error {
+	eventsURL := c.baseURL + signalEventsPath + "?account=" + url.QueryEscape(c.config.Account)
+	req, err := http.NewRequestWithContext(c.ctx, http.MethodGet, eventsURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := c.eventClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	// Server-sent events: "event:" and "data:" lines, ended by a blank line.
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 4<<20)
+	var event string
+	var data strings.Builder
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if (event == "" || event == "receive") && data.Len() > 0 {
+				c.handleEvent([]byte(data.String()))
+			}
+			event = ""
+			data.Reset()
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return io.EOF
+}
+
+func (c *SignalChannel) handleEvent(data []byte) {
+	var ev signalReceiveEvent
+	if err := json.Unmarshal(data, &ev); err != nil {
+		logger.WarnCF("signal", "Failed to parse event", map[string]any{"error": err.Error()})
+		return
+	}
+	c.handleEnvelope(ev.Envelope)
+}
+
+// handleEnvelope publishes a received data message. Receipts, typing
+// notifications and messages sent from our other devices are ignored.
+func (c *SignalChannel) handleEnvelope(env signalEnvelope) {
+	dm := env.DataMessage
+	if dm == nil || (dm.Message == "" && len(dm.Attachments) == 0) {
+		return
+	}
+
+	senderID := env.SourceNumber
+	if senderID == "" {
+		senderID = env.SourceUUID
+	}
+	if senderID == "" {
+		senderID = env.Source
+	}
+	if !c.IsAllowed(senderID) {
+		logger.DebugCF("signal", "Message rejected by allowlist", map[string]any{"sender_id": senderID})
+		return
+	}
+
+	chatID := senderID
+	metadata := map[string]string{
+		"message_id": strconv.FormatInt(env.Timestamp, 10),
+		"user_name":  env.SourceName,
+		"peer_kind":  "direct",
+		"peer_id":    senderID,
+	}
+	if dm.GroupInfo != nil && dm.GroupInfo.GroupID != "" {
+		chatID = signalGroupPrefix + dm.GroupInfo.GroupID
+		metadata["peer_kind"] = "group"
+		metadata["peer_id"] = dm.GroupInfo.GroupID
+	}
+
+	content := dm.Message
+	if metadata["peer_kind"] == "group" {
+		mentioned := slices.ContainsFunc(dm.Mentions, func(m signalMention) bool {
+			return m.Number == c.config.Account
+		})
+		if mentioned {
+			content = strings.TrimSpace(strings.ReplaceAll(content, "\uFFFC", ""))
+		}
+		var ok bool
+		if content, _, ok = c.admitGroupMessage(senderID, chatID, content, mentioned, metadata); !ok {
+			return
+		}
+	}
+
+	ref := signalMessageRef{author: senderID, timestamp: env.Timestamp}
+	c.pendingAcks.Store(chatID, ref)
+	go c.react(chatID, ref, signalReceivedEmoji)
+
+	var mediaPaths []string
+	for _, att := range dm.Attachments {
+		path := c.fetchAttachment(chatID, att)
+		if path == "" {
+			continue
+		}
+		mediaPaths = append(mediaPaths, path)
+		switch {
+		case strings.HasPrefix(att.ContentType, "image/"):
+			content = appendContent(content, "[image]")
+		case utils.IsAudioFile(att.Filename, att.ContentType):
+			content = appendContent(content, "[audio]")
+		default:
+			content = appendContent(content, fmt.Sprintf("[file: %s]", signalAttachmentName(att)))
+		}
+	}
+
+	logger.DebugCF("signal", "Received message", map[string]any{
+		"sender_id": senderID,
+		"chat_id":   chatID,
+		"preview":   utils.Truncate(content, 50),
+	})
+
+	c.HandleMessage(senderID, chatID, content, mediaPaths, metadata)
+}
+
+// fetchAttachment saves a received attachment to the media directory and
+// returns its path, or "" on failure.
+func (c *SignalChannel) fetchAttachment(chatID string, att signalAttachment) string {
+	params := signalTarget(chatID)
+	params["id"] = att.ID
+	if recipients, ok := params["recipient"].([]string); ok {
+		params["recipient"] = recipients[0]
+	}
+
+	ctx, cancel := context.WithTimeout(c.ctx, time.Minute)
+	defer cancel()
+	result, err := c.rpc(ctx, "getAttachment", params)
+	if err != nil {
+		logger.WarnCF("signal", "Failed to fetch attachment", map[string]any{"id": att.ID, "error": err.Error()})
+		return ""
+	}
+
+	var encoded struct {
+		Data string `json:"data"`
+	}
+	if err := json.Unmarshal(result, &encoded); err != nil {
+		logger.WarnCF("signal", "Invalid attachment response", map[string]any{"id": att.ID, "error": err.Error()})
+		return ""
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded.Data)
+	if err != nil {
+		logger.WarnCF("signal", "Invalid attachment data", map[string]any{"id": att.ID, "error": err.Error()})
+		return ""
+	}
+
+	mediaDir := filepath.Join(os.TempDir(), "picoclaw_media")
+	if err := os.MkdirAll(mediaDir, 0o700); err != nil {
+		logger.ErrorCF("signal", "Failed to create media directory", map[string]any{"error": err.Error()})
+		return ""
+	}
+	path := filepath.Join(mediaDir, uuid.New().String()[:8]+"_"+utils.SanitizeFilename(signalAttachmentName(att)))
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		logger.ErrorCF("signal", "Failed to save attachment", map[string]any{"error": err.Error()})
+		return ""
+	}
+	return path
+}
+
+func signalAttachmentName(att signalAttachment) string {
+	if att.Filename != "" {
+		return att.Filename
+	}
+	return att.ID
+}
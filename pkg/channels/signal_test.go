@@ -0,0 +1,163 @@
+package channels
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// fakeSignalCLI serves the parts of the signal-cli HTTP daemon the channel
+// uses, streaming events and recording JSON-RPC calls.
+type fakeSignalCLI struct {
+	*httptest.Server
+	events chan string
+	mu     sync.Mutex
+	calls  []map[string]any
+}
+
+func newFakeSignalCLI(t *testing.T) *fakeSignalCLI {
+	f := &fakeSignalCLI{events: make(chan string, 10)}
+	mux := http.NewServeMux()
+	mux.HandleFunc(signalCheckPath, func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc(signalRPCPath, func(w http.ResponseWriter, r *http.Request) {
+		var call map[string]any
+		json.NewDecoder(r.Body).Decode(&call)
+		f.mu.Lock()
+		f.calls = append(f.calls, call)
+		f.mu.Unlock()
+
+		result := map[string]any{"timestamp": 1}
+		if call["method"] == "getAttachment" {
+			result = map[string]any{"data": base64.StdEncoding.EncodeToString([]byte("jpeg"))}
+		}
+		json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "result": result, "id": call["id"]})
+	})
+	mux.HandleFunc(signalEventsPath, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.(http.Flusher).Flush()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case data := <-f.events:
+				fmt.Fprintf(w, "event:receive\ndata:%s\n\n", data)
+				w.(http.Flusher).Flush()
+			}
+		}
+	})
+	f.Server = httptest.NewServer(mux)
+	t.Cleanup(f.Close)
+	return f
+}
+
+// call waits for the first JSON-RPC call of method and returns its params.
+func (f *fakeSignalCLI) call(t *testing.T, method string) map[string]any {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		f.mu.Lock()
+		for _, call := range f.calls {
+			if call["method"] == method {
+				f.mu.Unlock()
+				return call["params"].(map[string]any)
+			}
+		}
+		f.mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("no %s call", method)
+	return nil
+}
+
+func startSignalChannel(t *testing.T, f *fakeSignalCLI, mb *bus.MessageBus) *SignalChannel {
+	t.Helper()
+	c, err := NewSignalChannel(config.SignalConfig{
+		Account:   "+15550000000",
+		URL:       f.URL,
+		AllowFrom: config.FlexibleStringSlice{"+15551111111"},
+	}, mb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Stop(context.Background()) })
+	return c
+}
+
+func TestSignalChannel_Receive(t *testing.T) {
+	f := newFakeSignalCLI(t)
+	mb := bus.NewMessageBus()
+	startSignalChannel(t, f, mb)
+
+	f.events <- `{"envelope":{"sourceNumber":"+15552222222","timestamp":5,"dataMessage":{"message":"blocked"}}}`
+	f.events <- `{"envelope":{"sourceNumber":"+15551111111","sourceName":"Alice","timestamp":7,` +
+		`"dataMessage":{"message":"look","groupInfo":{"groupId":"Z3JvdXA="},` +
+		`"attachments":[{"id":"abc.jpg","contentType":"image/jpeg","filename":"cat.jpg"}]}}}`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	msg, ok := mb.ConsumeInbound(ctx)
+	if !ok {
+		t.Fatal("no inbound message")
+	}
+	if msg.SenderID != "+15551111111" || msg.ChatID != "group:Z3JvdXA=" || msg.Content != "look\n[image]" {
+		t.Errorf("inbound = %+v", msg)
+	}
+	if msg.Metadata["peer_kind"] != "group" || msg.Metadata["peer_id"] != "Z3JvdXA=" ||
+		msg.Metadata["user_name"] != "Alice" {
+		t.Errorf("metadata = %v", msg.Metadata)
+	}
+	if len(msg.Media) != 1 {
+		t.Fatalf("media = %v", msg.Media)
+	}
+	defer os.Remove(msg.Media[0])
+	if data, _ := os.ReadFile(msg.Media[0]); string(data) != "jpeg" {
+		t.Errorf("attachment = %q", data)
+	}
+
+	reaction := f.call(t, "sendReaction")
+	if reaction["groupId"] != "Z3JvdXA=" || reaction["targetAuthor"] != "+15551111111" ||
+		reaction["targetTimestamp"] != float64(7) || reaction["account"] != "+15550000000" {
+		t.Errorf("reaction = %v", reaction)
+	}
+}
+
+func TestSignalChannel_Send(t *testing.T) {
+	f := newFakeSignalCLI(t)
+	c := startSignalChannel(t, f, bus.NewMessageBus())
+
+	err := c.Send(context.Background(), bus.OutboundMessage{
+		ChatID:  "+15551111111",
+		Content: "**Report** attached",
+		Media:   []bus.OutboundMedia{{Data: []byte("pdf"), Filename: "report.pdf", Caption: "Weekly"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	params := f.call(t, "send")
+	if params["message"] != "Report attached\nWeekly" {
+		t.Errorf("message = %q", params["message"])
+	}
+	if recipients, _ := params["recipient"].([]any); len(recipients) != 1 || recipients[0] != "+15551111111" {
+		t.Errorf("recipient = %v", params["recipient"])
+	}
+	attachments, _ := params["attachments"].([]any)
+	if len(attachments) != 1 ||
+		!strings.HasPrefix(attachments[0].(string), "data:application/pdf;filename=report.pdf;base64,") {
+		t.Errorf("attachments = %v", attachments)
+	}
+}
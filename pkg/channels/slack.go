@@ -50,6 +50,7 @@ func NewSlackChannel(cfg config.SlackConfig, messageBus *bus.MessageBus) (*Slack
 	socketClient := socketmode.New(api)
 
 	base := NewBaseChannel("slack", cfg, messageBus, cfg.AllowFrom)
+	base.setGroupPolicy(cfg.Groups, config.GroupPolicy{})
 
 	return &SlackChannel{
 		BaseChannel:  base,
@@ -119,17 +120,36 @@ func (c *SlackChannel) Send(ctx context.Context, msg bus.OutboundMessage) error
 		return fmt.Errorf("invalid slack chat ID: %s", msg.ChatID)
 	}
 
-	opts := []slack.MsgOption{
-		slack.MsgOptionText(msg.Content, false),
-	}
+	files, notices := slackMediaLimits.prepare(msg.Media)
+	content := appendNotices(msg.Content, notices)
 
-	if threadTS != "" {
-		opts = append(opts, slack.MsgOptionTS(threadTS))
+	content = interactiveContent(content, msg.Interactive)
+
+	if content != "" || len(files) == 0 {
+		chunks := slackFormat.Render(content)
+		for i, chunk := range chunks {
+			opts := []slack.MsgOption{
+				slack.MsgOptionText(chunk, false),
+			}
+			if i == len(chunks)-1 && len(msg.Interactive) > 0 {
+				opts = append(opts, slack.MsgOptionBlocks(c.blocks(msg.ChatID, chunk, msg.Interactive)...))
+			}
+
+			if threadTS != "" {
+				opts = append(opts, slack.MsgOptionTS(threadTS))
+			}
+
+			_, _, err := c.api.PostMessageContext(ctx, channelID, opts...)
+			if err != nil {
+				return fmt.Errorf("failed to send slack message: %w", err)
+			}
+		}
 	}
 
-	_, _, err := c.api.PostMessageContext(ctx, channelID, opts...)
-	if err != nil {
-		return fmt.Errorf("failed to send slack message: %w", err)
+	for _, f := range files {
+		if err := c.uploadFile(ctx, channelID, threadTS, f); err != nil {
+			return err
+		}
 	}
 
 	if ref, ok := c.pendingAcks.LoadAndDelete(msg.ChatID); ok {
@@ -148,6 +168,117 @@ func (c *SlackChannel) Send(ctx context.Context, msg bus.OutboundMessage) error
 	return nil
 }
 
+// slackFormat is Slack's mrkdwn. Slack truncates messages far longer than
+// 4000 characters and recommends staying under that.
+var slackFormat = MessageFormat{Dialect: DialectSlack, MaxLen: 4000}
+
+func (c *SlackChannel) SupportsMedia() bool {
+	return true
+}
+
+func (c *SlackChannel) SupportsInteractive() bool {
+	return true
+}
+
+// Block Kit limits.
+const (
+	slackSectionTextLimit = 3000
+	slackLabelLimit       = 75
+	slackActionsLimit     = 25
+	slackSelectLimit      = 100
+)
+
+// blocks lays out text and the interactive elements with Block Kit: the
+// text in a section, then an actions block of buttons and menus. Quick
+// replies are buttons, and menu options that open links become link
+// buttons, as Slack menus cannot. The message text is then only used for
+// notifications.
+func (c *SlackChannel) blocks(chatID, text string, elements []bus.InteractiveElement) []slack.Block {
+	var blocks []slack.Block
+	for _, part := range utils.SplitMessage(text, slackSectionTextLimit) {
+		blocks = append(blocks, slack.NewSectionBlock(
+			slack.NewTextBlockObject(slack.MarkdownType, part, false, false), nil, nil))
+	}
+
+	links, elements := linkOptions(elements, bus.InteractiveSelect)
+	var actions []slack.BlockElement
+	for _, el := range elements {
+		if el.Kind == bus.InteractiveSelect {
+			var options []*slack.OptionBlockObject
+			for _, opt := range el.Options[:min(len(el.Options), slackSelectLimit)] {
+				options = append(options, slack.NewOptionBlockObject(c.interactions.register(chatID, el, opt),
+					slackPlainText(opt.Label), nil))
+			}
+			placeholder := el.Placeholder
+			if placeholder == "" {
+				placeholder = "Choose an option"
+			}
+			actions = append(actions, slack.NewOptionsSelectBlockElement(slack.OptTypeStatic,
+				slackPlainText(placeholder), newInteractionToken(), options...))
+			continue
+		}
+		for _, opt := range el.Options {
+			actions = append(actions, c.button(chatID, el, opt))
+		}
+	}
+	for _, opt := range links {
+		actions = append(actions, c.button(chatID, bus.InteractiveElement{}, opt))
+	}
+
+	for len(actions) > 0 {
+		n := min(len(actions), slackActionsLimit)
+		blocks = append(blocks, slack.NewActionBlock("", actions[:n]...))
+		actions = actions[n:]
+	}
+	return blocks
+}
+
+func (c *SlackChannel) button(chatID string, el bus.InteractiveElement, opt bus.InteractiveOption) slack.BlockElement {
+	if opt.URL != "" {
+		return slack.NewButtonBlockElement(newInteractionToken(), "", slackPlainText(opt.Label)).WithURL(opt.URL)
+	}
+	token := c.interactions.register(chatID, el, opt)
+	button := slack.NewButtonBlockElement(token, token, slackPlainText(opt.Label))
+	switch opt.Style {
+	case "primary":
+		button = button.WithStyle(slack.StylePrimary)
+	case "danger":
+		button = button.WithStyle(slack.StyleDanger)
+	}
+	return button
+}
+
+func slackPlainText(text string) *slack.TextBlockObject {
+	return slack.NewTextBlockObject(slack.PlainTextType, utils.Truncate(text, slackLabelLimit), false, false)
+}
+
+// slackMediaLimits is the Slack file upload limit.
+var slackMediaLimits = mediaLimits{bus.MediaFile: 1 << 30}
+
+// uploadFile shares f in the channel (or thread), with its caption as the
+// initial comment.
+func (c *SlackChannel) uploadFile(ctx context.Context, channelID, threadTS string, f outboundFile) error {
+	r, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", f.Name(), err)
+	}
+	defer r.Close()
+
+	_, err = c.api.UploadFileV2Context(ctx, slack.UploadFileV2Parameters{
+		Reader:          r,
+		FileSize:        int(f.size),
+		Filename:        f.Name(),
+		Title:           f.Name(),
+		InitialComment:  f.Caption,
+		Channel:         channelID,
+		ThreadTimestamp: threadTS,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload %s to slack: %w", f.Name(), err)
+	}
+	return nil
+}
+
 func (c *SlackChannel) eventLoop() {
 	for {
 		select {
@@ -163,9 +294,7 @@ func (c *SlackChannel) eventLoop() {
 			case socketmode.EventTypeSlashCommand:
 				c.handleSlashCommand(event)
 			case socketmode.EventTypeInteractive:
-				if event.Request != nil {
-					c.socketClient.Ack(*event.Request)
-				}
+				c.handleInteractive(event)
 			}
 		}
 	}
@@ -218,6 +347,35 @@ func (c *SlackChannel) handleMessageEvent(ev *slackevents.MessageEvent) {
 		chatID = channelID + "/" + threadTS
 	}
 
+	peerKind := "channel"
+	peerID := channelID
+	if strings.HasPrefix(channelID, "D") {
+		peerKind = "direct"
+		peerID = senderID
+	}
+
+	metadata := map[string]string{
+		"message_ts": messageTS,
+		"channel_id": channelID,
+		"thread_ts":  threadTS,
+		"platform":   "slack",
+		"peer_kind":  peerKind,
+		"peer_id":    peerID,
+		"team_id":    c.teamID,
+	}
+
+	content := c.stripBotMention(ev.Text)
+	if peerKind == "channel" {
+		mentioned := strings.Contains(ev.Text, "<@"+c.botUserID+">")
+		var thread, ok bool
+		if content, thread, ok = c.admitGroupMessage(senderID, chatID, content, mentioned, metadata); !ok {
+			return
+		}
+		if thread && threadTS == "" {
+			chatID = channelID + "/" + messageTS
+		}
+	}
+
 	c.api.AddReaction("eyes", slack.ItemRef{
 		Channel:   channelID,
 		Timestamp: messageTS,
@@ -228,9 +386,6 @@ func (c *SlackChannel) handleMessageEvent(ev *slackevents.MessageEvent) {
 		Timestamp: messageTS,
 	})
 
-	content := ev.Text
-	content = c.stripBotMention(content)
-
 	var mediaPaths []string
 	localFiles := []string{} // 跟踪需要清理的本地文件
 
@@ -276,23 +431,6 @@ func (c *SlackChannel) handleMessageEvent(ev *slackevents.MessageEvent) {
 		return
 	}
 
-	peerKind := "channel"
-	peerID := channelID
-	if strings.HasPrefix(channelID, "D") {
-		peerKind = "direct"
-		peerID = senderID
-	}
-
-	metadata := map[string]string{
-		"message_ts": messageTS,
-		"channel_id": channelID,
-		"thread_ts":  threadTS,
-		"platform":   "slack",
-		"peer_kind":  peerKind,
-		"peer_id":    peerID,
-		"team_id":    c.teamID,
-	}
-
 	logger.DebugCF("slack", "Received message", map[string]any{
 		"sender_id":  senderID,
 		"chat_id":    chatID,
@@ -327,22 +465,6 @@ func (c *SlackChannel) handleAppMention(ev *slackevents.AppMentionEvent) {
 		chatID = channelID + "/" + messageTS
 	}
 
-	c.api.AddReaction("eyes", slack.ItemRef{
-		Channel:   channelID,
-		Timestamp: messageTS,
-	})
-
-	c.pendingAcks.Store(chatID, slackMessageRef{
-		ChannelID: channelID,
-		Timestamp: messageTS,
-	})
-
-	content := c.stripBotMention(ev.Text)
-
-	if strings.TrimSpace(content) == "" {
-		return
-	}
-
 	mentionPeerKind := "channel"
 	mentionPeerID := channelID
 	if strings.HasPrefix(channelID, "D") {
@@ -361,9 +483,73 @@ func (c *SlackChannel) handleAppMention(ev *slackevents.AppMentionEvent) {
 		"team_id":    c.teamID,
 	}
 
+	content := c.stripBotMention(ev.Text)
+	if mentionPeerKind == "channel" {
+		var ok bool
+		if content, _, ok = c.admitGroupMessage(senderID, chatID, content, true, metadata); !ok {
+			return
+		}
+	}
+
+	if strings.TrimSpace(content) == "" {
+		return
+	}
+
+	c.api.AddReaction("eyes", slack.ItemRef{
+		Channel:   channelID,
+		Timestamp: messageTS,
+	})
+
+	c.pendingAcks.Store(chatID, slackMessageRef{
+		ChannelID: channelID,
+		Timestamp: messageTS,
+	})
+
 	c.HandleMessage(senderID, chatID, content, nil, metadata)
 }
 
+// handleInteractive handles clicks on buttons and picks from menus sent by
+// Send.
+func (c *SlackChannel) handleInteractive(event socketmode.Event) {
+	if event.Request != nil {
+		c.socketClient.Ack(*event.Request)
+	}
+
+	callback, ok := event.Data.(slack.InteractionCallback)
+	if !ok || callback.Type != slack.InteractionTypeBlockActions {
+		return
+	}
+	if !c.IsAllowed(callback.User.ID) {
+		logger.DebugCF("slack", "Interaction rejected by allowlist", map[string]any{
+			"user_id": callback.User.ID,
+		})
+		return
+	}
+
+	peerKind := "channel"
+	if strings.HasPrefix(callback.Channel.ID, "D") {
+		peerKind = "direct"
+	}
+	for _, action := range callback.ActionCallback.BlockActions {
+		token := action.Value
+		if action.Type == slack.ActionType(slack.OptTypeStatic) {
+			token = action.SelectedOption.Value
+		}
+		metadata := map[string]string{
+			"channel_id": callback.Channel.ID,
+			"platform":   "slack",
+			"peer_kind":  peerKind,
+			"peer_id":    callback.Channel.ID,
+			"team_id":    c.teamID,
+		}
+		if !c.HandleInteraction(callback.User.ID, token, metadata) && token != "" {
+			logger.DebugCF("slack", "Ignoring unknown or expired interaction", map[string]any{
+				"action_id": action.ActionID,
+			})
+		}
+	}
+}
+
 func (c *SlackChannel) handleSlashCommand(event socketmode.Event) {
 	cmd, ok := event.Data.(slack.SlashCommand)
 	if !ok {
@@ -6,10 +6,10 @@ import (
 	"net/http"
 	"net/url"
 	"os"
-	"regexp"
 	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/mymmrac/telego"
 	"github.com/mymmrac/telego/telegohandler"
@@ -73,6 +73,7 @@ func NewTelegramChannel(cfg *config.Config, bus *bus.MessageBus) (*TelegramChann
 	}
 
 	base := NewBaseChannel("telegram", telegramCfg, bus, telegramCfg.AllowFrom)
+	base.setGroupPolicy(telegramCfg.Groups, config.GroupPolicy{})
 
 	return &TelegramChannel{
 		BaseChannel:  base,
@@ -125,6 +126,10 @@ func (c *TelegramChannel) Start(ctx context.Context) error {
 		return c.handleMessage(ctx, &message)
 	}, th.AnyMessage())
 
+	bh.HandleCallbackQuery(func(ctx *th.Context, query telego.CallbackQuery) error {
+		return c.handleCallbackQuery(ctx, query)
+	}, th.AnyCallbackQuery())
+
 	c.setRunning(true)
 	logger.InfoCF("telegram", "Telegram bot connected", map[string]any{
 		"username": c.bot.Username(),
@@ -164,35 +169,212 @@ func (c *TelegramChannel) Send(ctx context.Context, msg bus.OutboundMessage) err
 		c.stopThinking.Delete(msg.ChatID)
 	}
 
-	htmlContent := markdownToTelegramHTML(msg.Content)
+	files, notices := telegramMediaLimits.prepare(msg.Media)
+	content := appendNotices(msg.Content, notices)
+	content = interactiveContent(content, msg.Interactive)
+	markup, links := c.replyMarkup(msg.ChatID, msg.Interactive)
+	for _, link := range links {
+		content = appendContent(content, link.Label+": "+link.URL)
+	}
 
-	// Try to edit placeholder
-	if pID, ok := c.placeholders.Load(msg.ChatID); ok {
-		c.placeholders.Delete(msg.ChatID)
-		editMsg := tu.EditMessageText(tu.ID(chatID), pID.(int), htmlContent)
-		editMsg.ParseMode = telego.ModeHTML
+	if content != "" || len(files) == 0 {
+		if err := c.sendText(ctx, chatID, msg.ChatID, content, markup); err != nil {
+			return err
+		}
+	} else if pID, ok := c.placeholders.LoadAndDelete(msg.ChatID); ok {
+		// The files are the whole reply, so drop the "Thinking..." placeholder.
+		c.bot.DeleteMessage(ctx, tu.Delete(tu.ID(chatID), pID.(int)))
+	}
 
-		if _, err = c.bot.EditMessageText(ctx, editMsg); err == nil {
-			return nil
+	for _, f := range files {
+		if err := c.sendFile(ctx, chatID, f); err != nil {
+			return fmt.Errorf("failed to send %s: %w", f.Name(), err)
 		}
-		// Fallback to new message if edit fails
 	}
 
-	tgMsg := tu.Message(tu.ID(chatID), htmlContent)
-	tgMsg.ParseMode = telego.ModeHTML
+	return nil
+}
 
-	if _, err = c.bot.SendMessage(ctx, tgMsg); err != nil {
-		logger.ErrorCF("telegram", "HTML parse failed, falling back to plain text", map[string]any{
-			"error": err.Error(),
-		})
-		tgMsg.ParseMode = ""
-		_, err = c.bot.SendMessage(ctx, tgMsg)
-		return err
+func (c *TelegramChannel) SupportsMedia() bool {
+	return true
+}
+
+// telegramMediaLimits are the Bot API upload limits: 10 MB for photos and
+// 50 MB for everything else.
+var telegramMediaLimits = mediaLimits{
+	bus.MediaImage: 10 << 20,
+	bus.MediaFile:  50 << 20,
+}
+
+// telegramMaxMessageLen is the Bot API limit on message text.
+const telegramMaxMessageLen = 4096
+
+// messageFormat returns the configured parse mode's dialect.
+func (c *TelegramChannel) messageFormat() (MessageFormat, string) {
+	if strings.EqualFold(c.config.Channels.Telegram.ParseMode, "markdownv2") {
+		return MessageFormat{Dialect: DialectTelegramMarkdownV2, MaxLen: telegramMaxMessageLen}, telego.ModeMarkdownV2
+	}
+	return MessageFormat{Dialect: DialectTelegramHTML, MaxLen: telegramMaxMessageLen}, telego.ModeHTML
+}
+
+func (c *TelegramChannel) SupportsInteractive() bool {
+	return true
+}
+
+// replyMarkup builds the keyboard for the interactive elements. Buttons and
+// menus become an inline keyboard under the message. Quick replies become a
+// one-time keyboard in place of the user's, unless the message also has an
+// inline keyboard, which they then join, as a message takes one keyboard.
+// Quick replies that open links are returned separately: reply keyboard
+// buttons can only send their label.
+func (c *TelegramChannel) replyMarkup(
+	chatID string,
+	elements []bus.InteractiveElement,
+) (telego.ReplyMarkup, []bus.InteractiveOption) {
+	if len(elements) == 0 {
+		return nil, nil
+	}
+
+	inline := false
+	for _, el := range elements {
+		inline = inline || el.Kind != bus.InteractiveQuickReplies
+	}
+
+	if !inline {
+		links, elements := linkOptions(elements, bus.InteractiveQuickReplies)
+		var rows [][]telego.KeyboardButton
+		for _, el := range elements {
+			var buttons []telego.KeyboardButton
+			for _, opt := range el.Options {
+				c.interactions.register(chatID, el, opt)
+				buttons = append(buttons, tu.KeyboardButton(opt.Label))
+			}
+			rows = append(rows, tu.KeyboardCols(telegramButtonCols(el), buttons...)...)
+		}
+		if len(rows) == 0 {
+			return nil, links
+		}
+		return tu.Keyboard(rows...).WithResizeKeyboard().WithOneTimeKeyboard(), links
+	}
+
+	var rows [][]telego.InlineKeyboardButton
+	for _, el := range elements {
+		var buttons []telego.InlineKeyboardButton
+		for _, opt := range el.Options {
+			button := tu.InlineKeyboardButton(opt.Label)
+			if opt.URL != "" {
+				button = button.WithURL(opt.URL)
+			} else {
+				button = button.WithCallbackData(c.interactions.register(chatID, el, opt))
+			}
+			if opt.Style == "primary" || opt.Style == "danger" {
+				button = button.WithStyle(opt.Style)
+			}
+			buttons = append(buttons, button)
+		}
+		rows = append(rows, tu.InlineKeyboardCols(telegramButtonCols(el), buttons...)...)
+	}
+	return tu.InlineKeyboard(rows...), nil
+}
+
+// telegramButtonCols lays short button labels out three to a row and
+// everything else, including menus, one to a row.
+func telegramButtonCols(el bus.InteractiveElement) int {
+	if el.Kind == bus.InteractiveSelect {
+		return 1
+	}
+	for _, opt := range el.Options {
+		if utf8.RuneCountInString(opt.Label) > 12 {
+			return 1
+		}
+	}
+	return 3
+}
+
+// sendText sends content in as many messages as it takes, the first
+// replacing the "Thinking..." placeholder and the last carrying markup.
+func (c *TelegramChannel) sendText(
+	ctx context.Context,
+	chatID int64,
+	key, content string,
+	markup telego.ReplyMarkup,
+) error {
+	format, parseMode := c.messageFormat()
+	inlineMarkup, _ := markup.(*telego.InlineKeyboardMarkup)
+
+	chunks := format.Split(content)
+	for i, chunk := range chunks {
+		formatted := RenderMarkdown(chunk, format.Dialect)
+		last := i == len(chunks)-1
+
+		if i == 0 {
+			// Try to edit placeholder. Only inline keyboards can be added
+			// by editing; for a reply keyboard the placeholder is replaced.
+			if pID, ok := c.placeholders.LoadAndDelete(key); ok {
+				if last && markup != nil && inlineMarkup == nil {
+					c.bot.DeleteMessage(ctx, tu.Delete(tu.ID(chatID), pID.(int)))
+				} else {
+					editMsg := tu.EditMessageText(tu.ID(chatID), pID.(int), formatted)
+					editMsg.ParseMode = parseMode
+					if last {
+						editMsg.ReplyMarkup = inlineMarkup
+					}
+
+					if _, err := c.bot.EditMessageText(ctx, editMsg); err == nil {
+						continue
+					}
+					// Fallback to new message if edit fails
+				}
+			}
+		}
+
+		tgMsg := tu.Message(tu.ID(chatID), formatted)
+		tgMsg.ParseMode = parseMode
+		if last {
+			tgMsg.ReplyMarkup = markup
+		}
+
+		if _, err := c.bot.SendMessage(ctx, tgMsg); err != nil {
+			logger.ErrorCF("telegram", "Formatted send failed, falling back to plain text", map[string]any{
+				"parse_mode": parseMode,
+				"error":      err.Error(),
+			})
+			tgMsg.Text = RenderMarkdown(chunk, DialectPlain)
+			tgMsg.ParseMode = ""
+			if _, err := c.bot.SendMessage(ctx, tgMsg); err != nil {
+				return err
+			}
+		}
 	}
 
 	return nil
 }
 
+// sendFile uploads f as a photo, audio, video or document message.
+func (c *TelegramChannel) sendFile(ctx context.Context, chatID int64, f outboundFile) error {
+	r, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	file := tu.File(tu.NameReader(r, f.Name()))
+	caption := utils.Truncate(f.Caption, 1024)
+	id := tu.ID(chatID)
+
+	switch f.kind {
+	case bus.MediaImage:
+		_, err = c.bot.SendPhoto(ctx, tu.Photo(id, file).WithCaption(caption))
+	case bus.MediaAudio:
+		_, err = c.bot.SendAudio(ctx, tu.Audio(id, file).WithCaption(caption))
+	case bus.MediaVideo:
+		_, err = c.bot.SendVideo(ctx, tu.Video(id, file).WithCaption(caption))
+	default:
+		_, err = c.bot.SendDocument(ctx, tu.Document(id, file).WithCaption(caption))
+	}
+	return err
+}
+
 func (c *TelegramChannel) handleMessage(ctx context.Context, message *telego.Message) error {
 	if message == nil {
 		return fmt.Errorf("message is nil")
@@ -246,6 +428,36 @@ func (c *TelegramChannel) handleMessage(ctx context.Context, message *telego.Mes
 		content += message.Caption
 	}
 
+	peerKind := "direct"
+	peerID := fmt.Sprintf("%d", user.ID)
+	if message.Chat.Type != "private" {
+		peerKind = "group"
+		peerID = fmt.Sprintf("%d", chatID)
+	}
+
+	metadata := map[string]string{
+		"message_id": fmt.Sprintf("%d", message.MessageID),
+		"user_id":    fmt.Sprintf("%d", user.ID),
+		"username":   user.Username,
+		"first_name": user.FirstName,
+		"is_group":   fmt.Sprintf("%t", message.Chat.Type != "private"),
+		"peer_kind":  peerKind,
+		"peer_id":    peerID,
+	}
+
+	// Check the group policy before downloading any attachments.
+	if message.Chat.Type != "private" {
+		mentioned := c.isBotMentioned(message)
+		if mentioned {
+			content = c.stripBotMention(content)
+		}
+		var ok bool
+		content, _, ok = c.admitGroupMessage(senderID, fmt.Sprintf("%d", chatID), content, mentioned, metadata)
+		if !ok {
+			return nil
+		}
+	}
+
 	if len(message.Photo) > 0 {
 		photo := message.Photo[len(message.Photo)-1]
 		photoPath := c.downloadPhoto(ctx, photo.FileID)
@@ -354,24 +566,77 @@ func (c *TelegramChannel) handleMessage(ctx context.Context, message *telego.Mes
 		c.placeholders.Store(chatIDStr, pID)
 	}
 
-	peerKind := "direct"
-	peerID := fmt.Sprintf("%d", user.ID)
-	if message.Chat.Type != "private" {
-		peerKind = "group"
-		peerID = fmt.Sprintf("%d", chatID)
+	content = c.resolveQuickReply(chatIDStr, content, metadata)
+
+	c.HandleMessage(fmt.Sprintf("%d", user.ID), fmt.Sprintf("%d", chatID), content, mediaPaths, metadata)
+	return nil
+}
+
+// isBotMentioned reports whether a group message addresses the bot, by
+// @username or by replying to one of its messages.
+func (c *TelegramChannel) isBotMentioned(message *telego.Message) bool {
+	if reply := message.ReplyToMessage; reply != nil && reply.From != nil && reply.From.ID == c.bot.ID() {
+		return true
+	}
+	username := c.bot.Username()
+	if username == "" {
+		return false
+	}
+	text := strings.ToLower(message.Text + " " + message.Caption)
+	return strings.Contains(text, "@"+strings.ToLower(username))
+}
+
+// stripBotMention removes the bot's @username from text.
+func (c *TelegramChannel) stripBotMention(text string) string {
+	username := c.bot.Username()
+	if username == "" {
+		return text
+	}
+	mention := "@" + username
+	for i := 0; i+len(mention) <= len(text); {
+		if strings.EqualFold(text[i:i+len(mention)], mention) {
+			text = text[:i] + text[i+len(mention):]
+			continue
+		}
+		i++
+	}
+	return strings.TrimSpace(text)
+}
+
+// handleCallbackQuery handles a press of an inline keyboard button.
+func (c *TelegramChannel) handleCallbackQuery(ctx context.Context, query telego.CallbackQuery) error {
+	answer := tu.CallbackQuery(query.ID)
+	defer c.bot.AnswerCallbackQuery(ctx, answer)
+
+	user := query.From
+	senderID := fmt.Sprintf("%d", user.ID)
+	if user.Username != "" {
+		senderID = fmt.Sprintf("%d|%s", user.ID, user.Username)
+	}
+	if !c.IsAllowed(senderID) {
+		return nil
 	}
 
 	metadata := map[string]string{
-		"message_id": fmt.Sprintf("%d", message.MessageID),
 		"user_id":    fmt.Sprintf("%d", user.ID),
 		"username":   user.Username,
 		"first_name": user.FirstName,
-		"is_group":   fmt.Sprintf("%t", message.Chat.Type != "private"),
-		"peer_kind":  peerKind,
-		"peer_id":    peerID,
+		"peer_kind":  "direct",
+		"peer_id":    fmt.Sprintf("%d", user.ID),
+	}
+	if query.Message != nil {
+		chat := query.Message.GetChat()
+		metadata["is_group"] = fmt.Sprintf("%t", chat.Type != "private")
+		if chat.Type != "private" {
+			metadata["peer_kind"] = "group"
+			metadata["peer_id"] = fmt.Sprintf("%d", chat.ID)
+		}
+		c.bot.SendChatAction(ctx, tu.ChatAction(chat.ChatID(), telego.ChatActionTyping))
 	}
 
-	c.HandleMessage(fmt.Sprintf("%d", user.ID), fmt.Sprintf("%d", chatID), content, mediaPaths, metadata)
+	if !c.HandleInteraction(fmt.Sprintf("%d", user.ID), query.Data, metadata) {
+		answer.WithText("This option has expired.")
+	}
 	return nil
 }
 
@@ -419,111 +684,3 @@ func parseChatID(chatIDStr string) (int64, error) {
 	_, err := fmt.Sscanf(chatIDStr, "%d", &id)
 	return id, err
 }
-
-func markdownToTelegramHTML(text string) string {
-	if text == "" {
-		return ""
-	}
-
-	codeBlocks := extractCodeBlocks(text)
-	text = codeBlocks.text
-
-	inlineCodes := extractInlineCodes(text)
-	text = inlineCodes.text
-
-	text = regexp.MustCompile(`^#{1,6}\s+(.+)$`).ReplaceAllString(text, "$1")
-
-	text = regexp.MustCompile(`^>\s*(.*)$`).ReplaceAllString(text, "$1")
-
-	text = escapeHTML(text)
-
-	text = regexp.MustCompile(`\[([^\]]+)\]\(([^)]+)\)`).ReplaceAllString(text, `<a href="$2">$1</a>`)
-
-	text = regexp.MustCompile(`\*\*(.+?)\*\*`).ReplaceAllString(text, "<b>$1</b>")
-
-	text = regexp.MustCompile(`__(.+?)__`).ReplaceAllString(text, "<b>$1</b>")
-
-	reItalic := regexp.MustCompile(`_([^_]+)_`)
-	text = reItalic.ReplaceAllStringFunc(text, func(s string) string {
-		match := reItalic.FindStringSubmatch(s)
-		if len(match) < 2 {
-			return s
-		}
-		return "<i>" + match[1] + "</i>"
-	})
-
-	text = regexp.MustCompile(`~~(.+?)~~`).ReplaceAllString(text, "<s>$1</s>")
-
-	text = regexp.MustCompile(`^[-*]\s+`).ReplaceAllString(text, "• ")
-
-	for i, code := range inlineCodes.codes {
-		escaped := escapeHTML(code)
-		text = strings.ReplaceAll(text, fmt.Sprintf("\x00IC%d\x00", i), fmt.Sprintf("<code>%s</code>", escaped))
-	}
-
-	for i, code := range codeBlocks.codes {
-		escaped := escapeHTML(code)
-		text = strings.ReplaceAll(
-			text,
-			fmt.Sprintf("\x00CB%d\x00", i),
-			fmt.Sprintf("<pre><code>%s</code></pre>", escaped),
-		)
-	}
-
-	return text
-}
-
-type codeBlockMatch struct {
-	text  string
-	codes []string
-}
-
-func extractCodeBlocks(text string) codeBlockMatch {
-	re := regexp.MustCompile("```[\\w]*\\n?([\\s\\S]*?)```")
-	matches := re.FindAllStringSubmatch(text, -1)
-
-	codes := make([]string, 0, len(matches))
-	for _, match := range matches {
-		codes = append(codes, match[1])
-	}
-
-	i := 0
-	text = re.ReplaceAllStringFunc(text, func(m string) string {
-		placeholder := fmt.Sprintf("\x00CB%d\x00", i)
-		i++
-		return placeholder
-	})
-
-	return codeBlockMatch{text: text, codes: codes}
-}
-
-type inlineCodeMatch struct {
-	text  string
-	codes []string
-}
-
-func extractInlineCodes(text string) inlineCodeMatch {
-	re := regexp.MustCompile("`([^`]+)`")
-	matches := re.FindAllStringSubmatch(text, -1)
-
-	codes := make([]string, 0, len(matches))
-	for _, match := range matches {
-		codes = append(codes, match[1])
-	}
-
-	i := 0
-	text = re.ReplaceAllStringFunc(text, func(m string) string {
-		placeholder := fmt.Sprintf("\x00IC%d\x00", i)
-		i++
-		return placeholder
-	})
-
-	return inlineCodeMatch{text: text, codes: codes}
-}
-
-func escapeHTML(text string) string {
-	text = strings.ReplaceAll(text, "&", "&amp;")
-	text = strings.ReplaceAll(text, "<", "&lt;")
-	text = strings.ReplaceAll(text, ">", "&gt;")
-	return text
-}
@@ -9,14 +9,19 @@ import (
 	"context"
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/md5"
 	"crypto/sha1"
 	"encoding/base64"
 	"encoding/binary"
+	"encoding/hex"
 	"encoding/json"
 	"encoding/xml"
 	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
+	"net/textproto"
+	"net/url"
 	"sort"
 	"strings"
 	"sync"
@@ -97,6 +102,7 @@ func NewWeComBotChannel(cfg config.WeComConfig, messageBus *bus.MessageBus) (*We
 	}
 
 	base := NewBaseChannel("wecom", cfg, messageBus, cfg.AllowFrom)
+	base.setGroupPolicy(cfg.Groups, config.GroupPolicy{})
 
 	return &WeComBotChannel{
 		BaseChannel:   base,
@@ -183,7 +189,39 @@ func (c *WeComBotChannel) Send(ctx context.Context, msg bus.OutboundMessage) err
 		"preview": utils.Truncate(msg.Content, 100),
 	})
 
-	return c.sendWebhookReply(ctx, msg.ChatID, msg.Content)
+	files, notices := wecomBotMediaLimits.prepare(msg.Media)
+	content := appendNotices(msg.Content, notices)
+
+	if content != "" || len(files) == 0 {
+		for _, chunk := range wecomFormat.Render(content) {
+			if err := c.sendWebhookReply(ctx, msg.ChatID, chunk); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, f := range files {
+		if err := c.sendWebhookFile(ctx, msg.ChatID, f); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *WeComBotChannel) SupportsMedia() bool {
+	return true
+}
+
+// wecomFormat is plain text within the 2048 byte limit of WeCom text
+// messages, shared by the bot and the app.
+var wecomFormat = MessageFormat{Dialect: DialectPlain, MaxLen: 2048}
+
+// wecomBotMediaLimits are the webhook limits: 2 MB for inline images and
+// 20 MB for uploaded files.
+var wecomBotMediaLimits = mediaLimits{
+	bus.MediaImage: 2 << 20,
+	bus.MediaFile:  20 << 20,
 }
 
 // handleWebhook handles incoming webhook requests from WeCom
@@ -398,6 +436,12 @@ func (c *WeComBotChannel) processMessage(ctx context.Context, msg WeComBotMessag
 	if isGroupChat {
 		metadata["chat_id"] = msg.ChatID
 		metadata["sender_id"] = senderID
+
+		// The bot only receives group messages that @ it.
+		var ok bool
+		if content, _, ok = c.admitGroupMessage(senderID, chatID, content, true, metadata); !ok {
+			return
+		}
 	}
 
 	logger.DebugCF("wecom", "Received message", map[string]any{
@@ -419,7 +463,51 @@ func (c *WeComBotChannel) sendWebhookReply(ctx context.Context, userID, content
 	}
 	reply.Text.Content = content
 
-	jsonData, err := json.Marshal(reply)
+	return c.postWebhook(ctx, reply)
+}
+
+// sendWebhookFile sends f through the webhook, images inline as base64 and
+// other files by uploading them first. The webhook has no captions, so a
+// caption goes out as a text message before the file.
+func (c *WeComBotChannel) sendWebhookFile(ctx context.Context, userID string, f outboundFile) error {
+	if f.Caption != "" {
+		if err := c.sendWebhookReply(ctx, userID, f.Caption); err != nil {
+			return err
+		}
+	}
+
+	if f.kind == bus.MediaImage && wecomImageFormat(f) {
+		data, err := f.ReadAll()
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", f.Name(), err)
+		}
+		sum := md5.Sum(data)
+		return c.postWebhook(ctx, map[string]any{
+			"msgtype": "image",
+			"image": map[string]string{
+				"base64": base64.StdEncoding.EncodeToString(data),
+				"md5":    hex.EncodeToString(sum[:]),
+			},
+		})
+	}
+
+	uploadURL, err := wecomWebhookUploadURL(c.config.WebhookURL)
+	if err != nil {
+		return err
+	}
+	mediaID, err := uploadWeComMedia(ctx, uploadURL, f)
+	if err != nil {
+		return err
+	}
+	return c.postWebhook(ctx, map[string]any{
+		"msgtype": "file",
+		"file":    map[string]string{"media_id": mediaID},
+	})
+}
+
+// postWebhook posts a message payload to the webhook URL.
+func (c *WeComBotChannel) postWebhook(ctx context.Context, payload any) error {
+	jsonData, err := json.Marshal(payload)
 	if err != nil {
 		return fmt.Errorf("failed to marshal reply: %w", err)
 	}
@@ -467,6 +555,90 @@ func (c *WeComBotChannel) sendWebhookReply(ctx context.Context, userID, content
 	return nil
 }
 
+// wecomWebhookUploadURL derives the upload_media URL from a webhook send
+// URL, keeping its key.
+func wecomWebhookUploadURL(webhookURL string) (string, error) {
+	u, err := url.Parse(webhookURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid webhook URL: %w", err)
+	}
+	q := u.Query()
+	if q.Get("key") == "" {
+		return "", fmt.Errorf("webhook URL has no key")
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/send") + "/upload_media"
+	q.Set("type", "file")
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// wecomImageFormat reports whether WeCom displays f as an image; it only
+// accepts JPEG and PNG.
+func wecomImageFormat(f outboundFile) bool {
+	t := f.ContentType()
+	return t == "image/jpeg" || t == "image/png"
+}
+
+// uploadWeComMedia uploads f as multipart form data to a WeCom media upload
+// endpoint and returns the media_id.
+func uploadWeComMedia(ctx context.Context, uploadURL string, f outboundFile) (string, error) {
+	r, err := f.Open()
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %w", f.Name(), err)
+	}
+	defer r.Close()
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition",
+		fmt.Sprintf(`form-data; name="media"; filename=%q; filelength=%d`, f.Name(), f.size))
+	header.Set("Content-Type", f.ContentType())
+	part, err := mw.CreatePart(header)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(part, r); err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", f.Name(), err)
+	}
+	if err := mw.Close(); err != nil {
+		return "", err
+	}
+
+	reqCtx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodPost, uploadURL, &body)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to upload %s: %w", f.Name(), err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var result struct {
+		ErrCode int    `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+		MediaID string `json:"media_id"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+	if result.ErrCode != 0 {
+		return "", fmt.Errorf("media upload error: %s (code: %d)", result.ErrMsg, result.ErrCode)
+	}
+	return result.MediaID, nil
+}
+
 // handleHealth handles health check requests
 func (c *WeComBotChannel) handleHealth(w http.ResponseWriter, r *http.Request) {
 	status := map[string]any{
@@ -223,7 +223,71 @@ func (c *WeComAppChannel) Send(ctx context.Context, msg bus.OutboundMessage) err
 		"preview": utils.Truncate(msg.Content, 100),
 	})
 
-	return c.sendTextMessage(ctx, accessToken, msg.ChatID, msg.Content)
+	files, notices := wecomAppMediaLimits.prepare(msg.Media)
+	content := appendNotices(msg.Content, notices)
+
+	if content != "" || len(files) == 0 {
+		for _, chunk := range wecomFormat.Render(content) {
+			if err := c.sendTextMessage(ctx, accessToken, msg.ChatID, chunk); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, f := range files {
+		if err := c.sendFile(ctx, accessToken, msg.ChatID, f); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *WeComAppChannel) SupportsMedia() bool {
+	return true
+}
+
+// wecomAppMediaLimits are the temporary media upload limits.
+var wecomAppMediaLimits = mediaLimits{
+	bus.MediaImage: 10 << 20,
+	bus.MediaVideo: 10 << 20,
+	bus.MediaFile:  20 << 20,
+}
+
+// sendFile uploads f as temporary media and sends it as an image, video or
+// file message. Media messages have no caption, so a caption goes out as
+// text first. Voice messages only take AMR, so audio is sent as a file.
+func (c *WeComAppChannel) sendFile(ctx context.Context, accessToken, userID string, f outboundFile) error {
+	if f.Caption != "" {
+		if err := c.sendTextMessage(ctx, accessToken, userID, f.Caption); err != nil {
+			return err
+		}
+	}
+
+	msgType := "file"
+	switch {
+	case f.kind == bus.MediaImage && wecomImageFormat(f):
+		msgType = "image"
+	case f.kind == bus.MediaVideo && f.ContentType() == "video/mp4":
+		msgType = "video"
+	}
+
+	uploadURL := fmt.Sprintf("%s/cgi-bin/media/upload?access_token=%s&type=%s", wecomAPIBase, accessToken, msgType)
+	mediaID, err := uploadWeComMedia(ctx, uploadURL, f)
+	if err != nil {
+		return err
+	}
+
+	media := map[string]string{"media_id": mediaID}
+	if msgType == "video" {
+		media["title"] = f.Name()
+	}
+	return c.postMessage(ctx, accessToken, map[string]any{
+		"touser":  userID,
+		"msgtype": msgType,
+		"agentid": c.config.AgentID,
+		msgType:   media,
+	})
 }
 
 // handleWebhook handles incoming webhook requests from WeCom
@@ -518,8 +582,6 @@ func (c *WeComAppChannel) getAccessToken() string {
 
 // sendTextMessage sends a text message to a user
 func (c *WeComAppChannel) sendTextMessage(ctx context.Context, accessToken, userID, content string) error {
-	apiURL := fmt.Sprintf("%s/cgi-bin/message/send?access_token=%s", wecomAPIBase, accessToken)
-
 	msg := WeComTextMessage{
 		ToUser:  userID,
 		MsgType: "text",
@@ -527,54 +589,11 @@ func (c *WeComAppChannel) sendTextMessage(ctx context.Context, accessToken, user
 	}
 	msg.Text.Content = content
 
-	jsonData, err := json.Marshal(msg)
-	if err != nil {
-		return fmt.Errorf("failed to marshal message: %w", err)
-	}
-
-	// Use configurable timeout (default 5 seconds)
-	timeout := c.config.ReplyTimeout
-	if timeout <= 0 {
-		timeout = 5
-	}
-
-	reqCtx, cancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
-	defer cancel()
-
-	req, err := http.NewRequestWithContext(reqCtx, http.MethodPost, apiURL, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
-	}
-	req.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{Timeout: time.Duration(timeout) * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to send message: %w", err)
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Errorf("failed to read response: %w", err)
-	}
-
-	var sendResp WeComSendMessageResponse
-	if err := json.Unmarshal(body, &sendResp); err != nil {
-		return fmt.Errorf("failed to parse response: %w", err)
-	}
-
-	if sendResp.ErrCode != 0 {
-		return fmt.Errorf("API error: %s (code: %d)", sendResp.ErrMsg, sendResp.ErrCode)
-	}
-
-	return nil
+	return c.postMessage(ctx, accessToken, msg)
 }
 
 // sendMarkdownMessage sends a markdown message to a user
 func (c *WeComAppChannel) sendMarkdownMessage(ctx context.Context, accessToken, userID, content string) error {
-	apiURL := fmt.Sprintf("%s/cgi-bin/message/send?access_token=%s", wecomAPIBase, accessToken)
-
 	msg := WeComMarkdownMessage{
 		ToUser:  userID,
 		MsgType: "markdown",
@@ -582,6 +601,13 @@ func (c *WeComAppChannel) sendMarkdownMessage(ctx context.Context, accessToken,
 	}
 	msg.Markdown.Content = content
 
+	return c.postMessage(ctx, accessToken, msg)
+}
+
+// postMessage posts a message to the message/send API
+func (c *WeComAppChannel) postMessage(ctx context.Context, accessToken string, msg any) error {
+	apiURL := fmt.Sprintf("%s/cgi-bin/message/send?access_token=%s", wecomAPIBase, accessToken)
+
 	jsonData, err := json.Marshal(msg)
 	if err != nil {
 		return fmt.Errorf("failed to marshal message: %w", err)
@@ -26,6 +26,7 @@ type WhatsAppChannel struct {
 
 func NewWhatsAppChannel(cfg config.WhatsAppConfig, bus *bus.MessageBus) (*WhatsAppChannel, error) {
 	base := NewBaseChannel("whatsapp", cfg, bus, cfg.AllowFrom)
+	base.setGroupPolicy(cfg.Groups, config.GroupPolicy{})
 
 	return &WhatsAppChannel{
 		BaseChannel: base,
@@ -86,24 +87,30 @@ func (c *WhatsAppChannel) Send(ctx context.Context, msg bus.OutboundMessage) err
 		return fmt.Errorf("whatsapp connection not established")
 	}
 
-	payload := map[string]any{
-		"type":    "message",
-		"to":      msg.ChatID,
-		"content": msg.Content,
-	}
+	for _, chunk := range whatsAppFormat.Render(msg.Content) {
+		payload := map[string]any{
+			"type":    "message",
+			"to":      msg.ChatID,
+			"content": chunk,
+		}
 
-	data, err := json.Marshal(payload)
-	if err != nil {
-		return fmt.Errorf("failed to marshal message: %w", err)
-	}
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("failed to marshal message: %w", err)
+		}
 
-	if err := c.conn.WriteMessage(websocket.TextMessage, data); err != nil {
-		return fmt.Errorf("failed to send message: %w", err)
+		if err := c.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+			return fmt.Errorf("failed to send message: %w", err)
+		}
 	}
 
 	return nil
 }
 
+// whatsAppFormat is WhatsApp's own emphasis syntax, in messages of at most
+// 4096 characters.
+var whatsAppFormat = MessageFormat{Dialect: DialectWhatsApp, MaxLen: 4096}
+
 func (c *WhatsAppChannel) listen(ctx context.Context) {
 	for {
 		select {
@@ -184,6 +191,12 @@ func (c *WhatsAppChannel) handleIncomingMessage(msg map[string]any) {
 	} else {
 		metadata["peer_kind"] = "group"
 		metadata["peer_id"] = chatID
+
+		// The bridge reports mentions of the bot's own number, if it can.
+		mentioned, _ := msg["mentioned"].(bool)
+		if content, _, ok = c.admitGroupMessage(senderID, chatID, content, mentioned, metadata); !ok {
+			return
+		}
 	}
 
 	log.Printf("WhatsApp message from %s: %s...", senderID, utils.Truncate(content, 50))
@@ -0,0 +1,668 @@
+package channels
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/utils"
+	"github.com/sipeed/picoclaw/pkg/voice"
+)
+
+const (
+	whatsAppCloudAPIBase = "https://graph.facebook.com"
+	// whatsAppCloudWindow is how long after a user's last message the
+	// business may send free-form messages. Outside it only approved
+	// templates are delivered.
+	whatsAppCloudWindow = 24 * time.Hour
+	// whatsAppCloudTemplateParam bounds the text put in a template's body
+	// parameter.
+	whatsAppCloudTemplateParam = 1000
+)
+
+// whatsAppCloudMediaLimits are the Cloud API's upload limits.
+var whatsAppCloudMediaLimits = mediaLimits{
+	bus.MediaImage: 5 << 20,
+	bus.MediaAudio: 16 << 20,
+	bus.MediaVideo: 16 << 20,
+	bus.MediaFile:  100 << 20,
+}
+
+// WhatsAppCloudChannel talks to Meta's WhatsApp Business Cloud API
+// directly: messages arrive on a webhook and are sent through the Graph
+// API, without the WebSocket bridge WhatsAppChannel needs.
+type WhatsAppCloudChannel struct {
+	*BaseChannel
+	config      config.WhatsAppCloudConfig
+	apiBase     string // Graph API base including the version
+	client      *http.Client
+	httpServer  *http.Server
+	transcriber *voice.GroqTranscriber
+	ctx         context.Context
+	cancel      context.CancelFunc
+	windows     sync.Map // chat ID -> time.Time of the user's last message
+	mu          sync.Mutex
+	dedup       map[string]struct{}
+	dedupRing   []string
+	dedupIdx    int
+}
+
+type whatsAppCloudWebhook struct {
+	Entry []struct {
+		Changes []struct {
+			Value whatsAppCloudValue `json:"value"`
+		} `json:"changes"`
+	} `json:"entry"`
+}
+
+type whatsAppCloudValue struct {
+	Contacts []struct {
+		WaID    string `json:"wa_id"`
+		Profile struct {
+			Name string `json:"name"`
+		} `json:"profile"`
+	} `json:"contacts"`
+	Messages []whatsAppCloudMessage `json:"messages"`
+	Statuses []whatsAppCloudStatus  `json:"statuses"`
+}
+
+type whatsAppCloudMessage struct {
+	From        string                    `json:"from"`
+	ID          string                    `json:"id"`
+	Type        string                    `json:"type"`
+	Text        *struct{ Body string }    `json:"text"`
+	Image       *whatsAppCloudMedia       `json:"image"`
+	Audio       *whatsAppCloudMedia       `json:"audio"`
+	Video       *whatsAppCloudMedia       `json:"video"`
+	Document    *whatsAppCloudMedia       `json:"document"`
+	Sticker     *whatsAppCloudMedia       `json:"sticker"`
+	Button      *struct{ Text string }    `json:"button"`
+	Interactive *whatsAppCloudInteractive `json:"interactive"`
+}
+
+type whatsAppCloudMedia struct {
+	ID       string `json:"id"`
+	MimeType string `json:"mime_type"`
+	Caption  string `json:"caption"`
+	Filename string `json:"filename"`
+	Voice    bool   `json:"voice"`
+}
+
+type whatsAppCloudInteractive struct {
+	ButtonReply *struct{ Title string } `json:"button_reply"`
+	ListReply   *struct{ Title string } `json:"list_reply"`
+}
+
+type whatsAppCloudStatus struct {
+	ID          string `json:"id"`
+	Status      string `json:"status"`
+	RecipientID string `json:"recipient_id"`
+	Errors      []struct {
+		Code  int    `json:"code"`
+		Title string `json:"title"`
+	} `json:"errors"`
+}
+
+func NewWhatsAppCloudChannel(
+	cfg config.WhatsAppCloudConfig,
+	messageBus *bus.MessageBus,
+) (*WhatsAppCloudChannel, error) {
+	if cfg.PhoneNumberID == "" || cfg.AccessToken == "" {
+		return nil, fmt.Errorf("whatsapp_cloud phone_number_id and access_token are required")
+	}
+
+	version := cfg.APIVersion
+	if version == "" {
+		version = "v21.0"
+	}
+
+	base := NewBaseChannel("whatsapp_cloud", cfg, messageBus, cfg.AllowFrom)
+
+	return &WhatsAppCloudChannel{
+		BaseChannel: base,
+		config:      cfg,
+		apiBase:     whatsAppCloudAPIBase + "/" + version,
+		client:      &http.Client{Timeout: 60 * time.Second},
+		dedup:       make(map[string]struct{}),
+		dedupRing:   make([]string, 1024),
+	}, nil
+}
+
+func (c *WhatsAppCloudChannel) SetTranscriber(transcriber *voice.GroqTranscriber) {
+	c.transcriber = transcriber
+}
+
+func (c *WhatsAppCloudChannel) Start(ctx context.Context) error {
+	logger.InfoC("whatsapp_cloud", "Starting WhatsApp Cloud API channel (Webhook Mode)")
+
+	c.ctx, c.cancel = context.WithCancel(ctx)
+
+	path := c.config.WebhookPath
+	if path == "" {
+		path = "/webhook/whatsapp"
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(path, c.webhookHandler)
+
+	addr := fmt.Sprintf("%s:%d", c.config.WebhookHost, c.config.WebhookPort)
+	c.httpServer = &http.Server{
+		Addr:    addr,
+		Handler: mux,
+	}
+
+	go func() {
+		logger.InfoCF("whatsapp_cloud", "WhatsApp Cloud webhook server listening", map[string]any{
+			"addr": addr,
+			"path": path,
+		})
+		if err := c.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.ErrorCF("whatsapp_cloud", "Webhook server error", map[string]any{
+				"error": err.Error(),
+			})
+		}
+	}()
+
+	c.setRunning(true)
+	logger.InfoC("whatsapp_cloud", "WhatsApp Cloud API channel started")
+	return nil
+}
+
+func (c *WhatsAppCloudChannel) Stop(ctx context.Context) error {
+	logger.InfoC("whatsapp_cloud", "Stopping WhatsApp Cloud API channel")
+
+	if c.cancel != nil {
+		c.cancel()
+	}
+
+	if c.httpServer != nil {
+		shutdownCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		if err := c.httpServer.Shutdown(shutdownCtx); err != nil {
+			logger.ErrorCF("whatsapp_cloud", "Webhook server shutdown error", map[string]any{
+				"error": err.Error(),
+			})
+		}
+	}
+
+	c.setRunning(false)
+	logger.InfoC("whatsapp_cloud", "WhatsApp Cloud API channel stopped")
+	return nil
+}
+
+func (c *WhatsAppCloudChannel) SupportsMedia() bool {
+	return true
+}
+
+// webhookHandler answers Meta's subscription check (GET) and receives
+// notifications (POST), which must be signed with the app secret.
+func (c *WhatsAppCloudChannel) webhookHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		q := r.URL.Query()
+		if q.Get("hub.mode") != "subscribe" || c.config.VerifyToken == "" ||
+			!hmac.Equal([]byte(q.Get("hub.verify_token")), []byte(c.config.VerifyToken)) {
+			logger.WarnC("whatsapp_cloud", "Webhook verification failed")
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		io.WriteString(w, q.Get("hub.challenge"))
+		return
+	case http.MethodPost:
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+	if !c.verifySignature(body, r.Header.Get("X-Hub-Signature-256")) {
+		logger.WarnC("whatsapp_cloud", "Invalid webhook signature")
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	var payload whatsAppCloudWebhook
+	if err := json.Unmarshal(body, &payload); err != nil {
+		logger.ErrorCF("whatsapp_cloud", "Failed to parse webhook payload", map[string]any{
+			"error": err.Error(),
+		})
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	// Return 200 immediately; Meta retries notifications that are slow
+	// to be acknowledged.
+	w.WriteHeader(http.StatusOK)
+
+	for _, entry := range payload.Entry {
+		for _, change := range entry.Changes {
+			c.processValue(change.Value)
+		}
+	}
+}
+
+func (c *WhatsAppCloudChannel) verifySignature(body []byte, signature string) bool {
+	hexSig, ok := strings.CutPrefix(signature, "sha256=")
+	if !ok || c.config.AppSecret == "" {
+		return false
+	}
+	got, err := hex.DecodeString(hexSig)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(c.config.AppSecret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), got)
+}
+
+func (c *WhatsAppCloudChannel) processValue(value whatsAppCloudValue) {
+	names := make(map[string]string, len(value.Contacts))
+	for _, contact := range value.Contacts {
+		names[contact.WaID] = contact.Profile.Name
+	}
+
+	for _, msg := range value.Messages {
+		if c.isDuplicate(msg.ID) {
+			continue
+		}
+		// The window opens with any message from the user, allowed or not.
+		c.windows.Store(msg.From, time.Now())
+		go c.processMessage(msg, names[msg.From])
+	}
+
+	for _, st := range value.Statuses {
+		if st.Status != "failed" {
+			continue
+		}
+		for _, e := range st.Errors {
+			logger.WarnCF("whatsapp_cloud", "Message delivery failed", map[string]any{
+				"message_id":   st.ID,
+				"recipient_id": st.RecipientID,
+				"code":         e.Code,
+				"error":        e.Title,
+			})
+		}
+	}
+}
+
+func (c *WhatsAppCloudChannel) processMessage(msg whatsAppCloudMessage, userName string) {
+	senderID := msg.From
+	if !c.IsAllowed(senderID) {
+		logger.DebugCF("whatsapp_cloud", "Message rejected by allowlist", map[string]any{
+			"sender_id": senderID,
+		})
+		return
+	}
+
+	c.markRead(msg.ID)
+
+	var content string
+	var mediaPaths []string
+	switch {
+	case msg.Text != nil:
+		content = msg.Text.Body
+	case msg.Button != nil:
+		content = msg.Button.Text
+	case msg.Interactive != nil && msg.Interactive.ButtonReply != nil:
+		content = msg.Interactive.ButtonReply.Title
+	case msg.Interactive != nil && msg.Interactive.ListReply != nil:
+		content = msg.Interactive.ListReply.Title
+	case msg.Audio != nil:
+		path := c.downloadMedia(msg.Audio, "voice.ogg")
+		if path == "" {
+			break
+		}
+		mediaPaths = append(mediaPaths, path)
+		content = c.transcribe(path, msg.Audio.Voice)
+	default:
+		for _, m := range []struct {
+			media *whatsAppCloudMedia
+			name  string
+			tag   string
+		}{
+			{msg.Image, "image.jpg", "[image: photo]"},
+			{msg.Sticker, "sticker.webp", "[image: sticker]"},
+			{msg.Video, "video.mp4", "[video]"},
+			{msg.Document, "document", "[file]"},
+		} {
+			if m.media == nil {
+				continue
+			}
+			if path := c.downloadMedia(m.media, m.name); path != "" {
+				mediaPaths = append(mediaPaths, path)
+			}
+			content = appendContent(m.media.Caption, m.tag)
+		}
+	}
+
+	if content == "" && len(mediaPaths) == 0 {
+		logger.DebugCF("whatsapp_cloud", "Ignoring unsupported message", map[string]any{
+			"type": msg.Type,
+		})
+		return
+	}
+
+	metadata := map[string]string{
+		"message_id": msg.ID,
+		"user_name":  userName,
+		"peer_kind":  "direct",
+		"peer_id":    senderID,
+	}
+
+	logger.DebugCF("whatsapp_cloud", "Received message", map[string]any{
+		"sender_id": senderID,
+		"preview":   utils.Truncate(content, 50),
+	})
+
+	c.HandleMessage(senderID, senderID, content, mediaPaths, metadata)
+}
+
+// transcribe returns the content for an audio message saved at path.
+func (c *WhatsAppCloudChannel) transcribe(path string, isVoice bool) string {
+	if !isVoice {
+		return "[audio]"
+	}
+	if c.transcriber == nil || !c.transcriber.IsAvailable() {
+		return "[voice]"
+	}
+
+	ctx, cancel := context.WithTimeout(c.ctx, 30*time.Second)
+	defer cancel()
+	result, err := c.transcriber.Transcribe(ctx, path)
+	if err != nil {
+		logger.ErrorCF("whatsapp_cloud", "Voice transcription failed", map[string]any{
+			"error": err.Error(),
+			"path":  path,
+		})
+		return "[voice (transcription failed)]"
+	}
+	return fmt.Sprintf("[voice transcription: %s]", result.Text)
+}
+
+// downloadMedia looks up a received media item's URL and downloads it,
+// returning the local path or "" on failure.
+func (c *WhatsAppCloudChannel) downloadMedia(media *whatsAppCloudMedia, fallbackName string) string {
+	req, err := http.NewRequestWithContext(c.ctx, http.MethodGet, c.apiBase+"/"+media.ID, nil)
+	if err != nil {
+		return ""
+	}
+	var info struct {
+		URL string `json:"url"`
+	}
+	if err := c.do(req, &info); err != nil || info.URL == "" {
+		logger.ErrorCF("whatsapp_cloud", "Failed to look up media", map[string]any{
+			"media_id": media.ID,
+			"error":    fmt.Sprint(err),
+		})
+		return ""
+	}
+
+	filename := media.Filename
+	if filename == "" {
+		filename = fallbackName
+	}
+	return utils.DownloadFile(info.URL, filename, utils.DownloadOptions{
+		ExtraHeaders: map[string]string{"Authorization": "Bearer " + c.config.AccessToken},
+		LoggerPrefix: "whatsapp_cloud",
+	})
+}
+
+// markRead marks a received message as read, showing the blue ticks.
+func (c *WhatsAppCloudChannel) markRead(messageID string) {
+	ctx, cancel := context.WithTimeout(c.ctx, 10*time.Second)
+	defer cancel()
+	err := c.sendMessage(ctx, map[string]any{
+		"messaging_product": "whatsapp",
+		"status":            "read",
+		"message_id":        messageID,
+	})
+	if err != nil {
+		logger.DebugCF("whatsapp_cloud", "Failed to mark message read", map[string]any{
+			"error": err.Error(),
+		})
+	}
+}
+
+func (c *WhatsAppCloudChannel) isDuplicate(messageID string) bool {
+	if messageID == "" {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, exists := c.dedup[messageID]; exists {
+		return true
+	}
+
+	if old := c.dedupRing[c.dedupIdx]; old != "" {
+		delete(c.dedup, old)
+	}
+	c.dedupRing[c.dedupIdx] = messageID
+	c.dedup[messageID] = struct{}{}
+	c.dedupIdx = (c.dedupIdx + 1) % len(c.dedupRing)
+
+	return false
+}
+
+// windowOpen reports whether the user last wrote to us within the
+// customer service window. Chats we have not heard from since starting
+// count as closed.
+func (c *WhatsAppCloudChannel) windowOpen(chatID string) bool {
+	last, ok := c.windows.Load(chatID)
+	return ok && time.Since(last.(time.Time)) < whatsAppCloudWindow
+}
+
+func (c *WhatsAppCloudChannel) Send(ctx context.Context, msg bus.OutboundMessage) error {
+	if !c.IsRunning() {
+		return fmt.Errorf("whatsapp_cloud channel not running")
+	}
+
+	if !c.windowOpen(msg.ChatID) && c.config.TemplateName != "" {
+		return c.sendTemplate(ctx, mediaAsText(msg))
+	}
+
+	files, notices := whatsAppCloudMediaLimits.prepare(msg.Media)
+	content := appendNotices(msg.Content, notices)
+
+	if content != "" {
+		for _, chunk := range whatsAppFormat.Render(content) {
+			err := c.sendMessage(ctx, map[string]any{
+				"messaging_product": "whatsapp",
+				"to":                msg.ChatID,
+				"type":              "text",
+				"text":              map[string]any{"body": chunk},
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, f := range files {
+		if err := c.sendFile(ctx, msg.ChatID, f); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sendTemplate sends msg as the configured template, for chats outside the
+// customer service window. The template's body takes the message text as
+// its one parameter.
+func (c *WhatsAppCloudChannel) sendTemplate(ctx context.Context, msg bus.OutboundMessage) error {
+	language := c.config.TemplateLanguage
+	if language == "" {
+		language = "en_US"
+	}
+
+	// Template parameters may not contain newlines, tabs or runs of
+	// spaces.
+	param := strings.Join(strings.Fields(RenderMarkdown(msg.Content, DialectWhatsApp)), " ")
+	param = utils.Truncate(param, whatsAppCloudTemplateParam)
+
+	logger.DebugCF("whatsapp_cloud", "Chat outside the service window, sending template", map[string]any{
+		"chat_id":  msg.ChatID,
+		"template": c.config.TemplateName,
+	})
+
+	return c.sendMessage(ctx, map[string]any{
+		"messaging_product": "whatsapp",
+		"to":                msg.ChatID,
+		"type":              "template",
+		"template": map[string]any{
+			"name":     c.config.TemplateName,
+			"language": map[string]string{"code": language},
+			"components": []map[string]any{{
+				"type":       "body",
+				"parameters": []map[string]string{{"type": "text", "text": param}},
+			}},
+		},
+	})
+}
+
+// sendFile uploads f and sends it as the matching message type. Formats
+// WhatsApp cannot show inline go as documents.
+func (c *WhatsAppCloudChannel) sendFile(ctx context.Context, to string, f outboundFile) error {
+	mediaID, err := c.uploadMedia(ctx, f)
+	if err != nil {
+		return err
+	}
+
+	msgType := "document"
+	object := map[string]any{"id": mediaID}
+	switch t := f.ContentType(); {
+	case f.kind == bus.MediaImage && (t == "image/jpeg" || t == "image/png"):
+		msgType = "image"
+	case f.kind == bus.MediaAudio:
+		msgType = "audio"
+	case f.kind == bus.MediaVideo && (t == "video/mp4" || t == "video/3gpp"):
+		msgType = "video"
+	default:
+		object["filename"] = f.Name()
+	}
+	if f.Caption != "" && msgType != "audio" {
+		object["caption"] = f.Caption
+	}
+
+	return c.sendMessage(ctx, map[string]any{
+		"messaging_product": "whatsapp",
+		"to":                to,
+		"type":              msgType,
+		msgType:             object,
+	})
+}
+
+// uploadMedia uploads f to the phone number's media store and returns the
+// media ID.
+func (c *WhatsAppCloudChannel) uploadMedia(ctx context.Context, f outboundFile) (string, error) {
+	r, err := f.Open()
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %w", f.Name(), err)
+	}
+	defer r.Close()
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("messaging_product", "whatsapp")
+	mw.WriteField("type", f.ContentType())
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename=%q`, f.Name()))
+	header.Set("Content-Type", f.ContentType())
+	part, err := mw.CreatePart(header)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(part, r); err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", f.Name(), err)
+	}
+	if err := mw.Close(); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		c.apiBase+"/"+c.config.PhoneNumberID+"/media", &body)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+
+	var result struct {
+		ID string `json:"id"`
+	}
+	if err := c.do(req, &result); err != nil {
+		return "", fmt.Errorf("failed to upload %s: %w", f.Name(), err)
+	}
+	return result.ID, nil
+}
+
+func (c *WhatsAppCloudChannel) sendMessage(ctx context.Context, payload map[string]any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		c.apiBase+"/"+c.config.PhoneNumberID+"/messages", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	return c.do(req, nil)
+}
+
+// do sends an authenticated Graph API request and decodes the response
+// into result, if not nil.
+func (c *WhatsAppCloudChannel) do(req *http.Request, result any) error {
+	req.Header.Set("Authorization", "Bearer "+c.config.AccessToken)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("API request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Error struct {
+				Message string `json:"message"`
+				Code    int    `json:"code"`
+			} `json:"error"`
+		}
+		if json.Unmarshal(respBody, &apiErr) == nil && apiErr.Error.Message != "" {
+			return fmt.Errorf("WhatsApp API error: %s (code %d)", apiErr.Error.Message, apiErr.Error.Code)
+		}
+		return fmt.Errorf("WhatsApp API error (status %d): %s", resp.StatusCode, string(respBody))
+	}
+
+	if result != nil {
+		if err := json.Unmarshal(respBody, result); err != nil {
+			return fmt.Errorf("failed to parse response: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package channels
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// fakeGraphAPI records the messages sent through it.
+type fakeGraphAPI struct {
+	*httptest.Server
+	mu       sync.Mutex
+	messages []map[string]any
+	uploads  []string // content types
+}
+
+func newFakeGraphAPI(t *testing.T) *fakeGraphAPI {
+	f := &fakeGraphAPI{}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			io.WriteString(w, `{"error":{"message":"bad token","code":190}}`)
+			return
+		}
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		switch r.URL.Path {
+		case "/123/messages":
+			var msg map[string]any
+			json.NewDecoder(r.Body).Decode(&msg)
+			f.messages = append(f.messages, msg)
+			io.WriteString(w, `{"messages":[{"id":"wamid.out"}]}`)
+		case "/123/media":
+			r.ParseMultipartForm(1 << 20)
+			f.uploads = append(f.uploads, r.FormValue("type"))
+			io.WriteString(w, `{"id":"media-1"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func newTestWhatsAppCloudChannel(t *testing.T, f *fakeGraphAPI, mb *bus.MessageBus) *WhatsAppCloudChannel {
+	t.Helper()
+	c, err := NewWhatsAppCloudChannel(config.WhatsAppCloudConfig{
+		PhoneNumberID: "123",
+		AccessToken:   "token",
+		AppSecret:     "secret",
+		VerifyToken:   "verify-me",
+		TemplateName:  "follow_up",
+	}, mb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.apiBase = f.URL
+	c.ctx = context.Background()
+	c.setRunning(true)
+	return c
+}
+
+func TestWhatsAppCloudWebhook(t *testing.T) {
+	f := newFakeGraphAPI(t)
+	mb := bus.NewMessageBus()
+	c := newTestWhatsAppCloudChannel(t, f, mb)
+
+	rec := httptest.NewRecorder()
+	c.webhookHandler(rec, httptest.NewRequest(http.MethodGet,
+		"/webhook/whatsapp?hub.mode=subscribe&hub.verify_token=verify-me&hub.challenge=42", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "42" {
+		t.Errorf("verification = %d %q", rec.Code, rec.Body.String())
+	}
+	rec = httptest.NewRecorder()
+	c.webhookHandler(rec, httptest.NewRequest(http.MethodGet,
+		"/webhook/whatsapp?hub.mode=subscribe&hub.verify_token=wrong&hub.challenge=42", nil))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("wrong verify token: status %d", rec.Code)
+	}
+
+	body := `{"entry":[{"changes":[{"value":{` +
+		`"contacts":[{"wa_id":"15551111111","profile":{"name":"Alice"}}],` +
+		`"messages":[{"from":"15551111111","id":"wamid.1","type":"text","text":{"body":"hello"}}]}}]}]}`
+	post := func(signature string) int {
+		req := httptest.NewRequest(http.MethodPost, "/webhook/whatsapp", strings.NewReader(body))
+		req.Header.Set("X-Hub-Signature-256", signature)
+		rec := httptest.NewRecorder()
+		c.webhookHandler(rec, req)
+		return rec.Code
+	}
+
+	if code := post("sha256=00"); code != http.StatusForbidden {
+		t.Errorf("bad signature: status %d", code)
+	}
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte(body))
+	signature := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	if code := post(signature); code != http.StatusOK {
+		t.Fatalf("signed post: status %d", code)
+	}
+	post(signature) // a redelivery is ignored
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	msg, ok := mb.ConsumeInbound(ctx)
+	if !ok {
+		t.Fatal("no inbound message")
+	}
+	if msg.SenderID != "15551111111" || msg.ChatID != "15551111111" || msg.Content != "hello" ||
+		msg.Metadata["user_name"] != "Alice" || msg.Metadata["peer_kind"] != "direct" {
+		t.Errorf("inbound = %+v", msg)
+	}
+	shortCtx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if dup, ok := mb.ConsumeInbound(shortCtx); ok {
+		t.Errorf("redelivered message published: %+v", dup)
+	}
+	if !c.windowOpen("15551111111") {
+		t.Error("expected the service window to be open")
+	}
+}
+
+func TestWhatsAppCloudSend(t *testing.T) {
+	f := newFakeGraphAPI(t)
+	c := newTestWhatsAppCloudChannel(t, f, bus.NewMessageBus())
+	c.windows.Store("15551111111", time.Now())
+
+	err := c.Send(context.Background(), bus.OutboundMessage{
+		ChatID:  "15551111111",
+		Content: "**Done**",
+		Media:   []bus.OutboundMedia{{Data: []byte("%PDF-1.4"), Filename: "report.pdf", Caption: "Weekly"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.messages) != 2 || len(f.uploads) != 1 {
+		t.Fatalf("messages = %v, uploads = %v", f.messages, f.uploads)
+	}
+	if text := f.messages[0]["text"].(map[string]any); text["body"] != "*Done*" {
+		t.Errorf("text = %v", text)
+	}
+	doc := f.messages[1]["document"].(map[string]any)
+	if f.messages[1]["type"] != "document" || doc["id"] != "media-1" || doc["filename"] != "report.pdf" ||
+		doc["caption"] != "Weekly" {
+		t.Errorf("document message = %v", f.messages[1])
+	}
+
+	// Outside the window the message goes out as the template.
+	f.messages = nil
+	err = c.Send(context.Background(), bus.OutboundMessage{ChatID: "15559999999", Content: "Reminder:\n\nstand-up"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.messages) != 1 || f.messages[0]["type"] != "template" {
+		t.Fatalf("messages = %v", f.messages)
+	}
+	tmpl := f.messages[0]["template"].(map[string]any)
+	params := tmpl["components"].([]any)[0].(map[string]any)["parameters"].([]any)
+	if tmpl["name"] != "follow_up" || params[0].(map[string]any)["text"] != "Reminder: stand-up" {
+		t.Errorf("template = %v", tmpl)
+	}
+
+	c.config.AccessToken = "expired"
+	err = c.Send(context.Background(), bus.OutboundMessage{ChatID: "15551111111", Content: "hi"})
+	if err == nil || !strings.Contains(err.Error(), "bad token (code 190)") {
+		t.Errorf("err = %v, want the API error", err)
+	}
+}
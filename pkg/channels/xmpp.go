@@ -0,0 +1,543 @@
+package channels
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+const (
+	xmppReconnectDelay = 10 * time.Second
+	xmppKeepalive      = 60 * time.Second
+
+	nsXMPPStream = "http://etherx.jabber.org/streams"
+	nsXMPPTLS    = "urn:ietf:params:xml:ns:xmpp-tls"
+	nsXMPPSASL   = "urn:ietf:params:xml:ns:xmpp-sasl"
+	nsXMPPBind   = "urn:ietf:params:xml:ns:xmpp-bind"
+)
+
+// xmppFormat is plain text; XMPP has no hard message size limit, but large
+// stanzas are commonly rejected by servers.
+var xmppFormat = MessageFormat{Dialect: DialectPlain, MaxLen: 4000}
+
+// XMPPChannel connects to an XMPP server as a client over STARTTLS with SASL
+// PLAIN. One-to-one chats use the sender's bare JID as chat ID, and
+// multi-user chat (MUC) rooms the room JID.
+type XMPPChannel struct {
+	*BaseChannel
+	config config.XMPPConfig
+	domain string
+	nick   string // MUC nickname
+	ctx    context.Context
+	cancel context.CancelFunc
+	mu     sync.Mutex // guards conn and writes
+	conn   net.Conn
+
+	occupantsMu sync.Mutex
+	occupants   map[string]string // room/nick -> real bare JID, where the room reveals it
+}
+
+type xmppFeatures struct {
+	StartTLS   *struct{} `xml:"urn:ietf:params:xml:ns:xmpp-tls starttls"`
+	Mechanisms *struct {
+		Mechanism []string `xml:"mechanism"`
+	} `xml:"urn:ietf:params:xml:ns:xmpp-sasl mechanisms"`
+}
+
+type xmppMessage struct {
+	From  string    `xml:"from,attr"`
+	Type  string    `xml:"type,attr"`
+	ID    string    `xml:"id,attr"`
+	Body  string    `xml:"body"`
+	Delay *struct{} `xml:"urn:xmpp:delay delay"`
+}
+
+type xmppPresence struct {
+	From string `xml:"from,attr"`
+	Type string `xml:"type,attr"`
+	MUC  *struct {
+		Item struct {
+			JID string `xml:"jid,attr"`
+		} `xml:"item"`
+	} `xml:"http://jabber.org/protocol/muc#user x"`
+}
+
+type xmppIQ struct {
+	From string    `xml:"from,attr"`
+	ID   string    `xml:"id,attr"`
+	Type string    `xml:"type,attr"`
+	Ping *struct{} `xml:"urn:xmpp:ping ping"`
+	Bind *struct {
+		JID string `xml:"jid"`
+	} `xml:"urn:ietf:params:xml:ns:xmpp-bind bind"`
+}
+
+// bareJID strips the resource from a JID.
+func bareJID(jid string) string {
+	bare, _, _ := strings.Cut(jid, "/")
+	return bare
+}
+
+func NewXMPPChannel(cfg config.XMPPConfig, messageBus *bus.MessageBus) (*XMPPChannel, error) {
+	local, domain, ok := strings.Cut(bareJID(cfg.JID), "@")
+	if !ok || local == "" || domain == "" {
+		return nil, fmt.Errorf("xmpp jid must look like user@domain")
+	}
+	if cfg.Password == "" {
+		return nil, fmt.Errorf("xmpp password is required")
+	}
+	if cfg.Server == "" {
+		cfg.Server = net.JoinHostPort(domain, "5222")
+	}
+	if cfg.Resource == "" {
+		cfg.Resource = "picoclaw"
+	}
+
+	// Room nicks can be taken by anyone, so the channel checks allow_from
+	// itself against real JIDs (see xmppAllowed).
+	base := NewBaseChannel("xmpp", cfg, messageBus, nil)
+	base.setGroupPolicy(cfg.Groups, mentionOnlyDefaults(cfg.MentionOnly))
+
+	nick := cfg.Nick
+	if nick == "" {
+		nick = local
+	}
+	return &XMPPChannel{
+		BaseChannel: base,
+		config:      cfg,
+		domain:      domain,
+		nick:        nick,
+		occupants:   make(map[string]string),
+	}, nil
+}
+
+func (c *XMPPChannel) Start(ctx context.Context) error {
+	logger.InfoCF("xmpp", "Starting XMPP channel", map[string]any{
+		"jid":    c.config.JID,
+		"server": c.config.Server,
+	})
+
+	c.ctx, c.cancel = context.WithCancel(ctx)
+
+	conn, dec, err := c.connect()
+	if err != nil {
+		c.cancel()
+		return fmt.Errorf("failed to connect to XMPP server: %w", err)
+	}
+	go c.run(conn, dec)
+	go c.keepalive()
+
+	c.setRunning(true)
+	logger.InfoC("xmpp", "XMPP channel started")
+	return nil
+}
+
+func (c *XMPPChannel) Stop(ctx context.Context) error {
+	logger.InfoC("xmpp", "Stopping XMPP channel")
+
+	if c.cancel != nil {
+		c.cancel()
+	}
+
+	c.mu.Lock()
+	if c.conn != nil {
+		fmt.Fprint(c.conn, "<presence type='unavailable'/></stream:stream>")
+		c.conn.Close()
+		c.conn = nil
+	}
+	c.mu.Unlock()
+
+	c.setRunning(false)
+	logger.InfoC("xmpp", "XMPP channel stopped")
+	return nil
+}
+
+// connect dials the server and negotiates TLS, authentication and a
+// resource binding, returning the stream ready for stanzas.
+func (c *XMPPChannel) connect() (net.Conn, *xml.Decoder, error) {
+	dialer := &net.Dialer{Timeout: 15 * time.Second}
+	conn, err := dialer.DialContext(c.ctx, "tcp", c.config.Server)
+	if err != nil {
+		return nil, nil, err
+	}
+	conn.SetDeadline(time.Now().Add(30 * time.Second))
+
+	dec, features, err := c.openStream(conn)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	if features.StartTLS != nil {
+		fmt.Fprintf(conn, "<starttls xmlns='%s'/>", nsXMPPTLS)
+		if se, err := xmppNextElement(dec); err != nil {
+			conn.Close()
+			return nil, nil, err
+		} else if se.Name.Local != "proceed" {
+			conn.Close()
+			return nil, nil, fmt.Errorf("STARTTLS refused")
+		}
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: c.domain})
+		if err := tlsConn.HandshakeContext(c.ctx); err != nil {
+			conn.Close()
+			return nil, nil, fmt.Errorf("TLS handshake: %w", err)
+		}
+		conn = tlsConn
+		if dec, features, err = c.openStream(conn); err != nil {
+			conn.Close()
+			return nil, nil, err
+		}
+	} else if !c.config.Insecure {
+		conn.Close()
+		return nil, nil, fmt.Errorf("server does not offer STARTTLS (set insecure to allow plaintext)")
+	}
+
+	if err := c.authenticate(conn, dec, features); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	if dec, _, err = c.openStream(conn); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	fmt.Fprintf(conn, "<iq type='set' id='bind'><bind xmlns='%s'><resource>%s</resource></bind></iq>",
+		nsXMPPBind, xmlEscape(c.config.Resource))
+	var iq xmppIQ
+	if err := xmppDecodeNext(dec, &iq); err != nil || iq.Type != "result" || iq.Bind == nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("resource binding failed: %v", err)
+	}
+
+	fmt.Fprint(conn, "<presence/>")
+	for _, room := range c.config.Rooms {
+		fmt.Fprintf(conn, "<presence to='%s/%s'><x xmlns='http://jabber.org/protocol/muc'>"+
+			"<history maxstanzas='0'/></x></presence>", xmlEscape(room), xmlEscape(c.nick))
+	}
+	conn.SetDeadline(time.Time{})
+
+	logger.InfoCF("xmpp", "Connected", map[string]any{"jid": iq.Bind.JID})
+	return conn, dec, nil
+}
+
+// openStream (re)starts the XML stream on conn and reads its features.
+func (c *XMPPChannel) openStream(conn net.Conn) (*xml.Decoder, xmppFeatures, error) {
+	var features xmppFeatures
+	_, err := fmt.Fprintf(conn, "<?xml version='1.0'?><stream:stream to='%s' version='1.0' "+
+		"xmlns='jabber:client' xmlns:stream='%s'>", xmlEscape(c.domain), nsXMPPStream)
+	if err != nil {
+		return nil, features, err
+	}
+
+	dec := xml.NewDecoder(conn)
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, features, err
+		}
+		if se, ok := tok.(xml.StartElement); ok {
+			if se.Name.Space != nsXMPPStream || se.Name.Local != "stream" {
+				return nil, features, fmt.Errorf("unexpected <%s> opening stream", se.Name.Local)
+			}
+			break
+		}
+	}
+	se, err := xmppNextElement(dec)
+	if err != nil {
+		return nil, features, err
+	}
+	if se.Name.Local != "features" {
+		return nil, features, fmt.Errorf("unexpected <%s> instead of stream features", se.Name.Local)
+	}
+	return dec, features, dec.DecodeElement(&features, &se)
+}
+
+func (c *XMPPChannel) authenticate(conn net.Conn, dec *xml.Decoder, features xmppFeatures) error {
+	if features.Mechanisms == nil || !slices.Contains(features.Mechanisms.Mechanism, "PLAIN") {
+		return fmt.Errorf("server does not offer SASL PLAIN")
+	}
+	local, _, _ := strings.Cut(c.config.JID, "@")
+	creds := "\x00" + local + "\x00" + c.config.Password
+	fmt.Fprintf(conn, "<auth xmlns='%s' mechanism='PLAIN'>%s</auth>",
+		nsXMPPSASL, base64.StdEncoding.EncodeToString([]byte(creds)))
+
+	se, err := xmppNextElement(dec)
+	if err != nil {
+		return err
+	}
+	if err := dec.Skip(); err != nil {
+		return err
+	}
+	if se.Name.Local != "success" {
+		return fmt.Errorf("authentication failed")
+	}
+	return nil
+}
+
+// xmppNextElement returns the next element start at the current depth,
+// skipping whitespace. The end of the stream is reported as an error.
+func xmppNextElement(dec *xml.Decoder) (xml.StartElement, error) {
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return xml.StartElement{}, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			return t, nil
+		case xml.EndElement:
+			return xml.StartElement{}, fmt.Errorf("stream closed by server")
+		}
+	}
+}
+
+func xmppDecodeNext(dec *xml.Decoder, v any) error {
+	se, err := xmppNextElement(dec)
+	if err != nil {
+		return err
+	}
+	return dec.DecodeElement(v, &se)
+}
+
+func xmlEscape(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+// run serves the stream and then keeps reconnecting until the channel stops.
+func (c *XMPPChannel) run(conn net.Conn, dec *xml.Decoder) {
+	for {
+		err := c.serve(conn, dec)
+		if c.ctx.Err() != nil {
+			return
+		}
+		logger.WarnCF("xmpp", "Disconnected, reconnecting", map[string]any{"error": err.Error()})
+
+		for {
+			select {
+			case <-c.ctx.Done():
+				return
+			case <-time.After(xmppReconnectDelay):
+			}
+			if conn, dec, err = c.connect(); err == nil {
+				break
+			}
+			logger.ErrorCF("xmpp", "Reconnect failed", map[string]any{"error": err.Error()})
+		}
+	}
+}
+
+// serve handles stanzas on conn until the stream ends.
+func (c *XMPPChannel) serve(conn net.Conn, dec *xml.Decoder) error {
+	c.mu.Lock()
+	c.conn = conn
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		if c.conn == conn {
+			c.conn = nil
+		}
+		c.mu.Unlock()
+		conn.Close()
+	}()
+	// Rooms are joined again on this stream and send their occupants anew.
+	c.occupantsMu.Lock()
+	clear(c.occupants)
+	c.occupantsMu.Unlock()
+
+	for {
+		se, err := xmppNextElement(dec)
+		if err != nil {
+			return err
+		}
+		switch se.Name.Local {
+		case "message":
+			var msg xmppMessage
+			if err := dec.DecodeElement(&msg, &se); err != nil {
+				return err
+			}
+			c.handleMessage(msg)
+		case "presence":
+			var p xmppPresence
+			if err := dec.DecodeElement(&p, &se); err != nil {
+				return err
+			}
+			c.handlePresence(p)
+		case "iq":
+			var iq xmppIQ
+			if err := dec.DecodeElement(&iq, &se); err != nil {
+				return err
+			}
+			c.handleIQ(iq)
+		default:
+			if err := dec.Skip(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// handleIQ answers pings and refuses other requests, as clients must.
+func (c *XMPPChannel) handleIQ(iq xmppIQ) {
+	switch {
+	case iq.Type == "get" && iq.Ping != nil:
+		c.write(fmt.Sprintf("<iq type='result' id='%s' to='%s'/>", xmlEscape(iq.ID), xmlEscape(iq.From)))
+	case iq.Type == "get" || iq.Type == "set":
+		c.write(fmt.Sprintf("<iq type='error' id='%s' to='%s'><error type='cancel'>"+
+			"<service-unavailable xmlns='urn:ietf:params:xml:ns:xmpp-stanzas'/></error></iq>",
+			xmlEscape(iq.ID), xmlEscape(iq.From)))
+	}
+}
+
+// handlePresence tracks the real JIDs of room occupants. Rooms only send
+// them when they are non-anonymous, or to moderators.
+func (c *XMPPChannel) handlePresence(p xmppPresence) {
+	if p.MUC == nil || !strings.Contains(p.From, "/") {
+		return
+	}
+	c.occupantsMu.Lock()
+	defer c.occupantsMu.Unlock()
+	if p.Type == "unavailable" || p.MUC.Item.JID == "" {
+		delete(c.occupants, p.From)
+		return
+	}
+	c.occupants[p.From] = bareJID(p.MUC.Item.JID)
+}
+
+// occupantJID returns the real bare JID behind a room occupant, or "" if
+// the room does not reveal it.
+func (c *XMPPChannel) occupantJID(occupant string) string {
+	c.occupantsMu.Lock()
+	defer c.occupantsMu.Unlock()
+	return c.occupants[occupant]
+}
+
+// xmppAllowed reports whether the sender with the bare JID jid may talk to
+// the bot. An unknown JID, as in anonymous rooms, is only allowed when
+// allow_from is empty, since room nicks prove nothing.
+func xmppAllowed(allowFrom []string, jid string) bool {
+	if len(allowFrom) == 0 {
+		return true
+	}
+	for _, entry := range allowFrom {
+		if jid != "" && strings.EqualFold(bareJID(entry), jid) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *XMPPChannel) handleMessage(msg xmppMessage) {
+	text := strings.TrimSpace(msg.Body)
+	if text == "" || msg.Type == "error" {
+		return
+	}
+
+	metadata := map[string]string{
+		"message_id": msg.ID,
+		"platform":   "xmpp",
+	}
+	var senderID, chatID string
+	if msg.Type == "groupchat" {
+		room, nick, _ := strings.Cut(msg.From, "/")
+		// Skip room notices, our own echoes, and history replayed on join.
+		if nick == "" || nick == c.nick || msg.Delay != nil {
+			return
+		}
+		jid := c.occupantJID(msg.From)
+		if !xmppAllowed(c.config.AllowFrom, jid) {
+			logger.DebugCF("xmpp", "Message rejected by allowlist", map[string]any{"sender": msg.From, "jid": jid})
+			return
+		}
+		senderID = msg.From
+		if jid != "" {
+			senderID = jid + "|" + nick
+		}
+		chatID = room
+		metadata["user_name"] = nick
+		metadata["peer_kind"] = "group"
+		metadata["peer_id"] = room
+		content, mentioned := stripNickMention(text, c.nick)
+		var ok bool
+		if text, _, ok = c.admitGroupMessage(senderID, chatID, content, mentioned, metadata); !ok {
+			return
+		}
+	} else {
+		senderID = bareJID(msg.From)
+		if !xmppAllowed(c.config.AllowFrom, senderID) {
+			logger.DebugCF("xmpp", "Message rejected by allowlist", map[string]any{"sender": senderID})
+			return
+		}
+		chatID = senderID
+		metadata["user_name"] = senderID
+		metadata["peer_kind"] = "direct"
+		metadata["peer_id"] = senderID
+	}
+
+	logger.DebugCF("xmpp", "Received message", map[string]any{
+		"sender":  senderID,
+		"chat_id": chatID,
+		"preview": utils.Truncate(text, 50),
+	})
+
+	c.HandleMessage(senderID, chatID, text, nil, metadata)
+}
+
+func (c *XMPPChannel) Send(ctx context.Context, msg bus.OutboundMessage) error {
+	if !c.IsRunning() {
+		return fmt.Errorf("xmpp channel not running")
+	}
+
+	msgType := "chat"
+	if slices.Contains(c.config.Rooms, msg.ChatID) {
+		msgType = "groupchat"
+	}
+	for _, chunk := range xmppFormat.Render(msg.Content) {
+		stanza := fmt.Sprintf("<message to='%s' type='%s'><body>%s</body></message>",
+			xmlEscape(msg.ChatID), msgType, xmlEscape(chunk))
+		if err := c.write(stanza); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// keepalive sends whitespace pings so idle connections are not dropped by
+// servers or NAT.
+func (c *XMPPChannel) keepalive() {
+	ticker := time.NewTicker(xmppKeepalive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+			c.write(" ")
+		}
+	}
+}
+
+func (c *XMPPChannel) write(data string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return fmt.Errorf("xmpp not connected")
+	}
+	c.conn.SetWriteDeadline(time.Now().Add(30 * time.Second))
+	_, err := fmt.Fprint(c.conn, data)
+	return err
+}
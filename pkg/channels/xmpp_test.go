@@ -0,0 +1,148 @@
+package channels
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// xmppStanza is what the fake server decodes from the client.
+type xmppStanza struct {
+	XMLName xml.Name
+	To      string `xml:"to,attr"`
+	Type    string `xml:"type,attr"`
+	Body    string `xml:"body"`
+	Inner   string `xml:",innerxml"`
+}
+
+// acceptXMPPStream reads the client's stream header and answers with one
+// carrying features.
+func acceptXMPPStream(t *testing.T, conn net.Conn, features string) *xml.Decoder {
+	t.Helper()
+	dec := xml.NewDecoder(conn)
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if se, ok := tok.(xml.StartElement); ok && se.Name.Local == "stream" {
+			break
+		}
+	}
+	fmt.Fprintf(conn, "<?xml version='1.0'?><stream:stream xmlns='jabber:client' "+
+		"xmlns:stream='http://etherx.jabber.org/streams' id='s1' version='1.0'>"+
+		"<stream:features>%s</stream:features>", features)
+	return dec
+}
+
+func readXMPPStanza(t *testing.T, dec *xml.Decoder) xmppStanza {
+	t.Helper()
+	var s xmppStanza
+	if err := xmppDecodeNext(dec, &s); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestXMPPChannel(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	server := make(chan *xml.Decoder, 1)
+	conns := make(chan net.Conn, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		conns <- conn
+		dec := acceptXMPPStream(t, conn,
+			"<mechanisms xmlns='urn:ietf:params:xml:ns:xmpp-sasl'><mechanism>PLAIN</mechanism></mechanisms>")
+		auth := readXMPPStanza(t, dec)
+		if want := base64.StdEncoding.EncodeToString([]byte("\x00bot\x00pw")); auth.Inner != want {
+			t.Errorf("auth = %q, want %q", auth.Inner, want)
+		}
+		io.WriteString(conn, "<success xmlns='urn:ietf:params:xml:ns:xmpp-sasl'/>")
+
+		dec = acceptXMPPStream(t, conn, "<bind xmlns='urn:ietf:params:xml:ns:xmpp-bind'/>")
+		readXMPPStanza(t, dec)
+		io.WriteString(conn, "<iq type='result' id='bind'>"+
+			"<bind xmlns='urn:ietf:params:xml:ns:xmpp-bind'><jid>bot@example.org/picoclaw</jid></bind></iq>")
+		server <- dec
+	}()
+
+	mb := bus.NewMessageBus()
+	c, err := NewXMPPChannel(config.XMPPConfig{
+		JID: "bot@example.org", Password: "pw", Server: ln.Addr().String(), Insecure: true,
+		Rooms: []string{"dev@conference.example.org"}, MentionOnly: true,
+		AllowFrom: []string{"alice@example.org", "carol@example.org"},
+	}, mb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop(context.Background())
+
+	conn := <-conns
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	dec := <-server
+
+	if p := readXMPPStanza(t, dec); p.XMLName.Local != "presence" || p.To != "" {
+		t.Errorf("initial presence = %+v", p)
+	}
+	if p := readXMPPStanza(t, dec); p.To != "dev@conference.example.org/bot" {
+		t.Errorf("room join = %+v", p)
+	}
+
+	// The room reveals alice's real JID; mallory's is unknown, and taking
+	// an allowed user's name as nick does not help.
+	io.WriteString(conn, "<presence from='dev@conference.example.org/alice'>"+
+		"<x xmlns='http://jabber.org/protocol/muc#user'><item jid='alice@example.org/laptop'/></x></presence>"+
+		"<presence from='dev@conference.example.org/carol'><x xmlns='http://jabber.org/protocol/muc#user'/></presence>"+
+		"<message from='dev@conference.example.org/carol' type='groupchat'><body>bot: I am carol</body></message>"+
+		"<message from='dev@conference.example.org/alice' type='groupchat'>"+
+		"<body>bot: old question</body><delay xmlns='urn:xmpp:delay' stamp='2024-01-01T00:00:00Z'/></message>"+
+		"<message from='dev@conference.example.org/alice' type='groupchat'><body>chatter</body></message>"+
+		"<message from='dev@conference.example.org/alice' type='groupchat'><body>bot: what's up?</body></message>"+
+		"<message from='carol@example.org/phone' type='chat'><body>hello</body></message>")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	msg, ok := mb.ConsumeInbound(ctx)
+	if !ok {
+		t.Fatal("no inbound message")
+	}
+	if msg.SenderID != "alice@example.org|alice" || msg.ChatID != "dev@conference.example.org" ||
+		msg.Content != "what's up?" || msg.Metadata["user_name"] != "alice" || msg.Metadata["peer_kind"] != "group" {
+		t.Errorf("inbound = %+v", msg)
+	}
+	msg, ok = mb.ConsumeInbound(ctx)
+	if !ok {
+		t.Fatal("no inbound direct message")
+	}
+	if msg.SenderID != "carol@example.org" || msg.ChatID != "carol@example.org" || msg.Content != "hello" {
+		t.Errorf("inbound = %+v", msg)
+	}
+
+	out := bus.OutboundMessage{ChatID: "dev@conference.example.org", Content: "Fine <thanks>"}
+	if err := c.Send(context.Background(), out); err != nil {
+		t.Fatal(err)
+	}
+	if m := readXMPPStanza(t, dec); m.Type != "groupchat" || m.To != out.ChatID || m.Body != "Fine <thanks>" {
+		t.Errorf("sent = %+v", m)
+	}
+}
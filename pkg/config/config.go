@@ -177,27 +177,132 @@ type AgentDefaults struct {
 	MaxTokens           int      `json:"max_tokens"                      env:"PICOCLAW_AGENTS_DEFAULTS_MAX_TOKENS"`
 	Temperature         *float64 `json:"temperature,omitempty"           env:"PICOCLAW_AGENTS_DEFAULTS_TEMPERATURE"`
 	MaxToolIterations   int      `json:"max_tool_iterations"             env:"PICOCLAW_AGENTS_DEFAULTS_MAX_TOOL_ITERATIONS"`
+	MaxSubagentDepth    int      `json:"max_subagent_depth,omitempty"    env:"PICOCLAW_AGENTS_DEFAULTS_MAX_SUBAGENT_DEPTH"`
+	ShowReasoning       bool     `json:"show_reasoning,omitempty"        env:"PICOCLAW_AGENTS_DEFAULTS_SHOW_REASONING"`
+	FallbackStrategy    string   `json:"fallback_strategy,omitempty"     env:"PICOCLAW_AGENTS_DEFAULTS_FALLBACK_STRATEGY"`
+	HealthProbeInterval int      `json:"health_probe_interval,omitempty" env:"PICOCLAW_AGENTS_DEFAULTS_HEALTH_PROBE_INTERVAL"`
+	ResumeSubagents     bool     `json:"resume_subagents,omitempty"      env:"PICOCLAW_AGENTS_DEFAULTS_RESUME_SUBAGENTS"`
+
+	ResponseCache ResponseCacheConfig `json:"response_cache"`
+}
+
+// ResponseCacheConfig controls the on-disk cache of LLM responses to
+// identical requests. Requests with a temperature above 0 are only cached
+// with CacheSampled.
+type ResponseCacheConfig struct {
+	Enabled      bool `json:"enabled"       env:"PICOCLAW_AGENTS_DEFAULTS_RESPONSE_CACHE_ENABLED"`
+	TTLSeconds   int  `json:"ttl_seconds"   env:"PICOCLAW_AGENTS_DEFAULTS_RESPONSE_CACHE_TTL_SECONDS"`
+	MaxEntries   int  `json:"max_entries"   env:"PICOCLAW_AGENTS_DEFAULTS_RESPONSE_CACHE_MAX_ENTRIES"`
+	CacheSampled bool `json:"cache_sampled" env:"PICOCLAW_AGENTS_DEFAULTS_RESPONSE_CACHE_CACHE_SAMPLED"`
 }
 
 type ChannelsConfig struct {
-	WhatsApp WhatsAppConfig `json:"whatsapp"`
-	Telegram TelegramConfig `json:"telegram"`
-	Feishu   FeishuConfig   `json:"feishu"`
-	Discord  DiscordConfig  `json:"discord"`
-	MaixCam  MaixCamConfig  `json:"maixcam"`
-	QQ       QQConfig       `json:"qq"`
-	DingTalk DingTalkConfig `json:"dingtalk"`
-	Slack    SlackConfig    `json:"slack"`
-	LINE     LINEConfig     `json:"line"`
-	OneBot   OneBotConfig   `json:"onebot"`
-	WeCom    WeComConfig    `json:"wecom"`
-	WeComApp WeComAppConfig `json:"wecom_app"`
+	WhatsApp      WhatsAppConfig      `json:"whatsapp"`
+	WhatsAppCloud WhatsAppCloudConfig `json:"whatsapp_cloud"`
+	Telegram      TelegramConfig      `json:"telegram"`
+	Feishu        FeishuConfig        `json:"feishu"`
+	Discord       DiscordConfig       `json:"discord"`
+	MaixCam       MaixCamConfig       `json:"maixcam"`
+	QQ            QQConfig            `json:"qq"`
+	DingTalk      DingTalkConfig      `json:"dingtalk"`
+	Slack         SlackConfig         `json:"slack"`
+	LINE          LINEConfig          `json:"line"`
+	OneBot        OneBotConfig        `json:"onebot"`
+	WeCom         WeComConfig         `json:"wecom"`
+	WeComApp      WeComAppConfig      `json:"wecom_app"`
+	Signal        SignalConfig        `json:"signal"`
+	Mattermost    MattermostConfig    `json:"mattermost"`
+	RocketChat    RocketChatConfig    `json:"rocketchat"`
+	IRC           IRCConfig           `json:"irc"`
+	XMPP          XMPPConfig          `json:"xmpp"`
+	Local         LocalConfig         `json:"local"`
+}
+
+// Group trigger modes for GroupPolicyConfig.Trigger.
+const (
+	GroupTriggerAlways  = "always"  // every message
+	GroupTriggerMention = "mention" // mentions of the bot, and any prefixes or keywords
+	GroupTriggerPrefix  = "prefix"  // messages starting with one of the prefixes
+	GroupTriggerKeyword = "keyword" // messages containing one of the keywords
+)
+
+// GroupPolicy controls how a channel behaves in a group chat.
+type GroupPolicy struct {
+	Trigger         string   `json:"trigger,omitempty"`
+	Prefixes        []string `json:"prefixes,omitempty"`
+	Keywords        []string `json:"keywords,omitempty"`
+	ReplyInThread   *bool    `json:"reply_in_thread,omitempty"`
+	CooldownSeconds int      `json:"cooldown_seconds,omitempty"` // between replies in a group
+	MaxPerMinute    int      `json:"max_per_minute,omitempty"`   // replies per group per minute
+	Passive         *bool    `json:"passive,omitempty"`          // keep untriggered messages as context
+}
+
+// GroupPolicyConfig is a channel's group policy. Chats overrides it per
+// group or channel ID; fields set there replace the channel's, and unset
+// ones are inherited.
+type GroupPolicyConfig struct {
+	GroupPolicy
+	Chats map[string]GroupPolicy `json:"chats,omitempty"`
+}
+
+// ForChat returns the policy for the first of ids that has an override,
+// merged over the channel's policy.
+func (p GroupPolicyConfig) ForChat(ids ...string) GroupPolicy {
+	merged := p.GroupPolicy
+	for _, id := range ids {
+		o, ok := p.Chats[id]
+		if !ok {
+			continue
+		}
+		if o.Trigger != "" {
+			merged.Trigger = o.Trigger
+		}
+		if o.Prefixes != nil {
+			merged.Prefixes = o.Prefixes
+		}
+		if o.Keywords != nil {
+			merged.Keywords = o.Keywords
+		}
+		if o.ReplyInThread != nil {
+			merged.ReplyInThread = o.ReplyInThread
+		}
+		if o.CooldownSeconds != 0 {
+			merged.CooldownSeconds = o.CooldownSeconds
+		}
+		if o.MaxPerMinute != 0 {
+			merged.MaxPerMinute = o.MaxPerMinute
+		}
+		if o.Passive != nil {
+			merged.Passive = o.Passive
+		}
+		break
+	}
+	return merged
 }
 
 type WhatsAppConfig struct {
 	Enabled   bool                `json:"enabled"    env:"PICOCLAW_CHANNELS_WHATSAPP_ENABLED"`
 	BridgeURL string              `json:"bridge_url" env:"PICOCLAW_CHANNELS_WHATSAPP_BRIDGE_URL"`
 	AllowFrom FlexibleStringSlice `json:"allow_from" env:"PICOCLAW_CHANNELS_WHATSAPP_ALLOW_FROM"`
+	Groups    GroupPolicyConfig   `json:"groups"`
+}
+
+// WhatsAppCloudConfig configures Meta's WhatsApp Business Cloud API.
+// TemplateName is an approved template with one body parameter, used for
+// chats outside the 24-hour customer service window.
+type WhatsAppCloudConfig struct {
+	Enabled          bool                `json:"enabled"           env:"PICOCLAW_CHANNELS_WHATSAPP_CLOUD_ENABLED"`
+	PhoneNumberID    string              `json:"phone_number_id"   env:"PICOCLAW_CHANNELS_WHATSAPP_CLOUD_PHONE_NUMBER_ID"`
+	AccessToken      string              `json:"access_token"      env:"PICOCLAW_CHANNELS_WHATSAPP_CLOUD_ACCESS_TOKEN"`
+	AppSecret        string              `json:"app_secret"        env:"PICOCLAW_CHANNELS_WHATSAPP_CLOUD_APP_SECRET"`
+	VerifyToken      string              `json:"verify_token"      env:"PICOCLAW_CHANNELS_WHATSAPP_CLOUD_VERIFY_TOKEN"`
+	APIVersion       string              `json:"api_version"       env:"PICOCLAW_CHANNELS_WHATSAPP_CLOUD_API_VERSION"`
+	WebhookHost      string              `json:"webhook_host"      env:"PICOCLAW_CHANNELS_WHATSAPP_CLOUD_WEBHOOK_HOST"`
+	WebhookPort      int                 `json:"webhook_port"      env:"PICOCLAW_CHANNELS_WHATSAPP_CLOUD_WEBHOOK_PORT"`
+	WebhookPath      string              `json:"webhook_path"      env:"PICOCLAW_CHANNELS_WHATSAPP_CLOUD_WEBHOOK_PATH"`
+	TemplateName     string              `json:"template_name"     env:"PICOCLAW_CHANNELS_WHATSAPP_CLOUD_TEMPLATE_NAME"`
+	TemplateLanguage string              `json:"template_language" env:"PICOCLAW_CHANNELS_WHATSAPP_CLOUD_TEMPLATE_LANGUAGE"`
+	AllowFrom        FlexibleStringSlice `json:"allow_from"        env:"PICOCLAW_CHANNELS_WHATSAPP_CLOUD_ALLOW_FROM"`
 }
 
 type TelegramConfig struct {
@@ -205,6 +310,8 @@ type TelegramConfig struct {
 	Token     string              `json:"token"      env:"PICOCLAW_CHANNELS_TELEGRAM_TOKEN"`
 	Proxy     string              `json:"proxy"      env:"PICOCLAW_CHANNELS_TELEGRAM_PROXY"`
 	AllowFrom FlexibleStringSlice `json:"allow_from" env:"PICOCLAW_CHANNELS_TELEGRAM_ALLOW_FROM"`
+	Groups    GroupPolicyConfig   `json:"groups"`
+	ParseMode string              `json:"parse_mode" env:"PICOCLAW_CHANNELS_TELEGRAM_PARSE_MODE"` // "html" (default) or "markdownv2"
 }
 
 type FeishuConfig struct {
@@ -214,12 +321,14 @@ type FeishuConfig struct {
 	EncryptKey        string              `json:"encrypt_key"        env:"PICOCLAW_CHANNELS_FEISHU_ENCRYPT_KEY"`
 	VerificationToken string              `json:"verification_token" env:"PICOCLAW_CHANNELS_FEISHU_VERIFICATION_TOKEN"`
 	AllowFrom         FlexibleStringSlice `json:"allow_from"         env:"PICOCLAW_CHANNELS_FEISHU_ALLOW_FROM"`
+	Groups            GroupPolicyConfig   `json:"groups"`
 }
 
 type DiscordConfig struct {
 	Enabled     bool                `json:"enabled"      env:"PICOCLAW_CHANNELS_DISCORD_ENABLED"`
 	Token       string              `json:"token"        env:"PICOCLAW_CHANNELS_DISCORD_TOKEN"`
 	AllowFrom   FlexibleStringSlice `json:"allow_from"   env:"PICOCLAW_CHANNELS_DISCORD_ALLOW_FROM"`
+	Groups      GroupPolicyConfig   `json:"groups"`
 	MentionOnly bool                `json:"mention_only" env:"PICOCLAW_CHANNELS_DISCORD_MENTION_ONLY"`
 }
 
@@ -235,6 +344,7 @@ type QQConfig struct {
 	AppID     string              `json:"app_id"     env:"PICOCLAW_CHANNELS_QQ_APP_ID"`
 	AppSecret string              `json:"app_secret" env:"PICOCLAW_CHANNELS_QQ_APP_SECRET"`
 	AllowFrom FlexibleStringSlice `json:"allow_from" env:"PICOCLAW_CHANNELS_QQ_ALLOW_FROM"`
+	Groups    GroupPolicyConfig   `json:"groups"`
 }
 
 type DingTalkConfig struct {
@@ -242,6 +352,7 @@ type DingTalkConfig struct {
 	ClientID     string              `json:"client_id"     env:"PICOCLAW_CHANNELS_DINGTALK_CLIENT_ID"`
 	ClientSecret string              `json:"client_secret" env:"PICOCLAW_CHANNELS_DINGTALK_CLIENT_SECRET"`
 	AllowFrom    FlexibleStringSlice `json:"allow_from"    env:"PICOCLAW_CHANNELS_DINGTALK_ALLOW_FROM"`
+	Groups       GroupPolicyConfig   `json:"groups"`
 }
 
 type SlackConfig struct {
@@ -249,6 +360,7 @@ type SlackConfig struct {
 	BotToken  string              `json:"bot_token"  env:"PICOCLAW_CHANNELS_SLACK_BOT_TOKEN"`
 	AppToken  string              `json:"app_token"  env:"PICOCLAW_CHANNELS_SLACK_APP_TOKEN"`
 	AllowFrom FlexibleStringSlice `json:"allow_from" env:"PICOCLAW_CHANNELS_SLACK_ALLOW_FROM"`
+	Groups    GroupPolicyConfig   `json:"groups"`
 }
 
 type LINEConfig struct {
@@ -258,7 +370,9 @@ type LINEConfig struct {
 	WebhookHost        string              `json:"webhook_host"         env:"PICOCLAW_CHANNELS_LINE_WEBHOOK_HOST"`
 	WebhookPort        int                 `json:"webhook_port"         env:"PICOCLAW_CHANNELS_LINE_WEBHOOK_PORT"`
 	WebhookPath        string              `json:"webhook_path"         env:"PICOCLAW_CHANNELS_LINE_WEBHOOK_PATH"`
+	PublicURL          string              `json:"public_url"           env:"PICOCLAW_CHANNELS_LINE_PUBLIC_URL"` // HTTPS base URL of the webhook server, for sending files
 	AllowFrom          FlexibleStringSlice `json:"allow_from"           env:"PICOCLAW_CHANNELS_LINE_ALLOW_FROM"`
+	Groups             GroupPolicyConfig   `json:"groups"`
 }
 
 type OneBotConfig struct {
@@ -268,6 +382,7 @@ type OneBotConfig struct {
 	ReconnectInterval  int                 `json:"reconnect_interval"   env:"PICOCLAW_CHANNELS_ONEBOT_RECONNECT_INTERVAL"`
 	GroupTriggerPrefix []string            `json:"group_trigger_prefix" env:"PICOCLAW_CHANNELS_ONEBOT_GROUP_TRIGGER_PREFIX"`
 	AllowFrom          FlexibleStringSlice `json:"allow_from"           env:"PICOCLAW_CHANNELS_ONEBOT_ALLOW_FROM"`
+	Groups             GroupPolicyConfig   `json:"groups"`
 }
 
 type WeComConfig struct {
@@ -279,6 +394,7 @@ type WeComConfig struct {
 	WebhookPort    int                 `json:"webhook_port"     env:"PICOCLAW_CHANNELS_WECOM_WEBHOOK_PORT"`
 	WebhookPath    string              `json:"webhook_path"     env:"PICOCLAW_CHANNELS_WECOM_WEBHOOK_PATH"`
 	AllowFrom      FlexibleStringSlice `json:"allow_from"       env:"PICOCLAW_CHANNELS_WECOM_ALLOW_FROM"`
+	Groups         GroupPolicyConfig   `json:"groups"`
 	ReplyTimeout   int                 `json:"reply_timeout"    env:"PICOCLAW_CHANNELS_WECOM_REPLY_TIMEOUT"`
 }
 
@@ -296,6 +412,72 @@ type WeComAppConfig struct {
 	ReplyTimeout   int                 `json:"reply_timeout"    env:"PICOCLAW_CHANNELS_WECOM_APP_REPLY_TIMEOUT"`
 }
 
+type SignalConfig struct {
+	Enabled   bool                `json:"enabled"    env:"PICOCLAW_CHANNELS_SIGNAL_ENABLED"`
+	Account   string              `json:"account"    env:"PICOCLAW_CHANNELS_SIGNAL_ACCOUNT"` // phone number registered with signal-cli
+	URL       string              `json:"url"        env:"PICOCLAW_CHANNELS_SIGNAL_URL"`     // signal-cli daemon started with --http
+	AllowFrom FlexibleStringSlice `json:"allow_from" env:"PICOCLAW_CHANNELS_SIGNAL_ALLOW_FROM"`
+	Groups    GroupPolicyConfig   `json:"groups"`
+}
+
+type MattermostConfig struct {
+	Enabled     bool                `json:"enabled"      env:"PICOCLAW_CHANNELS_MATTERMOST_ENABLED"`
+	URL         string              `json:"url"          env:"PICOCLAW_CHANNELS_MATTERMOST_URL"`
+	Token       string              `json:"token"        env:"PICOCLAW_CHANNELS_MATTERMOST_TOKEN"`
+	MentionOnly bool                `json:"mention_only" env:"PICOCLAW_CHANNELS_MATTERMOST_MENTION_ONLY"`
+	AllowFrom   FlexibleStringSlice `json:"allow_from"   env:"PICOCLAW_CHANNELS_MATTERMOST_ALLOW_FROM"`
+	Groups      GroupPolicyConfig   `json:"groups"`
+}
+
+type RocketChatConfig struct {
+	Enabled     bool                `json:"enabled"      env:"PICOCLAW_CHANNELS_ROCKETCHAT_ENABLED"`
+	URL         string              `json:"url"          env:"PICOCLAW_CHANNELS_ROCKETCHAT_URL"`
+	UserID      string              `json:"user_id"      env:"PICOCLAW_CHANNELS_ROCKETCHAT_USER_ID"`
+	Token       string              `json:"token"        env:"PICOCLAW_CHANNELS_ROCKETCHAT_TOKEN"`
+	MentionOnly bool                `json:"mention_only" env:"PICOCLAW_CHANNELS_ROCKETCHAT_MENTION_ONLY"`
+	AllowFrom   FlexibleStringSlice `json:"allow_from"   env:"PICOCLAW_CHANNELS_ROCKETCHAT_ALLOW_FROM"`
+	Groups      GroupPolicyConfig   `json:"groups"`
+}
+
+type IRCConfig struct {
+	Enabled          bool                `json:"enabled"           env:"PICOCLAW_CHANNELS_IRC_ENABLED"`
+	Server           string              `json:"server"            env:"PICOCLAW_CHANNELS_IRC_SERVER"` // host:port
+	TLS              bool                `json:"tls"               env:"PICOCLAW_CHANNELS_IRC_TLS"`
+	Nick             string              `json:"nick"              env:"PICOCLAW_CHANNELS_IRC_NICK"`
+	Username         string              `json:"username"          env:"PICOCLAW_CHANNELS_IRC_USERNAME"`
+	RealName         string              `json:"real_name"         env:"PICOCLAW_CHANNELS_IRC_REAL_NAME"`
+	Password         string              `json:"password"          env:"PICOCLAW_CHANNELS_IRC_PASSWORD"`
+	SASLUser         string              `json:"sasl_user"         env:"PICOCLAW_CHANNELS_IRC_SASL_USER"`
+	SASLPassword     string              `json:"sasl_password"     env:"PICOCLAW_CHANNELS_IRC_SASL_PASSWORD"`
+	NickServPassword string              `json:"nickserv_password" env:"PICOCLAW_CHANNELS_IRC_NICKSERV_PASSWORD"`
+	Channels         FlexibleStringSlice `json:"channels"          env:"PICOCLAW_CHANNELS_IRC_CHANNELS"`
+	MentionOnly      bool                `json:"mention_only"      env:"PICOCLAW_CHANNELS_IRC_MENTION_ONLY"`
+	AllowFrom        FlexibleStringSlice `json:"allow_from"        env:"PICOCLAW_CHANNELS_IRC_ALLOW_FROM"`
+	Groups           GroupPolicyConfig   `json:"groups"`
+}
+
+type XMPPConfig struct {
+	Enabled     bool                `json:"enabled"      env:"PICOCLAW_CHANNELS_XMPP_ENABLED"`
+	JID         string              `json:"jid"          env:"PICOCLAW_CHANNELS_XMPP_JID"`
+	Password    string              `json:"password"     env:"PICOCLAW_CHANNELS_XMPP_PASSWORD"`
+	Server      string              `json:"server"       env:"PICOCLAW_CHANNELS_XMPP_SERVER"` // host:port, defaults to the JID's domain
+	Resource    string              `json:"resource"     env:"PICOCLAW_CHANNELS_XMPP_RESOURCE"`
+	Rooms       FlexibleStringSlice `json:"rooms"        env:"PICOCLAW_CHANNELS_XMPP_ROOMS"`
+	Nick        string              `json:"nick"         env:"PICOCLAW_CHANNELS_XMPP_NICK"`
+	MentionOnly bool                `json:"mention_only" env:"PICOCLAW_CHANNELS_XMPP_MENTION_ONLY"`
+	Insecure    bool                `json:"insecure"     env:"PICOCLAW_CHANNELS_XMPP_INSECURE"` // allow login without TLS
+	AllowFrom   FlexibleStringSlice `json:"allow_from"   env:"PICOCLAW_CHANNELS_XMPP_ALLOW_FROM"`
+	Groups      GroupPolicyConfig   `json:"groups"`
+}
+
+// LocalConfig enables the WebSocket chat endpoint on the gateway used by
+// `picoclaw chat`. Without a token, only loopback clients may connect.
+type LocalConfig struct {
+	Enabled   bool                `json:"enabled"    env:"PICOCLAW_CHANNELS_LOCAL_ENABLED"`
+	Token     string              `json:"token"      env:"PICOCLAW_CHANNELS_LOCAL_TOKEN"`
+	AllowFrom FlexibleStringSlice `json:"allow_from" env:"PICOCLAW_CHANNELS_LOCAL_ALLOW_FROM"`
+}
+
 type HeartbeatConfig struct {
 	Enabled  bool `json:"enabled"  env:"PICOCLAW_HEARTBEAT_ENABLED"`
 	Interval int  `json:"interval" env:"PICOCLAW_HEARTBEAT_INTERVAL"` // minutes, min 5
@@ -374,7 +556,7 @@ type OpenAIProviderConfig struct {
 // ModelConfig represents a model-centric provider configuration.
 // It allows adding new providers (especially OpenAI-compatible ones) via configuration only.
 // The model field uses protocol prefix format: [protocol/]model-identifier
-// Supported protocols: openai, anthropic, antigravity, claude-cli, codex-cli, github-copilot
+// Supported protocols: openai, anthropic, gemini, ollama, antigravity, claude-cli, codex-cli, github-copilot, replay
 // Default protocol is "openai" if no prefix is specified.
 type ModelConfig struct {
 	// Required fields
@@ -393,7 +575,26 @@ type ModelConfig struct {
 
 	// Optional optimizations
 	RPM            int    `json:"rpm,omitempty"`              // Requests per minute limit
+	TPM            int    `json:"tpm,omitempty"`              // Tokens per minute limit
 	MaxTokensField string `json:"max_tokens_field,omitempty"` // Field name for max tokens (e.g., "max_completion_tokens")
+	PromptCache    *bool  `json:"prompt_cache,omitempty"`     // Prompt caching; unset uses the provider default
+
+	// Reasoning models
+	ThinkingBudget  int    `json:"thinking_budget,omitempty"`  // Thinking token budget (Anthropic, Gemini)
+	ReasoningEffort string `json:"reasoning_effort,omitempty"` // low, medium or high (OpenAI-compatible, Ollama)
+
+	// Ollama native protocol only
+	KeepAlive string `json:"keep_alive,omitempty"` // How long the model stays loaded, e.g. "10m" or "-1"
+	NumCtx    int    `json:"num_ctx,omitempty"`    // Context window size
+	AutoPull  bool   `json:"auto_pull,omitempty"`  // Pull the model on first use if it is not installed
+
+	// Testing
+	Record string `json:"record,omitempty"` // Cassette file to record requests to, for a "replay/<file>" model
+	Strict bool   `json:"strict,omitempty"` // For a "replay/<file>" model, fail requests that match no recording
+
+	// Gemini native protocol only: harm category -> block threshold,
+	// e.g. {"HARASSMENT": "BLOCK_ONLY_HIGH"}
+	SafetySettings map[string]string `json:"safety_settings,omitempty"`
 }
 
 // Validate checks if the ModelConfig has all required fields.
@@ -408,8 +609,24 @@ func (c *ModelConfig) Validate() error {
 }
 
 type GatewayConfig struct {
-	Host string `json:"host" env:"PICOCLAW_GATEWAY_HOST"`
-	Port int    `json:"port" env:"PICOCLAW_GATEWAY_PORT"`
+	Host      string          `json:"host"      env:"PICOCLAW_GATEWAY_HOST"`
+	Port      int             `json:"port"      env:"PICOCLAW_GATEWAY_PORT"`
+	Dashboard DashboardConfig `json:"dashboard"`
+	Outbox    OutboxConfig    `json:"outbox"`
+}
+
+// OutboxConfig controls retries of outbound messages that fail to send.
+// Messages still failing after MaxAttempts go to the dead-letter log.
+type OutboxConfig struct {
+	MaxAttempts int `json:"max_attempts" env:"PICOCLAW_GATEWAY_OUTBOX_MAX_ATTEMPTS"`
+	MaxBackoff  int `json:"max_backoff"  env:"PICOCLAW_GATEWAY_OUTBOX_MAX_BACKOFF"` // seconds
+}
+
+// DashboardConfig controls the web admin dashboard served on the gateway port.
+// The dashboard refuses to start without a token.
+type DashboardConfig struct {
+	Enabled bool   `json:"enabled" env:"PICOCLAW_GATEWAY_DASHBOARD_ENABLED"`
+	Token   string `json:"token"   env:"PICOCLAW_GATEWAY_DASHBOARD_TOKEN"`
 }
 
 type BraveConfig struct {
@@ -17,6 +17,10 @@ func DefaultConfig() *Config {
 				MaxTokens:           8192,
 				Temperature:         nil, // nil means use provider default
 				MaxToolIterations:   20,
+				ResponseCache: ResponseCacheConfig{
+					TTLSeconds: 3600,
+					MaxEntries: 1000,
+				},
 			},
 		},
 		Bindings: []AgentBinding{},
@@ -29,6 +33,15 @@ func DefaultConfig() *Config {
 				BridgeURL: "ws://localhost:3001",
 				AllowFrom: FlexibleStringSlice{},
 			},
+			WhatsAppCloud: WhatsAppCloudConfig{
+				Enabled:          false,
+				APIVersion:       "v21.0",
+				WebhookHost:      "0.0.0.0",
+				WebhookPort:      18794,
+				WebhookPath:      "/webhook/whatsapp",
+				TemplateLanguage: "en_US",
+				AllowFrom:        FlexibleStringSlice{},
+			},
 			Telegram: TelegramConfig{
 				Enabled:   false,
 				Token:     "",
@@ -113,6 +126,41 @@ func DefaultConfig() *Config {
 				AllowFrom:      FlexibleStringSlice{},
 				ReplyTimeout:   5,
 			},
+			Signal: SignalConfig{
+				Enabled:   false,
+				Account:   "",
+				URL:       "http://127.0.0.1:8080",
+				AllowFrom: FlexibleStringSlice{},
+			},
+			Mattermost: MattermostConfig{
+				Enabled:     false,
+				MentionOnly: true,
+				AllowFrom:   FlexibleStringSlice{},
+			},
+			RocketChat: RocketChatConfig{
+				Enabled:     false,
+				MentionOnly: true,
+				AllowFrom:   FlexibleStringSlice{},
+			},
+			IRC: IRCConfig{
+				Enabled:     false,
+				Server:      "irc.libera.chat:6697",
+				TLS:         true,
+				Channels:    FlexibleStringSlice{},
+				MentionOnly: true,
+				AllowFrom:   FlexibleStringSlice{},
+			},
+			XMPP: XMPPConfig{
+				Enabled:     false,
+				Resource:    "picoclaw",
+				Rooms:       FlexibleStringSlice{},
+				MentionOnly: true,
+				AllowFrom:   FlexibleStringSlice{},
+			},
+			Local: LocalConfig{
+				Enabled:   false,
+				AllowFrom: FlexibleStringSlice{},
+			},
 		},
 		Providers: ProvidersConfig{
 			OpenAI: OpenAIProviderConfig{WebSearch: true},
@@ -251,8 +299,7 @@ func DefaultConfig() *Config {
 			{
 				ModelName: "llama3",
 				Model:     "ollama/llama3",
-				APIBase:   "http://localhost:11434/v1",
-				APIKey:    "ollama",
+				APIBase:   "http://localhost:11434",
 			},
 
 			// VLLM (local) - http://localhost:8000
@@ -266,6 +313,14 @@ func DefaultConfig() *Config {
 		Gateway: GatewayConfig{
 			Host: "0.0.0.0",
 			Port: 18790,
+			Dashboard: DashboardConfig{
+				Enabled: false,
+				Token:   "",
+			},
+			Outbox: OutboxConfig{
+				MaxAttempts: 12,
+				MaxBackoff:  300,
+			},
 		},
 		Tools: ToolsConfig{
 			Web: WebToolsConfig{
@@ -0,0 +1,379 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Config paths use dotted JSON keys with numeric list indices, the same
+// notation as Issue.Path: "channels.telegram.token", "model_list.0.api_key".
+
+func splitPath(path string) ([]string, error) {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return nil, fmt.Errorf("empty config path")
+	}
+	segments := strings.Split(path, ".")
+	for _, s := range segments {
+		if s == "" {
+			return nil, fmt.Errorf("invalid config path %q", path)
+		}
+	}
+	return segments, nil
+}
+
+// typeAt resolves the Go type that a config path refers to, so unknown keys
+// are reported before anything is read or written.
+func typeAt(segments []string) (reflect.Type, error) {
+	t := reflect.TypeOf(Config{})
+	for i, seg := range segments {
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		at := strings.Join(segments[:i+1], ".")
+		switch t.Kind() {
+		case reflect.Struct:
+			fields := jsonFields(t)
+			field, exact := lookupField(fields, seg)
+			if field == nil || !exact {
+				msg := fmt.Sprintf("unknown config key %q", at)
+				if s := suggestField(fields, seg); s != "" {
+					msg += fmt.Sprintf(" (did you mean %q?)", s)
+				}
+				return nil, fmt.Errorf("%s", msg)
+			}
+			t = field.Type
+		case reflect.Map:
+			t = t.Elem()
+		case reflect.Slice:
+			if _, err := strconv.Atoi(seg); err != nil {
+				return nil, fmt.Errorf("%s: expected a list index", at)
+			}
+			t = t.Elem()
+		default:
+			return nil, fmt.Errorf("%s: %s is not an object or list", at, strings.Join(segments[:i], "."))
+		}
+	}
+	return t, nil
+}
+
+func decodeGeneric(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// GetPath returns the value at path in the effective config, as it would be
+// serialized to JSON.
+func (c *Config) GetPath(path string) (any, error) {
+	segments, err := splitPath(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := typeAt(segments); err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	node, err := decodeGeneric(data)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, seg := range segments {
+		at := strings.Join(segments[:i+1], ".")
+		switch n := node.(type) {
+		case map[string]any:
+			v, ok := n[seg]
+			if !ok {
+				return nil, fmt.Errorf("%s is not set", at)
+			}
+			node = v
+		case []any:
+			idx, _ := strconv.Atoi(seg)
+			if idx < 0 || idx >= len(n) {
+				return nil, fmt.Errorf("%s: index out of range (length %d)", at, len(n))
+			}
+			node = n[idx]
+		default:
+			return nil, fmt.Errorf("%s is not set", at)
+		}
+	}
+	return node, nil
+}
+
+// parseValue converts a command-line value for a field of type t. String
+// fields take the value verbatim; everything else is parsed as JSON.
+func parseValue(t reflect.Type, value string) (any, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.String {
+		return value, nil
+	}
+	if t == agentModelConfigType && !strings.HasPrefix(strings.TrimSpace(value), "{") {
+		return value, nil
+	}
+	v, err := decodeGeneric([]byte(value))
+	if err != nil {
+		return nil, fmt.Errorf("value must be valid JSON for this key: %w", err)
+	}
+	return v, nil
+}
+
+// locateValue walks the JSON document in data along segments without
+// decoding it. If the whole path exists, matched is len(segments) and
+// [start, end) is the value's byte range. Otherwise matched is the number of
+// segments that exist and [start, end) covers the value they lead to: an
+// object that lacks the next key, or a null. List elements must already
+// exist.
+func locateValue(data []byte, segments []string) (start, end, matched int, err error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	start = skipSpace(data, 0)
+
+	for ; matched < len(segments); matched++ {
+		seg := segments[matched]
+		tok, err := dec.Token()
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		switch tok {
+		case json.Delim('{'):
+			found := false
+			for dec.More() {
+				key, err := dec.Token()
+				if err != nil {
+					return 0, 0, 0, err
+				}
+				if key == seg {
+					start = skipPast(data, int(dec.InputOffset()), ':')
+					found = true
+					break
+				}
+				if err := skipValue(dec); err != nil {
+					return 0, 0, 0, err
+				}
+			}
+			if !found {
+				if _, err := dec.Token(); err != nil {
+					return 0, 0, 0, err
+				}
+				return start, int(dec.InputOffset()), matched, nil
+			}
+		case json.Delim('['):
+			idx, _ := strconv.Atoi(seg)
+			length, found := 0, false
+			for dec.More() {
+				if length == idx {
+					start = skipPast(data, int(dec.InputOffset()), ',')
+					found = true
+					break
+				}
+				if err := skipValue(dec); err != nil {
+					return 0, 0, 0, err
+				}
+				length++
+			}
+			if !found {
+				return 0, 0, 0, fmt.Errorf("index %s out of range (length %d)", seg, length)
+			}
+		case nil:
+			return start, int(dec.InputOffset()), matched, nil
+		default:
+			return 0, 0, 0, fmt.Errorf("cannot set %q inside a %s", seg, jsonKind(tok))
+		}
+	}
+
+	if err := skipValue(dec); err != nil {
+		return 0, 0, 0, err
+	}
+	return start, int(dec.InputOffset()), matched, nil
+}
+
+func skipValue(dec *json.Decoder) error {
+	var raw json.RawMessage
+	return dec.Decode(&raw)
+}
+
+func skipSpace(data []byte, i int) int {
+	for i < len(data) && strings.IndexByte(" \t\r\n", data[i]) >= 0 {
+		i++
+	}
+	return i
+}
+
+// skipPast skips whitespace and, if present, one sep byte after it.
+func skipPast(data []byte, i int, sep byte) int {
+	i = skipSpace(data, i)
+	if i < len(data) && data[i] == sep {
+		i = skipSpace(data, i+1)
+	}
+	return i
+}
+
+// lineIndent returns the leading whitespace of the line containing data[i].
+func lineIndent(data []byte, i int) string {
+	lineStart := bytes.LastIndexByte(data[:i], '\n') + 1
+	end := lineStart
+	for end < i && (data[end] == ' ' || data[end] == '\t') {
+		end++
+	}
+	return string(data[lineStart:end])
+}
+
+// encodeValue renders value as JSON: on one line if inline is set, else
+// indented with continuation lines starting with indent.
+func encodeValue(value any, indent string, inline bool) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if !inline {
+		enc.SetIndent(indent, "  ")
+	}
+	if err := enc.Encode(value); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// setValue returns a copy of data with value stored at segments. Only the
+// bytes of the changed value are rewritten; missing objects are added as
+// new members at the end of their parent.
+func setValue(data []byte, segments []string, value any) ([]byte, error) {
+	start, end, matched, err := locateValue(data, segments)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(segments) - 1; i > matched; i-- {
+		value = map[string]any{segments[i]: value}
+	}
+
+	var replacement []byte
+	switch {
+	case matched == len(segments):
+		if replacement, err = encodeValue(value, lineIndent(data, start), false); err != nil {
+			return nil, err
+		}
+	case data[start] == 'n':
+		value = map[string]any{segments[matched]: value}
+		if replacement, err = encodeValue(value, lineIndent(data, start), false); err != nil {
+			return nil, err
+		}
+	default:
+		// Append a member to the object in [start, end). A one-line object
+		// stays on one line; otherwise the member goes on its own line,
+		// indented one level deeper than the closing brace.
+		inline := !bytes.Contains(data[start:end], []byte("\n"))
+		closeIndent := lineIndent(data, end-1)
+		indent := closeIndent + "  "
+		encoded, err := encodeValue(value, indent, inline)
+		if err != nil {
+			return nil, err
+		}
+		key, _ := json.Marshal(segments[matched])
+
+		last := end - 2
+		for last > start && strings.IndexByte(" \t\r\n", data[last]) >= 0 {
+			last--
+		}
+		member := string(key) + ": " + string(encoded)
+		switch {
+		case inline && last > start:
+			member = ", " + member
+		case !inline:
+			member = "\n" + indent + member + "\n" + closeIndent
+			if last > start {
+				member = "," + member
+			}
+			end--
+		}
+		start = last + 1
+		if inline {
+			end = start
+		}
+		replacement = []byte(member)
+	}
+
+	updated := make([]byte, 0, len(data)+len(replacement))
+	updated = append(updated, data[:start]...)
+	updated = append(updated, replacement...)
+	return append(updated, data[end:]...), nil
+}
+
+// SetPath sets a single value in the config file and saves it, leaving the
+// rest of the file (key order, formatting and comment keys) as it was. The
+// file is created from the defaults if it does not exist. The change is rejected if it makes
+// the file structurally invalid; problems already in the file do not block
+// it. The issues of the resulting file are returned for the caller to report.
+func SetPath(file, path, value string) ([]Issue, error) {
+	segments, err := splitPath(path)
+	if err != nil {
+		return nil, err
+	}
+	t, err := typeAt(segments)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := parseValue(t, value)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		data, err = json.MarshalIndent(DefaultConfig(), "", "  ")
+		data = append(data, '\n')
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err := decodeGeneric(data); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", file, err)
+	}
+	updated, err := setValue(data, segments, parsed)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	issues := ValidateJSON(updated)
+	existing := make(map[Issue]bool)
+	for _, issue := range ValidateJSON(data) {
+		existing[issue] = true
+	}
+	var introduced []Issue
+	for _, issue := range issues {
+		if issue.Severity == SeverityError && !existing[issue] {
+			introduced = append(introduced, issue)
+		}
+	}
+	if len(introduced) > 0 {
+		return introduced, fmt.Errorf("refusing to save invalid config")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(file, updated, 0o600); err != nil {
+		return nil, err
+	}
+
+	cfg := DefaultConfig()
+	if err := json.Unmarshal(updated, cfg); err != nil {
+		return issues, nil
+	}
+	return append(issues, cfg.Validate()...), nil
+}
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"reflect"
+)
+
+// SchemaID is the JSON Schema dialect used by Schema.
+const SchemaID = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema returns a JSON Schema describing config.json, generated from the
+// Config structs so it never drifts from what LoadConfig accepts. Editors use
+// it for completion and to flag unknown keys.
+func Schema() map[string]any {
+	s := schemaFor(reflect.TypeOf(Config{}))
+	s["$schema"] = SchemaID
+	s["title"] = "PicoClaw configuration"
+	return s
+}
+
+func schemaFor(t reflect.Type) map[string]any {
+	switch t {
+	case flexibleStringSliceType:
+		return map[string]any{
+			"type":  "array",
+			"items": map[string]any{"type": []string{"string", "number"}},
+		}
+	case agentModelConfigType:
+		return map[string]any{
+			"oneOf": []any{
+				map[string]any{"type": "string"},
+				structSchema(t),
+			},
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return schemaFor(t.Elem())
+	case reflect.Struct:
+		return structSchema(t)
+	case reflect.Map:
+		return map[string]any{
+			"type":                 "object",
+			"additionalProperties": schemaFor(t.Elem()),
+		}
+	case reflect.Slice:
+		return map[string]any{
+			"type":  "array",
+			"items": schemaFor(t.Elem()),
+		}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	default:
+		return map[string]any{}
+	}
+}
+
+func structSchema(t reflect.Type) map[string]any {
+	properties := make(map[string]any)
+	for _, f := range jsonFields(t) {
+		properties[f.Name] = schemaFor(f.Type)
+	}
+	return map[string]any{
+		"type":       "object",
+		"properties": properties,
+		// Keys such as "_comment" and "$schema" are annotations, see isCommentKey.
+		"patternProperties":    map[string]any{`^(_|\$schema$)`: map[string]any{}},
+		"additionalProperties": false,
+	}
+}
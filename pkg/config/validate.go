@@ -0,0 +1,584 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Severity classifies a validation issue.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Issue is a single problem found while validating a config.
+// Path uses dotted notation with numeric list indices, e.g. "model_list.0.model".
+type Issue struct {
+	Path     string   `json:"path"`
+	Message  string   `json:"message"`
+	Severity Severity `json:"severity"`
+}
+
+func (i Issue) String() string {
+	if i.Path == "" {
+		return fmt.Sprintf("%s: %s", i.Severity, i.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", i.Severity, i.Path, i.Message)
+}
+
+// HasErrors reports whether any issue has error severity.
+func HasErrors(issues []Issue) bool {
+	for _, issue := range issues {
+		if issue.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	flexibleStringSliceType = reflect.TypeOf(FlexibleStringSlice(nil))
+	agentModelConfigType    = reflect.TypeOf(AgentModelConfig{})
+)
+
+// jsonField is a struct field as seen by encoding/json.
+type jsonField struct {
+	Name string
+	Type reflect.Type
+}
+
+// jsonFields returns the JSON-visible fields of a struct type, promoting
+// fields of untagged embedded structs the same way encoding/json does.
+func jsonFields(t reflect.Type) []jsonField {
+	var fields []jsonField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			fields = append(fields, jsonFields(f.Type)...)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, jsonField{Name: name, Type: f.Type})
+	}
+	return fields
+}
+
+// isCommentKey reports whether an object key is a free-form annotation such as
+// "_comment" or "$schema". These are accepted anywhere and ignored on load.
+func isCommentKey(key string) bool {
+	return strings.HasPrefix(key, "_") || key == "$schema"
+}
+
+// ValidateJSON checks raw config.json content against the Config structure,
+// reporting unknown keys and values of the wrong type. LoadConfig silently
+// ignores both, so this is where typos surface.
+func ValidateJSON(data []byte) []Issue {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var raw any
+	if err := dec.Decode(&raw); err != nil {
+		return []Issue{{Message: fmt.Sprintf("invalid JSON: %v", err), Severity: SeverityError}}
+	}
+
+	var issues []Issue
+	checkValue(&issues, "", raw, reflect.TypeOf(Config{}))
+	return issues
+}
+
+func checkValue(issues *[]Issue, path string, value any, t reflect.Type) {
+	if value == nil {
+		// encoding/json accepts null for every type and leaves the default.
+		return
+	}
+
+	typeError := func(want string) {
+		*issues = append(*issues, Issue{
+			Path:     path,
+			Message:  fmt.Sprintf("expected %s, got %s", want, jsonKind(value)),
+			Severity: SeverityError,
+		})
+	}
+
+	switch t {
+	case flexibleStringSliceType:
+		items, ok := value.([]any)
+		if !ok {
+			typeError("array")
+			return
+		}
+		for i, item := range items {
+			switch item.(type) {
+			case string, json.Number:
+			default:
+				*issues = append(*issues, Issue{
+					Path:     joinPath(path, strconv.Itoa(i)),
+					Message:  fmt.Sprintf("expected string or number, got %s", jsonKind(item)),
+					Severity: SeverityError,
+				})
+			}
+		}
+		return
+	case agentModelConfigType:
+		if _, ok := value.(string); ok {
+			return
+		}
+		if _, ok := value.(map[string]any); !ok {
+			typeError("string or object")
+			return
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		checkValue(issues, path, value, t.Elem())
+	case reflect.Struct:
+		obj, ok := value.(map[string]any)
+		if !ok {
+			typeError("object")
+			return
+		}
+		fields := jsonFields(t)
+		keys := make([]string, 0, len(obj))
+		for key := range obj {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if isCommentKey(key) {
+				continue
+			}
+			field, exact := lookupField(fields, key)
+			switch {
+			case field == nil:
+				msg := "unknown key"
+				if s := suggestField(fields, key); s != "" {
+					msg += fmt.Sprintf(" (did you mean %q?)", s)
+				}
+				*issues = append(*issues, Issue{Path: joinPath(path, key), Message: msg, Severity: SeverityError})
+				continue
+			case !exact:
+				*issues = append(*issues, Issue{
+					Path:     joinPath(path, key),
+					Message:  fmt.Sprintf("key should be spelled %q", field.Name),
+					Severity: SeverityWarning,
+				})
+			}
+			checkValue(issues, joinPath(path, key), obj[key], field.Type)
+		}
+	case reflect.Map:
+		obj, ok := value.(map[string]any)
+		if !ok {
+			typeError("object")
+			return
+		}
+		for key, item := range obj {
+			checkValue(issues, joinPath(path, key), item, t.Elem())
+		}
+	case reflect.Slice:
+		items, ok := value.([]any)
+		if !ok {
+			typeError("array")
+			return
+		}
+		for i, item := range items {
+			checkValue(issues, joinPath(path, strconv.Itoa(i)), item, t.Elem())
+		}
+	case reflect.String:
+		if _, ok := value.(string); !ok {
+			typeError("string")
+		}
+	case reflect.Bool:
+		if _, ok := value.(bool); !ok {
+			typeError("boolean")
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := value.(json.Number)
+		if !ok {
+			typeError("integer")
+			return
+		}
+		if _, err := n.Int64(); err != nil {
+			typeError("integer")
+		}
+	case reflect.Float32, reflect.Float64:
+		if _, ok := value.(json.Number); !ok {
+			typeError("number")
+		}
+	}
+}
+
+// lookupField finds a field by name. Like encoding/json it falls back to a
+// case-insensitive match; exact reports whether the spelling matched.
+func lookupField(fields []jsonField, key string) (field *jsonField, exact bool) {
+	for i := range fields {
+		if fields[i].Name == key {
+			return &fields[i], true
+		}
+	}
+	for i := range fields {
+		if strings.EqualFold(fields[i].Name, key) {
+			return &fields[i], false
+		}
+	}
+	return nil, false
+}
+
+// suggestField returns the closest known field name to key, if any is close
+// enough to plausibly be a typo.
+func suggestField(fields []jsonField, key string) string {
+	best, bestDist := "", -1
+	for _, f := range fields {
+		d := editDistance(strings.ToLower(key), f.Name)
+		if bestDist < 0 || d < bestDist {
+			best, bestDist = f.Name, d
+		}
+	}
+	if bestDist < 0 || bestDist > max(2, len(key)/3) {
+		return ""
+	}
+	return best
+}
+
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func jsonKind(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case json.Number, float64:
+		return "number"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// channelRequirement lists the fields an enabled channel cannot start without.
+type channelRequirement struct {
+	name     string
+	enabled  bool
+	required map[string]bool // json field name -> is set
+}
+
+func (c *Config) channelRequirements() []channelRequirement {
+	ch := c.Channels
+	return []channelRequirement{
+		{"whatsapp", ch.WhatsApp.Enabled, map[string]bool{"bridge_url": ch.WhatsApp.BridgeURL != ""}},
+		{"whatsapp_cloud", ch.WhatsAppCloud.Enabled, map[string]bool{
+			"phone_number_id": ch.WhatsAppCloud.PhoneNumberID != "",
+			"access_token":    ch.WhatsAppCloud.AccessToken != "",
+			"app_secret":      ch.WhatsAppCloud.AppSecret != "",
+			"verify_token":    ch.WhatsAppCloud.VerifyToken != "",
+		}},
+		{"telegram", ch.Telegram.Enabled, map[string]bool{"token": ch.Telegram.Token != ""}},
+		{"feishu", ch.Feishu.Enabled, map[string]bool{
+			"app_id":     ch.Feishu.AppID != "",
+			"app_secret": ch.Feishu.AppSecret != "",
+		}},
+		{"discord", ch.Discord.Enabled, map[string]bool{"token": ch.Discord.Token != ""}},
+		{"qq", ch.QQ.Enabled, map[string]bool{
+			"app_id":     ch.QQ.AppID != "",
+			"app_secret": ch.QQ.AppSecret != "",
+		}},
+		{"dingtalk", ch.DingTalk.Enabled, map[string]bool{
+			"client_id":     ch.DingTalk.ClientID != "",
+			"client_secret": ch.DingTalk.ClientSecret != "",
+		}},
+		{"slack", ch.Slack.Enabled, map[string]bool{
+			"bot_token": ch.Slack.BotToken != "",
+			"app_token": ch.Slack.AppToken != "",
+		}},
+		{"line", ch.LINE.Enabled, map[string]bool{
+			"channel_secret":       ch.LINE.ChannelSecret != "",
+			"channel_access_token": ch.LINE.ChannelAccessToken != "",
+		}},
+		{"onebot", ch.OneBot.Enabled, map[string]bool{"ws_url": ch.OneBot.WSUrl != ""}},
+		{"wecom", ch.WeCom.Enabled, map[string]bool{
+			"token":       ch.WeCom.Token != "",
+			"webhook_url": ch.WeCom.WebhookURL != "",
+		}},
+		{"wecom_app", ch.WeComApp.Enabled, map[string]bool{
+			"corp_id":     ch.WeComApp.CorpID != "",
+			"corp_secret": ch.WeComApp.CorpSecret != "",
+			"agent_id":    ch.WeComApp.AgentID != 0,
+		}},
+		{"signal", ch.Signal.Enabled, map[string]bool{"account": ch.Signal.Account != ""}},
+		{"mattermost", ch.Mattermost.Enabled, map[string]bool{
+			"url":   ch.Mattermost.URL != "",
+			"token": ch.Mattermost.Token != "",
+		}},
+		{"rocketchat", ch.RocketChat.Enabled, map[string]bool{
+			"url":     ch.RocketChat.URL != "",
+			"user_id": ch.RocketChat.UserID != "",
+			"token":   ch.RocketChat.Token != "",
+		}},
+		{"irc", ch.IRC.Enabled, map[string]bool{
+			"server": ch.IRC.Server != "",
+			"nick":   ch.IRC.Nick != "",
+		}},
+		{"xmpp", ch.XMPP.Enabled, map[string]bool{
+			"jid":      ch.XMPP.JID != "",
+			"password": ch.XMPP.Password != "",
+		}},
+	}
+}
+
+// channelGroupPolicies returns the group policy of each channel that has one.
+func (c *Config) channelGroupPolicies() map[string]GroupPolicyConfig {
+	ch := c.Channels
+	return map[string]GroupPolicyConfig{
+		"whatsapp":   ch.WhatsApp.Groups,
+		"telegram":   ch.Telegram.Groups,
+		"feishu":     ch.Feishu.Groups,
+		"discord":    ch.Discord.Groups,
+		"qq":         ch.QQ.Groups,
+		"dingtalk":   ch.DingTalk.Groups,
+		"slack":      ch.Slack.Groups,
+		"line":       ch.LINE.Groups,
+		"onebot":     ch.OneBot.Groups,
+		"wecom":      ch.WeCom.Groups,
+		"signal":     ch.Signal.Groups,
+		"mattermost": ch.Mattermost.Groups,
+		"rocketchat": ch.RocketChat.Groups,
+		"irc":        ch.IRC.Groups,
+		"xmpp":       ch.XMPP.Groups,
+	}
+}
+
+func validGroupTrigger(trigger string) bool {
+	switch trigger {
+	case "", GroupTriggerAlways, GroupTriggerMention, GroupTriggerPrefix, GroupTriggerKeyword:
+		return true
+	}
+	return false
+}
+
+// Validate performs semantic checks on a loaded config: required fields of
+// enabled channels and model_list entries, bindings that point at unknown
+// agents, and model references that no model_list entry can serve.
+func (c *Config) Validate() []Issue {
+	var issues []Issue
+	add := func(severity Severity, path, format string, args ...any) {
+		issues = append(issues, Issue{Path: path, Message: fmt.Sprintf(format, args...), Severity: severity})
+	}
+
+	for _, req := range c.channelRequirements() {
+		if !req.enabled {
+			continue
+		}
+		fields := make([]string, 0, len(req.required))
+		for field := range req.required {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		for _, field := range fields {
+			if !req.required[field] {
+				add(SeverityError, "channels."+req.name+"."+field, "required when the channel is enabled")
+			}
+		}
+	}
+
+	for name, policy := range c.channelGroupPolicies() {
+		path := "channels." + name + ".groups"
+		if !validGroupTrigger(policy.Trigger) {
+			add(SeverityError, path+".trigger", "unknown trigger %q (use always, mention, prefix or keyword)", policy.Trigger)
+		}
+		if policy.Trigger == GroupTriggerPrefix && len(policy.Prefixes) == 0 {
+			add(SeverityError, path+".prefixes", "required when trigger is %q", GroupTriggerPrefix)
+		}
+		if policy.Trigger == GroupTriggerKeyword && len(policy.Keywords) == 0 {
+			add(SeverityError, path+".keywords", "required when trigger is %q", GroupTriggerKeyword)
+		}
+		for chatID, o := range policy.Chats {
+			if !validGroupTrigger(o.Trigger) {
+				add(SeverityError, path+".chats."+chatID+".trigger", "unknown trigger %q", o.Trigger)
+			}
+		}
+	}
+
+	modelNames := make(map[string]bool, len(c.ModelList))
+	for i := range c.ModelList {
+		m := &c.ModelList[i]
+		if m.ModelName == "" {
+			add(SeverityError, fmt.Sprintf("model_list.%d.model_name", i), "required")
+		}
+		if m.Model == "" {
+			add(SeverityError, fmt.Sprintf("model_list.%d.model", i), "required")
+		}
+		if m.ModelName != "" {
+			modelNames[m.ModelName] = true
+		}
+	}
+
+	agentIDs := make(map[string]bool, len(c.Agents.List))
+	for i, a := range c.Agents.List {
+		id := normalizeAgentRef(a.ID)
+		if agentIDs[id] {
+			add(SeverityError, fmt.Sprintf("agents.list.%d.id", i), "duplicate agent id %q", id)
+		}
+		agentIDs[id] = true
+	}
+	if len(agentIDs) == 0 {
+		// Without an explicit list the registry creates a single "main" agent.
+		agentIDs["main"] = true
+	}
+	for i, b := range c.Bindings {
+		if !agentIDs[normalizeAgentRef(b.AgentID)] {
+			add(SeverityError, fmt.Sprintf("bindings.%d.agent_id", i), "unknown agent %q", b.AgentID)
+		}
+		if b.Match.Channel == "" {
+			add(SeverityError, fmt.Sprintf("bindings.%d.match.channel", i), "required")
+		}
+	}
+
+	// The default model is resolved through model_list when providers are
+	// created, so a miss there stops the agent from starting. Other references
+	// may also name a provider/model directly, so a miss is only suspicious.
+	checkModel := func(severity Severity, path, name string) {
+		if name == "" || modelNames[name] {
+			return
+		}
+		if severity == SeverityWarning && strings.Contains(name, "/") {
+			return
+		}
+		add(severity, path, "model %q is not defined in model_list", name)
+	}
+	d := c.Agents.Defaults
+	checkModel(SeverityError, "agents.defaults.model", d.Model)
+	for i, name := range d.ModelFallbacks {
+		checkModel(SeverityWarning, fmt.Sprintf("agents.defaults.model_fallbacks.%d", i), name)
+	}
+	switch d.FallbackStrategy {
+	case "", "ordered", "lowest_latency", "weighted":
+	default:
+		add(SeverityError, "agents.defaults.fallback_strategy",
+			"unknown strategy %q (want ordered, lowest_latency or weighted)", d.FallbackStrategy)
+	}
+	if d.HealthProbeInterval < 0 {
+		add(SeverityError, "agents.defaults.health_probe_interval", "must not be negative")
+	}
+	checkModel(SeverityWarning, "agents.defaults.image_model", d.ImageModel)
+	for i, name := range d.ImageModelFallbacks {
+		checkModel(SeverityWarning, fmt.Sprintf("agents.defaults.image_model_fallbacks.%d", i), name)
+	}
+	checkAgentModel := func(path string, m *AgentModelConfig) {
+		if m == nil {
+			return
+		}
+		checkModel(SeverityWarning, path+".primary", m.Primary)
+		for i, name := range m.Fallbacks {
+			checkModel(SeverityWarning, fmt.Sprintf("%s.fallbacks.%d", path, i), name)
+		}
+	}
+	for i, a := range c.Agents.List {
+		checkAgentModel(fmt.Sprintf("agents.list.%d.model", i), a.Model)
+		if a.Subagents != nil {
+			checkAgentModel(fmt.Sprintf("agents.list.%d.subagents.model", i), a.Subagents.Model)
+			for j, id := range a.Subagents.AllowAgents {
+				if id != "*" && !agentIDs[normalizeAgentRef(id)] {
+					add(SeverityWarning, fmt.Sprintf("agents.list.%d.subagents.allow_agents.%d", i, j),
+						"unknown agent %q", id)
+				}
+			}
+		}
+	}
+
+	switch strings.ToLower(c.Channels.Telegram.ParseMode) {
+	case "", "html", "markdownv2":
+	default:
+		add(SeverityError, "channels.telegram.parse_mode",
+			"unknown parse mode %q (want html or markdownv2)", c.Channels.Telegram.ParseMode)
+	}
+
+	if c.Gateway.Dashboard.Enabled && strings.TrimSpace(c.Gateway.Dashboard.Token) == "" {
+		add(SeverityError, "gateway.dashboard.token", "required when the dashboard is enabled")
+	}
+	if c.Gateway.Outbox.MaxAttempts < 0 {
+		add(SeverityError, "gateway.outbox.max_attempts", "must not be negative")
+	}
+	if c.Gateway.Outbox.MaxBackoff < 0 {
+		add(SeverityError, "gateway.outbox.max_backoff", "must not be negative")
+	}
+
+	return issues
+}
+
+// normalizeAgentRef approximates routing.NormalizeAgentID, which config
+// cannot import: IDs are case-insensitive and an empty ID means "main".
+func normalizeAgentRef(id string) string {
+	id = strings.ToLower(strings.TrimSpace(id))
+	if id == "" {
+		return "main"
+	}
+	return id
+}
+
+// ValidateFile runs both the structural and semantic checks on a config file.
+// Semantic checks are skipped when the file cannot be loaded.
+func ValidateFile(path string) ([]Issue, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	issues := ValidateJSON(data)
+	if HasErrors(issues) {
+		return issues, nil
+	}
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		return append(issues, Issue{Message: err.Error(), Severity: SeverityError}), nil
+	}
+	return append(issues, cfg.Validate()...), nil
+}
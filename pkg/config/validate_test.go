@@ -0,0 +1,310 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func findIssue(issues []Issue, path string) *Issue {
+	for i := range issues {
+		if issues[i].Path == path {
+			return &issues[i]
+		}
+	}
+	return nil
+}
+
+func TestValidateJSON_UnknownKeysAndTypes(t *testing.T) {
+	data := `{
+		"_comment": "ignored",
+		"agents": {"defaults": {"max_tokens": "lots", "model": "gpt4"}},
+		"channels": {
+			"telegram": {"enabled": true, "tokne": "x", "allow_from": [123, "abc", true]}
+		},
+		"model_list": [{"model_name": "gpt4", "model": "openai/gpt-5.2", "rpm": 1.5}],
+		"gateway": {"Port": 18790}
+	}`
+
+	issues := ValidateJSON([]byte(data))
+
+	if issue := findIssue(issues, "channels.telegram.tokne"); issue == nil ||
+		issue.Severity != SeverityError || !strings.Contains(issue.Message, `"token"`) {
+		t.Errorf("expected unknown key with suggestion, got %+v", issue)
+	}
+	if issue := findIssue(issues, "agents.defaults.max_tokens"); issue == nil ||
+		!strings.Contains(issue.Message, "expected integer") {
+		t.Errorf("expected type error for max_tokens, got %+v", issue)
+	}
+	if issue := findIssue(issues, "channels.telegram.allow_from.2"); issue == nil {
+		t.Error("expected type error for boolean in allow_from")
+	}
+	if issue := findIssue(issues, "channels.telegram.allow_from.0"); issue != nil {
+		t.Errorf("numbers are valid in allow_from, got %+v", issue)
+	}
+	if issue := findIssue(issues, "model_list.0.rpm"); issue == nil {
+		t.Error("expected type error for fractional rpm")
+	}
+	if issue := findIssue(issues, "gateway.Port"); issue == nil || issue.Severity != SeverityWarning {
+		t.Errorf("expected case mismatch warning, got %+v", issue)
+	}
+	if issue := findIssue(issues, "_comment"); issue != nil {
+		t.Errorf("comment keys should be ignored, got %+v", issue)
+	}
+}
+
+func TestValidateJSON_ExampleConfig(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "config", "config.example.json"))
+	if err != nil {
+		t.Fatalf("read example: %v", err)
+	}
+	if issues := ValidateJSON(data); len(issues) != 0 {
+		t.Errorf("expected example config to be clean, got %v", issues)
+	}
+}
+
+func TestValidateJSON_AgentModelForms(t *testing.T) {
+	data := `{"agents": {"list": [
+		{"id": "a", "model": "gpt4"},
+		{"id": "b", "model": {"primary": "gpt4", "fallback": ["x"]}},
+		{"id": "c", "model": 42}
+	]}}`
+	issues := ValidateJSON([]byte(data))
+	if findIssue(issues, "agents.list.0.model") != nil {
+		t.Error("string model should be valid")
+	}
+	if findIssue(issues, "agents.list.1.model.fallback") == nil {
+		t.Error("expected unknown key inside model object")
+	}
+	if findIssue(issues, "agents.list.2.model") == nil {
+		t.Error("expected type error for numeric model")
+	}
+}
+
+func TestConfigValidate_Semantic(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Channels.Telegram.Enabled = true
+	cfg.Channels.Slack.Enabled = true
+	cfg.Channels.Slack.BotToken = "xoxb"
+	cfg.Agents.Defaults.Model = "missing-model"
+	cfg.Agents.Defaults.ModelFallbacks = []string{"also-missing", "openai/gpt-4o"}
+	cfg.Agents.Defaults.FallbackStrategy = "fastest"
+	cfg.Agents.List = []AgentConfig{{ID: "main"}, {ID: "Helper"}}
+	cfg.Bindings = []AgentBinding{
+		{AgentID: "helper", Match: BindingMatch{Channel: "telegram"}},
+		{AgentID: "ghost", Match: BindingMatch{Channel: "telegram"}},
+	}
+
+	issues := cfg.Validate()
+
+	for _, path := range []string{
+		"channels.telegram.token",
+		"channels.slack.app_token",
+		"agents.defaults.model",
+		"agents.defaults.fallback_strategy",
+		"bindings.1.agent_id",
+	} {
+		if issue := findIssue(issues, path); issue == nil || issue.Severity != SeverityError {
+			t.Errorf("expected error at %s, got %+v", path, issue)
+		}
+	}
+	if issue := findIssue(issues, "agents.defaults.model_fallbacks.0"); issue == nil ||
+		issue.Severity != SeverityWarning {
+		t.Errorf("expected warning for unknown fallback, got %+v", issue)
+	}
+	for _, path := range []string{"channels.slack.bot_token", "bindings.0.agent_id", "agents.defaults.model_fallbacks.1"} {
+		if issue := findIssue(issues, path); issue != nil {
+			t.Errorf("unexpected issue: %v", issue)
+		}
+	}
+}
+
+func TestConfigValidate_DefaultsClean(t *testing.T) {
+	if issues := DefaultConfig().Validate(); len(issues) != 0 {
+		t.Errorf("expected default config to validate cleanly, got %v", issues)
+	}
+}
+
+func TestSchema_CoversConfig(t *testing.T) {
+	data, err := json.Marshal(Schema())
+	if err != nil {
+		t.Fatalf("marshal schema: %v", err)
+	}
+	var s struct {
+		AdditionalProperties bool `json:"additionalProperties"`
+		Properties           map[string]struct {
+			Properties map[string]json.RawMessage `json:"properties"`
+		} `json:"properties"`
+	}
+	if err := json.Unmarshal(data, &s); err != nil {
+		t.Fatalf("unmarshal schema: %v", err)
+	}
+	if s.AdditionalProperties {
+		t.Error("expected unknown keys to be disallowed")
+	}
+	if _, ok := s.Properties["channels"].Properties["telegram"]; !ok {
+		t.Error("expected channels.telegram in schema")
+	}
+	// Embedded ProviderConfig fields are promoted like encoding/json does.
+	openai := s.Properties["providers"].Properties["openai"]
+	if !strings.Contains(string(openai), `"api_key"`) || !strings.Contains(string(openai), `"web_search"`) {
+		t.Errorf("expected promoted fields in providers.openai, got %s", openai)
+	}
+}
+
+func TestGetPath(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Channels.Telegram.Token = "abc"
+
+	v, err := cfg.GetPath("channels.telegram.token")
+	if err != nil || v != "abc" {
+		t.Errorf("GetPath token = %v, %v", v, err)
+	}
+	v, err = cfg.GetPath("model_list.0.model_name")
+	if err != nil || v != cfg.ModelList[0].ModelName {
+		t.Errorf("GetPath model_list.0.model_name = %v, %v", v, err)
+	}
+	if _, err := cfg.GetPath("channels.telegram.tokn"); err == nil || !strings.Contains(err.Error(), "did you mean") {
+		t.Errorf("expected suggestion for typo, got %v", err)
+	}
+	if _, err := cfg.GetPath("model_list.999.model"); err == nil {
+		t.Error("expected out of range error")
+	}
+}
+
+func TestSetPath(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+
+	if _, err := SetPath(path, "channels.telegram.enabled", "true"); err != nil {
+		t.Fatalf("SetPath enabled: %v", err)
+	}
+	issues, err := SetPath(path, "channels.telegram.allow_from", `["123", 456]`)
+	if err != nil {
+		t.Fatalf("SetPath allow_from: %v", err)
+	}
+	if findIssue(issues, "channels.telegram.token") == nil {
+		t.Error("expected missing token to be reported after enabling telegram")
+	}
+	if _, err := SetPath(path, "channels.telegram.token", "123:abc"); err != nil {
+		t.Fatalf("SetPath token: %v", err)
+	}
+	if _, err := SetPath(path, "gateway.port", "not-a-number"); err == nil {
+		t.Error("expected error for non-JSON integer")
+	}
+	if _, err := SetPath(path, "gateway.port", `"18790"`); err == nil {
+		t.Error("expected type error for string port")
+	}
+	if _, err := SetPath(path, "channels.telegram.tokn", "x"); err == nil {
+		t.Error("expected error for unknown key")
+	}
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if !cfg.Channels.Telegram.Enabled || cfg.Channels.Telegram.Token != "123:abc" {
+		t.Errorf("telegram not updated: %+v", cfg.Channels.Telegram)
+	}
+	if len(cfg.Channels.Telegram.AllowFrom) != 2 || cfg.Channels.Telegram.AllowFrom[1] != "456" {
+		t.Errorf("allow_from = %v", cfg.Channels.Telegram.AllowFrom)
+	}
+	if cfg.Gateway.Port != DefaultConfig().Gateway.Port {
+		t.Errorf("rejected set should not change port, got %d", cfg.Gateway.Port)
+	}
+}
+
+func TestSetPathKeepsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	original := `{
+  "$schema": "./config.schema.json",
+  "_comment": "my config",
+  "agents": {"defaults": {"model": "gpt4"}},
+  "gateway": {"prot": 1}
+}`
+	if err := os.WriteFile(path, []byte(original), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// The pre-existing typo under gateway does not block an unrelated set.
+	if _, err := SetPath(path, "channels.telegram.enabled", "true"); err != nil {
+		t.Fatalf("SetPath: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"$schema":  "./config.schema.json",
+		"_comment": "my config",
+		"agents":   map[string]any{"defaults": map[string]any{"model": "gpt4"}},
+		"gateway":  map[string]any{"prot": float64(1)},
+		"channels": map[string]any{"telegram": map[string]any{"enabled": true}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("file after set = %s", data)
+	}
+}
+
+func TestSetPathKeepsKeyOrder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	original := `{
+  "gateway": {"port": 18790, "host": "0.0.0.0"},
+  "_comment": "keep me here",
+  "channels": {
+    "telegram": {
+      "token": "old",
+      "enabled": false
+    },
+    "discord": {}
+  },
+  "agents": {"defaults": {"model": "gpt4"}}
+}
+`
+	if err := os.WriteFile(path, []byte(original), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct{ path, value string }{
+		{"channels.telegram.enabled", "true"},
+		{"channels.telegram.allow_from", `["123"]`},
+		{"channels.discord.enabled", "true"},
+		{"gateway.port", "8080"},
+		{"agents.defaults.max_tokens", "4096"},
+	}
+	for _, step := range steps {
+		if _, err := SetPath(path, step.path, step.value); err != nil {
+			t.Fatalf("SetPath %s: %v", step.path, err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{
+  "gateway": {"port": 8080, "host": "0.0.0.0"},
+  "_comment": "keep me here",
+  "channels": {
+    "telegram": {
+      "token": "old",
+      "enabled": true,
+      "allow_from": [
+        "123"
+      ]
+    },
+    "discord": {"enabled": true}
+  },
+  "agents": {"defaults": {"model": "gpt4", "max_tokens": 4096}}
+}
+`
+	if string(data) != want {
+		t.Errorf("file after set =\n%s\nwant\n%s", data, want)
+	}
+}
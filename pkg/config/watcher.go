@@ -0,0 +1,102 @@
+package config
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultWatchInterval is how often the Watcher polls the config file.
+const DefaultWatchInterval = 2 * time.Second
+
+// Watcher polls a config file and calls onChange when its modification time
+// or size changes. Polling keeps it dependency-free and works on filesystems
+// without inotify support.
+type Watcher struct {
+	path     string
+	interval time.Duration
+	onChange func()
+
+	mu       sync.Mutex
+	modTime  time.Time
+	size     int64
+	stopChan chan struct{}
+}
+
+// NewWatcher creates a watcher for path. A non-positive interval uses
+// DefaultWatchInterval.
+func NewWatcher(path string, interval time.Duration, onChange func()) *Watcher {
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+	return &Watcher{
+		path:     path,
+		interval: interval,
+		onChange: onChange,
+	}
+}
+
+// Start records the current file state and begins polling.
+func (w *Watcher) Start() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.stopChan != nil {
+		return
+	}
+
+	w.modTime, w.size = w.stat()
+	w.stopChan = make(chan struct{})
+	go w.run(w.stopChan)
+}
+
+// Stop stops polling.
+func (w *Watcher) Stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.stopChan == nil {
+		return
+	}
+	close(w.stopChan)
+	w.stopChan = nil
+}
+
+func (w *Watcher) run(stopChan chan struct{}) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stopChan:
+			return
+		case <-ticker.C:
+			if w.changed() {
+				w.onChange()
+			}
+		}
+	}
+}
+
+// changed reports whether the file differs from the last observed state.
+func (w *Watcher) changed() bool {
+	modTime, size := w.stat()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if modTime.Equal(w.modTime) && size == w.size {
+		return false
+	}
+	w.modTime, w.size = modTime, size
+	// A missing file (e.g. mid-rename by an editor) is not a reload trigger.
+	return !modTime.IsZero()
+}
+
+func (w *Watcher) stat() (time.Time, int64) {
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return time.Time{}, 0
+	}
+	return info.ModTime(), info.Size()
+}
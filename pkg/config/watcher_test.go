@@ -0,0 +1,49 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatcher_DetectsChanges(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	changes := make(chan struct{}, 4)
+	w := NewWatcher(path, 10*time.Millisecond, func() { changes <- struct{}{} })
+	w.Start()
+	defer w.Stop()
+
+	select {
+	case <-changes:
+		t.Fatal("unexpected change before file was modified")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if err := os.WriteFile(path, []byte(`{"gateway":{}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-changes:
+	case <-time.After(time.Second):
+		t.Fatal("expected change notification")
+	}
+}
+
+func TestWatcher_IgnoresMissingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	os.WriteFile(path, []byte(`{}`), 0o600)
+
+	w := NewWatcher(path, time.Hour, func() {})
+	w.modTime, w.size = w.stat()
+
+	os.Remove(path)
+	if w.changed() {
+		t.Error("expected removed file not to trigger a reload")
+	}
+}
@@ -29,6 +29,12 @@ type CronPayload struct {
 	Deliver bool   `json:"deliver"`
 	Channel string `json:"channel,omitempty"`
 	To      string `json:"to,omitempty"`
+	// ResponseSchema, if set, makes the agent answer with JSON matching this
+	// JSON Schema; the JSON is delivered as the job's output.
+	ResponseSchema map[string]any `json:"responseSchema,omitempty"`
+	// NoCache makes the agent always ask the model instead of answering
+	// from the response cache.
+	NoCache bool `json:"noCache,omitempty"`
 }
 
 type CronJobState struct {
@@ -169,11 +175,14 @@ func (cs *CronService) checkJobs() {
 
 	// Execute jobs outside lock.
 	for _, jobID := range dueJobIDs {
-		cs.executeJobByID(jobID)
+		cs.executeJobByID(jobID, true)
 	}
 }
 
-func (cs *CronService) executeJobByID(jobID string) {
+// executeJobByID runs a job's payload and records the outcome. A scheduled
+// run also advances the schedule, disabling or removing one-shot jobs;
+// a manual run (reschedule false) leaves the schedule as it was.
+func (cs *CronService) executeJobByID(jobID string, reschedule bool) {
 	startTime := time.Now().UnixMilli()
 
 	cs.mu.RLock()
@@ -224,15 +233,17 @@ func (cs *CronService) executeJobByID(jobID string) {
 		job.State.LastError = ""
 	}
 
-	// Compute next run time
-	if job.Schedule.Kind == "at" {
+	// Compute next run time; a manual run keeps the job's upcoming run.
+	switch {
+	case !reschedule:
+	case job.Schedule.Kind == "at":
 		if job.DeleteAfterRun {
 			cs.removeJobUnsafe(job.ID)
 		} else {
 			job.Enabled = false
 			job.State.NextRunAtMS = nil
 		}
-	} else {
+	default:
 		nextRun := cs.computeNextRun(&job.Schedule, time.Now().UnixMilli())
 		job.State.NextRunAtMS = nextRun
 	}
@@ -392,8 +403,15 @@ func (cs *CronService) UpdateJob(job *CronJob) error {
 
 	for i := range cs.store.Jobs {
 		if cs.store.Jobs[i].ID == job.ID {
+			now := time.Now().UnixMilli()
 			cs.store.Jobs[i] = *job
-			cs.store.Jobs[i].UpdatedAtMS = time.Now().UnixMilli()
+			cs.store.Jobs[i].UpdatedAtMS = now
+			// The schedule may have changed, so recompute the next run.
+			if job.Enabled {
+				cs.store.Jobs[i].State.NextRunAtMS = cs.computeNextRun(&job.Schedule, now)
+			} else {
+				cs.store.Jobs[i].State.NextRunAtMS = nil
+			}
 			return cs.saveStoreUnsafe()
 		}
 	}
@@ -453,6 +471,27 @@ func (cs *CronService) EnableJob(jobID string, enabled bool) *CronJob {
 	return nil
 }
 
+// RunJobNow executes a job immediately in the background, independent of its
+// schedule. It returns false if the job does not exist.
+func (cs *CronService) RunJobNow(jobID string) bool {
+	cs.mu.RLock()
+	found := false
+	for i := range cs.store.Jobs {
+		if cs.store.Jobs[i].ID == jobID {
+			found = true
+			break
+		}
+	}
+	cs.mu.RUnlock()
+
+	if !found {
+		return false
+	}
+
+	go cs.executeJobByID(jobID, false)
+	return true
+}
+
 func (cs *CronService) ListJobs(includeDisabled bool) []CronJob {
 	cs.mu.RLock()
 	defer cs.mu.RUnlock()
@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"testing"
+	"time"
 )
 
 func TestSaveStore_FilePermissions(t *testing.T) {
@@ -36,3 +37,44 @@ func TestSaveStore_FilePermissions(t *testing.T) {
 func int64Ptr(v int64) *int64 {
 	return &v
 }
+
+func TestRunJobNow_KeepsOneShotJob(t *testing.T) {
+	ran := make(chan struct{}, 1)
+	cs := NewCronService(filepath.Join(t.TempDir(), "jobs.json"), func(job *CronJob) (string, error) {
+		ran <- struct{}{}
+		return "ok", nil
+	})
+
+	at := time.Now().Add(time.Hour).UnixMilli()
+	job, err := cs.AddJob("reminder", CronSchedule{Kind: "at", AtMS: &at}, "hello", false, "cli", "direct")
+	if err != nil {
+		t.Fatalf("AddJob failed: %v", err)
+	}
+	if !cs.RunJobNow(job.ID) {
+		t.Fatal("RunJobNow returned false")
+	}
+	select {
+	case <-ran:
+	case <-time.After(2 * time.Second):
+		t.Fatal("job did not run")
+	}
+
+	// Wait for the run's state update.
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		jobs := cs.ListJobs(true)
+		if len(jobs) != 1 {
+			t.Fatalf("jobs = %d, want the one-shot job kept", len(jobs))
+		}
+		if jobs[0].State.LastRunAtMS != nil {
+			if !jobs[0].Enabled || jobs[0].State.NextRunAtMS == nil || *jobs[0].State.NextRunAtMS != at {
+				t.Errorf("job after manual run = %+v, want it still scheduled", jobs[0])
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("run not recorded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
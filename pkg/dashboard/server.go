@@ -0,0 +1,490 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+//
+// Copyright (c) 2026 PicoClaw contributors
+
+package dashboard
+
+import (
+	"crypto/subtle"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/agent"
+	"github.com/sipeed/picoclaw/pkg/channels"
+	"github.com/sipeed/picoclaw/pkg/cron"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// BasePath is the URL prefix the dashboard is mounted under.
+const BasePath = "/admin/"
+
+const (
+	defaultLogLimit = 200
+	maxRequestBody  = 64 * 1024
+)
+
+//go:embed static
+var staticFiles embed.FS
+
+// Options holds the gateway components the dashboard reads from.
+// Nil components are reported as unavailable.
+type Options struct {
+	Token    string
+	Channels *channels.Manager
+	Agents   *agent.AgentLoop
+	Cron     *cron.CronService
+}
+
+// Server serves the admin dashboard UI and its JSON API.
+type Server struct {
+	opts      Options
+	mux       *http.ServeMux
+	startTime time.Time
+}
+
+// NewServer creates a dashboard server. Token must be non-empty.
+func NewServer(opts Options) (*Server, error) {
+	if strings.TrimSpace(opts.Token) == "" {
+		return nil, fmt.Errorf("dashboard token is required")
+	}
+
+	s := &Server{
+		opts:      opts,
+		mux:       http.NewServeMux(),
+		startTime: time.Now(),
+	}
+
+	static, err := fs.Sub(staticFiles, "static")
+	if err != nil {
+		return nil, err
+	}
+	s.mux.Handle("GET "+BasePath, http.StripPrefix(BasePath, http.FileServerFS(static)))
+
+	s.handleAPI("GET /api/status", s.handleStatus)
+	s.handleAPI("GET /api/agents", s.handleAgents)
+	s.handleAPI("GET /api/agents/{id}/sessions", s.handleSessions)
+	s.handleAPI("GET /api/agents/{id}/history", s.handleHistory)
+	s.handleAPI("GET /api/agents/{id}/skills", s.handleSkills)
+	s.handleAPI("POST /api/agents/{id}/chat", s.handleChat)
+	s.handleAPI("GET /api/cron", s.handleCronList)
+	s.handleAPI("POST /api/cron", s.handleCronCreate)
+	s.handleAPI("PUT /api/cron/{id}", s.handleCronUpdate)
+	s.handleAPI("DELETE /api/cron/{id}", s.handleCronDelete)
+	s.handleAPI("POST /api/cron/{id}/run", s.handleCronRun)
+	s.handleAPI("GET /api/logs", s.handleLogs)
+	s.handleAPI("GET /api/usage", s.handleUsage)
+	s.handleAPI("GET /api/providers/health", s.handleProviderHealth)
+
+	return s, nil
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// handleAPI registers an authenticated API handler under BasePath.
+func (s *Server) handleAPI(pattern string, h http.HandlerFunc) {
+	method, path, _ := strings.Cut(pattern, " ")
+	s.mux.Handle(method+" "+strings.TrimSuffix(BasePath, "/")+path, s.requireToken(h))
+}
+
+// requireToken checks the bearer token on every API request.
+func (s *Server) requireToken(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.opts.Token)) != 1 {
+			writeError(w, http.StatusUnauthorized, "invalid or missing token")
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	resp := map[string]any{
+		"uptime":   time.Since(s.startTime).Round(time.Second).String(),
+		"channels": map[string]any{},
+	}
+	if s.opts.Channels != nil {
+		resp["channels"] = s.opts.Channels.GetStatus()
+	}
+	if s.opts.Cron != nil {
+		resp["cron"] = s.opts.Cron.Status()
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+type agentSummary struct {
+	ID        string   `json:"id"`
+	Name      string   `json:"name,omitempty"`
+	Model     string   `json:"model"`
+	Fallbacks []string `json:"fallbacks,omitempty"`
+	Workspace string   `json:"workspace"`
+	Tools     []string `json:"tools"`
+	Sessions  int      `json:"sessions"`
+}
+
+func (s *Server) handleAgents(w http.ResponseWriter, r *http.Request) {
+	if s.opts.Agents == nil {
+		writeJSON(w, http.StatusOK, []agentSummary{})
+		return
+	}
+
+	registry := s.opts.Agents.GetRegistry()
+	ids := registry.ListAgentIDs()
+	sort.Strings(ids)
+
+	agents := make([]agentSummary, 0, len(ids))
+	for _, id := range ids {
+		a, ok := registry.GetAgent(id)
+		if !ok {
+			continue
+		}
+		agents = append(agents, agentSummary{
+			ID:        a.ID,
+			Name:      a.Name,
+			Model:     a.Model,
+			Fallbacks: a.Fallbacks,
+			Workspace: a.Workspace,
+			Tools:     a.Tools.List(),
+			Sessions:  len(a.Sessions.ListSessions()),
+		})
+	}
+	writeJSON(w, http.StatusOK, agents)
+}
+
+func (s *Server) handleSessions(w http.ResponseWriter, r *http.Request) {
+	a, ok := s.lookupAgent(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, a.Sessions.ListSessions())
+}
+
+func (s *Server) handleHistory(w http.ResponseWriter, r *http.Request) {
+	a, ok := s.lookupAgent(w, r)
+	if !ok {
+		return
+	}
+	key := r.URL.Query().Get("key")
+	if key == "" {
+		writeError(w, http.StatusBadRequest, "key is required")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"key":      key,
+		"summary":  a.Sessions.GetSummary(key),
+		"messages": a.Sessions.GetHistory(key),
+	})
+}
+
+func (s *Server) handleSkills(w http.ResponseWriter, r *http.Request) {
+	a, ok := s.lookupAgent(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, a.ContextBuilder.ListSkills())
+}
+
+// dashboardChannel is the channel and sender of messages sent through the
+// chat API.
+const dashboardChannel = "dashboard"
+
+// chatRequest is a message sent to an agent through the API. With a
+// response_format the answer is JSON matching its schema.
+type chatRequest struct {
+	Message        string                    `json:"message"`
+	Session        string                    `json:"session,omitempty"`
+	ResponseFormat *providers.ResponseFormat `json:"response_format,omitempty"`
+}
+
+func (s *Server) handleChat(w http.ResponseWriter, r *http.Request) {
+	a, ok := s.lookupAgent(w, r)
+	if !ok {
+		return
+	}
+	var req chatRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if strings.TrimSpace(req.Message) == "" {
+		writeError(w, http.StatusBadRequest, "message is required")
+		return
+	}
+	session := req.Session
+	if session == "" {
+		session = "default"
+	}
+	sessionKey := fmt.Sprintf("agent:%s:api:%s", a.ID, session)
+
+	if req.ResponseFormat == nil {
+		resp, err := s.opts.Agents.ProcessDirectWithChannel(
+			r.Context(), req.Message, sessionKey, dashboardChannel, session, dashboardChannel)
+		if err != nil {
+			writeError(w, http.StatusBadGateway, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"session": sessionKey, "content": resp})
+		return
+	}
+
+	resp, err := s.opts.Agents.ProcessDirectStructured(
+		r.Context(), req.Message, sessionKey, dashboardChannel, session, dashboardChannel, req.ResponseFormat)
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"session": sessionKey,
+		"content": resp,
+		"output":  json.RawMessage(resp),
+	})
+}
+
+func (s *Server) lookupAgent(w http.ResponseWriter, r *http.Request) (*agent.AgentInstance, bool) {
+	if s.opts.Agents == nil {
+		writeError(w, http.StatusServiceUnavailable, "agents not available")
+		return nil, false
+	}
+	a, ok := s.opts.Agents.GetRegistry().GetAgent(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "agent not found")
+		return nil, false
+	}
+	return a, true
+}
+
+// cronJobRequest is the body accepted when creating or editing a cron job.
+type cronJobRequest struct {
+	Name     string            `json:"name"`
+	Message  string            `json:"message"`
+	Command  string            `json:"command,omitempty"`
+	Schedule cron.CronSchedule `json:"schedule"`
+	Deliver  bool              `json:"deliver"`
+	Channel  string            `json:"channel"`
+	To       string            `json:"to"`
+	Enabled  *bool             `json:"enabled,omitempty"`
+	// ResponseSchema makes the job's agent answer JSON matching this schema.
+	ResponseSchema map[string]any `json:"responseSchema,omitempty"`
+}
+
+func (req *cronJobRequest) validate() error {
+	if strings.TrimSpace(req.Message) == "" && strings.TrimSpace(req.Command) == "" {
+		return fmt.Errorf("message or command is required")
+	}
+	switch req.Schedule.Kind {
+	case "at":
+		if req.Schedule.AtMS == nil {
+			return fmt.Errorf("schedule.atMs is required for kind 'at'")
+		}
+	case "every":
+		if req.Schedule.EveryMS == nil || *req.Schedule.EveryMS <= 0 {
+			return fmt.Errorf("schedule.everyMs must be positive for kind 'every'")
+		}
+	case "cron":
+		if req.Schedule.Expr == "" {
+			return fmt.Errorf("schedule.expr is required for kind 'cron'")
+		}
+	default:
+		return fmt.Errorf("schedule.kind must be one of at, every, cron")
+	}
+	return nil
+}
+
+func (s *Server) handleCronList(w http.ResponseWriter, r *http.Request) {
+	if !s.requireCron(w) {
+		return
+	}
+	writeJSON(w, http.StatusOK, s.opts.Cron.ListJobs(true))
+}
+
+func (s *Server) handleCronCreate(w http.ResponseWriter, r *http.Request) {
+	if !s.requireCron(w) {
+		return
+	}
+	var req cronJobRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if err := req.validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	name := req.Name
+	if name == "" {
+		name = req.Message
+	}
+	job, err := s.opts.Cron.AddJob(name, req.Schedule, req.Message, req.Deliver, req.Channel, req.To)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if req.Command != "" || req.ResponseSchema != nil || (req.Enabled != nil && !*req.Enabled) {
+		job.Payload.Command = req.Command
+		job.Payload.ResponseSchema = req.ResponseSchema
+		if req.Enabled != nil {
+			job.Enabled = *req.Enabled
+		}
+		if err := s.opts.Cron.UpdateJob(job); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
+	logger.InfoCF("dashboard", "Cron job created", map[string]any{"job_id": job.ID})
+	writeJSON(w, http.StatusCreated, job)
+}
+
+func (s *Server) handleCronUpdate(w http.ResponseWriter, r *http.Request) {
+	if !s.requireCron(w) {
+		return
+	}
+	job, ok := s.findJob(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "job not found")
+		return
+	}
+	var req cronJobRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if err := req.validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if req.Name != "" {
+		job.Name = req.Name
+	}
+	job.Schedule = req.Schedule
+	job.Payload.Message = req.Message
+	job.Payload.Command = req.Command
+	job.Payload.Deliver = req.Deliver
+	job.Payload.Channel = req.Channel
+	job.Payload.To = req.To
+	job.Payload.ResponseSchema = req.ResponseSchema
+	if req.Enabled != nil {
+		job.Enabled = *req.Enabled
+	}
+	job.DeleteAfterRun = job.Schedule.Kind == "at"
+
+	if err := s.opts.Cron.UpdateJob(&job); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	logger.InfoCF("dashboard", "Cron job updated", map[string]any{"job_id": job.ID})
+	updated, _ := s.findJob(job.ID)
+	writeJSON(w, http.StatusOK, updated)
+}
+
+func (s *Server) handleCronDelete(w http.ResponseWriter, r *http.Request) {
+	if !s.requireCron(w) {
+		return
+	}
+	if !s.opts.Cron.RemoveJob(r.PathValue("id")) {
+		writeError(w, http.StatusNotFound, "job not found")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleCronRun(w http.ResponseWriter, r *http.Request) {
+	if !s.requireCron(w) {
+		return
+	}
+	id := r.PathValue("id")
+	if !s.opts.Cron.RunJobNow(id) {
+		writeError(w, http.StatusNotFound, "job not found")
+		return
+	}
+	logger.InfoCF("dashboard", "Cron job triggered manually", map[string]any{"job_id": id})
+	writeJSON(w, http.StatusAccepted, map[string]string{"status": "started"})
+}
+
+func (s *Server) requireCron(w http.ResponseWriter) bool {
+	if s.opts.Cron == nil {
+		writeError(w, http.StatusServiceUnavailable, "cron service not available")
+		return false
+	}
+	return true
+}
+
+func (s *Server) findJob(id string) (cron.CronJob, bool) {
+	for _, job := range s.opts.Cron.ListJobs(true) {
+		if job.ID == id {
+			return job, true
+		}
+	}
+	return cron.CronJob{}, false
+}
+
+func (s *Server) handleLogs(w http.ResponseWriter, r *http.Request) {
+	limit := defaultLogLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		limit = n
+	}
+
+	entries := logger.RecentEntries(limit)
+	if level := strings.ToUpper(r.URL.Query().Get("level")); level != "" {
+		filtered := entries[:0]
+		for _, e := range entries {
+			if e.Level == level {
+				filtered = append(filtered, e)
+			}
+		}
+		entries = filtered
+	}
+	writeJSON(w, http.StatusOK, entries)
+}
+
+func (s *Server) handleUsage(w http.ResponseWriter, r *http.Request) {
+	if s.opts.Agents == nil {
+		writeJSON(w, http.StatusOK, []agent.UsageStats{})
+		return
+	}
+	writeJSON(w, http.StatusOK, s.opts.Agents.GetUsage())
+}
+
+func (s *Server) handleProviderHealth(w http.ResponseWriter, r *http.Request) {
+	if s.opts.Agents == nil {
+		writeJSON(w, http.StatusOK, []providers.ProviderHealth{})
+		return
+	}
+	writeJSON(w, http.StatusOK, s.opts.Agents.GetProviderHealth())
+}
+
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBody)
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid JSON body: %v", err))
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
@@ -0,0 +1,213 @@
+package dashboard
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/agent"
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/cron"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+const testToken = "secret-token"
+
+type stubProvider struct{}
+
+func (p *stubProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	return &providers.LLMResponse{Content: "ok"}, nil
+}
+
+func (p *stubProvider) GetDefaultModel() string {
+	return "stub-model"
+}
+
+func newTestServer(t *testing.T) (*Server, *agent.AgentLoop, *cron.CronService) {
+	t.Helper()
+	tmpDir := t.TempDir()
+
+	cfg := config.DefaultConfig()
+	cfg.Agents.Defaults.Workspace = tmpDir
+	cfg.Agents.Defaults.Model = "stub-model"
+
+	loop := agent.NewAgentLoop(cfg, bus.NewMessageBus(), &stubProvider{})
+	cronService := cron.NewCronService(filepath.Join(tmpDir, "cron", "jobs.json"), nil)
+
+	srv, err := NewServer(Options{
+		Token:  testToken,
+		Agents: loop,
+		Cron:   cronService,
+	})
+	if err != nil {
+		t.Fatalf("NewServer failed: %v", err)
+	}
+	return srv, loop, cronService
+}
+
+func doRequest(t *testing.T, srv http.Handler, method, path, body string, auth bool) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if auth {
+		req.Header.Set("Authorization", "Bearer "+testToken)
+	}
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestNewServer_RequiresToken(t *testing.T) {
+	if _, err := NewServer(Options{Token: "  "}); err == nil {
+		t.Fatal("expected error for empty token")
+	}
+}
+
+func TestServer_Auth(t *testing.T) {
+	srv, _, _ := newTestServer(t)
+
+	rec := doRequest(t, srv, http.MethodGet, "/admin/api/status", "", false)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without token, got %d", rec.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/api/status", nil)
+	req.Header.Set("Authorization", "Bearer wrong")
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 with wrong token, got %d", rec.Code)
+	}
+
+	rec = doRequest(t, srv, http.MethodGet, "/admin/api/status", "", true)
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected 200 with token, got %d", rec.Code)
+	}
+}
+
+func TestServer_StaticIndex(t *testing.T) {
+	srv, _, _ := newTestServer(t)
+
+	rec := doRequest(t, srv, http.MethodGet, "/admin/", "", false)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 for index, got %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "PicoClaw Admin") {
+		t.Error("expected index.html to be served")
+	}
+}
+
+func TestServer_AgentsAndSessions(t *testing.T) {
+	srv, loop, _ := newTestServer(t)
+
+	main := loop.GetRegistry().GetDefaultAgent()
+	main.Sessions.AddMessage("telegram:1", "user", "hello")
+	main.Sessions.AddMessage("telegram:1", "assistant", "hi")
+
+	rec := doRequest(t, srv, http.MethodGet, "/admin/api/agents", "", true)
+	var agents []agentSummary
+	if err := json.Unmarshal(rec.Body.Bytes(), &agents); err != nil {
+		t.Fatalf("decode agents: %v", err)
+	}
+	if len(agents) != 1 || agents[0].ID != "main" || agents[0].Sessions != 1 {
+		t.Fatalf("unexpected agents: %+v", agents)
+	}
+
+	rec = doRequest(t, srv, http.MethodGet, "/admin/api/agents/main/history?key=telegram:1", "", true)
+	var history struct {
+		Messages []providers.Message `json:"messages"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &history); err != nil {
+		t.Fatalf("decode history: %v", err)
+	}
+	if len(history.Messages) != 2 {
+		t.Errorf("expected 2 messages, got %d", len(history.Messages))
+	}
+
+	rec = doRequest(t, srv, http.MethodGet, "/admin/api/agents/missing/sessions", "", true)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown agent, got %d", rec.Code)
+	}
+}
+
+func TestServer_CronCRUD(t *testing.T) {
+	srv, _, cronService := newTestServer(t)
+
+	rec := doRequest(t, srv, http.MethodPost, "/admin/api/cron",
+		`{"name":"ping","message":"ping","schedule":{"kind":"every","everyMs":60000}}`, true)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var job cron.CronJob
+	if err := json.Unmarshal(rec.Body.Bytes(), &job); err != nil {
+		t.Fatalf("decode job: %v", err)
+	}
+
+	rec = doRequest(t, srv, http.MethodPut, "/admin/api/cron/"+job.ID,
+		`{"name":"daily","message":"report","schedule":{"kind":"cron","expr":"0 9 * * *"},"enabled":false}`, true)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	jobs := cronService.ListJobs(true)
+	if len(jobs) != 1 || jobs[0].Name != "daily" || jobs[0].Enabled || jobs[0].Schedule.Expr != "0 9 * * *" {
+		t.Fatalf("job not updated: %+v", jobs)
+	}
+
+	rec = doRequest(t, srv, http.MethodPost, "/admin/api/cron",
+		`{"message":"bad","schedule":{"kind":"weekly"}}`, true)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for invalid schedule, got %d", rec.Code)
+	}
+
+	rec = doRequest(t, srv, http.MethodPost, "/admin/api/cron/nope/run", "", true)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown job, got %d", rec.Code)
+	}
+
+	rec = doRequest(t, srv, http.MethodDelete, "/admin/api/cron/"+job.ID, "", true)
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("expected 204, got %d", rec.Code)
+	}
+	if len(cronService.ListJobs(true)) != 0 {
+		t.Error("expected job to be removed")
+	}
+}
+
+func TestServer_Chat(t *testing.T) {
+	srv, loop, _ := newTestServer(t)
+
+	rec := doRequest(t, srv, http.MethodPost, "/admin/api/agents/main/chat", `{"message":"hi","session":"s1"}`, true)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("chat status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+	var resp map[string]any
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if resp["content"] != "ok" || resp["session"] != "agent:main:api:s1" {
+		t.Errorf("chat response = %v", resp)
+	}
+	if len(loop.GetRegistry().GetDefaultAgent().Sessions.GetHistory("agent:main:api:s1")) == 0 {
+		t.Error("chat should be recorded in the API session")
+	}
+
+	// The stub never answers with JSON, so a structured request fails.
+	rec = doRequest(t, srv, http.MethodPost, "/admin/api/agents/main/chat",
+		`{"message":"hi","response_format":{"schema":{"type":"object"}}}`, true)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("structured chat status = %d, want 422", rec.Code)
+	}
+
+	rec = doRequest(t, srv, http.MethodPost, "/admin/api/agents/main/chat", `{}`, true)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("empty message status = %d, want 400", rec.Code)
+	}
+}
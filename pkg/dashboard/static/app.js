@@ -0,0 +1,239 @@
+// PicoClaw admin dashboard. Plain JS, no build step.
+(function () {
+  "use strict";
+
+  const API = "api";
+  const views = ["status", "agents", "cron", "skills", "logs", "usage"];
+  const $ = (id) => document.getElementById(id);
+
+  function token() { return sessionStorage.getItem("picoclaw-token") || ""; }
+
+  async function api(path, options = {}) {
+    const resp = await fetch(`${API}/${path}`, {
+      ...options,
+      headers: { "Authorization": `Bearer ${token()}`, "Content-Type": "application/json" },
+    });
+    if (resp.status === 401) {
+      showLogin("Invalid token");
+      throw new Error("unauthorized");
+    }
+    if (resp.status === 204) return null;
+    const body = await resp.json();
+    if (!resp.ok) throw new Error(body.error || resp.statusText);
+    return body;
+  }
+
+  function cell(text) {
+    const td = document.createElement("td");
+    td.textContent = text == null ? "" : String(text);
+    return td;
+  }
+
+  function row(values, onClick) {
+    const tr = document.createElement("tr");
+    values.forEach((v) => tr.appendChild(v instanceof Node ? v : cell(v)));
+    if (onClick) {
+      tr.classList.add("clickable");
+      tr.addEventListener("click", onClick);
+    }
+    return tr;
+  }
+
+  function fill(id, rows) {
+    const el = $(id);
+    el.replaceChildren(...rows);
+  }
+
+  function fmtTime(v) {
+    if (!v) return "";
+    const d = typeof v === "number" ? new Date(v) : new Date(v);
+    return isNaN(d) ? "" : d.toLocaleString();
+  }
+
+  function showLogin(msg) {
+    $("login").hidden = false;
+    $("nav").hidden = true;
+    views.forEach((v) => { $(`view-${v}`).hidden = true; });
+    $("login-error").textContent = msg || "";
+  }
+
+  async function loadStatus() {
+    const s = await api("status");
+    $("uptime").textContent = s.uptime;
+    const names = Object.keys(s.channels).sort();
+    fill("channels", names.map((n) => row([n, s.channels[n].running ? "✓" : "✗"])));
+  }
+
+  async function loadAgents() {
+    const agents = await api("agents");
+    fill("agents", agents.map((a) => row(
+      [a.id, a.model, a.workspace, a.tools.length, a.sessions],
+      () => loadSessions(a.id),
+    )));
+  }
+
+  async function loadSessions(agentID) {
+    const sessions = await api(`agents/${encodeURIComponent(agentID)}/sessions`);
+    $("sessions-agent").textContent = agentID;
+    $("sessions-panel").hidden = false;
+    $("history-panel").hidden = true;
+    fill("sessions", sessions.map((s) => row(
+      [s.key, s.message_count, fmtTime(s.updated)],
+      () => loadHistory(agentID, s.key),
+    )));
+  }
+
+  async function loadHistory(agentID, key) {
+    const h = await api(`agents/${encodeURIComponent(agentID)}/history?key=${encodeURIComponent(key)}`);
+    $("history-key").textContent = key;
+    $("history-summary").textContent = h.summary ? `Summary: ${h.summary}` : "";
+    $("history-panel").hidden = false;
+    fill("history", h.messages.map((m) => {
+      const li = document.createElement("li");
+      const role = document.createElement("span");
+      role.className = "role";
+      role.textContent = m.role;
+      li.append(role, m.content || (m.tool_calls ? `[${m.tool_calls.length} tool call(s)]` : ""));
+      return li;
+    }));
+  }
+
+  function scheduleText(s) {
+    if (s.kind === "every") return `every ${Math.round(s.everyMs / 1000)}s`;
+    if (s.kind === "cron") return s.expr;
+    if (s.kind === "at") return `at ${fmtTime(s.atMs)}`;
+    return s.kind;
+  }
+
+  function actionButton(label, fn) {
+    const b = document.createElement("button");
+    b.type = "button";
+    b.textContent = label;
+    b.addEventListener("click", (e) => { e.stopPropagation(); fn(); });
+    return b;
+  }
+
+  async function loadCron() {
+    const jobs = await api("cron");
+    fill("cron", jobs.map((j) => {
+      const actions = document.createElement("td");
+      actions.append(
+        actionButton("Run now", () => api(`cron/${j.id}/run`, { method: "POST" }).then(loadCron)),
+        actionButton("Edit", () => editJob(j)),
+        actionButton("Delete", () => {
+          if (confirm(`Delete job "${j.name}"?`)) api(`cron/${j.id}`, { method: "DELETE" }).then(loadCron);
+        }),
+      );
+      return row([j.name, scheduleText(j.schedule), j.enabled ? "✓" : "✗",
+        fmtTime(j.state.nextRunAtMs), j.state.lastStatus || "", actions]);
+    }));
+  }
+
+  function editJob(j) {
+    $("cron-form-title").textContent = `Edit job ${j.id}`;
+    $("cron-id").value = j.id;
+    $("cron-name").value = j.name;
+    $("cron-message").value = j.payload.message;
+    $("cron-kind").value = j.schedule.kind;
+    $("cron-value").value = j.schedule.kind === "every" ? Math.round(j.schedule.everyMs / 1000)
+      : j.schedule.kind === "cron" ? j.schedule.expr
+      : new Date(j.schedule.atMs).toISOString().slice(0, 16);
+    $("cron-channel").value = j.payload.channel || "";
+    $("cron-to").value = j.payload.to || "";
+    $("cron-deliver").checked = j.payload.deliver;
+    $("cron-enabled").checked = j.enabled;
+  }
+
+  function resetCronForm() {
+    $("cron-form").reset();
+    $("cron-id").value = "";
+    $("cron-form-title").textContent = "New job";
+    $("cron-error").textContent = "";
+  }
+
+  async function saveCron(e) {
+    e.preventDefault();
+    const kind = $("cron-kind").value;
+    const value = $("cron-value").value.trim();
+    const schedule = { kind };
+    if (kind === "every") schedule.everyMs = Number(value) * 1000;
+    else if (kind === "cron") schedule.expr = value;
+    else schedule.atMs = new Date(value).getTime();
+
+    const body = {
+      name: $("cron-name").value.trim(),
+      message: $("cron-message").value,
+      schedule,
+      deliver: $("cron-deliver").checked,
+      channel: $("cron-channel").value.trim(),
+      to: $("cron-to").value.trim(),
+      enabled: $("cron-enabled").checked,
+    };
+    const id = $("cron-id").value;
+    try {
+      await api(id ? `cron/${id}` : "cron", { method: id ? "PUT" : "POST", body: JSON.stringify(body) });
+      resetCronForm();
+      loadCron();
+    } catch (err) {
+      $("cron-error").textContent = err.message;
+    }
+  }
+
+  async function loadSkills() {
+    const agents = await api("agents");
+    if (agents.length === 0) return fill("skills", []);
+    const skills = await api(`agents/${encodeURIComponent(agents[0].id)}/skills`);
+    fill("skills", skills.map((s) => row([s.name, s.source, s.description])));
+  }
+
+  async function loadLogs() {
+    const level = $("log-level").value;
+    const entries = await api(`logs?limit=300${level ? `&level=${level}` : ""}`);
+    $("logs").textContent = entries.map((e) => {
+      const fields = e.fields ? " " + JSON.stringify(e.fields) : "";
+      return `${e.timestamp} [${e.level}]${e.component ? " " + e.component + ":" : ""} ${e.message}${fields}`;
+    }).join("\n");
+  }
+
+  async function loadUsage() {
+    const usage = await api("usage");
+    fill("usage", usage.map((u) => row([u.agent_id, u.model, u.requests,
+      u.prompt_tokens, u.cached_tokens, u.completion_tokens, u.total_tokens])));
+    const health = await api("providers/health");
+    fill("provider-health", health.map((h) => row([h.provider, h.model, h.samples,
+      `${Math.round(h.error_rate * 100)}%`, h.avg_latency_ms ? `${h.avg_latency_ms} ms` : "",
+      h.degraded ? "degraded" : "ok", h.last_error || ""])));
+  }
+
+  const loaders = { status: loadStatus, agents: loadAgents, cron: loadCron,
+    skills: loadSkills, logs: loadLogs, usage: loadUsage };
+
+  function route() {
+    if (!token()) return showLogin();
+    const view = views.includes(location.hash.slice(1)) ? location.hash.slice(1) : "status";
+    $("login").hidden = true;
+    $("nav").hidden = false;
+    views.forEach((v) => { $(`view-${v}`).hidden = v !== view; });
+    document.querySelectorAll("nav a").forEach((a) => {
+      a.classList.toggle("active", a.getAttribute("href") === `#${view}`);
+    });
+    loaders[view]().catch((err) => console.error(err));
+  }
+
+  $("login-form").addEventListener("submit", (e) => {
+    e.preventDefault();
+    sessionStorage.setItem("picoclaw-token", $("token").value);
+    $("token").value = "";
+    route();
+  });
+  $("logout").addEventListener("click", () => {
+    sessionStorage.removeItem("picoclaw-token");
+    showLogin();
+  });
+  $("cron-form").addEventListener("submit", saveCron);
+  $("cron-reset").addEventListener("click", resetCronForm);
+  $("log-refresh").addEventListener("click", loadLogs);
+  $("log-level").addEventListener("change", loadLogs);
+  window.addEventListener("hashchange", route);
+  route();
+})();
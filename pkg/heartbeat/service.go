@@ -7,6 +7,8 @@
 package heartbeat
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -17,6 +19,7 @@ import (
 	"github.com/sipeed/picoclaw/pkg/bus"
 	"github.com/sipeed/picoclaw/pkg/constants"
 	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
 	"github.com/sipeed/picoclaw/pkg/state"
 	"github.com/sipeed/picoclaw/pkg/tools"
 )
@@ -29,7 +32,7 @@ const (
 // HeartbeatHandler is the function type for handling heartbeat.
 // It returns a ToolResult that can indicate async operations.
 // channel and chatID are derived from the last active user channel.
-type HeartbeatHandler func(prompt, channel, chatID string) *tools.ToolResult
+type HeartbeatHandler func(ctx context.Context, prompt, channel, chatID string) *tools.ToolResult
 
 // HeartbeatService manages periodic heartbeat checks
 type HeartbeatService struct {
@@ -41,6 +44,8 @@ type HeartbeatService struct {
 	enabled   bool
 	mu        sync.RWMutex
 	stopChan  chan struct{}
+	taskRuns  map[string]time.Time // last schedule evaluation per structured task, nil until loaded
+	execGuard *tools.ExecTool      // task conditions must pass its guard
 }
 
 // NewHeartbeatService creates a new heartbeat service
@@ -59,9 +64,20 @@ func NewHeartbeatService(workspace string, intervalMinutes int, enabled bool) *H
 		interval:  time.Duration(intervalMinutes) * time.Minute,
 		enabled:   enabled,
 		state:     state.NewManager(workspace),
+		execGuard: tools.NewExecTool(workspace, true),
 	}
 }
 
+// SetExecGuard sets the exec tool whose safety guard task conditions must
+// pass before they run, so they obey the same deny patterns and
+// restrict_to_workspace as the agent's exec tool. By default conditions get
+// the default deny patterns and are restricted to the workspace.
+func (hs *HeartbeatService) SetExecGuard(exec *tools.ExecTool) {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	hs.execGuard = exec
+}
+
 // SetBus sets the message bus for delivering heartbeat results.
 func (hs *HeartbeatService) SetBus(msgBus *bus.MessageBus) {
 	hs.mu.Lock()
@@ -159,14 +175,28 @@ func (hs *HeartbeatService) executeHeartbeat() {
 
 	logger.DebugC("heartbeat", "Executing heartbeat")
 
-	prompt := hs.buildPrompt()
-	if prompt == "" {
-		logger.InfoC("heartbeat", "No heartbeat prompt (HEARTBEAT.md empty or missing)")
+	if handler == nil {
+		hs.logError("Heartbeat handler not configured")
 		return
 	}
 
-	if handler == nil {
-		hs.logError("Heartbeat handler not configured")
+	content, ok := hs.readHeartbeatFile()
+	if !ok {
+		return
+	}
+
+	tasks, errs := parseTasks(content)
+	for _, err := range errs {
+		hs.logError("Invalid heartbeat task: %v", err)
+	}
+	if len(tasks) > 0 || len(errs) > 0 {
+		hs.executeTasks(handler, tasks, time.Now())
+		return
+	}
+
+	prompt := hs.formatPrompt(content)
+	if prompt == "" {
+		logger.InfoC("heartbeat", "No heartbeat prompt (HEARTBEAT.md empty or missing)")
 		return
 	}
 
@@ -177,8 +207,127 @@ func (hs *HeartbeatService) executeHeartbeat() {
 	// Debug log for channel resolution
 	hs.logInfo("Resolved channel: %s, chatID: %s (from lastChannel: %s)", channel, chatID, lastChannel)
 
-	result := handler(prompt, channel, chatID)
+	hs.handleResult(handler(context.Background(), prompt, channel, chatID), channel, chatID)
+}
+
+// executeTasks evaluates structured tasks locally and only invokes the
+// handler for tasks whose schedule and condition fire.
+func (hs *HeartbeatService) executeTasks(handler HeartbeatHandler, tasks []*Task, now time.Time) {
+	hs.mu.Lock()
+	due := hs.dueTasksLocked(tasks, now)
+	guard := hs.execGuard
+	hs.mu.Unlock()
+
+	for _, task := range tasks {
+		if !due[task] {
+			continue
+		}
+
+		fire, output, err := task.checkCondition(context.Background(), hs.workspace, guard)
+		if err != nil {
+			hs.logError("Task %s: condition failed: %v", task.Name, err)
+			continue
+		}
+		if !fire {
+			hs.logInfo("Task %s: condition not met, skipped", task.Name)
+			continue
+		}
+
+		channel, chatID := hs.resolveTaskChannel(task)
+		hs.logInfo("Task %s: due, routing to %s:%s", task.Name, channel, chatID)
+
+		ctx := context.Background()
+		if task.NoCache {
+			ctx = providers.WithNoCache(ctx)
+		}
+		result := handler(ctx, buildTaskPrompt(task, output), channel, chatID)
+		hs.handleResult(result, channel, chatID)
+	}
+}
+
+// dueTasksLocked records the schedule evaluation of each task and returns
+// the ones that are due. The last-run times are kept in the workspace, so a
+// restart neither re-fires interval tasks nor loses cron baselines; entries
+// of tasks that were removed from HEARTBEAT.md are dropped.
+func (hs *HeartbeatService) dueTasksLocked(tasks []*Task, now time.Time) map[*Task]bool {
+	if hs.taskRuns == nil {
+		hs.taskRuns = hs.loadTaskRuns()
+	}
+
+	due := make(map[*Task]bool)
+	current := make(map[string]bool, len(tasks))
+	for _, task := range tasks {
+		current[task.Name] = true
+		lastRun, seen := hs.taskRuns[task.Name]
+		due[task] = task.isDue(lastRun, now)
+		if due[task] || !seen {
+			hs.taskRuns[task.Name] = now
+		}
+	}
+	for name := range hs.taskRuns {
+		if !current[name] {
+			delete(hs.taskRuns, name)
+		}
+	}
 
+	if err := hs.saveTaskRuns(); err != nil {
+		hs.logError("Failed to save task run times: %v", err)
+	}
+	return due
+}
+
+func (hs *HeartbeatService) taskRunsPath() string {
+	return filepath.Join(hs.workspace, "state", "heartbeat_tasks.json")
+}
+
+func (hs *HeartbeatService) loadTaskRuns() map[string]time.Time {
+	runs := make(map[string]time.Time)
+	data, err := os.ReadFile(hs.taskRunsPath())
+	if err != nil {
+		if !os.IsNotExist(err) {
+			hs.logError("Failed to read task run times: %v", err)
+		}
+		return runs
+	}
+	if err := json.Unmarshal(data, &runs); err != nil {
+		hs.logError("Failed to parse task run times: %v", err)
+		return make(map[string]time.Time)
+	}
+	return runs
+}
+
+// saveTaskRuns writes the last-run times atomically via a temp file.
+func (hs *HeartbeatService) saveTaskRuns() error {
+	data, err := json.MarshalIndent(hs.taskRuns, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := hs.taskRunsPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// resolveTaskChannel returns the task's target channel, falling back to
+// the last active channel when the task doesn't specify one.
+func (hs *HeartbeatService) resolveTaskChannel(task *Task) (channel, chatID string) {
+	if task.Channel != "" {
+		return hs.parseLastChannel(task.Channel)
+	}
+	return hs.parseLastChannel(hs.state.GetLastChannel())
+}
+
+// handleResult logs the handler result and delivers any user-facing output.
+func (hs *HeartbeatService) handleResult(result *tools.ToolResult, channel, chatID string) {
 	if result == nil {
 		hs.logInfo("Heartbeat handler returned nil result")
 		return
@@ -207,9 +356,9 @@ func (hs *HeartbeatService) executeHeartbeat() {
 
 	// Send result to user
 	if result.ForUser != "" {
-		hs.sendResponse(result.ForUser)
+		hs.sendResponse(result.ForUser, channel, chatID)
 	} else if result.ForLLM != "" {
-		hs.sendResponse(result.ForLLM)
+		hs.sendResponse(result.ForLLM, channel, chatID)
 	}
 
 	hs.logInfo("Heartbeat completed: %s", result.ForLLM)
@@ -217,34 +366,47 @@ func (hs *HeartbeatService) executeHeartbeat() {
 
 // buildPrompt builds the heartbeat prompt from HEARTBEAT.md
 func (hs *HeartbeatService) buildPrompt() string {
+	content, ok := hs.readHeartbeatFile()
+	if !ok {
+		return ""
+	}
+	return hs.formatPrompt(content)
+}
+
+// readHeartbeatFile reads HEARTBEAT.md, creating the default template if it
+// doesn't exist. It returns false when there is nothing to process.
+func (hs *HeartbeatService) readHeartbeatFile() (string, bool) {
 	heartbeatPath := filepath.Join(hs.workspace, "HEARTBEAT.md")
 
 	data, err := os.ReadFile(heartbeatPath)
 	if err != nil {
 		if os.IsNotExist(err) {
 			hs.createDefaultHeartbeatTemplate()
-			return ""
+			return "", false
 		}
 		hs.logError("Error reading HEARTBEAT.md: %v", err)
-		return ""
+		return "", false
 	}
 
-	content := string(data)
+	return string(data), len(data) > 0
+}
+
+// formatPrompt wraps free-form HEARTBEAT.md content in the heartbeat prompt.
+// The prompt carries no time stamp: the agent's system prompt has the
+// current time, so repeated checks can be answered from the response cache.
+func (hs *HeartbeatService) formatPrompt(content string) string {
 	if len(content) == 0 {
 		return ""
 	}
 
-	now := time.Now().Format("2006-01-02 15:04:05")
 	return fmt.Sprintf(`# Heartbeat Check
 
-Current time: %s
-
 You are a proactive AI assistant. This is a scheduled heartbeat check.
 Review the following tasks and execute any necessary actions using available skills.
 If there is nothing that requires attention, respond ONLY with: HEARTBEAT_OK
 
 %s
-`, now, content)
+`, content)
 }
 
 // createDefaultHeartbeatTemplate creates the default HEARTBEAT.md file
@@ -270,6 +432,25 @@ This file contains tasks for the heartbeat service to check periodically.
 - After spawning a subagent, CONTINUE to process remaining tasks.
 - Only respond with HEARTBEAT_OK when ALL tasks are done AND nothing needs attention.
 
+## Structured Tasks
+
+Instead of free-form tasks you can declare fenced task blocks. They are
+evaluated locally and the agent is only called when a task is due, so
+idle heartbeats cost nothing. When any task block is present, free-form
+text in this file is ignored.
+
+    ` + "```task" + `
+    name: disk-check
+    schedule: every 1h
+    condition: test $(df --output=pcent / | tail -1 | tr -dc 0-9) -gt 90
+    channel: telegram:123456
+    prompt: Disk usage on / is above 90%. Report the largest directories.
+    ` + "```" + `
+
+- schedule: "every <duration>" or a cron expression (empty = every heartbeat)
+- condition: optional shell command; the task fires only if it exits 0
+- channel: optional "platform:chat_id"; defaults to the last active channel
+
 ---
 
 Add your heartbeat tasks below this line:
@@ -282,8 +463,8 @@ Add your heartbeat tasks below this line:
 	}
 }
 
-// sendResponse sends the heartbeat response to the last channel
-func (hs *HeartbeatService) sendResponse(response string) {
+// sendResponse sends the heartbeat response to the given channel
+func (hs *HeartbeatService) sendResponse(response, platform, chatID string) {
 	hs.mu.RLock()
 	msgBus := hs.bus
 	hs.mu.RUnlock()
@@ -293,23 +474,15 @@ func (hs *HeartbeatService) sendResponse(response string) {
 		return
 	}
 
-	// Get last channel from state
-	lastChannel := hs.state.GetLastChannel()
-	if lastChannel == "" {
-		hs.logInfo("No last channel recorded, heartbeat result not sent")
-		return
-	}
-
-	platform, userID := hs.parseLastChannel(lastChannel)
-
 	// Skip internal channels that can't receive messages
-	if platform == "" || userID == "" {
+	if platform == "" || chatID == "" {
+		hs.logInfo("No target channel resolved, heartbeat result not sent")
 		return
 	}
 
 	msgBus.PublishOutbound(bus.OutboundMessage{
 		Channel: platform,
-		ChatID:  userID,
+		ChatID:  chatID,
 		Content: response,
 	})
 
@@ -0,0 +1,254 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+//
+// Copyright (c) 2026 PicoClaw contributors
+
+package heartbeat
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/adhocore/gronx"
+
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+const (
+	// taskFence is the info string that marks a structured task block in HEARTBEAT.md.
+	taskFence = "task"
+
+	conditionTimeout   = 30 * time.Second
+	maxConditionOutput = 4000
+)
+
+// Task is a structured heartbeat task declared in HEARTBEAT.md as a fenced
+// block:
+//
+//	```task
+//	name: disk-check
+//	schedule: every 1h
+//	condition: test $(df --output=pcent / | tail -1 | tr -dc 0-9) -gt 90
+//	channel: telegram:123456
+//	prompt: Disk usage on / is above 90%. Find the largest directories.
+//	```
+//
+// Schedule is either "every <duration>" or a cron expression; an empty
+// schedule fires on every heartbeat. Condition is a shell command that must
+// exit 0 for the task to fire; its output is appended to the prompt.
+// Channel is "platform:chat_id"; when empty the last active channel is used.
+type Task struct {
+	Name      string
+	Schedule  string
+	Condition string
+	Channel   string
+	Prompt    string
+	NoCache   bool // always ask the model instead of the response cache
+
+	every time.Duration
+}
+
+// parseTasks extracts structured task blocks from HEARTBEAT.md content.
+// Blocks that fail to parse, or reuse the name of an earlier task, are
+// reported in errs and skipped.
+func parseTasks(content string) (tasks []*Task, errs []error) {
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	var block []string
+	inBlock := false
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		// Fences must start at column 0 so indented examples aren't parsed.
+		trimmed := strings.TrimRightFunc(line, unicode.IsSpace)
+
+		if !inBlock {
+			if strings.HasPrefix(trimmed, "```") && strings.TrimSpace(trimmed[3:]) == taskFence {
+				inBlock = true
+				block = block[:0]
+			}
+			continue
+		}
+
+		if trimmed == "```" {
+			inBlock = false
+			task, err := parseTaskBlock(block)
+			if err == nil && slices.ContainsFunc(tasks, func(t *Task) bool { return t.Name == task.Name }) {
+				err = fmt.Errorf("duplicate task name %q", task.Name)
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("task block %d: %w", len(tasks)+len(errs)+1, err))
+				continue
+			}
+			tasks = append(tasks, task)
+			continue
+		}
+		block = append(block, line)
+	}
+
+	if inBlock {
+		errs = append(errs, fmt.Errorf("task block %d: missing closing fence", len(tasks)+len(errs)+1))
+	}
+
+	return tasks, errs
+}
+
+// parseTaskBlock parses "key: value" lines. The prompt key consumes the rest
+// of the block so it may span multiple lines.
+func parseTaskBlock(lines []string) (*Task, error) {
+	task := &Task{}
+
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid line %q (expected key: value)", line)
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "name":
+			task.Name = value
+		case "schedule":
+			task.Schedule = value
+		case "condition":
+			task.Condition = value
+		case "channel":
+			task.Channel = value
+		case "no_cache":
+			noCache, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid no_cache %q (expected true or false)", value)
+			}
+			task.NoCache = noCache
+		case "prompt":
+			rest := append([]string{value}, lines[i+1:]...)
+			task.Prompt = strings.TrimSpace(strings.Join(rest, "\n"))
+			return task, task.validate()
+		default:
+			return nil, fmt.Errorf("unknown key %q", key)
+		}
+	}
+
+	return task, task.validate()
+}
+
+func (t *Task) validate() error {
+	if t.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if t.Prompt == "" {
+		return fmt.Errorf("task %q: prompt is required", t.Name)
+	}
+
+	schedule := strings.TrimSpace(t.Schedule)
+	switch {
+	case schedule == "":
+	case strings.HasPrefix(schedule, "every ") || strings.HasPrefix(schedule, "@every "):
+		_, raw, _ := strings.Cut(schedule, " ")
+		d, err := time.ParseDuration(strings.TrimSpace(raw))
+		if err != nil || d <= 0 {
+			return fmt.Errorf("task %q: invalid interval %q", t.Name, raw)
+		}
+		t.every = d
+	default:
+		if !gronx.IsValid(schedule) {
+			return fmt.Errorf("task %q: invalid cron expression %q", t.Name, schedule)
+		}
+	}
+
+	if t.Channel != "" {
+		platform, chatID, ok := strings.Cut(t.Channel, ":")
+		if !ok || platform == "" || chatID == "" {
+			return fmt.Errorf("task %q: channel must be platform:chat_id, got %q", t.Name, t.Channel)
+		}
+	}
+
+	return nil
+}
+
+// isDue reports whether the task's schedule fired in (lastRun, now].
+// A zero lastRun means the task has never run.
+func (t *Task) isDue(lastRun, now time.Time) bool {
+	schedule := strings.TrimSpace(t.Schedule)
+	if schedule == "" {
+		return true
+	}
+	if t.every > 0 {
+		return lastRun.IsZero() || now.Sub(lastRun) >= t.every
+	}
+	if lastRun.IsZero() {
+		// Only fire cron tasks on a tick that happens after the first sighting,
+		// so a restart doesn't replay every schedule at once.
+		return false
+	}
+	next, err := gronx.NextTickAfter(schedule, lastRun, false)
+	if err != nil {
+		return false
+	}
+	return !next.After(now)
+}
+
+// checkCondition runs the task's condition command in the workspace, if
+// guard allows it. It returns whether the task should fire and the command's
+// output.
+func (t *Task) checkCondition(ctx context.Context, workspace string, guard *tools.ExecTool) (bool, string, error) {
+	if strings.TrimSpace(t.Condition) == "" {
+		return true, "", nil
+	}
+	if reason := guard.Guard(t.Condition); reason != "" {
+		return false, "", fmt.Errorf("condition refused: %s", reason)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, conditionTimeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/c", t.Condition)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", t.Condition)
+	}
+	cmd.Dir = workspace
+
+	out, err := cmd.CombinedOutput()
+	output := strings.TrimSpace(string(out))
+	if len(output) > maxConditionOutput {
+		output = output[:maxConditionOutput] + "\n... (truncated)"
+	}
+
+	if err != nil {
+		if _, ok := err.(*exec.ExitError); ok && ctx.Err() == nil {
+			// Non-zero exit simply means the condition is not met.
+			return false, output, nil
+		}
+		return false, output, err
+	}
+	return true, output, nil
+}
+
+// buildTaskPrompt builds the prompt sent to the agent for a single due task.
+// Like formatPrompt, it leaves the current time to the system prompt.
+func buildTaskPrompt(task *Task, conditionOutput string) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# Heartbeat Task: %s\n\n", task.Name)
+	sb.WriteString("You are a proactive AI assistant. This scheduled heartbeat task is due.\n")
+	sb.WriteString("Execute it using available skills. If there is nothing that requires attention, ")
+	sb.WriteString("respond ONLY with: HEARTBEAT_OK\n\n")
+	sb.WriteString(task.Prompt)
+	sb.WriteString("\n")
+	if conditionOutput != "" {
+		fmt.Fprintf(&sb, "\n## Condition output\n\n```\n%s\n```\n", conditionOutput)
+	}
+	return sb.String()
+}
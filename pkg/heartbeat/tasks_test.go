@@ -0,0 +1,222 @@
+package heartbeat
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+const sampleTasks = "# Heartbeat\n\n" +
+	"```task\n" +
+	"name: every-beat\n" +
+	"prompt: Check things.\n" +
+	"```\n\n" +
+	"```task\n" +
+	"name: hourly\n" +
+	"schedule: every 1h\n" +
+	"channel: telegram:42\n" +
+	"no_cache: true\n" +
+	"prompt: Report status.\n" +
+	"  Keep it short.\n" +
+	"```\n\n" +
+	"```task\n" +
+	"name: morning\n" +
+	"schedule: 0 9 * * *\n" +
+	"prompt: Good morning summary.\n" +
+	"```\n"
+
+func TestParseTasks(t *testing.T) {
+	tasks, errs := parseTasks(sampleTasks)
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	if len(tasks) != 3 {
+		t.Fatalf("expected 3 tasks, got %d", len(tasks))
+	}
+
+	if tasks[1].Name != "hourly" || tasks[1].every != time.Hour {
+		t.Errorf("unexpected hourly task: %+v", tasks[1])
+	}
+	if tasks[0].NoCache || !tasks[1].NoCache {
+		t.Errorf("expected no_cache only on hourly, got %v and %v", tasks[0].NoCache, tasks[1].NoCache)
+	}
+	if tasks[1].Channel != "telegram:42" {
+		t.Errorf("expected channel telegram:42, got %q", tasks[1].Channel)
+	}
+	if tasks[1].Prompt != "Report status.\n  Keep it short." {
+		t.Errorf("expected multi-line prompt, got %q", tasks[1].Prompt)
+	}
+	if tasks[2].Schedule != "0 9 * * *" {
+		t.Errorf("expected cron schedule, got %q", tasks[2].Schedule)
+	}
+}
+
+func TestParseTasks_Invalid(t *testing.T) {
+	content := "```task\nname: bad\nschedule: every soon\nprompt: x\n```\n" +
+		"```task\nname: nochan\nchannel: telegram\nprompt: x\n```\n" +
+		"```task\nprompt: missing name\n```\n" +
+		"```task\nname: cached\nno_cache: sometimes\nprompt: x\n```\n" +
+		"```task\nname: twice\nprompt: x\n```\n" +
+		"```task\nname: twice\nprompt: y\n```\n" +
+		"    ```task\n    name: indented\n    prompt: ignored\n    ```\n"
+
+	tasks, errs := parseTasks(content)
+	if len(tasks) != 1 || tasks[0].Prompt != "x" {
+		t.Errorf("expected only the first task named twice, got %+v", tasks)
+	}
+	if len(errs) != 5 || !strings.Contains(errs[4].Error(), `duplicate task name "twice"`) {
+		t.Errorf("expected 5 errors, got %d: %v", len(errs), errs)
+	}
+}
+
+func TestTaskIsDue(t *testing.T) {
+	now := time.Date(2026, 1, 1, 9, 30, 0, 0, time.Local)
+
+	every := &Task{Name: "e", Schedule: "every 1h", Prompt: "x"}
+	if err := every.validate(); err != nil {
+		t.Fatal(err)
+	}
+	if !every.isDue(time.Time{}, now) {
+		t.Error("interval task should fire when never run")
+	}
+	if every.isDue(now.Add(-30*time.Minute), now) {
+		t.Error("interval task should not fire before interval elapsed")
+	}
+	if !every.isDue(now.Add(-time.Hour), now) {
+		t.Error("interval task should fire after interval elapsed")
+	}
+
+	cron := &Task{Name: "c", Schedule: "0 9 * * *", Prompt: "x"}
+	if err := cron.validate(); err != nil {
+		t.Fatal(err)
+	}
+	if cron.isDue(time.Time{}, now) {
+		t.Error("cron task should not fire on first sighting")
+	}
+	if !cron.isDue(now.Add(-time.Hour), now) {
+		t.Error("cron task should fire when a tick passed since last run")
+	}
+	if cron.isDue(now.Add(-10*time.Minute), now) {
+		t.Error("cron task should not fire when no tick passed")
+	}
+}
+
+func TestTaskCheckCondition(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("condition tests use POSIX shell")
+	}
+	dir := t.TempDir()
+	guard := tools.NewExecTool(dir, true)
+
+	ok, out, err := (&Task{Condition: "echo hello"}).checkCondition(t.Context(), dir, guard)
+	if err != nil || !ok || out != "hello" {
+		t.Errorf("expected condition to pass with output, got ok=%v out=%q err=%v", ok, out, err)
+	}
+
+	ok, _, err = (&Task{Condition: "exit 1"}).checkCondition(t.Context(), dir, guard)
+	if err != nil || ok {
+		t.Errorf("expected condition to be unmet without error, got ok=%v err=%v", ok, err)
+	}
+
+	for _, condition := range []string{"sudo true", "cat /etc/passwd", "test $(id -u) -eq 0"} {
+		ok, _, err = (&Task{Condition: condition}).checkCondition(t.Context(), dir, guard)
+		if err == nil || ok {
+			t.Errorf("expected %q to be refused by the exec guard, got ok=%v err=%v", condition, ok, err)
+		}
+	}
+	if _, _, err = (&Task{Condition: "cat /etc/hostname"}).checkCondition(
+		t.Context(), dir, tools.NewExecTool(dir, false),
+	); err != nil && strings.Contains(err.Error(), "refused") {
+		t.Errorf("expected paths outside the workspace to be allowed without restrict_to_workspace, got %v", err)
+	}
+}
+
+func TestExecuteHeartbeat_StructuredTasks(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	hs := NewHeartbeatService(tmpDir, 30, true)
+	hs.stopChan = make(chan struct{}) // Enable for testing
+	hs.state.SetLastChannel("discord:7")
+
+	type call struct {
+		prompt, channel, chatID string
+		noCache                 bool
+	}
+	var calls []call
+	hs.SetHandler(func(ctx context.Context, prompt, channel, chatID string) *tools.ToolResult {
+		calls = append(calls, call{prompt, channel, chatID, providers.NoCache(ctx)})
+		return tools.SilentResult("ok")
+	})
+
+	os.WriteFile(filepath.Join(tmpDir, "HEARTBEAT.md"), []byte(sampleTasks), 0o644)
+
+	hs.executeHeartbeat()
+
+	// every-beat and hourly fire; the cron task only records its baseline.
+	if len(calls) != 2 {
+		t.Fatalf("expected 2 handler calls, got %d", len(calls))
+	}
+	if calls[0].channel != "discord" || calls[0].chatID != "7" {
+		t.Errorf("expected fallback to last channel, got %s:%s", calls[0].channel, calls[0].chatID)
+	}
+	if calls[1].channel != "telegram" || calls[1].chatID != "42" {
+		t.Errorf("expected task channel, got %s:%s", calls[1].channel, calls[1].chatID)
+	}
+	if calls[0].noCache || !calls[1].noCache {
+		t.Errorf("expected only the no_cache task to bypass the response cache, got %+v", calls)
+	}
+	if !strings.Contains(calls[1].prompt, "Report status.") {
+		t.Errorf("expected task prompt, got %q", calls[1].prompt)
+	}
+
+	calls = nil
+	hs.executeHeartbeat()
+
+	// Only the unscheduled task fires again within the hour.
+	if len(calls) != 1 || !strings.Contains(calls[0].prompt, "every-beat") {
+		t.Errorf("expected only every-beat to fire, got %+v", calls)
+	}
+
+	// The run times are kept in the workspace, so a restart doesn't re-fire
+	// the hourly task either.
+	restarted := NewHeartbeatService(tmpDir, 30, true)
+	restarted.stopChan = make(chan struct{})
+	restarted.SetHandler(hs.handler)
+	calls = nil
+	restarted.executeHeartbeat()
+	if len(calls) != 1 || !strings.Contains(calls[0].prompt, "every-beat") {
+		t.Errorf("expected only every-beat to fire after a restart, got %+v", calls)
+	}
+}
+
+func TestExecuteHeartbeat_ConditionSkipsHandler(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("condition tests use POSIX shell")
+	}
+	tmpDir := t.TempDir()
+
+	hs := NewHeartbeatService(tmpDir, 30, true)
+	hs.stopChan = make(chan struct{}) // Enable for testing
+
+	called := false
+	hs.SetHandler(func(ctx context.Context, prompt, channel, chatID string) *tools.ToolResult {
+		called = true
+		return tools.SilentResult("ok")
+	})
+
+	content := "```task\nname: never\ncondition: false\nprompt: x\n```\n"
+	os.WriteFile(filepath.Join(tmpDir, "HEARTBEAT.md"), []byte(content), 0o644)
+
+	hs.executeHeartbeat()
+
+	if called {
+		t.Error("expected handler not to be called when condition fails")
+	}
+}
@@ -34,8 +34,15 @@ var (
 	logger       *Logger
 	once         sync.Once
 	mu           sync.RWMutex
+
+	// recent keeps the last maxRecentEntries log entries in memory so the
+	// gateway dashboard can show them without reading the log file.
+	recent   []LogEntry
+	recentMu sync.Mutex
 )
 
+const maxRecentEntries = 500
+
 type Logger struct {
 	file *os.File
 }
@@ -116,6 +123,8 @@ func logMessage(level LogLevel, component string, message string, fields map[str
 		}
 	}
 
+	recordRecent(entry)
+
 	if logger.file != nil {
 		jsonData, err := json.Marshal(entry)
 		if err == nil {
@@ -143,6 +152,32 @@ func logMessage(level LogLevel, component string, message string, fields map[str
 	}
 }
 
+func recordRecent(entry LogEntry) {
+	recentMu.Lock()
+	defer recentMu.Unlock()
+
+	if len(recent) >= maxRecentEntries {
+		copy(recent, recent[1:])
+		recent = recent[:len(recent)-1]
+	}
+	recent = append(recent, entry)
+}
+
+// RecentEntries returns up to limit of the most recent log entries, oldest first.
+// A limit <= 0 returns all retained entries.
+func RecentEntries(limit int) []LogEntry {
+	recentMu.Lock()
+	defer recentMu.Unlock()
+
+	start := 0
+	if limit > 0 && len(recent) > limit {
+		start = len(recent) - limit
+	}
+	entries := make([]LogEntry, len(recent)-start)
+	copy(entries, recent[start:])
+	return entries
+}
+
 func formatComponent(component string) string {
 	if component == "" {
 		return ""
@@ -3,9 +3,12 @@ package anthropicprovider
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"strings"
+	"time"
 
 	"github.com/anthropics/anthropic-sdk-go"
 	"github.com/anthropics/anthropic-sdk-go/option"
@@ -21,6 +24,8 @@ type (
 	Message                = protocoltypes.Message
 	ToolDefinition         = protocoltypes.ToolDefinition
 	ToolFunctionDefinition = protocoltypes.ToolFunctionDefinition
+	ThinkingBlock          = protocoltypes.ThinkingBlock
+	ResponseFormat         = protocoltypes.ResponseFormat
 )
 
 const defaultBaseURL = "https://api.anthropic.com"
@@ -29,6 +34,10 @@ type Provider struct {
 	client      *anthropic.Client
 	tokenSource func() (string, error)
 	baseURL     string
+	noCache     bool
+	// thinkingBudget enables extended thinking for every request unless the
+	// caller passes its own "thinking_budget" option.
+	thinkingBudget int
 }
 
 func NewProvider(token string) *Provider {
@@ -80,17 +89,95 @@ func (p *Provider) Chat(
 		opts = append(opts, option.WithAuthToken(tok))
 	}
 
+	if _, ok := options["thinking_budget"]; !ok && p.thinkingBudget > 0 {
+		merged := make(map[string]any, len(options)+1)
+		for k, v := range options {
+			merged[k] = v
+		}
+		merged["thinking_budget"] = p.thinkingBudget
+		options = merged
+	}
+
 	params, err := buildParams(messages, tools, model, options)
 	if err != nil {
 		return nil, err
 	}
+	if !p.noCache {
+		applyCacheControl(&params)
+	}
 
 	resp, err := p.client.Messages.New(ctx, params, opts...)
 	if err != nil {
+		var apiErr *anthropic.Error
+		if errors.As(err, &apiErr) && apiErr.Response != nil {
+			err = &protocoltypes.APIError{
+				StatusCode: apiErr.StatusCode,
+				Body:       apiErr.RawJSON(),
+				RetryAfter: retryAfter(apiErr.Response.Header, time.Now()),
+			}
+		}
 		return nil, fmt.Errorf("claude API call: %w", err)
 	}
 
-	return parseResponse(resp), nil
+	out := parseResponse(resp)
+	if rf, ok := options["response_format"].(*ResponseFormat); ok && rf != nil {
+		takeStructuredOutput(out, rf.OutputName())
+	}
+	return out, nil
+}
+
+// rateLimitKinds are the limits Anthropic reports in
+// anthropic-ratelimit-<kind>-remaining and -reset headers.
+var rateLimitKinds = []string{"requests", "tokens", "input-tokens", "output-tokens"}
+
+// retryAfter reads the delay from a Retry-After header or, failing that,
+// from the reset time of whichever exhausted rate limit resets last.
+func retryAfter(h http.Header, now time.Time) time.Duration {
+	if d := protocoltypes.ParseRetryAfter(h, now); d > 0 {
+		return d
+	}
+	var wait time.Duration
+	for _, kind := range rateLimitKinds {
+		if h.Get("anthropic-ratelimit-"+kind+"-remaining") != "0" {
+			continue
+		}
+		reset, err := time.Parse(time.RFC3339, h.Get("anthropic-ratelimit-"+kind+"-reset"))
+		if err == nil && reset.Sub(now) > wait {
+			wait = reset.Sub(now)
+		}
+	}
+	return wait
+}
+
+// SetPromptCache enables or disables prompt cache breakpoints. Caching is on
+// by default.
+func (p *Provider) SetPromptCache(enabled bool) {
+	p.noCache = !enabled
+}
+
+// SetReasoning enables extended thinking with the given token budget. If
+// budget is 0, effort ("low", "medium" or "high") picks one.
+func (p *Provider) SetReasoning(budget int, effort string) {
+	if budget <= 0 {
+		budget = effortBudget(effort)
+	}
+	p.thinkingBudget = budget
+}
+
+// minThinkingBudget is the smallest budget the API accepts.
+const minThinkingBudget = 1024
+
+func effortBudget(effort string) int {
+	switch strings.ToLower(strings.TrimSpace(effort)) {
+	case "low", "minimal":
+		return minThinkingBudget
+	case "medium":
+		return 4096
+	case "high":
+		return 16384
+	default:
+		return 0
+	}
 }
 
 func (p *Provider) GetDefaultModel() string {
@@ -125,8 +212,10 @@ func buildParams(
 				)
 			}
 		case "assistant":
-			if len(msg.ToolCalls) > 0 {
-				var blocks []anthropic.ContentBlockParamUnion
+			if len(msg.ToolCalls) > 0 || len(msg.ThinkingBlocks) > 0 {
+				// Thinking blocks must come first and be passed back
+				// unchanged for the signature to verify.
+				blocks := thinkingParams(msg.ThinkingBlocks)
 				if msg.Content != "" {
 					blocks = append(blocks, anthropic.NewTextBlock(msg.Content))
 				}
@@ -161,7 +250,16 @@ func buildParams(
 		params.System = system
 	}
 
-	if temp, ok := options["temperature"].(float64); ok {
+	budget, _ := options["thinking_budget"].(int)
+	if budget > 0 {
+		budget = max(budget, minThinkingBudget)
+		// max_tokens includes the thinking budget, and thinking requires the
+		// default temperature.
+		if params.MaxTokens <= int64(budget) {
+			params.MaxTokens = int64(budget) + maxTokens
+		}
+		params.Thinking = anthropic.ThinkingConfigParamOfEnabled(int64(budget))
+	} else if temp, ok := options["temperature"].(float64); ok {
 		params.Temperature = anthropic.Float(temp)
 	}
 
@@ -169,9 +267,97 @@ func buildParams(
 		params.Tools = translateTools(tools)
 	}
 
+	// Anthropic has no response_format; the schema becomes an output tool the
+	// model is made to call. Forcing a tool is not allowed with extended
+	// thinking, so then the tool is only offered.
+	if rf, ok := options["response_format"].(*ResponseFormat); ok && rf != nil {
+		params.Tools = append(params.Tools, outputTool(rf)...)
+		if budget <= 0 {
+			if len(tools) == 0 {
+				params.ToolChoice = anthropic.ToolChoiceParamOfTool(rf.OutputName())
+			} else {
+				params.ToolChoice = anthropic.ToolChoiceUnionParam{OfAny: &anthropic.ToolChoiceAnyParam{}}
+			}
+		}
+	}
+
 	return params, nil
 }
 
+func outputTool(rf *ResponseFormat) []anthropic.ToolUnionParam {
+	schema := rf.Schema
+	if schema == nil {
+		schema = map[string]any{"type": "object"}
+	}
+	return translateTools([]ToolDefinition{{
+		Type: "function",
+		Function: ToolFunctionDefinition{
+			Name:        rf.OutputName(),
+			Description: "Give your final answer by calling this tool with it as the input.",
+			Parameters:  schema,
+		},
+	}})
+}
+
+// takeStructuredOutput turns a call to the output tool into the response
+// content, so it reads as a final answer. If the model also called other
+// tools, the output call is dropped and the loop continues.
+func takeStructuredOutput(resp *LLMResponse, name string) {
+	for i, tc := range resp.ToolCalls {
+		if tc.Name != name {
+			continue
+		}
+		data, err := json.Marshal(tc.Arguments)
+		if err != nil {
+			return
+		}
+		resp.ToolCalls = append(resp.ToolCalls[:i:i], resp.ToolCalls[i+1:]...)
+		if len(resp.ToolCalls) == 0 {
+			resp.Content = string(data)
+			resp.FinishReason = "stop"
+		}
+		return
+	}
+}
+
+func thinkingParams(blocks []ThinkingBlock) []anthropic.ContentBlockParamUnion {
+	var params []anthropic.ContentBlockParamUnion
+	for _, b := range blocks {
+		switch b.Type {
+		case "thinking":
+			params = append(params, anthropic.NewThinkingBlock(b.Signature, b.Thinking))
+		case "redacted_thinking":
+			params = append(params, anthropic.NewRedactedThinkingBlock(b.Data))
+		}
+	}
+	return params
+}
+
+// applyCacheControl marks prompt cache breakpoints. Anthropic caches the
+// request prefix up to each breakpoint in the order tools, system, messages,
+// so marking the last tool, the system prompt and the newest message lets
+// every later iteration and turn reuse everything sent before it. Only the
+// first system block is marked: later ones carry per-request details such
+// as the current time, which would change the cached prefix.
+func applyCacheControl(params *anthropic.MessageNewParams) {
+	if n := len(params.Tools); n > 0 {
+		if cc := params.Tools[n-1].GetCacheControl(); cc != nil {
+			*cc = anthropic.NewCacheControlEphemeralParam()
+		}
+	}
+	if len(params.System) > 0 {
+		params.System[0].CacheControl = anthropic.NewCacheControlEphemeralParam()
+	}
+	if n := len(params.Messages); n > 0 {
+		content := params.Messages[n-1].Content
+		if m := len(content); m > 0 {
+			if cc := content[m-1].GetCacheControl(); cc != nil {
+				*cc = anthropic.NewCacheControlEphemeralParam()
+			}
+		}
+	}
+}
+
 func translateTools(tools []ToolDefinition) []anthropic.ToolUnionParam {
 	result := make([]anthropic.ToolUnionParam, 0, len(tools))
 	for _, t := range tools {
@@ -199,14 +385,21 @@ func translateTools(tools []ToolDefinition) []anthropic.ToolUnionParam {
 }
 
 func parseResponse(resp *anthropic.Message) *LLMResponse {
-	var content string
+	var content, reasoning string
 	var toolCalls []ToolCall
+	var thinking []ThinkingBlock
 
 	for _, block := range resp.Content {
 		switch block.Type {
 		case "text":
 			tb := block.AsText()
 			content += tb.Text
+		case "thinking":
+			tb := block.AsThinking()
+			reasoning += tb.Thinking
+			thinking = append(thinking, ThinkingBlock{Type: "thinking", Thinking: tb.Thinking, Signature: tb.Signature})
+		case "redacted_thinking":
+			thinking = append(thinking, ThinkingBlock{Type: "redacted_thinking", Data: block.AsRedactedThinking().Data})
 		case "tool_use":
 			tu := block.AsToolUse()
 			var args map[string]any
@@ -233,14 +426,25 @@ func parseResponse(resp *anthropic.Message) *LLMResponse {
 	}
 
 	return &LLMResponse{
-		Content:      content,
-		ToolCalls:    toolCalls,
-		FinishReason: finishReason,
-		Usage: &UsageInfo{
-			PromptTokens:     int(resp.Usage.InputTokens),
-			CompletionTokens: int(resp.Usage.OutputTokens),
-			TotalTokens:      int(resp.Usage.InputTokens + resp.Usage.OutputTokens),
-		},
+		Content:        content,
+		ToolCalls:      toolCalls,
+		FinishReason:   finishReason,
+		Usage:          parseUsage(resp.Usage),
+		Reasoning:      reasoning,
+		ThinkingBlocks: thinking,
+	}
+}
+
+// parseUsage reports prompt tokens including cache reads and writes, which
+// Anthropic counts separately from input_tokens.
+func parseUsage(u anthropic.Usage) *UsageInfo {
+	prompt := u.InputTokens + u.CacheReadInputTokens + u.CacheCreationInputTokens
+	return &UsageInfo{
+		PromptTokens:        int(prompt),
+		CompletionTokens:    int(u.OutputTokens),
+		TotalTokens:         int(prompt + u.OutputTokens),
+		CachedTokens:        int(u.CacheReadInputTokens),
+		CacheCreationTokens: int(u.CacheCreationInputTokens),
 	}
 }
 
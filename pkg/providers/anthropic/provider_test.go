@@ -2,13 +2,17 @@ package anthropicprovider
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/anthropics/anthropic-sdk-go"
 	anthropicoption "github.com/anthropics/anthropic-sdk-go/option"
+
+	"github.com/sipeed/picoclaw/pkg/providers/protocoltypes"
 )
 
 func TestBuildParams_BasicMessage(t *testing.T) {
@@ -193,6 +197,51 @@ func TestProvider_ChatRoundTrip(t *testing.T) {
 	}
 }
 
+func TestProvider_ChatRateLimitError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Retry-After", "7")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"type":"error","error":{"type":"rate_limit_error","message":"slow down"}}`))
+	}))
+	defer server.Close()
+
+	c := anthropic.NewClient(
+		anthropicoption.WithAuthToken("test-token"),
+		anthropicoption.WithBaseURL(server.URL),
+		anthropicoption.WithMaxRetries(0),
+	)
+	provider := NewProviderWithClient(&c)
+	_, err := provider.Chat(t.Context(), []Message{{Role: "user", Content: "Hello"}}, nil, "claude-sonnet-4.6", nil)
+
+	var apiErr *protocoltypes.APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected an APIError, got %v", err)
+	}
+	if apiErr.StatusCode != http.StatusTooManyRequests || apiErr.RetryAfter != 7*time.Second {
+		t.Errorf("got status %d, retry after %s; want 429 and 7s", apiErr.StatusCode, apiErr.RetryAfter)
+	}
+}
+
+func TestRetryAfter_RateLimitReset(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	h := http.Header{}
+	h.Set("anthropic-ratelimit-requests-remaining", "12")
+	h.Set("anthropic-ratelimit-requests-reset", now.Add(time.Minute).Format(time.RFC3339))
+	h.Set("anthropic-ratelimit-input-tokens-remaining", "0")
+	h.Set("anthropic-ratelimit-input-tokens-reset", now.Add(20*time.Second).Format(time.RFC3339))
+	h.Set("anthropic-ratelimit-output-tokens-remaining", "0")
+	h.Set("anthropic-ratelimit-output-tokens-reset", now.Add(5*time.Second).Format(time.RFC3339))
+
+	if got := retryAfter(h, now); got != 20*time.Second {
+		t.Errorf("retryAfter = %s, want the latest reset of an exhausted limit (20s)", got)
+	}
+	h.Set("Retry-After", "3")
+	if got := retryAfter(h, now); got != 3*time.Second {
+		t.Errorf("retryAfter = %s, want Retry-After to take precedence (3s)", got)
+	}
+}
+
 func TestProvider_GetDefaultModel(t *testing.T) {
 	p := NewProvider("test-token")
 	if got := p.GetDefaultModel(); got != "claude-sonnet-4.6" {
@@ -269,3 +318,235 @@ func createAnthropicTestClient(baseURL, token string) *anthropic.Client {
 	)
 	return &c
 }
+
+func TestApplyCacheControl_MarksPrefixBreakpoints(t *testing.T) {
+	messages := []Message{
+		{Role: "system", Content: "You are helpful."},
+		{Role: "system", Content: "## Current Time\n2026-01-02 15:04"},
+		{Role: "user", Content: "What's the weather?"},
+		{
+			Role:      "assistant",
+			ToolCalls: []ToolCall{{ID: "call_1", Name: "get_weather", Arguments: map[string]any{"city": "SF"}}},
+		},
+		{Role: "tool", Content: "Sunny", ToolCallID: "call_1"},
+	}
+	tools := []ToolDefinition{
+		{Type: "function", Function: ToolFunctionDefinition{Name: "a", Parameters: map[string]any{}}},
+		{Type: "function", Function: ToolFunctionDefinition{Name: "b", Parameters: map[string]any{}}},
+	}
+	params, err := buildParams(messages, tools, "claude-sonnet-4.6", map[string]any{})
+	if err != nil {
+		t.Fatalf("buildParams() error: %v", err)
+	}
+	applyCacheControl(&params)
+
+	data, err := json.Marshal(params)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var req struct {
+		System []map[string]any `json:"system"`
+		Tools  []map[string]any `json:"tools"`
+		Msgs   []struct {
+			Content []map[string]any `json:"content"`
+		} `json:"messages"`
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	if _, ok := req.System[0]["cache_control"]; !ok {
+		t.Error("expected cache_control on system prompt")
+	}
+	if _, ok := req.System[1]["cache_control"]; ok {
+		t.Error("the dynamic system block should not be a breakpoint")
+	}
+	if _, ok := req.Tools[0]["cache_control"]; ok {
+		t.Error("only the last tool should be a breakpoint")
+	}
+	if _, ok := req.Tools[1]["cache_control"]; !ok {
+		t.Error("expected cache_control on last tool")
+	}
+	last := req.Msgs[len(req.Msgs)-1].Content
+	if _, ok := last[len(last)-1]["cache_control"]; !ok {
+		t.Error("expected cache_control on newest message")
+	}
+	if _, ok := req.Msgs[0].Content[0]["cache_control"]; ok {
+		t.Error("earlier messages should not be breakpoints")
+	}
+}
+
+func TestProvider_ChatReportsCachedUsage(t *testing.T) {
+	var reqBody map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&reqBody)
+		resp := map[string]any{
+			"id":          "msg_test",
+			"type":        "message",
+			"role":        "assistant",
+			"model":       reqBody["model"],
+			"stop_reason": "end_turn",
+			"content":     []map[string]any{{"type": "text", "text": "ok"}},
+			"usage": map[string]any{
+				"input_tokens":                10,
+				"output_tokens":               5,
+				"cache_read_input_tokens":     2000,
+				"cache_creation_input_tokens": 300,
+			},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	provider := NewProviderWithClient(createAnthropicTestClient(server.URL, "test-token"))
+	messages := []Message{{Role: "system", Content: "sys"}, {Role: "user", Content: "Hello"}}
+	resp, err := provider.Chat(t.Context(), messages, nil, "claude-sonnet-4.6", map[string]any{})
+	if err != nil {
+		t.Fatalf("Chat() error: %v", err)
+	}
+	if resp.Usage.PromptTokens != 2310 || resp.Usage.CachedTokens != 2000 || resp.Usage.CacheCreationTokens != 300 {
+		t.Errorf("Usage = %+v", resp.Usage)
+	}
+	if _, ok := reqBody["system"].([]any)[0].(map[string]any)["cache_control"]; !ok {
+		t.Error("expected cache_control in request by default")
+	}
+
+	provider.SetPromptCache(false)
+	if _, err := provider.Chat(t.Context(), messages, nil, "claude-sonnet-4.6", map[string]any{}); err != nil {
+		t.Fatalf("Chat() error: %v", err)
+	}
+	if _, ok := reqBody["system"].([]any)[0].(map[string]any)["cache_control"]; ok {
+		t.Error("did not expect cache_control with caching disabled")
+	}
+}
+
+func TestBuildParams_ThinkingBudget(t *testing.T) {
+	messages := []Message{
+		{Role: "user", Content: "Weather?"},
+		{
+			Role: "assistant",
+			ThinkingBlocks: []ThinkingBlock{
+				{Type: "thinking", Thinking: "Need the tool.", Signature: "sig-1"},
+				{Type: "redacted_thinking", Data: "opaque"},
+			},
+			ToolCalls: []ToolCall{{ID: "call_1", Name: "get_weather", Arguments: map[string]any{}}},
+		},
+		{Role: "tool", Content: "Sunny", ToolCallID: "call_1"},
+	}
+	params, err := buildParams(messages, nil, "claude-sonnet-4.6", map[string]any{
+		"max_tokens":      2048,
+		"temperature":     0.7,
+		"thinking_budget": 4096,
+	})
+	if err != nil {
+		t.Fatalf("buildParams() error: %v", err)
+	}
+	if params.Thinking.OfEnabled == nil || params.Thinking.OfEnabled.BudgetTokens != 4096 {
+		t.Fatalf("Thinking = %+v, want enabled with 4096", params.Thinking)
+	}
+	if params.MaxTokens <= 4096 {
+		t.Errorf("MaxTokens = %d, must exceed the thinking budget", params.MaxTokens)
+	}
+	if params.Temperature.Valid() {
+		t.Error("temperature must not be sent with thinking enabled")
+	}
+
+	blocks := params.Messages[1].Content
+	if len(blocks) != 3 || blocks[0].OfThinking == nil || blocks[1].OfRedactedThinking == nil ||
+		blocks[2].OfToolUse == nil {
+		t.Fatalf("expected thinking, redacted_thinking, tool_use; got %+v", blocks)
+	}
+	if blocks[0].OfThinking.Signature != "sig-1" || blocks[1].OfRedactedThinking.Data != "opaque" {
+		t.Error("thinking blocks were not passed back unchanged")
+	}
+}
+
+func TestProvider_ChatParsesThinking(t *testing.T) {
+	var reqBody map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&reqBody)
+		resp := map[string]any{
+			"id":          "msg_test",
+			"type":        "message",
+			"role":        "assistant",
+			"model":       reqBody["model"],
+			"stop_reason": "tool_use",
+			"content": []map[string]any{
+				{"type": "thinking", "thinking": "Let me check.", "signature": "sig-abc"},
+				{"type": "redacted_thinking", "data": "enc"},
+				{"type": "tool_use", "id": "toolu_1", "name": "lookup", "input": map[string]any{}},
+			},
+			"usage": map[string]any{"input_tokens": 10, "output_tokens": 20},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	provider := NewProviderWithClient(createAnthropicTestClient(server.URL, "test-token"))
+	provider.SetReasoning(0, "medium")
+	resp, err := provider.Chat(t.Context(), []Message{{Role: "user", Content: "Hi"}}, nil,
+		"claude-sonnet-4.6", map[string]any{"max_tokens": 1024})
+	if err != nil {
+		t.Fatalf("Chat() error: %v", err)
+	}
+
+	thinking, _ := reqBody["thinking"].(map[string]any)
+	if thinking["type"] != "enabled" || thinking["budget_tokens"] != float64(4096) {
+		t.Errorf("thinking = %v, want enabled with medium budget", reqBody["thinking"])
+	}
+	if resp.Reasoning != "Let me check." {
+		t.Errorf("Reasoning = %q", resp.Reasoning)
+	}
+	if len(resp.ThinkingBlocks) != 2 || resp.ThinkingBlocks[0].Signature != "sig-abc" ||
+		resp.ThinkingBlocks[1].Data != "enc" {
+		t.Errorf("ThinkingBlocks = %+v", resp.ThinkingBlocks)
+	}
+}
+
+func TestBuildParams_ResponseFormatForcesOutputTool(t *testing.T) {
+	rf := &ResponseFormat{Name: "report", Schema: map[string]any{
+		"type":       "object",
+		"properties": map[string]any{"ok": map[string]any{"type": "boolean"}},
+		"required":   []any{"ok"},
+	}}
+	params, err := buildParams([]Message{{Role: "user", Content: "status?"}}, nil, "claude-sonnet-4.6",
+		map[string]any{"response_format": rf})
+	if err != nil {
+		t.Fatalf("buildParams() error: %v", err)
+	}
+	if len(params.Tools) != 1 || params.Tools[0].OfTool.Name != "report" {
+		t.Fatalf("Tools = %+v, want the report output tool", params.Tools)
+	}
+	if params.ToolChoice.OfTool == nil || params.ToolChoice.OfTool.Name != "report" {
+		t.Errorf("ToolChoice = %+v, want the report tool forced", params.ToolChoice)
+	}
+
+	tools := []ToolDefinition{{Type: "function", Function: ToolFunctionDefinition{Name: "lookup"}}}
+	params, _ = buildParams([]Message{{Role: "user", Content: "status?"}}, tools, "claude-sonnet-4.6",
+		map[string]any{"response_format": rf})
+	if len(params.Tools) != 2 || params.ToolChoice.OfAny == nil {
+		t.Errorf("with other tools: Tools = %d, ToolChoice = %+v, want 2 and any", len(params.Tools), params.ToolChoice)
+	}
+}
+
+func TestTakeStructuredOutput(t *testing.T) {
+	resp := &LLMResponse{
+		ToolCalls:    []ToolCall{{ID: "t1", Name: "report", Arguments: map[string]any{"ok": true}}},
+		FinishReason: "tool_calls",
+	}
+	takeStructuredOutput(resp, "report")
+	if resp.Content != `{"ok":true}` || len(resp.ToolCalls) != 0 || resp.FinishReason != "stop" {
+		t.Errorf("resp = %+v", resp)
+	}
+
+	resp = &LLMResponse{ToolCalls: []ToolCall{
+		{ID: "t1", Name: "lookup"},
+		{ID: "t2", Name: "report", Arguments: map[string]any{"ok": true}},
+	}}
+	takeStructuredOutput(resp, "report")
+	if resp.Content != "" || len(resp.ToolCalls) != 1 || resp.ToolCalls[0].Name != "lookup" {
+		t.Errorf("resp = %+v, want only the lookup call", resp)
+	}
+}
@@ -28,8 +28,9 @@ const (
 // This provider authenticates via Google OAuth and provides access to models like Claude and Gemini
 // through Google's infrastructure.
 type AntigravityProvider struct {
-	tokenSource func() (string, string, error) // Returns (accessToken, projectID, error)
-	httpClient  *http.Client
+	tokenSource    func() (string, string, error) // Returns (accessToken, projectID, error)
+	httpClient     *http.Client
+	thinkingBudget int
 }
 
 // NewAntigravityProvider creates a new Antigravity provider using stored auth credentials.
@@ -147,6 +148,22 @@ func (p *AntigravityProvider) Chat(
 	return llmResp, nil
 }
 
+// SetReasoning sets the Gemini thinking budget and asks for thought
+// summaries. If budget is 0, effort ("low", "medium" or "high") picks one.
+func (p *AntigravityProvider) SetReasoning(budget int, effort string) {
+	if budget <= 0 {
+		switch strings.ToLower(strings.TrimSpace(effort)) {
+		case "low", "minimal":
+			budget = 1024
+		case "medium":
+			budget = 8192
+		case "high":
+			budget = 24576
+		}
+	}
+	p.thinkingBudget = budget
+}
+
 // GetDefaultModel returns the default model identifier.
 func (p *AntigravityProvider) GetDefaultModel() string {
 	return antigravityDefaultModel
@@ -199,8 +216,14 @@ type antigravitySystemPrompt struct {
 }
 
 type antigravityGenConfig struct {
-	MaxOutputTokens int     `json:"maxOutputTokens,omitempty"`
-	Temperature     float64 `json:"temperature,omitempty"`
+	MaxOutputTokens int                      `json:"maxOutputTokens,omitempty"`
+	Temperature     float64                  `json:"temperature,omitempty"`
+	ThinkingConfig  *antigravityThinkingConf `json:"thinkingConfig,omitempty"`
+}
+
+type antigravityThinkingConf struct {
+	ThinkingBudget  int  `json:"thinkingBudget,omitempty"`
+	IncludeThoughts bool `json:"includeThoughts"`
 }
 
 func (p *AntigravityProvider) buildRequest(
@@ -216,9 +239,10 @@ func (p *AntigravityProvider) buildRequest(
 	for _, msg := range messages {
 		switch msg.Role {
 		case "system":
-			req.SystemPrompt = &antigravitySystemPrompt{
-				Parts: []antigravityPart{{Text: msg.Content}},
+			if req.SystemPrompt == nil {
+				req.SystemPrompt = &antigravitySystemPrompt{}
 			}
+			req.SystemPrompt.Parts = append(req.SystemPrompt.Parts, antigravityPart{Text: msg.Content})
 		case "user":
 			if msg.ToolCallID != "" {
 				toolName := resolveToolResponseName(msg.ToolCallID, toolCallNames)
@@ -321,7 +345,13 @@ func (p *AntigravityProvider) buildRequest(
 	if temp, ok := options["temperature"].(float64); ok {
 		config.Temperature = temp
 	}
-	if config.MaxOutputTokens > 0 || config.Temperature > 0 {
+	if p.thinkingBudget > 0 {
+		config.ThinkingConfig = &antigravityThinkingConf{
+			ThinkingBudget:  p.thinkingBudget,
+			IncludeThoughts: true,
+		}
+	}
+	if config.MaxOutputTokens > 0 || config.Temperature > 0 || config.ThinkingConfig != nil {
 		req.Config = config
 	}
 
@@ -389,6 +419,7 @@ type antigravityJSONResponse struct {
 		Content struct {
 			Parts []struct {
 				Text                  string                   `json:"text,omitempty"`
+				Thought               bool                     `json:"thought,omitempty"`
 				ThoughtSignature      string                   `json:"thoughtSignature,omitempty"`
 				ThoughtSignatureSnake string                   `json:"thought_signature,omitempty"`
 				FunctionCall          *antigravityFunctionCall `json:"functionCall,omitempty"`
@@ -415,11 +446,14 @@ func (p *AntigravityProvider) parseJSONResponse(body []byte) (*LLMResponse, erro
 	}
 
 	candidate := resp.Candidates[0]
-	var contentParts []string
+	var contentParts, thoughtParts []string
 	var toolCalls []ToolCall
 
 	for _, part := range candidate.Content.Parts {
-		if part.Text != "" {
+		// Thought parts are summaries of the model's reasoning, not answer text.
+		if part.Thought {
+			thoughtParts = append(thoughtParts, part.Text)
+		} else if part.Text != "" {
 			contentParts = append(contentParts, part.Text)
 		}
 		if part.FunctionCall != nil {
@@ -459,11 +493,12 @@ func (p *AntigravityProvider) parseJSONResponse(body []byte) (*LLMResponse, erro
 		ToolCalls:    toolCalls,
 		FinishReason: finishReason,
 		Usage:        usage,
+		Reasoning:    strings.Join(thoughtParts, ""),
 	}, nil
 }
 
 func (p *AntigravityProvider) parseSSEResponse(body string) (*LLMResponse, error) {
-	var contentParts []string
+	var contentParts, thoughtParts []string
 	var toolCalls []ToolCall
 	var usage *UsageInfo
 	var finishReason string
@@ -490,7 +525,9 @@ func (p *AntigravityProvider) parseSSEResponse(body string) (*LLMResponse, error
 
 		for _, candidate := range resp.Candidates {
 			for _, part := range candidate.Content.Parts {
-				if part.Text != "" {
+				if part.Thought {
+					thoughtParts = append(thoughtParts, part.Text)
+				} else if part.Text != "" {
 					contentParts = append(contentParts, part.Text)
 				}
 				if part.FunctionCall != nil {
@@ -537,6 +574,7 @@ func (p *AntigravityProvider) parseSSEResponse(body string) (*LLMResponse, error
 		ToolCalls:    toolCalls,
 		FinishReason: mappedFinish,
 		Usage:        usage,
+		Reasoning:    strings.Join(thoughtParts, ""),
 	}, nil
 }
 
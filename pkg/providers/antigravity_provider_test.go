@@ -54,3 +54,35 @@ func TestResolveToolResponseNameInfersNameFromGeneratedCallID(t *testing.T) {
 		t.Fatalf("expected inferred tool name search_docs, got %q", got)
 	}
 }
+
+func TestParseJSONResponseSeparatesThoughts(t *testing.T) {
+	p := &AntigravityProvider{}
+	body := `{"candidates":[{"content":{"role":"model","parts":[
+		{"text":"Considering the question.","thought":true},
+		{"text":"The answer is 4."}
+	]},"finishReason":"STOP"}]}`
+
+	resp, err := p.parseJSONResponse([]byte(body))
+	if err != nil {
+		t.Fatalf("parseJSONResponse() error: %v", err)
+	}
+	if resp.Content != "The answer is 4." {
+		t.Errorf("Content = %q", resp.Content)
+	}
+	if resp.Reasoning != "Considering the question." {
+		t.Errorf("Reasoning = %q", resp.Reasoning)
+	}
+}
+
+func TestBuildRequestThinkingConfig(t *testing.T) {
+	p := &AntigravityProvider{}
+	p.SetReasoning(0, "low")
+
+	req := p.buildRequest([]Message{{Role: "user", Content: "hi"}}, nil, "", nil)
+	if req.Config == nil || req.Config.ThinkingConfig == nil {
+		t.Fatal("expected thinkingConfig in request")
+	}
+	if req.Config.ThinkingConfig.ThinkingBudget != 1024 || !req.Config.ThinkingConfig.IncludeThoughts {
+		t.Errorf("ThinkingConfig = %+v", req.Config.ThinkingConfig)
+	}
+}
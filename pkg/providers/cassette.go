@@ -0,0 +1,289 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+const cassetteVersion = 1
+
+// Cassette is a recorded sequence of LLM requests and their responses.
+type Cassette struct {
+	Version      int           `json:"version"`
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is one recorded Chat call. Exactly one of Response and Error
+// is set.
+type Interaction struct {
+	// Key is ResponseCacheKey of the request, used to match it on replay.
+	Key      string          `json:"key"`
+	Request  CassetteRequest `json:"request"`
+	Response *LLMResponse    `json:"response,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// CassetteRequest is the recorded input of a Chat call, kept for reading
+// and diffing cassettes.
+type CassetteRequest struct {
+	Model    string           `json:"model"`
+	Messages []Message        `json:"messages"`
+	Tools    []ToolDefinition `json:"tools,omitempty"`
+	Options  map[string]any   `json:"options,omitempty"`
+}
+
+// LoadCassette reads a cassette file: a cassette, followed by any
+// interactions a RecordingProvider appended to it as separate JSON values.
+func LoadCassette(path string) (*Cassette, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(f)
+	var c Cassette
+	if err := dec.Decode(&c); err != nil {
+		return nil, fmt.Errorf("parsing cassette %s: %w", path, err)
+	}
+	if c.Version != cassetteVersion {
+		return nil, fmt.Errorf("cassette %s has version %d, want %d", path, c.Version, cassetteVersion)
+	}
+	for {
+		var in Interaction
+		err := dec.Decode(&in)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parsing cassette %s: interaction #%d: %w", path, len(c.Interactions)+1, err)
+		}
+		c.Interactions = append(c.Interactions, in)
+	}
+	return &c, nil
+}
+
+// Save writes the cassette to path, replacing it atomically.
+func (c *Cassette) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// RecordingProvider passes calls through to another provider and appends
+// each request and its response or error to a cassette file.
+type RecordingProvider struct {
+	LLMProvider
+	path string
+}
+
+// cassetteMu serializes cassette writes, so recording providers created for
+// the same file, for example before and after a config reload, do not drop
+// each other's interactions.
+var cassetteMu sync.Mutex
+
+// NewRecordingProvider records the calls made through provider to path. The
+// calls are appended to an existing cassette at path, so a recording spans
+// reloads and restarts; delete the file to start over.
+func NewRecordingProvider(provider LLMProvider, path string) *RecordingProvider {
+	return &RecordingProvider{
+		LLMProvider: provider,
+		path:        path,
+	}
+}
+
+func (p *RecordingProvider) Chat(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+) (*LLMResponse, error) {
+	resp, err := p.LLMProvider.Chat(ctx, messages, tools, model, options)
+
+	// Copy what the caller may reuse and change for later calls.
+	opts := make(map[string]any, len(options))
+	for k, v := range options {
+		opts[k] = v
+	}
+	in := Interaction{
+		Key: ResponseCacheKey(model, messages, tools, options),
+		Request: CassetteRequest{
+			Model:    model,
+			Messages: append([]Message(nil), messages...),
+			Tools:    tools,
+			Options:  opts,
+		},
+		Response: resp,
+	}
+	if err != nil {
+		in.Response = nil
+		in.Error = err.Error()
+	}
+
+	if saveErr := p.append(in); saveErr != nil {
+		logger.WarnCF("providers", "Failed to save cassette", map[string]any{
+			"path":  p.path,
+			"error": saveErr.Error(),
+		})
+	}
+
+	return resp, err
+}
+
+// append writes in to the end of the cassette file, starting the file with
+// an empty cassette if it is new. Earlier recordings are never rewritten, so
+// a crash can at worst cut off the last one.
+func (p *RecordingProvider) append(in Interaction) error {
+	data, err := json.MarshalIndent(in, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	cassetteMu.Lock()
+	defer cassetteMu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(p.path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(p.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if info, err := f.Stat(); err == nil && info.Size() == 0 {
+		header, _ := json.Marshal(Cassette{Version: cassetteVersion, Interactions: []Interaction{}})
+		data = append(append(header, '\n'), data...)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ReplayProvider serves the responses of a cassette instead of calling a
+// model. A request gets the first unused interaction recorded for an
+// identical request. Failing that, it gets the next unused interaction in
+// recorded order, since prompts often differ slightly between runs (the
+// workspace path in the system prompt, for example). In strict mode such a
+// mismatch is an error instead.
+type ReplayProvider struct {
+	path   string
+	strict bool
+
+	mu       sync.Mutex
+	cassette *Cassette
+	used     []bool
+}
+
+// NewReplayProvider loads the cassette at path.
+func NewReplayProvider(path string) (*ReplayProvider, error) {
+	c, err := LoadCassette(path)
+	if err != nil {
+		return nil, err
+	}
+	return &ReplayProvider{
+		path:     path,
+		cassette: c,
+		used:     make([]bool, len(c.Interactions)),
+	}, nil
+}
+
+// SetStrict makes requests that match no recorded request fail.
+func (p *ReplayProvider) SetStrict(strict bool) {
+	p.mu.Lock()
+	p.strict = strict
+	p.mu.Unlock()
+}
+
+// Remaining returns how many recorded interactions have not been served.
+func (p *ReplayProvider) Remaining() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n := 0
+	for _, u := range p.used {
+		if !u {
+			n++
+		}
+	}
+	return n
+}
+
+func (p *ReplayProvider) Chat(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+) (*LLMResponse, error) {
+	key := ResponseCacheKey(model, messages, tools, options)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	idx := -1
+	next := -1
+	for i, in := range p.cassette.Interactions {
+		if p.used[i] {
+			continue
+		}
+		if next < 0 {
+			next = i
+		}
+		if in.Key == key {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		if next < 0 {
+			return nil, fmt.Errorf("replay %s: all %d recorded interactions used", p.path, len(p.used))
+		}
+		if p.strict {
+			return nil, fmt.Errorf("replay %s: no recorded interaction matches request for model %s (next is #%d)",
+				p.path, model, next+1)
+		}
+		idx = next
+	}
+	p.used[idx] = true
+
+	in := p.cassette.Interactions[idx]
+	if in.Error != "" {
+		return nil, errors.New(in.Error)
+	}
+	if in.Response == nil {
+		return nil, fmt.Errorf("replay %s: interaction #%d has no response", p.path, idx+1)
+	}
+	resp := *in.Response
+	return &resp, nil
+}
+
+// GetDefaultModel returns the model of the first recorded request.
+func (p *ReplayProvider) GetDefaultModel() string {
+	if len(p.cassette.Interactions) == 0 {
+		return ""
+	}
+	return p.cassette.Interactions[0].Request.Model
+}
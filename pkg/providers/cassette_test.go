@@ -0,0 +1,191 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+type scriptProvider struct {
+	replies []string
+	err     error
+	calls   int
+}
+
+func (p *scriptProvider) Chat(
+	ctx context.Context, messages []Message, tools []ToolDefinition, model string, options map[string]any,
+) (*LLMResponse, error) {
+	p.calls++
+	if p.err != nil {
+		return nil, p.err
+	}
+	reply := p.replies[0]
+	p.replies = p.replies[1:]
+	return &LLMResponse{Content: reply, FinishReason: "stop"}, nil
+}
+
+func (p *scriptProvider) GetDefaultModel() string { return "script" }
+
+func userMessage(content string) []Message {
+	return []Message{{Role: "user", Content: content}}
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassettes", "run.json")
+	live := &scriptProvider{replies: []string{"first", "second"}}
+	rec := NewRecordingProvider(live, path)
+
+	ctx := context.Background()
+	opts := map[string]any{"temperature": 0.0}
+	for _, prompt := range []string{"one", "two"} {
+		if _, err := rec.Chat(ctx, userMessage(prompt), nil, "gpt-4", opts); err != nil {
+			t.Fatalf("record %s: %v", prompt, err)
+		}
+	}
+	live.err = errors.New("API request failed:\n  Status: 503\n  Body:   overloaded")
+	if _, err := rec.Chat(ctx, userMessage("three"), nil, "gpt-4", opts); err == nil {
+		t.Fatal("expected the live error to pass through")
+	}
+
+	replay, err := NewReplayProvider(path)
+	if err != nil {
+		t.Fatalf("NewReplayProvider: %v", err)
+	}
+	if got := replay.GetDefaultModel(); got != "gpt-4" {
+		t.Errorf("default model = %q, want gpt-4", got)
+	}
+
+	// Identical requests are matched regardless of order.
+	resp, err := replay.Chat(ctx, userMessage("two"), nil, "gpt-4", opts)
+	if err != nil || resp.Content != "second" {
+		t.Fatalf("replay two = %+v, %v; want second", resp, err)
+	}
+	resp, err = replay.Chat(ctx, userMessage("one"), nil, "gpt-4", opts)
+	if err != nil || resp.Content != "first" {
+		t.Fatalf("replay one = %+v, %v; want first", resp, err)
+	}
+
+	// Recorded errors come back with the same message, so they classify the same.
+	_, err = replay.Chat(ctx, userMessage("three"), nil, "gpt-4", opts)
+	want := ClassifyError(live.err, "openai", "gpt-4")
+	if fe := ClassifyError(err, "openai", "gpt-4"); fe == nil || fe.Reason != want.Reason || fe.Status != 503 {
+		t.Errorf("replayed error classified as %+v, want %+v", fe, want)
+	}
+
+	if _, err := replay.Chat(ctx, userMessage("four"), nil, "gpt-4", opts); err == nil ||
+		!strings.Contains(err.Error(), "all 3 recorded interactions used") {
+		t.Errorf("err = %v, want cassette exhausted", err)
+	}
+	if live.calls != 3 {
+		t.Errorf("live calls = %d, want 3 (replay must not call the model)", live.calls)
+	}
+}
+
+func TestRecordingProvider_AppendsToCassette(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.json")
+	ctx := context.Background()
+	// A config reload creates a new recorder for the same file.
+	NewRecordingProvider(&scriptProvider{replies: []string{"a"}}, path).Chat(ctx, userMessage("one"), nil, "m", nil)
+	before, _ := os.ReadFile(path)
+	NewRecordingProvider(&scriptProvider{replies: []string{"b"}}, path).Chat(ctx, userMessage("two"), nil, "m", nil)
+
+	after, _ := os.ReadFile(path)
+	if !bytes.HasPrefix(after, before) {
+		t.Error("expected the second recording to be appended without rewriting the first")
+	}
+	c, err := LoadCassette(path)
+	if err != nil {
+		t.Fatalf("LoadCassette: %v", err)
+	}
+	if len(c.Interactions) != 2 || c.Interactions[1].Response.Content != "b" {
+		t.Errorf("interactions = %+v, want both recordings", c.Interactions)
+	}
+
+	// Recordings are appended to a saved cassette too.
+	saved := filepath.Join(t.TempDir(), "saved.json")
+	if err := c.Save(saved); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	NewRecordingProvider(&scriptProvider{replies: []string{"c"}}, saved).Chat(ctx, userMessage("three"), nil, "m", nil)
+	if c, err = LoadCassette(saved); err != nil || len(c.Interactions) != 3 || c.Interactions[2].Response.Content != "c" {
+		t.Errorf("LoadCassette = %+v, %v; want three recordings", c, err)
+	}
+}
+
+func TestReplay_MismatchedRequests(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.json")
+	rec := NewRecordingProvider(&scriptProvider{replies: []string{"a", "b"}}, path)
+	ctx := context.Background()
+	rec.Chat(ctx, userMessage("It is 10:00. Hello"), nil, "m", nil)
+	rec.Chat(ctx, userMessage("It is 10:00. Bye"), nil, "m", nil)
+
+	// Prompts that changed since recording are served in recorded order.
+	replay, err := NewReplayProvider(path)
+	if err != nil {
+		t.Fatalf("NewReplayProvider: %v", err)
+	}
+	resp, err := replay.Chat(ctx, userMessage("It is 11:30. Hello"), nil, "m", nil)
+	if err != nil || resp.Content != "a" {
+		t.Fatalf("replay = %+v, %v; want a", resp, err)
+	}
+	if replay.Remaining() != 1 {
+		t.Errorf("remaining = %d, want 1", replay.Remaining())
+	}
+
+	strict, _ := NewReplayProvider(path)
+	strict.SetStrict(true)
+	if _, err := strict.Chat(ctx, userMessage("It is 11:30. Hello"), nil, "m", nil); err == nil {
+		t.Error("expected strict replay to reject a changed request")
+	}
+}
+
+func TestCreateProviderFromConfig_ReplayAndRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.json")
+
+	provider, _, err := CreateProviderFromConfig(&config.ModelConfig{
+		ModelName: "recorded",
+		Model:     "openai/gpt-4o",
+		APIKey:    "test-key",
+		Record:    path,
+	})
+	if err != nil {
+		t.Fatalf("CreateProviderFromConfig() error = %v", err)
+	}
+	if _, ok := provider.(*RecordingProvider); !ok {
+		t.Fatalf("provider = %T, want *RecordingProvider", provider)
+	}
+
+	if _, _, err := CreateProviderFromConfig(&config.ModelConfig{
+		ModelName: "replayed",
+		Model:     "replay/" + path,
+	}); err == nil {
+		t.Error("expected an error for a missing cassette")
+	}
+
+	if err := (&Cassette{Version: cassetteVersion, Interactions: []Interaction{{
+		Request:  CassetteRequest{Model: "gpt-4o"},
+		Response: &LLMResponse{Content: "hi"},
+	}}}).Save(path); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	provider, modelID, err := CreateProviderFromConfig(&config.ModelConfig{
+		ModelName: "replayed",
+		Model:     "replay/" + path,
+		Strict:    true,
+	})
+	if err != nil {
+		t.Fatalf("CreateProviderFromConfig() error = %v", err)
+	}
+	if replay, ok := provider.(*ReplayProvider); !ok || !replay.strict {
+		t.Errorf("provider = %#v, want a strict *ReplayProvider", provider)
+	}
+	if modelID != "gpt-4o" {
+		t.Errorf("modelID = %q, want the recorded model", modelID)
+	}
+}
@@ -51,6 +51,16 @@ func (p *ClaudeProvider) Chat(
 	return resp, nil
 }
 
+// SetPromptCache enables or disables prompt cache breakpoints.
+func (p *ClaudeProvider) SetPromptCache(enabled bool) {
+	p.delegate.SetPromptCache(enabled)
+}
+
+// SetReasoning enables extended thinking, see anthropicprovider.Provider.SetReasoning.
+func (p *ClaudeProvider) SetReasoning(budget int, effort string) {
+	p.delegate.SetReasoning(budget, effort)
+}
+
 func (p *ClaudeProvider) GetDefaultModel() string {
 	return p.delegate.GetDefaultModel()
 }
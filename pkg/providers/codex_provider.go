@@ -208,7 +208,10 @@ func buildCodexParams(
 	for _, msg := range messages {
 		switch msg.Role {
 		case "system":
-			instructions = msg.Content
+			if instructions != "" {
+				instructions += "\n\n"
+			}
+			instructions += msg.Content
 		case "user":
 			if msg.ToolCallID != "" {
 				inputItems = append(inputItems, responses.ResponseInputItemUnionParam{
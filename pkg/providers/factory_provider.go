@@ -53,9 +53,37 @@ func ExtractProtocol(model string) (protocol, modelID string) {
 
 // CreateProviderFromConfig creates a provider based on the ModelConfig.
 // It uses the protocol prefix in the Model field to determine which provider to create.
-// Supported protocols: openai, anthropic, antigravity, claude-cli, codex-cli, github-copilot
+// Supported protocols: openai, anthropic, gemini, ollama, antigravity, claude-cli, codex-cli, github-copilot,
+// and replay for serving a recorded cassette.
 // Returns the provider, the model ID (without protocol prefix), and any error.
 func CreateProviderFromConfig(cfg *config.ModelConfig) (LLMProvider, string, error) {
+	provider, modelID, err := createProviderFromConfig(cfg)
+	if err != nil {
+		return nil, "", err
+	}
+	if cfg.PromptCache != nil {
+		if pc, ok := provider.(interface{ SetPromptCache(bool) }); ok {
+			pc.SetPromptCache(*cfg.PromptCache)
+		}
+	}
+	if cfg.ThinkingBudget > 0 || cfg.ReasoningEffort != "" {
+		if r, ok := provider.(interface{ SetReasoning(int, string) }); ok {
+			r.SetReasoning(cfg.ThinkingBudget, cfg.ReasoningEffort)
+		}
+	}
+	if cfg.Record != "" {
+		provider = NewRecordingProvider(provider, cfg.Record)
+	}
+	if cfg.RPM > 0 || cfg.TPM > 0 {
+		provider = &rateLimitedProvider{
+			LLMProvider: provider,
+			limiter:     sharedRateLimiter(cfg.Model, cfg.APIKey, cfg.RPM, cfg.TPM),
+		}
+	}
+	return provider, modelID, nil
+}
+
+func createProviderFromConfig(cfg *config.ModelConfig) (LLMProvider, string, error) {
 	if cfg == nil {
 		return nil, "", fmt.Errorf("config is nil")
 	}
@@ -86,8 +114,19 @@ func CreateProviderFromConfig(cfg *config.ModelConfig) (LLMProvider, string, err
 		}
 		return NewHTTPProviderWithMaxTokensField(cfg.APIKey, apiBase, cfg.Proxy, cfg.MaxTokensField), modelID, nil
 
-	case "openrouter", "groq", "zhipu", "gemini", "nvidia",
-		"ollama", "moonshot", "shengsuanyun", "deepseek", "cerebras",
+	case "gemini":
+		// Native API. An api_base pointing at the OpenAI-compatible endpoint
+		// (".../v1beta/openai") keeps using it.
+		if strings.Contains(cfg.APIBase, "/openai") {
+			return NewHTTPProviderWithMaxTokensField(cfg.APIKey, cfg.APIBase, cfg.Proxy, cfg.MaxTokensField), modelID, nil
+		}
+		if cfg.APIKey == "" {
+			return nil, "", fmt.Errorf("api_key is required for gemini protocol (model: %s)", cfg.Model)
+		}
+		return newGeminiProviderFromConfig(cfg), modelID, nil
+
+	case "openrouter", "groq", "zhipu", "nvidia",
+		"moonshot", "shengsuanyun", "deepseek", "cerebras",
 		"volcengine", "vllm", "qwen":
 		// All other OpenAI-compatible HTTP providers
 		if cfg.APIKey == "" && cfg.APIBase == "" {
@@ -99,6 +138,10 @@ func CreateProviderFromConfig(cfg *config.ModelConfig) (LLMProvider, string, err
 		}
 		return NewHTTPProviderWithMaxTokensField(cfg.APIKey, apiBase, cfg.Proxy, cfg.MaxTokensField), modelID, nil
 
+	case "ollama":
+		// Native API; a local server needs neither api_key nor api_base.
+		return newOllamaProviderFromConfig(cfg), modelID, nil
+
 	case "anthropic":
 		if cfg.AuthMethod == "oauth" || cfg.AuthMethod == "token" {
 			// Use OAuth credentials from auth store
@@ -135,6 +178,15 @@ func CreateProviderFromConfig(cfg *config.ModelConfig) (LLMProvider, string, err
 		}
 		return NewCodexCliProvider(workspace), modelID, nil
 
+	case "replay":
+		// The model ID is the cassette path, e.g. "replay/testdata/run.json".
+		provider, err := NewReplayProvider(modelID)
+		if err != nil {
+			return nil, "", fmt.Errorf("loading replay cassette: %w", err)
+		}
+		provider.SetStrict(cfg.Strict)
+		return provider, provider.GetDefaultModel(), nil
+
 	case "github-copilot", "copilot":
 		apiBase := cfg.APIBase
 		if apiBase == "" {
@@ -170,8 +222,6 @@ func getDefaultAPIBase(protocol string) string {
 		return "https://generativelanguage.googleapis.com/v1beta"
 	case "nvidia":
 		return "https://integrate.api.nvidia.com/v1"
-	case "ollama":
-		return "http://localhost:11434/v1"
 	case "moonshot":
 		return "https://api.moonshot.cn/v1"
 	case "shengsuanyun":
@@ -107,7 +107,6 @@ func TestCreateProviderFromConfig_DefaultAPIBase(t *testing.T) {
 		{"qwen", "qwen"},
 		{"vllm", "vllm"},
 		{"deepseek", "deepseek"},
-		{"ollama", "ollama"},
 	}
 
 	for _, tt := range tests {
@@ -131,6 +130,24 @@ func TestCreateProviderFromConfig_DefaultAPIBase(t *testing.T) {
 	}
 }
 
+func TestCreateProviderFromConfig_Ollama(t *testing.T) {
+	cfg := &config.ModelConfig{
+		ModelName: "local",
+		Model:     "ollama/qwen2.5:14b",
+	}
+
+	provider, modelID, err := CreateProviderFromConfig(cfg)
+	if err != nil {
+		t.Fatalf("CreateProviderFromConfig() error = %v", err)
+	}
+	if _, ok := provider.(*OllamaProvider); !ok {
+		t.Fatalf("expected *OllamaProvider, got %T", provider)
+	}
+	if modelID != "qwen2.5:14b" {
+		t.Errorf("modelID = %q, want %q", modelID, "qwen2.5:14b")
+	}
+}
+
 func TestCreateProviderFromConfig_Anthropic(t *testing.T) {
 	cfg := &config.ModelConfig{
 		ModelName: "test-anthropic",
@@ -247,3 +264,62 @@ func TestCreateProviderFromConfig_EmptyModel(t *testing.T) {
 		t.Fatal("CreateProviderFromConfig() expected error for empty model")
 	}
 }
+
+func TestCreateProviderFromConfig_Gemini(t *testing.T) {
+	cfg := &config.ModelConfig{
+		ModelName: "test-gemini",
+		Model:     "gemini/gemini-2.5-flash",
+		APIKey:    "test-key",
+	}
+
+	provider, modelID, err := CreateProviderFromConfig(cfg)
+	if err != nil {
+		t.Fatalf("CreateProviderFromConfig() error = %v", err)
+	}
+	if _, ok := provider.(*GeminiProvider); !ok {
+		t.Errorf("provider = %T, want *GeminiProvider", provider)
+	}
+	if modelID != "gemini-2.5-flash" {
+		t.Errorf("modelID = %q, want %q", modelID, "gemini-2.5-flash")
+	}
+
+	// The OpenAI-compatible endpoint keeps using the HTTP provider.
+	cfg.APIBase = "https://generativelanguage.googleapis.com/v1beta/openai"
+	provider, _, err = CreateProviderFromConfig(cfg)
+	if err != nil {
+		t.Fatalf("CreateProviderFromConfig() error = %v", err)
+	}
+	if _, ok := provider.(*HTTPProvider); !ok {
+		t.Errorf("provider = %T, want *HTTPProvider for an /openai api_base", provider)
+	}
+
+	cfg.APIBase, cfg.APIKey = "", ""
+	if _, _, err := CreateProviderFromConfig(cfg); err == nil {
+		t.Error("expected an error without api_key")
+	}
+}
+
+func TestCreateProviderFromConfig_RateLimited(t *testing.T) {
+	cfg := &config.ModelConfig{
+		ModelName: "test-limited",
+		Model:     "openai/gpt-4o",
+		APIKey:    "test-key",
+		RPM:       60,
+		TPM:       100000,
+	}
+
+	provider, _, err := CreateProviderFromConfig(cfg)
+	if err != nil {
+		t.Fatalf("CreateProviderFromConfig() error = %v", err)
+	}
+	limited, ok := provider.(*rateLimitedProvider)
+	if !ok {
+		t.Fatalf("provider = %T, want *rateLimitedProvider", provider)
+	}
+	if _, ok := limited.LLMProvider.(*HTTPProvider); !ok {
+		t.Errorf("wrapped provider = %T, want *HTTPProvider", limited.LLMProvider)
+	}
+	if st := limited.limiter.Status(); st.RPM != 60 || st.TPM != 100000 {
+		t.Errorf("limits = %d rpm / %d tpm, want 60 / 100000", st.RPM, st.TPM)
+	}
+}
@@ -4,12 +4,18 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 )
 
 // FallbackChain orchestrates model fallback across multiple candidates.
 type FallbackChain struct {
 	cooldown *CooldownTracker
+	health   *HealthTracker
+
+	mu       sync.Mutex
+	strategy SelectionStrategy
+	current  map[string]float64 // smooth weighted round-robin state
 }
 
 // FallbackCandidate represents one model/provider to try.
@@ -38,7 +44,30 @@ type FallbackAttempt struct {
 
 // NewFallbackChain creates a new fallback chain with the given cooldown tracker.
 func NewFallbackChain(cooldown *CooldownTracker) *FallbackChain {
-	return &FallbackChain{cooldown: cooldown}
+	return &FallbackChain{
+		cooldown: cooldown,
+		strategy: StrategyOrdered,
+		current:  make(map[string]float64),
+	}
+}
+
+// SetHealthTracker makes the chain record latency and errors of every attempt
+// in health and use them to order candidates.
+func (fc *FallbackChain) SetHealthTracker(health *HealthTracker) {
+	fc.health = health
+}
+
+// HealthTracker returns the chain's health tracker, or nil.
+func (fc *FallbackChain) HealthTracker() *HealthTracker {
+	return fc.health
+}
+
+// SetStrategy sets how candidates are ordered. Strategies other than
+// StrategyOrdered need a health tracker.
+func (fc *FallbackChain) SetStrategy(strategy SelectionStrategy) {
+	fc.mu.Lock()
+	fc.strategy = strategy
+	fc.mu.Unlock()
 }
 
 // ResolveCandidates parses model config into a deduplicated candidate list.
@@ -77,6 +106,8 @@ func ResolveCandidates(cfg ModelConfig, defaultProvider string) []FallbackCandid
 // It tries each candidate in order, respecting cooldowns and error classification.
 //
 // Behavior:
+//   - With a health tracker, candidates are reordered by the chain's strategy
+//     and degraded candidates are tried last.
 //   - Candidates in cooldown are skipped (logged as skipped attempt).
 //   - context.Canceled aborts immediately (user abort, no fallback).
 //   - Non-retriable errors (format) abort immediately.
@@ -92,6 +123,7 @@ func (fc *FallbackChain) Execute(
 		return nil, fmt.Errorf("fallback: no candidates configured")
 	}
 
+	candidates = fc.order(candidates)
 	result := &FallbackResult{
 		Attempts: make([]FallbackAttempt, 0, len(candidates)),
 	}
@@ -126,6 +158,7 @@ func (fc *FallbackChain) Execute(
 
 		if err == nil {
 			// Success.
+			fc.recordHealth(candidate, elapsed, nil)
 			fc.cooldown.MarkSuccess(candidate.Provider)
 			result.Response = resp
 			result.Provider = candidate.Provider
@@ -172,6 +205,7 @@ func (fc *FallbackChain) Execute(
 		}
 
 		// Retriable error: mark failure and continue to next candidate.
+		fc.recordHealth(candidate, elapsed, failErr)
 		fc.cooldown.MarkFailure(candidate.Provider, failErr.Reason)
 		result.Attempts = append(result.Attempts, FallbackAttempt{
 			Provider: candidate.Provider,
@@ -191,6 +225,15 @@ func (fc *FallbackChain) Execute(
 	return nil, &FallbackExhaustedError{Attempts: result.Attempts}
 }
 
+// recordHealth feeds one attempt into the health tracker, if any. Only
+// successes and retriable failures count; request format errors say nothing
+// about the provider's health.
+func (fc *FallbackChain) recordHealth(candidate FallbackCandidate, elapsed time.Duration, err error) {
+	if fc.health != nil {
+		fc.health.Record(candidate.Provider, candidate.Model, elapsed, err)
+	}
+}
+
 // ExecuteImage runs the fallback chain for image/vision requests.
 // Simpler than Execute: no cooldown checks (image endpoints have different rate limits).
 // Image dimension/size errors abort immediately (non-retriable).
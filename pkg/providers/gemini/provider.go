@@ -0,0 +1,554 @@
+// Package geminiprovider talks to the Gemini API's native generateContent
+// endpoint with an API key. Unlike the OpenAI-compatible endpoint it keeps
+// thought signatures, thought summaries and inline image parts.
+package geminiprovider
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/providers/protocoltypes"
+)
+
+type (
+	ToolCall       = protocoltypes.ToolCall
+	FunctionCall   = protocoltypes.FunctionCall
+	LLMResponse    = protocoltypes.LLMResponse
+	UsageInfo      = protocoltypes.UsageInfo
+	Message        = protocoltypes.Message
+	ToolDefinition = protocoltypes.ToolDefinition
+	ExtraContent   = protocoltypes.ExtraContent
+	GoogleExtra    = protocoltypes.GoogleExtra
+	ResponseFormat = protocoltypes.ResponseFormat
+)
+
+// DefaultBaseURL is the Gemini API endpoint.
+const DefaultBaseURL = "https://generativelanguage.googleapis.com/v1beta"
+
+const defaultModel = "gemini-2.5-flash"
+
+// maxImageBytes caps an inline image; larger requests are rejected by the API.
+const maxImageBytes = 20 << 20
+
+// Options are the Gemini-specific settings from a model_list entry.
+type Options struct {
+	APIKey string
+	Proxy  string
+	// SafetySettings maps harm categories to block thresholds, e.g.
+	// "HARASSMENT": "BLOCK_ONLY_HIGH". The HARM_CATEGORY_ prefix is optional.
+	SafetySettings map[string]string
+}
+
+type Provider struct {
+	baseURL        string
+	opts           Options
+	thinkingBudget int
+	httpClient     *http.Client
+}
+
+var callCounter atomic.Uint64
+
+func NewProvider(apiBase string, opts Options) *Provider {
+	client := &http.Client{Timeout: 120 * time.Second}
+	if opts.Proxy != "" {
+		parsed, err := url.Parse(opts.Proxy)
+		if err == nil {
+			client.Transport = &http.Transport{Proxy: http.ProxyURL(parsed)}
+		} else {
+			log.Printf("gemini: invalid proxy URL %q: %v", opts.Proxy, err)
+		}
+	}
+
+	base := strings.TrimRight(strings.TrimSpace(apiBase), "/")
+	if base == "" {
+		base = DefaultBaseURL
+	}
+	return &Provider{
+		baseURL:    base,
+		opts:       opts,
+		httpClient: client,
+	}
+}
+
+// SetReasoning sets the thinking budget and asks for thought summaries. If
+// budget is 0, effort ("low", "medium" or "high") picks one.
+func (p *Provider) SetReasoning(budget int, effort string) {
+	if budget <= 0 {
+		switch strings.ToLower(strings.TrimSpace(effort)) {
+		case "low", "minimal":
+			budget = 1024
+		case "medium":
+			budget = 8192
+		case "high":
+			budget = 24576
+		}
+	}
+	p.thinkingBudget = budget
+}
+
+func (p *Provider) GetDefaultModel() string {
+	return defaultModel
+}
+
+func (p *Provider) Chat(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+) (*LLMResponse, error) {
+	if p.opts.APIKey == "" {
+		return nil, fmt.Errorf("gemini: api_key not configured")
+	}
+	model = strings.TrimPrefix(model, "models/")
+	if model == "" {
+		model = defaultModel
+	}
+
+	body, err := json.Marshal(p.buildRequest(messages, tools, options))
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	endpoint := fmt.Sprintf("%s/models/%s:generateContent", p.baseURL, url.PathEscape(model))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-goog-api-key", p.opts.APIKey)
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, protocoltypes.NewAPIError(resp, data)
+	}
+
+	return parseResponse(data)
+}
+
+// --- Request building ---
+
+type request struct {
+	Contents          []content         `json:"contents"`
+	SystemInstruction *content          `json:"systemInstruction,omitempty"`
+	Tools             []tool            `json:"tools,omitempty"`
+	SafetySettings    []safetySetting   `json:"safetySettings,omitempty"`
+	GenerationConfig  *generationConfig `json:"generationConfig,omitempty"`
+}
+
+type content struct {
+	Role  string `json:"role,omitempty"`
+	Parts []part `json:"parts"`
+}
+
+type part struct {
+	Text             string            `json:"text,omitempty"`
+	Thought          bool              `json:"thought,omitempty"`
+	ThoughtSignature string            `json:"thoughtSignature,omitempty"`
+	InlineData       *blob             `json:"inlineData,omitempty"`
+	FunctionCall     *functionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *functionResponse `json:"functionResponse,omitempty"`
+}
+
+type blob struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"`
+}
+
+type functionCall struct {
+	ID   string         `json:"id,omitempty"`
+	Name string         `json:"name"`
+	Args map[string]any `json:"args"`
+}
+
+type functionResponse struct {
+	ID       string         `json:"id,omitempty"`
+	Name     string         `json:"name"`
+	Response map[string]any `json:"response"`
+}
+
+type tool struct {
+	FunctionDeclarations []functionDeclaration `json:"functionDeclarations"`
+}
+
+// functionDeclaration passes parameters as parametersJsonSchema, which takes
+// plain JSON Schema rather than the OpenAPI subset of "parameters".
+type functionDeclaration struct {
+	Name                 string         `json:"name"`
+	Description          string         `json:"description,omitempty"`
+	ParametersJSONSchema map[string]any `json:"parametersJsonSchema,omitempty"`
+}
+
+type safetySetting struct {
+	Category  string `json:"category"`
+	Threshold string `json:"threshold"`
+}
+
+type generationConfig struct {
+	MaxOutputTokens    int             `json:"maxOutputTokens,omitempty"`
+	Temperature        *float64        `json:"temperature,omitempty"`
+	ThinkingConfig     *thinkingConfig `json:"thinkingConfig,omitempty"`
+	ResponseMimeType   string          `json:"responseMimeType,omitempty"`
+	ResponseJSONSchema map[string]any  `json:"responseJsonSchema,omitempty"`
+}
+
+type thinkingConfig struct {
+	ThinkingBudget  int  `json:"thinkingBudget,omitempty"`
+	IncludeThoughts bool `json:"includeThoughts"`
+}
+
+func (p *Provider) buildRequest(messages []Message, tools []ToolDefinition, options map[string]any) request {
+	var req request
+	toolNames := make(map[string]string)
+
+	for _, msg := range messages {
+		switch msg.Role {
+		case "system":
+			if req.SystemInstruction == nil {
+				req.SystemInstruction = &content{}
+			}
+			req.SystemInstruction.Parts = append(req.SystemInstruction.Parts, part{Text: msg.Content})
+		case "user", "tool":
+			if msg.ToolCallID != "" {
+				name := toolNames[msg.ToolCallID]
+				if name == "" {
+					name = msg.ToolCallID
+				}
+				req.Contents = appendContent(req.Contents, "user", part{
+					FunctionResponse: &functionResponse{
+						ID:       geminiCallID(msg.ToolCallID),
+						Name:     name,
+						Response: map[string]any{"result": msg.Content},
+					},
+				})
+				continue
+			}
+			var parts []part
+			if msg.Content != "" {
+				parts = append(parts, part{Text: msg.Content})
+			}
+			for _, path := range msg.Media {
+				if img, ok := loadImage(path); ok {
+					parts = append(parts, part{InlineData: img})
+				}
+			}
+			if len(parts) > 0 {
+				req.Contents = appendContent(req.Contents, "user", parts...)
+			}
+		case "assistant":
+			var parts []part
+			if msg.Content != "" {
+				parts = append(parts, part{Text: msg.Content})
+			}
+			for _, tc := range msg.ToolCalls {
+				name, args := toolCallParts(tc)
+				if name == "" {
+					continue
+				}
+				toolNames[tc.ID] = name
+				// The signature must go back on the part it came with, or
+				// Gemini 3 rejects the turn.
+				parts = append(parts, part{
+					ThoughtSignature: thoughtSignature(tc),
+					FunctionCall:     &functionCall{ID: geminiCallID(tc.ID), Name: name, Args: args},
+				})
+			}
+			if len(parts) > 0 {
+				req.Contents = appendContent(req.Contents, "model", parts...)
+			}
+		}
+	}
+
+	var decls []functionDeclaration
+	for _, t := range tools {
+		if t.Type != "" && t.Type != "function" {
+			continue
+		}
+		decls = append(decls, functionDeclaration{
+			Name:                 t.Function.Name,
+			Description:          t.Function.Description,
+			ParametersJSONSchema: t.Function.Parameters,
+		})
+	}
+	if len(decls) > 0 {
+		req.Tools = []tool{{FunctionDeclarations: decls}}
+	}
+
+	req.SafetySettings = safetySettings(p.opts.SafetySettings)
+
+	cfg := &generationConfig{}
+	if maxTokens, ok := asInt(options["max_tokens"]); ok && maxTokens > 0 {
+		cfg.MaxOutputTokens = maxTokens
+	}
+	if temp, ok := asFloat(options["temperature"]); ok {
+		cfg.Temperature = &temp
+	}
+	if p.thinkingBudget > 0 {
+		cfg.ThinkingConfig = &thinkingConfig{ThinkingBudget: p.thinkingBudget, IncludeThoughts: true}
+	}
+	// JSON mode is only set without tools; with tools the agent validates
+	// the final answer instead.
+	if rf, ok := options["response_format"].(*ResponseFormat); ok && rf != nil && len(decls) == 0 {
+		cfg.ResponseMimeType = "application/json"
+		cfg.ResponseJSONSchema = rf.Schema
+	}
+	if cfg.MaxOutputTokens > 0 || cfg.Temperature != nil || cfg.ThinkingConfig != nil || cfg.ResponseMimeType != "" {
+		req.GenerationConfig = cfg
+	}
+
+	return req
+}
+
+// appendContent adds parts as a turn of role, merging with the previous turn
+// if it has the same role: Gemini expects parallel function responses in a
+// single turn.
+func appendContent(contents []content, role string, parts ...part) []content {
+	if n := len(contents); n > 0 && contents[n-1].Role == role {
+		contents[n-1].Parts = append(contents[n-1].Parts, parts...)
+		return contents
+	}
+	return append(contents, content{Role: role, Parts: parts})
+}
+
+// geminiCallID returns the call ID to echo back to Gemini. IDs generated
+// locally for calls that came without one are not sent.
+func geminiCallID(id string) string {
+	if strings.HasPrefix(id, "call_gemini_") {
+		return ""
+	}
+	return id
+}
+
+func toolCallParts(tc ToolCall) (string, map[string]any) {
+	name, args := tc.Name, tc.Arguments
+	if tc.Function != nil {
+		if name == "" {
+			name = tc.Function.Name
+		}
+		if len(args) == 0 && tc.Function.Arguments != "" {
+			if err := json.Unmarshal([]byte(tc.Function.Arguments), &args); err != nil {
+				log.Printf("gemini: failed to decode tool call arguments for %q: %v", name, err)
+			}
+		}
+	}
+	if args == nil {
+		args = map[string]any{}
+	}
+	return name, args
+}
+
+// thoughtSignature finds a stored call's signature in any of the places the
+// agent and other providers keep it.
+func thoughtSignature(tc ToolCall) string {
+	if tc.Function != nil && tc.Function.ThoughtSignature != "" {
+		return tc.Function.ThoughtSignature
+	}
+	if tc.ExtraContent != nil && tc.ExtraContent.Google != nil && tc.ExtraContent.Google.ThoughtSignature != "" {
+		return tc.ExtraContent.Google.ThoughtSignature
+	}
+	return tc.ThoughtSignature
+}
+
+func safetySettings(settings map[string]string) []safetySetting {
+	if len(settings) == 0 {
+		return nil
+	}
+	out := make([]safetySetting, 0, len(settings))
+	for category, threshold := range settings {
+		category = strings.ToUpper(strings.TrimSpace(category))
+		if !strings.HasPrefix(category, "HARM_CATEGORY_") {
+			category = "HARM_CATEGORY_" + category
+		}
+		out = append(out, safetySetting{
+			Category:  category,
+			Threshold: strings.ToUpper(strings.TrimSpace(threshold)),
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Category < out[j].Category })
+	return out
+}
+
+// loadImage reads a local image attachment for an inline part. Remote URLs
+// and non-image files are skipped.
+func loadImage(path string) (*blob, bool) {
+	mimeType := mime.TypeByExtension(strings.ToLower(filepath.Ext(path)))
+	if !strings.HasPrefix(mimeType, "image/") {
+		return nil, false
+	}
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return nil, false
+	}
+	if info.Size() > maxImageBytes {
+		log.Printf("gemini: skipping image %s: %d bytes exceeds limit", path, info.Size())
+		return nil, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Printf("gemini: failed to read image %s: %v", path, err)
+		return nil, false
+	}
+	return &blob{MimeType: mimeType, Data: base64.StdEncoding.EncodeToString(data)}, true
+}
+
+// --- Response parsing ---
+
+type response struct {
+	Candidates []struct {
+		Content      content `json:"content"`
+		FinishReason string  `json:"finishReason"`
+	} `json:"candidates"`
+	PromptFeedback *struct {
+		BlockReason string `json:"blockReason"`
+	} `json:"promptFeedback"`
+	UsageMetadata *struct {
+		PromptTokenCount        int `json:"promptTokenCount"`
+		CandidatesTokenCount    int `json:"candidatesTokenCount"`
+		ThoughtsTokenCount      int `json:"thoughtsTokenCount"`
+		TotalTokenCount         int `json:"totalTokenCount"`
+		CachedContentTokenCount int `json:"cachedContentTokenCount"`
+	} `json:"usageMetadata"`
+}
+
+func parseResponse(data []byte) (*LLMResponse, error) {
+	var resp response
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+
+	if len(resp.Candidates) == 0 {
+		if resp.PromptFeedback != nil && resp.PromptFeedback.BlockReason != "" {
+			return nil, fmt.Errorf("gemini: prompt blocked (%s)", resp.PromptFeedback.BlockReason)
+		}
+		return nil, fmt.Errorf("gemini: no candidates in response")
+	}
+
+	candidate := resp.Candidates[0]
+	var text, reasoning strings.Builder
+	var toolCalls []ToolCall
+
+	for _, pt := range candidate.Content.Parts {
+		switch {
+		case pt.FunctionCall != nil:
+			toolCalls = append(toolCalls, newToolCall(pt))
+		case pt.Thought:
+			reasoning.WriteString(pt.Text)
+		default:
+			text.WriteString(pt.Text)
+		}
+	}
+
+	finishReason := "stop"
+	switch candidate.FinishReason {
+	case "MAX_TOKENS":
+		finishReason = "length"
+	case "SAFETY", "RECITATION", "BLOCKLIST", "PROHIBITED_CONTENT", "SPII", "IMAGE_SAFETY":
+		finishReason = "content_filter"
+	}
+	if len(toolCalls) > 0 {
+		finishReason = "tool_calls"
+	}
+
+	var usage *UsageInfo
+	if u := resp.UsageMetadata; u != nil {
+		usage = &UsageInfo{
+			PromptTokens:     u.PromptTokenCount,
+			CompletionTokens: u.CandidatesTokenCount + u.ThoughtsTokenCount,
+			TotalTokens:      u.TotalTokenCount,
+			CachedTokens:     u.CachedContentTokenCount,
+		}
+	}
+
+	return &LLMResponse{
+		Content:      text.String(),
+		ToolCalls:    toolCalls,
+		FinishReason: finishReason,
+		Usage:        usage,
+		Reasoning:    reasoning.String(),
+	}, nil
+}
+
+// newToolCall converts a functionCall part, keeping its thought signature in
+// every field the agent loop copies into history.
+func newToolCall(pt part) ToolCall {
+	fc := pt.FunctionCall
+	id := fc.ID
+	if id == "" {
+		id = fmt.Sprintf("call_gemini_%d_%d", time.Now().UnixNano(), callCounter.Add(1))
+	}
+	args := fc.Args
+	if args == nil {
+		args = map[string]any{}
+	}
+	argsJSON, _ := json.Marshal(args)
+
+	tc := ToolCall{
+		ID:        id,
+		Type:      "function",
+		Name:      fc.Name,
+		Arguments: args,
+		Function: &FunctionCall{
+			Name:             fc.Name,
+			Arguments:        string(argsJSON),
+			ThoughtSignature: pt.ThoughtSignature,
+		},
+		ThoughtSignature: pt.ThoughtSignature,
+	}
+	if pt.ThoughtSignature != "" {
+		tc.ExtraContent = &ExtraContent{Google: &GoogleExtra{ThoughtSignature: pt.ThoughtSignature}}
+	}
+	return tc
+}
+
+func asInt(v any) (int, bool) {
+	switch val := v.(type) {
+	case int:
+		return val, true
+	case int64:
+		return int(val), true
+	case float64:
+		return int(val), true
+	case float32:
+		return int(val), true
+	default:
+		return 0, false
+	}
+}
+
+func asFloat(v any) (float64, bool) {
+	switch val := v.(type) {
+	case float64:
+		return val, true
+	case float32:
+		return float64(val), true
+	case int:
+		return float64(val), true
+	case int64:
+		return float64(val), true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,189 @@
+package geminiprovider
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/providers/protocoltypes"
+)
+
+func TestProviderChat_NativeRequestAndToolCalls(t *testing.T) {
+	var gotPath, gotKey string
+	var body map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotKey = r.Header.Get("x-goog-api-key")
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Write([]byte(`{
+			"candidates": [{
+				"content": {"role": "model", "parts": [
+					{"text": "Checking the weather.", "thought": true},
+					{"functionCall": {"name": "get_weather", "args": {"city": "Paris"}}, "thoughtSignature": "sig-abc"}
+				]},
+				"finishReason": "STOP"
+			}],
+			"usageMetadata": {"promptTokenCount": 10, "candidatesTokenCount": 5, "thoughtsTokenCount": 3,
+				"totalTokenCount": 18, "cachedContentTokenCount": 4}
+		}`))
+	}))
+	defer server.Close()
+
+	p := NewProvider(server.URL, Options{
+		APIKey:         "key-1",
+		SafetySettings: map[string]string{"harassment": "block_only_high"},
+	})
+	tools := []ToolDefinition{{
+		Type: "function",
+		Function: protocoltypes.ToolFunctionDefinition{
+			Name: "get_weather",
+			Parameters: map[string]any{
+				"type":                 "object",
+				"properties":           map[string]any{"city": map[string]any{"type": "string"}},
+				"additionalProperties": false,
+			},
+		},
+	}}
+	resp, err := p.Chat(t.Context(), []Message{
+		{Role: "system", Content: "Be brief."},
+		{Role: "user", Content: "Weather in Paris?"},
+	}, tools, "gemini-3-flash", map[string]any{"max_tokens": 256, "temperature": 0.0})
+	if err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+
+	if gotPath != "/models/gemini-3-flash:generateContent" || gotKey != "key-1" {
+		t.Errorf("path = %q, key = %q", gotPath, gotKey)
+	}
+	if _, ok := body["systemInstruction"]; !ok {
+		t.Error("system prompt should be sent as systemInstruction")
+	}
+	decl := body["tools"].([]any)[0].(map[string]any)["functionDeclarations"].([]any)[0].(map[string]any)
+	if schema, _ := decl["parametersJsonSchema"].(map[string]any); schema["additionalProperties"] != false {
+		t.Errorf("parametersJsonSchema = %v, want the schema unchanged", decl["parametersJsonSchema"])
+	}
+	gen := body["generationConfig"].(map[string]any)
+	if gen["maxOutputTokens"] != float64(256) || gen["temperature"] != float64(0) {
+		t.Errorf("generationConfig = %v", gen)
+	}
+	safety := body["safetySettings"].([]any)[0].(map[string]any)
+	if safety["category"] != "HARM_CATEGORY_HARASSMENT" || safety["threshold"] != "BLOCK_ONLY_HIGH" {
+		t.Errorf("safetySettings = %v", body["safetySettings"])
+	}
+
+	if resp.FinishReason != "tool_calls" || len(resp.ToolCalls) != 1 {
+		t.Fatalf("resp = %+v", resp)
+	}
+	tc := resp.ToolCalls[0]
+	if tc.Name != "get_weather" || tc.Arguments["city"] != "Paris" {
+		t.Errorf("tool call = %+v", tc)
+	}
+	if tc.Function.ThoughtSignature != "sig-abc" || tc.ExtraContent.Google.ThoughtSignature != "sig-abc" {
+		t.Errorf("thought signature not kept: %+v", tc)
+	}
+	if resp.Reasoning != "Checking the weather." || resp.Content != "" {
+		t.Errorf("Reasoning = %q, Content = %q", resp.Reasoning, resp.Content)
+	}
+	if resp.Usage.CompletionTokens != 8 || resp.Usage.CachedTokens != 4 {
+		t.Errorf("usage = %+v", resp.Usage)
+	}
+}
+
+func TestBuildRequest_RoundTripsSignaturesAndGroupsResponses(t *testing.T) {
+	p := NewProvider("", Options{APIKey: "k"})
+	messages := []Message{
+		{Role: "user", Content: "Compare Paris and Rome"},
+		{Role: "assistant", ToolCalls: []ToolCall{
+			{ID: "call_gemini_1_1", Name: "get_weather", Arguments: map[string]any{"city": "Paris"},
+				Function: &FunctionCall{Name: "get_weather", ThoughtSignature: "sig-1"}},
+			{ID: "call_gemini_1_2", Name: "get_weather", Arguments: map[string]any{"city": "Rome"}},
+		}},
+		{Role: "tool", ToolCallID: "call_gemini_1_1", Content: "Sunny"},
+		{Role: "tool", ToolCallID: "call_gemini_1_2", Content: "Rainy"},
+	}
+
+	req := p.buildRequest(messages, nil, nil)
+	if len(req.Contents) != 3 {
+		t.Fatalf("contents = %d, want user, model, user", len(req.Contents))
+	}
+	model := req.Contents[1]
+	if model.Role != "model" || model.Parts[0].ThoughtSignature != "sig-1" || model.Parts[1].ThoughtSignature != "" {
+		t.Errorf("model turn = %+v", model)
+	}
+	if model.Parts[0].FunctionCall.ID != "" {
+		t.Error("locally generated call IDs must not be sent")
+	}
+	responses := req.Contents[2]
+	if len(responses.Parts) != 2 || responses.Parts[1].FunctionResponse.Name != "get_weather" {
+		t.Errorf("function responses = %+v, want both in one turn", responses)
+	}
+	if req.GenerationConfig != nil {
+		t.Errorf("GenerationConfig = %+v, want none without options", req.GenerationConfig)
+	}
+}
+
+func TestBuildRequest_InlineImage(t *testing.T) {
+	dir := t.TempDir()
+	img := filepath.Join(dir, "photo.png")
+	os.WriteFile(img, []byte("\x89PNG"), 0o644)
+	txt := filepath.Join(dir, "notes.txt")
+	os.WriteFile(txt, []byte("hi"), 0o644)
+
+	p := NewProvider("", Options{APIKey: "k"})
+	req := p.buildRequest([]Message{{Role: "user", Content: "What is this?", Media: []string{img, txt}}}, nil, nil)
+
+	parts := req.Contents[0].Parts
+	if len(parts) != 2 || parts[1].InlineData == nil {
+		t.Fatalf("parts = %+v, want text and one image", parts)
+	}
+	if parts[1].InlineData.MimeType != "image/png" || parts[1].InlineData.Data != "iVBORw==" {
+		t.Errorf("inlineData = %+v", parts[1].InlineData)
+	}
+}
+
+func TestBuildRequest_ThinkingAndResponseFormat(t *testing.T) {
+	p := NewProvider("", Options{APIKey: "k"})
+	p.SetReasoning(0, "medium")
+	schema := map[string]any{"type": "object"}
+
+	req := p.buildRequest([]Message{{Role: "user", Content: "hi"}}, nil,
+		map[string]any{"response_format": &ResponseFormat{Schema: schema}})
+	cfg := req.GenerationConfig
+	if cfg == nil || cfg.ThinkingConfig == nil || cfg.ThinkingConfig.ThinkingBudget != 8192 {
+		t.Fatalf("GenerationConfig = %+v", cfg)
+	}
+	if cfg.ResponseMimeType != "application/json" || cfg.ResponseJSONSchema == nil {
+		t.Errorf("JSON mode not set: %+v", cfg)
+	}
+}
+
+func TestProviderChat_Errors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "blocked") {
+			w.Write([]byte(`{"promptFeedback": {"blockReason": "SAFETY"}}`))
+			return
+		}
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"error": {"status": "RESOURCE_EXHAUSTED"}}`))
+	}))
+	defer server.Close()
+
+	p := NewProvider(server.URL, Options{APIKey: "k"})
+	msgs := []Message{{Role: "user", Content: "hi"}}
+
+	if _, err := p.Chat(t.Context(), msgs, nil, "gemini-2.5-flash", nil); err == nil ||
+		!strings.Contains(err.Error(), "Status: 429") {
+		t.Errorf("err = %v, want status 429", err)
+	}
+	if _, err := p.Chat(t.Context(), msgs, nil, "blocked", nil); err == nil ||
+		!strings.Contains(err.Error(), "prompt blocked (SAFETY)") {
+		t.Errorf("err = %v, want blocked prompt", err)
+	}
+	if _, err := NewProvider(server.URL, Options{}).Chat(t.Context(), msgs, nil, "m", nil); err == nil {
+		t.Error("expected an error without an API key")
+	}
+}
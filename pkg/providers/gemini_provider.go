@@ -0,0 +1,41 @@
+package providers
+
+import (
+	"context"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	geminiprovider "github.com/sipeed/picoclaw/pkg/providers/gemini"
+)
+
+type GeminiProvider struct {
+	delegate *geminiprovider.Provider
+}
+
+func NewGeminiProvider(apiBase string, opts geminiprovider.Options) *GeminiProvider {
+	return &GeminiProvider{
+		delegate: geminiprovider.NewProvider(apiBase, opts),
+	}
+}
+
+func newGeminiProviderFromConfig(cfg *config.ModelConfig) *GeminiProvider {
+	return NewGeminiProvider(cfg.APIBase, geminiprovider.Options{
+		APIKey:         cfg.APIKey,
+		Proxy:          cfg.Proxy,
+		SafetySettings: cfg.SafetySettings,
+	})
+}
+
+func (p *GeminiProvider) Chat(
+	ctx context.Context, messages []Message, tools []ToolDefinition, model string, options map[string]any,
+) (*LLMResponse, error) {
+	return p.delegate.Chat(ctx, messages, tools, model, options)
+}
+
+func (p *GeminiProvider) GetDefaultModel() string {
+	return p.delegate.GetDefaultModel()
+}
+
+// SetReasoning enables thinking with thought summaries.
+func (p *GeminiProvider) SetReasoning(budget int, effort string) {
+	p.delegate.SetReasoning(budget, effort)
+}
@@ -0,0 +1,344 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// SelectionStrategy decides the order in which FallbackChain tries candidates.
+type SelectionStrategy string
+
+const (
+	// StrategyOrdered tries candidates in configured order.
+	StrategyOrdered SelectionStrategy = "ordered"
+	// StrategyLowestLatency tries the candidate with the lowest recent latency first.
+	StrategyLowestLatency SelectionStrategy = "lowest_latency"
+	// StrategyWeighted spreads first attempts over candidates by weighted
+	// round-robin, weighting each by its recent success rate and speed.
+	StrategyWeighted SelectionStrategy = "weighted"
+)
+
+const (
+	defaultHealthWindow = 20
+	defaultProbeTimeout = 30 * time.Second
+
+	// A candidate with at least degradedMinSamples recent results and an error
+	// rate of degradedErrorRate or more is tried after all healthy ones.
+	degradedErrorRate  = 0.5
+	degradedMinSamples = 3
+)
+
+// ParseSelectionStrategy parses a strategy name. Empty means StrategyOrdered.
+func ParseSelectionStrategy(s string) (SelectionStrategy, error) {
+	switch SelectionStrategy(s) {
+	case "", StrategyOrdered:
+		return StrategyOrdered, nil
+	case StrategyLowestLatency, StrategyWeighted:
+		return SelectionStrategy(s), nil
+	}
+	return StrategyOrdered, fmt.Errorf("unknown fallback strategy %q", s)
+}
+
+// ProviderHealth summarizes the recent results of one provider/model pair.
+type ProviderHealth struct {
+	Provider     string    `json:"provider"`
+	Model        string    `json:"model"`
+	Samples      int       `json:"samples"`
+	ErrorRate    float64   `json:"error_rate"`
+	AvgLatencyMs int64     `json:"avg_latency_ms"`
+	Degraded     bool      `json:"degraded"`
+	LastError    string    `json:"last_error,omitempty"`
+	LastChecked  time.Time `json:"last_checked"`
+}
+
+// HealthTracker keeps rolling latency and error statistics over the last
+// few results of each provider/model pair. Results come from real requests
+// and from background probes. Thread-safe, in-memory only.
+type HealthTracker struct {
+	mu      sync.Mutex
+	window  int
+	entries map[string]*healthEntry
+	nowFunc func() time.Time // for testing
+}
+
+type healthEntry struct {
+	provider    string
+	model       string
+	samples     []healthSample // ring buffer of at most window results
+	next        int
+	lastError   string
+	lastChecked time.Time
+}
+
+type healthSample struct {
+	latency time.Duration
+	ok      bool
+}
+
+// NewHealthTracker creates a tracker that keeps the last window results per
+// candidate. A window of 0 or less uses the default of 20.
+func NewHealthTracker(window int) *HealthTracker {
+	if window <= 0 {
+		window = defaultHealthWindow
+	}
+	return &HealthTracker{
+		window:  window,
+		entries: make(map[string]*healthEntry),
+		nowFunc: time.Now,
+	}
+}
+
+// Record adds one result. A nil err counts as a success.
+func (h *HealthTracker) Record(provider, model string, latency time.Duration, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	key := ModelKey(provider, model)
+	e := h.entries[key]
+	if e == nil {
+		e = &healthEntry{provider: provider, model: model}
+		h.entries[key] = e
+	}
+
+	s := healthSample{latency: latency, ok: err == nil}
+	if len(e.samples) < h.window {
+		e.samples = append(e.samples, s)
+	} else {
+		e.samples[e.next] = s
+	}
+	e.next = (e.next + 1) % h.window
+	e.lastChecked = h.nowFunc()
+	if err != nil {
+		e.lastError = err.Error()
+	}
+}
+
+// Get returns the statistics of one provider/model pair.
+func (h *HealthTracker) Get(provider, model string) ProviderHealth {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if e := h.entries[ModelKey(provider, model)]; e != nil {
+		return e.summary()
+	}
+	return ProviderHealth{Provider: provider, Model: model}
+}
+
+// Snapshot returns the statistics of every tracked pair, sorted by provider
+// and model.
+func (h *HealthTracker) Snapshot() []ProviderHealth {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	out := make([]ProviderHealth, 0, len(h.entries))
+	for _, e := range h.entries {
+		out = append(out, e.summary())
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Provider != out[j].Provider {
+			return out[i].Provider < out[j].Provider
+		}
+		return out[i].Model < out[j].Model
+	})
+	return out
+}
+
+func (e *healthEntry) summary() ProviderHealth {
+	ph := ProviderHealth{
+		Provider:    e.provider,
+		Model:       e.model,
+		Samples:     len(e.samples),
+		LastError:   e.lastError,
+		LastChecked: e.lastChecked,
+	}
+	var failures, successes int
+	var total time.Duration
+	for _, s := range e.samples {
+		if !s.ok {
+			failures++
+			continue
+		}
+		successes++
+		total += s.latency
+	}
+	if ph.Samples > 0 {
+		ph.ErrorRate = float64(failures) / float64(ph.Samples)
+	}
+	if successes > 0 {
+		ph.AvgLatencyMs = (total / time.Duration(successes)).Milliseconds()
+	}
+	ph.Degraded = ph.Samples >= degradedMinSamples && ph.ErrorRate >= degradedErrorRate
+	return ph
+}
+
+// ProbeTarget is a candidate to probe and the provider that serves it.
+type ProbeTarget struct {
+	Candidate FallbackCandidate
+	Provider  LLMProvider
+}
+
+// StartProbes sends a tiny request to every target returned by targets once
+// per interval, until ctx is done. Results feed the health tracker and the
+// cooldown tracker, so a failing primary is skipped before a user request
+// hits it. It does nothing if the chain has no health tracker or interval
+// is not positive.
+func (fc *FallbackChain) StartProbes(ctx context.Context, interval time.Duration, targets func() []ProbeTarget) {
+	if fc.health == nil || interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			fc.probe(ctx, targets())
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// probe runs one round of health probes, one target at a time.
+func (fc *FallbackChain) probe(ctx context.Context, targets []ProbeTarget) {
+	for _, t := range targets {
+		if ctx.Err() != nil {
+			return
+		}
+		c := t.Candidate
+
+		probeCtx, cancel := context.WithTimeout(ctx, defaultProbeTimeout)
+		start := time.Now()
+		_, err := t.Provider.Chat(probeCtx,
+			[]Message{{Role: "user", Content: "ping"}}, nil, c.Model,
+			map[string]any{"max_tokens": 16})
+		elapsed := time.Since(start)
+		cancel()
+
+		if ctx.Err() != nil {
+			return
+		}
+		fc.health.Record(c.Provider, c.Model, elapsed, err)
+		if err == nil {
+			fc.cooldown.MarkSuccess(c.Provider)
+			continue
+		}
+
+		logger.WarnCF("providers", "Health probe failed", map[string]any{
+			"provider": c.Provider,
+			"model":    c.Model,
+			"error":    err.Error(),
+		})
+		if failErr := ClassifyError(err, c.Provider, c.Model); failErr != nil && failErr.IsRetriable() {
+			fc.cooldown.MarkFailure(c.Provider, failErr.Reason)
+		}
+	}
+}
+
+// order returns candidates in the order the chain's strategy tries them.
+// Degraded candidates always come last, keeping their relative order.
+func (fc *FallbackChain) order(candidates []FallbackCandidate) []FallbackCandidate {
+	if fc.health == nil || len(candidates) < 2 {
+		return candidates
+	}
+
+	stats := make([]ProviderHealth, len(candidates))
+	var healthy, degraded []int
+	for i, c := range candidates {
+		stats[i] = fc.health.Get(c.Provider, c.Model)
+		if stats[i].Degraded {
+			degraded = append(degraded, i)
+		} else {
+			healthy = append(healthy, i)
+		}
+	}
+
+	fc.mu.Lock()
+	strategy := fc.strategy
+	fc.mu.Unlock()
+
+	switch strategy {
+	case StrategyLowestLatency:
+		// Candidates without a measured latency keep their configured order
+		// after the measured ones.
+		sort.SliceStable(healthy, func(a, b int) bool {
+			la, lb := stats[healthy[a]].AvgLatencyMs, stats[healthy[b]].AvgLatencyMs
+			if la == 0 || lb == 0 {
+				return la != 0 && lb == 0
+			}
+			return la < lb
+		})
+	case StrategyWeighted:
+		healthy = fc.weightedOrder(candidates, stats, healthy)
+	}
+
+	out := make([]FallbackCandidate, 0, len(candidates))
+	for _, i := range healthy {
+		out = append(out, candidates[i])
+	}
+	for _, i := range degraded {
+		out = append(out, candidates[i])
+	}
+	return out
+}
+
+// weightedOrder picks the first candidate by smooth weighted round-robin and
+// orders the rest by weight. A candidate's weight is its success rate divided
+// by its average latency in seconds; unmeasured candidates get the mean
+// weight of the measured ones.
+func (fc *FallbackChain) weightedOrder(candidates []FallbackCandidate, stats []ProviderHealth, idx []int) []int {
+	if len(idx) < 2 {
+		return idx
+	}
+
+	weights := make(map[int]float64, len(idx))
+	var sum float64
+	var measured int
+	for _, i := range idx {
+		if stats[i].AvgLatencyMs <= 0 {
+			continue
+		}
+		w := (1 - stats[i].ErrorRate) / (float64(stats[i].AvgLatencyMs) / 1000)
+		weights[i] = w
+		sum += w
+		measured++
+	}
+	fallback := 1.0
+	if measured > 0 && sum > 0 {
+		fallback = sum / float64(measured)
+	}
+	var total float64
+	for _, i := range idx {
+		if _, ok := weights[i]; !ok {
+			weights[i] = fallback
+		}
+		total += weights[i]
+	}
+
+	fc.mu.Lock()
+	best := idx[0]
+	for _, i := range idx {
+		key := ModelKey(candidates[i].Provider, candidates[i].Model)
+		fc.current[key] += weights[i]
+		if fc.current[key] > fc.current[ModelKey(candidates[best].Provider, candidates[best].Model)] {
+			best = i
+		}
+	}
+	fc.current[ModelKey(candidates[best].Provider, candidates[best].Model)] -= total
+	fc.mu.Unlock()
+
+	rest := make([]int, 0, len(idx)-1)
+	for _, i := range idx {
+		if i != best {
+			rest = append(rest, i)
+		}
+	}
+	sort.SliceStable(rest, func(a, b int) bool { return weights[rest[a]] > weights[rest[b]] })
+	return append([]int{best}, rest...)
+}
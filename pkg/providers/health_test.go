@@ -0,0 +1,184 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type probeProvider struct {
+	errs  map[string]error
+	calls []string
+}
+
+func (p *probeProvider) Chat(
+	ctx context.Context, messages []Message, tools []ToolDefinition, model string, options map[string]any,
+) (*LLMResponse, error) {
+	p.calls = append(p.calls, model)
+	if err := p.errs[model]; err != nil {
+		return nil, err
+	}
+	return &LLMResponse{Content: "pong", FinishReason: "stop"}, nil
+}
+
+func (p *probeProvider) GetDefaultModel() string { return "" }
+
+func candidateModels(candidates []FallbackCandidate) []string {
+	out := make([]string, len(candidates))
+	for i, c := range candidates {
+		out[i] = c.Model
+	}
+	return out
+}
+
+func TestHealthTracker_RollingWindow(t *testing.T) {
+	h := NewHealthTracker(4)
+	h.Record("openai", "gpt-4", 100*time.Millisecond, errors.New("timeout"))
+	h.Record("openai", "gpt-4", 100*time.Millisecond, errors.New("timeout"))
+	for i := 0; i < 4; i++ {
+		h.Record("openai", "gpt-4", 200*time.Millisecond, nil)
+	}
+
+	got := h.Get("openai", "gpt-4")
+	if got.Samples != 4 || got.ErrorRate != 0 {
+		t.Errorf("samples/error rate = %d/%v, want failures to roll out of the window", got.Samples, got.ErrorRate)
+	}
+	if got.AvgLatencyMs != 200 {
+		t.Errorf("avg latency = %d, want 200", got.AvgLatencyMs)
+	}
+	if got.LastError != "timeout" {
+		t.Errorf("last error = %q, want timeout", got.LastError)
+	}
+
+	unknown := h.Get("anthropic", "claude")
+	if unknown.Samples != 0 || unknown.Degraded {
+		t.Errorf("unknown candidate = %+v, want empty", unknown)
+	}
+}
+
+func TestHealthTracker_Degraded(t *testing.T) {
+	h := NewHealthTracker(0)
+	h.Record("openai", "gpt-4", time.Second, errors.New("503"))
+	h.Record("openai", "gpt-4", time.Second, errors.New("503"))
+	if h.Get("openai", "gpt-4").Degraded {
+		t.Error("expected too few samples to not count as degraded")
+	}
+	h.Record("openai", "gpt-4", time.Second, nil)
+	if got := h.Get("openai", "gpt-4"); !got.Degraded {
+		t.Errorf("expected degraded at error rate %v", got.ErrorRate)
+	}
+}
+
+func TestFallback_OrderedSkipsDegradedPrimary(t *testing.T) {
+	fc := NewFallbackChain(NewCooldownTracker())
+	h := NewHealthTracker(0)
+	fc.SetHealthTracker(h)
+	for i := 0; i < 3; i++ {
+		h.Record("openai", "gpt-4", time.Second, errors.New("overloaded"))
+	}
+
+	candidates := []FallbackCandidate{
+		makeCandidate("openai", "gpt-4"),
+		makeCandidate("anthropic", "claude-opus"),
+	}
+	var tried []string
+	result, err := fc.Execute(context.Background(), candidates,
+		func(ctx context.Context, provider, model string) (*LLMResponse, error) {
+			tried = append(tried, model)
+			return &LLMResponse{Content: "ok"}, nil
+		})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Model != "claude-opus" || len(tried) != 1 {
+		t.Errorf("tried %v, want the healthy fallback first", tried)
+	}
+	if got := h.Get("anthropic", "claude-opus"); got.Samples != 1 {
+		t.Errorf("expected the successful attempt to be recorded, got %+v", got)
+	}
+}
+
+func TestFallback_LowestLatencyOrder(t *testing.T) {
+	fc := NewFallbackChain(NewCooldownTracker())
+	h := NewHealthTracker(0)
+	fc.SetHealthTracker(h)
+	fc.SetStrategy(StrategyLowestLatency)
+	h.Record("a", "slow", 3*time.Second, nil)
+	h.Record("b", "fast", 500*time.Millisecond, nil)
+
+	candidates := []FallbackCandidate{
+		makeCandidate("a", "slow"),
+		makeCandidate("c", "unmeasured"),
+		makeCandidate("b", "fast"),
+	}
+	got := candidateModels(fc.order(candidates))
+	want := []string{"fast", "slow", "unmeasured"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("order = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestFallback_WeightedRoundRobin(t *testing.T) {
+	fc := NewFallbackChain(NewCooldownTracker())
+	h := NewHealthTracker(0)
+	fc.SetHealthTracker(h)
+	fc.SetStrategy(StrategyWeighted)
+	// "fast" answers three times as quickly, so it should go first three
+	// times as often.
+	h.Record("a", "fast", 100*time.Millisecond, nil)
+	h.Record("b", "slow", 300*time.Millisecond, nil)
+
+	candidates := []FallbackCandidate{makeCandidate("a", "fast"), makeCandidate("b", "slow")}
+	first := make(map[string]int)
+	for i := 0; i < 8; i++ {
+		order := fc.order(candidates)
+		if len(order) != 2 {
+			t.Fatalf("order = %v, want both candidates", candidateModels(order))
+		}
+		first[order[0].Model]++
+	}
+	if first["fast"] != 6 || first["slow"] != 2 {
+		t.Errorf("first picks = %v, want fast:6 slow:2", first)
+	}
+}
+
+func TestFallback_ProbeFeedsHealthAndCooldown(t *testing.T) {
+	ct := NewCooldownTracker()
+	fc := NewFallbackChain(ct)
+	fc.SetHealthTracker(NewHealthTracker(0))
+
+	p := &probeProvider{errs: map[string]error{"gpt-4": errors.New("rate limit exceeded")}}
+	fc.probe(context.Background(), []ProbeTarget{
+		{Candidate: makeCandidate("openai", "gpt-4"), Provider: p},
+		{Candidate: makeCandidate("anthropic", "claude-opus"), Provider: p},
+	})
+
+	if len(p.calls) != 2 {
+		t.Fatalf("probed %v, want both candidates", p.calls)
+	}
+	if ct.IsAvailable("openai") {
+		t.Error("expected failed probe to put the provider in cooldown")
+	}
+	if !ct.IsAvailable("anthropic") {
+		t.Error("expected healthy provider to stay available")
+	}
+	snap := fc.HealthTracker().Snapshot()
+	if len(snap) != 2 || snap[0].Provider != "anthropic" || snap[1].ErrorRate != 1 {
+		t.Errorf("snapshot = %+v", snap)
+	}
+}
+
+func TestParseSelectionStrategy(t *testing.T) {
+	if s, err := ParseSelectionStrategy(""); err != nil || s != StrategyOrdered {
+		t.Errorf("empty = %q, %v; want ordered", s, err)
+	}
+	if s, err := ParseSelectionStrategy("weighted"); err != nil || s != StrategyWeighted {
+		t.Errorf("weighted = %q, %v", s, err)
+	}
+	if _, err := ParseSelectionStrategy("fastest"); err == nil {
+		t.Error("expected error for unknown strategy")
+	}
+}
@@ -38,6 +38,16 @@ func (p *HTTPProvider) Chat(
 	return p.delegate.Chat(ctx, messages, tools, model, options)
 }
 
+// SetPromptCache overrides whether prompt cache keys are sent.
+func (p *HTTPProvider) SetPromptCache(enabled bool) {
+	p.delegate.SetPromptCache(enabled)
+}
+
+// SetReasoning sets the default reasoning effort.
+func (p *HTTPProvider) SetReasoning(budget int, effort string) {
+	p.delegate.SetReasoning(budget, effort)
+}
+
 func (p *HTTPProvider) GetDefaultModel() string {
 	return ""
 }
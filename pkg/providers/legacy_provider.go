@@ -7,6 +7,8 @@ package providers
 
 import (
 	"fmt"
+	"path/filepath"
+	"time"
 
 	"github.com/sipeed/picoclaw/pkg/config"
 )
@@ -45,5 +47,14 @@ func CreateProvider(cfg *config.Config) (LLMProvider, string, error) {
 		return nil, "", fmt.Errorf("failed to create provider for model %q: %w", model, err)
 	}
 
+	if rc := cfg.Agents.Defaults.ResponseCache; rc.Enabled {
+		cache := NewResponseCache(
+			filepath.Join(cfg.WorkspacePath(), "cache", "responses"),
+			time.Duration(rc.TTLSeconds)*time.Second,
+			rc.MaxEntries,
+		)
+		provider = NewCachingProvider(provider, cache, rc.CacheSampled)
+	}
+
 	return provider, modelID, nil
 }
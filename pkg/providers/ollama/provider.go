@@ -0,0 +1,464 @@
+// Package ollamaprovider talks to Ollama's native API (/api/chat, /api/tags,
+// /api/pull) instead of its OpenAI-compatible endpoint, which lacks
+// keep_alive, num_ctx and model management.
+package ollamaprovider
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/providers/protocoltypes"
+)
+
+type (
+	ToolCall       = protocoltypes.ToolCall
+	FunctionCall   = protocoltypes.FunctionCall
+	LLMResponse    = protocoltypes.LLMResponse
+	UsageInfo      = protocoltypes.UsageInfo
+	Message        = protocoltypes.Message
+	ToolDefinition = protocoltypes.ToolDefinition
+	ResponseFormat = protocoltypes.ResponseFormat
+)
+
+// DefaultBaseURL is where a local Ollama server listens by default.
+const DefaultBaseURL = "http://localhost:11434"
+
+// maxImageBytes caps the size of an image attachment sent inline.
+const maxImageBytes = 20 << 20
+
+// Options are the Ollama-specific settings from a model_list entry.
+type Options struct {
+	APIKey    string // Sent as a bearer token, for servers behind an authenticating proxy
+	Proxy     string
+	KeepAlive string // How long the model stays loaded, e.g. "10m" or "-1" for forever
+	NumCtx    int    // Context window size; 0 uses the model default
+	AutoPull  bool   // Pull a missing model on first use
+	// Think enables thinking: "on", or an effort level ("low", "medium",
+	// "high") for models that support levels, such as gpt-oss.
+	Think string
+}
+
+type Provider struct {
+	baseURL    string
+	opts       Options
+	httpClient *http.Client
+	// pullClient has no timeout: pulls of multi-GB models are bounded by ctx only.
+	pullClient *http.Client
+}
+
+var callCounter atomic.Uint64
+
+func NewProvider(apiBase string, opts Options) *Provider {
+	client := &http.Client{Timeout: 300 * time.Second}
+	pullClient := &http.Client{}
+
+	if opts.Proxy != "" {
+		parsed, err := url.Parse(opts.Proxy)
+		if err == nil {
+			transport := &http.Transport{Proxy: http.ProxyURL(parsed)}
+			client.Transport = transport
+			pullClient.Transport = transport
+		} else {
+			log.Printf("ollama: invalid proxy URL %q: %v", opts.Proxy, err)
+		}
+	}
+
+	return &Provider{
+		baseURL:    NormalizeBaseURL(apiBase),
+		opts:       opts,
+		httpClient: client,
+		pullClient: pullClient,
+	}
+}
+
+// NormalizeBaseURL returns the server root for apiBase. The "/v1" suffix of
+// OpenAI-compatible configs is dropped so existing api_base values keep working.
+func NormalizeBaseURL(apiBase string) string {
+	base := strings.TrimRight(strings.TrimSpace(apiBase), "/")
+	if base == "" {
+		return DefaultBaseURL
+	}
+	base = strings.TrimSuffix(base, "/v1")
+	return strings.TrimRight(base, "/")
+}
+
+func (p *Provider) BaseURL() string {
+	return p.baseURL
+}
+
+func (p *Provider) GetDefaultModel() string {
+	return ""
+}
+
+type chatMessage struct {
+	Role      string         `json:"role"`
+	Content   string         `json:"content"`
+	Thinking  string         `json:"thinking,omitempty"`
+	Images    []string       `json:"images,omitempty"`
+	ToolCalls []chatToolCall `json:"tool_calls,omitempty"`
+	ToolName  string         `json:"tool_name,omitempty"`
+}
+
+type chatToolCall struct {
+	ID       string `json:"id,omitempty"`
+	Function struct {
+		Name      string         `json:"name"`
+		Arguments map[string]any `json:"arguments"`
+	} `json:"function"`
+}
+
+type chatResponse struct {
+	Message         chatMessage `json:"message"`
+	Done            bool        `json:"done"`
+	DoneReason      string      `json:"done_reason"`
+	PromptEvalCount int         `json:"prompt_eval_count"`
+	EvalCount       int         `json:"eval_count"`
+}
+
+// apiError is a non-200 response. Its message follows the same format as the
+// OpenAI-compatible provider so the error classifier can read the status.
+type apiError struct {
+	status int
+	body   string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("API request failed:\n  Status: %d\n  Body:   %s", e.status, e.body)
+}
+
+func (e *apiError) modelMissing() bool {
+	return e.status == http.StatusNotFound && strings.Contains(strings.ToLower(e.body), "not found")
+}
+
+func (p *Provider) Chat(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+) (*LLMResponse, error) {
+	body, err := json.Marshal(p.buildRequest(messages, tools, model, options))
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	data, err := p.post(ctx, p.httpClient, "/api/chat", body)
+	if apiErr, ok := err.(*apiError); ok && apiErr.modelMissing() && p.opts.AutoPull {
+		log.Printf("ollama: model %q not installed, pulling", model)
+		if pullErr := p.Pull(ctx, model); pullErr != nil {
+			return nil, fmt.Errorf("pulling model %q: %w", model, pullErr)
+		}
+		data, err = p.post(ctx, p.httpClient, "/api/chat", body)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return parseResponse(data)
+}
+
+func (p *Provider) buildRequest(
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+) map[string]any {
+	req := map[string]any{
+		"model":    model,
+		"messages": convertMessages(messages),
+		"stream":   false,
+	}
+	if len(tools) > 0 {
+		req["tools"] = tools
+	}
+
+	modelOptions := map[string]any{}
+	if p.opts.NumCtx > 0 {
+		modelOptions["num_ctx"] = p.opts.NumCtx
+	}
+	if maxTokens, ok := asInt(options["max_tokens"]); ok {
+		modelOptions["num_predict"] = maxTokens
+	}
+	if temperature, ok := asFloat(options["temperature"]); ok {
+		modelOptions["temperature"] = temperature
+	}
+	if len(modelOptions) > 0 {
+		req["options"] = modelOptions
+	}
+
+	// Ollama constrains output to a JSON schema, or to any JSON with "json".
+	if rf, ok := options["response_format"].(*ResponseFormat); ok && rf != nil {
+		if rf.Schema != nil {
+			req["format"] = rf.Schema
+		} else {
+			req["format"] = "json"
+		}
+	}
+
+	if think := strings.ToLower(strings.TrimSpace(p.opts.Think)); think != "" {
+		if think != "on" && strings.Contains(strings.ToLower(model), "gpt-oss") {
+			req["think"] = think
+		} else {
+			req["think"] = true
+		}
+	}
+
+	if keepAlive := strings.TrimSpace(p.opts.KeepAlive); keepAlive != "" {
+		// Ollama reads bare numbers as seconds and strings as Go durations.
+		if seconds, err := strconv.Atoi(keepAlive); err == nil {
+			req["keep_alive"] = seconds
+		} else {
+			req["keep_alive"] = keepAlive
+		}
+	}
+
+	return req
+}
+
+func convertMessages(messages []Message) []chatMessage {
+	out := make([]chatMessage, 0, len(messages))
+	toolNames := make(map[string]string)
+
+	for _, msg := range messages {
+		cm := chatMessage{Role: msg.Role, Content: msg.Content, Thinking: msg.ReasoningContent}
+
+		for _, tc := range msg.ToolCalls {
+			var call chatToolCall
+			call.ID = tc.ID
+			call.Function.Name, call.Function.Arguments = toolCallParts(tc)
+			cm.ToolCalls = append(cm.ToolCalls, call)
+			if tc.ID != "" {
+				toolNames[tc.ID] = call.Function.Name
+			}
+		}
+
+		if msg.Role == "tool" {
+			cm.ToolName = toolNames[msg.ToolCallID]
+		}
+
+		for _, path := range msg.Media {
+			if img, ok := loadImage(path); ok {
+				cm.Images = append(cm.Images, img)
+			}
+		}
+
+		out = append(out, cm)
+	}
+	return out
+}
+
+func toolCallParts(tc ToolCall) (string, map[string]any) {
+	name, args := tc.Name, tc.Arguments
+	if tc.Function != nil {
+		if name == "" {
+			name = tc.Function.Name
+		}
+		if args == nil && tc.Function.Arguments != "" {
+			if err := json.Unmarshal([]byte(tc.Function.Arguments), &args); err != nil {
+				log.Printf("ollama: failed to decode tool call arguments for %q: %v", name, err)
+			}
+		}
+	}
+	if args == nil {
+		args = map[string]any{}
+	}
+	return name, args
+}
+
+// loadImage reads a local image attachment as base64. Remote URLs and
+// non-image files are skipped.
+func loadImage(path string) (string, bool) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jpg", ".jpeg", ".png", ".gif", ".webp", ".bmp":
+	default:
+		return "", false
+	}
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return "", false
+	}
+	if info.Size() > maxImageBytes {
+		log.Printf("ollama: skipping image %s: %d bytes exceeds limit", path, info.Size())
+		return "", false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Printf("ollama: failed to read image %s: %v", path, err)
+		return "", false
+	}
+	return base64.StdEncoding.EncodeToString(data), true
+}
+
+func parseResponse(data []byte) (*LLMResponse, error) {
+	var resp chatResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+
+	toolCalls := make([]ToolCall, 0, len(resp.Message.ToolCalls))
+	for _, tc := range resp.Message.ToolCalls {
+		id := tc.ID
+		if id == "" {
+			// Older Ollama versions do not assign IDs, but the agent pairs
+			// tool results with calls by ID.
+			id = fmt.Sprintf("call_ollama_%d_%d", time.Now().UnixNano(), callCounter.Add(1))
+		}
+		args := tc.Function.Arguments
+		if args == nil {
+			args = map[string]any{}
+		}
+		toolCalls = append(toolCalls, ToolCall{
+			ID:        id,
+			Name:      tc.Function.Name,
+			Arguments: args,
+		})
+	}
+
+	finishReason := resp.DoneReason
+	if len(toolCalls) > 0 {
+		finishReason = "tool_calls"
+	} else if finishReason == "" {
+		finishReason = "stop"
+	}
+
+	return &LLMResponse{
+		Content:      resp.Message.Content,
+		ToolCalls:    toolCalls,
+		FinishReason: finishReason,
+		Reasoning:    resp.Message.Thinking,
+		Usage: &UsageInfo{
+			PromptTokens:     resp.PromptEvalCount,
+			CompletionTokens: resp.EvalCount,
+			TotalTokens:      resp.PromptEvalCount + resp.EvalCount,
+		},
+	}, nil
+}
+
+// Pull downloads model to the Ollama server and blocks until it completes.
+func (p *Provider) Pull(ctx context.Context, model string) error {
+	body, err := json.Marshal(map[string]any{"model": model, "stream": false})
+	if err != nil {
+		return err
+	}
+	data, err := p.post(ctx, p.pullClient, "/api/pull", body)
+	if err != nil {
+		return err
+	}
+	var resp struct {
+		Status string `json:"status"`
+		Error  string `json:"error"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return fmt.Errorf("failed to unmarshal pull response: %w", err)
+	}
+	if resp.Error != "" {
+		return fmt.Errorf("%s", resp.Error)
+	}
+	if resp.Status != "success" {
+		return fmt.Errorf("unexpected pull status %q", resp.Status)
+	}
+	return nil
+}
+
+// ModelInfo describes a model installed on the Ollama server.
+type ModelInfo struct {
+	Name       string    `json:"name"`
+	Size       int64     `json:"size"`
+	ModifiedAt time.Time `json:"modified_at"`
+	Details    struct {
+		ParameterSize     string `json:"parameter_size"`
+		QuantizationLevel string `json:"quantization_level"`
+	} `json:"details"`
+}
+
+// ListModels returns the models installed on the Ollama server.
+func (p *Provider) ListModels(ctx context.Context) ([]ModelInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.baseURL+"/api/tags", nil)
+	if err != nil {
+		return nil, err
+	}
+	data, err := p.do(p.httpClient, req)
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Models []ModelInfo `json:"models"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal model list: %w", err)
+	}
+	return resp.Models, nil
+}
+
+func (p *Provider) post(ctx context.Context, client *http.Client, path string, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return p.do(client, req)
+}
+
+func (p *Provider) do(client *http.Client, req *http.Request) ([]byte, error) {
+	if p.opts.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.opts.APIKey)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, &apiError{status: resp.StatusCode, body: string(data)}
+	}
+	return data, nil
+}
+
+func asInt(v any) (int, bool) {
+	switch val := v.(type) {
+	case int:
+		return val, true
+	case int64:
+		return int(val), true
+	case float64:
+		return int(val), true
+	case float32:
+		return int(val), true
+	default:
+		return 0, false
+	}
+}
+
+func asFloat(v any) (float64, bool) {
+	switch val := v.(type) {
+	case float64:
+		return val, true
+	case float32:
+		return float64(val), true
+	case int:
+		return float64(val), true
+	case int64:
+		return float64(val), true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,221 @@
+package ollamaprovider
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNormalizeBaseURL(t *testing.T) {
+	tests := map[string]string{
+		"":                           DefaultBaseURL,
+		"http://localhost:11434/v1":  "http://localhost:11434",
+		"http://gpu-box:11434/":      "http://gpu-box:11434",
+		"http://localhost:11434/v1/": "http://localhost:11434",
+	}
+	for in, want := range tests {
+		if got := NormalizeBaseURL(in); got != want {
+			t.Errorf("NormalizeBaseURL(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestProviderChat_NativeRequestAndToolCalls(t *testing.T) {
+	var requestBody map[string]any
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"message": map[string]any{
+				"role":    "assistant",
+				"content": "",
+				"tool_calls": []map[string]any{
+					{"function": map[string]any{"name": "get_weather", "arguments": map[string]any{"city": "SF"}}},
+				},
+			},
+			"done":              true,
+			"done_reason":       "stop",
+			"prompt_eval_count": 12,
+			"eval_count":        3,
+		})
+	}))
+	defer server.Close()
+
+	imgPath := filepath.Join(t.TempDir(), "cat.png")
+	if err := os.WriteFile(imgPath, []byte("png-bytes"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	p := NewProvider(server.URL+"/v1", Options{KeepAlive: "-1", NumCtx: 8192})
+	resp, err := p.Chat(t.Context(), []Message{
+		{Role: "user", Content: "what is this?", Media: []string{imgPath, "https://example.com/x.png"}},
+		{Role: "assistant", ToolCalls: []ToolCall{{ID: "call_1", Name: "read_file", Arguments: map[string]any{"path": "a"}}}},
+		{Role: "tool", Content: "contents", ToolCallID: "call_1"},
+	}, nil, "llama3.2", map[string]any{"max_tokens": 256, "temperature": 0.2})
+	if err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+
+	if requestBody["stream"] != false {
+		t.Errorf("expected stream=false, got %v", requestBody["stream"])
+	}
+	if requestBody["keep_alive"] != float64(-1) {
+		t.Errorf("expected numeric keep_alive, got %v", requestBody["keep_alive"])
+	}
+	opts := requestBody["options"].(map[string]any)
+	if opts["num_ctx"] != float64(8192) || opts["num_predict"] != float64(256) {
+		t.Errorf("unexpected options: %v", opts)
+	}
+
+	msgs := requestBody["messages"].([]any)
+	user := msgs[0].(map[string]any)
+	images, _ := user["images"].([]any)
+	if len(images) != 1 || images[0] != base64.StdEncoding.EncodeToString([]byte("png-bytes")) {
+		t.Errorf("expected one inline image, got %v", user["images"])
+	}
+	assistant := msgs[1].(map[string]any)
+	call := assistant["tool_calls"].([]any)[0].(map[string]any)["function"].(map[string]any)
+	if call["name"] != "read_file" || call["arguments"].(map[string]any)["path"] != "a" {
+		t.Errorf("unexpected assistant tool call: %v", call)
+	}
+	if tool := msgs[2].(map[string]any); tool["tool_name"] != "read_file" {
+		t.Errorf("expected tool_name on tool result, got %v", tool["tool_name"])
+	}
+
+	if len(resp.ToolCalls) != 1 || resp.ToolCalls[0].Name != "get_weather" || resp.ToolCalls[0].ID == "" {
+		t.Fatalf("unexpected tool calls: %+v", resp.ToolCalls)
+	}
+	if resp.FinishReason != "tool_calls" {
+		t.Errorf("FinishReason = %q, want tool_calls", resp.FinishReason)
+	}
+	if resp.Usage == nil || resp.Usage.TotalTokens != 15 {
+		t.Errorf("unexpected usage: %+v", resp.Usage)
+	}
+}
+
+func TestProviderChat_AutoPull(t *testing.T) {
+	pulled := false
+	chats := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/chat":
+			chats++
+			if !pulled {
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte(`{"error":"model \"qwen3\" not found, try pulling it first"}`))
+				return
+			}
+			w.Write([]byte(`{"message":{"role":"assistant","content":"hi"},"done":true,"done_reason":"stop"}`))
+		case "/api/pull":
+			var body map[string]any
+			json.NewDecoder(r.Body).Decode(&body)
+			if body["model"] != "qwen3" {
+				http.Error(w, "wrong model", http.StatusBadRequest)
+				return
+			}
+			pulled = true
+			w.Write([]byte(`{"status":"success"}`))
+		}
+	}))
+	defer server.Close()
+
+	msgs := []Message{{Role: "user", Content: "hi"}}
+
+	if _, err := NewProvider(server.URL, Options{}).Chat(t.Context(), msgs, nil, "qwen3", nil); err == nil {
+		t.Fatal("expected error without auto_pull")
+	}
+	if pulled {
+		t.Fatal("model should not be pulled without auto_pull")
+	}
+
+	resp, err := NewProvider(server.URL, Options{AutoPull: true}).Chat(t.Context(), msgs, nil, "qwen3", nil)
+	if err != nil {
+		t.Fatalf("Chat() with auto_pull error = %v", err)
+	}
+	if !pulled || resp.Content != "hi" || chats != 3 {
+		t.Errorf("pulled=%v content=%q chats=%d", pulled, resp.Content, chats)
+	}
+}
+
+func TestProviderChat_Think(t *testing.T) {
+	var body map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body = nil
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Write([]byte(`{"message":{"role":"assistant","content":"4","thinking":"2+2"},"done":true}`))
+	}))
+	defer server.Close()
+
+	msgs := []Message{{Role: "user", Content: "2+2?"}}
+
+	resp, err := NewProvider(server.URL, Options{Think: "high"}).Chat(t.Context(), msgs, nil, "qwen3", nil)
+	if err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	if body["think"] != true {
+		t.Errorf("think = %v, want true for a model without levels", body["think"])
+	}
+	if resp.Reasoning != "2+2" {
+		t.Errorf("Reasoning = %q, want 2+2", resp.Reasoning)
+	}
+
+	if _, err := NewProvider(server.URL, Options{Think: "high"}).Chat(t.Context(), msgs, nil, "gpt-oss:20b", nil); err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	if body["think"] != "high" {
+		t.Errorf("think = %v, want high for gpt-oss", body["think"])
+	}
+
+	if _, err := NewProvider(server.URL, Options{}).Chat(t.Context(), msgs, nil, "qwen3", nil); err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	if _, ok := body["think"]; ok {
+		t.Error("think should not be sent unless configured")
+	}
+}
+
+func TestBuildRequest_ResponseFormat(t *testing.T) {
+	p := NewProvider("", Options{})
+	schema := map[string]any{"type": "object"}
+
+	req := p.buildRequest(nil, nil, "llama3", map[string]any{"response_format": &ResponseFormat{Schema: schema}})
+	if got, ok := req["format"].(map[string]any); !ok || got["type"] != "object" {
+		t.Errorf("format = %v, want the schema", req["format"])
+	}
+
+	req = p.buildRequest(nil, nil, "llama3", map[string]any{"response_format": &ResponseFormat{}})
+	if req["format"] != "json" {
+		t.Errorf("format = %v, want json", req["format"])
+	}
+}
+
+func TestProviderListModels(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/tags" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"models":[{"name":"llama3:latest","size":4661224676,"details":{"parameter_size":"8.0B"}}]}`))
+	}))
+	defer server.Close()
+
+	models, err := NewProvider(server.URL, Options{}).ListModels(t.Context())
+	if err != nil {
+		t.Fatalf("ListModels() error = %v", err)
+	}
+	if len(models) != 1 || models[0].Name != "llama3:latest" || models[0].Details.ParameterSize != "8.0B" {
+		t.Errorf("unexpected models: %+v", models)
+	}
+}
@@ -0,0 +1,63 @@
+package providers
+
+import (
+	"context"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	ollamaprovider "github.com/sipeed/picoclaw/pkg/providers/ollama"
+)
+
+type OllamaProvider struct {
+	delegate *ollamaprovider.Provider
+}
+
+func NewOllamaProvider(apiBase string, opts ollamaprovider.Options) *OllamaProvider {
+	return &OllamaProvider{
+		delegate: ollamaprovider.NewProvider(apiBase, opts),
+	}
+}
+
+func newOllamaProviderFromConfig(cfg *config.ModelConfig) *OllamaProvider {
+	return NewOllamaProvider(cfg.APIBase, ollamaprovider.Options{
+		APIKey:    cfg.APIKey,
+		Proxy:     cfg.Proxy,
+		KeepAlive: cfg.KeepAlive,
+		NumCtx:    cfg.NumCtx,
+		AutoPull:  cfg.AutoPull,
+		Think:     ollamaThink(cfg),
+	})
+}
+
+func ollamaThink(cfg *config.ModelConfig) string {
+	if cfg.ReasoningEffort != "" {
+		return cfg.ReasoningEffort
+	}
+	if cfg.ThinkingBudget > 0 {
+		return "on"
+	}
+	return ""
+}
+
+func (p *OllamaProvider) Chat(
+	ctx context.Context, messages []Message, tools []ToolDefinition, model string, options map[string]any,
+) (*LLMResponse, error) {
+	return p.delegate.Chat(ctx, messages, tools, model, options)
+}
+
+func (p *OllamaProvider) GetDefaultModel() string {
+	return p.delegate.GetDefaultModel()
+}
+
+// ListOllamaModels returns the names of the models installed on the Ollama
+// server at apiBase (the default local server if empty).
+func ListOllamaModels(ctx context.Context, apiBase string) ([]string, error) {
+	models, err := ollamaprovider.NewProvider(apiBase, ollamaprovider.Options{}).ListModels(ctx)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(models))
+	for _, m := range models {
+		names = append(names, m.Name)
+	}
+	return names, nil
+}
@@ -25,12 +25,15 @@ type (
 	ToolFunctionDefinition = protocoltypes.ToolFunctionDefinition
 	ExtraContent           = protocoltypes.ExtraContent
 	GoogleExtra            = protocoltypes.GoogleExtra
+	ResponseFormat         = protocoltypes.ResponseFormat
 )
 
 type Provider struct {
 	apiKey         string
 	apiBase        string
 	maxTokensField string // Field name for max tokens (e.g., "max_completion_tokens" for o1/glm models)
+	promptCacheKey bool   // Send options["prompt_cache_key"] as prompt_cache_key
+	reasoning      string // Default reasoning_effort, e.g. "low", "medium", "high"
 	httpClient     *http.Client
 }
 
@@ -58,10 +61,32 @@ func NewProviderWithMaxTokensField(apiKey, apiBase, proxy, maxTokensField string
 		apiKey:         apiKey,
 		apiBase:        strings.TrimRight(apiBase, "/"),
 		maxTokensField: maxTokensField,
+		promptCacheKey: supportsPromptCacheKey(apiBase),
 		httpClient:     client,
 	}
 }
 
+// SetPromptCache overrides whether prompt_cache_key is sent. By default it is
+// only sent to endpoints known to accept it, since strict servers reject
+// unknown fields.
+func (p *Provider) SetPromptCache(enabled bool) {
+	p.promptCacheKey = enabled
+}
+
+// SetReasoning sets the default reasoning_effort sent with each request. The
+// thinking budget is not part of the Chat Completions API and is ignored.
+func (p *Provider) SetReasoning(budget int, effort string) {
+	p.reasoning = strings.ToLower(strings.TrimSpace(effort))
+}
+
+func supportsPromptCacheKey(apiBase string) bool {
+	u, err := url.Parse(strings.TrimSpace(apiBase))
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Hostname(), "api.openai.com")
+}
+
 func (p *Provider) Chat(
 	ctx context.Context,
 	messages []Message,
@@ -77,7 +102,7 @@ func (p *Provider) Chat(
 
 	requestBody := map[string]any{
 		"model":    model,
-		"messages": messages,
+		"messages": wireMessages(messages),
 	}
 
 	if len(tools) > 0 {
@@ -111,6 +136,24 @@ func (p *Provider) Chat(
 		}
 	}
 
+	effort := p.reasoning
+	if e, ok := options["reasoning_effort"].(string); ok && e != "" {
+		effort = e
+	}
+	if effort != "" {
+		requestBody["reasoning_effort"] = effort
+	}
+
+	if rf, ok := options["response_format"].(*ResponseFormat); ok && rf != nil {
+		requestBody["response_format"] = responseFormatParam(rf)
+	}
+
+	// Requests sharing a cache key are routed to the same cache, so the
+	// stable prefix of a conversation is served from it.
+	if key, ok := options["prompt_cache_key"].(string); ok && key != "" && p.promptCacheKey {
+		requestBody["prompt_cache_key"] = key
+	}
+
 	jsonData, err := json.Marshal(requestBody)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
@@ -138,18 +181,36 @@ func (p *Provider) Chat(
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API request failed:\n  Status: %d\n  Body:   %s", resp.StatusCode, string(body))
+		return nil, protocoltypes.NewAPIError(resp, body)
 	}
 
 	return parseResponse(body)
 }
 
+// responseFormatParam builds the response_format field: a json_schema
+// structured output when a schema is given, otherwise JSON mode.
+func responseFormatParam(rf *ResponseFormat) map[string]any {
+	if rf.Schema == nil {
+		return map[string]any{"type": "json_object"}
+	}
+	return map[string]any{
+		"type": "json_schema",
+		"json_schema": map[string]any{
+			"name":   rf.OutputName(),
+			"schema": rf.Schema,
+			"strict": rf.Strict,
+		},
+	}
+}
+
 func parseResponse(body []byte) (*LLMResponse, error) {
 	var apiResponse struct {
 		Choices []struct {
 			Message struct {
-				Content   string `json:"content"`
-				ToolCalls []struct {
+				Content          string `json:"content"`
+				ReasoningContent string `json:"reasoning_content"`
+				Reasoning        string `json:"reasoning"`
+				ToolCalls        []struct {
 					ID       string `json:"id"`
 					Type     string `json:"type"`
 					Function *struct {
@@ -165,7 +226,7 @@ func parseResponse(body []byte) (*LLMResponse, error) {
 			} `json:"message"`
 			FinishReason string `json:"finish_reason"`
 		} `json:"choices"`
-		Usage *UsageInfo `json:"usage"`
+		Usage *apiUsage `json:"usage"`
 	}
 
 	if err := json.Unmarshal(body, &apiResponse); err != nil {
@@ -220,14 +281,86 @@ func parseResponse(body []byte) (*LLMResponse, error) {
 		toolCalls = append(toolCalls, toolCall)
 	}
 
+	// DeepSeek, Qwen and Kimi return reasoning_content; OpenRouter reasoning.
+	reasoning := choice.Message.ReasoningContent
+	if reasoning == "" {
+		reasoning = choice.Message.Reasoning
+	}
+
 	return &LLMResponse{
 		Content:      choice.Message.Content,
 		ToolCalls:    toolCalls,
 		FinishReason: choice.FinishReason,
-		Usage:        apiResponse.Usage,
+		Usage:        apiResponse.Usage.toUsageInfo(),
+		Reasoning:    reasoning,
 	}, nil
 }
 
+// wireMessage is a Message as sent to the Chat Completions API.
+type wireMessage struct {
+	Role             string     `json:"role"`
+	Content          string     `json:"content"`
+	ToolCalls        []ToolCall `json:"tool_calls,omitempty"`
+	ToolCallID       string     `json:"tool_call_id,omitempty"`
+	ReasoningContent string     `json:"reasoning_content,omitempty"`
+}
+
+// wireMessages converts messages for the request body. Reasoning is only sent
+// back on assistant tool calls of the current turn (after the last user
+// message): thinking models that call tools need it to continue, while some
+// reject it on earlier turns.
+func wireMessages(messages []Message) []wireMessage {
+	lastUser := -1
+	for i, m := range messages {
+		if m.Role == "user" {
+			lastUser = i
+		}
+	}
+
+	out := make([]wireMessage, len(messages))
+	for i, m := range messages {
+		out[i] = wireMessage{
+			Role:       m.Role,
+			Content:    m.Content,
+			ToolCalls:  m.ToolCalls,
+			ToolCallID: m.ToolCallID,
+		}
+		if i > lastUser && m.Role == "assistant" && len(m.ToolCalls) > 0 {
+			out[i].ReasoningContent = m.ReasoningContent
+		}
+	}
+	return out
+}
+
+// apiUsage is the usage block of a chat completion. Cached prompt tokens are
+// reported as prompt_tokens_details.cached_tokens by OpenAI and as
+// prompt_cache_hit_tokens by DeepSeek.
+type apiUsage struct {
+	PromptTokens        int `json:"prompt_tokens"`
+	CompletionTokens    int `json:"completion_tokens"`
+	TotalTokens         int `json:"total_tokens"`
+	PromptTokensDetails *struct {
+		CachedTokens int `json:"cached_tokens"`
+	} `json:"prompt_tokens_details"`
+	PromptCacheHitTokens int `json:"prompt_cache_hit_tokens"`
+}
+
+func (u *apiUsage) toUsageInfo() *UsageInfo {
+	if u == nil {
+		return nil
+	}
+	info := &UsageInfo{
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		TotalTokens:      u.TotalTokens,
+		CachedTokens:     u.PromptCacheHitTokens,
+	}
+	if u.PromptTokensDetails != nil && u.PromptTokensDetails.CachedTokens > 0 {
+		info.CachedTokens = u.PromptTokensDetails.CachedTokens
+	}
+	return info
+}
+
 func normalizeModel(model, apiBase string) string {
 	idx := strings.Index(model, "/")
 	if idx == -1 {
@@ -281,3 +281,156 @@ func TestNormalizeModel_UsesAPIBase(t *testing.T) {
 		t.Fatalf("normalizeModel(openrouter) = %q, want %q", got, "openrouter/auto")
 	}
 }
+
+func TestProviderChat_PromptCacheKeyAndCachedUsage(t *testing.T) {
+	var requestBody map[string]any
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestBody = nil
+		json.NewDecoder(r.Body).Decode(&requestBody)
+		resp := map[string]any{
+			"choices": []map[string]any{
+				{"message": map[string]any{"content": "ok"}, "finish_reason": "stop"},
+			},
+			"usage": map[string]any{
+				"prompt_tokens":         1200,
+				"completion_tokens":     10,
+				"total_tokens":          1210,
+				"prompt_tokens_details": map[string]any{"cached_tokens": 1024},
+			},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	options := map[string]any{"prompt_cache_key": "picoclaw-abc"}
+	messages := []Message{{Role: "user", Content: "hi"}}
+
+	// Unknown endpoints don't get the key unless enabled explicitly.
+	p := NewProvider("key", server.URL, "")
+	resp, err := p.Chat(t.Context(), messages, nil, "gpt-4o", options)
+	if err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	if _, ok := requestBody["prompt_cache_key"]; ok {
+		t.Error("did not expect prompt_cache_key for an unknown endpoint")
+	}
+	if resp.Usage == nil || resp.Usage.CachedTokens != 1024 || resp.Usage.PromptTokens != 1200 {
+		t.Errorf("Usage = %+v, want 1024 cached of 1200", resp.Usage)
+	}
+
+	p.SetPromptCache(true)
+	if _, err := p.Chat(t.Context(), messages, nil, "gpt-4o", options); err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	if requestBody["prompt_cache_key"] != "picoclaw-abc" {
+		t.Errorf("prompt_cache_key = %v, want picoclaw-abc", requestBody["prompt_cache_key"])
+	}
+
+	if !supportsPromptCacheKey("https://api.openai.com/v1") {
+		t.Error("expected api.openai.com to support prompt_cache_key")
+	}
+}
+
+func TestParseResponse_DeepSeekCacheHits(t *testing.T) {
+	body := `{"choices":[{"message":{"content":"ok"},"finish_reason":"stop"}],
+		"usage":{"prompt_tokens":500,"completion_tokens":5,"total_tokens":505,"prompt_cache_hit_tokens":384}}`
+	resp, err := parseResponse([]byte(body))
+	if err != nil {
+		t.Fatalf("parseResponse() error = %v", err)
+	}
+	if resp.Usage.CachedTokens != 384 {
+		t.Errorf("CachedTokens = %d, want 384", resp.Usage.CachedTokens)
+	}
+}
+
+func TestProviderChat_Reasoning(t *testing.T) {
+	var requestBody map[string]any
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&requestBody)
+		resp := map[string]any{
+			"choices": []map[string]any{
+				{
+					"message": map[string]any{
+						"content":           "42",
+						"reasoning_content": "Six times seven.",
+					},
+					"finish_reason": "stop",
+				},
+			},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	p := NewProvider("key", server.URL, "")
+	p.SetReasoning(0, "High")
+	messages := []Message{
+		{Role: "user", Content: "earlier"},
+		{Role: "assistant", Content: "", ReasoningContent: "old", ToolCalls: []ToolCall{{ID: "a", Type: "function"}}},
+		{Role: "tool", Content: "x", ToolCallID: "a"},
+		{Role: "assistant", Content: "done", ReasoningContent: "old answer"},
+		{Role: "user", Content: "now"},
+		{Role: "assistant", Content: "", ReasoningContent: "current", ToolCalls: []ToolCall{{ID: "b", Type: "function"}}},
+		{Role: "tool", Content: "y", ToolCallID: "b"},
+	}
+	resp, err := p.Chat(t.Context(), messages, nil, "deepseek-reasoner", nil)
+	if err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	if resp.Reasoning != "Six times seven." {
+		t.Errorf("Reasoning = %q", resp.Reasoning)
+	}
+	if requestBody["reasoning_effort"] != "high" {
+		t.Errorf("reasoning_effort = %v, want high", requestBody["reasoning_effort"])
+	}
+
+	sent := requestBody["messages"].([]any)
+	for i, m := range sent {
+		_, has := m.(map[string]any)["reasoning_content"]
+		if want := i == 5; has != want {
+			t.Errorf("message %d: reasoning_content present = %v, want %v", i, has, want)
+		}
+	}
+}
+
+func TestProviderChat_ResponseFormat(t *testing.T) {
+	var requestBody map[string]any
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&requestBody)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"content":"{\"ok\":true}"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	p := NewProvider("key", server.URL, "")
+	schema := map[string]any{"type": "object", "properties": map[string]any{"ok": map[string]any{"type": "boolean"}}}
+	_, err := p.Chat(t.Context(), []Message{{Role: "user", Content: "status?"}}, nil, "gpt-4o", map[string]any{
+		"response_format": &ResponseFormat{Name: "status", Schema: schema, Strict: true},
+	})
+	if err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+
+	rf, _ := requestBody["response_format"].(map[string]any)
+	if rf["type"] != "json_schema" {
+		t.Fatalf("response_format = %v, want json_schema", requestBody["response_format"])
+	}
+	js, _ := rf["json_schema"].(map[string]any)
+	if js["name"] != "status" || js["strict"] != true || js["schema"] == nil {
+		t.Errorf("json_schema = %v", js)
+	}
+
+	if _, err := p.Chat(t.Context(), []Message{{Role: "user", Content: "status?"}}, nil, "gpt-4o", map[string]any{
+		"response_format": &ResponseFormat{},
+	}); err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	if rf, _ := requestBody["response_format"].(map[string]any); rf["type"] != "json_object" {
+		t.Errorf("response_format = %v, want json_object without a schema", requestBody["response_format"])
+	}
+}
@@ -0,0 +1,56 @@
+package protocoltypes
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// APIError is a non-2xx response from a provider's HTTP API. Its message
+// format is what the fallback error classifier parses the status from.
+type APIError struct {
+	StatusCode int
+	Body       string
+	// RetryAfter is how long the server asked clients to wait, from a
+	// Retry-After or Retry-After-Ms header or a provider's own rate limit
+	// headers. Zero if it sent none.
+	RetryAfter time.Duration
+}
+
+// NewAPIError builds an APIError from an HTTP response and its body.
+func NewAPIError(resp *http.Response, body []byte) *APIError {
+	return &APIError{
+		StatusCode: resp.StatusCode,
+		Body:       string(body),
+		RetryAfter: ParseRetryAfter(resp.Header, time.Now()),
+	}
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("API request failed:\n  Status: %d\n  Body:   %s", e.StatusCode, e.Body)
+}
+
+// ParseRetryAfter reads the delay from a Retry-After-Ms header or a
+// Retry-After header in seconds or HTTP-date form.
+func ParseRetryAfter(h http.Header, now time.Time) time.Duration {
+	if v := h.Get("Retry-After-Ms"); v != "" {
+		if ms, err := strconv.ParseFloat(v, 64); err == nil && ms > 0 {
+			return time.Duration(ms * float64(time.Millisecond))
+		}
+	}
+	v := h.Get("Retry-After")
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.ParseFloat(v, 64); err == nil {
+		if secs <= 0 {
+			return 0
+		}
+		return time.Duration(secs * float64(time.Second))
+	}
+	if t, err := http.ParseTime(v); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
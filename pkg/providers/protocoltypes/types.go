@@ -29,12 +29,29 @@ type LLMResponse struct {
 	ToolCalls    []ToolCall `json:"tool_calls,omitempty"`
 	FinishReason string     `json:"finish_reason"`
 	Usage        *UsageInfo `json:"usage,omitempty"`
+	// Reasoning is the model's thinking as readable text, if it returned any.
+	Reasoning string `json:"reasoning,omitempty"`
+	// ThinkingBlocks are provider-signed thinking blocks that must be sent
+	// back unchanged with the assistant message when continuing a tool loop.
+	ThinkingBlocks []ThinkingBlock `json:"thinking_blocks,omitempty"`
+}
+
+// ThinkingBlock is an Anthropic thinking or redacted_thinking block.
+type ThinkingBlock struct {
+	Type      string `json:"type"` // "thinking" or "redacted_thinking"
+	Thinking  string `json:"thinking,omitempty"`
+	Signature string `json:"signature,omitempty"`
+	Data      string `json:"data,omitempty"` // Encrypted content of a redacted block
 }
 
 type UsageInfo struct {
 	PromptTokens     int `json:"prompt_tokens"`
 	CompletionTokens int `json:"completion_tokens"`
 	TotalTokens      int `json:"total_tokens"`
+	// CachedTokens is the part of PromptTokens read from the provider's
+	// prompt cache; CacheCreationTokens the part written to it.
+	CachedTokens        int `json:"cached_tokens,omitempty"`
+	CacheCreationTokens int `json:"cache_creation_tokens,omitempty"`
 }
 
 type Message struct {
@@ -42,6 +59,17 @@ type Message struct {
 	Content    string     `json:"content"`
 	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
 	ToolCallID string     `json:"tool_call_id,omitempty"`
+	// ReasoningContent and ThinkingBlocks carry an assistant turn's reasoning
+	// back to the provider that produced it.
+	ReasoningContent string          `json:"reasoning_content,omitempty"`
+	ThinkingBlocks   []ThinkingBlock `json:"thinking_blocks,omitempty"`
+	// Media holds local paths of attachments sent with this turn. It is not
+	// serialized: providers that support images read the files themselves.
+	Media []string `json:"-"`
+	// Volatile marks a message whose content changes between otherwise
+	// identical requests, such as the current time. The response cache
+	// leaves it out of the key. It is not serialized.
+	Volatile bool `json:"-"`
 }
 
 type ToolDefinition struct {
@@ -54,3 +82,22 @@ type ToolFunctionDefinition struct {
 	Description string         `json:"description"`
 	Parameters  map[string]any `json:"parameters"`
 }
+
+// ResponseFormat asks for a final answer that is a JSON value matching
+// Schema. It is passed to Chat as options["response_format"]. A nil Schema
+// asks for any JSON object.
+type ResponseFormat struct {
+	Name   string         `json:"name,omitempty"`
+	Schema map[string]any `json:"schema,omitempty"`
+	// Strict requests exact schema adherence where the provider supports it
+	// (OpenAI structured outputs require additionalProperties: false).
+	Strict bool `json:"strict,omitempty"`
+}
+
+// OutputName returns Name, or a default usable as a schema or tool name.
+func (f *ResponseFormat) OutputName() string {
+	if f == nil || f.Name == "" {
+		return "structured_output"
+	}
+	return f.Name
+}
@@ -0,0 +1,331 @@
+package providers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+const (
+	// A 429 asking to wait longer than this is returned to the caller so the
+	// fallback chain can move on instead of holding the request.
+	maxRetryAfterWait = time.Minute
+	maxRateLimitRetry = 2
+)
+
+// RateLimiter is a token bucket for requests and tokens per minute. Calls
+// queue in arrival order and wait until the buckets have room, the context
+// is done, or a server-requested Retry-After delay has passed.
+type RateLimiter struct {
+	model string
+	keyID string
+
+	turn chan struct{} // held by the caller at the head of the queue
+
+	mu           sync.Mutex
+	rpm, tpm     int
+	requests     float64 // available requests
+	tokens       float64 // available tokens; negative after an underestimate
+	last         time.Time
+	blockedUntil time.Time
+	waiting      int
+	nowFunc      func() time.Time // for testing
+}
+
+// RateLimitStatus is a snapshot of one limiter for status output.
+type RateLimitStatus struct {
+	Model        string    `json:"model"`
+	KeyID        string    `json:"key_id,omitempty"`
+	RPM          int       `json:"rpm,omitempty"`
+	TPM          int       `json:"tpm,omitempty"`
+	Waiting      int       `json:"waiting"`
+	Requests     int       `json:"available_requests"`
+	Tokens       int       `json:"available_tokens"`
+	BlockedUntil time.Time `json:"blocked_until,omitzero"`
+}
+
+// NewRateLimiter creates a limiter that starts with full buckets. A limit of
+// 0 is not enforced.
+func NewRateLimiter(rpm, tpm int) *RateLimiter {
+	l := &RateLimiter{
+		turn:    make(chan struct{}, 1),
+		nowFunc: time.Now,
+	}
+	l.setLimits(rpm, tpm)
+	return l
+}
+
+func (l *RateLimiter) setLimits(rpm, tpm int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.nowFunc()
+	if !l.last.IsZero() {
+		l.refill(now)
+	}
+	if rpm != l.rpm {
+		l.rpm, l.requests = rpm, float64(rpm)
+	}
+	if tpm != l.tpm {
+		l.tpm, l.tokens = tpm, float64(tpm)
+	}
+	l.last = now
+}
+
+// Wait blocks until a request of about estTokens tokens may be sent, and
+// takes it from the buckets.
+func (l *RateLimiter) Wait(ctx context.Context, estTokens int) error {
+	l.mu.Lock()
+	l.waiting++
+	l.mu.Unlock()
+	defer func() {
+		l.mu.Lock()
+		l.waiting--
+		l.mu.Unlock()
+	}()
+
+	select {
+	case l.turn <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-l.turn }()
+
+	for {
+		d := l.reserve(estTokens)
+		if d <= 0 {
+			return nil
+		}
+		timer := time.NewTimer(d)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// reserve takes one request and estTokens tokens if available, or returns
+// how long to wait before trying again.
+func (l *RateLimiter) reserve(estTokens int) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.nowFunc()
+	l.refill(now)
+	if now.Before(l.blockedUntil) {
+		return l.blockedUntil.Sub(now)
+	}
+
+	var wait time.Duration
+	if l.rpm > 0 && l.requests < 1 {
+		wait = perMinute(1-l.requests, l.rpm)
+	}
+	need := float64(min(estTokens, l.tpm))
+	if l.tpm > 0 && l.tokens < need {
+		wait = max(wait, perMinute(need-l.tokens, l.tpm))
+	}
+	if wait > 0 {
+		return wait
+	}
+
+	if l.rpm > 0 {
+		l.requests--
+	}
+	if l.tpm > 0 {
+		l.tokens -= float64(estTokens)
+	}
+	return 0
+}
+
+func (l *RateLimiter) refill(now time.Time) {
+	elapsed := now.Sub(l.last).Minutes()
+	if elapsed <= 0 {
+		return
+	}
+	l.last = now
+	l.requests = min(float64(l.rpm), l.requests+elapsed*float64(l.rpm))
+	l.tokens = min(float64(l.tpm), l.tokens+elapsed*float64(l.tpm))
+}
+
+// perMinute returns how long a bucket refilling limit units per minute takes
+// to gain amount units.
+func perMinute(amount float64, limit int) time.Duration {
+	return time.Duration(amount / float64(limit) * float64(time.Minute))
+}
+
+// Settle corrects the token bucket once the real usage of a request reserved
+// with estTokens is known.
+func (l *RateLimiter) Settle(estTokens, actualTokens int) {
+	if actualTokens <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.tpm > 0 {
+		l.tokens -= float64(actualTokens - estTokens)
+	}
+}
+
+// Backoff handles a 429 from the server. With a Retry-After delay, no call
+// is let through before it passes; without one, the request bucket is
+// emptied so calls slow to the configured rate.
+func (l *RateLimiter) Backoff(retryAfter time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.nowFunc()
+	if retryAfter > 0 {
+		if until := now.Add(retryAfter); until.After(l.blockedUntil) {
+			l.blockedUntil = until
+		}
+		return
+	}
+	l.refill(now)
+	l.requests = min(l.requests, 0)
+}
+
+// Status returns a snapshot of the limiter.
+func (l *RateLimiter) Status() RateLimitStatus {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.nowFunc()
+	l.refill(now)
+	st := RateLimitStatus{
+		Model:    l.model,
+		KeyID:    l.keyID,
+		RPM:      l.rpm,
+		TPM:      l.tpm,
+		Waiting:  l.waiting,
+		Requests: int(l.requests),
+		Tokens:   int(l.tokens),
+	}
+	if now.Before(l.blockedUntil) {
+		st.BlockedUntil = l.blockedUntil
+	}
+	return st
+}
+
+// rateLimiters holds one limiter per model and API key, shared by every
+// provider created for them so that reloads and load-balanced entries with
+// the same key draw from the same budget.
+var rateLimiters = struct {
+	sync.Mutex
+	m map[string]*RateLimiter
+}{m: make(map[string]*RateLimiter)}
+
+// sharedRateLimiter returns the limiter for model and apiKey, creating it or
+// updating its limits as needed.
+func sharedRateLimiter(model, apiKey string, rpm, tpm int) *RateLimiter {
+	keyID := ""
+	if apiKey != "" {
+		sum := sha256.Sum256([]byte(apiKey))
+		keyID = hex.EncodeToString(sum[:4])
+	}
+
+	rateLimiters.Lock()
+	defer rateLimiters.Unlock()
+	key := model + "|" + keyID
+	l := rateLimiters.m[key]
+	if l == nil {
+		l = NewRateLimiter(rpm, tpm)
+		l.model, l.keyID = model, keyID
+		rateLimiters.m[key] = l
+		return l
+	}
+	l.setLimits(rpm, tpm)
+	return l
+}
+
+// RateLimitStatuses returns the state of every limiter in this process,
+// sorted by model.
+func RateLimitStatuses() []RateLimitStatus {
+	rateLimiters.Lock()
+	limiters := make([]*RateLimiter, 0, len(rateLimiters.m))
+	for _, l := range rateLimiters.m {
+		limiters = append(limiters, l)
+	}
+	rateLimiters.Unlock()
+
+	out := make([]RateLimitStatus, 0, len(limiters))
+	for _, l := range limiters {
+		out = append(out, l.Status())
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Model != out[j].Model {
+			return out[i].Model < out[j].Model
+		}
+		return out[i].KeyID < out[j].KeyID
+	})
+	return out
+}
+
+// rateLimitedProvider queues Chat calls through a RateLimiter.
+type rateLimitedProvider struct {
+	LLMProvider
+	limiter *RateLimiter
+}
+
+func (p *rateLimitedProvider) Chat(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+) (*LLMResponse, error) {
+	est := estimateRequestTokens(messages, tools, options)
+	for attempt := 0; ; attempt++ {
+		if err := p.limiter.Wait(ctx, est); err != nil {
+			return nil, err
+		}
+		resp, err := p.LLMProvider.Chat(ctx, messages, tools, model, options)
+		if err == nil {
+			if resp != nil && resp.Usage != nil {
+				p.limiter.Settle(est, resp.Usage.TotalTokens)
+			}
+			return resp, nil
+		}
+
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusTooManyRequests {
+			return nil, err
+		}
+		p.limiter.Backoff(apiErr.RetryAfter)
+		if apiErr.RetryAfter <= 0 || apiErr.RetryAfter > maxRetryAfterWait || attempt >= maxRateLimitRetry {
+			return nil, err
+		}
+		logger.InfoCF("providers", "Rate limited, retrying after server delay", map[string]any{
+			"model":       model,
+			"retry_after": apiErr.RetryAfter.String(),
+		})
+	}
+}
+
+// estimateRequestTokens guesses the tokens a request will use: its text at
+// 2.5 characters per token plus the completion limit, if set.
+func estimateRequestTokens(messages []Message, tools []ToolDefinition, options map[string]any) int {
+	chars := 0
+	for _, m := range messages {
+		chars += utf8.RuneCountInString(m.Content)
+		for _, tc := range m.ToolCalls {
+			if tc.Function != nil {
+				chars += utf8.RuneCountInString(tc.Function.Arguments)
+			}
+		}
+	}
+	for _, t := range tools {
+		chars += utf8.RuneCountInString(t.Function.Description)
+	}
+	est := chars * 2 / 5
+	if n, ok := options["max_tokens"].(int); ok && n > 0 {
+		est += n
+	}
+	return est
+}
@@ -0,0 +1,194 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/providers/protocoltypes"
+)
+
+func newTestLimiter(rpm, tpm int, now *time.Time) *RateLimiter {
+	l := NewRateLimiter(rpm, tpm)
+	l.nowFunc = func() time.Time { return *now }
+	l.last = *now
+	return l
+}
+
+func TestRateLimiter_RequestBucket(t *testing.T) {
+	now := time.Now()
+	l := newTestLimiter(2, 0, &now)
+
+	if d := l.reserve(0); d != 0 {
+		t.Fatalf("first request waited %s", d)
+	}
+	if d := l.reserve(0); d != 0 {
+		t.Fatalf("second request waited %s", d)
+	}
+	if d := l.reserve(0); d != 30*time.Second {
+		t.Fatalf("third request wait = %s, want 30s at 2 rpm", d)
+	}
+
+	now = now.Add(30 * time.Second)
+	if d := l.reserve(0); d != 0 {
+		t.Errorf("expected a request to be available after refill, waited %s", d)
+	}
+}
+
+func TestRateLimiter_TokenBucket(t *testing.T) {
+	now := time.Now()
+	l := newTestLimiter(0, 600, &now)
+
+	if d := l.reserve(500); d != 0 {
+		t.Fatalf("first request waited %s", d)
+	}
+	// Only 100 tokens left; 300 more refill in 30s.
+	if d := l.reserve(400); d != 30*time.Second {
+		t.Errorf("wait = %s, want 30s", d)
+	}
+
+	// The first request used 200 more tokens than estimated.
+	l.Settle(500, 700)
+	if st := l.Status(); st.Tokens != -100 {
+		t.Errorf("tokens after settle = %d, want -100", st.Tokens)
+	}
+
+	// A request larger than the whole budget only waits for a full bucket.
+	now = now.Add(2 * time.Minute)
+	if d := l.reserve(5000); d != 0 {
+		t.Errorf("oversized request waited %s", d)
+	}
+}
+
+func TestRateLimiter_BackoffHonorsRetryAfter(t *testing.T) {
+	now := time.Now()
+	l := newTestLimiter(60, 0, &now)
+
+	l.Backoff(10 * time.Second)
+	if d := l.reserve(0); d != 10*time.Second {
+		t.Errorf("wait = %s, want the Retry-After delay", d)
+	}
+	if st := l.Status(); st.BlockedUntil.IsZero() {
+		t.Error("expected status to report the block")
+	}
+
+	now = now.Add(10 * time.Second)
+	l.Backoff(0)
+	if d := l.reserve(0); d != time.Second {
+		t.Errorf("wait after 429 without Retry-After = %s, want one request interval", d)
+	}
+}
+
+func TestRateLimiter_WaitQueuesAndCancels(t *testing.T) {
+	l := NewRateLimiter(1, 0)
+	if err := l.Wait(context.Background(), 0); err != nil {
+		t.Fatalf("first wait: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- l.Wait(ctx, 0) }()
+
+	deadline := time.Now().Add(time.Second)
+	for l.Status().Waiting != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the second call to be queued")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+	if st := l.Status(); st.Waiting != 0 {
+		t.Errorf("waiting = %d after cancel, want 0", st.Waiting)
+	}
+}
+
+type rateLimitedStub struct {
+	errs  []error
+	calls int
+}
+
+func (p *rateLimitedStub) Chat(
+	ctx context.Context, messages []Message, tools []ToolDefinition, model string, options map[string]any,
+) (*LLMResponse, error) {
+	p.calls++
+	if len(p.errs) > 0 {
+		err := p.errs[0]
+		p.errs = p.errs[1:]
+		return nil, err
+	}
+	return &LLMResponse{Content: "ok", Usage: &UsageInfo{TotalTokens: 10}}, nil
+}
+
+func (p *rateLimitedStub) GetDefaultModel() string { return "" }
+
+func TestRateLimitedProvider_RetriesAfterServerDelay(t *testing.T) {
+	stub := &rateLimitedStub{errs: []error{
+		&APIError{StatusCode: http.StatusTooManyRequests, RetryAfter: 10 * time.Millisecond},
+	}}
+	p := &rateLimitedProvider{LLMProvider: stub, limiter: NewRateLimiter(100, 0)}
+
+	resp, err := p.Chat(context.Background(), []Message{{Role: "user", Content: "hi"}}, nil, "m", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Content != "ok" || stub.calls != 2 {
+		t.Errorf("content/calls = %q/%d, want ok after one retry", resp.Content, stub.calls)
+	}
+}
+
+func TestRateLimitedProvider_ReturnsOtherErrors(t *testing.T) {
+	noDelay := &APIError{StatusCode: http.StatusTooManyRequests}
+	stub := &rateLimitedStub{errs: []error{noDelay}}
+	p := &rateLimitedProvider{LLMProvider: stub, limiter: NewRateLimiter(100, 0)}
+
+	_, err := p.Chat(context.Background(), nil, nil, "m", nil)
+	if !errors.Is(err, noDelay) || stub.calls != 1 {
+		t.Errorf("err/calls = %v/%d, want the 429 returned without retry", err, stub.calls)
+	}
+	if got := ClassifyError(err, "openai", "m"); got == nil || got.Reason != FailoverRateLimit {
+		t.Errorf("classified as %+v, want rate_limit", got)
+	}
+}
+
+func TestSharedRateLimiter_PerModelAndKey(t *testing.T) {
+	a := sharedRateLimiter("test/shared-model", "key-1", 10, 0)
+	if b := sharedRateLimiter("test/shared-model", "key-1", 20, 0); b != a {
+		t.Error("expected the same model and key to share a limiter")
+	}
+	if st := a.Status(); st.RPM != 20 {
+		t.Errorf("rpm = %d, want updated limit 20", st.RPM)
+	}
+	if c := sharedRateLimiter("test/shared-model", "key-2", 10, 0); c == a {
+		t.Error("expected another API key to get its own limiter")
+	}
+	for _, st := range RateLimitStatuses() {
+		if st.KeyID == "key-1" || st.KeyID == "key-2" {
+			t.Errorf("status exposes the raw API key: %+v", st)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name   string
+		header http.Header
+		want   time.Duration
+	}{
+		{"seconds", http.Header{"Retry-After": {"7"}}, 7 * time.Second},
+		{"milliseconds", http.Header{"Retry-After-Ms": {"250"}, "Retry-After": {"7"}}, 250 * time.Millisecond},
+		{"date", http.Header{"Retry-After": {now.Add(90 * time.Second).Format(http.TimeFormat)}}, 90 * time.Second},
+		{"past date", http.Header{"Retry-After": {now.Add(-time.Minute).Format(http.TimeFormat)}}, 0},
+		{"missing", http.Header{}, 0},
+	}
+	for _, tt := range tests {
+		if got := protocoltypes.ParseRetryAfter(tt.header, now); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,288 @@
+package providers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// OptionNoCache, set to true in Chat options, bypasses the response cache
+// for that call: the cache is neither read nor written.
+const OptionNoCache = "no_cache"
+
+type noCacheKey struct{}
+
+// WithNoCache marks ctx so that Chat calls made with it bypass the response
+// cache, like OptionNoCache. Cron jobs and heartbeat tasks with no_cache set
+// run their agent turns under such a context.
+func WithNoCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noCacheKey{}, true)
+}
+
+// NoCache reports whether ctx was marked by WithNoCache.
+func NoCache(ctx context.Context) bool {
+	noCache, _ := ctx.Value(noCacheKey{}).(bool)
+	return noCache
+}
+
+const (
+	defaultResponseCacheTTL        = time.Hour
+	defaultResponseCacheMaxEntries = 1000
+)
+
+// ResponseCache stores LLM responses on disk, one JSON file per request
+// key, with a TTL and a maximum number of entries. Thread-safe.
+type ResponseCache struct {
+	dir        string
+	ttl        time.Duration
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[string]time.Time // key -> creation time
+	loaded  bool
+	nowFunc func() time.Time // for testing
+}
+
+type cachedResponse struct {
+	CreatedAt time.Time    `json:"created_at"`
+	Model     string       `json:"model"`
+	Response  *LLMResponse `json:"response"`
+}
+
+// NewResponseCache creates a cache in dir. Zero ttl or maxEntries use the
+// defaults of one hour and 1000 entries.
+func NewResponseCache(dir string, ttl time.Duration, maxEntries int) *ResponseCache {
+	if ttl <= 0 {
+		ttl = defaultResponseCacheTTL
+	}
+	if maxEntries <= 0 {
+		maxEntries = defaultResponseCacheMaxEntries
+	}
+	return &ResponseCache{
+		dir:        dir,
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[string]time.Time),
+		nowFunc:    time.Now,
+	}
+}
+
+// Get returns the cached response for key, or nil if there is none or it
+// has expired.
+func (c *ResponseCache) Get(key string) *LLMResponse {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.load()
+
+	created, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	if c.nowFunc().Sub(created) > c.ttl {
+		c.remove(key)
+		return nil
+	}
+
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		delete(c.entries, key)
+		return nil
+	}
+	var entry cachedResponse
+	if err := json.Unmarshal(data, &entry); err != nil || entry.Response == nil {
+		c.remove(key)
+		return nil
+	}
+	return entry.Response
+}
+
+// Put stores resp under key, evicting the oldest entries beyond the limit.
+func (c *ResponseCache) Put(key, model string, resp *LLMResponse) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.load()
+
+	now := c.nowFunc()
+	data, err := json.Marshal(cachedResponse{CreatedAt: now, Model: model, Response: resp})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return err
+	}
+	tmp := c.path(key) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, c.path(key)); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	c.entries[key] = now
+	c.evict(now)
+	return nil
+}
+
+// load indexes the entries already on disk, once.
+func (c *ResponseCache) load() {
+	if c.loaded {
+		return
+	}
+	c.loaded = true
+
+	files, err := os.ReadDir(c.dir)
+	if err != nil {
+		return
+	}
+	for _, f := range files {
+		key, ok := strings.CutSuffix(f.Name(), ".json")
+		if !ok || f.IsDir() {
+			continue
+		}
+		info, err := f.Info()
+		if err != nil {
+			continue
+		}
+		c.entries[key] = info.ModTime()
+	}
+	c.evict(c.nowFunc())
+}
+
+// evict drops expired entries, then the oldest ones beyond maxEntries.
+func (c *ResponseCache) evict(now time.Time) {
+	for key, created := range c.entries {
+		if now.Sub(created) > c.ttl {
+			c.remove(key)
+		}
+	}
+	if len(c.entries) <= c.maxEntries {
+		return
+	}
+
+	keys := make([]string, 0, len(c.entries))
+	for key := range c.entries {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return c.entries[keys[i]].Before(c.entries[keys[j]]) })
+	for _, key := range keys[:len(keys)-c.maxEntries] {
+		c.remove(key)
+	}
+}
+
+func (c *ResponseCache) remove(key string) {
+	delete(c.entries, key)
+	os.Remove(c.path(key))
+}
+
+func (c *ResponseCache) path(key string) string {
+	return filepath.Join(c.dir, key+".json")
+}
+
+// ResponseCacheKey hashes everything that determines a response: model,
+// messages, tools and options. Options that do not affect the answer, such
+// as the prompt cache key, are left out, and so are volatile messages like
+// the current time, so scheduled runs a minute apart share a key.
+func ResponseCacheKey(model string, messages []Message, tools []ToolDefinition, options map[string]any) string {
+	opts := make(map[string]any, len(options))
+	for k, v := range options {
+		if k == "prompt_cache_key" || k == OptionNoCache {
+			continue
+		}
+		opts[k] = v
+	}
+	// encoding/json sorts map keys, so equal requests hash equally.
+	data, _ := json.Marshal(struct {
+		Model    string           `json:"model"`
+		Messages []Message        `json:"messages"`
+		Tools    []ToolDefinition `json:"tools"`
+		Options  map[string]any   `json:"options"`
+	}{model, withoutVolatile(messages), tools, opts})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// withoutVolatile returns messages without the volatile ones. The input is
+// not modified.
+func withoutVolatile(messages []Message) []Message {
+	if !slices.ContainsFunc(messages, func(m Message) bool { return m.Volatile }) {
+		return messages
+	}
+	out := make([]Message, 0, len(messages))
+	for _, m := range messages {
+		if !m.Volatile {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// cachingProvider answers repeated identical requests from a ResponseCache.
+type cachingProvider struct {
+	LLMProvider
+	cache   *ResponseCache
+	sampled bool // also cache requests with temperature > 0
+}
+
+// NewCachingProvider wraps provider with cache. Requests with a temperature
+// above 0 are only cached if sampled is true, since their answers are
+// expected to vary.
+func NewCachingProvider(provider LLMProvider, cache *ResponseCache, sampled bool) LLMProvider {
+	return &cachingProvider{LLMProvider: provider, cache: cache, sampled: sampled}
+}
+
+func (p *cachingProvider) Chat(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+) (*LLMResponse, error) {
+	if NoCache(ctx) || !p.cacheable(messages, options) {
+		return p.LLMProvider.Chat(ctx, messages, tools, model, options)
+	}
+
+	key := ResponseCacheKey(model, messages, tools, options)
+	if resp := p.cache.Get(key); resp != nil {
+		logger.DebugCF("providers", "Response cache hit", map[string]any{"model": model, "key": key[:12]})
+		resp.Usage = nil // nothing was spent on this answer
+		return resp, nil
+	}
+
+	resp, err := p.LLMProvider.Chat(ctx, messages, tools, model, options)
+	if err != nil || resp == nil {
+		return resp, err
+	}
+	if err := p.cache.Put(key, model, resp); err != nil {
+		logger.WarnCF("providers", "Failed to store cached response", map[string]any{"error": err.Error()})
+	}
+	return resp, nil
+}
+
+func (p *cachingProvider) cacheable(messages []Message, options map[string]any) bool {
+	if noCache, _ := options[OptionNoCache].(bool); noCache {
+		return false
+	}
+	// Attachments are files on disk that the key does not cover.
+	for _, m := range messages {
+		if len(m.Media) > 0 {
+			return false
+		}
+	}
+	if p.sampled {
+		return true
+	}
+	// Without a temperature the provider default applies, which is
+	// usually above 0.
+	t, ok := asNumber(options["temperature"])
+	return ok && t <= 0
+}
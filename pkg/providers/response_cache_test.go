@@ -0,0 +1,161 @@
+package providers
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type countingProvider struct {
+	calls int
+}
+
+func (p *countingProvider) Chat(
+	ctx context.Context, messages []Message, tools []ToolDefinition, model string, options map[string]any,
+) (*LLMResponse, error) {
+	p.calls++
+	return &LLMResponse{Content: "answer", FinishReason: "stop", Usage: &UsageInfo{TotalTokens: 42}}, nil
+}
+
+func (p *countingProvider) GetDefaultModel() string { return "" }
+
+func TestResponseCacheKey(t *testing.T) {
+	msgs := []Message{{Role: "user", Content: "daily report"}}
+	base := ResponseCacheKey("gpt-4", msgs, nil, map[string]any{"temperature": 0.0, "max_tokens": 100})
+
+	same := ResponseCacheKey("gpt-4", msgs, nil, map[string]any{
+		"max_tokens": 100, "temperature": 0.0, "prompt_cache_key": "agent-main", OptionNoCache: false,
+	})
+	if same != base {
+		t.Error("expected prompt_cache_key and no_cache to not affect the key")
+	}
+	if ResponseCacheKey("gpt-4o", msgs, nil, map[string]any{"temperature": 0.0, "max_tokens": 100}) == base {
+		t.Error("expected the model to affect the key")
+	}
+	if ResponseCacheKey("gpt-4", msgs, nil, map[string]any{"temperature": 0.0, "max_tokens": 200}) == base {
+		t.Error("expected options to affect the key")
+	}
+	tools := []ToolDefinition{{Type: "function", Function: ToolFunctionDefinition{Name: "exec"}}}
+	if ResponseCacheKey("gpt-4", msgs, tools, map[string]any{"temperature": 0.0, "max_tokens": 100}) == base {
+		t.Error("expected tools to affect the key")
+	}
+
+	at := func(now, user string) string {
+		return ResponseCacheKey("gpt-4", []Message{
+			{Role: "system", Content: "You are helpful."},
+			{Role: "system", Content: "## Current Time\n" + now, Volatile: true},
+			{Role: "user", Content: user},
+		}, nil, nil)
+	}
+	if at("2026-10-18 09:00 (Sunday)", "Check the disk.") != at("2026-10-18 09:01 (Sunday)", "Check the disk.") {
+		t.Error("expected volatile messages to not affect the key")
+	}
+	if at("2026-10-18 09:00 (Sunday)", "Current time: 09:00") == at("2026-10-18 09:00 (Sunday)", "Current time: 10:00") {
+		t.Error("expected a time in the user message to affect the key")
+	}
+}
+
+func TestResponseCache_TTLAndPersistence(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	c := NewResponseCache(dir, time.Minute, 10)
+	c.nowFunc = func() time.Time { return now }
+
+	if err := c.Put("k1", "gpt-4", &LLMResponse{Content: "cached"}); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if got := c.Get("k1"); got == nil || got.Content != "cached" {
+		t.Fatalf("Get = %+v, want cached response", got)
+	}
+
+	// A new cache on the same directory finds the entry on disk.
+	reopened := NewResponseCache(dir, time.Minute, 10)
+	if got := reopened.Get("k1"); got == nil || got.Content != "cached" {
+		t.Errorf("reopened Get = %+v, want cached response", got)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if got := c.Get("k1"); got != nil {
+		t.Errorf("expected expired entry to be dropped, got %+v", got)
+	}
+}
+
+func TestResponseCache_EvictsOldest(t *testing.T) {
+	now := time.Now()
+	c := NewResponseCache(t.TempDir(), time.Hour, 2)
+	c.nowFunc = func() time.Time { return now }
+
+	for _, key := range []string{"a", "b", "c"} {
+		if err := c.Put(key, "m", &LLMResponse{Content: key}); err != nil {
+			t.Fatalf("Put(%s): %v", key, err)
+		}
+		now = now.Add(time.Second)
+	}
+	if c.Get("a") != nil {
+		t.Error("expected the oldest entry to be evicted")
+	}
+	if c.Get("b") == nil || c.Get("c") == nil {
+		t.Error("expected the newest entries to stay")
+	}
+}
+
+func TestCachingProvider(t *testing.T) {
+	msgs := []Message{{Role: "user", Content: "heartbeat"}}
+
+	tests := []struct {
+		name      string
+		sampled   bool
+		options   map[string]any
+		messages  []Message
+		wantCalls int
+	}{
+		{"deterministic", false, map[string]any{"temperature": 0.0}, msgs, 1},
+		{"sampled skipped", false, map[string]any{"temperature": 0.7}, msgs, 2},
+		{"no temperature skipped", false, map[string]any{}, msgs, 2},
+		{"sampled enabled", true, map[string]any{"temperature": 0.7}, msgs, 1},
+		{"bypass", true, map[string]any{"temperature": 0.0, OptionNoCache: true}, msgs, 2},
+		{"media skipped", true, map[string]any{"temperature": 0.0},
+			[]Message{{Role: "user", Content: "what is this?", Media: []string{"/tmp/a.png"}}}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner := &countingProvider{}
+			p := NewCachingProvider(inner, NewResponseCache(t.TempDir(), 0, 0), tt.sampled)
+
+			first, err := p.Chat(context.Background(), tt.messages, nil, "gpt-4", tt.options)
+			if err != nil {
+				t.Fatalf("first Chat: %v", err)
+			}
+			second, err := p.Chat(context.Background(), tt.messages, nil, "gpt-4", tt.options)
+			if err != nil {
+				t.Fatalf("second Chat: %v", err)
+			}
+			if inner.calls != tt.wantCalls {
+				t.Errorf("provider calls = %d, want %d", inner.calls, tt.wantCalls)
+			}
+			if second.Content != first.Content {
+				t.Errorf("content = %q, want %q", second.Content, first.Content)
+			}
+			if tt.wantCalls == 1 && second.Usage != nil {
+				t.Errorf("cache hit reported usage %+v, want none", second.Usage)
+			}
+		})
+	}
+}
+
+func TestCachingProvider_NoCacheContext(t *testing.T) {
+	inner := &countingProvider{}
+	p := NewCachingProvider(inner, NewResponseCache(t.TempDir(), 0, 0), false)
+	msgs := []Message{{Role: "user", Content: "check the news"}}
+	opts := map[string]any{"temperature": 0.0}
+
+	ctx := WithNoCache(context.Background())
+	for range 2 {
+		if _, err := p.Chat(ctx, msgs, nil, "gpt-4", opts); err != nil {
+			t.Fatalf("Chat: %v", err)
+		}
+	}
+	if inner.calls != 2 {
+		t.Errorf("provider calls = %d, want every call under WithNoCache to reach the provider", inner.calls)
+	}
+}
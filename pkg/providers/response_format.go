@@ -0,0 +1,307 @@
+package providers
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// StructuredOutputInstruction tells the model the answer format. It backs up
+// native enforcement and is all that providers without it get.
+func StructuredOutputInstruction(rf *ResponseFormat) string {
+	if rf == nil {
+		return ""
+	}
+	if rf.Schema == nil {
+		return "Respond with only a JSON object, without any other text or code fences."
+	}
+	schema, _ := json.Marshal(rf.Schema)
+	return "Respond with only a JSON value matching this JSON Schema, without any other text or code fences:\n" +
+		string(schema)
+}
+
+// ParseStructuredOutput extracts the JSON answer from content and validates
+// it against rf.Schema. It returns the JSON text without surrounding prose or
+// code fences.
+func ParseStructuredOutput(content string, rf *ResponseFormat) (string, error) {
+	raw, value, err := extractJSON(content)
+	if err != nil {
+		return "", err
+	}
+	if rf == nil || rf.Schema == nil {
+		if _, ok := value.(map[string]any); !ok {
+			return "", fmt.Errorf("$: expected a JSON object")
+		}
+		return raw, nil
+	}
+	if err := validateSchema(value, rf.Schema, "$"); err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+// extractJSON finds the JSON value in a model answer, which may be wrapped in
+// a code fence or a sentence despite instructions.
+func extractJSON(content string) (string, any, error) {
+	s := strings.TrimSpace(content)
+	if strings.HasPrefix(s, "```") {
+		s = strings.TrimPrefix(s, "```")
+		if i := strings.IndexByte(s, '\n'); i >= 0 {
+			s = s[i+1:] // drop the language tag
+		}
+		s = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(s), "```"))
+	}
+
+	var value any
+	if err := json.Unmarshal([]byte(s), &value); err == nil {
+		return s, value, nil
+	}
+
+	start := strings.IndexAny(s, "{[")
+	if start >= 0 {
+		closer := "}"
+		if s[start] == '[' {
+			closer = "]"
+		}
+		if end := strings.LastIndex(s, closer); end > start {
+			candidate := s[start : end+1]
+			if err := json.Unmarshal([]byte(candidate), &value); err == nil {
+				return candidate, value, nil
+			}
+		}
+	}
+	return "", nil, fmt.Errorf("response is not valid JSON")
+}
+
+// validateSchema checks v against the commonly used subset of JSON Schema:
+// type, enum, const, properties, required, additionalProperties, items,
+// length and range bounds, and anyOf/oneOf.
+func validateSchema(v any, schema map[string]any, path string) error {
+	if types := schemaTypes(schema["type"]); len(types) > 0 {
+		matched := false
+		for _, t := range types {
+			if matchesType(v, t) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return fmt.Errorf("%s: expected %s, got %s", path, strings.Join(types, " or "), jsonType(v))
+		}
+	}
+
+	if enum, ok := schema["enum"].([]any); ok {
+		found := false
+		for _, e := range enum {
+			if jsonEqual(v, e) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: value must be one of %v", path, enum)
+		}
+	}
+	if c, ok := schema["const"]; ok && !jsonEqual(v, c) {
+		return fmt.Errorf("%s: value must be %v", path, c)
+	}
+
+	for _, key := range []string{"anyOf", "oneOf"} {
+		options, ok := schema[key].([]any)
+		if !ok {
+			continue
+		}
+		var firstErr error
+		matched := 0
+		for _, opt := range options {
+			sub, _ := opt.(map[string]any)
+			err := validateSchema(v, sub, path)
+			if err == nil {
+				matched++
+				if key == "anyOf" {
+					break
+				}
+				continue
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+		if matched == 0 && firstErr != nil {
+			return firstErr
+		}
+		if key == "oneOf" && matched > 1 {
+			return fmt.Errorf("%s: value matches %d oneOf options, expected exactly one", path, matched)
+		}
+	}
+
+	switch val := v.(type) {
+	case map[string]any:
+		return validateObject(val, schema, path)
+	case []any:
+		if n, ok := asNumber(schema["minItems"]); ok && float64(len(val)) < n {
+			return fmt.Errorf("%s: expected at least %v items", path, n)
+		}
+		if n, ok := asNumber(schema["maxItems"]); ok && float64(len(val)) > n {
+			return fmt.Errorf("%s: expected at most %v items", path, n)
+		}
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range val {
+				if err := validateSchema(item, items, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case string:
+		length := float64(len([]rune(val)))
+		if n, ok := asNumber(schema["minLength"]); ok && length < n {
+			return fmt.Errorf("%s: expected at least %v characters", path, n)
+		}
+		if n, ok := asNumber(schema["maxLength"]); ok && length > n {
+			return fmt.Errorf("%s: expected at most %v characters", path, n)
+		}
+	case float64:
+		if n, ok := asNumber(schema["minimum"]); ok && val < n {
+			return fmt.Errorf("%s: must be >= %v", path, n)
+		}
+		if n, ok := asNumber(schema["maximum"]); ok && val > n {
+			return fmt.Errorf("%s: must be <= %v", path, n)
+		}
+	}
+	return nil
+}
+
+func validateObject(obj map[string]any, schema map[string]any, path string) error {
+	props, _ := schema["properties"].(map[string]any)
+
+	for _, r := range schemaStrings(schema["required"]) {
+		if _, ok := obj[r]; !ok {
+			return fmt.Errorf("%s: missing required property %q", path, r)
+		}
+	}
+
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		child := path + "." + k
+		if sub, ok := props[k].(map[string]any); ok {
+			if err := validateSchema(obj[k], sub, child); err != nil {
+				return err
+			}
+			continue
+		}
+		switch extra := schema["additionalProperties"].(type) {
+		case bool:
+			if !extra {
+				return fmt.Errorf("%s: unexpected property %q", path, k)
+			}
+		case map[string]any:
+			if err := validateSchema(obj[k], extra, child); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func schemaTypes(t any) []string {
+	switch val := t.(type) {
+	case string:
+		return []string{val}
+	default:
+		return schemaStrings(val)
+	}
+}
+
+// schemaStrings reads a string list from a schema decoded from JSON ([]any)
+// or built in Go ([]string).
+func schemaStrings(v any) []string {
+	switch val := v.(type) {
+	case []string:
+		return val
+	case []any:
+		out := make([]string, 0, len(val))
+		for _, s := range val {
+			if str, ok := s.(string); ok {
+				out = append(out, str)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func matchesType(v any, t string) bool {
+	switch t {
+	case "object":
+		_, ok := v.(map[string]any)
+		return ok
+	case "array":
+		_, ok := v.([]any)
+		return ok
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "number":
+		_, ok := v.(float64)
+		return ok
+	case "integer":
+		f, ok := v.(float64)
+		return ok && f == math.Trunc(f)
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "null":
+		return v == nil
+	}
+	return true
+}
+
+func jsonType(v any) string {
+	switch v.(type) {
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case nil:
+		return "null"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// jsonEqual compares a decoded value with one from the schema, which may use
+// Go number types if the schema was built in code.
+func jsonEqual(a, b any) bool {
+	if n, ok := asNumber(b); ok {
+		f, isNum := a.(float64)
+		return isNum && f == n
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func asNumber(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	}
+	return 0, false
+}
@@ -0,0 +1,75 @@
+package providers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseStructuredOutput(t *testing.T) {
+	rf := &ResponseFormat{Schema: map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"status": map[string]any{"type": "string", "enum": []any{"ok", "down"}},
+			"count":  map[string]any{"type": "integer", "minimum": 0},
+			"tags":   map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+			"limit": map[string]any{"oneOf": []any{
+				map[string]any{"type": "integer"},
+				map[string]any{"type": "number", "minimum": 10},
+			}},
+		},
+		"required":             []string{"status", "count"},
+		"additionalProperties": false,
+	}}
+
+	tests := []struct {
+		name    string
+		content string
+		want    string
+		wantErr string
+	}{
+		{name: "plain", content: `{"status":"ok","count":3}`, want: `{"status":"ok","count":3}`},
+		{
+			name:    "fenced",
+			content: "```json\n{\"status\":\"down\",\"count\":0,\"tags\":[\"db\"]}\n```",
+			want:    `{"status":"down","count":0,"tags":["db"]}`,
+		},
+		{name: "prose", content: `Here you go: {"status":"ok","count":1} Hope it helps.`, want: `{"status":"ok","count":1}`},
+		{name: "not json", content: "all good", wantErr: "not valid JSON"},
+		{name: "missing", content: `{"status":"ok"}`, wantErr: `missing required property "count"`},
+		{name: "enum", content: `{"status":"meh","count":1}`, wantErr: "$.status: value must be one of"},
+		{name: "integer", content: `{"status":"ok","count":1.5}`, wantErr: "$.count: expected integer"},
+		{name: "minimum", content: `{"status":"ok","count":-1}`, wantErr: "$.count: must be >= 0"},
+		{name: "items", content: `{"status":"ok","count":1,"tags":[1]}`, wantErr: "$.tags[0]: expected string"},
+		{name: "oneOf", content: `{"status":"ok","count":1,"limit":2}`, want: `{"status":"ok","count":1,"limit":2}`},
+		{name: "oneOf none", content: `{"status":"ok","count":1,"limit":2.5}`, wantErr: "$.limit: expected integer"},
+		{name: "oneOf both", content: `{"status":"ok","count":1,"limit":20}`, wantErr: "$.limit: value matches 2 oneOf"},
+		{name: "extra", content: `{"status":"ok","count":1,"x":true}`, wantErr: `unexpected property "x"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseStructuredOutput(tt.content, rf)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseStructuredOutput_NoSchemaRequiresObject(t *testing.T) {
+	if _, err := ParseStructuredOutput(`{"a":1}`, &ResponseFormat{}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := ParseStructuredOutput(`[1,2]`, &ResponseFormat{}); err == nil {
+		t.Error("expected an error for a non-object answer")
+	}
+}
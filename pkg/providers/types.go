@@ -17,6 +17,9 @@ type (
 	ToolFunctionDefinition = protocoltypes.ToolFunctionDefinition
 	ExtraContent           = protocoltypes.ExtraContent
 	GoogleExtra            = protocoltypes.GoogleExtra
+	ThinkingBlock          = protocoltypes.ThinkingBlock
+	ResponseFormat         = protocoltypes.ResponseFormat
+	APIError               = protocoltypes.APIError
 )
 
 type LLMProvider interface {
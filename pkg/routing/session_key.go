@@ -42,6 +42,12 @@ func BuildAgentMainSessionKey(agentID string) string {
 	return fmt.Sprintf("agent:%s:%s", NormalizeAgentID(agentID), DefaultMainKey)
 }
 
+// BuildSubagentSessionKey returns "agent:<agentId>:subagent:<taskId>", the
+// session that holds the transcript of one subagent run.
+func BuildSubagentSessionKey(agentID, taskID string) string {
+	return fmt.Sprintf("agent:%s:subagent:%s", NormalizeAgentID(agentID), strings.ToLower(strings.TrimSpace(taskID)))
+}
+
 // BuildAgentPeerSessionKey constructs a session key based on agent, channel, peer, and DM scope.
 func BuildAgentPeerSessionKey(params SessionKeyParams) string {
 	agentID := NormalizeAgentID(params.AgentID)
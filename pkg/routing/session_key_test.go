@@ -160,3 +160,11 @@ func TestIsSubagentSessionKey(t *testing.T) {
 		}
 	}
 }
+
+func TestBuildSubagentSessionKey(t *testing.T) {
+	got := BuildSubagentSessionKey("Sales Bot", "subagent-3")
+	want := "agent:sales-bot:subagent:subagent-3"
+	if got != want {
+		t.Errorf("BuildSubagentSessionKey = %q, want %q", got, want)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -59,6 +60,36 @@ func (sm *SessionManager) GetOrCreate(key string) *Session {
 	return session
 }
 
+// SessionInfo is a lightweight description of a stored session.
+type SessionInfo struct {
+	Key          string    `json:"key"`
+	MessageCount int       `json:"message_count"`
+	HasSummary   bool      `json:"has_summary"`
+	Created      time.Time `json:"created"`
+	Updated      time.Time `json:"updated"`
+}
+
+// ListSessions returns info for all sessions, most recently updated first.
+func (sm *SessionManager) ListSessions() []SessionInfo {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	infos := make([]SessionInfo, 0, len(sm.sessions))
+	for _, s := range sm.sessions {
+		infos = append(infos, SessionInfo{
+			Key:          s.Key,
+			MessageCount: len(s.Messages),
+			HasSummary:   s.Summary != "",
+			Created:      s.Created,
+			Updated:      s.Updated,
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Updated.After(infos[j].Updated)
+	})
+	return infos
+}
+
 func (sm *SessionManager) AddMessage(sessionKey, role, content string) {
 	sm.AddFullMessage(sessionKey, providers.Message{
 		Role:    role,
@@ -17,6 +17,15 @@ type ContextualTool interface {
 	SetContext(channel, chatID string)
 }
 
+// CloneableTool is an optional interface for tools that keep per-run state,
+// such as the chat they reply to or whether they sent a message this round.
+// Clone returns an instance with the same configuration and fresh state, so
+// a subagent run never shares that state with the agent that spawned it.
+type CloneableTool interface {
+	Tool
+	Clone() Tool
+}
+
 // AsyncCallback is a function type that async tools use to notify completion.
 // When an async tool finishes its work, it calls this callback with the result.
 //
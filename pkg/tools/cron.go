@@ -9,12 +9,16 @@ import (
 	"github.com/sipeed/picoclaw/pkg/bus"
 	"github.com/sipeed/picoclaw/pkg/config"
 	"github.com/sipeed/picoclaw/pkg/cron"
+	"github.com/sipeed/picoclaw/pkg/providers"
 	"github.com/sipeed/picoclaw/pkg/utils"
 )
 
 // JobExecutor is the interface for executing cron jobs through the agent
 type JobExecutor interface {
-	ProcessDirectWithChannel(ctx context.Context, content, sessionKey, channel, chatID string) (string, error)
+	ProcessDirectWithChannel(ctx context.Context, content, sessionKey, channel, chatID, senderID string) (string, error)
+	ProcessDirectStructured(
+		ctx context.Context, content, sessionKey, channel, chatID, senderID string, format *providers.ResponseFormat,
+	) (string, error)
 }
 
 // CronTool provides scheduling capabilities for the agent
@@ -92,6 +96,14 @@ func (t *CronTool) Parameters() map[string]any {
 				"type":        "boolean",
 				"description": "If true, send message directly to channel. If false, let agent process message (for complex tasks). Default: true",
 			},
+			"no_cache": map[string]any{
+				"type":        "boolean",
+				"description": "If true, the agent always asks the model for this job instead of reusing a cached answer. Use it for jobs whose answer depends on fresh data, such as checking news.",
+			},
+			"response_schema": map[string]any{
+				"type":        "object",
+				"description": "Optional: JSON Schema the result must match. The agent processes the message and the job outputs its answer as JSON. Implies deliver=false.",
+			},
 		},
 		"required": []string{"action"},
 	}
@@ -105,6 +117,17 @@ func (t *CronTool) SetContext(channel, chatID string) {
 	t.chatID = chatID
 }
 
+// Clone returns a cron tool sharing the service and executor, with no
+// session context.
+func (t *CronTool) Clone() Tool {
+	return &CronTool{
+		cronService: t.cronService,
+		executor:    t.executor,
+		msgBus:      t.msgBus,
+		execTool:    t.execTool,
+	}
+}
+
 // Execute runs the tool with the given arguments
 func (t *CronTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
 	action, ok := args["action"].(string)
@@ -187,6 +210,13 @@ func (t *CronTool) addJob(args map[string]any) *ToolResult {
 		deliver = false
 	}
 
+	responseSchema, _ := args["response_schema"].(map[string]any)
+	if responseSchema != nil {
+		deliver = false
+	}
+
+	noCache, _ := args["no_cache"].(bool)
+
 	// Truncate message for job name (max 30 chars)
 	messagePreview := utils.Truncate(message, 30)
 
@@ -202,8 +232,10 @@ func (t *CronTool) addJob(args map[string]any) *ToolResult {
 		return ErrorResult(fmt.Sprintf("Error adding job: %v", err))
 	}
 
-	if command != "" {
+	if command != "" || responseSchema != nil || noCache {
 		job.Payload.Command = command
+		job.Payload.ResponseSchema = responseSchema
+		job.Payload.NoCache = noCache
 		// Need to save the updated payload
 		t.cronService.UpdateJob(job)
 	}
@@ -314,6 +346,31 @@ func (t *CronTool) ExecuteJob(ctx context.Context, job *cron.CronJob) string {
 
 	// For deliver=false, process through agent (for complex tasks)
 	sessionKey := fmt.Sprintf("cron-%s", job.ID)
+	if job.Payload.NoCache {
+		ctx = providers.WithNoCache(ctx)
+	}
+
+	// Structured jobs deliver the agent's JSON answer as their output
+	if job.Payload.ResponseSchema != nil {
+		output, err := t.executor.ProcessDirectStructured(
+			ctx,
+			job.Payload.Message,
+			sessionKey,
+			channel,
+			chatID,
+			"cron",
+			&providers.ResponseFormat{Name: "cron_result", Schema: job.Payload.ResponseSchema},
+		)
+		if err != nil {
+			return fmt.Sprintf("Error: %v", err)
+		}
+		t.msgBus.PublishOutbound(bus.OutboundMessage{
+			Channel: channel,
+			ChatID:  chatID,
+			Content: output,
+		})
+		return output
+	}
 
 	// Call agent with job's message
 	response, err := t.executor.ProcessDirectWithChannel(
@@ -322,6 +379,7 @@ func (t *CronTool) ExecuteJob(ctx context.Context, job *cron.CronJob) string {
 		sessionKey,
 		channel,
 		chatID,
+		"cron",
 	)
 	if err != nil {
 		return fmt.Sprintf("Error: %v", err)
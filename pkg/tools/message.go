@@ -3,15 +3,24 @@ package tools
 import (
 	"context"
 	"fmt"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
 )
 
 type SendCallback func(channel, chatID, content string) error
 
+// RichSendCallback sends a message with attached files or interactive
+// options.
+type RichSendCallback func(msg bus.OutboundMessage) error
+
 type MessageTool struct {
-	sendCallback   SendCallback
-	defaultChannel string
-	defaultChatID  string
-	sentInRound    bool // Tracks whether a message was sent in the current processing round
+	sendCallback     SendCallback
+	richSendCallback RichSendCallback
+	workspace        string
+	restrict         bool
+	defaultChannel   string
+	defaultChatID    string
+	sentInRound      bool // Tracks whether a message was sent in the current processing round
 }
 
 func NewMessageTool() *MessageTool {
@@ -23,7 +32,9 @@ func (t *MessageTool) Name() string {
 }
 
 func (t *MessageTool) Description() string {
-	return "Send a message to user on a chat channel. Use this when you want to communicate something."
+	return "Send a message to user on a chat channel. Use this when you want to communicate something. " +
+		"To send files, images or audio, list them in files; content may then be empty. " +
+		"To let the user pick an answer, list the choices in options; the picked value arrives as their reply."
 }
 
 func (t *MessageTool) Parameters() map[string]any {
@@ -42,6 +53,52 @@ func (t *MessageTool) Parameters() map[string]any {
 				"type":        "string",
 				"description": "Optional: target chat/user ID",
 			},
+			"files": map[string]any{
+				"type":        "array",
+				"description": "Optional: files to send with the message, uploaded to the chat as attachments",
+				"items": map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"path": map[string]any{
+							"type":        "string",
+							"description": "File path, relative to the workspace or absolute",
+						},
+						"caption": map[string]any{
+							"type":        "string",
+							"description": "Optional: caption shown with the file",
+						},
+					},
+					"required": []string{"path"},
+				},
+			},
+			"options": map[string]any{
+				"type": "array",
+				"description": "Optional: choices shown as buttons; a picked choice is sent back as the user's reply. " +
+					"Choices with a url open the link instead.",
+				"items": map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"label": map[string]any{
+							"type":        "string",
+							"description": "Text shown on the choice",
+						},
+						"value": map[string]any{
+							"type":        "string",
+							"description": "Optional: reply sent when picked, defaults to the label",
+						},
+						"url": map[string]any{
+							"type":        "string",
+							"description": "Optional: link opened when picked",
+						},
+					},
+					"required": []string{"label"},
+				},
+			},
+			"options_style": map[string]any{
+				"type":        "string",
+				"enum":        []string{"buttons", "select", "quick_replies"},
+				"description": "Optional: how options are shown: buttons (default), a select menu, or quick replies",
+			},
 		},
 		"required": []string{"content"},
 	}
@@ -53,6 +110,17 @@ func (t *MessageTool) SetContext(channel, chatID string) {
 	t.sentInRound = false // Reset send tracking for new processing round
 }
 
+// Clone returns a message tool with the same callbacks and workspace and no
+// reply target or send tracking.
+func (t *MessageTool) Clone() Tool {
+	return &MessageTool{
+		sendCallback:     t.sendCallback,
+		richSendCallback: t.richSendCallback,
+		workspace:        t.workspace,
+		restrict:         t.restrict,
+	}
+}
+
 // HasSentInRound returns true if the message tool sent a message during the current round.
 func (t *MessageTool) HasSentInRound() bool {
 	return t.sentInRound
@@ -62,9 +130,22 @@ func (t *MessageTool) SetSendCallback(callback SendCallback) {
 	t.sendCallback = callback
 }
 
+// SetRichSendCallback sets the callback used for messages with files or
+// options.
+func (t *MessageTool) SetRichSendCallback(callback RichSendCallback) {
+	t.richSendCallback = callback
+}
+
+// SetWorkspace sets the directory relative file paths are resolved against
+// and, if restrict is true, the only directory files may be sent from.
+func (t *MessageTool) SetWorkspace(workspace string, restrict bool) {
+	t.workspace = workspace
+	t.restrict = restrict
+}
+
 func (t *MessageTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
 	content, ok := args["content"].(string)
-	if !ok {
+	if !ok && args["files"] == nil && args["options"] == nil {
 		return &ToolResult{ForLLM: "content is required", IsError: true}
 	}
 
@@ -82,6 +163,24 @@ func (t *MessageTool) Execute(ctx context.Context, args map[string]any) *ToolRes
 		return &ToolResult{ForLLM: "No target channel/chat specified", IsError: true}
 	}
 
+	media, err := t.parseFiles(args["files"])
+	if err != nil {
+		return &ToolResult{ForLLM: err.Error(), IsError: true}
+	}
+	interactive, err := parseOptions(args["options"], args["options_style"])
+	if err != nil {
+		return &ToolResult{ForLLM: err.Error(), IsError: true}
+	}
+	if len(media) > 0 || len(interactive) > 0 {
+		return t.sendRich(bus.OutboundMessage{
+			Channel:     channel,
+			ChatID:      chatID,
+			Content:     content,
+			Media:       media,
+			Interactive: interactive,
+		})
+	}
+
 	if t.sendCallback == nil {
 		return &ToolResult{ForLLM: "Message sending not configured", IsError: true}
 	}
@@ -101,3 +200,108 @@ func (t *MessageTool) Execute(ctx context.Context, args map[string]any) *ToolRes
 		Silent: true,
 	}
 }
+
+func (t *MessageTool) sendRich(msg bus.OutboundMessage) *ToolResult {
+	if t.richSendCallback == nil {
+		if len(msg.Media) > 0 {
+			return &ToolResult{ForLLM: "File sending not configured", IsError: true}
+		}
+		return &ToolResult{ForLLM: "Sending options not configured", IsError: true}
+	}
+
+	if err := t.richSendCallback(msg); err != nil {
+		return &ToolResult{
+			ForLLM:  fmt.Sprintf("sending message: %v", err),
+			IsError: true,
+			Err:     err,
+		}
+	}
+
+	t.sentInRound = true
+	summary := fmt.Sprintf("Message sent to %s:%s", msg.Channel, msg.ChatID)
+	if len(msg.Media) > 0 {
+		summary = fmt.Sprintf("Message with %d file(s) sent to %s:%s", len(msg.Media), msg.Channel, msg.ChatID)
+	}
+	if len(msg.Interactive) > 0 {
+		summary += fmt.Sprintf("; the user's pick of the %d option(s) will arrive as their reply",
+			len(msg.Interactive[0].Options))
+	}
+	return &ToolResult{ForLLM: summary, Silent: true}
+}
+
+// parseFiles turns the files argument into outbound media, checking that
+// each file exists and may be read. Entries may be objects with a path and
+// caption, or plain path strings.
+func (t *MessageTool) parseFiles(arg any) ([]bus.OutboundMedia, error) {
+	items, ok := arg.([]any)
+	if arg != nil && !ok {
+		return nil, fmt.Errorf("files must be an array")
+	}
+
+	media := make([]bus.OutboundMedia, 0, len(items))
+	for i, item := range items {
+		var path, caption string
+		switch v := item.(type) {
+		case string:
+			path = v
+		case map[string]any:
+			path, _ = v["path"].(string)
+			caption, _ = v["caption"].(string)
+		}
+		if path == "" {
+			return nil, fmt.Errorf("files[%d]: path is required", i)
+		}
+
+		resolved, err := validatePath(path, t.workspace, t.restrict)
+		if err != nil {
+			return nil, fmt.Errorf("files[%d]: %v", i, err)
+		}
+		m := bus.OutboundMedia{Path: resolved, Caption: caption}
+		if _, err := m.Size(); err != nil {
+			return nil, fmt.Errorf("files[%d]: %v", i, err)
+		}
+		media = append(media, m)
+	}
+	return media, nil
+}
+
+// parseOptions turns the options argument into an interactive element of
+// the given style. Entries may be objects with a label, value and url, or
+// plain label strings.
+func parseOptions(arg, style any) ([]bus.InteractiveElement, error) {
+	items, ok := arg.([]any)
+	if arg != nil && !ok {
+		return nil, fmt.Errorf("options must be an array")
+	}
+	if len(items) == 0 {
+		return nil, nil
+	}
+
+	kind := bus.InteractiveButtons
+	if s, _ := style.(string); s != "" {
+		kind = bus.InteractiveKind(s)
+	}
+	switch kind {
+	case bus.InteractiveButtons, bus.InteractiveSelect, bus.InteractiveQuickReplies:
+	default:
+		return nil, fmt.Errorf("options_style must be buttons, select or quick_replies")
+	}
+
+	el := bus.InteractiveElement{Kind: kind}
+	for i, item := range items {
+		var opt bus.InteractiveOption
+		switch v := item.(type) {
+		case string:
+			opt.Label = v
+		case map[string]any:
+			opt.Label, _ = v["label"].(string)
+			opt.Value, _ = v["value"].(string)
+			opt.URL, _ = v["url"].(string)
+		}
+		if opt.Label == "" {
+			return nil, fmt.Errorf("options[%d]: label is required", i)
+		}
+		el.Options = append(el.Options, opt)
+	}
+	return []bus.InteractiveElement{el}, nil
+}
@@ -3,7 +3,12 @@ package tools
 import (
 	"context"
 	"errors"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
 )
 
 func TestMessageTool_Execute_Success(t *testing.T) {
@@ -257,3 +262,119 @@ func TestMessageTool_Parameters(t *testing.T) {
 		t.Error("Expected chat_id type to be 'string'")
 	}
 }
+
+func TestMessageTool_Execute_Files(t *testing.T) {
+	workspace := t.TempDir()
+	if err := os.WriteFile(filepath.Join(workspace, "chart.png"), []byte("png"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tool := NewMessageTool()
+	tool.SetContext("telegram", "42")
+	tool.SetWorkspace(workspace, true)
+
+	var sent []bus.OutboundMedia
+	tool.SetRichSendCallback(func(msg bus.OutboundMessage) error {
+		sent = msg.Media
+		return nil
+	})
+
+	result := tool.Execute(context.Background(), map[string]any{
+		"files": []any{map[string]any{"path": "chart.png", "caption": "Weekly usage"}},
+	})
+	if result.IsError {
+		t.Fatalf("unexpected error: %s", result.ForLLM)
+	}
+	if !result.Silent || !tool.HasSentInRound() {
+		t.Error("expected a silent result and the round marked as sent")
+	}
+	if len(sent) != 1 || sent[0].Path != filepath.Join(workspace, "chart.png") || sent[0].Caption != "Weekly usage" {
+		t.Errorf("sent media = %+v", sent)
+	}
+
+	tests := []struct {
+		name  string
+		files []any
+		want  string
+	}{
+		{"missing file", []any{"missing.pdf"}, "no such file"},
+		{"outside workspace", []any{"/etc/hostname"}, "outside the workspace"},
+		{"no path", []any{map[string]any{"caption": "x"}}, "path is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := tool.Execute(context.Background(), map[string]any{"content": "", "files": tt.files})
+			if !result.IsError || !strings.Contains(result.ForLLM, tt.want) {
+				t.Errorf("result = %+v, want error containing %q", result, tt.want)
+			}
+		})
+	}
+}
+
+func TestMessageTool_Execute_FilesNotConfigured(t *testing.T) {
+	workspace := t.TempDir()
+	os.WriteFile(filepath.Join(workspace, "a.txt"), []byte("a"), 0o644)
+
+	tool := NewMessageTool()
+	tool.SetContext("telegram", "42")
+	tool.SetWorkspace(workspace, true)
+	tool.SetSendCallback(func(channel, chatID, content string) error { return nil })
+
+	result := tool.Execute(context.Background(), map[string]any{"content": "see file", "files": []any{"a.txt"}})
+	if !result.IsError || result.ForLLM != "File sending not configured" {
+		t.Errorf("result = %+v, want file sending not configured", result)
+	}
+}
+
+func TestMessageTool_Execute_Options(t *testing.T) {
+	tool := NewMessageTool()
+	tool.SetContext("telegram", "42")
+
+	var sent bus.OutboundMessage
+	tool.SetRichSendCallback(func(msg bus.OutboundMessage) error {
+		sent = msg
+		return nil
+	})
+
+	result := tool.Execute(context.Background(), map[string]any{
+		"content": "Deploy now?",
+		"options": []any{
+			"Yes",
+			map[string]any{"label": "Later", "value": "remind me tomorrow"},
+			map[string]any{"label": "Docs", "url": "https://example.com"},
+		},
+		"options_style": "quick_replies",
+	})
+	if result.IsError {
+		t.Fatalf("unexpected error: %s", result.ForLLM)
+	}
+	if sent.Content != "Deploy now?" || len(sent.Interactive) != 1 {
+		t.Fatalf("sent = %+v", sent)
+	}
+	el := sent.Interactive[0]
+	if el.Kind != bus.InteractiveQuickReplies || len(el.Options) != 3 {
+		t.Fatalf("element = %+v", el)
+	}
+	if el.Options[0].OptionValue() != "Yes" || el.Options[1].OptionValue() != "remind me tomorrow" ||
+		el.Options[2].URL != "https://example.com" {
+		t.Errorf("options = %+v", el.Options)
+	}
+
+	tests := []struct {
+		name string
+		args map[string]any
+		want string
+	}{
+		{"no label", map[string]any{"options": []any{map[string]any{"value": "x"}}}, "label is required"},
+		{"bad style", map[string]any{"options": []any{"a"}, "options_style": "radio"}, "options_style"},
+		{"not an array", map[string]any{"options": "a, b"}, "must be an array"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := tool.Execute(context.Background(), tt.args); !result.IsError ||
+				!strings.Contains(result.ForLLM, tt.want) {
+				t.Errorf("result = %+v, want error containing %q", result, tt.want)
+			}
+		})
+	}
+}
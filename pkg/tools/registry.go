@@ -3,6 +3,8 @@ package tools
 import (
 	"context"
 	"fmt"
+	"slices"
+	"sort"
 	"sync"
 	"time"
 
@@ -27,6 +29,27 @@ func (r *ToolRegistry) Register(tool Tool) {
 	r.tools[tool.Name()] = tool
 }
 
+// Fork returns a registry for a separate run. Tools implementing
+// CloneableTool are replaced by fresh clones so the run keeps its own
+// per-run state; the others are shared. A non-empty allow list keeps only
+// the named tools.
+func (r *ToolRegistry) Fork(allow []string) *ToolRegistry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	fork := NewToolRegistry()
+	for name, tool := range r.tools {
+		if len(allow) > 0 && !slices.Contains(allow, name) {
+			continue
+		}
+		if cloneable, ok := tool.(CloneableTool); ok {
+			tool = cloneable.Clone()
+		}
+		fork.tools[name] = tool
+	}
+	return fork
+}
+
 func (r *ToolRegistry) Get(name string) (Tool, bool) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
@@ -112,8 +135,8 @@ func (r *ToolRegistry) GetDefinitions() []map[string]any {
 	defer r.mu.RUnlock()
 
 	definitions := make([]map[string]any, 0, len(r.tools))
-	for _, tool := range r.tools {
-		definitions = append(definitions, ToolToSchema(tool))
+	for _, name := range r.sortedNames() {
+		definitions = append(definitions, ToolToSchema(r.tools[name]))
 	}
 	return definitions
 }
@@ -125,8 +148,8 @@ func (r *ToolRegistry) ToProviderDefs() []providers.ToolDefinition {
 	defer r.mu.RUnlock()
 
 	definitions := make([]providers.ToolDefinition, 0, len(r.tools))
-	for _, tool := range r.tools {
-		schema := ToolToSchema(tool)
+	for _, name := range r.sortedNames() {
+		schema := ToolToSchema(r.tools[name])
 
 		// Safely extract nested values with type checks
 		fn, ok := schema["function"].(map[string]any)
@@ -154,11 +177,18 @@ func (r *ToolRegistry) ToProviderDefs() []providers.ToolDefinition {
 func (r *ToolRegistry) List() []string {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
+	return r.sortedNames()
+}
 
+// sortedNames returns the tool names in order, so the tool list sent to the
+// model is the same on every request and prompt and response caches can hit.
+// The caller must hold r.mu.
+func (r *ToolRegistry) sortedNames() []string {
 	names := make([]string, 0, len(r.tools))
 	for name := range r.tools {
 		names = append(names, name)
 	}
+	sort.Strings(names)
 	return names
 }
 
@@ -176,7 +206,8 @@ func (r *ToolRegistry) GetSummaries() []string {
 	defer r.mu.RUnlock()
 
 	summaries := make([]string, 0, len(r.tools))
-	for _, tool := range r.tools {
+	for _, name := range r.sortedNames() {
+		tool := r.tools[name]
 		summaries = append(summaries, fmt.Sprintf("- `%s` - %s", tool.Name(), tool.Description()))
 	}
 	return summaries
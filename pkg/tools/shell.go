@@ -257,6 +257,18 @@ func (t *ExecTool) Execute(ctx context.Context, args map[string]any) *ToolResult
 	}
 }
 
+// Guard returns why Execute would refuse to run command in the tool's
+// working directory, or "" if it is allowed. Code that runs commands on its
+// own, like heartbeat task conditions, uses it to apply the same deny
+// patterns and workspace restriction.
+func (t *ExecTool) Guard(command string) string {
+	cwd := t.workingDir
+	if cwd == "" {
+		cwd, _ = os.Getwd()
+	}
+	return t.guardCommand(command, cwd)
+}
+
 func (t *ExecTool) guardCommand(command, cwd string) string {
 	cmd := strings.TrimSpace(command)
 	lower := strings.ToLower(cmd)
@@ -7,6 +7,7 @@ import (
 
 type SpawnTool struct {
 	manager        *SubagentManager
+	agentID        string // Agent that owns this tool and spawns on its behalf
 	originChannel  string
 	originChatID   string
 	allowlistCheck func(targetAgentID string) bool
@@ -50,6 +51,11 @@ func (t *SpawnTool) Parameters() map[string]any {
 				"type":        "string",
 				"description": "Optional target agent ID to delegate the task to",
 			},
+			"tools": map[string]any{
+				"type":        "array",
+				"description": "Optional: names of the tools the subagent may use; defaults to all of the agent's tools",
+				"items":       map[string]any{"type": "string"},
+			},
 		},
 		"required": []string{"task"},
 	}
@@ -60,6 +66,20 @@ func (t *SpawnTool) SetContext(channel, chatID string) {
 	t.originChatID = chatID
 }
 
+// Clone returns a spawn tool for the same agent and manager, with the
+// default origin and no completion callback.
+func (t *SpawnTool) Clone() Tool {
+	clone := NewSpawnTool(t.manager)
+	clone.agentID = t.agentID
+	clone.allowlistCheck = t.allowlistCheck
+	return clone
+}
+
+// SetAgentID records the agent this tool spawns on behalf of.
+func (t *SpawnTool) SetAgentID(agentID string) {
+	t.agentID = agentID
+}
+
 func (t *SpawnTool) SetAllowlistChecker(check func(targetAgentID string) bool) {
 	t.allowlistCheck = check
 }
@@ -73,6 +93,17 @@ func (t *SpawnTool) Execute(ctx context.Context, args map[string]any) *ToolResul
 	label, _ := args["label"].(string)
 	agentID, _ := args["agent_id"].(string)
 
+	var toolNames []string
+	if raw, ok := args["tools"].([]any); ok {
+		for _, item := range raw {
+			name, ok := item.(string)
+			if !ok {
+				return ErrorResult("tools must be a list of tool names")
+			}
+			toolNames = append(toolNames, name)
+		}
+	}
+
 	// Check allowlist if targeting a specific agent
 	if agentID != "" && t.allowlistCheck != nil {
 		if !t.allowlistCheck(agentID) {
@@ -85,7 +116,8 @@ func (t *SpawnTool) Execute(ctx context.Context, args map[string]any) *ToolResul
 	}
 
 	// Pass callback to manager for async completion notification
-	result, err := t.manager.Spawn(ctx, task, label, agentID, t.originChannel, t.originChatID, t.callback)
+	result, err := t.manager.SpawnFor(
+		ctx, t.agentID, task, label, agentID, toolNames, t.originChannel, t.originChatID, t.callback)
 	if err != nil {
 		return ErrorResult(fmt.Sprintf("failed to spawn subagent: %v", err))
 	}
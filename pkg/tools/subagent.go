@@ -3,6 +3,7 @@ package tools
 import (
 	"context"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
@@ -10,16 +11,70 @@ import (
 	"github.com/sipeed/picoclaw/pkg/providers"
 )
 
+// Subagent task states.
+const (
+	SubagentRunning   = "running"
+	SubagentCompleted = "completed"
+	SubagentFailed    = "failed"
+	SubagentCancelled = "cancelled"
+	// SubagentInterrupted marks a task that was still running when the
+	// process stopped. It is resumed by ResumeInterrupted or can be cancelled.
+	SubagentInterrupted = "interrupted"
+)
+
+// DefaultMaxSubagentDepth allows the main agent to spawn subagents, but not
+// subagents to spawn further subagents.
+const DefaultMaxSubagentDepth = 1
+
 type SubagentTask struct {
-	ID            string
+	ID            string   `json:"id"`
+	Task          string   `json:"task"`
+	Label         string   `json:"label,omitempty"`
+	AgentID       string   `json:"agent_id,omitempty"` // Target agent; empty runs as the parent
+	ParentAgentID string   `json:"parent_agent_id,omitempty"`
+	SessionKey    string   `json:"session_key,omitempty"`
+	Tools         []string `json:"tools,omitempty"` // Tools the task may use; empty allows all
+	Depth         int      `json:"depth"`
+	OriginChannel string   `json:"origin_channel"`
+	OriginChatID  string   `json:"origin_chat_id"`
+	Status        string   `json:"status"`
+	Result        string   `json:"result,omitempty"`
+	Iterations    int      `json:"iterations,omitempty"`
+	Created       int64    `json:"created"`
+	Finished      int64    `json:"finished,omitempty"`
+
+	cancel context.CancelFunc
+}
+
+// SubagentRequest describes a single subagent run.
+type SubagentRequest struct {
+	TaskID        string
 	Task          string
 	Label         string
 	AgentID       string
+	ParentAgentID string
+	SessionKey    string
+	Tools         []string // Tools the run may use; empty allows all of the agent's tools
 	OriginChannel string
 	OriginChatID  string
-	Status        string
-	Result        string
-	Created       int64
+}
+
+// SubagentRunner executes a subagent run. The agent loop installs one so
+// spawned tasks run as the target registered agent; without it the manager
+// falls back to a plain tool loop with its own provider and tools.
+type SubagentRunner func(ctx context.Context, req SubagentRequest) (*ToolLoopResult, error)
+
+type subagentDepthKey struct{}
+
+// WithSubagentDepth marks ctx as running inside a subagent at depth.
+func WithSubagentDepth(ctx context.Context, depth int) context.Context {
+	return context.WithValue(ctx, subagentDepthKey{}, depth)
+}
+
+// SubagentDepth returns the subagent nesting depth of ctx, 0 for the main agent.
+func SubagentDepth(ctx context.Context) int {
+	depth, _ := ctx.Value(subagentDepthKey{}).(int)
+	return depth
 }
 
 type SubagentManager struct {
@@ -30,12 +85,16 @@ type SubagentManager struct {
 	bus            *bus.MessageBus
 	workspace      string
 	tools          *ToolRegistry
+	runner         SubagentRunner
+	sessionKeyFunc func(agentID, taskID string) string
+	maxDepth       int
 	maxIterations  int
 	maxTokens      int
 	temperature    float64
 	hasMaxTokens   bool
 	hasTemperature bool
 	nextID         int
+	storePath      string
 }
 
 func NewSubagentManager(
@@ -50,6 +109,7 @@ func NewSubagentManager(
 		bus:           bus,
 		workspace:     workspace,
 		tools:         NewToolRegistry(),
+		maxDepth:      DefaultMaxSubagentDepth,
 		maxIterations: 10,
 		nextID:        1,
 	}
@@ -80,71 +140,109 @@ func (sm *SubagentManager) RegisterTool(tool Tool) {
 	sm.tools.Register(tool)
 }
 
+// SetRunner installs the function that executes subagent runs.
+func (sm *SubagentManager) SetRunner(runner SubagentRunner) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.runner = runner
+}
+
+// SetSessionKeyFunc sets how the session key for a task's transcript is
+// derived from the agent that runs it and the task ID.
+func (sm *SubagentManager) SetSessionKeyFunc(fn func(agentID, taskID string) string) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.sessionKeyFunc = fn
+}
+
+// SetMaxDepth limits how deeply subagents may spawn further subagents.
+// Values below 1 use DefaultMaxSubagentDepth.
+func (sm *SubagentManager) SetMaxDepth(depth int) {
+	if depth < 1 {
+		depth = DefaultMaxSubagentDepth
+	}
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.maxDepth = depth
+}
+
 func (sm *SubagentManager) Spawn(
 	ctx context.Context,
 	task, label, agentID, originChannel, originChatID string,
 	callback AsyncCallback,
+) (string, error) {
+	return sm.SpawnFor(ctx, "", task, label, agentID, nil, originChannel, originChatID, callback)
+}
+
+// SpawnFor starts a background task on behalf of parentAgentID. The task runs
+// detached from ctx, so it outlives the turn that spawned it; use Cancel to
+// stop it. A non-empty toolNames limits the tools the task may use.
+func (sm *SubagentManager) SpawnFor(
+	ctx context.Context,
+	parentAgentID, task, label, agentID string,
+	toolNames []string,
+	originChannel, originChatID string,
+	callback AsyncCallback,
 ) (string, error) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
+	depth := SubagentDepth(ctx) + 1
+	if depth > sm.maxDepth {
+		return "", fmt.Errorf("subagent depth limit reached (max %d)", sm.maxDepth)
+	}
+
 	taskID := fmt.Sprintf("subagent-%d", sm.nextID)
 	sm.nextID++
 
+	taskCtx, cancel := context.WithCancel(WithSubagentDepth(context.WithoutCancel(ctx), depth))
+
 	subagentTask := &SubagentTask{
 		ID:            taskID,
 		Task:          task,
 		Label:         label,
 		AgentID:       agentID,
+		ParentAgentID: parentAgentID,
+		SessionKey:    sm.sessionKeyLocked(agentID, parentAgentID, taskID),
+		Tools:         toolNames,
+		Depth:         depth,
 		OriginChannel: originChannel,
 		OriginChatID:  originChatID,
-		Status:        "running",
+		Status:        SubagentRunning,
 		Created:       time.Now().UnixMilli(),
+		cancel:        cancel,
 	}
 	sm.tasks[taskID] = subagentTask
+	sm.saveLocked()
 
 	// Start task in background with context cancellation support
-	go sm.runTask(ctx, subagentTask, callback)
+	go sm.runTask(taskCtx, subagentTask, callback)
 
 	if label != "" {
-		return fmt.Sprintf("Spawned subagent '%s' for task: %s", label, task), nil
+		return fmt.Sprintf("Spawned subagent '%s' (%s) for task: %s", label, taskID, task), nil
 	}
-	return fmt.Sprintf("Spawned subagent for task: %s", task), nil
+	return fmt.Sprintf("Spawned subagent %s for task: %s", taskID, task), nil
 }
 
-func (sm *SubagentManager) runTask(ctx context.Context, task *SubagentTask, callback AsyncCallback) {
-	task.Status = "running"
-	task.Created = time.Now().UnixMilli()
-
-	// Build system prompt for subagent
-	systemPrompt := `You are a subagent. Complete the given task independently and report the result.
-You have access to tools - use them as needed to complete your task.
-After completing the task, provide a clear summary of what was done.`
-
-	messages := []providers.Message{
-		{
-			Role:    "system",
-			Content: systemPrompt,
-		},
-		{
-			Role:    "user",
-			Content: task.Task,
-		},
+func (sm *SubagentManager) sessionKeyLocked(agentID, parentAgentID, taskID string) string {
+	if sm.sessionKeyFunc == nil {
+		return ""
 	}
-
-	// Check if context is already cancelled before starting
-	select {
-	case <-ctx.Done():
-		sm.mu.Lock()
-		task.Status = "cancelled"
-		task.Result = "Task cancelled before execution"
-		sm.mu.Unlock()
-		return
-	default:
+	if agentID == "" {
+		agentID = parentAgentID
 	}
+	return sm.sessionKeyFunc(agentID, taskID)
+}
 
-	// Run tool loop with access to tools
+// execute runs one subagent request through the installed runner, or through
+// a plain tool loop when none is installed.
+func (sm *SubagentManager) execute(
+	ctx context.Context,
+	req SubagentRequest,
+	systemPrompt string,
+) (*ToolLoopResult, error) {
 	sm.mu.RLock()
+	runner := sm.runner
 	tools := sm.tools
 	maxIter := sm.maxIterations
 	maxTokens := sm.maxTokens
@@ -153,6 +251,21 @@ After completing the task, provide a clear summary of what was done.`
 	hasTemperature := sm.hasTemperature
 	sm.mu.RUnlock()
 
+	if runner != nil {
+		return runner(ctx, req)
+	}
+
+	messages := []providers.Message{
+		{
+			Role:    "system",
+			Content: systemPrompt,
+		},
+		{
+			Role:    "user",
+			Content: req.Task,
+		},
+	}
+
 	var llmOptions map[string]any
 	if hasMaxTokens || hasTemperature {
 		llmOptions = map[string]any{}
@@ -164,17 +277,43 @@ After completing the task, provide a clear summary of what was done.`
 		}
 	}
 
-	loopResult, err := RunToolLoop(ctx, ToolLoopConfig{
+	return RunToolLoop(ctx, ToolLoopConfig{
 		Provider:      sm.provider,
 		Model:         sm.defaultModel,
-		Tools:         tools,
+		Tools:         tools.Fork(req.Tools),
 		MaxIterations: maxIter,
 		LLMOptions:    llmOptions,
-	}, messages, task.OriginChannel, task.OriginChatID)
+	}, messages, req.OriginChannel, req.OriginChatID)
+}
+
+func (sm *SubagentManager) runTask(ctx context.Context, task *SubagentTask, callback AsyncCallback) {
+	defer task.cancel()
+
+	// Build system prompt for subagent
+	systemPrompt := `You are a subagent. Complete the given task independently and report the result.
+You have access to tools - use them as needed to complete your task.
+After completing the task, provide a clear summary of what was done.`
+
+	sm.mu.RLock()
+	req := SubagentRequest{
+		TaskID:        task.ID,
+		Task:          task.Task,
+		Label:         task.Label,
+		AgentID:       task.AgentID,
+		ParentAgentID: task.ParentAgentID,
+		SessionKey:    task.SessionKey,
+		Tools:         task.Tools,
+		OriginChannel: task.OriginChannel,
+		OriginChatID:  task.OriginChatID,
+	}
+	sm.mu.RUnlock()
+
+	loopResult, err := sm.execute(ctx, req, systemPrompt)
 
 	sm.mu.Lock()
 	var result *ToolResult
 	defer func() {
+		sm.saveLocked()
 		sm.mu.Unlock()
 		// Call callback if provided and result is set
 		if callback != nil && result != nil {
@@ -182,12 +321,13 @@ After completing the task, provide a clear summary of what was done.`
 		}
 	}()
 
+	task.Finished = time.Now().UnixMilli()
 	if err != nil {
-		task.Status = "failed"
+		task.Status = SubagentFailed
 		task.Result = fmt.Sprintf("Error: %v", err)
 		// Check if it was cancelled
 		if ctx.Err() != nil {
-			task.Status = "cancelled"
+			task.Status = SubagentCancelled
 			task.Result = "Task cancelled during execution"
 		}
 		result = &ToolResult{
@@ -199,8 +339,9 @@ After completing the task, provide a clear summary of what was done.`
 			Err:     err,
 		}
 	} else {
-		task.Status = "completed"
+		task.Status = SubagentCompleted
 		task.Result = loopResult.Content
+		task.Iterations = loopResult.Iterations
 		result = &ToolResult{
 			ForLLM: fmt.Sprintf(
 				"Subagent '%s' completed (iterations: %d): %s",
@@ -217,7 +358,7 @@ After completing the task, provide a clear summary of what was done.`
 
 	// Send announce message back to main agent
 	if sm.bus != nil {
-		announceContent := fmt.Sprintf("Task '%s' completed.\n\nResult:\n%s", task.Label, task.Result)
+		announceContent := fmt.Sprintf("Task '%s' %s.\n\nResult:\n%s", task.Label, task.Status, task.Result)
 		sm.bus.PublishInbound(bus.InboundMessage{
 			Channel:  "system",
 			SenderID: fmt.Sprintf("subagent:%s", task.ID),
@@ -228,21 +369,58 @@ After completing the task, provide a clear summary of what was done.`
 	}
 }
 
+// Cancel stops a running or interrupted task. A running task reports itself
+// as cancelled once its current LLM call or tool returns.
+func (sm *SubagentManager) Cancel(taskID string) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	task, ok := sm.tasks[taskID]
+	if !ok {
+		return fmt.Errorf("subagent task %q not found", taskID)
+	}
+	switch task.Status {
+	case SubagentRunning:
+		task.cancel()
+	case SubagentInterrupted:
+		task.Status = SubagentCancelled
+		task.Result = "Task cancelled after restart"
+		task.Finished = time.Now().UnixMilli()
+		sm.saveLocked()
+	default:
+		return fmt.Errorf("subagent task %q is already %s", taskID, task.Status)
+	}
+	return nil
+}
+
+// GetTask returns a snapshot of the task.
 func (sm *SubagentManager) GetTask(taskID string) (*SubagentTask, bool) {
 	sm.mu.RLock()
 	defer sm.mu.RUnlock()
 	task, ok := sm.tasks[taskID]
-	return task, ok
+	if !ok {
+		return nil, false
+	}
+	snapshot := *task
+	return &snapshot, true
 }
 
+// ListTasks returns snapshots of all tasks, oldest first.
 func (sm *SubagentManager) ListTasks() []*SubagentTask {
 	sm.mu.RLock()
 	defer sm.mu.RUnlock()
 
 	tasks := make([]*SubagentTask, 0, len(sm.tasks))
 	for _, task := range sm.tasks {
-		tasks = append(tasks, task)
+		snapshot := *task
+		tasks = append(tasks, &snapshot)
 	}
+	sort.Slice(tasks, func(i, j int) bool {
+		if tasks[i].Created != tasks[j].Created {
+			return tasks[i].Created < tasks[j].Created
+		}
+		return tasks[i].ID < tasks[j].ID
+	})
 	return tasks
 }
 
@@ -293,6 +471,11 @@ func (t *SubagentTool) SetContext(channel, chatID string) {
 	t.originChatID = chatID
 }
 
+// Clone returns a subagent tool for the same manager with the default origin.
+func (t *SubagentTool) Clone() Tool {
+	return NewSubagentTool(t.manager)
+}
+
 func (t *SubagentTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
 	task, ok := args["task"].(string)
 	if !ok {
@@ -305,47 +488,22 @@ func (t *SubagentTool) Execute(ctx context.Context, args map[string]any) *ToolRe
 		return ErrorResult("Subagent manager not configured").WithError(fmt.Errorf("manager is nil"))
 	}
 
-	// Build messages for subagent
-	messages := []providers.Message{
-		{
-			Role:    "system",
-			Content: "You are a subagent. Complete the given task independently and provide a clear, concise result.",
-		},
-		{
-			Role:    "user",
-			Content: task,
-		},
-	}
-
-	// Use RunToolLoop to execute with tools (same as async SpawnTool)
-	sm := t.manager
-	sm.mu.RLock()
-	tools := sm.tools
-	maxIter := sm.maxIterations
-	maxTokens := sm.maxTokens
-	temperature := sm.temperature
-	hasMaxTokens := sm.hasMaxTokens
-	hasTemperature := sm.hasTemperature
-	sm.mu.RUnlock()
-
-	var llmOptions map[string]any
-	if hasMaxTokens || hasTemperature {
-		llmOptions = map[string]any{}
-		if hasMaxTokens {
-			llmOptions["max_tokens"] = maxTokens
-		}
-		if hasTemperature {
-			llmOptions["temperature"] = temperature
-		}
+	// Runs synchronously within the caller's turn, one level deeper.
+	depth := SubagentDepth(ctx) + 1
+	t.manager.mu.RLock()
+	maxDepth := t.manager.maxDepth
+	t.manager.mu.RUnlock()
+	if depth > maxDepth {
+		err := fmt.Errorf("subagent depth limit reached (max %d)", maxDepth)
+		return ErrorResult(err.Error()).WithError(err)
 	}
 
-	loopResult, err := RunToolLoop(ctx, ToolLoopConfig{
-		Provider:      sm.provider,
-		Model:         sm.defaultModel,
-		Tools:         tools,
-		MaxIterations: maxIter,
-		LLMOptions:    llmOptions,
-	}, messages, t.originChannel, t.originChatID)
+	loopResult, err := t.manager.execute(WithSubagentDepth(ctx, depth), SubagentRequest{
+		Task:          task,
+		Label:         label,
+		OriginChannel: t.originChannel,
+		OriginChatID:  t.originChatID,
+	}, "You are a subagent. Complete the given task independently and provide a clear, concise result.")
 	if err != nil {
 		return ErrorResult(fmt.Sprintf("Subagent execution failed: %v", err)).WithError(err)
 	}
@@ -0,0 +1,176 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// maxStoredSubagentTasks bounds how many finished tasks are kept, oldest
+// first out. Running and interrupted tasks are never dropped.
+const maxStoredSubagentTasks = 200
+
+type subagentStore struct {
+	Version int             `json:"version"`
+	Tasks   []*SubagentTask `json:"tasks"`
+}
+
+// SetStorePath persists the task registry to path and loads any tasks saved
+// there by a previous run. Tasks that were still running when the process
+// stopped are marked interrupted.
+func (sm *SubagentManager) SetStorePath(path string) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	sm.storePath = path
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var store subagentStore
+	if err := json.Unmarshal(data, &store); err != nil {
+		return fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	for _, task := range store.Tasks {
+		if task == nil || task.ID == "" {
+			continue
+		}
+		if task.Status == SubagentRunning {
+			task.Status = SubagentInterrupted
+		}
+		sm.tasks[task.ID] = task
+		if n, err := strconv.Atoi(strings.TrimPrefix(task.ID, "subagent-")); err == nil && n >= sm.nextID {
+			sm.nextID = n + 1
+		}
+	}
+	sm.saveLocked()
+	return nil
+}
+
+// ResumeInterrupted restarts every interrupted task from the beginning,
+// keeping its ID and session, and returns how many were resumed.
+func (sm *SubagentManager) ResumeInterrupted(callback AsyncCallback) int {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	resumed := 0
+	for _, task := range sm.tasks {
+		if task.Status != SubagentInterrupted {
+			continue
+		}
+		ctx, cancel := context.WithCancel(WithSubagentDepth(context.Background(), task.Depth))
+		task.Status = SubagentRunning
+		task.Result = ""
+		task.cancel = cancel
+		go sm.runTask(ctx, task, callback)
+		resumed++
+
+		logger.InfoCF("subagent", "Resuming interrupted task",
+			map[string]any{
+				"task_id":  task.ID,
+				"agent_id": task.AgentID,
+				"label":    task.Label,
+			})
+	}
+	if resumed > 0 {
+		sm.saveLocked()
+	}
+	return resumed
+}
+
+// AbandonInterrupted marks every interrupted task failed without running
+// it again, and returns snapshots of them so the caller can report them.
+func (sm *SubagentManager) AbandonInterrupted() []SubagentTask {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	var abandoned []SubagentTask
+	for _, task := range sm.tasks {
+		if task.Status != SubagentInterrupted {
+			continue
+		}
+		task.Status = SubagentFailed
+		task.Result = "Task interrupted by a restart and not resumed"
+		task.Finished = time.Now().UnixMilli()
+		abandoned = append(abandoned, *task)
+	}
+	if len(abandoned) > 0 {
+		sm.saveLocked()
+	}
+	sort.Slice(abandoned, func(i, j int) bool {
+		return abandoned[i].Created < abandoned[j].Created
+	})
+	return abandoned
+}
+
+// saveLocked writes the registry to the store path, if one is set. Failures
+// are logged; the in-memory registry stays authoritative.
+//
+// Must be called with the lock held.
+func (sm *SubagentManager) saveLocked() {
+	if sm.storePath == "" {
+		return
+	}
+	sm.pruneLocked()
+
+	store := subagentStore{Version: 1, Tasks: make([]*SubagentTask, 0, len(sm.tasks))}
+	for _, task := range sm.tasks {
+		store.Tasks = append(store.Tasks, task)
+	}
+	sort.Slice(store.Tasks, func(i, j int) bool {
+		return store.Tasks[i].Created < store.Tasks[j].Created
+	})
+
+	data, err := json.MarshalIndent(store, "", "  ")
+	if err == nil {
+		tempFile := sm.storePath + ".tmp"
+		if err = os.WriteFile(tempFile, data, 0o600); err == nil {
+			if err = os.Rename(tempFile, sm.storePath); err != nil {
+				os.Remove(tempFile)
+			}
+		}
+	}
+	if err != nil {
+		logger.WarnCF("subagent", "Failed to save task registry",
+			map[string]any{"path": sm.storePath, "error": err.Error()})
+	}
+}
+
+// pruneLocked drops the oldest finished tasks beyond maxStoredSubagentTasks.
+func (sm *SubagentManager) pruneLocked() {
+	if len(sm.tasks) <= maxStoredSubagentTasks {
+		return
+	}
+	finished := make([]*SubagentTask, 0, len(sm.tasks))
+	for _, task := range sm.tasks {
+		if task.Status != SubagentRunning && task.Status != SubagentInterrupted {
+			finished = append(finished, task)
+		}
+	}
+	sort.Slice(finished, func(i, j int) bool {
+		return finished[i].Created < finished[j].Created
+	})
+	for _, task := range finished {
+		if len(sm.tasks) <= maxStoredSubagentTasks {
+			break
+		}
+		delete(sm.tasks, task.ID)
+	}
+}
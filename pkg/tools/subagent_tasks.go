@@ -0,0 +1,225 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+// Task management tools let an agent inspect and cancel the subagents it
+// spawned. Each tool is bound to one agent and only sees that agent's tasks.
+
+func subagentTaskOwned(task *SubagentTask, agentID string) bool {
+	return agentID == "" || task.ParentAgentID == "" || task.ParentAgentID == agentID
+}
+
+func formatTaskAge(ms int64) string {
+	if ms == 0 {
+		return "-"
+	}
+	return time.Since(time.UnixMilli(ms)).Truncate(time.Second).String() + " ago"
+}
+
+func subagentTaskArg(args map[string]any) (string, *ToolResult) {
+	taskID, _ := args["task_id"].(string)
+	taskID = strings.TrimSpace(taskID)
+	if taskID == "" {
+		return "", ErrorResult("task_id is required")
+	}
+	return taskID, nil
+}
+
+// SubagentListTool lists spawned subagent tasks.
+type SubagentListTool struct {
+	manager *SubagentManager
+	agentID string
+}
+
+func NewSubagentListTool(manager *SubagentManager, agentID string) *SubagentListTool {
+	return &SubagentListTool{manager: manager, agentID: agentID}
+}
+
+func (t *SubagentListTool) Name() string {
+	return "subagent_list"
+}
+
+func (t *SubagentListTool) Description() string {
+	return "List background subagent tasks you have spawned, newest first, with their status. Use subagent_status for a task's full result."
+}
+
+func (t *SubagentListTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"status": map[string]any{
+				"type":        "string",
+				"description": "Only list tasks with this status",
+				"enum": []string{
+					SubagentRunning, SubagentCompleted, SubagentFailed, SubagentCancelled, SubagentInterrupted,
+				},
+			},
+			"limit": map[string]any{
+				"type":        "integer",
+				"description": "Maximum number of tasks to list (default 20)",
+			},
+		},
+	}
+}
+
+func (t *SubagentListTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	if t.manager == nil {
+		return ErrorResult("Subagent manager not configured")
+	}
+
+	status, _ := args["status"].(string)
+	limit := 20
+	if l, ok := args["limit"].(float64); ok && l > 0 {
+		limit = int(l)
+	}
+
+	all := t.manager.ListTasks()
+	var sb strings.Builder
+	count := 0
+	for i := len(all) - 1; i >= 0 && count < limit; i-- {
+		task := all[i]
+		if !subagentTaskOwned(task, t.agentID) || (status != "" && task.Status != status) {
+			continue
+		}
+		count++
+		label := task.Label
+		if label == "" {
+			label = utils.Truncate(task.Task, 60)
+		}
+		fmt.Fprintf(&sb, "- %s [%s] %s (started %s", task.ID, task.Status, label, formatTaskAge(task.Created))
+		if task.AgentID != "" {
+			fmt.Fprintf(&sb, ", agent %s", task.AgentID)
+		}
+		sb.WriteString(")\n")
+	}
+
+	if count == 0 {
+		return SilentResult("No subagent tasks found.")
+	}
+	return SilentResult(fmt.Sprintf("Subagent tasks:\n%s", sb.String()))
+}
+
+// SubagentStatusTool reports the details and result of one task.
+type SubagentStatusTool struct {
+	manager *SubagentManager
+	agentID string
+}
+
+func NewSubagentStatusTool(manager *SubagentManager, agentID string) *SubagentStatusTool {
+	return &SubagentStatusTool{manager: manager, agentID: agentID}
+}
+
+func (t *SubagentStatusTool) Name() string {
+	return "subagent_status"
+}
+
+func (t *SubagentStatusTool) Description() string {
+	return "Get the status, details and result of a spawned subagent task by its task ID."
+}
+
+func (t *SubagentStatusTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"task_id": map[string]any{
+				"type":        "string",
+				"description": "The task ID, e.g. subagent-3",
+			},
+		},
+		"required": []string{"task_id"},
+	}
+}
+
+func (t *SubagentStatusTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	if t.manager == nil {
+		return ErrorResult("Subagent manager not configured")
+	}
+	taskID, errResult := subagentTaskArg(args)
+	if errResult != nil {
+		return errResult
+	}
+
+	task, ok := t.manager.GetTask(taskID)
+	if !ok || !subagentTaskOwned(task, t.agentID) {
+		return ErrorResult(fmt.Sprintf("subagent task %q not found", taskID))
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Task: %s\n", task.ID)
+	if task.Label != "" {
+		fmt.Fprintf(&sb, "Label: %s\n", task.Label)
+	}
+	fmt.Fprintf(&sb, "Status: %s\n", task.Status)
+	if task.AgentID != "" {
+		fmt.Fprintf(&sb, "Agent: %s\n", task.AgentID)
+	}
+	fmt.Fprintf(&sb, "Started: %s\n", formatTaskAge(task.Created))
+	if task.Finished != 0 {
+		fmt.Fprintf(&sb, "Finished: %s\n", formatTaskAge(task.Finished))
+	}
+	if task.Iterations > 0 {
+		fmt.Fprintf(&sb, "Iterations: %d\n", task.Iterations)
+	}
+	fmt.Fprintf(&sb, "Request: %s\n", task.Task)
+	if task.Result != "" {
+		fmt.Fprintf(&sb, "\nResult:\n%s", task.Result)
+	}
+	return SilentResult(sb.String())
+}
+
+// SubagentCancelTool cancels a running or interrupted task.
+type SubagentCancelTool struct {
+	manager *SubagentManager
+	agentID string
+}
+
+func NewSubagentCancelTool(manager *SubagentManager, agentID string) *SubagentCancelTool {
+	return &SubagentCancelTool{manager: manager, agentID: agentID}
+}
+
+func (t *SubagentCancelTool) Name() string {
+	return "subagent_cancel"
+}
+
+func (t *SubagentCancelTool) Description() string {
+	return "Cancel a running background subagent task by its task ID."
+}
+
+func (t *SubagentCancelTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"task_id": map[string]any{
+				"type":        "string",
+				"description": "The task ID to cancel, e.g. subagent-3",
+			},
+		},
+		"required": []string{"task_id"},
+	}
+}
+
+func (t *SubagentCancelTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	if t.manager == nil {
+		return ErrorResult("Subagent manager not configured")
+	}
+	taskID, errResult := subagentTaskArg(args)
+	if errResult != nil {
+		return errResult
+	}
+
+	task, ok := t.manager.GetTask(taskID)
+	if !ok || !subagentTaskOwned(task, t.agentID) {
+		return ErrorResult(fmt.Sprintf("subagent task %q not found", taskID))
+	}
+	if err := t.manager.Cancel(taskID); err != nil {
+		return ErrorResult(err.Error()).WithError(err)
+	}
+	return SilentResult(fmt.Sprintf("Cancellation requested for subagent task %s", taskID))
+}
@@ -0,0 +1,254 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func waitForStatus(t *testing.T, sm *SubagentManager, taskID, status string) *SubagentTask {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if task, ok := sm.GetTask(taskID); ok && task.Status == status {
+			return task
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	task, _ := sm.GetTask(taskID)
+	t.Fatalf("task %s did not reach %q, got %+v", taskID, status, task)
+	return nil
+}
+
+func TestSubagentManager_RunnerReceivesRequest(t *testing.T) {
+	sm := NewSubagentManager(&MockLLMProvider{}, "test-model", "/tmp/test", nil)
+	sm.SetSessionKeyFunc(func(agentID, taskID string) string {
+		return "agent:" + agentID + ":subagent:" + taskID
+	})
+
+	got := make(chan SubagentRequest, 1)
+	sm.SetRunner(func(ctx context.Context, req SubagentRequest) (*ToolLoopResult, error) {
+		if SubagentDepth(ctx) != 1 {
+			t.Errorf("SubagentDepth = %d, want 1", SubagentDepth(ctx))
+		}
+		got <- req
+		return &ToolLoopResult{Content: "done", Iterations: 2}, nil
+	})
+
+	if _, err := sm.SpawnFor(context.Background(), "main", "research", "r", "", nil, "telegram", "42", nil); err != nil {
+		t.Fatalf("SpawnFor: %v", err)
+	}
+
+	req := <-got
+	if req.ParentAgentID != "main" || req.SessionKey != "agent:main:subagent:subagent-1" ||
+		req.OriginChannel != "telegram" || req.OriginChatID != "42" {
+		t.Errorf("unexpected request: %+v", req)
+	}
+
+	task := waitForStatus(t, sm, "subagent-1", SubagentCompleted)
+	if task.Result != "done" || task.Iterations != 2 || task.Finished == 0 {
+		t.Errorf("unexpected task: %+v", task)
+	}
+}
+
+func TestSubagentManager_DepthLimit(t *testing.T) {
+	sm := NewSubagentManager(&MockLLMProvider{}, "test-model", "/tmp/test", nil)
+
+	ctx := WithSubagentDepth(context.Background(), 1)
+	if _, err := sm.Spawn(ctx, "nested", "", "", "cli", "direct", nil); err == nil ||
+		!strings.Contains(err.Error(), "depth") {
+		t.Fatalf("expected depth error, got %v", err)
+	}
+
+	sm.SetMaxDepth(2)
+	if _, err := sm.Spawn(ctx, "nested", "", "", "cli", "direct", nil); err != nil {
+		t.Fatalf("expected nested spawn to be allowed at max depth 2: %v", err)
+	}
+	if task, _ := sm.GetTask("subagent-1"); task.Depth != 2 {
+		t.Errorf("Depth = %d, want 2", task.Depth)
+	}
+
+	tool := NewSubagentTool(sm)
+	result := tool.Execute(WithSubagentDepth(context.Background(), 2), map[string]any{"task": "deeper"})
+	if !result.IsError {
+		t.Error("expected sync subagent to respect the depth limit")
+	}
+}
+
+func TestSubagentManager_Cancel(t *testing.T) {
+	sm := NewSubagentManager(&MockLLMProvider{}, "test-model", "/tmp/test", nil)
+	started := make(chan struct{})
+	sm.SetRunner(func(ctx context.Context, req SubagentRequest) (*ToolLoopResult, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	// Cancelling the spawning turn must not stop the task.
+	parent, cancelParent := context.WithCancel(context.Background())
+	if _, err := sm.Spawn(parent, "long task", "", "", "cli", "direct", nil); err != nil {
+		t.Fatalf("Spawn: %v", err)
+	}
+	<-started
+	cancelParent()
+	if task, _ := sm.GetTask("subagent-1"); task.Status != SubagentRunning {
+		t.Fatalf("task stopped with its parent context: %+v", task)
+	}
+
+	if err := sm.Cancel("subagent-1"); err != nil {
+		t.Fatalf("Cancel: %v", err)
+	}
+	waitForStatus(t, sm, "subagent-1", SubagentCancelled)
+
+	if err := sm.Cancel("subagent-1"); err == nil {
+		t.Error("expected error cancelling a finished task")
+	}
+	if err := sm.Cancel("subagent-99"); err == nil {
+		t.Error("expected error cancelling an unknown task")
+	}
+}
+
+func TestSubagentManager_StoreSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "subagents", "tasks.json")
+
+	first := NewSubagentManager(&MockLLMProvider{}, "test-model", "/tmp/test", nil)
+	if err := first.SetStorePath(path); err != nil {
+		t.Fatalf("SetStorePath: %v", err)
+	}
+	block := make(chan struct{})
+	first.SetRunner(func(ctx context.Context, req SubagentRequest) (*ToolLoopResult, error) {
+		if req.TaskID == "subagent-1" {
+			return &ToolLoopResult{Content: "quick"}, nil
+		}
+		<-block
+		return nil, context.Canceled
+	})
+
+	first.SpawnFor(context.Background(), "main", "quick task", "", "", nil, "cli", "direct", nil)
+	waitForStatus(t, first, "subagent-1", SubagentCompleted)
+	first.SpawnFor(context.Background(), "main", "slow task", "slow", "", nil, "telegram", "42", nil)
+
+	// A new manager on the same store sees the running task as interrupted.
+	second := NewSubagentManager(&MockLLMProvider{}, "test-model", "/tmp/test", nil)
+	if err := second.SetStorePath(path); err != nil {
+		t.Fatalf("SetStorePath: %v", err)
+	}
+	if task, ok := second.GetTask("subagent-1"); !ok || task.Status != SubagentCompleted || task.Result != "quick" {
+		t.Errorf("completed task not restored: %+v", task)
+	}
+	if task, ok := second.GetTask("subagent-2"); !ok || task.Status != SubagentInterrupted || task.OriginChatID != "42" {
+		t.Fatalf("running task not restored as interrupted: %+v", task)
+	}
+
+	resumed := make(chan SubagentRequest, 1)
+	second.SetRunner(func(ctx context.Context, req SubagentRequest) (*ToolLoopResult, error) {
+		resumed <- req
+		return &ToolLoopResult{Content: "finished after restart"}, nil
+	})
+	if n := second.ResumeInterrupted(nil); n != 1 {
+		t.Fatalf("ResumeInterrupted = %d, want 1", n)
+	}
+	if req := <-resumed; req.TaskID != "subagent-2" || req.Label != "slow" {
+		t.Errorf("unexpected resumed request: %+v", req)
+	}
+	waitForStatus(t, second, "subagent-2", SubagentCompleted)
+
+	// IDs continue after the restored tasks.
+	msg, _ := second.Spawn(context.Background(), "next", "", "", "cli", "direct", nil)
+	if !strings.Contains(msg, "subagent-3") {
+		t.Errorf("expected next ID subagent-3, got %q", msg)
+	}
+
+	// Let background tasks finish writing before the temp dir is removed.
+	close(block)
+	waitForStatus(t, first, "subagent-2", SubagentFailed)
+	waitForStatus(t, second, "subagent-3", SubagentCompleted)
+}
+
+func TestSubagentManager_CancelInterrupted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.json")
+	data := `{"version":1,"tasks":[{"id":"subagent-7","task":"t","status":"running","created":1}]}`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	sm := NewSubagentManager(&MockLLMProvider{}, "test-model", "/tmp/test", nil)
+	if err := sm.SetStorePath(path); err != nil {
+		t.Fatalf("SetStorePath: %v", err)
+	}
+	if err := sm.Cancel("subagent-7"); err != nil {
+		t.Fatalf("Cancel: %v", err)
+	}
+	if task, _ := sm.GetTask("subagent-7"); task.Status != SubagentCancelled {
+		t.Errorf("Status = %s, want cancelled", task.Status)
+	}
+	if sm.ResumeInterrupted(nil) != 0 {
+		t.Error("cancelled task should not be resumed")
+	}
+}
+
+func TestSubagentManager_AbandonInterrupted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.json")
+	data := `{"version":1,"tasks":[{"id":"subagent-7","task":"t","label":"crawl","status":"running","created":1}]}`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	sm := NewSubagentManager(&MockLLMProvider{}, "test-model", "/tmp/test", nil)
+	if err := sm.SetStorePath(path); err != nil {
+		t.Fatalf("SetStorePath: %v", err)
+	}
+	abandoned := sm.AbandonInterrupted()
+	if len(abandoned) != 1 || abandoned[0].ID != "subagent-7" || abandoned[0].Label != "crawl" {
+		t.Fatalf("abandoned = %+v", abandoned)
+	}
+	if task, _ := sm.GetTask("subagent-7"); task.Status != SubagentFailed {
+		t.Errorf("Status = %s, want failed", task.Status)
+	}
+	if sm.ResumeInterrupted(nil) != 0 || len(sm.AbandonInterrupted()) != 0 {
+		t.Error("abandoned task should not be resumed or reported again")
+	}
+}
+
+func TestSubagentTaskTools(t *testing.T) {
+	sm := NewSubagentManager(&MockLLMProvider{}, "test-model", "/tmp/test", nil)
+	sm.SetRunner(func(ctx context.Context, req SubagentRequest) (*ToolLoopResult, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	sm.SpawnFor(context.Background(), "main", "crawl the docs", "crawler", "", nil, "cli", "direct", nil)
+	sm.SpawnFor(context.Background(), "other", "someone else's", "", "", nil, "cli", "direct", nil)
+
+	list := NewSubagentListTool(sm, "main").Execute(context.Background(), map[string]any{})
+	if !strings.Contains(list.ForLLM, "subagent-1 [running] crawler") {
+		t.Errorf("list missing own task: %s", list.ForLLM)
+	}
+	if strings.Contains(list.ForLLM, "subagent-2") {
+		t.Errorf("list should not show other agents' tasks: %s", list.ForLLM)
+	}
+
+	status := NewSubagentStatusTool(sm, "main").Execute(context.Background(), map[string]any{"task_id": "subagent-1"})
+	if status.IsError || !strings.Contains(status.ForLLM, "Request: crawl the docs") {
+		t.Errorf("unexpected status: %s", status.ForLLM)
+	}
+	if r := NewSubagentStatusTool(sm, "main").Execute(context.Background(), map[string]any{"task_id": "subagent-2"}); !r.IsError {
+		t.Error("expected other agent's task to be hidden")
+	}
+
+	cancel := NewSubagentCancelTool(sm, "main")
+	if r := cancel.Execute(context.Background(), map[string]any{"task_id": "subagent-1"}); r.IsError {
+		t.Fatalf("cancel failed: %s", r.ForLLM)
+	}
+	waitForStatus(t, sm, "subagent-1", SubagentCancelled)
+	if r := cancel.Execute(context.Background(), map[string]any{"task_id": "subagent-1"}); !r.IsError {
+		t.Error("expected error cancelling a finished task")
+	}
+	if r := cancel.Execute(context.Background(), map[string]any{}); !r.IsError {
+		t.Error("expected error without task_id")
+	}
+	sm.Cancel("subagent-2")
+}